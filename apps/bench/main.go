@@ -0,0 +1,56 @@
+//
+// main.go
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/markkurossi/mpc/bench"
+)
+
+func main() {
+	dir := flag.String("dir", os.Getenv("MPCLDIR"),
+		"MPCL root directory for the representative circuits")
+	seed := flag.Int64("seed", 1, "random seed for circuit inputs")
+	protocols := flag.String("protocols", "",
+		"comma-separated list of protocols (default all)")
+	flag.Parse()
+
+	log.SetFlags(0)
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = bench.Representative
+	}
+	circuits, err := bench.LoadCircuits(*dir, files, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	protos := bench.Protocols
+	if len(*protocols) > 0 {
+		protos = nil
+		for _, name := range strings.Split(*protocols, ",") {
+			p, err := bench.LookupProtocol(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			protos = append(protos, p)
+		}
+	}
+
+	results, err := bench.Run(circuits, protos)
+	if err != nil {
+		log.Fatal(err)
+	}
+	results.Print(os.Stdout)
+}
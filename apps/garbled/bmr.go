@@ -12,6 +12,7 @@ import (
 
 	"github.com/markkurossi/mpc"
 	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler"
 	"github.com/markkurossi/mpc/compiler/utils"
 	"github.com/markkurossi/mpc/p2p"
 )
@@ -20,7 +21,7 @@ func bmrMode(file string, params *utils.Params, player int) error {
 	fmt.Printf("semi-honest secure BMR protocol\n")
 	fmt.Printf("player: %d\n", player)
 
-	circ, err := loadCircuit(file, params, nil)
+	circ, err := loadCircuit(compiler.New(params), file, nil)
 	if err != nil {
 		return err
 	}
@@ -21,7 +21,7 @@ import (
 )
 
 func compileFiles(files []string, params *utils.Params, inputSizes [][]int,
-	compile, ssa, dot, svg bool, circFormat string) error {
+	compile, ssa, ssaDot, dot, svg bool, circFormat string) error {
 
 	var circ *circuit.Circuit
 	var err error
@@ -73,6 +73,12 @@ func compileFiles(files []string, params *utils.Params, inputSizes [][]int,
 					}
 				}
 			}
+			if ssaDot {
+				params.SSACFGOut, err = makeOutput(file, "cfg.dot")
+				if err != nil {
+					return err
+				}
+			}
 			circ, _, err = compiler.New(params).CompileFile(file, inputSizes)
 			if err != nil {
 				return err
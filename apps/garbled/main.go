@@ -9,16 +9,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"net"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/pprof"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/markkurossi/mpc"
 	"github.com/markkurossi/mpc/circuit"
@@ -29,8 +33,10 @@ import (
 )
 
 var (
-	port    = ":8080"
-	verbose = false
+	port             = ":8080"
+	verbose          = false
+	handshakeTimeout = 30 * time.Second
+	ioTimeout        time.Duration
 )
 
 type input []string
@@ -46,11 +52,39 @@ func (i *input) Set(value string) error {
 	return nil
 }
 
-var inputFlag, peerFlag input
+var inputFlag, peerFlag, widthFlag, peerWidthFlag input
+
+type defines map[string]string
+
+func (d defines) String() string {
+	return fmt.Sprint(map[string]string(d))
+}
+
+func (d defines) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok {
+		val = "true"
+	}
+	d[name] = val
+	return nil
+}
+
+var defineFlag = make(defines)
 
 func init() {
-	flag.Var(&inputFlag, "i", "comma-separated list of circuit inputs")
+	flag.Var(&inputFlag, "i",
+		"comma-separated list of circuit inputs, or - to read inputs from stdin")
 	flag.Var(&peerFlag, "pi", "comma-separated list of peer's circuit inputs")
+	flag.Var(&widthFlag, "w",
+		"comma-separated list of input bit widths, _ for the input size")
+	flag.Var(&peerWidthFlag, "pw",
+		"comma-separated list of peer's input bit widths")
+	flag.Var(defineFlag, "D",
+		"define compile-time constant `name[=value]`, can be repeated")
+	flag.DurationVar(&handshakeTimeout, "handshake-timeout", handshakeTimeout,
+		"evaluator connection handshake timeout")
+	flag.DurationVar(&ioTimeout, "io-timeout", ioTimeout,
+		"evaluator connection I/O timeout, 0 for no timeout")
 }
 
 func main() {
@@ -58,8 +92,10 @@ func main() {
 	stream := flag.Bool("stream", false, "streaming mode")
 	compile := flag.Bool("circ", false, "compile MPCL to circuit")
 	circFormat := flag.String("format", "mpclc",
-		"circuit format: mpclc, bristol")
+		"circuit format: mpclc, bristol, smtlib, json")
 	ssa := flag.Bool("ssa", false, "compile MPCL to SSA assembly")
+	ssaDot := flag.Bool("ssa-dot", false,
+		"create Graphviz DOT output of the SSA control-flow graph")
 	dot := flag.Bool("dot", false, "create Graphviz DOT output")
 	svg := flag.Bool("svg", false, "create SVG output")
 	optimize := flag.Int("O", 1, "optimization level")
@@ -71,14 +107,45 @@ func main() {
 	bmr := flag.Int("bmr", -1, "semi-honest secure BMR protocol player number")
 	mpclcErrLoc := flag.Bool("mpclc-err-loc", false,
 		"print MPCLC error locations")
+	allErrors := flag.Bool("all-errors", false,
+		"report all syntax errors instead of stopping at the first")
 	benchmarkCompile := flag.Bool("benchmark-compile", false,
 		"benchmark MPCL compilation")
+	indexPolicy := flag.String("index-policy", "zero",
+		"out-of-bounds array index policy: zero, flag")
+	noBoundsCheck := flag.Bool("no-bounds-check", false,
+		"assume non-constant array indices are in bounds (unsafe)")
+	estimate := flag.Bool("estimate", false,
+		"estimate protocol bandwidth and runtime without running it")
+	costModel := flag.String("cost-model", circuit.DefaultCostModelName,
+		"circuit cost model: freexor, legacy")
+	labelEncoding := flag.String("label-encoding", "msb",
+		"on-wire label encoding: msb, lsb")
+	zeroizeLabels := flag.Bool("zeroize-labels", false,
+		"clear the reclaimed wire labels in the streaming garbler")
+	maxOTs := flag.Int("max-ots", 0,
+		"maximum number of evaluator's OTs, 0 for no limit")
+	maxGates := flag.Int("max-gates", 0,
+		"maximum number of evaluated gates, 0 for no limit")
+	maxWires := flag.Int("max-wires", 0,
+		"maximum number of evaluated wires, 0 for no limit")
+	trace := flag.String("trace", "",
+		"trace the cleartext evaluation of the `wire` with both parties' "+
+			"inputs (-i and -pi)")
 	flag.Parse()
 
 	log.SetFlags(0)
 
 	verbose = *fVerbose
 
+	if len(inputFlag) == 1 && inputFlag[0] == "-" {
+		var err error
+		inputFlag, err = circuit.ReadInputs(os.Stdin)
+		if err != nil {
+			log.Fatalf("failed to read inputs: %s", err)
+		}
+	}
+
 	if len(*cpuprofile) > 0 {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -97,16 +164,59 @@ func main() {
 	params.Verbose = *fVerbose
 	params.Diagnostics = *fDiagnostics
 	params.MPCLCErrorLoc = *mpclcErrLoc
+	params.AllErrors = *allErrors
 	params.BenchmarkCompile = *benchmarkCompile
+	params.Defines = defineFlag
+
+	policy, err := utils.ParseIndexPolicy(*indexPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params.IndexPolicy = policy
+	params.NoBoundsCheck = *noBoundsCheck
+
+	params.CostModel, err = circuit.ParseCostModel(*costModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	params.LabelEncoding, err = ot.ParseLabelEncoding(*labelEncoding)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params.ZeroizeLabels = *zeroizeLabels
+	params.MaxOTs = *maxOTs
+	params.MaxGates = *maxGates
+	params.MaxWires = *maxWires
+
+	if len(widthFlag) > 0 || len(peerWidthFlag) > 0 {
+		widths, err := circuit.InputWidths(widthFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		peerWidths, err := circuit.InputWidths(peerWidthFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *evaluator {
+			params.InputWidths = [][]int{peerWidths, widths}
+		} else {
+			params.InputWidths = [][]int{widths, peerWidths}
+		}
+	}
 
 	if *optimize > 0 {
 		params.OptPruneGates = true
+		params.OptCoalesceMovs = true
+	}
+	if *optimize > 1 {
+		params.SubcircuitCalls = true
 	}
-	if *ssa && !*compile {
+	if (*ssa || *ssaDot) && !*compile {
 		params.NoCircCompile = true
 	}
 
-	if *compile || *ssa {
+	if *compile || *ssa || *ssaDot {
 		inputSizes := make([][]int, 2)
 		iSizes, err := circuit.InputSizes(inputFlag)
 		if err != nil {
@@ -125,7 +235,7 @@ func main() {
 		}
 
 		err = compileFiles(flag.Args(), params, inputSizes,
-			*compile, *ssa, *dot, *svg, *circFormat)
+			*compile, *ssa, *ssaDot, *dot, *svg, *circFormat)
 		if err != nil {
 			log.Fatalf("compile failed: %s", err)
 		}
@@ -133,13 +243,12 @@ func main() {
 		return
 	}
 
-	var err error
-
 	oti := ot.NewCO()
 
 	if *stream {
 		if *evaluator {
-			err = streamEvaluatorMode(oti, inputFlag, len(*cpuprofile) > 0)
+			err = streamEvaluatorMode(params, oti, inputFlag,
+				len(*cpuprofile) > 0)
 		} else {
 			err = streamGarblerMode(params, oti, inputFlag, flag.Args())
 		}
@@ -155,6 +264,22 @@ func main() {
 	}
 	file := flag.Args()[0]
 
+	if *estimate {
+		err = estimateMode(file, params, *evaluator)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(*trace) > 0 {
+		err = traceMode(file, params, *trace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if *bmr >= 0 {
 		err = bmrMode(file, params, *bmr)
 		if err != nil {
@@ -173,7 +298,7 @@ func main() {
 	}
 }
 
-func loadCircuit(file string, params *utils.Params, inputSizes [][]int) (
+func loadCircuit(cc *compiler.Compiler, file string, inputSizes [][]int) (
 	*circuit.Circuit, error) {
 
 	var circ *circuit.Circuit
@@ -185,7 +310,7 @@ func loadCircuit(file string, params *utils.Params, inputSizes [][]int) (
 			return nil, err
 		}
 	} else if strings.HasSuffix(file, ".mpcl") {
-		circ, _, err = compiler.New(params).CompileFile(file, inputSizes)
+		circ, _, err = cc.CompileFile(file, inputSizes)
 		if err != nil {
 			return nil, err
 		}
@@ -202,6 +327,87 @@ func loadCircuit(file string, params *utils.Params, inputSizes [][]int) (
 	return circ, err
 }
 
+func estimateMode(file string, params *utils.Params, evaluator bool) error {
+	iSizes, err := circuit.InputSizes(inputFlag)
+	if err != nil {
+		return err
+	}
+	pSizes, err := circuit.InputSizes(peerFlag)
+	if err != nil {
+		return err
+	}
+	inputSizes := [][]int{iSizes, pSizes}
+	if evaluator {
+		inputSizes[0], inputSizes[1] = pSizes, iSizes
+	}
+
+	circ, err := loadCircuit(compiler.New(params), file, inputSizes)
+	if err != nil {
+		return err
+	}
+	e := circuit.EstimateCost(circ, circuit.DefaultCostParams())
+
+	fmt.Printf("circuit: %v\n", circ)
+	fmt.Printf(" - garbled tables: %s\n", e.GateBytes)
+	fmt.Printf(" - garbler inputs: %s\n", e.InputBytes)
+	fmt.Printf(" - evaluator OTs:  %s (%d OTs)\n", e.OTBytes, e.NumOTs)
+	fmt.Printf(" - outputs:        %s\n", e.OutputBytes)
+	fmt.Printf(" - total:          %s\n", e.Bytes)
+	fmt.Printf(" - time:           %s\n", e.Time)
+	fmt.Printf(" - cost:           %d\n", circ.CostWith(params.CostModel))
+	return nil
+}
+
+func traceMode(file string, params *utils.Params, wire string) error {
+	iSizes, err := circuit.InputSizes(inputFlag)
+	if err != nil {
+		return err
+	}
+	pSizes, err := circuit.InputSizes(peerFlag)
+	if err != nil {
+		return err
+	}
+	circ, err := loadCircuit(compiler.New(params), file,
+		[][]int{iSizes, pSizes})
+	if err != nil {
+		return err
+	}
+	if len(circ.Inputs) != 2 {
+		return fmt.Errorf("invalid circuit for 2-party computation: %d parties",
+			len(circ.Inputs))
+	}
+	wires, err := circ.LookupWires(wire)
+	if err != nil {
+		return err
+	}
+
+	// Both parties' inputs are known so we can evaluate the circuit
+	// in cleartext.
+	var inputs []*big.Int
+	for idx, flags := range [][]string{inputFlag, peerFlag} {
+		arg := circ.Inputs[idx]
+		v, err := arg.Parse(flags)
+		if err != nil {
+			return err
+		}
+		if len(arg.Compound) > 0 {
+			inputs = append(inputs, arg.Compound.Split(v)...)
+		} else {
+			inputs = append(inputs, v)
+		}
+	}
+	trace, result, err := circuit.TraceEval(circ, inputs)
+	if err != nil {
+		return err
+	}
+	for _, w := range wires {
+		fmt.Printf("%v:\n", w)
+		trace.Dump(os.Stdout, w)
+	}
+	mpc.PrintResults(result, circ.Outputs)
+	return nil
+}
+
 func memProfile(file string) {
 	if len(file) == 0 {
 		return
@@ -230,49 +436,95 @@ func evaluatorMode(oti ot.OT, file string, params *utils.Params,
 	}
 	inputSizes[1] = myInputSizes
 
-	ln, err := net.Listen("tcp", port)
+	ln, err := p2p.Listen(port)
 	if err != nil {
 		return err
 	}
+	ln.HandshakeTimeout = handshakeTimeout
+	ln.IOTimeout = ioTimeout
 	fmt.Printf("Listening for connections at %s\n", port)
 
+	// Close the listener on SIGINT.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
+	go func() {
+		if _, ok := <-sigs; ok {
+			fmt.Printf("Interrupted, closing listener\n")
+			ln.Close()
+		}
+	}()
+	defer ln.Close()
+
+	// The compiler reuses the parsed program when the peer input
+	// sizes change.
+	cc := compiler.New(params)
+	limits := params.Limits()
+
 	var oPeerInputSizes []int
 	var circ *circuit.Circuit
 
 	for {
-		nc, err := ln.Accept()
-		if err != nil {
-			return err
-		}
-		fmt.Printf("New connection from %s\n", nc.RemoteAddr())
-
-		conn := p2p.NewConn(nc)
-
-		err = conn.SendInputSizes(myInputSizes)
-		if err != nil {
-			conn.Close()
-			return err
-		}
-		err = conn.Flush()
-		if err != nil {
-			conn.Close()
-			return err
-		}
-		peerInputSizes, err := conn.ReceiveInputSizes()
+		var peerInputSizes []int
+		conn, err := ln.Accept(func(conn *p2p.Conn) error {
+			fmt.Printf("New connection from %s\n", conn.RemoteAddr())
+			err := conn.Handshake(p2p.RoleEvaluator)
+			if err != nil {
+				return err
+			}
+			err = conn.SendInputSizes(myInputSizes)
+			if err != nil {
+				return err
+			}
+			err = conn.Flush()
+			if err != nil {
+				return err
+			}
+			peerInputSizes, err = conn.ReceiveInputSizes()
+			if err != nil {
+				return err
+			}
+			// Reject excessive peer inputs before compiling the
+			// circuit for them.
+			var bits int
+			for _, size := range peerInputSizes {
+				bits += size
+			}
+			return limits.CheckWires(bits)
+		})
 		if err != nil {
-			conn.Close()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			var herr *p2p.HandshakeError
+			if errors.As(err, &herr) {
+				fmt.Printf("%s\n", err)
+				continue
+			}
 			return err
 		}
 		inputSizes[0] = peerInputSizes
+		conn.SetLabelEncoding(params.LabelEncoding)
 
 		if circ == nil || slices.Compare(peerInputSizes, oPeerInputSizes) != 0 {
-			circ, err = loadCircuit(file, params, inputSizes)
+			circ, err = loadCircuit(cc, file, inputSizes)
 			if err != nil {
 				conn.Close()
 				return err
 			}
 			oPeerInputSizes = peerInputSizes
 		}
+		if err := limits.CheckCircuit(circ); err != nil {
+			conn.Close()
+			if once {
+				return err
+			}
+			fmt.Printf("%s: %s\n", conn.RemoteAddr(), err)
+			continue
+		}
 		circ.PrintInputs(circuit.IDEvaluator, inputFlag)
 		if len(circ.Inputs) != 2 {
 			return fmt.Errorf("invalid circuit for 2-party MPC: %d parties",
@@ -287,7 +539,11 @@ func evaluatorMode(oti ot.OT, file string, params *utils.Params,
 		result, err := circuit.Evaluator(conn, oti, circ, input, verbose)
 		conn.Close()
 		if err != nil && err != io.EOF {
-			return err
+			if once {
+				return err
+			}
+			fmt.Printf("%s: %s\n", conn.RemoteAddr(), err)
+			continue
 		}
 		mpc.PrintResults(result, circ.Outputs)
 		if once {
@@ -309,8 +565,12 @@ func garblerMode(oti ot.OT, file string, params *utils.Params) error {
 		return err
 	}
 	conn := p2p.NewConn(nc)
+	conn.SetLabelEncoding(params.LabelEncoding)
 	defer conn.Close()
 
+	if err := conn.Handshake(p2p.RoleGarbler); err != nil {
+		return err
+	}
 	peerInputSizes, err := conn.ReceiveInputSizes()
 	if err != nil {
 		conn.Close()
@@ -328,7 +588,7 @@ func garblerMode(oti ot.OT, file string, params *utils.Params) error {
 		return err
 	}
 
-	circ, err := loadCircuit(file, params, inputSizes)
+	circ, err := loadCircuit(compiler.New(params), file, inputSizes)
 	if err != nil {
 		return err
 	}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -7,9 +7,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/markkurossi/mpc"
@@ -20,44 +23,70 @@ import (
 	"github.com/markkurossi/mpc/p2p"
 )
 
-func streamEvaluatorMode(oti ot.OT, input input, once bool) error {
+func streamEvaluatorMode(params *utils.Params, oti ot.OT, input input,
+	once bool) error {
+
 	inputSizes, err := circuit.InputSizes(input)
 	if err != nil {
 		return err
 	}
 
-	ln, err := net.Listen("tcp", port)
+	ln, err := p2p.Listen(port)
 	if err != nil {
 		return err
 	}
+	ln.HandshakeTimeout = handshakeTimeout
+	ln.IOTimeout = ioTimeout
 	fmt.Printf("Listening for connections at %s\n", port)
 
-	for {
-		nc, err := ln.Accept()
-		if err != nil {
-			return err
+	// Close the listener on SIGINT.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}()
+	go func() {
+		if _, ok := <-sigs; ok {
+			fmt.Printf("Interrupted, closing listener\n")
+			ln.Close()
 		}
-		fmt.Printf("New connection from %s\n", nc.RemoteAddr())
-
-		conn := p2p.NewConn(nc)
+	}()
+	defer ln.Close()
 
-		err = conn.SendInputSizes(inputSizes)
-		if err != nil {
-			conn.Close()
-			return err
-		}
-		err = conn.Flush()
+	for {
+		conn, err := ln.Accept(func(conn *p2p.Conn) error {
+			fmt.Printf("New connection from %s\n", conn.RemoteAddr())
+			conn.SetLabelEncoding(params.LabelEncoding)
+			err := conn.Handshake(p2p.RoleEvaluator)
+			if err != nil {
+				return err
+			}
+			err = conn.SendInputSizes(inputSizes)
+			if err != nil {
+				return err
+			}
+			return conn.Flush()
+		})
 		if err != nil {
-			conn.Close()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			// Drop the failed connection and keep accepting.
+			var herr *p2p.HandshakeError
+			if errors.As(err, &herr) {
+				fmt.Printf("%s\n", err)
+				continue
+			}
 			return err
 		}
 
-		outputs, result, err := circuit.StreamEvaluator(conn, oti, input,
-			verbose)
+		outputs, result, err := circuit.StreamEvaluatorLimits(conn, oti,
+			input, params.Limits(), verbose)
 		conn.Close()
 
 		if err != nil && err != io.EOF {
-			return fmt.Errorf("%s: %v", nc.RemoteAddr(), err)
+			return fmt.Errorf("%s: %v", conn.RemoteAddr(), err)
 		}
 
 		mpc.PrintResults(result, outputs)
@@ -88,6 +117,9 @@ func streamGarblerMode(params *utils.Params, oti ot.OT, input input,
 	conn := p2p.NewConn(nc)
 	defer conn.Close()
 
+	if err := conn.Handshake(p2p.RoleGarbler); err != nil {
+		return err
+	}
 	sizes, err = conn.ReceiveInputSizes()
 	if err != nil {
 		return err
@@ -23,6 +23,7 @@ func main() {
 	evaluator := flag.Bool("e", false, "evaluator / garbler mode")
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to `file`")
 	testIO := flag.Int64("test-io", 0, "test I/O performance")
+	testOT := flag.Int("test-ot", 0, "test OT and garbled table performance")
 	flag.Parse()
 
 	log.SetFlags(0)
@@ -53,4 +54,18 @@ func main() {
 		}
 		return
 	}
+	if *testOT > 0 {
+		if *evaluator {
+			err := evaluatorTestOT(*testOT, len(*cpuprofile) > 0)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			err := garblerTestOT(*testOT)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
 }
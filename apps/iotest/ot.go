@@ -0,0 +1,250 @@
+//
+// ot.go
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+	"github.com/markkurossi/tabulate"
+)
+
+// Phase contains the measurements of one benchmark phase.
+type Phase struct {
+	Name  string
+	Count int
+	Time  time.Duration
+	Xfer  uint64
+}
+
+// PerOp returns the amortized time of one phase operation.
+func (p Phase) PerOp() time.Duration {
+	if p.Count == 0 {
+		return 0
+	}
+	return p.Time / time.Duration(p.Count)
+}
+
+// Throughput returns the phase throughput in MB/s.
+func (p Phase) Throughput() float64 {
+	if p.Time == 0 {
+		return 0
+	}
+	return float64(p.Xfer) / 1000000 / p.Time.Seconds()
+}
+
+// measure runs the phase function f and records its time and the
+// number of bytes transferred over the connection. The function
+// synchronizes the peers at the end of the phase so that both peers
+// measure the full phase.
+func measure(conn *p2p.Conn, name string, count int, sender bool,
+	f func() error) (Phase, error) {
+
+	start := time.Now()
+	xfer := conn.Stats.Sum()
+
+	if err := f(); err != nil {
+		return Phase{}, err
+	}
+	if sender {
+		if _, err := conn.ReceiveByte(); err != nil {
+			return Phase{}, err
+		}
+	} else {
+		if err := conn.SendByte(0); err != nil {
+			return Phase{}, err
+		}
+		if err := conn.Flush(); err != nil {
+			return Phase{}, err
+		}
+	}
+
+	return Phase{
+		Name:  name,
+		Count: count,
+		Time:  time.Since(start),
+		Xfer:  conn.Stats.Sum() - xfer,
+	}, nil
+}
+
+// otSender runs the OT benchmark sender over the connection. The
+// benchmark measures the base OT setup, count OTs, and the transfer
+// of count garbled AND tables. The CO OT is used for the OTs as the
+// tree does not implement an OT extension; the OT phase reports the
+// per-OT cost of the CO OT.
+func otSender(conn *p2p.Conn, count int) ([]Phase, error) {
+	oti := ot.NewCO()
+	var result []Phase
+
+	phase, err := measure(conn, "Base OT", 1, true, func() error {
+		return oti.InitSender(conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	wires := make([]ot.Wire, count)
+	for i := 0; i < count; i++ {
+		l0, err := ot.NewLabel(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		l1, err := ot.NewLabel(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		wires[i] = ot.Wire{
+			L0: l0,
+			L1: l1,
+		}
+	}
+	phase, err = measure(conn, "OT", count, true, func() error {
+		if err := oti.Send(wires); err != nil {
+			return err
+		}
+		return conn.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	// Half-gates AND tables have two labels.
+	table := make([]ot.Label, 2)
+	phase, err = measure(conn, "Tables", count, true, func() error {
+		for i := 0; i < count; i++ {
+			if err := conn.SendLabels(table); err != nil {
+				return err
+			}
+		}
+		return conn.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	return result, nil
+}
+
+// otReceiver runs the OT benchmark receiver over the connection.
+func otReceiver(conn *p2p.Conn, count int) ([]Phase, error) {
+	oti := ot.NewCO()
+	var result []Phase
+
+	phase, err := measure(conn, "Base OT", 1, false, func() error {
+		return oti.InitReceiver(conn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	flags := make([]bool, count)
+	for i := 0; i < count; i++ {
+		flags[i] = i%2 == 0
+	}
+	labels := make([]ot.Label, count)
+	phase, err = measure(conn, "OT", count, false, func() error {
+		return oti.Receive(flags, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	table := make([]ot.Label, 2)
+	phase, err = measure(conn, "Tables", count, false, func() error {
+		for i := 0; i < count; i++ {
+			if err := conn.ReceiveLabels(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, phase)
+
+	return result, nil
+}
+
+func printPhases(out io.Writer, phases []Phase) {
+	tab := tabulate.New(tabulate.UnicodeLight)
+	tab.Header("Phase").SetAlign(tabulate.ML)
+	tab.Header("Count").SetAlign(tabulate.MR)
+	tab.Header("Time").SetAlign(tabulate.MR)
+	tab.Header("Per Op").SetAlign(tabulate.MR)
+	tab.Header("Xfer").SetAlign(tabulate.MR)
+	tab.Header("MB/s").SetAlign(tabulate.MR)
+
+	for _, phase := range phases {
+		row := tab.Row()
+		row.Column(phase.Name)
+		row.Column(fmt.Sprintf("%v", phase.Count))
+		row.Column(phase.Time.String())
+		row.Column(phase.PerOp().String())
+		row.Column(circuit.FileSize(phase.Xfer).String())
+		row.Column(fmt.Sprintf("%.2f", phase.Throughput()))
+	}
+	tab.Print(out)
+}
+
+func evaluatorTestOT(count int, once bool) error {
+	ln, err := net.Listen("tcp", port)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Listening for connections at %s\n", port)
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("New connection from %s\n", nc.RemoteAddr())
+
+		conn := p2p.NewConn(nc)
+		phases, err := otReceiver(conn, count)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+		printPhases(os.Stdout, phases)
+
+		if once {
+			return nil
+		}
+	}
+}
+
+func garblerTestOT(count int) error {
+	nc, err := net.Dial("tcp", port)
+	if err != nil {
+		return err
+	}
+	conn := p2p.NewConn(nc)
+	defer conn.Close()
+
+	phases, err := otSender(conn, count)
+	if err != nil {
+		return err
+	}
+	printPhases(os.Stdout, phases)
+	return nil
+}
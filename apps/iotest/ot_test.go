@@ -0,0 +1,68 @@
+//
+// ot_test.go
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestOTBenchmark(t *testing.T) {
+	const count = 256
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		phases []Phase
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		phases, err := otReceiver(eConn, count)
+		ch <- result{
+			phases: phases,
+			err:    err,
+		}
+	}()
+	phases, err := otSender(gConn, count)
+	if err != nil {
+		t.Fatalf("otSender failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("otReceiver failed: %v", eResult.err)
+	}
+
+	expected := []struct {
+		name  string
+		count int
+	}{
+		{"Base OT", 1},
+		{"OT", count},
+		{"Tables", count},
+	}
+	for _, p := range [][]Phase{phases, eResult.phases} {
+		if len(p) != len(expected) {
+			t.Fatalf("got %d phases, expected %d", len(p), len(expected))
+		}
+		for idx, e := range expected {
+			if p[idx].Name != e.name || p[idx].Count != e.count {
+				t.Errorf("phase %d: got %s/%d, expected %s/%d",
+					idx, p[idx].Name, p[idx].Count, e.name, e.count)
+			}
+			if p[idx].Xfer == 0 || p[idx].Throughput() <= 0 {
+				t.Errorf("phase %s: no throughput: %v bytes in %v",
+					p[idx].Name, p[idx].Xfer, p[idx].Time)
+			}
+		}
+	}
+}
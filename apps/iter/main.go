@@ -1,7 +1,7 @@
 //
 // main.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -17,14 +17,15 @@ import (
 	"runtime/pprof"
 	"strings"
 
+	"github.com/markkurossi/mpc/circuit"
 	"github.com/markkurossi/mpc/compiler"
 	"github.com/markkurossi/mpc/compiler/utils"
 )
 
-var template = `
+var code = `
 package main
 
-func main(a, b int%d) int {
+func main(a, b int) int {
     return a * b
 }
 `
@@ -45,8 +46,15 @@ func main() {
 	minLimit := flag.Int("min", 8, "treshold minimum limit")
 	maxLimit := flag.Int("max", 22, "treshold maximum limit")
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to `file`")
+	costModel := flag.String("cost-model", circuit.DefaultCostModelName,
+		"circuit cost model: freexor, legacy")
 	flag.Parse()
 
+	model, err := circuit.ParseCostModel(*costModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	if len(*cpuprofile) > 0 {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -65,7 +73,6 @@ func main() {
 	for i := 0; i < *numWorkers; i++ {
 		go func(bits int) {
 			for ; bits <= *endBits; bits += *numWorkers {
-				code := fmt.Sprintf(template, bits)
 				var bestLimit int
 				var bestCost uint64
 				var worstLimit int
@@ -73,6 +80,8 @@ func main() {
 				var costs []uint64
 
 				params := utils.NewParams()
+				params.CostModel = model
+				params.InputWidths = [][]int{{bits}, {bits}}
 
 				for limit := *minLimit; limit <= *maxLimit; limit++ {
 					params.CircMultArrayTreshold = limit
@@ -81,7 +90,7 @@ func main() {
 						log.Fatalf("Compilation %d:%d failed: %s\n%s",
 							bits, limit, err, code)
 					}
-					cost := circ.Cost()
+					cost := circ.CostWith(params.CostModel)
 					costs = append(costs, cost)
 
 					if bestCost == 0 || cost < bestCost ||
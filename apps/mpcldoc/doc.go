@@ -491,6 +491,16 @@ func formatType(out Output, ti *ast.TypeInfo, pp bool) *text.Text {
 }
 
 func annotations(out Output, annotations ast.Annotations) error {
+	// The directives are not part of the documentation text.
+	directives := annotations.Directives()
+	annotations = annotations.Prose()
+	if directives.Has("deprecated") {
+		if len(annotations) > 0 {
+			annotations = append(annotations, "")
+		}
+		annotations = append(annotations,
+			strings.TrimSpace("Deprecated: "+directives.Get("deprecated")))
+	}
 	if len(annotations) == 0 {
 		return nil
 	}
@@ -0,0 +1,84 @@
+//
+// main.go
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/markkurossi/mpc/compiler"
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func main() {
+	write := flag.Bool("w", false,
+		"write result to the source file instead of stdout")
+	list := flag.Bool("l", false,
+		"list files whose formatting differs from mpclfmt's")
+	flag.Parse()
+
+	log.SetFlags(0)
+
+	if len(flag.Args()) == 0 {
+		fmt.Println("no files specified")
+		os.Exit(1)
+	}
+	for _, file := range flag.Args() {
+		err := format(file, *write, *list)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func format(file string, write, list bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	// The formatter works on the syntax tree which does not contain
+	// the lines excluded by the conditional compilation directives.
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			return fmt.Errorf("%s: can't format conditional compilation",
+				file)
+		}
+	}
+	// Parse the file without its imports; the formatter needs only
+	// the file's syntax tree.
+	parser := compiler.NewParser(file, compiler.New(utils.NewParams()),
+		utils.NewLogger(os.Stderr), bytes.NewReader(data))
+	pkg, err := parser.Parse(nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = ast.Format(pkg, &buf)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(data, buf.Bytes())
+	if list && changed {
+		fmt.Println(file)
+	}
+	if write {
+		if changed {
+			return os.WriteFile(file, buf.Bytes(), 0644)
+		}
+		return nil
+	}
+	if !list {
+		_, err = os.Stdout.Write(buf.Bytes())
+	}
+	return err
+}
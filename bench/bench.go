@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package bench implements a benchmark harness that runs circuits
+// through the available protocol back ends over in-memory
+// connections and compares their bandwidth and wall time.
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"path"
+	"time"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/p2p"
+	"github.com/markkurossi/tabulate"
+)
+
+// Circuit defines a benchmark circuit and its inputs.
+type Circuit struct {
+	Name    string
+	Circuit *circuit.Circuit
+	Inputs  []*big.Int
+}
+
+// NewCircuit creates a benchmark circuit for circ. The inputs are
+// generated from the random source rnd.
+func NewCircuit(name string, circ *circuit.Circuit, rnd *rand.Rand) (
+	Circuit, error) {
+
+	if len(circ.Inputs) != 2 {
+		return Circuit{}, fmt.Errorf("%s: expected 2 inputs, got %d",
+			name, len(circ.Inputs))
+	}
+	var inputs []*big.Int
+	for _, arg := range circ.Inputs {
+		max := new(big.Int).Lsh(big.NewInt(1), uint(arg.Type.Bits))
+		inputs = append(inputs, new(big.Int).Rand(rnd, max))
+	}
+	return Circuit{
+		Name:    name,
+		Circuit: circ,
+		Inputs:  inputs,
+	}, nil
+}
+
+// Representative are the representative circuits of the
+// benchmark. The circuit files are relative to the MPCL root
+// directory.
+var Representative = []string{
+	"pkg/math/add64.circ",
+	"pkg/math/mul64.circ",
+	"pkg/math/div64.circ",
+	"pkg/crypto/aes/aes_128.circ",
+	"pkg/crypto/sha256/sha256.circ",
+}
+
+// LoadCircuits loads the circuit files from the directory dir. The
+// circuit inputs are generated from the seed so that the benchmark
+// runs are reproducible.
+func LoadCircuits(dir string, files []string, seed int64) (
+	[]Circuit, error) {
+
+	rnd := rand.New(rand.NewSource(seed))
+
+	var result []Circuit
+	for _, file := range files {
+		circ, err := circuit.Parse(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		c, err := NewCircuit(path.Base(file), circ, rnd)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// Result contains the benchmark result of a protocol for a circuit.
+type Result struct {
+	Circuit  string
+	Protocol string
+	Setup    time.Duration
+	Time     time.Duration
+	Sent     uint64
+	Recvd    uint64
+	Outputs  []*big.Int
+}
+
+// Results contains benchmark results.
+type Results []Result
+
+// Run runs the circuits through the protocols. The function returns
+// an error if the protocols compute different outputs for a circuit.
+func Run(circuits []Circuit, protocols []Protocol) (Results, error) {
+	var results Results
+
+	for _, c := range circuits {
+		var expected []*big.Int
+		for _, proto := range protocols {
+			result, err := run(c, proto)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", c.Name, proto.Name, err)
+			}
+			if expected == nil {
+				expected = result.Outputs
+			} else if !equal(expected, result.Outputs) {
+				return nil, fmt.Errorf("%s: %s: result mismatch: %v != %v",
+					c.Name, proto.Name, result.Outputs, expected)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func run(c Circuit, proto Protocol) (Result, error) {
+	result := Result{
+		Circuit:  c.Name,
+		Protocol: proto.Name,
+	}
+
+	var state interface{}
+	var err error
+
+	if proto.Setup != nil {
+		start := time.Now()
+		state, err = proto.Setup(c.Circuit)
+		if err != nil {
+			return result, err
+		}
+		result.Setup = time.Since(start)
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	start := time.Now()
+	result.Outputs, err = proto.Run(state, gConn, eConn, c.Circuit,
+		c.Inputs[0], c.Inputs[1])
+	if err != nil {
+		return result, err
+	}
+	result.Time = time.Since(start)
+	result.Sent = gConn.Stats.Sent.Load()
+	result.Recvd = gConn.Stats.Recvd.Load()
+
+	return result, nil
+}
+
+func equal(a, b []*big.Int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx, v := range a {
+		if v.Cmp(b[idx]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Print prints the results as a comparison table.
+func (results Results) Print(w io.Writer) {
+	tab := tabulate.New(tabulate.UnicodeLight)
+	tab.Header("Circuit").SetAlign(tabulate.ML)
+	tab.Header("Protocol").SetAlign(tabulate.ML)
+	tab.Header("Setup").SetAlign(tabulate.MR)
+	tab.Header("Time").SetAlign(tabulate.MR)
+	tab.Header("Sent").SetAlign(tabulate.MR)
+	tab.Header("Recvd").SetAlign(tabulate.MR)
+	tab.Header("Total").SetAlign(tabulate.MR)
+
+	for _, result := range results {
+		row := tab.Row()
+		row.Column(result.Circuit)
+		row.Column(result.Protocol)
+		row.Column(result.Setup.String())
+		row.Column(result.Time.String())
+		row.Column(circuit.FileSize(result.Sent).String())
+		row.Column(circuit.FileSize(result.Recvd).String())
+		row.Column(circuit.FileSize(result.Sent + result.Recvd).String())
+	}
+	tab.Print(w)
+}
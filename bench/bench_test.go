@@ -0,0 +1,68 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bench
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	circuits, err := LoadCircuits("..", []string{"pkg/math/add64.circ"}, 1)
+	if err != nil {
+		t.Fatalf("LoadCircuits failed: %v", err)
+	}
+	results, err := Run(circuits, Protocols)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != len(Protocols) {
+		t.Fatalf("got %d results, expected %d", len(results), len(Protocols))
+	}
+
+	inputs := circuits[0].Inputs
+	expected := new(big.Int).Add(inputs[0], inputs[1])
+	expected.SetBit(expected, 64, 0)
+
+	for idx, result := range results {
+		if result.Protocol != Protocols[idx].Name {
+			t.Errorf("result %d: protocol %s, expected %s",
+				idx, result.Protocol, Protocols[idx].Name)
+		}
+		if len(result.Outputs) != 1 || result.Outputs[0].Cmp(expected) != 0 {
+			t.Errorf("%s: got %v, expected %v",
+				result.Protocol, result.Outputs, expected)
+		}
+		if result.Protocol != "cleartext" && result.Sent == 0 {
+			t.Errorf("%s: no bytes sent", result.Protocol)
+		}
+	}
+
+	var buf bytes.Buffer
+	results.Print(&buf)
+	for _, p := range Protocols {
+		if !strings.Contains(buf.String(), p.Name) {
+			t.Errorf("results table does not contain protocol %s", p.Name)
+		}
+	}
+}
+
+func TestLoadCircuitsReproducible(t *testing.T) {
+	a, err := LoadCircuits("..", []string{"pkg/math/add64.circ"}, 42)
+	if err != nil {
+		t.Fatalf("LoadCircuits failed: %v", err)
+	}
+	b, err := LoadCircuits("..", []string{"pkg/math/add64.circ"}, 42)
+	if err != nil {
+		t.Fatalf("LoadCircuits failed: %v", err)
+	}
+	if !equal(a[0].Inputs, b[0].Inputs) {
+		t.Errorf("inputs differ: %v != %v", a[0].Inputs, b[0].Inputs)
+	}
+}
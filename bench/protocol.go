@@ -0,0 +1,125 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bench
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// Protocol defines a two-party protocol back end. The optional Setup
+// runs the input-independent preprocessing of the protocol and its
+// result is passed to Run. The Run function runs the protocol between
+// the garbler connection gConn and the evaluator connection eConn
+// with the garbler input g and the evaluator input e.
+type Protocol struct {
+	Name  string
+	Setup func(circ *circuit.Circuit) (interface{}, error)
+	Run   func(state interface{}, gConn, eConn *p2p.Conn,
+		circ *circuit.Circuit, g, e *big.Int) ([]*big.Int, error)
+}
+
+// Protocols lists the available protocol back ends. The cleartext
+// evaluation is the reference for the other protocols. The BMR
+// multi-party protocol is not listed since its online phase does not
+// compute the circuit outputs yet.
+var Protocols = []Protocol{
+	{
+		Name: "cleartext",
+		Run:  runCleartext,
+	},
+	{
+		Name: "garbled",
+		Run:  runGarbled,
+	},
+	{
+		Name:  "garbled-offline",
+		Setup: setupOffline,
+		Run:   runOffline,
+	},
+}
+
+// LookupProtocol returns the protocol with the name.
+func LookupProtocol(name string) (Protocol, error) {
+	for _, p := range Protocols {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Protocol{}, fmt.Errorf("unknown protocol: %s", name)
+}
+
+func runCleartext(state interface{}, gConn, eConn *p2p.Conn,
+	circ *circuit.Circuit, g, e *big.Int) ([]*big.Int, error) {
+
+	return circ.Compute([]*big.Int{g, e})
+}
+
+func runGarbled(state interface{}, gConn, eConn *p2p.Conn,
+	circ *circuit.Circuit, g, e *big.Int) ([]*big.Int, error) {
+
+	return runPeers(eConn, circ, e, func() ([]*big.Int, error) {
+		return circuit.Garbler(gConn, ot.NewCO(), circ, g, false)
+	})
+}
+
+func setupOffline(circ *circuit.Circuit) (interface{}, error) {
+	return circuit.GarbleOffline(circ, rand.Reader)
+}
+
+func runOffline(state interface{}, gConn, eConn *p2p.Conn,
+	circ *circuit.Circuit, g, e *big.Int) ([]*big.Int, error) {
+
+	tables := state.(*circuit.GarbledTables)
+	return runPeers(eConn, circ, e, func() ([]*big.Int, error) {
+		return circuit.GarblerOnline(gConn, ot.NewCO(), circ, tables, g,
+			false)
+	})
+}
+
+// runPeers runs the garbler function and the evaluator on the
+// connection eConn. The function returns an error if the peers
+// compute different results.
+func runPeers(eConn *p2p.Conn, circ *circuit.Circuit, e *big.Int,
+	garbler func() ([]*big.Int, error)) ([]*big.Int, error) {
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := circuit.Evaluator(eConn, ot.NewCO(), circ, e, false)
+		if err != nil {
+			eConn.Close()
+		}
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	gValues, err := garbler()
+	if err != nil {
+		eConn.Close()
+		<-ch
+		return nil, err
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		return nil, eResult.err
+	}
+	if !equal(gValues, eResult.values) {
+		return nil, fmt.Errorf("garbler and evaluator results differ: %v != %v",
+			gValues, eResult.values)
+	}
+	return gValues, nil
+}
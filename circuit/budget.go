@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Budget specifies upper limits for circuit complexity. The zero
+// value of a limit means that the property is not limited.
+type Budget struct {
+	// MaxGates limits the total number of gates.
+	MaxGates uint64
+	// MaxAND limits the number of AND gates.
+	MaxAND uint64
+	// MaxNonXOR limits the number of non-XOR gates (AND, OR, INV).
+	MaxNonXOR uint64
+	// MaxLevels limits the circuit depth in gate levels.
+	MaxLevels uint64
+}
+
+// CheckBudget checks that the circuit is within the limits of the
+// budget. The function assigns the circuit levels and returns an
+// error describing all exceeded limits.
+func (c *Circuit) CheckBudget(budget Budget) error {
+	c.AssignLevels()
+
+	limits := []struct {
+		name  string
+		value uint64
+		limit uint64
+	}{
+		{"gates", c.Stats.Count(), budget.MaxGates},
+		{"AND gates", c.Stats[AND], budget.MaxAND},
+		{"non-XOR gates", c.Stats[AND] + c.Stats[OR] + c.Stats[INV],
+			budget.MaxNonXOR},
+		{"levels", c.Stats[NumLevels], budget.MaxLevels},
+	}
+
+	var exceeded []string
+	for _, l := range limits {
+		if l.limit > 0 && l.value > l.limit {
+			exceeded = append(exceeded,
+				fmt.Sprintf("%d %s (limit %d)", l.value, l.name, l.limit))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("circuit exceeds budget: %s",
+			strings.Join(exceeded, ", "))
+	}
+	return nil
+}
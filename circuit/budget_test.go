@@ -0,0 +1,56 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckBudget(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	circ.AssignLevels()
+	ands := circ.Stats[AND]
+	levels := circ.Stats[NumLevels]
+
+	// Loose budget.
+	err = circ.CheckBudget(Budget{
+		MaxGates:  circ.Stats.Count(),
+		MaxAND:    ands,
+		MaxLevels: levels,
+	})
+	if err != nil {
+		t.Errorf("loose budget: %v", err)
+	}
+	if err := circ.CheckBudget(Budget{}); err != nil {
+		t.Errorf("unlimited budget: %v", err)
+	}
+
+	// Tight budgets.
+	tests := []struct {
+		budget Budget
+		msg    string
+	}{
+		{Budget{MaxAND: ands - 1}, "AND gates"},
+		{Budget{MaxLevels: levels - 1}, "levels"},
+		{Budget{MaxGates: 1}, "gates"},
+		{Budget{MaxNonXOR: 1}, "non-XOR gates"},
+	}
+	for _, test := range tests {
+		err := circ.CheckBudget(test.budget)
+		if err == nil {
+			t.Errorf("budget %+v: expected error", test.budget)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("budget %+v: unexpected error: %v", test.budget, err)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -61,9 +61,69 @@ func (stats Stats) Count() uint64 {
 	return result
 }
 
-// Cost computes the relative computational cost of the circuit.
+// CostModel specifies the relative costs of the gate operations.
+type CostModel [Count]uint64
+
+var (
+	// FreeXORCostModel models free-XOR garbling with half-gates where
+	// the XOR, XNOR, and INV gates are nearly free and the AND and OR
+	// gates dominate the cost.
+	FreeXORCostModel = CostModel{
+		XOR:  1,
+		XNOR: 1,
+		AND:  100,
+		OR:   100,
+		INV:  1,
+	}
+
+	// LegacyCostModel is the original cost model which charges all
+	// non-XOR gates by their garbled table sizes.
+	LegacyCostModel = CostModel{
+		AND: 2,
+		OR:  3,
+		INV: 2,
+	}
+
+	costModels = map[string]CostModel{
+		"freexor": FreeXORCostModel,
+		"legacy":  LegacyCostModel,
+	}
+)
+
+// DefaultCostModelName is the name of the cost model that the Cost
+// functions use.
+const DefaultCostModelName = "legacy"
+
+// DefaultCostModel returns the cost model that the Cost functions
+// use.
+func DefaultCostModel() CostModel {
+	return costModels[DefaultCostModelName]
+}
+
+// ParseCostModel parses the cost model name.
+func ParseCostModel(name string) (CostModel, error) {
+	model, ok := costModels[name]
+	if !ok {
+		return DefaultCostModel(), fmt.Errorf("unknown cost model: %s",
+			name)
+	}
+	return model, nil
+}
+
+// Cost computes the relative computational cost of the circuit with
+// the DefaultCostModel.
 func (stats Stats) Cost() uint64 {
-	return (stats[AND]+stats[INV])*2 + stats[OR]*3
+	return stats.CostWith(DefaultCostModel())
+}
+
+// CostWith computes the relative computational cost of the circuit
+// with the cost model.
+func (stats Stats) CostWith(model CostModel) uint64 {
+	var result uint64
+	for i := XOR; i < Count; i++ {
+		result += stats[i] * model[i]
+	}
+	return result
 }
 
 func (stats Stats) String() string {
@@ -167,11 +227,18 @@ func (c *Circuit) TabulateRow(row *tabulate.Row) {
 	row.Column(fmt.Sprintf("%v", c.NumWires))
 }
 
-// Cost computes the relative computational cost of the circuit.
+// Cost computes the relative computational cost of the circuit with
+// the DefaultCostModel.
 func (c *Circuit) Cost() uint64 {
 	return c.Stats.Cost()
 }
 
+// CostWith computes the relative computational cost of the circuit
+// with the cost model.
+func (c *Circuit) CostWith(model CostModel) uint64 {
+	return c.Stats.CostWith(model)
+}
+
 // Dump prints a debug dump of the circuit.
 func (c *Circuit) Dump() {
 	fmt.Printf("circuit %s\n", c)
@@ -181,7 +248,9 @@ func (c *Circuit) Dump() {
 }
 
 // AssignLevels assigns levels for gates. The level desribes how many
-// steps away the gate is from input wires.
+// steps away the gate is from input wires. The levels are computed in
+// one pass over the gates in their circuit order so the same circuit
+// always gets the same level assignment.
 func (c *Circuit) AssignLevels() {
 	levels := make([]Level, c.NumWires)
 	countByLevel := make([]uint32, c.NumWires)
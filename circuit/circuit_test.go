@@ -17,3 +17,103 @@ func TestSize(t *testing.T) {
 		t.Errorf("unexpected gate size: got %v, expected 20", unsafe.Sizeof(g))
 	}
 }
+
+func TestAssignLevelsDeterministic(t *testing.T) {
+	file := "../pkg/crypto/aes/aes_128.circ"
+
+	circ, err := Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	circ.AssignLevels()
+
+	expected := make([]Level, len(circ.Gates))
+	for idx, g := range circ.Gates {
+		expected[idx] = g.Level
+	}
+	stats := circ.Stats
+
+	for i := 0; i < 10; i++ {
+		c, err := Parse(file)
+		if err != nil {
+			t.Fatalf("Parse failed: %s", err)
+		}
+		// Assign levels twice to check that the assignment does not
+		// depend on previous levels.
+		c.AssignLevels()
+		c.AssignLevels()
+		if c.Stats != stats {
+			t.Fatalf("run %d: stats %v, expected %v", i, c.Stats, stats)
+		}
+		for idx, g := range c.Gates {
+			if g.Level != expected[idx] {
+				t.Fatalf("run %d: gate %d: level %v, expected %v",
+					i, idx, g.Level, expected[idx])
+			}
+			if g != circ.Gates[idx] {
+				t.Fatalf("run %d: gate %d: %v, expected %v",
+					i, idx, g, circ.Gates[idx])
+			}
+		}
+	}
+}
+
+func TestCostModel(t *testing.T) {
+	const n = 1000
+
+	var xorStats, andStats, and2Stats Stats
+	xorStats[XOR] = n / 2
+	xorStats[XNOR] = n / 2
+	andStats[AND] = n
+	and2Stats[AND] = 2 * n
+
+	xorCost := xorStats.CostWith(FreeXORCostModel)
+	andCost := andStats.CostWith(FreeXORCostModel)
+	if xorCost*50 > andCost {
+		t.Errorf("free-XOR: XOR cost %v not near zero compared to AND cost %v",
+			xorCost, andCost)
+	}
+	if and2Stats.CostWith(FreeXORCostModel) != 2*andCost {
+		t.Errorf("free-XOR: AND cost not proportional: %v != 2*%v",
+			and2Stats.CostWith(FreeXORCostModel), andCost)
+	}
+
+	var stats Stats
+	stats[XOR] = 1
+	stats[XNOR] = 2
+	stats[AND] = 3
+	stats[OR] = 4
+	stats[INV] = 5
+	legacy := (stats[AND]+stats[INV])*2 + stats[OR]*3
+	if stats.CostWith(LegacyCostModel) != legacy {
+		t.Errorf("legacy cost: got %v, expected %v",
+			stats.CostWith(LegacyCostModel), legacy)
+	}
+	if stats.Cost() != legacy {
+		t.Errorf("Cost does not use the legacy cost model: got %v, "+
+			"expected %v", stats.Cost(), legacy)
+	}
+
+	for name, expected := range map[string]CostModel{
+		"freexor": FreeXORCostModel,
+		"legacy":  LegacyCostModel,
+	} {
+		model, err := ParseCostModel(name)
+		if err != nil {
+			t.Fatalf("ParseCostModel(%s): %v", name, err)
+		}
+		if model != expected {
+			t.Errorf("ParseCostModel(%s): got %v, expected %v",
+				name, model, expected)
+		}
+	}
+	// The apps' -cost-model flags default to the library's model.
+	model, err := ParseCostModel(DefaultCostModelName)
+	if err != nil || model != DefaultCostModel() {
+		t.Errorf("ParseCostModel(%s): got %v, %v, expected %v",
+			DefaultCostModelName, model, err, DefaultCostModel())
+	}
+	if _, err := ParseCostModel("uniform"); err == nil {
+		t.Errorf("ParseCostModel succeeded for unknown model")
+	}
+}
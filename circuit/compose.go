@@ -0,0 +1,246 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+)
+
+// ComposeSpec specifies how circuits are linked together into a
+// combined circuit.
+type ComposeSpec struct {
+	// Circuits lists the circuits to compose. The circuits are
+	// evaluated in the order they are listed.
+	Circuits []*Circuit
+
+	// Connections connect circuit outputs to the inputs of the
+	// circuits following them.
+	Connections []Connection
+
+	// Outputs lists the outputs of the combined circuit. If unset,
+	// the combined circuit outputs all circuit outputs that are not
+	// connected to any inputs.
+	Outputs []Port
+}
+
+// Port identifies an input or output argument of a circuit in the
+// ComposeSpec.
+type Port struct {
+	Circuit int
+	Arg     int
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%d.%d", p.Circuit, p.Arg)
+}
+
+// Connection connects the output From to the input To.
+type Connection struct {
+	From Port
+	To   Port
+}
+
+// Compose links the circuits of the spec into a single circuit. The
+// inputs of the combined circuit are all unconnected circuit inputs,
+// in the order of the circuits and their inputs. The wires of the
+// combined circuit are renumbered so that the inputs are first and
+// the outputs are last, as in all circuits.
+func Compose(spec *ComposeSpec) (*Circuit, error) {
+	if len(spec.Circuits) == 0 {
+		return nil, fmt.Errorf("no circuits to compose")
+	}
+
+	// Resolve input connections.
+	inputs := make([][]*Port, len(spec.Circuits))
+	connected := make([][]bool, len(spec.Circuits))
+	for idx, c := range spec.Circuits {
+		inputs[idx] = make([]*Port, len(c.Inputs))
+		connected[idx] = make([]bool, len(c.Outputs))
+	}
+	for _, conn := range spec.Connections {
+		from, err := spec.output(conn.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := spec.input(conn.To)
+		if err != nil {
+			return nil, err
+		}
+		if conn.From.Circuit >= conn.To.Circuit {
+			return nil, fmt.Errorf("output %v connected to preceding input %v",
+				conn.From, conn.To)
+		}
+		if inputs[conn.To.Circuit][conn.To.Arg] != nil {
+			return nil, fmt.Errorf("input %v connected multiple times",
+				conn.To)
+		}
+		if from.Type.Bits != to.Type.Bits {
+			return nil, fmt.Errorf("connection %v->%v width mismatch: %d!=%d",
+				conn.From, conn.To, from.Type.Bits, to.Type.Bits)
+		}
+		src := conn.From
+		inputs[conn.To.Circuit][conn.To.Arg] = &src
+		connected[conn.From.Circuit][conn.From.Arg] = true
+	}
+
+	// Resolve outputs.
+	outputs := spec.Outputs
+	if len(outputs) == 0 {
+		for idx, c := range spec.Circuits {
+			for arg := range c.Outputs {
+				if !connected[idx][arg] {
+					outputs = append(outputs, Port{
+						Circuit: idx,
+						Arg:     arg,
+					})
+				}
+			}
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("composed circuit has no outputs")
+	}
+
+	// Output wire positions of all circuits.
+	outputWires := make([][]Wire, len(spec.Circuits))
+	for idx, c := range spec.Circuits {
+		outputWires[idx] = make([]Wire, len(c.Outputs))
+		w := Wire(c.NumWires - c.Outputs.Size())
+		for arg, io := range c.Outputs {
+			outputWires[idx][arg] = w
+			w += Wire(io.Type.Bits)
+		}
+	}
+
+	var numWires, numInputs int
+	for idx, c := range spec.Circuits {
+		numWires += c.NumWires
+		for arg, io := range c.Inputs {
+			if inputs[idx][arg] == nil {
+				numInputs += int(io.Type.Bits)
+			}
+			numWires -= int(io.Type.Bits)
+		}
+	}
+	numWires += numInputs
+
+	// Allocate the combined circuit's output wires.
+	wireMaps := make([][]Wire, len(spec.Circuits))
+	for idx, c := range spec.Circuits {
+		wireMaps[idx] = make([]Wire, c.NumWires)
+		for w := range wireMaps[idx] {
+			wireMaps[idx][w] = InvalidWire
+		}
+	}
+
+	var out IO
+	var numOutputs int
+	for _, o := range outputs {
+		io, err := spec.output(o)
+		if err != nil {
+			return nil, err
+		}
+		numOutputs += int(io.Type.Bits)
+		out = append(out, *io)
+	}
+	next := Wire(numWires - numOutputs)
+	for _, o := range outputs {
+		io := spec.Circuits[o.Circuit].Outputs[o.Arg]
+		from := outputWires[o.Circuit][o.Arg]
+		for bit := 0; bit < int(io.Type.Bits); bit++ {
+			w := from + Wire(bit)
+			if wireMaps[o.Circuit][w] != InvalidWire {
+				return nil, fmt.Errorf("output %v listed multiple times", o)
+			}
+			wireMaps[o.Circuit][w] = next
+			next++
+		}
+	}
+
+	// Renumber wires and merge gates.
+	var in IO
+	var gates []Gate
+	var stats Stats
+
+	nextInput := Wire(0)
+	next = Wire(numInputs)
+
+	for idx, c := range spec.Circuits {
+		wireMap := wireMaps[idx]
+
+		var w Wire
+		for arg, io := range c.Inputs {
+			from := inputs[idx][arg]
+			for bit := 0; bit < int(io.Type.Bits); bit++ {
+				if from == nil {
+					wireMap[w] = nextInput
+					nextInput++
+				} else {
+					src := outputWires[from.Circuit][from.Arg] + Wire(bit)
+					wireMap[w] = wireMaps[from.Circuit][src]
+				}
+				w++
+			}
+			if from == nil {
+				in = append(in, io)
+			}
+		}
+		for ; int(w) < c.NumWires; w++ {
+			if wireMap[w] == InvalidWire {
+				wireMap[w] = next
+				next++
+			}
+		}
+
+		for _, g := range c.Gates {
+			gate := Gate{
+				Input0: wireMap[g.Input0],
+				Output: wireMap[g.Output],
+				Op:     g.Op,
+			}
+			if g.Op != INV {
+				gate.Input1 = wireMap[g.Input1]
+			}
+			gates = append(gates, gate)
+			stats[g.Op]++
+		}
+	}
+
+	circ := &Circuit{
+		NumGates: len(gates),
+		NumWires: numWires,
+		Inputs:   in,
+		Outputs:  out,
+		Gates:    gates,
+		Stats:    stats,
+	}
+	circ.AssignLevels()
+
+	return circ, nil
+}
+
+func (spec *ComposeSpec) input(p Port) (*IOArg, error) {
+	if p.Circuit < 0 || p.Circuit >= len(spec.Circuits) {
+		return nil, fmt.Errorf("invalid circuit %d", p.Circuit)
+	}
+	c := spec.Circuits[p.Circuit]
+	if p.Arg < 0 || p.Arg >= len(c.Inputs) {
+		return nil, fmt.Errorf("invalid input %v", p)
+	}
+	return &c.Inputs[p.Arg], nil
+}
+
+func (spec *ComposeSpec) output(p Port) (*IOArg, error) {
+	if p.Circuit < 0 || p.Circuit >= len(spec.Circuits) {
+		return nil, fmt.Errorf("invalid circuit %d", p.Circuit)
+	}
+	c := spec.Circuits[p.Circuit]
+	if p.Arg < 0 || p.Arg >= len(c.Outputs) {
+		return nil, fmt.Errorf("invalid output %v", p)
+	}
+	return &c.Outputs[p.Arg], nil
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -13,6 +13,14 @@ import (
 
 // Compute evaluates the circuit with the given input values.
 func (c *Circuit) Compute(inputs []*big.Int) ([]*big.Int, error) {
+	return c.compute(inputs, nil)
+}
+
+// compute evaluates the circuit with the given input values. If trace
+// is not nil, the function appends the evaluated gates into it.
+func (c *Circuit) compute(inputs []*big.Int, trace *Trace) (
+	[]*big.Int, error) {
+
 	// Flatten circuit arguments.
 	var args IO
 	for _, io := range c.Inputs {
@@ -70,6 +78,18 @@ func (c *Circuit) Compute(inputs []*big.Int) ([]*big.Int, error) {
 			return nil, fmt.Errorf("invalid gate %s", gate.Op)
 		}
 
+		if trace != nil {
+			entry := TraceEntry{
+				Gate:   gate,
+				Input0: wires[gate.Input0],
+				Output: result,
+			}
+			if gate.Op != INV {
+				entry.Input1 = wires[gate.Input1]
+			}
+			*trace = append(*trace, entry)
+		}
+
 		wires[gate.Output] = result
 	}
 
@@ -0,0 +1,86 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/markkurossi/mpc/ot"
+)
+
+// OutputDecoding contains the output decoding table of a garbled
+// circuit. The table lets a third party, for example an auditor,
+// decode the evaluator's final output labels and verify the result
+// without trusting either party's claimed output.
+//
+// The table contains hashes of the output wire labels instead of the
+// labels themselves. Publishing both labels of a wire would reveal
+// the free-XOR offset R and with it all wire values of the
+// circuit. The hashes reveal nothing about the other wires, but
+// anyone holding the table and the output labels learns the output
+// values. The table must be published only if the outputs are meant
+// to be public.
+type OutputDecoding struct {
+	Outputs IO
+	Hashes  [][2][sha256.Size]byte
+}
+
+// PublishOutputDecoding creates the output decoding table from the
+// garbled tables of the circuit circ. The table can be published
+// after the online phase with GarblerOnline.
+func PublishOutputDecoding(circ *Circuit, tables *GarbledTables) (
+	*OutputDecoding, error) {
+
+	if len(tables.Outputs) != circ.Outputs.Size() {
+		return nil, fmt.Errorf("garbled tables do not match circuit")
+	}
+	decoding := &OutputDecoding{
+		Outputs: circ.Outputs,
+		Hashes:  make([][2][sha256.Size]byte, len(tables.Outputs)),
+	}
+	for i, wire := range tables.Outputs {
+		decoding.Hashes[i][0] = outputHash(i, wire.L0)
+		decoding.Hashes[i][1] = outputHash(i, wire.L1)
+	}
+	return decoding, nil
+}
+
+// DecodeOutputs decodes the evaluator's output labels with the output
+// decoding table. The function returns an error if any of the labels
+// is not a valid output label of its wire.
+func DecodeOutputs(decoding *OutputDecoding, labels []ot.Label) (
+	[]*big.Int, error) {
+
+	if len(labels) != len(decoding.Hashes) {
+		return nil, fmt.Errorf("got %d output labels, expected %d",
+			len(labels), len(decoding.Hashes))
+	}
+	result := new(big.Int)
+	for i, label := range labels {
+		h := outputHash(i, label)
+		if h == decoding.Hashes[i][1] {
+			result.SetBit(result, i, 1)
+		} else if h != decoding.Hashes[i][0] {
+			return nil, fmt.Errorf("unknown label %s for result %d", label, i)
+		}
+	}
+	return decoding.Outputs.Split(result), nil
+}
+
+// outputHash computes the hash of the label of the output wire idx.
+func outputHash(idx int, label ot.Label) [sha256.Size]byte {
+	var buf [4 + len(ot.LabelData{})]byte
+	var data ot.LabelData
+
+	binary.BigEndian.PutUint32(buf[:4], uint32(idx))
+	copy(buf[4:], label.Bytes(&data))
+
+	return sha256.Sum256(buf[:])
+}
@@ -0,0 +1,87 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// evalLabels evaluates the garbled tables with the inputs and returns
+// the output labels that the evaluator holds after the evaluation.
+func evalLabels(t *testing.T, circ *Circuit, tables *GarbledTables,
+	inputs []*big.Int) []ot.Label {
+
+	wires := make([]ot.Label, circ.NumWires)
+	var base int
+	for idx, arg := range circ.Inputs {
+		for i := 0; i < int(arg.Type.Bits); i++ {
+			wire := tables.Inputs[base+i]
+			if inputs[idx].Bit(i) == 1 {
+				wires[base+i] = wire.L1
+			} else {
+				wires[base+i] = wire.L0
+			}
+		}
+		base += int(arg.Type.Bits)
+	}
+	if err := circ.Eval(tables.Key, wires, tables.Gates); err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	return wires[circ.NumWires-circ.Outputs.Size():]
+}
+
+func TestOutputDecoding(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("auditor decodes!"))
+
+	tables, err := GarbleOffline(circ, rand.Reader)
+	if err != nil {
+		t.Fatalf("GarbleOffline failed: %v", err)
+	}
+	labels := evalLabels(t, circ, tables, []*big.Int{key, data})
+
+	expected := runGarbled(t, circ, func(conn *p2p.Conn) ([]*big.Int, error) {
+		return GarblerOnline(conn, ot.NewCO(), circ, tables, key, false)
+	}, data)
+
+	decoding, err := PublishOutputDecoding(circ, tables)
+	if err != nil {
+		t.Fatalf("PublishOutputDecoding failed: %v", err)
+	}
+	result, err := DecodeOutputs(decoding, labels)
+	if err != nil {
+		t.Fatalf("DecodeOutputs failed: %v", err)
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("got %v, expected %v", result, expected)
+	}
+	for i := range result {
+		if result[i].Cmp(expected[i]) != 0 {
+			t.Errorf("result %d: got %x, expected %x",
+				i, result[i], expected[i])
+		}
+	}
+
+	// Forged labels are rejected.
+	forged := append([]ot.Label(nil), labels...)
+	forged[3].D0 ^= 1
+	if _, err := DecodeOutputs(decoding, forged); err == nil {
+		t.Errorf("DecodeOutputs accepted forged label")
+	}
+	if _, err := DecodeOutputs(decoding, labels[1:]); err == nil {
+		t.Errorf("DecodeOutputs accepted wrong number of labels")
+	}
+}
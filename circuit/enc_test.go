@@ -20,7 +20,7 @@ func TestEnc(t *testing.T) {
 	a, _ := ot.NewLabel(rand.Reader)
 	b, _ := ot.NewLabel(rand.Reader)
 	c, _ := ot.NewLabel(rand.Reader)
-	tweak := uint32(42)
+	tweak := uint64(42)
 	var key [32]byte
 
 	cipher, err := aes.NewCipher(key[:])
@@ -66,7 +66,7 @@ func BenchmarkEnc(b *testing.B) {
 	b.ResetTimer()
 	var data ot.LabelData
 	for i := 0; i < b.N; i++ {
-		encrypt(cipher, al, bl, cl, uint32(i), &data)
+		encrypt(cipher, al, bl, cl, uint64(i), &data)
 	}
 }
 
@@ -86,6 +86,6 @@ func BenchmarkEncHalf(b *testing.B) {
 	b.ResetTimer()
 	var data ot.LabelData
 	for i := 0; i < b.N; i++ {
-		encryptHalf(cipher, xl, uint32(i), &data)
+		encryptHalf(cipher, xl, uint64(i), &data)
 	}
 }
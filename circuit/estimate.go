@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Wire format sizes of the garbled circuit protocol messages.
+const (
+	labelBytes  = 16
+	uint32Bytes = 4
+)
+
+// CostParams specify the protocol parameters for the cost
+// estimation.
+type CostParams struct {
+	// OTSetupBytes specifies the number of bytes exchanged in the
+	// oblivious transfer initialization.
+	OTSetupBytes uint64
+	// OTBytes specifies the number of bytes exchanged for each
+	// oblivious transfer.
+	OTBytes uint64
+	// Bandwidth specifies the network throughput in bytes per
+	// second. If zero, the time is not estimated.
+	Bandwidth uint64
+}
+
+// DefaultCostParams returns the cost parameters for the CO oblivious
+// transfer over the P-256 curve and a 1Gbit/s network.
+func DefaultCostParams() CostParams {
+	return CostParams{
+		OTSetupBytes: 96,
+		OTBytes:      112,
+		Bandwidth:    125 * 1000 * 1000,
+	}
+}
+
+// CostEstimate contains the projected data transfer and runtime of a
+// two-party garbled circuit protocol run.
+type CostEstimate struct {
+	GateBytes   FileSize
+	InputBytes  FileSize
+	NumOTs      uint64
+	OTBytes     FileSize
+	OutputBytes FileSize
+	Bytes       FileSize
+	Time        time.Duration
+}
+
+func (e CostEstimate) String() string {
+	result := fmt.Sprintf("gates=%s inputs=%s ot=%s (#%d) outputs=%s total=%s",
+		e.GateBytes, e.InputBytes, e.OTBytes, e.NumOTs, e.OutputBytes, e.Bytes)
+	if e.Time > 0 {
+		result += fmt.Sprintf(" time=%s", e.Time)
+	}
+	return result
+}
+
+// EstimateCost estimates the number of bytes exchanged and the
+// wall-clock time of evaluating the circuit circ with the Garbler and
+// Evaluator protocol. The estimate is computed from the circuit
+// statistics: the garbled tables are sized from the gate counts and
+// the evaluator's inputs from the oblivious transfer costs in params.
+// The time only accounts for the data transfer.
+func EstimateCost(circ *Circuit, params CostParams) CostEstimate {
+	var e CostEstimate
+
+	// Garbled tables: the table size is sent for each gate, followed
+	// by its labels.
+	tables := circ.Stats[AND]*2 + circ.Stats[OR]*3 + circ.Stats[INV]
+	e.GateBytes = FileSize(uint32Bytes + uint64(circ.NumGates)*uint32Bytes +
+		tables*labelBytes)
+
+	// Key and the garbler's input labels.
+	var garblerBits, evaluatorBits uint64
+	if len(circ.Inputs) > 0 {
+		garblerBits = uint64(circ.Inputs[0].Type.Bits)
+	}
+	if len(circ.Inputs) > 1 {
+		evaluatorBits = uint64(circ.Inputs[1].Type.Bits)
+	}
+	e.InputBytes = FileSize(uint32Bytes + garbledKeySize +
+		garblerBits*labelBytes)
+
+	// The evaluator's inputs with oblivious transfer.
+	e.NumOTs = evaluatorBits
+	e.OTBytes = FileSize(2*uint32Bytes + params.OTSetupBytes +
+		evaluatorBits*params.OTBytes)
+
+	// Output labels and the resolved result.
+	outputBits := uint64(circ.Outputs.Size())
+	e.OutputBytes = FileSize(outputBits*labelBytes + uint32Bytes +
+		(outputBits+7)/8)
+
+	e.Bytes = e.GateBytes + e.InputBytes + e.OTBytes + e.OutputBytes
+	if params.Bandwidth > 0 {
+		e.Time = time.Duration(float64(e.Bytes) / float64(params.Bandwidth) *
+			float64(time.Second))
+	}
+	return e
+}
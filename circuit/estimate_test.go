@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestEstimateCost(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("estimate me, ok?"))
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	ch := make(chan error)
+	go func() {
+		_, err := Evaluator(eConn, ot.NewCO(), circ, data, false)
+		ch <- err
+	}()
+	_, err = Garbler(gConn, ot.NewCO(), circ, key, false)
+	if err != nil {
+		t.Fatalf("Garbler failed: %v", err)
+	}
+	if err := <-ch; err != nil {
+		t.Fatalf("Evaluator failed: %v", err)
+	}
+
+	actual := float64(gConn.Stats.Sum())
+	e := EstimateCost(circ, DefaultCostParams())
+	ratio := float64(e.Bytes) / actual
+	t.Logf("estimate %v, actual %v, ratio %.3f",
+		e, FileSize(actual), ratio)
+	if ratio < 0.9 || ratio > 1.1 {
+		t.Errorf("estimate %v not within 10%% of actual %v",
+			e.Bytes, FileSize(actual))
+	}
+	if e.NumOTs != uint64(circ.Inputs[1].Type.Bits) {
+		t.Errorf("got %d OTs, expected %d", e.NumOTs, circ.Inputs[1].Type.Bits)
+	}
+	if e.Time <= 0 {
+		t.Errorf("time not estimated")
+	}
+}
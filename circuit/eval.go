@@ -16,6 +16,13 @@ import (
 // Eval evaluates the circuit.
 func (c *Circuit) Eval(key []byte, wires []ot.Label,
 	garbled [][]ot.Label) error {
+	return c.EvalDomain(key, 0, wires, garbled)
+}
+
+// EvalDomain evaluates the circuit that was garbled in the domain with
+// GarbleDomain.
+func (c *Circuit) EvalDomain(key []byte, domain uint32, wires []ot.Label,
+	garbled [][]ot.Label) error {
 
 	alg, err := aes.NewCipher(key)
 	if err != nil {
@@ -23,7 +30,10 @@ func (c *Circuit) Eval(key []byte, wires []ot.Label,
 	}
 
 	var data ot.LabelData
-	var id uint32
+	id, err := domainTweak(uint64(domain), len(c.Gates))
+	if err != nil {
+		return err
+	}
 
 	for i := 0; i < len(c.Gates); i++ {
 		gate := &c.Gates[i]
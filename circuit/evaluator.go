@@ -1,7 +1,7 @@
 //
 // evaluator.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -72,12 +72,16 @@ func Evaluator(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	wires := make([]ot.Label, circ.NumWires)
 
 	// Receive peer inputs.
-	for i := 0; i < int(circ.Inputs[0].Type.Bits); i++ {
-		err := conn.ReceiveLabel(&label, &labelData)
+	err = conn.ReceiveLabels(wires[:circ.Inputs[0].Type.Bits])
+	if err != nil {
+		return nil, err
+	}
+	shared := circ.sharedInputs()
+	if shared != nil {
+		err = receiveShared(conn, circ, wires, shared, inputs)
 		if err != nil {
 			return nil, err
 		}
-		wires[Wire(i)] = label
 	}
 
 	// Init oblivious transfer.
@@ -100,17 +104,42 @@ func Evaluator(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	if err := conn.SendUint32(int(circ.Inputs[1].Type.Bits)); err != nil {
 		return nil, err
 	}
+	if shared != nil {
+		// Our digest of the shared values.
+		err := conn.SendData(sharedDigest(shared, inputs))
+		if err != nil {
+			return nil, err
+		}
+	}
 	if err := conn.Flush(); err != nil {
 		return nil, err
 	}
-	flags := make([]bool, int(circ.Inputs[1].Type.Bits))
+	var flags []bool
 	for i := 0; i < int(circ.Inputs[1].Type.Bits); i++ {
-		if inputs.Bit(i) == 1 {
-			flags[i] = true
+		if shared != nil && shared[i] {
+			continue
 		}
+		flags = append(flags, inputs.Bit(i) == 1)
 	}
-	if err := oti.Receive(flags, wires[circ.Inputs[0].Type.Bits:]); err != nil {
-		return nil, err
+	inputWires := wires[circ.Inputs[0].Type.Bits:circ.Inputs.Size()]
+	if shared == nil {
+		if err := oti.Receive(flags, inputWires); err != nil {
+			return nil, err
+		}
+	} else {
+		// Receive the non-shared input labels and place them between
+		// the shared labels.
+		labels := make([]ot.Label, len(flags))
+		if err := oti.Receive(flags, labels); err != nil {
+			return nil, err
+		}
+		var idx int
+		for i, s := range shared {
+			if !s {
+				inputWires[i] = labels[idx]
+				idx++
+			}
+		}
 	}
 	xfer := conn.Stats.Sum() - ioStats
 	ioStats = conn.Stats.Sum()
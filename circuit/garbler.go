@@ -1,7 +1,7 @@
 //
 // garbler.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -9,7 +9,7 @@
 package circuit
 
 import (
-	"crypto/rand"
+	"bytes"
 	"fmt"
 	"math/big"
 
@@ -38,38 +38,52 @@ func (s FileSize) String() string {
 func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	verbose bool) ([]*big.Int, error) {
 
-	timing := NewTiming()
-	if verbose {
-		fmt.Printf(" - Garbling...\n")
-	}
+	return GarblerShared(conn, oti, circ, inputs, nil, verbose)
+}
 
-	var key [32]byte
-	_, err := rand.Read(key[:])
-	if err != nil {
-		return nil, err
-	}
+// GarblerOnline runs the online phase of the garbler on the P2P
+// network. The garbled tables must be computed with GarbleOffline for
+// the circuit circ. The tables are consumed by this call and they
+// must not be used for any other session. The evaluator runs the
+// normal Evaluator protocol.
+func GarblerOnline(conn *p2p.Conn, oti ot.OT, circ *Circuit,
+	tables *GarbledTables, inputs *big.Int, verbose bool) (
+	[]*big.Int, error) {
+
+	return garblerOnline(conn, oti, circ, tables, inputs, nil, verbose,
+		NewTiming())
+}
 
-	garbled, err := circ.Garble(key[:])
-	if err != nil {
+func garblerOnline(conn *p2p.Conn, oti ot.OT, circ *Circuit,
+	tables *GarbledTables, inputs, sharedValue *big.Int, verbose bool,
+	timing *Timing) ([]*big.Int, error) {
+
+	shared := circ.sharedInputs()
+	if shared != nil && sharedValue == nil {
+		return nil, fmt.Errorf("shared input values not specified")
+	}
+	if tables.used {
+		return nil, ErrTablesUsed
+	}
+	if err := tables.Verify(circ); err != nil {
 		return nil, err
 	}
-
-	timing.Sample("Garble", nil)
+	tables.used = true
 
 	// Send program info.
 	if verbose {
 		fmt.Printf(" - Sending garbled circuit...\n")
 	}
-	if err := conn.SendData(key[:]); err != nil {
+	if err := conn.SendData(tables.Key); err != nil {
 		return nil, err
 	}
 
 	// Send garbled tables.
-	if err := conn.SendUint32(len(garbled.Gates)); err != nil {
+	if err := conn.SendUint32(len(tables.Gates)); err != nil {
 		return nil, err
 	}
 	var labelData ot.LabelData
-	for _, data := range garbled.Gates {
+	for _, data := range tables.Gates {
 		if err := conn.SendUint32(len(data)); err != nil {
 			return nil, err
 		}
@@ -83,7 +97,7 @@ func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	// Select our inputs.
 	var n1 []ot.Label
 	for i := 0; i < int(circ.Inputs[0].Type.Bits); i++ {
-		wire := garbled.Wires[i]
+		wire := tables.Inputs[i]
 
 		var n ot.Label
 
@@ -96,11 +110,12 @@ func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	}
 
 	// Send our inputs.
-	for idx, i := range n1 {
-		if verbose && false {
-			fmt.Printf("N1[%d]:\t%s\n", idx, i)
-		}
-		if err := conn.SendLabel(i, &labelData); err != nil {
+	if err := conn.SendLabels(n1); err != nil {
+		return nil, err
+	}
+	if shared != nil {
+		err := sendShared(conn, circ, tables, shared, sharedValue)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -111,7 +126,7 @@ func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 	}
 
 	// Init oblivious transfer.
-	err = oti.InitSender(conn)
+	err := oti.InitSender(conn)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +148,26 @@ func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 		return nil, fmt.Errorf("peer can't OT wires [%d...%d[",
 			offset, offset+count)
 	}
-	err = oti.Send(garbled.Wires[offset : offset+count])
+	wires := tables.Inputs[offset : offset+count]
+	if shared != nil {
+		// The evaluator's digest of the shared values.
+		digest, err := conn.ReceiveData()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(digest, sharedDigest(shared, sharedValue)) {
+			return nil, ErrSharedInputMismatch
+		}
+		// Transfer only the non-shared input wires.
+		var otWires []ot.Wire
+		for i, s := range shared {
+			if !s {
+				otWires = append(otWires, wires[i])
+			}
+		}
+		wires = otWires
+	}
+	err = oti.Send(wires)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +188,7 @@ func Garbler(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs *big.Int,
 		if i == 0 {
 			timing.Sample("Eval", nil)
 		}
-		wire := garbled.Wires[circ.NumWires-circ.Outputs.Size()+i]
+		wire := tables.Outputs[i]
 
 		var bit uint
 		if label.Equal(wire.L0) {
@@ -0,0 +1,172 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+	"github.com/markkurossi/mpc/types"
+)
+
+func TestGarbleLabelEncoding(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("label encoding!!"))
+
+	plain, err := circ.Compute([]*big.Int{key, data})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	for _, enc := range []ot.LabelEncoding{ot.LabelMSB, ot.LabelLSB} {
+		result := runGarbledEncoding(t, circ, enc,
+			func(conn *p2p.Conn) ([]*big.Int, error) {
+				return Garbler(conn, ot.NewCO(), circ, key, false)
+			}, data)
+		if result[0].Cmp(plain[0]) != 0 {
+			t.Errorf("%s: got %x, expected %x", enc, result[0], plain[0])
+		}
+	}
+}
+
+func garbleDomainTables(t *testing.T, circ *Circuit, key []byte,
+	domain uint32) *Garbled {
+
+	garbled, err := circ.garble(rand.New(rand.NewSource(42)), key, domain)
+	if err != nil {
+		t.Fatalf("garble failed: %v", err)
+	}
+	return garbled
+}
+
+func TestGarbleDomain(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key [16]byte
+
+	// The same labels in the same domain produce the same tables.
+	g0 := garbleDomainTables(t, circ, key[:], 0)
+	g0b := garbleDomainTables(t, circ, key[:], 0)
+	if !reflect.DeepEqual(g0.Gates, g0b.Gates) {
+		t.Fatalf("garbling is not deterministic")
+	}
+
+	// The same labels in different domains produce independent
+	// tables.
+	g1 := garbleDomainTables(t, circ, key[:], 1)
+	if g0.R != g1.R || g0.Wires[0] != g1.Wires[0] {
+		t.Fatalf("garblings have different input labels")
+	}
+	labels := make(map[ot.Label]bool)
+	for _, gate := range g0.Gates {
+		for _, l := range gate {
+			labels[l] = true
+		}
+	}
+	var count int
+	for idx, gate := range g1.Gates {
+		for _, l := range gate {
+			count++
+			if labels[l] {
+				t.Fatalf("gate %d: label %v in both domains", idx, l)
+			}
+		}
+	}
+	if count == 0 {
+		t.Fatalf("no garbled gate labels")
+	}
+
+	// The circuit evaluates only in its domain.
+	keyVal := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("domain separated"))
+	plain, err := circ.Compute([]*big.Int{keyVal, data})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	input := new(big.Int).Or(keyVal, new(big.Int).Lsh(data, 128))
+
+	eval := func(domain uint32) bool {
+		wires := make([]ot.Label, circ.NumWires)
+		for i := 0; i < circ.Inputs.Size(); i++ {
+			if input.Bit(i) == 1 {
+				wires[i] = g1.Wires[i].L1
+			} else {
+				wires[i] = g1.Wires[i].L0
+			}
+		}
+		err := circ.EvalDomain(key[:], domain, wires, g1.Gates)
+		if err != nil {
+			t.Fatalf("EvalDomain failed: %v", err)
+		}
+		first := circ.NumWires - circ.Outputs.Size()
+		for i := 0; i < circ.Outputs.Size(); i++ {
+			expected := g1.Wires[first+i].L0
+			if plain[0].Bit(i) == 1 {
+				expected = g1.Wires[first+i].L1
+			}
+			if wires[first+i] != expected {
+				return false
+			}
+		}
+		return true
+	}
+	if !eval(1) {
+		t.Errorf("evaluation failed in the garbling domain")
+	}
+	if eval(0) {
+		t.Errorf("evaluation succeeded in a different domain")
+	}
+}
+
+func TestDomainTweakLimits(t *testing.T) {
+	tweak, err := domainTweak(math.MaxUint32, math.MaxUint32/2)
+	if err != nil {
+		t.Fatalf("domainTweak failed: %v", err)
+	}
+	// The last tweak of the largest circuit stays in the domain.
+	last := tweak + 2*(math.MaxUint32/2) - 1
+	if last>>32 != math.MaxUint32 {
+		t.Errorf("last tweak %x leaks out of its domain", last)
+	}
+	if _, err := domainTweak(math.MaxUint32+1, 1); err == nil {
+		t.Errorf("domainTweak succeeded with a 33-bit domain")
+	}
+	if _, err := domainTweak(0, math.MaxUint32/2+1); err == nil {
+		t.Errorf("domainTweak succeeded with too many gates")
+	}
+
+	// The streaming garbler fails when it runs out of domains.
+	stream, err := NewStreaming(make([]byte, 16), []Wire{0, 1}, nil)
+	if err != nil {
+		t.Fatalf("NewStreaming failed: %v", err)
+	}
+	stream.domain = math.MaxUint32 + 1
+	circ := &Circuit{
+		NumWires: 3,
+		Inputs: []IOArg{
+			{Type: types.Info{Type: types.TUint, Bits: 1}},
+			{Type: types.Info{Type: types.TUint, Bits: 1}},
+		},
+		Outputs: []IOArg{
+			{Type: types.Info{Type: types.TUint, Bits: 1}},
+		},
+	}
+	_, _, err = stream.Garble(circ, []Wire{0, 1}, []Wire{2})
+	if err == nil {
+		t.Errorf("Garble succeeded after the domains ran out")
+	}
+}
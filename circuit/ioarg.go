@@ -8,7 +8,9 @@ package circuit
 
 import (
 	"fmt"
+	"io"
 	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/markkurossi/mpc/types"
@@ -58,11 +60,54 @@ func (io IO) Split(in *big.Int) []*big.Int {
 	return result
 }
 
-// IOArg describes circuit input argument.
+// SharedBits returns a bitmask of the argument bits which are
+// resolved with the OutputShare mode.
+func (io IO) SharedBits() []bool {
+	var result []bool
+	for _, arg := range io {
+		for i := 0; i < int(arg.Type.Bits); i++ {
+			result = append(result, arg.Mode == OutputShare)
+		}
+	}
+	return result
+}
+
+// IOArg describes circuit input argument. The SharedInput flag marks
+// the evaluator's input arguments whose values are known to both
+// parties; see GarblerShared.
 type IOArg struct {
-	Name     string
-	Type     types.Info
-	Compound IO
+	Name        string
+	Type        types.Info
+	Compound    IO
+	Mode        OutputMode
+	SharedInput bool
+}
+
+// OutputMode specifies how the value of an output argument is
+// resolved at the end of the computation.
+type OutputMode byte
+
+// Output modes.
+const (
+	// OutputReveal reveals the output value to both parties.
+	OutputReveal OutputMode = iota
+	// OutputShare keeps the output value XOR-shared between the
+	// parties. Each party receives its share of the value and neither
+	// party learns the value itself.
+	OutputShare
+)
+
+var outputModes = map[OutputMode]string{
+	OutputReveal: "reveal",
+	OutputShare:  "share",
+}
+
+func (m OutputMode) String() string {
+	name, ok := outputModes[m]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{OutputMode %d}", m)
 }
 
 func (io IOArg) String() string {
@@ -76,7 +121,72 @@ func (io IOArg) String() string {
 	return io.Type.String()
 }
 
-// Parse parses the I/O argument from the input string values.
+// InputParseError describes an invalid input value for an I/O
+// argument.
+type InputParseError struct {
+	// Name is the argument name. It is empty for unnamed arguments.
+	Name string
+	// Type is the expected argument type.
+	Type types.Info
+	// Input is the offending input token.
+	Input string
+	// Suggestion explains why the input was rejected and how to fix
+	// it.
+	Suggestion string
+}
+
+func (e *InputParseError) Error() string {
+	msg := fmt.Sprintf("invalid input '%s' for ", e.Input)
+	if len(e.Name) > 0 {
+		msg += e.Name + " "
+	}
+	msg += e.Type.String()
+	if len(e.Suggestion) > 0 {
+		msg += ": " + e.Suggestion
+	}
+	return msg
+}
+
+func (io IOArg) parseError(input, format string,
+	a ...interface{}) *InputParseError {
+
+	return &InputParseError{
+		Name:       io.Name,
+		Type:       io.Type,
+		Input:      input,
+		Suggestion: fmt.Sprintf(format, a...),
+	}
+}
+
+// parseInt parses the integer input value. The function returns an
+// error with a suggestion about the expected syntax if the input is
+// not a valid integer.
+func (io IOArg) parseInt(input string) (*big.Int, error) {
+	val, ok := new(big.Int).SetString(input, 0)
+	if ok {
+		return val, nil
+	}
+	digits := strings.TrimPrefix(strings.TrimPrefix(input, "-"), "+")
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			return nil, io.parseError(input, "invalid hexadecimal value")
+		case 'o', 'O':
+			return nil, io.parseError(input, "invalid octal value")
+		case 'b', 'B':
+			return nil, io.parseError(input, "invalid binary value")
+		}
+	}
+	if strings.ContainsAny(input, ".eE") {
+		return nil, io.parseError(input, "%s is not an integer value",
+			input)
+	}
+	return nil, io.parseError(input,
+		"use a decimal, 0x hexadecimal, 0o octal, or 0b binary integer")
+}
+
+// Parse parses the I/O argument from the input string values. The
+// invalid input values are reported with an InputParseError.
 func (io IOArg) Parse(inputs []string) (*big.Int, error) {
 	result := new(big.Int)
 
@@ -86,22 +196,48 @@ func (io IOArg) Parse(inputs []string) (*big.Int, error) {
 				fmt.Errorf("invalid amount of arguments, got %d, expected 1",
 					len(inputs))
 		}
+		input := inputs[0]
 
 		switch io.Type.Type {
 		case types.TInt, types.TUint:
-			_, ok := result.SetString(inputs[0], 0)
+			val, err := io.parseInt(input)
+			if err != nil {
+				return nil, err
+			}
+			if err := io.checkIntRange(input, val); err != nil {
+				return nil, err
+			}
+			result = val
+
+		case types.TFixed:
+			val, ok := new(big.Rat).SetString(input)
 			if !ok {
-				return nil, fmt.Errorf("invalid input '%s' for %s",
-					inputs[0], io.Type)
+				return nil, io.parseError(input,
+					"use a decimal number such as 1.5 or -0.25")
+			}
+			scale := new(big.Int).Lsh(big.NewInt(1), uint(io.Type.FracBits))
+			val.Mul(val, new(big.Rat).SetInt(scale))
+			result.Quo(val.Num(), val.Denom())
+
+			if io.Type.Bits > 0 {
+				max := new(big.Int).Lsh(big.NewInt(1), uint(io.Type.Bits-1))
+				min := new(big.Int).Neg(max)
+				if result.Cmp(min) < 0 || result.Cmp(max) >= 0 {
+					return nil, io.parseError(input,
+						"value %s exceeds %s range", input, io.Type)
+				}
 			}
+			result.Mod(result,
+				new(big.Int).Lsh(big.NewInt(1), uint(io.Type.Bits)))
 
 		case types.TBool:
-			switch inputs[0] {
+			switch input {
 			case "0", "f", "false":
 			case "1", "t", "true":
 				result.SetInt64(1)
 			default:
-				return nil, fmt.Errorf("invalid bool constant: %s", inputs[0])
+				return nil, io.parseError(input,
+					"use true, false, t, f, 1, or 0")
 			}
 
 		case types.TArray, types.TSlice:
@@ -112,15 +248,15 @@ func (io IOArg) Parse(inputs []string) (*big.Int, error) {
 				break
 			}
 
-			val := new(big.Int)
-			_, ok := val.SetString(inputs[0], 0)
-			if !ok {
-				return nil, fmt.Errorf("invalid input '%s' for %s",
-					inputs[0], io.Type)
+			val, ok := new(big.Int).SetString(input, 0)
+			if !ok || val.Sign() < 0 {
+				return nil, io.parseError(input,
+					"use a 0x hexadecimal value of the concatenated "+
+						"%s elements", io.Type.ElementType)
 			}
 			var bitLen int
-			if strings.HasPrefix(inputs[0], "0x") {
-				bitLen = (len(inputs[0]) - 2) * 4
+			if strings.HasPrefix(input, "0x") {
+				bitLen = (len(input) - 2) * 4
 			} else {
 				bitLen = val.BitLen()
 			}
@@ -134,8 +270,9 @@ func (io IOArg) Parse(inputs []string) (*big.Int, error) {
 				count = valElCount
 			}
 			if valElCount > count {
-				return nil, fmt.Errorf("too many values for input: %s",
-					inputs[0])
+				return nil, io.parseError(input,
+					"too many values: got %d elements, expected %d",
+					valElCount, count)
 			}
 			pad := count - valElCount
 			val.Lsh(val, uint(pad*elSize))
@@ -181,6 +318,35 @@ func (io IOArg) Parse(inputs []string) (*big.Int, error) {
 	return result, nil
 }
 
+// checkIntRange checks that the integer value fits into the
+// argument's type. The signed integers accept also the unsigned
+// values of their bit patterns, for example 0xff for int8.
+func (io IOArg) checkIntRange(input string, val *big.Int) error {
+	bits := uint(io.Type.Bits)
+	if bits == 0 {
+		return nil
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), bits)
+	if io.Type.Type == types.TUint {
+		if val.Sign() < 0 {
+			return io.parseError(input, "negative value %s for %s",
+				input, io.Type)
+		}
+		if val.Cmp(limit) >= 0 {
+			return io.parseError(input, "value %s exceeds %s range 0..%s",
+				input, io.Type, new(big.Int).Sub(limit, big.NewInt(1)))
+		}
+		return nil
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), bits-1)
+	min := new(big.Int).Neg(max)
+	if val.Cmp(min) < 0 || val.Cmp(limit) >= 0 {
+		return io.parseError(input, "value %s exceeds %s range %s..%s",
+			input, io.Type, min, new(big.Int).Sub(max, big.NewInt(1)))
+	}
+	return nil
+}
+
 // InputSizes computes the bit sizes of the input arguments. This is
 // used for parametrized main() when the program is instantiated based
 // on input sizes.
@@ -211,3 +377,47 @@ func InputSizes(inputs []string) ([]int, error) {
 
 	return result, nil
 }
+
+// InputWidths parses the bit widths of the input arguments. The
+// widths are positive decimal numbers and the value "_" specifies an
+// unset width which keeps the size of the input value. The widths are
+// used with utils.Params.InputWidths to compile the parametrized
+// main() for specific input sizes.
+func InputWidths(widths []string) ([]int, error) {
+	var result []int
+
+	for _, width := range widths {
+		if width == "_" {
+			result = append(result, 0)
+			continue
+		}
+		w, err := strconv.Atoi(width)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid input width: %s", width)
+		}
+		result = append(result, w)
+	}
+
+	return result, nil
+}
+
+// ReadInputs reads input values from the reader. The values are
+// separated by newlines or commas, similarly to the comma-separated
+// command line inputs. Leading and trailing whitespace is trimmed
+// from the values and empty values are ignored.
+func ReadInputs(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, v := range strings.Split(line, ",") {
+			v = strings.TrimSpace(v)
+			if len(v) > 0 {
+				result = append(result, v)
+			}
+		}
+	}
+	return result, nil
+}
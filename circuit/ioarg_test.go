@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2023 Markku Rossi
+// Copyright (c) 2023-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -7,7 +7,12 @@
 package circuit
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/markkurossi/mpc/types"
 )
 
 var inputSizeTests = []struct {
@@ -60,3 +65,244 @@ func TestInputSizes(t *testing.T) {
 		}
 	}
 }
+
+func TestInputWidths(t *testing.T) {
+	widths, err := InputWidths([]string{"16", "_", "64"})
+	if err != nil {
+		t.Fatalf("InputWidths failed: %v", err)
+	}
+	if !slices.Equal(widths, []int{16, 0, 64}) {
+		t.Errorf("got widths %v, expected [16 0 64]", widths)
+	}
+	for _, width := range []string{"0", "-8", "0x10", "int32", ""} {
+		if _, err := InputWidths([]string{width}); err == nil {
+			t.Errorf("InputWidths(%q) succeeded", width)
+		}
+	}
+}
+
+func TestReadInputs(t *testing.T) {
+	arg := IOArg{
+		Compound: IO{
+			{Type: types.Info{Type: types.TUint, IsConcrete: true, Bits: 8}},
+			{Type: types.Info{Type: types.TBool, IsConcrete: true, Bits: 1}},
+			{Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 16}},
+			{Type: types.Info{
+				Type:        types.TArray,
+				IsConcrete:  true,
+				Bits:        32,
+				ArraySize:   4,
+				ElementType: &types.Info{Type: types.TUint, Bits: 8},
+			}},
+		},
+	}
+	// The -i flag value split into inputs.
+	flagInputs := strings.Split("42,true,0x1234,0xdeadbeef", ",")
+	expected, err := arg.Parse(flagInputs)
+	if err != nil {
+		t.Fatalf("Parse(%v) failed: %v", flagInputs, err)
+	}
+
+	for idx, data := range []string{
+		"42,true,0x1234,0xdeadbeef\n",
+		"42\ntrue\n0x1234\n0xdeadbeef",
+		" 42 , true\r\n\n0x1234,\n 0xdeadbeef \n",
+	} {
+		inputs, err := ReadInputs(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("t%v: ReadInputs failed: %v", idx, err)
+		}
+		if !slices.Equal(inputs, flagInputs) {
+			t.Errorf("t%v: ReadInputs=%q, expected %q", idx, inputs, flagInputs)
+			continue
+		}
+		v, err := arg.Parse(inputs)
+		if err != nil {
+			t.Fatalf("t%v: Parse(%v) failed: %v", idx, inputs, err)
+		}
+		if v.Cmp(expected) != 0 {
+			t.Errorf("t%v: got %v, expected %v", idx, v, expected)
+		}
+	}
+}
+
+var inputParseErrorTests = []struct {
+	arg        IOArg
+	input      string
+	suggestion string
+}{
+	{
+		arg: IOArg{
+			Name: "a",
+			Type: types.Info{Type: types.TUint, IsConcrete: true, Bits: 8},
+		},
+		input:      "300",
+		suggestion: "value 300 exceeds uint8 range 0..255",
+	},
+	{
+		arg: IOArg{
+			Name: "a",
+			Type: types.Info{Type: types.TUint, IsConcrete: true, Bits: 8},
+		},
+		input:      "-1",
+		suggestion: "negative value -1 for uint8",
+	},
+	{
+		arg: IOArg{
+			Name: "b",
+			Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 8},
+		},
+		input:      "-129",
+		suggestion: "value -129 exceeds int8 range -128..127",
+	},
+	{
+		arg: IOArg{
+			Name: "c",
+			Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 32},
+		},
+		input:      "0xfg",
+		suggestion: "invalid hexadecimal value",
+	},
+	{
+		arg: IOArg{
+			Name: "c",
+			Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 32},
+		},
+		input:      "0b102",
+		suggestion: "invalid binary value",
+	},
+	{
+		arg: IOArg{
+			Name: "c",
+			Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 32},
+		},
+		input:      "1.5",
+		suggestion: "1.5 is not an integer value",
+	},
+	{
+		arg: IOArg{
+			Name: "d",
+			Type: types.Info{Type: types.TBool, IsConcrete: true, Bits: 1},
+		},
+		input:      "yes",
+		suggestion: "use true, false, t, f, 1, or 0",
+	},
+	{
+		arg: IOArg{
+			Name: "e",
+			Type: types.Info{
+				Type:       types.TArray,
+				IsConcrete: true,
+				Bits:       32,
+				ArraySize:  4,
+				ElementType: &types.Info{
+					Type:       types.TUint,
+					IsConcrete: true,
+					Bits:       8,
+				},
+			},
+		},
+		input:      "0x0102030405",
+		suggestion: "too many values: got 5 elements, expected 4",
+	},
+	{
+		arg: IOArg{
+			Name: "e",
+			Type: types.Info{
+				Type:       types.TArray,
+				IsConcrete: true,
+				Bits:       32,
+				ArraySize:  4,
+				ElementType: &types.Info{
+					Type:       types.TUint,
+					IsConcrete: true,
+					Bits:       8,
+				},
+			},
+		},
+		input: "[1,2,3,4]",
+		suggestion: "use a 0x hexadecimal value of the concatenated " +
+			"uint8 elements",
+	},
+	{
+		arg: IOArg{
+			Name: "f",
+			Type: types.Info{
+				Type:       types.TFixed,
+				IsConcrete: true,
+				Bits:       16,
+				FracBits:   8,
+			},
+		},
+		input:      "128",
+		suggestion: "value 128 exceeds fixed<8,8> range",
+	},
+}
+
+func TestInputParseError(t *testing.T) {
+	for idx, test := range inputParseErrorTests {
+		_, err := test.arg.Parse([]string{test.input})
+		if err == nil {
+			t.Errorf("t%v: Parse(%v) succeeded", idx, test.input)
+			continue
+		}
+		var perr *InputParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("t%v: unexpected error type %T: %v", idx, err, err)
+			continue
+		}
+		if perr.Name != test.arg.Name {
+			t.Errorf("t%v: Name=%q, expected %q", idx, perr.Name,
+				test.arg.Name)
+		}
+		if !perr.Type.Equal(test.arg.Type) {
+			t.Errorf("t%v: Type=%v, expected %v", idx, perr.Type,
+				test.arg.Type)
+		}
+		if perr.Input != test.input {
+			t.Errorf("t%v: Input=%q, expected %q", idx, perr.Input,
+				test.input)
+		}
+		if perr.Suggestion != test.suggestion {
+			t.Errorf("t%v: Suggestion=%q, expected %q", idx,
+				perr.Suggestion, test.suggestion)
+		}
+	}
+}
+
+func TestInputParseErrorCompound(t *testing.T) {
+	arg := IOArg{
+		Compound: IO{
+			{
+				Name: "x",
+				Type: types.Info{Type: types.TUint, IsConcrete: true, Bits: 8},
+			},
+			{
+				Name: "y",
+				Type: types.Info{Type: types.TInt, IsConcrete: true, Bits: 8},
+			},
+		},
+	}
+	_, err := arg.Parse([]string{"255", "256"})
+	var perr *InputParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if perr.Name != "y" || perr.Input != "256" {
+		t.Errorf("got error for %s='%s', expected y='256'",
+			perr.Name, perr.Input)
+	}
+	expected := "invalid input '256' for y int8: " +
+		"value 256 exceeds int8 range -128..127"
+	if err.Error() != expected {
+		t.Errorf("got %q, expected %q", err, expected)
+	}
+
+	// The signed values accept their unsigned bit patterns.
+	for _, input := range []string{"-128", "127", "0xff"} {
+		_, err := arg.Parse([]string{"255", input})
+		if err != nil {
+			t.Errorf("Parse(%v) failed: %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+const (
+	// JSONFormat identifies the JSON circuit interchange format.
+	JSONFormat = "mpcl-circuit"
+
+	// JSONVersion is the version of the JSON circuit interchange
+	// format.
+	JSONVersion = 1
+)
+
+type jsonCircuit struct {
+	Format   string      `json:"format"`
+	Version  int         `json:"version"`
+	NumGates int         `json:"numGates"`
+	NumWires int         `json:"numWires"`
+	Inputs   []jsonIOArg `json:"inputs"`
+	Outputs  []jsonIOArg `json:"outputs"`
+	Gates    []jsonGate  `json:"gates"`
+}
+
+type jsonIOArg struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Bits     int         `json:"bits"`
+	Mode     string      `json:"mode,omitempty"`
+	Shared   bool        `json:"shared,omitempty"`
+	Compound []jsonIOArg `json:"compound,omitempty"`
+}
+
+type jsonGate struct {
+	Op  string `json:"op"`
+	In  []Wire `json:"in"`
+	Out Wire   `json:"out"`
+}
+
+func newJSONIO(io IO) []jsonIOArg {
+	result := []jsonIOArg{}
+	for _, arg := range io {
+		a := jsonIOArg{
+			Name:   arg.Name,
+			Type:   arg.Type.String(),
+			Bits:   int(arg.Type.Bits),
+			Shared: arg.SharedInput,
+		}
+		if arg.Mode != OutputReveal {
+			a.Mode = arg.Mode.String()
+		}
+		if len(arg.Compound) > 0 {
+			a.Compound = newJSONIO(arg.Compound)
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// MarshalJSON implements the json.Marshaler interface. The circuit is
+// encoded in the JSON circuit interchange format.
+func (c *Circuit) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.MarshalJSONFormat(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalJSONFormat marshals the circuit in the JSON circuit
+// interchange format. The gates are written one per line so that the
+// output remains readable for large circuits.
+func (c *Circuit) MarshalJSONFormat(out io.Writer) error {
+	inputs, err := json.Marshal(newJSONIO(c.Inputs))
+	if err != nil {
+		return err
+	}
+	outputs, err := json.Marshal(newJSONIO(c.Outputs))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, `{
+  "format": %q,
+  "version": %d,
+  "numGates": %d,
+  "numWires": %d,
+  "inputs": %s,
+  "outputs": %s,
+  "gates": [`,
+		JSONFormat, JSONVersion, c.NumGates, c.NumWires, inputs, outputs)
+	if err != nil {
+		return err
+	}
+	for idx, g := range c.Gates {
+		switch g.Op {
+		case XOR, XNOR, AND, OR, INV:
+		default:
+			return fmt.Errorf("unsupported gate type %s", g.Op)
+		}
+		data, err := json.Marshal(jsonGate{
+			Op:  g.Op.String(),
+			In:  g.Inputs(),
+			Out: g.Output,
+		})
+		if err != nil {
+			return err
+		}
+		var sep string
+		if idx > 0 {
+			sep = ","
+		}
+		if _, err := fmt.Fprintf(out, "%s\n    %s", sep, data); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(out, "\n  ]\n}\n")
+	return err
+}
+
+// ParseJSON parses a circuit in the JSON circuit interchange format.
+func ParseJSON(in io.Reader) (*Circuit, error) {
+	var data jsonCircuit
+
+	decoder := json.NewDecoder(in)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Format != JSONFormat {
+		return nil, fmt.Errorf("invalid circuit format '%s', expected '%s'",
+			data.Format, JSONFormat)
+	}
+	if data.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported circuit format version %d",
+			data.Version)
+	}
+	if data.NumGates != len(data.Gates) {
+		return nil, fmt.Errorf("invalid number of gates: got %d, expected %d",
+			len(data.Gates), data.NumGates)
+	}
+	if data.NumWires < 0 {
+		return nil, fmt.Errorf("invalid number of wires: %d", data.NumWires)
+	}
+
+	inputs, err := parseJSONIO(data.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := parseJSONIO(data.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	if inputs.Size()+outputs.Size() > data.NumWires {
+		return nil, fmt.Errorf("too many input and output wires: %d+%d > %d",
+			inputs.Size(), outputs.Size(), data.NumWires)
+	}
+
+	wiresSeen := make(Seen, data.NumWires)
+
+	// Mark input wires seen.
+	for i := 0; i < inputs.Size(); i++ {
+		if err := wiresSeen.Set(Wire(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	gates := make([]Gate, len(data.Gates))
+	var stats Stats
+	for idx, g := range data.Gates {
+		op, err := parseJSONOp(g.Op)
+		if err != nil {
+			return nil, fmt.Errorf("gate %d: %s", idx, err)
+		}
+		var arity int
+		if op == INV {
+			arity = 1
+		} else {
+			arity = 2
+		}
+		if len(g.In) != arity {
+			return nil, fmt.Errorf("gate %d: %s has %d inputs, expected %d",
+				idx, op, len(g.In), arity)
+		}
+		for _, w := range g.In {
+			seen, err := wiresSeen.Get(w)
+			if err != nil {
+				return nil, err
+			}
+			if !seen {
+				return nil, fmt.Errorf("input %d of gate %d not set", w, idx)
+			}
+		}
+		if err := wiresSeen.Set(g.Out); err != nil {
+			return nil, err
+		}
+		gates[idx] = Gate{
+			Input0: g.In[0],
+			Output: g.Out,
+			Op:     op,
+		}
+		if arity == 2 {
+			gates[idx].Input1 = g.In[1]
+		}
+		stats[op]++
+	}
+
+	// Check that all wires are seen.
+	for i := 0; i < len(wiresSeen); i++ {
+		if !wiresSeen[i] {
+			return nil, fmt.Errorf("wire %d not assigned", i)
+		}
+	}
+
+	return &Circuit{
+		NumGates: data.NumGates,
+		NumWires: data.NumWires,
+		Inputs:   inputs,
+		Outputs:  outputs,
+		Gates:    gates,
+		Stats:    stats,
+	}, nil
+}
+
+func parseJSONIO(args []jsonIOArg) (IO, error) {
+	var result IO
+	for _, a := range args {
+		if a.Bits < 0 {
+			return nil, fmt.Errorf("invalid size %d for argument '%s'",
+				a.Bits, a.Name)
+		}
+		t, err := types.Parse(a.Type)
+		if err != nil {
+			return nil, err
+		}
+		t.Bits = types.Size(a.Bits)
+
+		arg := IOArg{
+			Name:        a.Name,
+			Type:        t,
+			SharedInput: a.Shared,
+		}
+		switch a.Mode {
+		case "", OutputReveal.String():
+		case OutputShare.String():
+			arg.Mode = OutputShare
+		default:
+			return nil, fmt.Errorf("invalid output mode '%s' for '%s'",
+				a.Mode, a.Name)
+		}
+		if len(a.Compound) > 0 {
+			arg.Compound, err = parseJSONIO(a.Compound)
+			if err != nil {
+				return nil, err
+			}
+			if arg.Compound.Size() != a.Bits {
+				return nil, fmt.Errorf(
+					"compound size %d does not match argument '%s' size %d",
+					arg.Compound.Size(), a.Name, a.Bits)
+			}
+		}
+		result = append(result, arg)
+	}
+	return result, nil
+}
+
+func parseJSONOp(name string) (Operation, error) {
+	for op := XOR; op < Count; op++ {
+		if op.String() == name {
+			return op, nil
+		}
+	}
+	return Count, fmt.Errorf("unsupported gate type %s", name)
+}
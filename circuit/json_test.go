@@ -0,0 +1,263 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// jsonAES parses the AES-128 circuit and gives its arguments names,
+// compound values, and output modes.
+func jsonAES(t *testing.T) *Circuit {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	half, err := types.Parse("uint64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	circ.Inputs[0].Name = "key"
+	circ.Inputs[1].Name = "data"
+	circ.Inputs[1].Compound = IO{
+		{
+			Name:        "lo",
+			Type:        half,
+			SharedInput: true,
+		},
+		{
+			Name: "hi",
+			Type: half,
+		},
+	}
+	circ.Outputs[0].Name = "ciphertext"
+	circ.Outputs[0].Mode = OutputShare
+	return circ
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	circ := jsonAES(t)
+
+	var buf bytes.Buffer
+	if err := circ.MarshalFormat(&buf, "json"); err != nil {
+		t.Fatalf("MarshalFormat failed: %v", err)
+	}
+	parsed, err := ParseJSON(&buf)
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	if parsed.NumGates != circ.NumGates || parsed.NumWires != circ.NumWires {
+		t.Errorf("got %v, expected %v", parsed, circ)
+	}
+	if parsed.Stats != circ.Stats {
+		t.Errorf("got stats %v, expected %v", parsed.Stats, circ.Stats)
+	}
+	if !reflect.DeepEqual(parsed.Gates, circ.Gates) {
+		t.Errorf("gates differ")
+	}
+	if parsed.Inputs.String() != circ.Inputs.String() ||
+		parsed.Outputs.String() != circ.Outputs.String() {
+		t.Errorf("got I/O %v -> %v, expected %v -> %v",
+			parsed.Inputs, parsed.Outputs, circ.Inputs, circ.Outputs)
+	}
+	if !reflect.DeepEqual(parsed.Inputs[1].SharedInputBits(),
+		circ.Inputs[1].SharedInputBits()) {
+		t.Errorf("shared input bits differ")
+	}
+	if parsed.Outputs[0].Mode != OutputShare {
+		t.Errorf("got output mode %v, expected %v",
+			parsed.Outputs[0].Mode, OutputShare)
+	}
+
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("round-trip input"))
+	mask := new(big.Int).Lsh(big.NewInt(1), 64)
+	mask.Sub(mask, big.NewInt(1))
+	args := []*big.Int{
+		key,
+		new(big.Int).And(data, mask),
+		new(big.Int).Rsh(data, 64),
+	}
+	expected, err := circ.Compute(args)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	got, err := parsed.Compute(args)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if got[0].Cmp(expected[0]) != 0 {
+		t.Errorf("got %x, expected %x", got[0], expected[0])
+	}
+
+	// The encoding/json package uses the MarshalJSON method.
+	data1, err := json.Marshal(circ)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	parsed, err = ParseJSON(bytes.NewReader(data1))
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Gates, circ.Gates) {
+		t.Errorf("json.Marshal: gates differ")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	circ := jsonAES(t)
+
+	data, err := circ.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, field := range []string{
+		"format", "version", "numGates", "numWires", "inputs", "outputs",
+		"gates",
+	} {
+		if _, ok := doc[field]; !ok {
+			t.Errorf("missing field %s", field)
+		}
+	}
+	if doc["format"] != JSONFormat {
+		t.Errorf("got format %v, expected %v", doc["format"], JSONFormat)
+	}
+	if doc["version"] != float64(JSONVersion) {
+		t.Errorf("got version %v, expected %v", doc["version"], JSONVersion)
+	}
+
+	checkArg := func(arg interface{}, name, typ string, bits float64) {
+		a, ok := arg.(map[string]interface{})
+		if !ok {
+			t.Fatalf("invalid argument %v", arg)
+		}
+		if a["name"] != name || a["type"] != typ || a["bits"] != bits {
+			t.Errorf("got argument %v, expected %s:%s/%v",
+				a, name, typ, bits)
+		}
+	}
+	inputs := doc["inputs"].([]interface{})
+	if len(inputs) != 2 {
+		t.Fatalf("got %d inputs, expected 2", len(inputs))
+	}
+	checkArg(inputs[0], "key", "uint128", 128)
+	checkArg(inputs[1], "data", "uint128", 128)
+	compound := inputs[1].(map[string]interface{})["compound"].([]interface{})
+	if len(compound) != 2 {
+		t.Fatalf("got %d compound values, expected 2", len(compound))
+	}
+	checkArg(compound[0], "lo", "uint64", 64)
+	checkArg(compound[1], "hi", "uint64", 64)
+	if compound[0].(map[string]interface{})["shared"] != true {
+		t.Errorf("shared input not marked: %v", compound[0])
+	}
+
+	outputs := doc["outputs"].([]interface{})
+	if len(outputs) != 1 {
+		t.Fatalf("got %d outputs, expected 1", len(outputs))
+	}
+	checkArg(outputs[0], "ciphertext", "uint128", 128)
+	if outputs[0].(map[string]interface{})["mode"] != "share" {
+		t.Errorf("output mode not set: %v", outputs[0])
+	}
+
+	gates := doc["gates"].([]interface{})
+	if len(gates) != circ.NumGates || doc["numGates"] != float64(len(gates)) {
+		t.Fatalf("got %d gates, expected %d", len(gates), circ.NumGates)
+	}
+	for idx, g := range gates {
+		gate := g.(map[string]interface{})
+		if len(gate) != 3 {
+			t.Fatalf("gate %d: invalid fields: %v", idx, gate)
+		}
+		in := gate["in"].([]interface{})
+		expected := circ.Gates[idx]
+		if gate["op"] != expected.Op.String() ||
+			len(in) != len(expected.Inputs()) ||
+			gate["out"] != float64(expected.Output) {
+			t.Fatalf("gate %d: got %v, expected %v", idx, gate, expected)
+		}
+		for i, w := range expected.Inputs() {
+			if in[i] != float64(w) {
+				t.Fatalf("gate %d: got %v, expected %v", idx, gate, expected)
+			}
+		}
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	tests := []struct {
+		data    string
+		message string
+	}{
+		{
+			data:    `{"format":"bristol","version":1}`,
+			message: "invalid circuit format",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":2,"numGates":0,
+"numWires":0,"inputs":[],"outputs":[],"gates":[]}`,
+			message: "unsupported circuit format version 2",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":1,"numGates":1,
+"numWires":3,"inputs":[{"name":"a","type":"uint2","bits":2}],
+"outputs":[{"name":"r","type":"bool1","bits":1}],
+"gates":[{"op":"NAND","in":[0,1],"out":2}]}`,
+			message: "unsupported gate type NAND",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":1,"numGates":1,
+"numWires":3,"inputs":[{"name":"a","type":"uint2","bits":2}],
+"outputs":[{"name":"r","type":"bool1","bits":1}],
+"gates":[{"op":"INV","in":[0,1],"out":2}]}`,
+			message: "INV has 2 inputs, expected 1",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":1,"numGates":1,
+"numWires":4,"inputs":[{"name":"a","type":"uint2","bits":2}],
+"outputs":[{"name":"r","type":"bool1","bits":1}],
+"gates":[{"op":"AND","in":[0,2],"out":3}]}`,
+			message: "input 2 of gate 0 not set",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":1,"numGates":1,
+"numWires":3,"inputs":[{"name":"a","type":"uint2","bits":2}],
+"outputs":[{"name":"r","type":"bool1","bits":1,"mode":"hide"}],
+"gates":[{"op":"AND","in":[0,1],"out":2}]}`,
+			message: "invalid output mode 'hide'",
+		},
+		{
+			data: `{"format":"mpcl-circuit","version":1,"numGates":1,
+"numWires":3,"inputs":[{"name":"a","type":"uint2","bits":2}],
+"outputs":[{"name":"r","type":"bool1","bits":1}],
+"gates":[{"op":"AND","in":[0,1],"out":2}],"extra":true}`,
+			message: "unknown field",
+		},
+	}
+	for _, test := range tests {
+		_, err := ParseJSON(strings.NewReader(test.data))
+		if err == nil {
+			t.Errorf("ParseJSON succeeded for %s", test.data)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("got error %q, expected %q", err, test.message)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned when the garbler's declarations exceed
+// the evaluator's limits.
+var ErrLimitExceeded = errors.New("evaluator limit exceeded")
+
+// Limits specify the upper limits for the evaluator's work. The
+// evaluator checks the limits against the input sizes and circuits
+// that the garbler declares so it can reject the excessive
+// declarations before allocating or doing the work. The zero value
+// of a limit disables it.
+type Limits struct {
+	// MaxOTs specifies the maximum number of oblivious transfers
+	// i.e. the maximum number of the evaluator's input bits.
+	MaxOTs int
+
+	// MaxGates specifies the maximum number of gates. In the
+	// streaming mode, the limit applies to the total number of
+	// gates over all streamed circuits.
+	MaxGates int
+
+	// MaxWires specifies the maximum number of wires. In the
+	// streaming mode, the limit applies to the input and output
+	// wires and to the wires of each streamed circuit.
+	MaxWires int
+}
+
+// CheckOTs checks that count oblivious transfers are within the
+// limits.
+func (l Limits) CheckOTs(count int) error {
+	if l.MaxOTs > 0 && count > l.MaxOTs {
+		return fmt.Errorf("%w: %d OTs exceed the maximum of %d",
+			ErrLimitExceeded, count, l.MaxOTs)
+	}
+	return nil
+}
+
+// CheckGates checks that count gates are within the limits.
+func (l Limits) CheckGates(count int) error {
+	if l.MaxGates > 0 && count > l.MaxGates {
+		return fmt.Errorf("%w: %d gates exceed the maximum of %d",
+			ErrLimitExceeded, count, l.MaxGates)
+	}
+	return nil
+}
+
+// CheckWires checks that count wires are within the limits.
+func (l Limits) CheckWires(count int) error {
+	if l.MaxWires > 0 && count > l.MaxWires {
+		return fmt.Errorf("%w: %d wires exceed the maximum of %d",
+			ErrLimitExceeded, count, l.MaxWires)
+	}
+	return nil
+}
+
+// CheckCircuit checks that the evaluator's work for the 2-party
+// circuit c is within the limits.
+func (l Limits) CheckCircuit(c *Circuit) error {
+	if len(c.Inputs) > 1 {
+		if err := l.CheckOTs(int(c.Inputs[1].Type.Bits)); err != nil {
+			return err
+		}
+	}
+	if err := l.CheckGates(c.NumGates); err != nil {
+		return err
+	}
+	return l.CheckWires(c.NumWires)
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// sendLimitsHeader sends the streaming program header with the peer
+// and evaluator input sizes in1 and in2.
+func sendLimitsHeader(conn *p2p.Conn, in1, in2 int) error {
+	var key [16]byte
+	if err := conn.SendData(key[:]); err != nil {
+		return err
+	}
+	if err := conn.SendUint32(DefaultStreamWindow); err != nil {
+		return err
+	}
+	for _, size := range []int{in1, in2} {
+		if err := conn.SendString("x"); err != nil {
+			return err
+		}
+		if err := conn.SendString("uint64"); err != nil {
+			return err
+		}
+		if err := conn.SendUint32(size); err != nil {
+			return err
+		}
+		if err := conn.SendUint32(0); err != nil {
+			return err
+		}
+	}
+	// No outputs, no steps.
+	if err := conn.SendUint32(0); err != nil {
+		return err
+	}
+	if err := conn.SendUint32(0); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+func testLimitsStream(t *testing.T, in1, in2 int, limits Limits) error {
+	gConn, eConn := p2p.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		sendLimitsHeader(gConn, in1, in2)
+		gConn.Close()
+		close(done)
+	}()
+
+	_, _, err := StreamEvaluatorLimits(eConn, ot.NewCO(), []string{"0"},
+		limits, false)
+	eConn.Close()
+	<-done
+
+	if err == nil {
+		t.Fatalf("StreamEvaluatorLimits accepted in1=%d, in2=%d with %v",
+			in1, in2, limits)
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return err
+}
+
+func TestLimitsStreamOTs(t *testing.T) {
+	// The evaluator would allocate the OT flags and labels for 2^30
+	// input bits without the limit.
+	err := testLimitsStream(t, 64, 1<<30, Limits{
+		MaxOTs: 64,
+	})
+	expected := "evaluator limit exceeded: " +
+		"1073741824 OTs exceed the maximum of 64"
+	if err.Error() != expected {
+		t.Errorf("unexpected error: got %q, expected %q", err, expected)
+	}
+}
+
+func TestLimitsStreamWires(t *testing.T) {
+	testLimitsStream(t, 1<<30, 64, Limits{
+		MaxOTs:   64,
+		MaxWires: 1024,
+	})
+}
+
+func TestLimitsCircuit(t *testing.T) {
+	circ := outputsCircuit()
+
+	if err := (Limits{}).CheckCircuit(circ); err != nil {
+		t.Errorf("unlimited CheckCircuit failed: %v", err)
+	}
+	limits := Limits{
+		MaxOTs:   1,
+		MaxGates: 2,
+		MaxWires: 4,
+	}
+	if err := limits.CheckCircuit(circ); err != nil {
+		t.Errorf("CheckCircuit failed: %v", err)
+	}
+
+	for _, l := range []Limits{
+		{MaxGates: 1},
+		{MaxWires: 3},
+	} {
+		err := l.CheckCircuit(circ)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("CheckCircuit(%v): unexpected error: %v", l, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// RunLocal runs the garbled circuit protocol for the circuit with
+// both parties in the same process. The garbler and evaluator
+// communicate over an in-memory connection and use the CO oblivious
+// transfer. Unlike Compute, RunLocal garbles the circuit and runs the
+// oblivious transfers so it is useful for testing and benchmarking
+// the protocol without running two processes. The function returns an
+// error if the garbler and evaluator compute different results.
+func RunLocal(circ *Circuit, garblerInput, evaluatorInput *big.Int) (
+	[]*big.Int, error) {
+
+	gConn, eConn := p2p.Pipe()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := Evaluator(eConn, ot.NewCO(), circ, evaluatorInput,
+			false)
+		// Closing the connection also unblocks the garbler on errors.
+		eConn.Close()
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+
+	values, err := Garbler(gConn, ot.NewCO(), circ, garblerInput, false)
+	// Closing the connection also unblocks the evaluator on errors.
+	gConn.Close()
+	eResult := <-ch
+	if err != nil {
+		return nil, err
+	}
+	if eResult.err != nil {
+		return nil, eResult.err
+	}
+	if len(values) != len(eResult.values) {
+		return nil, fmt.Errorf("garbler %v != evaluator %v",
+			values, eResult.values)
+	}
+	for idx, v := range values {
+		if v.Cmp(eResult.values[idx]) != 0 {
+			return nil, fmt.Errorf("garbler %v != evaluator %v",
+				values, eResult.values)
+		}
+	}
+	return values, nil
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2021, 2023 Markku Rossi
+// Copyright (c) 2020-2021, 2023-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -28,6 +28,10 @@ func (c *Circuit) MarshalFormat(out io.Writer, format string) error {
 		return c.Marshal(out)
 	case "bristol":
 		return c.MarshalBristol(out)
+	case "smtlib":
+		return c.MarshalSMTLIB(out)
+	case "json":
+		return c.MarshalJSONFormat(out)
 	default:
 		return fmt.Errorf("unsupported circuit format: %s", format)
 	}
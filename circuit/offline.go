@@ -0,0 +1,275 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/markkurossi/mpc/ot"
+)
+
+const (
+	// GarbledTablesMagic is a magic number for the garbled tables
+	// format version 0.
+	GarbledTablesMagic = 0x67637400 // gct0
+
+	garbledKeySize = 32
+
+	// garbledMaxPrealloc limits the number of elements that
+	// Unmarshal preallocates based on the untrusted header counts.
+	garbledMaxPrealloc = 64 * 1024
+)
+
+// ErrTablesUsed is returned when garbled tables are used for more
+// than one online evaluation.
+var ErrTablesUsed = errors.New("garbled tables already used")
+
+// GarbledTables contains the garbler's precomputed garbled circuit
+// for an offline/online protocol split. The tables are computed in
+// the offline phase, independently of the parties' inputs, and they
+// are consumed by exactly one online evaluation with GarblerOnline.
+//
+// The tables contain the garbler's secrets: the wire labels of all
+// input and output wires and the free-XOR offset R. They must be
+// stored securely and they must never be reused across sessions. An
+// evaluator that sees the same tables twice learns the XOR of the
+// garbler's input labels, and thus breaks the privacy of both
+// parties' inputs. GarblerOnline marks the tables used and refuses to
+// run with them again, but it can't detect copies restored with
+// Unmarshal; callers must delete the serialized tables once they have
+// been loaded for the online phase.
+type GarbledTables struct {
+	NumGates int
+	NumWires int
+	Key      []byte
+	R        ot.Label
+	Inputs   []ot.Wire
+	Outputs  []ot.Wire
+	Gates    [][]ot.Label
+	used     bool
+}
+
+// GarbleOffline garbles the circuit circ using rng as the source of
+// randomness. The returned tables are used in the online phase with
+// GarblerOnline.
+func GarbleOffline(circ *Circuit, rng io.Reader) (*GarbledTables, error) {
+	key := make([]byte, garbledKeySize)
+	if _, err := io.ReadFull(rng, key); err != nil {
+		return nil, err
+	}
+	garbled, err := circ.garble(rng, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	numOutputs := circ.Outputs.Size()
+
+	return &GarbledTables{
+		NumGates: circ.NumGates,
+		NumWires: circ.NumWires,
+		Key:      key,
+		R:        garbled.R,
+		Inputs:   garbled.Wires[:circ.Inputs.Size()],
+		Outputs:  garbled.Wires[circ.NumWires-numOutputs:],
+		Gates:    garbled.Gates,
+	}, nil
+}
+
+// Verify verifies that the tables are garbled for the circuit circ.
+func (t *GarbledTables) Verify(circ *Circuit) error {
+	if t.NumGates != circ.NumGates || t.NumWires != circ.NumWires ||
+		len(t.Inputs) != circ.Inputs.Size() ||
+		len(t.Outputs) != circ.Outputs.Size() ||
+		len(t.Gates) != len(circ.Gates) {
+		return fmt.Errorf("garbled tables do not match circuit")
+	}
+	for i := 0; i < len(circ.Gates); i++ {
+		var count int
+		switch circ.Gates[i].Op {
+		case XOR, XNOR:
+			count = 0
+		case AND:
+			count = 2
+		case OR:
+			count = 3
+		case INV:
+			count = 1
+		default:
+			return fmt.Errorf("invalid gate type %s", circ.Gates[i].Op)
+		}
+		if len(t.Gates[i]) != count {
+			return fmt.Errorf("garbled tables do not match circuit: "+
+				"gate %d: got %d labels, expected %d",
+				i, len(t.Gates[i]), count)
+		}
+	}
+	return nil
+}
+
+// Marshal marshals the garbled tables into out. Only the zero labels
+// of the input and output wires are stored since the one labels are
+// derived from them with the free-XOR offset R.
+func (t *GarbledTables) Marshal(out io.Writer) error {
+	if t.used {
+		return ErrTablesUsed
+	}
+	w := bufio.NewWriter(out)
+
+	var data = []interface{}{
+		uint32(GarbledTablesMagic),
+		uint32(t.NumGates),
+		uint32(t.NumWires),
+		uint32(len(t.Inputs)),
+		uint32(len(t.Outputs)),
+	}
+	for _, v := range data {
+		if err := binary.Write(w, bo, v); err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(t.Key); err != nil {
+		return err
+	}
+
+	var labelData ot.LabelData
+	writeLabel := func(l ot.Label) error {
+		_, err := w.Write(l.Bytes(&labelData))
+		return err
+	}
+
+	if err := writeLabel(t.R); err != nil {
+		return err
+	}
+	for _, wire := range t.Inputs {
+		if err := writeLabel(wire.L0); err != nil {
+			return err
+		}
+	}
+	for _, wire := range t.Outputs {
+		if err := writeLabel(wire.L0); err != nil {
+			return err
+		}
+	}
+	for _, row := range t.Gates {
+		if err := w.WriteByte(byte(len(row))); err != nil {
+			return err
+		}
+		for _, l := range row {
+			if err := writeLabel(l); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// Unmarshal unmarshals the garbled tables from in. The element
+// counts in the input header are not trusted: the tables grow as
+// their data is read from in so a crafted header can't force large
+// allocations without the corresponding input.
+func (t *GarbledTables) Unmarshal(in io.Reader) error {
+	r := bufio.NewReader(in)
+
+	var header struct {
+		Magic      uint32
+		NumGates   uint32
+		NumWires   uint32
+		NumInputs  uint32
+		NumOutputs uint32
+	}
+	if err := binary.Read(r, bo, &header); err != nil {
+		return err
+	}
+	if header.Magic != GarbledTablesMagic {
+		return fmt.Errorf("invalid garbled tables magic: %08x", header.Magic)
+	}
+	if header.NumInputs > header.NumWires ||
+		header.NumOutputs > header.NumWires {
+		return fmt.Errorf("invalid garbled tables: %d inputs, %d outputs, "+
+			"%d wires", header.NumInputs, header.NumOutputs, header.NumWires)
+	}
+
+	key := make([]byte, garbledKeySize)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return err
+	}
+
+	var labelData ot.LabelData
+	readLabel := func(l *ot.Label) error {
+		if _, err := io.ReadFull(r, labelData[:]); err != nil {
+			return err
+		}
+		l.SetData(&labelData)
+		return nil
+	}
+	readWires := func(count uint32) ([]ot.Wire, error) {
+		wires := make([]ot.Wire, 0, prealloc(count))
+		for i := 0; i < int(count); i++ {
+			var wire ot.Wire
+			if err := readLabel(&wire.L0); err != nil {
+				return nil, err
+			}
+			wire.L1 = wire.L0
+			wire.L1.Xor(t.R)
+			wires = append(wires, wire)
+		}
+		return wires, nil
+	}
+
+	if err := readLabel(&t.R); err != nil {
+		return err
+	}
+	inputs, err := readWires(header.NumInputs)
+	if err != nil {
+		return err
+	}
+	outputs, err := readWires(header.NumOutputs)
+	if err != nil {
+		return err
+	}
+
+	gates := make([][]ot.Label, 0, prealloc(header.NumGates))
+	for i := 0; i < int(header.NumGates); i++ {
+		count, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if count > 4 {
+			return fmt.Errorf("invalid garbled tables: gate %d: %d labels",
+				i, count)
+		}
+		row := make([]ot.Label, count)
+		for j := 0; j < len(row); j++ {
+			if err := readLabel(&row[j]); err != nil {
+				return err
+			}
+		}
+		gates = append(gates, row)
+	}
+
+	t.NumGates = int(header.NumGates)
+	t.NumWires = int(header.NumWires)
+	t.Key = key
+	t.Inputs = inputs
+	t.Outputs = outputs
+	t.Gates = gates
+	t.used = false
+
+	return nil
+}
+
+// prealloc returns the initial capacity for count elements read from
+// untrusted input.
+func prealloc(count uint32) int {
+	if count > garbledMaxPrealloc {
+		return garbledMaxPrealloc
+	}
+	return int(count)
+}
@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"runtime"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+type garblerFunc func(conn *p2p.Conn) ([]*big.Int, error)
+
+func runGarbled(t *testing.T, circ *Circuit, garbler garblerFunc,
+	e *big.Int) []*big.Int {
+
+	return runGarbledEncoding(t, circ, ot.LabelMSB, garbler, e)
+}
+
+func runGarbledEncoding(t *testing.T, circ *Circuit, enc ot.LabelEncoding,
+	garbler garblerFunc, e *big.Int) []*big.Int {
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	gConn.SetLabelEncoding(enc)
+	eConn.SetLabelEncoding(enc)
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := Evaluator(eConn, ot.NewCO(), circ, e, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	gResult, err := garbler(gConn)
+	if err != nil {
+		t.Fatalf("garbler failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("evaluator failed: %v", eResult.err)
+	}
+	if len(gResult) != len(eResult.values) {
+		t.Fatalf("result mismatch: garbler %v, evaluator %v",
+			gResult, eResult.values)
+	}
+	for i := range gResult {
+		if gResult[i].Cmp(eResult.values[i]) != 0 {
+			t.Fatalf("result %d mismatch: garbler %v, evaluator %v",
+				i, gResult[i], eResult.values[i])
+		}
+	}
+	return gResult
+}
+
+func TestGarbleOffline(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("offline/online!!"))
+
+	// Single-phase garbling.
+	expected := runGarbled(t, circ, func(conn *p2p.Conn) ([]*big.Int, error) {
+		return Garbler(conn, ot.NewCO(), circ, key, false)
+	}, data)
+	plain, err := circ.Compute([]*big.Int{key, data})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if expected[0].Cmp(plain[0]) != 0 {
+		t.Fatalf("Garbler: got %x, expected %x", expected[0], plain[0])
+	}
+
+	// Offline phase.
+	tables, err := GarbleOffline(circ, rand.Reader)
+	if err != nil {
+		t.Fatalf("GarbleOffline failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tables.Marshal(&buf); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	loaded := new(GarbledTables)
+	if err := loaded.Unmarshal(&buf); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	// Online phase.
+	result := runGarbled(t, circ, func(conn *p2p.Conn) ([]*big.Int, error) {
+		return GarblerOnline(conn, ot.NewCO(), circ, loaded, key, false)
+	}, data)
+
+	if len(result) != len(expected) {
+		t.Fatalf("got %v, expected %v", result, expected)
+	}
+	for i := range result {
+		if result[i].Cmp(expected[i]) != 0 {
+			t.Errorf("result %d: got %x, expected %x",
+				i, result[i], expected[i])
+		}
+	}
+
+	// The tables must not be reused.
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+	_, err = GarblerOnline(gConn, ot.NewCO(), circ, loaded, key, false)
+	if !errors.Is(err, ErrTablesUsed) {
+		t.Errorf("GarblerOnline: expected %v, got %v", ErrTablesUsed, err)
+	}
+	if err := loaded.Marshal(&buf); !errors.Is(err, ErrTablesUsed) {
+		t.Errorf("Marshal: expected %v, got %v", ErrTablesUsed, err)
+	}
+}
+
+func TestGarbledTablesMismatch(t *testing.T) {
+	circ, err := ParseBristol(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	tables, err := GarbleOffline(circ, rand.Reader)
+	if err != nil {
+		t.Fatalf("GarbleOffline failed: %v", err)
+	}
+	if err := tables.Verify(circ); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	aes, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tables.Verify(aes); err == nil {
+		t.Errorf("Verify succeeded for wrong circuit")
+	}
+}
+
+func TestGarbledTablesUnmarshalCounts(t *testing.T) {
+	// A header claiming the maximum counts but without the
+	// corresponding data must fail without allocating the tables.
+	var buf bytes.Buffer
+	for _, v := range []uint32{
+		GarbledTablesMagic, 0xffffffff, 0xffffffff, 0xffffffff, 0,
+	} {
+		binary.Write(&buf, bo, v)
+	}
+	buf.Write(make([]byte, garbledKeySize+16*4))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := new(GarbledTables).Unmarshal(&buf)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatalf("Unmarshal succeeded for truncated tables")
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 64*1024*1024 {
+		t.Errorf("Unmarshal allocated %d bytes for truncated tables", alloc)
+	}
+}
@@ -0,0 +1,64 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+)
+
+// BitName returns the name of the argument and its bit that the bit
+// index bit refers to. The bit index is counted over all arguments.
+func (io IO) BitName(bit int) string {
+	for idx, arg := range io {
+		size := int(arg.Type.Bits)
+		if bit < size {
+			name := arg.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("%d", idx)
+			}
+			return fmt.Sprintf("%s[%d]", name, bit)
+		}
+		bit -= size
+	}
+	return fmt.Sprintf("[%d]", bit)
+}
+
+// ValidateOutputs checks that the circuit's output wires are
+// assigned. Each output wire must be the output of exactly one gate,
+// and it must not alias an input wire. A gate that assigns the output
+// wire of another output mixes the outputs' values.
+func (c *Circuit) ValidateOutputs() error {
+	numOutputs := c.Outputs.Size()
+	first := c.NumWires - numOutputs
+	if first < c.Inputs.Size() {
+		return fmt.Errorf("output %s aliases input wire %d",
+			c.Outputs.BitName(0), first)
+	}
+
+	assigned := make([]int, numOutputs)
+	for i := range assigned {
+		assigned[i] = -1
+	}
+	for idx, g := range c.Gates {
+		o := int(g.Output) - first
+		if o < 0 || o >= numOutputs {
+			continue
+		}
+		if assigned[o] >= 0 {
+			return fmt.Errorf("output %s: wire %d assigned by gates %d and %d",
+				c.Outputs.BitName(o), g.Output, assigned[o], idx)
+		}
+		assigned[o] = idx
+	}
+	for o, gate := range assigned {
+		if gate < 0 {
+			return fmt.Errorf("output %s: wire %d not assigned",
+				c.Outputs.BitName(o), first+o)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// outputsCircuit creates a circuit that computes a&b and a^b for the
+// 1-bit inputs a and b.
+func outputsCircuit() *Circuit {
+	bit := types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       1,
+	}
+	return &Circuit{
+		NumGates: 2,
+		NumWires: 4,
+		Inputs: IO{
+			{Name: "a", Type: bit},
+			{Name: "b", Type: bit},
+		},
+		Outputs: IO{
+			{Name: "and", Type: bit},
+			{Name: "xor", Type: bit},
+		},
+		Gates: []Gate{
+			{Input0: 0, Input1: 1, Output: 2, Op: AND},
+			{Input0: 0, Input1: 1, Output: 3, Op: XOR},
+		},
+	}
+}
+
+func TestValidateOutputs(t *testing.T) {
+	if err := outputsCircuit().ValidateOutputs(); err != nil {
+		t.Fatalf("ValidateOutputs failed: %v", err)
+	}
+
+	tests := []struct {
+		modify  func(c *Circuit)
+		message string
+	}{
+		{
+			modify: func(c *Circuit) {
+				// Both gates assign the output and.
+				c.Gates[1].Output = 2
+			},
+			message: "output and[0]: wire 2 assigned by gates 0 and 1",
+		},
+		{
+			modify: func(c *Circuit) {
+				// The output xor is not assigned.
+				c.Gates[1].Output = 1
+			},
+			message: "output xor[0]: wire 3 not assigned",
+		},
+		{
+			modify: func(c *Circuit) {
+				// The outputs overlap with the inputs.
+				c.NumWires = 3
+			},
+			message: "output and[0] aliases input wire 1",
+		},
+	}
+	for _, test := range tests {
+		c := outputsCircuit()
+		test.modify(c)
+		err := c.ValidateOutputs()
+		if err == nil {
+			t.Errorf("ValidateOutputs succeeded, expected %q", test.message)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("got error %q, expected %q", err, test.message)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
 	"os"
 	"regexp"
@@ -48,7 +49,8 @@ func (s Seen) Set(index Wire) error {
 func IsFilename(file string) bool {
 	return strings.HasSuffix(file, ".circ") ||
 		strings.HasSuffix(file, ".bristol") ||
-		strings.HasSuffix(file, ".mpclc")
+		strings.HasSuffix(file, ".mpclc") ||
+		strings.HasSuffix(file, ".json")
 }
 
 // Parse parses the circuit file.
@@ -59,10 +61,27 @@ func Parse(file string) (*Circuit, error) {
 	}
 	defer f.Close()
 
+	return parse(file, f)
+}
+
+// ParseFS parses the circuit file from the file system fsys.
+func ParseFS(fsys fs.FS, file string) (*Circuit, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parse(file, f)
+}
+
+func parse(file string, in io.Reader) (*Circuit, error) {
 	if strings.HasSuffix(file, ".circ") || strings.HasSuffix(file, ".bristol") {
-		return ParseBristol(f)
+		return ParseBristol(in)
 	} else if strings.HasSuffix(file, ".mpclc") {
-		return ParseMPCLC(f)
+		return ParseMPCLC(in)
+	} else if strings.HasSuffix(file, ".json") {
+		return ParseJSON(in)
 	}
 	return nil, fmt.Errorf("unsupported circuit format")
 }
@@ -0,0 +1,224 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// poolDone tells the garbler pool that the evaluator has no more
+// tasks for the connection.
+const poolDone = 0xffffffff
+
+// PoolTask specifies an independent circuit instance and the party's
+// input for it. The garbler and evaluator pools must have the same
+// circuits in the same task order.
+type PoolTask struct {
+	Circuit *Circuit
+	Inputs  *big.Int
+}
+
+// EvaluatorPool evaluates independent circuit instances concurrently
+// over a pool of connections to the same garbler, which serves the
+// instances with a GarblerPool. The tasks are load-balanced so that
+// each connection evaluates the next pending task when it completes
+// its previous task. Each connection is used by one goroutine and it
+// has its own oblivious transfer instance and I/O statistics.
+type EvaluatorPool struct {
+	conns []*p2p.Conn
+	ots   []ot.OT
+}
+
+// NewEvaluatorPool creates an evaluator pool for the connections. The
+// newOT function creates the oblivious transfer instance for each
+// connection.
+func NewEvaluatorPool(conns []*p2p.Conn, newOT func() ot.OT) (
+	*EvaluatorPool, error) {
+
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("no connections for evaluator pool")
+	}
+	pool := &EvaluatorPool{
+		conns: conns,
+	}
+	for range conns {
+		pool.ots = append(pool.ots, newOT())
+	}
+	return pool, nil
+}
+
+// Stats returns the sum of the I/O statistics of the pool's
+// connections.
+func (pool *EvaluatorPool) Stats() p2p.IOStats {
+	return poolStats(pool.conns)
+}
+
+// Evaluate evaluates the tasks and returns their outputs in the task
+// order. If the evaluation fails, the function returns the first
+// error and the caller must close the connections since their
+// protocol state is undefined.
+func (pool *EvaluatorPool) Evaluate(tasks []PoolTask) ([][]*big.Int, error) {
+	queue := make(chan int, len(tasks))
+	for idx := range tasks {
+		queue <- idx
+	}
+	close(queue)
+
+	results := make([][]*big.Int, len(tasks))
+	errs := make([]error, len(pool.conns))
+
+	var wg sync.WaitGroup
+	for i := range pool.conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pool.worker(pool.conns[i], pool.ots[i], queue, tasks,
+				results)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (pool *EvaluatorPool) worker(conn *p2p.Conn, oti ot.OT, queue chan int,
+	tasks []PoolTask, results [][]*big.Int) error {
+
+	for idx := range queue {
+		if err := conn.SendUint32(idx); err != nil {
+			return err
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		task := tasks[idx]
+		result, err := Evaluator(conn, oti, task.Circuit, task.Inputs, false)
+		if err != nil {
+			return fmt.Errorf("task %d: %w", idx, err)
+		}
+		results[idx] = result
+	}
+	if err := conn.SendUint32(poolDone); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// GarblerPool garbles the circuit instances that an EvaluatorPool
+// requests over a pool of connections. Each connection is used by one
+// goroutine and it has its own oblivious transfer instance and I/O
+// statistics.
+type GarblerPool struct {
+	conns []*p2p.Conn
+	ots   []ot.OT
+}
+
+// NewGarblerPool creates a garbler pool for the connections. The
+// newOT function creates the oblivious transfer instance for each
+// connection.
+func NewGarblerPool(conns []*p2p.Conn, newOT func() ot.OT) (
+	*GarblerPool, error) {
+
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("no connections for garbler pool")
+	}
+	pool := &GarblerPool{
+		conns: conns,
+	}
+	for range conns {
+		pool.ots = append(pool.ots, newOT())
+	}
+	return pool, nil
+}
+
+// Stats returns the sum of the I/O statistics of the pool's
+// connections.
+func (pool *GarblerPool) Stats() p2p.IOStats {
+	return poolStats(pool.conns)
+}
+
+// Serve garbles the tasks in the order the evaluator requests them
+// and returns their outputs in the task order. The function returns
+// when the evaluator has completed all tasks on all connections. If
+// the garbling fails, the function returns the first error and the
+// caller must close the connections.
+func (pool *GarblerPool) Serve(tasks []PoolTask) ([][]*big.Int, error) {
+	results := make([][]*big.Int, len(tasks))
+	errs := make([]error, len(pool.conns))
+
+	var m sync.Mutex
+	served := make([]bool, len(tasks))
+
+	var wg sync.WaitGroup
+	for i := range pool.conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pool.worker(pool.conns[i], pool.ots[i], tasks, results,
+				&m, served)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for idx, s := range served {
+		if !s {
+			return nil, fmt.Errorf("task %d not evaluated", idx)
+		}
+	}
+	return results, nil
+}
+
+func (pool *GarblerPool) worker(conn *p2p.Conn, oti ot.OT, tasks []PoolTask,
+	results [][]*big.Int, m *sync.Mutex, served []bool) error {
+
+	for {
+		idx, err := conn.ReceiveUint32()
+		if err != nil {
+			return err
+		}
+		if idx == poolDone {
+			return nil
+		}
+		m.Lock()
+		if idx >= len(tasks) || served[idx] {
+			m.Unlock()
+			return fmt.Errorf("invalid task %d", idx)
+		}
+		served[idx] = true
+		m.Unlock()
+
+		task := tasks[idx]
+		result, err := Garbler(conn, oti, task.Circuit, task.Inputs, false)
+		if err != nil {
+			return fmt.Errorf("task %d: %w", idx, err)
+		}
+		results[idx] = result
+	}
+}
+
+func poolStats(conns []*p2p.Conn) p2p.IOStats {
+	stats := p2p.NewIOStats()
+	for _, conn := range conns {
+		stats = stats.Add(conn.Stats)
+	}
+	return stats
+}
@@ -0,0 +1,119 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestPool(t *testing.T) {
+	const numTasks = 16
+	const numConns = 4
+
+	aes, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits := outputsCircuit()
+
+	var gTasks, eTasks []PoolTask
+	var expected [][]*big.Int
+	for i := 0; i < numTasks; i++ {
+		var circ *Circuit
+		var g, e *big.Int
+		if i%2 == 0 {
+			circ = bits
+			g = big.NewInt(int64(i / 2 % 2))
+			e = big.NewInt(int64(i / 4 % 2))
+		} else {
+			circ = aes
+			g = big.NewInt(int64(i))
+			e = big.NewInt(int64(i * 1000))
+		}
+		gTasks = append(gTasks, PoolTask{
+			Circuit: circ,
+			Inputs:  g,
+		})
+		eTasks = append(eTasks, PoolTask{
+			Circuit: circ,
+			Inputs:  e,
+		})
+		result, err := circ.Compute([]*big.Int{g, e})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, result)
+	}
+
+	var gConns, eConns []*p2p.Conn
+	for i := 0; i < numConns; i++ {
+		g, e := p2p.Pipe()
+		gConns = append(gConns, g)
+		eConns = append(eConns, e)
+	}
+	newOT := func() ot.OT {
+		return ot.NewCO()
+	}
+	gPool, err := NewGarblerPool(gConns, newOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ePool, err := NewEvaluatorPool(eConns, newOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		values [][]*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := gPool.Serve(gTasks)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	eResults, err := ePool.Evaluate(eTasks)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	gResult := <-ch
+	if gResult.err != nil {
+		t.Fatalf("Serve failed: %v", gResult.err)
+	}
+
+	for i := 0; i < numTasks; i++ {
+		for j := range expected[i] {
+			if eResults[i][j].Cmp(expected[i][j]) != 0 ||
+				gResult.values[i][j].Cmp(expected[i][j]) != 0 {
+				t.Errorf("task %d: result %d: garbler %x, evaluator %x, "+
+					"expected %x", i, j, gResult.values[i][j],
+					eResults[i][j], expected[i][j])
+			}
+		}
+	}
+
+	var sum uint64
+	for _, conn := range eConns {
+		sum += conn.Stats.Sum()
+	}
+	if sum == 0 || ePool.Stats().Sum() != sum {
+		t.Errorf("pool stats %d, connection stats %d", ePool.Stats().Sum(),
+			sum)
+	}
+
+	for i := 0; i < numConns; i++ {
+		gConns[i].Close()
+		eConns[i].Close()
+	}
+}
@@ -0,0 +1,86 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+)
+
+// ReorderInputs creates an equivalent circuit whose inputs are the
+// inputs of the circuit c permuted by perm: the input i of the
+// result circuit is the input perm[i] of c. The input wires are
+// renumbered to follow the new input order and all other wires keep
+// their IDs.
+func (c *Circuit) ReorderInputs(perm []int) (*Circuit, error) {
+	if len(perm) != len(c.Inputs) {
+		return nil, fmt.Errorf("invalid permutation: got %d inputs, need %d",
+			len(perm), len(c.Inputs))
+	}
+	seen := make([]bool, len(perm))
+	for _, p := range perm {
+		if p < 0 || p >= len(perm) || seen[p] {
+			return nil, fmt.Errorf("invalid permutation: %v", perm)
+		}
+		seen[p] = true
+	}
+
+	// Input wire offsets in the original circuit.
+	offsets := make([]int, len(c.Inputs))
+	var size int
+	for idx, input := range c.Inputs {
+		offsets[idx] = size
+		size += int(input.Type.Bits)
+	}
+
+	// Map the original input wires to their new IDs.
+	wireMap := make([]Wire, size)
+	inputs := make(IO, len(perm))
+	var next int
+	for idx, p := range perm {
+		inputs[idx] = c.Inputs[p]
+		for i := 0; i < int(c.Inputs[p].Type.Bits); i++ {
+			wireMap[offsets[p]+i] = Wire(next)
+			next++
+		}
+	}
+	mapWire := func(w Wire) Wire {
+		if w.Int() < size {
+			return wireMap[w]
+		}
+		return w
+	}
+
+	gates := make([]Gate, len(c.Gates))
+	for idx, g := range c.Gates {
+		g.Input0 = mapWire(g.Input0)
+		if g.Op != INV {
+			g.Input1 = mapWire(g.Input1)
+		}
+		g.Output = mapWire(g.Output)
+		gates[idx] = g
+	}
+
+	return &Circuit{
+		NumGates: c.NumGates,
+		NumWires: c.NumWires,
+		Inputs:   inputs,
+		Outputs:  c.Outputs,
+		Gates:    gates,
+		Stats:    c.Stats,
+	}, nil
+}
+
+// SwapParties creates an equivalent circuit where the garbler and
+// evaluator inputs are swapped. The garbler of the result circuit
+// provides the evaluator's input of the circuit c and vice versa.
+func (c *Circuit) SwapParties() (*Circuit, error) {
+	if c.NumParties() != 2 {
+		return nil, fmt.Errorf("can't swap parties of a %d-party circuit",
+			c.NumParties())
+	}
+	return c.ReorderInputs([]int{IDEvaluator, IDGarbler})
+}
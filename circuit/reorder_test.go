@@ -0,0 +1,85 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestSwapParties(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("swapped parties!"))
+
+	plain, err := circ.Compute([]*big.Int{key, data})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+
+	swapped, err := circ.SwapParties()
+	if err != nil {
+		t.Fatalf("SwapParties failed: %v", err)
+	}
+	if swapped.Inputs[0].Name != circ.Inputs[1].Name ||
+		swapped.Inputs[1].Name != circ.Inputs[0].Name {
+		t.Errorf("inputs not swapped: %v", swapped.Inputs)
+	}
+	result, err := swapped.Compute([]*big.Int{data, key})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if result[0].Cmp(plain[0]) != 0 {
+		t.Errorf("Compute: got %x, expected %x", result[0], plain[0])
+	}
+
+	// The garbler provides the data and the evaluator the key.
+	result = runGarbledEncoding(t, swapped, ot.LabelMSB,
+		func(conn *p2p.Conn) ([]*big.Int, error) {
+			return Garbler(conn, ot.NewCO(), swapped, data, false)
+		}, key)
+	if result[0].Cmp(plain[0]) != 0 {
+		t.Errorf("garbled: got %x, expected %x", result[0], plain[0])
+	}
+
+	// Swapping twice restores the original circuit.
+	again, err := swapped.SwapParties()
+	if err != nil {
+		t.Fatalf("SwapParties failed: %v", err)
+	}
+	for idx, g := range again.Gates {
+		if g != circ.Gates[idx] {
+			t.Fatalf("gate %d: got %v, expected %v", idx, g, circ.Gates[idx])
+		}
+	}
+}
+
+func TestReorderInputsInvalid(t *testing.T) {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, perm := range [][]int{
+		nil,
+		{0},
+		{0, 0},
+		{1, 2},
+		{-1, 0},
+		{0, 1, 2},
+	} {
+		_, err := circ.ReorderInputs(perm)
+		if err == nil {
+			t.Errorf("ReorderInputs(%v) succeeded", perm)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// ErrSharedInputMismatch is returned when the garbler and evaluator
+// have different values for the shared input arguments.
+var ErrSharedInputMismatch = errors.New("shared input values differ")
+
+// SharedInputBits returns a bitmask of the argument bits which are
+// shared inputs. The compound arguments are marked shared either as a
+// whole or by their individual elements.
+func (io IOArg) SharedInputBits() []bool {
+	var result []bool
+	if len(io.Compound) > 0 && !io.SharedInput {
+		for _, arg := range io.Compound {
+			result = append(result, arg.SharedInputBits()...)
+		}
+		return result
+	}
+	for i := 0; i < int(io.Type.Bits); i++ {
+		result = append(result, io.SharedInput)
+	}
+	return result
+}
+
+// sharedInputs returns the bitmask of the evaluator's shared input
+// bits. The function returns nil if the circuit does not have shared
+// inputs.
+func (c *Circuit) sharedInputs() []bool {
+	if len(c.Inputs) < 2 {
+		return nil
+	}
+	bits := c.Inputs[1].SharedInputBits()
+	for _, shared := range bits {
+		if shared {
+			return bits
+		}
+	}
+	return nil
+}
+
+// sharedDigest computes the digest of the shared input bits of the
+// evaluator's input value.
+func sharedDigest(shared []bool, value *big.Int) []byte {
+	h := sha256.New()
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(shared)))
+	h.Write(buf[:])
+
+	packed := make([]byte, (len(shared)+7)/8)
+	for i, s := range shared {
+		if s {
+			packed[i/8] |= byte(value.Bit(i) << (i % 8))
+		}
+	}
+	h.Write(packed)
+
+	return h.Sum(nil)
+}
+
+// GarblerShared runs the garbler on the P2P network for a circuit
+// with shared inputs. The shared inputs are the evaluator's input
+// arguments that are marked with SharedInput. Both parties know the
+// shared values so the garbler sends their wire labels directly
+// instead of transferring them with oblivious transfer. The argument
+// shared holds the shared values in the layout of the evaluator's
+// input; its non-shared bits are ignored. The parties verify that
+// they have the same shared values by exchanging the digests of the
+// values, and both parties abort with ErrSharedInputMismatch if the
+// values differ.
+func GarblerShared(conn *p2p.Conn, oti ot.OT, circ *Circuit, inputs,
+	shared *big.Int, verbose bool) ([]*big.Int, error) {
+
+	timing := NewTiming()
+	if verbose {
+		fmt.Printf(" - Garbling...\n")
+	}
+
+	tables, err := GarbleOffline(circ, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	timing.Sample("Garble", nil)
+
+	return garblerOnline(conn, oti, circ, tables, inputs, shared, verbose,
+		timing)
+}
+
+// sendShared sends the digest and the wire labels of the shared
+// inputs to the evaluator.
+func sendShared(conn *p2p.Conn, circ *Circuit, tables *GarbledTables,
+	shared []bool, value *big.Int) error {
+
+	if err := conn.SendData(sharedDigest(shared, value)); err != nil {
+		return err
+	}
+	offset := int(circ.Inputs[0].Type.Bits)
+	var labels []ot.Label
+	for i, s := range shared {
+		if !s {
+			continue
+		}
+		wire := tables.Inputs[offset+i]
+		if value.Bit(i) == 1 {
+			labels = append(labels, wire.L1)
+		} else {
+			labels = append(labels, wire.L0)
+		}
+	}
+	return conn.SendLabels(labels)
+}
+
+// receiveShared receives the digest and the wire labels of the shared
+// inputs from the garbler. The function verifies that the garbler's
+// shared values match the evaluator's values.
+func receiveShared(conn *p2p.Conn, circ *Circuit, wires []ot.Label,
+	shared []bool, value *big.Int) error {
+
+	digest, err := conn.ReceiveData()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(digest, sharedDigest(shared, value)) {
+		return ErrSharedInputMismatch
+	}
+	var count int
+	for _, s := range shared {
+		if s {
+			count++
+		}
+	}
+	labels := make([]ot.Label, count)
+	if err := conn.ReceiveLabels(labels); err != nil {
+		return err
+	}
+	offset := int(circ.Inputs[0].Type.Bits)
+	var idx int
+	for i, s := range shared {
+		if s {
+			wires[offset+i] = labels[idx]
+			idx++
+		}
+	}
+	return nil
+}
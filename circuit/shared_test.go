@@ -0,0 +1,148 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+	"github.com/markkurossi/mpc/types"
+)
+
+// countingOT counts the number of received OT labels.
+type countingOT struct {
+	ot.OT
+	count int
+}
+
+func (c *countingOT) Receive(flags []bool, result []ot.Label) error {
+	c.count += len(flags)
+	return c.OT.Receive(flags, result)
+}
+
+// sharedAES parses the AES-128 circuit and splits its data input into
+// two 64-bit halves, marking the low half shared.
+func sharedAES(t *testing.T) *Circuit {
+	circ, err := Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       64,
+	}
+	circ.Inputs[1].Compound = IO{
+		{
+			Name:        "lo",
+			Type:        half,
+			SharedInput: true,
+		},
+		{
+			Name: "hi",
+			Type: half,
+		},
+	}
+	return circ
+}
+
+func runShared(circ *Circuit, oti ot.OT, key, gShared, data *big.Int) (
+	[]*big.Int, []*big.Int, error, error) {
+
+	gConn, eConn := p2p.Pipe()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := Evaluator(eConn, oti, circ, data, false)
+		// Closing the connection also unblocks the garbler on errors.
+		eConn.Close()
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	gValues, gErr := GarblerShared(gConn, ot.NewCO(), circ, key, gShared,
+		false)
+	gConn.Close()
+	eResult := <-ch
+	return gValues, eResult.values, gErr, eResult.err
+}
+
+func TestSharedInputs(t *testing.T) {
+	circ := sharedAES(t)
+
+	bits := circ.Inputs[1].SharedInputBits()
+	if len(bits) != 128 || !bits[0] || !bits[63] || bits[64] {
+		t.Fatalf("invalid shared input bits: %v", bits)
+	}
+
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("shared/private!!"))
+
+	// The garbler knows only the shared low half.
+	mask := new(big.Int).Lsh(big.NewInt(1), 64)
+	mask.Sub(mask, big.NewInt(1))
+	lo := new(big.Int).And(data, mask)
+
+	oti := &countingOT{
+		OT: ot.NewCO(),
+	}
+	gValues, eValues, gErr, eErr := runShared(circ, oti, key, lo, data)
+	if gErr != nil {
+		t.Fatalf("garbler failed: %v", gErr)
+	}
+	if eErr != nil {
+		t.Fatalf("evaluator failed: %v", eErr)
+	}
+	hi := new(big.Int).Rsh(data, 64)
+	plain, err := circ.Compute([]*big.Int{key, lo, hi})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if gValues[0].Cmp(plain[0]) != 0 || eValues[0].Cmp(plain[0]) != 0 {
+		t.Fatalf("result mismatch: garbler %x, evaluator %x, expected %x",
+			gValues[0], eValues[0], plain[0])
+	}
+	if oti.count != 64 {
+		t.Errorf("got %d OTs, expected 64", oti.count)
+	}
+}
+
+func TestSharedInputsMismatch(t *testing.T) {
+	circ := sharedAES(t)
+
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("shared/private!!"))
+
+	_, _, gErr, eErr := runShared(circ, ot.NewCO(), key, big.NewInt(42),
+		data)
+	if gErr == nil {
+		t.Errorf("garbler succeeded with mismatching shared inputs")
+	}
+	if !errors.Is(eErr, ErrSharedInputMismatch) {
+		t.Errorf("evaluator: got %v, expected %v", eErr,
+			ErrSharedInputMismatch)
+	}
+}
+
+func TestSharedInputsMissing(t *testing.T) {
+	circ := sharedAES(t)
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("shared/private!!"))
+
+	_, _, gErr, _ := runShared(circ, ot.NewCO(), key, nil, data)
+	if gErr == nil {
+		t.Errorf("garbler succeeded without shared input values")
+	}
+}
@@ -0,0 +1,115 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MarshalSMTLIB marshals the circuit as an SMT-LIB script for formal
+// verification with SMT solvers. The circuit inputs are declared as
+// free bit-vector constants and the outputs are defined as
+// bit-vector functions of the circuit wires. Each wire is a boolean
+// constant and each gate is an assertion that defines its output
+// wire. The script does not contain check-sat commands so a solver
+// can verify properties of the circuit, for example, its equivalence
+// to a specification, by appending assertions and commands to the
+// script.
+func (c *Circuit) MarshalSMTLIB(out io.Writer) error {
+	inputs := smtNames(c.Inputs, "in")
+	outputs := smtNames(c.Outputs, "out")
+
+	fmt.Fprintf(out, "; circuit: #gates=%d, #wires=%d\n",
+		c.NumGates, c.NumWires)
+	fmt.Fprintf(out, "(set-logic QF_BV)\n")
+
+	// Inputs.
+	var w int
+	for idx, input := range c.Inputs {
+		fmt.Fprintf(out, "(declare-const %s (_ BitVec %d))\n",
+			inputs[idx], input.Type.Bits)
+	}
+	for idx, input := range c.Inputs {
+		for bit := 0; bit < int(input.Type.Bits); bit++ {
+			fmt.Fprintf(out, "(define-fun w%d () Bool ", w)
+			fmt.Fprintf(out, "(= ((_ extract %d %d) %s) #b1))\n",
+				bit, bit, inputs[idx])
+			w++
+		}
+	}
+
+	// Gates.
+	for _, g := range c.Gates {
+		fmt.Fprintf(out, "(declare-const w%d Bool)\n", g.Output)
+	}
+	for _, g := range c.Gates {
+		var expr string
+		switch g.Op {
+		case XOR:
+			expr = fmt.Sprintf("(xor w%d w%d)", g.Input0, g.Input1)
+		case XNOR:
+			expr = fmt.Sprintf("(not (xor w%d w%d))",
+				g.Input0, g.Input1)
+		case AND:
+			expr = fmt.Sprintf("(and w%d w%d)", g.Input0, g.Input1)
+		case OR:
+			expr = fmt.Sprintf("(or w%d w%d)", g.Input0, g.Input1)
+		case INV:
+			expr = fmt.Sprintf("(not w%d)", g.Input0)
+		default:
+			return fmt.Errorf("unsupported gate type %s", g.Op)
+		}
+		fmt.Fprintf(out, "(assert (= w%d %s))\n", g.Output, expr)
+	}
+
+	// Outputs are the last wires of the circuit.
+	w = c.NumWires - c.Outputs.Size()
+	for idx, output := range c.Outputs {
+		bits := int(output.Type.Bits)
+		fmt.Fprintf(out, "(define-fun %s () (_ BitVec %d)",
+			outputs[idx], bits)
+		if bits > 1 {
+			fmt.Fprintf(out, " (concat")
+		}
+		// The concat operands are ordered from MSB to LSB.
+		for bit := bits - 1; bit >= 0; bit-- {
+			fmt.Fprintf(out, " (ite w%d #b1 #b0)", w+bit)
+		}
+		if bits > 1 {
+			fmt.Fprintf(out, ")")
+		}
+		fmt.Fprintf(out, ")\n")
+		w += bits
+	}
+
+	return nil
+}
+
+// smtNames creates SMT-LIB symbols for the I/O arguments. The
+// symbols are the argument names quoted as SMT-LIB symbols. Unnamed
+// and duplicate arguments are named by their indices with the
+// argument prefix.
+func smtNames(args IO, prefix string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, len(args))
+	for idx, arg := range args {
+		name := strings.Map(func(r rune) rune {
+			if r == '|' || r == '\\' {
+				return '_'
+			}
+			return r
+		}, arg.Name)
+		if len(name) == 0 || seen[name] {
+			name = fmt.Sprintf("%s%d", prefix, idx)
+		}
+		seen[name] = true
+		result[idx] = fmt.Sprintf("|%s|", name)
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMarshalSMTLIB(t *testing.T) {
+	circ, err := Parse("../pkg/math/add64.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := circ.MarshalFormat(&buf, "smtlib"); err != nil {
+		t.Fatalf("MarshalSMTLIB failed: %v", err)
+	}
+	script := buf.String()
+
+	var asserts, wires, inputs, outputs int
+	for _, line := range strings.Split(script, "\n") {
+		switch {
+		case strings.HasPrefix(line, "(assert "):
+			asserts++
+		case strings.HasPrefix(line, "(declare-const w"),
+			strings.HasPrefix(line, "(define-fun w"):
+			wires++
+		case strings.HasPrefix(line, "(declare-const |NI"):
+			if !strings.HasSuffix(line, " (_ BitVec 64))") {
+				t.Errorf("invalid input: %s", line)
+			}
+			inputs++
+		case strings.HasPrefix(line, "(define-fun |NO"):
+			prefix := "(define-fun |NO1| () (_ BitVec 64)"
+			if !strings.HasPrefix(line, prefix) {
+				t.Errorf("invalid output: %.40s", line)
+			}
+			outputs++
+		}
+	}
+	if asserts != circ.NumGates {
+		t.Errorf("got %d assertions, expected %d",
+			asserts, circ.NumGates)
+	}
+	if wires != circ.NumWires {
+		t.Errorf("got %d wires, expected %d", wires, circ.NumWires)
+	}
+	if inputs != 2 || outputs != 1 {
+		t.Errorf("got %d inputs and %d outputs", inputs, outputs)
+	}
+
+	// Verify the adder with a solver if it is available.
+	z3, err := exec.LookPath("z3")
+	if err != nil {
+		t.Skip("z3 not found")
+	}
+	buf.WriteString("(assert (not (= |NO1| (bvadd |NI0| |NI1|))))\n")
+	buf.WriteString("(check-sat)\n")
+
+	cmd := exec.Command(z3, "-in")
+	cmd.Stdin = &buf
+	result, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("z3 failed: %v", err)
+	}
+	if strings.TrimSpace(string(result)) != "unsat" {
+		t.Errorf("z3: got %q, expected unsat", result)
+	}
+}
+
+func TestSMTNames(t *testing.T) {
+	names := smtNames(IO{
+		{Name: "a"},
+		{Name: ""},
+		{Name: "a"},
+		{Name: "x|y"},
+	}, "in")
+	expected := []string{"|a|", "|in1|", "|in2|", "|x_y|"}
+	for idx, name := range names {
+		if name != expected[idx] {
+			t.Errorf("name %d: got %s, expected %s",
+				idx, name, expected[idx])
+		}
+	}
+}
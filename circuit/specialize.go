@@ -0,0 +1,303 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// specValue describes the value of a wire in the specialized
+// circuit. The value is either the wire of the specialized circuit or
+// a constant if wire is InvalidWire. The inv flag inverts the wire
+// value and holds the value of constants.
+type specValue struct {
+	wire Wire
+	inv  bool
+}
+
+func (v specValue) constant() bool {
+	return v.wire == InvalidWire
+}
+
+// specializer builds the gates of the specialized circuit.
+type specializer struct {
+	gates []Gate
+	next  Wire
+	invs  map[Wire]Wire
+	zero  Wire
+}
+
+func (s *specializer) emit(op Operation, i0, i1 Wire) Wire {
+	out := s.next
+	s.next++
+	s.gates = append(s.gates, Gate{
+		Input0: i0,
+		Input1: i1,
+		Output: out,
+		Op:     op,
+	})
+	return out
+}
+
+// wire returns the wire holding the value v. The function adds an INV
+// gate for the inverted values. The inverted wires are cached so each
+// wire is inverted at most once.
+func (s *specializer) wire(v specValue) Wire {
+	if !v.inv {
+		return v.wire
+	}
+	w, ok := s.invs[v.wire]
+	if !ok {
+		w = s.emit(INV, v.wire, 0)
+		s.invs[v.wire] = w
+	}
+	return w
+}
+
+// SpecializeShared creates an equivalent circuit for the shared input
+// values. The shared inputs are the evaluator's input arguments that
+// are marked with SharedInput, and the argument shared holds their
+// values in the layout of the evaluator's input, as in GarblerShared.
+// The function propagates the shared values as constants through the
+// circuit: the AND gates with a shared 0 input become constant 0, the
+// AND gates with a shared 1 input pass their other input through, and
+// the OR, XOR, XNOR, and INV gates are simplified similarly. The
+// gates that no longer contribute to the outputs are removed.
+//
+// The specialized circuit has the same inputs and outputs as the
+// circuit c and it computes the same outputs as long as the shared
+// inputs have the specialized values. The specialized circuit must
+// therefore be evaluated with GarblerShared which verifies that both
+// parties use the same shared values.
+func (c *Circuit) SpecializeShared(shared *big.Int) (*Circuit, error) {
+	bits := c.sharedInputs()
+	if bits == nil {
+		return nil, fmt.Errorf("circuit has no shared inputs")
+	}
+	if shared == nil {
+		return nil, fmt.Errorf("shared input values not set")
+	}
+	numInputs := c.Inputs.Size()
+	numOutputs := c.Outputs.Size()
+
+	values := make([]specValue, c.NumWires)
+	for w := 0; w < numInputs; w++ {
+		values[w] = specValue{
+			wire: Wire(w),
+		}
+	}
+	offset := int(c.Inputs[0].Type.Bits)
+	for i, s := range bits {
+		if s {
+			values[offset+i] = specValue{
+				wire: InvalidWire,
+				inv:  shared.Bit(i) == 1,
+			}
+		}
+	}
+
+	s := &specializer{
+		next: Wire(numInputs),
+		invs: make(map[Wire]Wire),
+		zero: InvalidWire,
+	}
+
+	for _, g := range c.Gates {
+		a := values[g.Input0]
+		var b specValue
+		if g.Op != INV {
+			b = values[g.Input1]
+		}
+		var r specValue
+
+		switch g.Op {
+		case XOR, XNOR:
+			inv := a.inv != b.inv
+			if g.Op == XNOR {
+				inv = !inv
+			}
+			switch {
+			case a.constant():
+				r = specValue{
+					wire: b.wire,
+					inv:  inv,
+				}
+			case b.constant():
+				r = specValue{
+					wire: a.wire,
+					inv:  inv,
+				}
+			case a.wire == b.wire:
+				r = specValue{
+					wire: InvalidWire,
+					inv:  inv,
+				}
+			case inv:
+				r = specValue{
+					wire: s.emit(XNOR, a.wire, b.wire),
+				}
+			default:
+				r = specValue{
+					wire: s.emit(XOR, a.wire, b.wire),
+				}
+			}
+
+		case AND, OR:
+			// The constant that determines the gate output: 0 for
+			// AND and 1 for OR.
+			absorbing := g.Op == OR
+			switch {
+			case a.constant():
+				if a.inv == absorbing {
+					r = a
+				} else {
+					r = b
+				}
+			case b.constant():
+				if b.inv == absorbing {
+					r = b
+				} else {
+					r = a
+				}
+			case a.wire == b.wire:
+				if a.inv == b.inv {
+					r = a
+				} else {
+					r = specValue{
+						wire: InvalidWire,
+						inv:  absorbing,
+					}
+				}
+			default:
+				r = specValue{
+					wire: s.emit(g.Op, s.wire(a), s.wire(b)),
+				}
+			}
+
+		case INV:
+			r = specValue{
+				wire: a.wire,
+				inv:  !a.inv,
+			}
+
+		default:
+			return nil, fmt.Errorf("invalid gate %s", g.Op)
+		}
+		values[g.Output] = r
+	}
+
+	// Assign the output wires. The gate outputs are used directly as
+	// output wires when possible and the constants and the other
+	// values are copied to the output wires with extra gates.
+	if numOutputs > 0 && numInputs == 0 {
+		return nil, fmt.Errorf("circuit has no inputs")
+	}
+	outputs := make([]Wire, numOutputs)
+	claimed := make(map[Wire]bool)
+	for i := 0; i < numOutputs; i++ {
+		v := values[c.NumWires-numOutputs+i]
+
+		var w Wire
+		if v.constant() {
+			if v.inv {
+				w = s.emit(XNOR, 0, 0)
+			} else {
+				w = s.emit(XOR, 0, 0)
+			}
+		} else {
+			w = v.wire
+			if v.inv {
+				var ok bool
+				w, ok = s.invs[v.wire]
+				if !ok {
+					w = InvalidWire
+				}
+			}
+			if w == InvalidWire || w.Int() < numInputs || claimed[w] {
+				if v.inv {
+					w = s.emit(INV, v.wire, 0)
+				} else {
+					if s.zero == InvalidWire {
+						s.zero = s.emit(XOR, 0, 0)
+					}
+					w = s.emit(XOR, v.wire, s.zero)
+				}
+			}
+		}
+		claimed[w] = true
+		outputs[i] = w
+	}
+
+	// Remove the gates that do not contribute to the outputs.
+	live := make([]bool, s.next)
+	for _, w := range outputs {
+		live[w] = true
+	}
+	for i := len(s.gates) - 1; i >= 0; i-- {
+		g := s.gates[i]
+		if !live[g.Output] {
+			continue
+		}
+		live[g.Input0] = true
+		if g.Op != INV {
+			live[g.Input1] = true
+		}
+	}
+
+	// Renumber the wires so that the inputs are first and the
+	// outputs are last.
+	var numLive int
+	for _, g := range s.gates {
+		if live[g.Output] && !claimed[g.Output] {
+			numLive++
+		}
+	}
+	numWires := numInputs + numLive + numOutputs
+
+	wireMap := make([]Wire, s.next)
+	for w := 0; w < numInputs; w++ {
+		wireMap[w] = Wire(w)
+	}
+	for i, w := range outputs {
+		wireMap[w] = Wire(numWires - numOutputs + i)
+	}
+	next := Wire(numInputs)
+	var gates []Gate
+	var stats Stats
+	for _, g := range s.gates {
+		if !live[g.Output] {
+			continue
+		}
+		if !claimed[g.Output] {
+			wireMap[g.Output] = next
+			next++
+		}
+		gate := Gate{
+			Input0: wireMap[g.Input0],
+			Output: wireMap[g.Output],
+			Op:     g.Op,
+		}
+		if g.Op != INV {
+			gate.Input1 = wireMap[g.Input1]
+		}
+		gates = append(gates, gate)
+		stats[g.Op]++
+	}
+
+	circ := &Circuit{
+		NumGates: len(gates),
+		NumWires: numWires,
+		Inputs:   c.Inputs,
+		Outputs:  c.Outputs,
+		Gates:    gates,
+		Stats:    stats,
+	}
+	circ.AssignLevels()
+
+	return circ, nil
+}
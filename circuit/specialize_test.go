@@ -0,0 +1,137 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/types"
+)
+
+// maskCircuit creates a circuit computing (a^b)&mask|^mask where the
+// 4-bit mask is the shared low half of the evaluator's input.
+func maskCircuit() *Circuit {
+	nibble := types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       4,
+	}
+	byteType := types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       8,
+	}
+	circ := &Circuit{
+		NumWires: 28,
+		Inputs: IO{
+			{Name: "a", Type: nibble},
+			{
+				Type: byteType,
+				Compound: IO{
+					{Name: "mask", Type: nibble, SharedInput: true},
+					{Name: "b", Type: nibble},
+				},
+			},
+		},
+		Outputs: IO{
+			{Name: "r", Type: nibble},
+		},
+	}
+	// Wires: a=0-3, mask=4-7, b=8-11, a^b=12-15, &mask=16-19,
+	// ^mask=20-23, r=24-27.
+	for i := 0; i < 4; i++ {
+		circ.Gates = append(circ.Gates,
+			Gate{Input0: Wire(i), Input1: Wire(8 + i), Output: Wire(12 + i),
+				Op: XOR},
+			Gate{Input0: Wire(12 + i), Input1: Wire(4 + i),
+				Output: Wire(16 + i), Op: AND},
+			Gate{Input0: Wire(4 + i), Output: Wire(20 + i), Op: INV},
+			Gate{Input0: Wire(16 + i), Input1: Wire(20 + i),
+				Output: Wire(24 + i), Op: OR},
+		)
+	}
+	circ.NumGates = len(circ.Gates)
+	for _, g := range circ.Gates {
+		circ.Stats[g.Op]++
+	}
+	circ.AssignLevels()
+	return circ
+}
+
+func TestSpecializeShared(t *testing.T) {
+	circ := maskCircuit()
+
+	for mask := int64(0); mask < 16; mask++ {
+		spec, err := circ.SpecializeShared(big.NewInt(mask))
+		if err != nil {
+			t.Fatalf("SpecializeShared(%x) failed: %v", mask, err)
+		}
+		if spec.Stats[AND] != 0 || spec.Stats[OR] != 0 {
+			t.Errorf("mask %x: AND and OR gates not collapsed: %v",
+				mask, spec.Stats)
+		}
+		if err := spec.ValidateOutputs(); err != nil {
+			t.Errorf("mask %x: %v", mask, err)
+		}
+		for a := int64(0); a < 16; a++ {
+			for b := int64(0); b < 16; b++ {
+				inputs := []*big.Int{
+					big.NewInt(a), big.NewInt(mask), big.NewInt(b),
+				}
+				expected, err := circ.Compute(inputs)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result, err := spec.Compute(inputs)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result[0].Cmp(expected[0]) != 0 {
+					t.Fatalf("mask %x: %x^%x: got %x, expected %x",
+						mask, a, b, result[0], expected[0])
+				}
+			}
+		}
+	}
+}
+
+func TestSpecializeSharedGarbled(t *testing.T) {
+	mask := big.NewInt(0x5)
+	spec, err := maskCircuit().SpecializeShared(mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := big.NewInt(0x9)
+	b := big.NewInt(0xc)
+	data := new(big.Int).Or(mask, new(big.Int).Lsh(b, 4))
+
+	gValues, eValues, gErr, eErr := runShared(spec, ot.NewCO(), a, mask,
+		data)
+	if gErr != nil {
+		t.Fatalf("garbler failed: %v", gErr)
+	}
+	if eErr != nil {
+		t.Fatalf("evaluator failed: %v", eErr)
+	}
+	// (0x9^0xc)&0x5 | ^0x5 = 0x5&0x5 | 0xa = 0xf
+	for _, v := range []*big.Int{gValues[0], eValues[0]} {
+		if v.Int64() != 0xf {
+			t.Errorf("got %x, expected f", v)
+		}
+	}
+}
+
+func TestSpecializeSharedErrors(t *testing.T) {
+	if _, err := outputsCircuit().SpecializeShared(big.NewInt(0)); err == nil {
+		t.Errorf("SpecializeShared succeeded without shared inputs")
+	}
+	if _, err := maskCircuit().SpecializeShared(nil); err == nil {
+		t.Errorf("SpecializeShared succeeded without shared values")
+	}
+}
@@ -9,7 +9,9 @@ package circuit
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"time"
 
@@ -23,13 +25,41 @@ const (
 	OpResult = iota
 	OpCircuit
 	OpReturn
+	OpAck
+	OpAbort
+	OpGC
 )
 
-// StreamEval is a streaming garbled circuit evaluator.
+// ErrStreamAborted is returned when the garbler aborts the streaming
+// computation.
+var ErrStreamAborted = errors.New("stream aborted by peer")
+
+const (
+	streamPageBits = 12
+	streamPageSize = 1 << streamPageBits
+	streamPageMask = streamPageSize - 1
+)
+
+// streamPage holds the wire labels of a page of wires. The live bits
+// track the wires that have labels.
+type streamPage struct {
+	labels [streamPageSize]ot.Label
+	live   [streamPageSize / 64]uint64
+	count  int
+}
+
+// StreamEval is a streaming garbled circuit evaluator. The evaluator
+// stores the wire labels in pages that it allocates when the wires
+// are set. The garbler sends OpGC when its wire allocator collects
+// dead wires and the evaluator frees their labels and releases the
+// pages that do not have live labels. This bounds the evaluator's
+// wire labels by the garbler's peak number of live wires.
 type StreamEval struct {
 	key   []byte
 	alg   cipher.Block
-	wires []ot.Label
+	pages []*streamPage
+	spare *streamPage
+	live  int
 	tmp   []ot.Label
 }
 
@@ -39,44 +69,92 @@ func NewStreamEval(key []byte, numInputs, numOutputs int) (*StreamEval, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &StreamEval{
-		key:   key,
-		alg:   alg,
-		wires: make([]ot.Label, numInputs+numOutputs),
-	}, nil
+	stream := &StreamEval{
+		key: key,
+		alg: alg,
+	}
+	stream.InitCircuit(numInputs+numOutputs, 0)
+	return stream, nil
 }
 
-// Get gets the value of the wire.
+// Get gets the value of the wire. The function returns an empty
+// label for wires that do not have labels.
 func (stream *StreamEval) Get(tmp bool, w int) ot.Label {
 	if tmp {
 		return stream.tmp[w]
 	}
-	return stream.wires[w]
-}
-
-// GetInputs gets the specified input wire range.
-func (stream *StreamEval) GetInputs(offset, count int) []ot.Label {
-	return stream.wires[offset : offset+count]
+	page := stream.pages[w>>streamPageBits]
+	if page == nil {
+		return ot.Label{}
+	}
+	return page.labels[w&streamPageMask]
 }
 
 // Set sets the value of the wire.
 func (stream *StreamEval) Set(tmp bool, w int, label ot.Label) {
 	if tmp {
 		stream.tmp[w] = label
-	} else {
-		stream.wires[w] = label
+		return
+	}
+	page := stream.pages[w>>streamPageBits]
+	if page == nil {
+		page = stream.spare
+		if page == nil {
+			page = new(streamPage)
+		}
+		stream.spare = nil
+		stream.pages[w>>streamPageBits] = page
+	}
+	i := w & streamPageMask
+	bit := uint64(1) << (i & 63)
+	if page.live[i>>6]&bit == 0 {
+		page.live[i>>6] |= bit
+		page.count++
+		stream.live++
 	}
+	page.labels[i] = label
+}
+
+// Free frees the labels of the wires [w, w+count). The pages that do
+// not have live labels after the operation are released.
+func (stream *StreamEval) Free(w, count int) {
+	if max := len(stream.pages) << streamPageBits; w+count > max {
+		count = max - w
+	}
+	for ; count > 0; w, count = w+1, count-1 {
+		page := stream.pages[w>>streamPageBits]
+		if page == nil {
+			continue
+		}
+		i := w & streamPageMask
+		bit := uint64(1) << (i & 63)
+		if page.live[i>>6]&bit == 0 {
+			continue
+		}
+		page.live[i>>6] &^= bit
+		page.labels[i] = ot.Label{}
+		page.count--
+		stream.live--
+		if page.count == 0 {
+			stream.pages[w>>streamPageBits] = nil
+			if stream.spare == nil {
+				stream.spare = page
+			}
+		}
+	}
+}
+
+// Live returns the number of wires that have labels.
+func (stream *StreamEval) Live() int {
+	return stream.live
 }
 
 // InitCircuit initializes the stream evaluator with wires.
 func (stream *StreamEval) InitCircuit(numWires, numTmpWires int) {
-	if numWires > len(stream.wires) {
-		var size int
-		for size = 1024; size < numWires; size *= 2 {
-		}
-		n := make([]ot.Label, size)
-		copy(n, stream.wires)
-		stream.wires = n
+	numPages := (numWires + streamPageMask) >> streamPageBits
+	if numPages > len(stream.pages) {
+		stream.pages = append(stream.pages,
+			make([]*streamPage, numPages-len(stream.pages))...)
 	}
 	if numTmpWires > len(stream.tmp) {
 		var size int
@@ -89,6 +167,14 @@ func (stream *StreamEval) InitCircuit(numWires, numTmpWires int) {
 // StreamEvaluator runs the stream evaluator on the connection.
 func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	verbose bool) (IO, []*big.Int, error) {
+	return StreamEvaluatorLimits(conn, oti, inputFlag, Limits{}, verbose)
+}
+
+// StreamEvaluatorLimits runs the stream evaluator on the connection
+// and rejects the garbler's declarations that exceed the limits. The
+// limit errors wrap ErrLimitExceeded.
+func StreamEvaluatorLimits(conn *p2p.Conn, oti ot.OT, inputFlag []string,
+	limits Limits, verbose bool) (IO, []*big.Int, error) {
 
 	timing := NewTiming()
 
@@ -104,6 +190,14 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	if err != nil {
 		return nil, nil, err
 	}
+	window, err := conn.ReceiveUint32()
+	if err != nil {
+		return nil, nil, err
+	}
+	flow, err := NewStreamFlow(window)
+	if err != nil {
+		return nil, nil, err
+	}
 	// Peer input.
 	in1, err := receiveArgument(conn)
 	if err != nil {
@@ -114,6 +208,9 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := limits.CheckOTs(int(in2.Type.Bits)); err != nil {
+		return nil, nil, err
+	}
 	inputs, err := in2.Parse(inputFlag)
 	if err != nil {
 		return nil, nil, err
@@ -129,6 +226,14 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 		if err != nil {
 			return nil, nil, err
 		}
+		mode, err := conn.ReceiveByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Mode = OutputMode(mode)
+		if out.Mode != OutputReveal && out.Mode != OutputShare {
+			return nil, nil, fmt.Errorf("invalid output mode %s", out.Mode)
+		}
 		outputs = append(outputs, out)
 	}
 
@@ -136,6 +241,11 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	if err != nil {
 		return nil, nil, err
 	}
+	err = limits.CheckWires(int(in1.Type.Bits+in2.Type.Bits) +
+		outputs.Size())
+	if err != nil {
+		return nil, nil, err
+	}
 
 	fmt.Printf(" - In1: %s\n", in1)
 	fmt.Printf(" + In2: %s\n", in2)
@@ -149,15 +259,15 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	}
 
 	// Receive peer inputs.
-	var label ot.Label
-	var labelData ot.LabelData
-	for w := 0; w < int(in1.Type.Bits); w++ {
-		err := conn.ReceiveLabel(&label, &labelData)
-		if err != nil {
-			return nil, nil, err
-		}
+	peerInputs := make([]ot.Label, in1.Type.Bits)
+	if err := conn.ReceiveLabels(peerInputs); err != nil {
+		return nil, nil, err
+	}
+	for w, label := range peerInputs {
 		streaming.Set(false, w, label)
 	}
+	var label ot.Label
+	var labelData ot.LabelData
 
 	// Init oblivious transfer.
 	err = oti.InitReceiver(conn)
@@ -177,10 +287,13 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 			flags[i] = true
 		}
 	}
-	inputLabels := streaming.GetInputs(int(in1.Type.Bits), int(in2.Type.Bits))
+	inputLabels := make([]ot.Label, in2.Type.Bits)
 	if err := oti.Receive(flags, inputLabels); err != nil {
 		return nil, nil, err
 	}
+	for i, label := range inputLabels {
+		streaming.Set(false, int(in1.Type.Bits)+i, label)
+	}
 	xfer := conn.Stats.Sum() - ioStats
 	ioStats = conn.Stats.Sum()
 	timing.Sample("Inputs", []string{FileSize(xfer).String()})
@@ -200,6 +313,8 @@ func StreamEvaluator(conn *p2p.Conn, oti ot.OT, inputFlag []string,
 	var lastStep int
 
 	var rawResult *big.Int
+	var numGatesTotal int
+	var domain uint64
 
 	start := time.Now()
 	lastReport := start
@@ -246,8 +361,21 @@ loop:
 					}
 				}
 			}
+			numGatesTotal += numGates
+			if err := limits.CheckGates(numGatesTotal); err != nil {
+				return nil, nil, err
+			}
+			if err := limits.CheckWires(numWires + numTmpWires); err != nil {
+				return nil, nil, err
+			}
 			streaming.InitCircuit(numWires, numTmpWires)
-			var id uint32
+			// The circuits are garbled in domains that follow their
+			// streaming order.
+			id, err := domainTweak(domain, numGates)
+			if err != nil {
+				return nil, nil, err
+			}
+			domain++
 			for i := 0; i < numGates; i++ {
 				gop, err := conn.ReceiveByte()
 				if err != nil {
@@ -263,46 +391,15 @@ loop:
 				if gop&0b00100000 != 0 {
 					cTmp = true
 				}
-				var recvWire func() (int, error)
-				if gop&0b00010000 != 0 {
-					recvWire = conn.ReceiveUint16
-				} else {
-					recvWire = conn.ReceiveUint32
+				aIndex, bIndex, cIndex, err := receiveGateWires(conn,
+					gop)
+				if err != nil {
+					return nil, nil, err
 				}
-
 				gop &^= 0b11110000
 
-				var aIndex, bIndex, cIndex int
 				var tableCount int
 
-				switch Operation(gop) {
-				case XOR, XNOR, AND, OR:
-					aIndex, err = recvWire()
-					if err != nil {
-						return nil, nil, err
-					}
-					bIndex, err = recvWire()
-					if err != nil {
-						return nil, nil, err
-					}
-					cIndex, err = recvWire()
-					if err != nil {
-						return nil, nil, err
-					}
-
-				case INV:
-					aIndex, err = recvWire()
-					if err != nil {
-						return nil, nil, err
-					}
-					cIndex, err = recvWire()
-					if err != nil {
-						return nil, nil, err
-					}
-				default:
-					return nil, nil, fmt.Errorf("invalid operation %s",
-						Operation(gop))
-				}
 				switch Operation(gop) {
 				case XOR, XNOR:
 					tableCount = 0
@@ -410,20 +507,44 @@ loop:
 				}
 				streaming.Set(cTmp, cIndex, output)
 			}
+			if err := flow.Evaluated(conn, numGates); err != nil {
+				return nil, nil, err
+			}
+
+		case OpGC:
+			w, err := conn.ReceiveUint32()
+			if err != nil {
+				return nil, nil, err
+			}
+			count, err := conn.ReceiveUint32()
+			if err != nil {
+				return nil, nil, err
+			}
+			streaming.Free(w, count)
 
 		case OpReturn:
 			xfer := conn.Stats.Sum() - ioStats
 			ioStats = conn.Stats.Sum()
 			timing.Sample("Eval", []string{FileSize(xfer).String()})
 
+			// Our shares of the shared outputs are the permutation
+			// bits of their labels. The labels of the revealed
+			// outputs are returned to the garbler for resolving.
 			var labels []ot.Label
-			for i := 0; i < outputs.Size(); i++ {
+			shares := new(big.Int)
+			for i, shared := range outputs.SharedBits() {
 				id, err := conn.ReceiveUint32()
 				if err != nil {
 					return nil, nil, err
 				}
 				label := streaming.Get(false, id)
-				labels = append(labels, label)
+				if shared {
+					if label.S() {
+						shares.SetBit(shares, i, 1)
+					}
+				} else {
+					labels = append(labels, label)
+				}
 			}
 
 			// Resolve result values.
@@ -440,13 +561,29 @@ loop:
 				return nil, nil, err
 			}
 
+			// The garbler aborts if it can't resolve our labels.
+			op, err := conn.ReceiveUint32()
+			if err != nil {
+				return nil, nil, err
+			}
+			switch op {
+			case OpResult:
+			case OpAbort:
+				return nil, nil, ErrStreamAborted
+			default:
+				return nil, nil, fmt.Errorf("unexpected operation %d", op)
+			}
 			result, err := conn.ReceiveData()
 			if err != nil {
 				return nil, nil, err
 			}
 			rawResult = new(big.Int).SetBytes(result)
+			rawResult.Or(rawResult, shares)
 			break loop
 
+		case OpAbort:
+			return nil, nil, ErrStreamAborted
+
 		default:
 			return nil, nil, fmt.Errorf("unknown operation %d", op)
 		}
@@ -475,6 +612,9 @@ func receiveArgument(conn *p2p.Conn) (arg IOArg, err error) {
 	if err != nil {
 		return arg, err
 	}
+	if size > math.MaxInt32 {
+		return arg, fmt.Errorf("invalid size %d for argument %s", size, name)
+	}
 	arg.Name = name
 	arg.Type, err = types.Parse(t)
 	if err != nil {
@@ -0,0 +1,77 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+)
+
+func streamEvalPages(stream *StreamEval) int {
+	var count int
+	for _, page := range stream.pages {
+		if page != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func TestStreamEvalFree(t *testing.T) {
+	var key [16]byte
+	stream, err := NewStreamEval(key[:], 8, 8)
+	if err != nil {
+		t.Fatalf("NewStreamEval failed: %v", err)
+	}
+
+	// Stream values to ever-increasing wire IDs and free each value
+	// after the next one has been computed.
+	const valueBits = 1000
+	const rounds = 1000
+
+	var label ot.Label
+	for round := 0; round < rounds; round++ {
+		base := round * valueBits
+		stream.InitCircuit(base+valueBits, 0)
+		for w := base; w < base+valueBits; w++ {
+			label.D0 = uint64(w)
+			stream.Set(false, w, label)
+		}
+		if round > 0 {
+			prev := base - valueBits
+			if got := stream.Get(false, prev); got.D0 != uint64(prev) {
+				t.Fatalf("wire %d: got %v, expected %v", prev, got.D0, prev)
+			}
+			stream.Free(prev, valueBits)
+			if got := stream.Get(false, prev); got != (ot.Label{}) {
+				t.Fatalf("wire %d not freed: %v", prev, got)
+			}
+		}
+		if stream.Live() != valueBits {
+			t.Fatalf("round %d: %d live labels, expected %d",
+				round, stream.Live(), valueBits)
+		}
+		if pages := streamEvalPages(stream); pages > 2 {
+			t.Fatalf("round %d: %d pages for %d live labels",
+				round, pages, stream.Live())
+		}
+	}
+
+	// Freeing dead and out-of-range wires is a no-op.
+	last := (rounds - 1) * valueBits
+	stream.Free(0, last)
+	stream.Free(last+valueBits, 1<<30)
+	if stream.Live() != valueBits {
+		t.Errorf("%d live labels, expected %d", stream.Live(), valueBits)
+	}
+	stream.Free(last, valueBits)
+	if stream.Live() != 0 || streamEvalPages(stream) != 0 {
+		t.Errorf("%d live labels in %d pages, expected none",
+			stream.Live(), streamEvalPages(stream))
+	}
+}
@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// DefaultStreamWindow specifies the default size of the streaming
+// flow-control window in gates.
+const DefaultStreamWindow = 1 << 20
+
+// StreamFlow implements the flow control of the streaming
+// protocol. The evaluator acknowledges each window of evaluated gates
+// with OpAck and the garbler waits for the acknowledgements when it
+// is more than one window ahead of the evaluator. This bounds the
+// amount of garbled tables in transit between the peers, regardless
+// of the buffering of the underlying connection.
+type StreamFlow struct {
+	window  int
+	gates   int
+	pending int
+}
+
+// NewStreamFlow creates a new flow control for the window size in
+// gates.
+func NewStreamFlow(window int) (*StreamFlow, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid stream window: %d", window)
+	}
+	return &StreamFlow{
+		window: window,
+	}, nil
+}
+
+// Window returns the flow-control window size in gates.
+func (flow *StreamFlow) Window() int {
+	return flow.window
+}
+
+// Sent records that the garbler has sent numGates garbled gates. If
+// the garbler is more than one window ahead of the evaluator, the
+// function flushes the connection and waits for the evaluator's
+// acknowledgements.
+func (flow *StreamFlow) Sent(conn *p2p.Conn, numGates int) error {
+	flow.gates += numGates
+	for flow.gates >= flow.window {
+		flow.gates -= flow.window
+		flow.pending++
+	}
+	if flow.pending <= 1 {
+		return nil
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for flow.pending > 1 {
+		if err := flow.receiveAck(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drain receives all pending acknowledgements from the evaluator.
+func (flow *StreamFlow) Drain(conn *p2p.Conn) error {
+	for flow.pending > 0 {
+		if err := flow.receiveAck(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (flow *StreamFlow) receiveAck(conn *p2p.Conn) error {
+	op, err := conn.ReceiveUint32()
+	if err != nil {
+		return err
+	}
+	if op != OpAck {
+		return fmt.Errorf("unexpected operation: %d", op)
+	}
+	flow.pending--
+	return nil
+}
+
+// Evaluated records that the evaluator has evaluated numGates
+// garbled gates and acknowledges all completed windows.
+func (flow *StreamFlow) Evaluated(conn *p2p.Conn, numGates int) error {
+	flow.gates += numGates
+	if flow.gates < flow.window {
+		return nil
+	}
+	for flow.gates >= flow.window {
+		flow.gates -= flow.window
+		if err := conn.SendUint32(OpAck); err != nil {
+			return err
+		}
+	}
+	return conn.Flush()
+}
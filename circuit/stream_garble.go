@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2021, 2023 Markku Rossi
+// Copyright (c) 2020-2021, 2023-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -35,6 +35,7 @@ type Streaming struct {
 	out      []Wire
 	firstTmp Wire
 	firstOut Wire
+	domain   uint64
 }
 
 // NewStreaming creates a new streaming garbled circuit garbler.
@@ -63,7 +64,7 @@ func NewStreaming(key []byte, inputs []Wire, conn *p2p.Conn) (
 
 	// Assing all input wires.
 	for i := 0; i < len(inputs); i++ {
-		w, err := makeLabels(stream.r)
+		w, err := makeLabels(rand.Reader, stream.r)
 		if err != nil {
 			return nil, err
 		}
@@ -118,6 +119,18 @@ func (stream *Streaming) GetInputs(offset, count int) []ot.Wire {
 	return stream.wires[offset : offset+count]
 }
 
+// Zeroize clears the labels of the argument wires. The wires must
+// not be used after zeroization until they are assigned new values
+// so the caller must not zeroize wires that live values still use,
+// including the constant zero and one wires.
+func (stream *Streaming) Zeroize(wires []Wire) {
+	for _, w := range wires {
+		if int(w) < len(stream.wires) {
+			stream.wires[w] = ot.Wire{}
+		}
+	}
+}
+
 // Get gets the value of the wire.
 func (stream *Streaming) Get(w Wire) (ot.Wire, Wire, bool) {
 	if w < stream.firstTmp {
@@ -148,7 +161,9 @@ func (stream *Streaming) Set(w Wire, val ot.Wire) (index Wire, tmp bool) {
 }
 
 // Garble garbles the circuit and streams the garbled tables into the
-// stream.
+// stream. Each circuit is garbled in its own domain, numbered in the
+// garbling order, so the gate hashes of the circuits are independent
+// even though all circuits share the stream's key.
 func (stream *Streaming) Garble(c *Circuit, in, out []Wire) (
 	time.Duration, time.Duration, error) {
 	if StreamDebug {
@@ -162,9 +177,14 @@ func (stream *Streaming) Garble(c *Circuit, in, out []Wire) (
 	// Garble gates.
 
 	var data ot.LabelData
-	var id uint32
 	var table [4]ot.Label
 
+	id, err := domainTweak(stream.domain, len(c.Gates))
+	if err != nil {
+		return 0, 0, err
+	}
+	stream.domain++
+
 	mid := time.Now()
 
 	for i := 0; i < len(c.Gates); i++ {
@@ -183,7 +203,7 @@ func (stream *Streaming) Garble(c *Circuit, in, out []Wire) (
 }
 
 // GarbleGate garbles the gate and streams it to the stream.
-func (stream *Streaming) garbleGate(g *Gate, idp *uint32,
+func (stream *Streaming) garbleGate(g *Gate, idp *uint64,
 	table []ot.Label, data *ot.LabelData, buf []byte, bufpos *int) error {
 
 	var a, b, c ot.Wire
@@ -389,51 +409,17 @@ func (stream *Streaming) garbleGate(g *Gate, idp *uint32,
 	if cTmp {
 		op |= 0b00100000
 	}
-	if aIndex <= 0xffff && bIndex <= 0xffff && cIndex <= 0xffff {
-		op |= 0b00010000
-		buf[*bufpos] = op
-		*bufpos = *bufpos + 1
-
-		switch wireCount {
-		case 3:
-			bo.PutUint16(buf[*bufpos+0:], uint16(aIndex))
-			bo.PutUint16(buf[*bufpos+2:], uint16(bIndex))
-			bo.PutUint16(buf[*bufpos+4:], uint16(cIndex))
-			*bufpos = *bufpos + 6
-
-		case 2:
-			bo.PutUint16(buf[*bufpos+0:], uint16(aIndex))
-			bo.PutUint16(buf[*bufpos+2:], uint16(cIndex))
-			*bufpos = *bufpos + 4
-
-		default:
-			panic(fmt.Sprintf("invalid wire count: %d", wireCount))
-		}
-	} else {
-		buf[*bufpos] = op
-		*bufpos = *bufpos + 1
-
-		switch wireCount {
-		case 3:
-			bo.PutUint32(buf[*bufpos+0:], uint32(aIndex))
-			bo.PutUint32(buf[*bufpos+4:], uint32(bIndex))
-			bo.PutUint32(buf[*bufpos+8:], uint32(cIndex))
-			*bufpos = *bufpos + 12
-
-		case 2:
-			bo.PutUint32(buf[*bufpos+0:], uint32(aIndex))
-			bo.PutUint32(buf[*bufpos+4:], uint32(cIndex))
-			*bufpos = *bufpos + 8
-
-		default:
-			panic(fmt.Sprintf("invalid wire count: %d", wireCount))
-		}
+	err := putGateWires(buf, bufpos, op, wireCount,
+		uint64(aIndex), uint64(bIndex), uint64(cIndex))
+	if err != nil {
+		return err
 	}
 
+	enc := stream.conn.LabelEncoding()
 	for i := 0; i < tableCount; i++ {
-		bytes := table[tableStart+i].Bytes(data)
-		copy(buf[*bufpos:], bytes)
-		*bufpos = *bufpos + len(bytes)
+		enc.Encode(table[tableStart+i], data)
+		copy(buf[*bufpos:], data[:])
+		*bufpos = *bufpos + len(data)
 	}
 
 	return nil
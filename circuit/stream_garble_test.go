@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -13,6 +13,36 @@ import (
 	"github.com/markkurossi/mpc/ot"
 )
 
+func TestStreamingZeroize(t *testing.T) {
+	var key [16]byte
+	inputs := []Wire{0, 1, 2, 3}
+
+	stream, err := NewStreaming(key[:], inputs, nil)
+	if err != nil {
+		t.Fatalf("failed to init streaming: %s", err)
+	}
+	var labels []ot.Wire
+	for _, w := range inputs {
+		labels = append(labels, stream.GetInput(w))
+	}
+
+	// The out-of-range wires are ignored.
+	stream.Zeroize([]Wire{1, 3, Wire(len(stream.wires))})
+
+	var zero ot.Wire
+	for i, w := range inputs {
+		got := stream.GetInput(w)
+		if w == 1 || w == 3 {
+			if got != zero {
+				t.Errorf("wire %d not zeroized: %v", w, got)
+			}
+		} else if got != labels[i] || got == zero {
+			t.Errorf("wire %d modified: got %v, expected %v",
+				w, got, labels[i])
+		}
+	}
+}
+
 func BenchmarkGarbleXOR(b *testing.B) {
 	benchmarkGate(b, newGate(XOR))
 }
@@ -57,7 +87,7 @@ func benchmarkGate(b *testing.B, g *Gate) {
 	stream.firstTmp = 2
 	stream.firstOut = 2
 
-	var id uint32
+	var id uint64
 	var data ot.LabelData
 	var table [4]ot.Label
 
@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// gateWires16 is the gate opcode flag for 16-bit wire IDs. Gates that
+// have all their wire IDs below 2^16 use the short encoding, and the
+// other gates encode their wire IDs as 32-bit values.
+const gateWires16 = 0b00010000
+
+// checkWireID tests if the wire ID fits into the 32-bit wire IDs of
+// the streaming protocol.
+func checkWireID(id uint64) error {
+	if id > math.MaxUint32 {
+		return fmt.Errorf("wire ID %d exceeds 32 bits", id)
+	}
+	return nil
+}
+
+// SendWire sends the wire ID to the connection. The function returns
+// an error if the wire ID does not fit into 32 bits.
+func SendWire(conn *p2p.Conn, id uint64) error {
+	if err := checkWireID(id); err != nil {
+		return err
+	}
+	return conn.SendUint32(int(id))
+}
+
+// putGateWires encodes the gate opcode op and its wire IDs into buf
+// at the position bufpos. The INV gates have only the wires a and c.
+func putGateWires(buf []byte, bufpos *int, op byte, wireCount int,
+	a, b, c uint64) error {
+
+	var wires []uint64
+	switch wireCount {
+	case 3:
+		wires = []uint64{a, b, c}
+	case 2:
+		wires = []uint64{a, c}
+	default:
+		panic(fmt.Sprintf("invalid wire count: %d", wireCount))
+	}
+
+	if a <= 0xffff && b <= 0xffff && c <= 0xffff {
+		buf[*bufpos] = op | gateWires16
+		*bufpos = *bufpos + 1
+		for _, wire := range wires {
+			bo.PutUint16(buf[*bufpos:], uint16(wire))
+			*bufpos = *bufpos + 2
+		}
+		return nil
+	}
+
+	buf[*bufpos] = op
+	*bufpos = *bufpos + 1
+	for _, wire := range wires {
+		if err := checkWireID(wire); err != nil {
+			return err
+		}
+		bo.PutUint32(buf[*bufpos:], uint32(wire))
+		*bufpos = *bufpos + 4
+	}
+	return nil
+}
+
+// receiveGateWires receives the gate's wire IDs that follow the gate
+// opcode gop. The b wire is 0 for the INV gates.
+func receiveGateWires(conn *p2p.Conn, gop byte) (a, b, c int, err error) {
+	recvWire := conn.ReceiveUint32
+	if gop&gateWires16 != 0 {
+		recvWire = conn.ReceiveUint16
+	}
+
+	switch Operation(gop &^ 0b11110000) {
+	case XOR, XNOR, AND, OR:
+		if a, err = recvWire(); err != nil {
+			return
+		}
+		if b, err = recvWire(); err != nil {
+			return
+		}
+		c, err = recvWire()
+
+	case INV:
+		if a, err = recvWire(); err != nil {
+			return
+		}
+		c, err = recvWire()
+
+	default:
+		err = fmt.Errorf("invalid operation %s", Operation(gop&^0b11110000))
+	}
+	return
+}
@@ -0,0 +1,106 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math"
+	"testing"
+
+	"github.com/markkurossi/mpc/p2p"
+)
+
+type wireTestGate struct {
+	op      Operation
+	a, b, c uint64
+}
+
+var wireTestGates = []wireTestGate{
+	{op: XOR, a: 1, b: 2, c: 3},
+	{op: AND, a: 0xffff, b: 0x10000, c: 7},
+	{op: INV, a: 0x12345678, c: math.MaxUint32},
+	{op: OR, a: math.MaxUint32, b: 1 << 20, c: 0xffff},
+	{op: INV, a: 1 << 31, c: 5},
+}
+
+func TestWireRoundTrip(t *testing.T) {
+	gConn, eConn := p2p.Pipe()
+	ids := []uint64{0, 0xffff, 0x10000, math.MaxUint32}
+
+	errs := make(chan error, 1)
+	go func() {
+		for _, id := range ids {
+			if err := SendWire(gConn, id); err != nil {
+				errs <- err
+				return
+			}
+		}
+		for _, g := range wireTestGates {
+			wireCount := 3
+			if g.op == INV {
+				wireCount = 2
+			}
+			if err := gConn.NeedSpace(64); err != nil {
+				errs <- err
+				return
+			}
+			err := putGateWires(gConn.WriteBuf, &gConn.WritePos,
+				byte(g.op), wireCount, g.a, g.b, g.c)
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- gConn.Flush()
+	}()
+
+	for _, id := range ids {
+		got, err := eConn.ReceiveUint32()
+		if err != nil {
+			t.Fatalf("ReceiveUint32 failed: %s", err)
+		}
+		if uint64(got) != id {
+			t.Errorf("got wire ID %d, expected %d", got, id)
+		}
+	}
+	for idx, g := range wireTestGates {
+		gop, err := eConn.ReceiveByte()
+		if err != nil {
+			t.Fatalf("ReceiveByte failed: %s", err)
+		}
+		if Operation(gop&^0b11110000) != g.op {
+			t.Errorf("gate %d: got %s, expected %s",
+				idx, Operation(gop&^0b11110000), g.op)
+		}
+		a, b, c, err := receiveGateWires(eConn, gop)
+		if err != nil {
+			t.Fatalf("gate %d: receiveGateWires failed: %s", idx, err)
+		}
+		if uint64(a) != g.a || uint64(b) != g.b || uint64(c) != g.c {
+			t.Errorf("gate %d: got wires %d,%d,%d, expected %d,%d,%d",
+				idx, a, b, c, g.a, g.b, g.c)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("send failed: %s", err)
+	}
+}
+
+func TestWireOverflow(t *testing.T) {
+	var buf [64]byte
+	var bufpos int
+
+	err := putGateWires(buf[:], &bufpos, byte(XOR), 3,
+		1, 2, math.MaxUint32+1)
+	if err == nil {
+		t.Errorf("encoding of 33-bit wire ID succeeded")
+	}
+
+	gConn, _ := p2p.Pipe()
+	if err := SendWire(gConn, math.MaxUint32+1); err == nil {
+		t.Errorf("SendWire of 33-bit wire ID succeeded")
+	}
+}
@@ -255,7 +255,8 @@ func renderRow(out io.Writer, width int, y float64, tiles []*tile,
 	for _, t := range tiles {
 		ctx.tileAvgInputX(t)
 	}
-	sort.Slice(tiles, func(i, j int) bool {
+	// Stable sort keeps tiles with equal averages in gate order.
+	sort.SliceStable(tiles, func(i, j int) bool {
 		return tiles[i].avg < tiles[j].avg
 	})
 
@@ -0,0 +1,105 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// TraceEntry records the cleartext input and output values of an
+// evaluated gate.
+type TraceEntry struct {
+	Gate   Gate
+	Input0 byte
+	Input1 byte
+	Output byte
+}
+
+func (e TraceEntry) String() string {
+	switch e.Gate.Op {
+	case INV:
+		return fmt.Sprintf("%s %v=%d -> %v=%d",
+			e.Gate.Op, e.Gate.Input0, e.Input0, e.Gate.Output, e.Output)
+	default:
+		return fmt.Sprintf("%s %v=%d %v=%d -> %v=%d",
+			e.Gate.Op, e.Gate.Input0, e.Input0, e.Gate.Input1, e.Input1,
+			e.Gate.Output, e.Output)
+	}
+}
+
+// Trace records the gate values of a cleartext circuit evaluation.
+// The trace has one entry per gate in the circuit's gate order.
+type Trace []TraceEntry
+
+// TraceEval evaluates the circuit with the given input values and
+// records the input and output values of every gate. The inputs are
+// given as in Circuit.Compute. The trace reveals all intermediate
+// values of the computation and it must be used only for debugging
+// with known inputs.
+func TraceEval(circ *Circuit, inputs []*big.Int) (Trace, []*big.Int, error) {
+	trace := make(Trace, 0, len(circ.Gates))
+	result, err := circ.compute(inputs, &trace)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trace, result, nil
+}
+
+// Dump prints the trace entries which read or write the wire w.
+func (t Trace) Dump(out io.Writer, w Wire) {
+	for idx, e := range t {
+		if e.Gate.Output != w && e.Gate.Input0 != w &&
+			(e.Gate.Op == INV || e.Gate.Input1 != w) {
+			continue
+		}
+		fmt.Fprintf(out, "g%d:\t%s\n", idx, e)
+	}
+}
+
+// LookupWires returns the wires of the named input or output
+// argument. The name can also be a wire ID in the numeric or in the
+// wN format.
+func (c *Circuit) LookupWires(name string) ([]Wire, error) {
+	if wires := lookupWires(c.Inputs, name, 0); wires != nil {
+		return wires, nil
+	}
+	if wires := lookupWires(c.Outputs, name,
+		c.NumWires-c.Outputs.Size()); wires != nil {
+		return wires, nil
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(name, "w"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unknown wire %s", name)
+	}
+	if id >= uint64(c.NumWires) {
+		return nil, fmt.Errorf("invalid wire %d: circuit has %d wires",
+			id, c.NumWires)
+	}
+	return []Wire{Wire(id)}, nil
+}
+
+func lookupWires(args IO, name string, offset int) []Wire {
+	for _, arg := range args {
+		if len(arg.Compound) > 0 {
+			if wires := lookupWires(arg.Compound, name, offset); wires != nil {
+				return wires
+			}
+		} else if arg.Name == name {
+			var wires []Wire
+			for i := 0; i < int(arg.Type.Bits); i++ {
+				wires = append(wires, Wire(offset+i))
+			}
+			return wires
+		}
+		offset += int(arg.Type.Bits)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuit
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestTraceEval(t *testing.T) {
+	circ, err := Parse("../pkg/math/add64.circ")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	a := big.NewInt(0x7fffffff12345678)
+	b := big.NewInt(0x0123456789abcdef)
+
+	trace, result, err := TraceEval(circ, []*big.Int{a, b})
+	if err != nil {
+		t.Fatalf("TraceEval failed: %s", err)
+	}
+	expected := new(big.Int).Add(a, b)
+	if len(result) != 1 || result[0].Cmp(expected) != 0 {
+		t.Errorf("TraceEval: got %v, expected %v", result, expected)
+	}
+	if len(trace) != len(circ.Gates) {
+		t.Fatalf("trace has %d entries, expected %d",
+			len(trace), len(circ.Gates))
+	}
+
+	// Resolve wire values from the inputs and the traced gate
+	// outputs.
+	values := make(map[Wire]byte)
+	for i := 0; i < 64; i++ {
+		values[Wire(i)] = byte(a.Bit(i))
+		values[Wire(64+i)] = byte(b.Bit(i))
+	}
+	for idx, e := range trace {
+		if e.Gate != circ.Gates[idx] {
+			t.Fatalf("entry %d: gate %v, expected %v",
+				idx, e.Gate, circ.Gates[idx])
+		}
+		if e.Input0 != values[e.Gate.Input0] {
+			t.Errorf("entry %d: %v=%d, expected %d",
+				idx, e.Gate.Input0, e.Input0, values[e.Gate.Input0])
+		}
+		var output byte
+		switch e.Gate.Op {
+		case XOR:
+			output = e.Input0 ^ e.Input1
+		case XNOR:
+			output = 1 ^ e.Input0 ^ e.Input1
+		case AND:
+			output = e.Input0 & e.Input1
+		case OR:
+			output = e.Input0 | e.Input1
+		case INV:
+			output = 1 ^ e.Input0
+		}
+		if e.Gate.Op != INV && e.Input1 != values[e.Gate.Input1] {
+			t.Errorf("entry %d: %v=%d, expected %d",
+				idx, e.Gate.Input1, e.Input1, values[e.Gate.Input1])
+		}
+		if e.Output != output {
+			t.Errorf("entry %d: %s: output %d, expected %d",
+				idx, e, e.Output, output)
+		}
+		values[e.Gate.Output] = e.Output
+	}
+
+	wires, err := circ.LookupWires("w130")
+	if err != nil || len(wires) != 1 || wires[0] != 130 {
+		t.Errorf("LookupWires(w130): got %v (%v)", wires, err)
+	}
+}
@@ -0,0 +1,307 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package compare implements a two-party secure comparison protocol
+// which outputs only the comparison bit a<b without garbling a
+// comparator circuit.
+//
+// The protocol follows the DGK comparison. For the n-bit inputs a and
+// b, the values
+//
+//	c_i = a_i - b_i + 1 + 3 * sum_{j>i} (a_j XOR b_j)
+//
+// contain a zero if and only if a<b. Each c_i is a sum of terms that
+// depend on a single bit of b, so the sender (holding a) prepares
+// both alternatives of the terms of bit j, multiplies them with
+// random non-zero factors r_i, masks them with additive shares of
+// zero, and permutes them with a random permutation. The receiver
+// (holding b) learns the alternatives of its bits with oblivious
+// transfer and sums them into the blinded and permuted values r_i*c_i
+// which reveal only if one of them is zero. The protocol is secure
+// against semi-honest adversaries.
+package compare
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/bits"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// The values are computed in the field Z_p where p is the Mersenne
+// prime 2^61-1.
+const (
+	prime     uint64 = 1<<61 - 1
+	valueSize        = 8
+)
+
+var bo = binary.BigEndian
+
+// Sender runs the comparison protocol as the party holding the value
+// a. The argument size specifies the bit size of the compared
+// values. The function returns the comparison result a<b.
+func Sender(conn *p2p.Conn, oti ot.OT, a *big.Int, size int) (bool, error) {
+	if err := checkInput(a, size); err != nil {
+		return false, err
+	}
+	if err := conn.SendUint32(size); err != nil {
+		return false, err
+	}
+	if err := conn.Flush(); err != nil {
+		return false, err
+	}
+
+	// Transfer the keys of the receiver's alternatives.
+	if err := oti.InitSender(conn); err != nil {
+		return false, err
+	}
+	keys := make([]ot.Wire, size)
+	for j := 0; j < size; j++ {
+		l0, err := ot.NewLabel(rand.Reader)
+		if err != nil {
+			return false, err
+		}
+		l1, err := ot.NewLabel(rand.Reader)
+		if err != nil {
+			return false, err
+		}
+		keys[j] = ot.Wire{
+			L0: l0,
+			L1: l1,
+		}
+	}
+	if err := oti.Send(keys); err != nil {
+		return false, err
+	}
+
+	// Blinding factors and the permutation of the values.
+	factors := make([]uint64, size)
+	for i := range factors {
+		r, err := randomNonZero()
+		if err != nil {
+			return false, err
+		}
+		factors[i] = r
+	}
+	perm, err := randomPerm(size)
+	if err != nil {
+		return false, err
+	}
+
+	// Additive shares of zero for each value.
+	masks := make([][]uint64, size)
+	for i := range masks {
+		masks[i] = make([]uint64, size)
+		var sum uint64
+		for j := 0; j < size-1; j++ {
+			m, err := randomElement()
+			if err != nil {
+				return false, err
+			}
+			masks[i][j] = m
+			sum = add(sum, m)
+		}
+		masks[i][size-1] = sub(0, sum)
+	}
+
+	// Send the encrypted alternatives for each bit of b.
+	buf := make([]byte, size*valueSize)
+	for j := 0; j < size; j++ {
+		aj := uint64(a.Bit(j))
+		for b := uint64(0); b <= 1; b++ {
+			for i := 0; i < size; i++ {
+				var term uint64
+				if j == i {
+					term = aj + 1 - b
+				} else if j > i {
+					term = 3 * (aj ^ b)
+				}
+				v := add(mul(factors[i], term), masks[i][j])
+				bo.PutUint64(buf[perm[i]*valueSize:], v)
+			}
+			key := keys[j].L0
+			if b == 1 {
+				key = keys[j].L1
+			}
+			if err := xorKeyStream(key, buf); err != nil {
+				return false, err
+			}
+			if err := conn.SendData(buf); err != nil {
+				return false, err
+			}
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return false, err
+	}
+
+	result, err := conn.ReceiveByte()
+	if err != nil {
+		return false, err
+	}
+	return result != 0, nil
+}
+
+// Receiver runs the comparison protocol as the party holding the
+// value b. The argument size specifies the bit size of the compared
+// values. The function returns the comparison result a<b.
+func Receiver(conn *p2p.Conn, oti ot.OT, b *big.Int, size int) (bool, error) {
+	if err := checkInput(b, size); err != nil {
+		return false, err
+	}
+	peerSize, err := conn.ReceiveUint32()
+	if err != nil {
+		return false, err
+	}
+	if peerSize != size {
+		return false, fmt.Errorf("size mismatch: got %d, expected %d",
+			peerSize, size)
+	}
+
+	// Receive the keys of our alternatives.
+	if err := oti.InitReceiver(conn); err != nil {
+		return false, err
+	}
+	flags := make([]bool, size)
+	for j := range flags {
+		flags[j] = b.Bit(j) == 1
+	}
+	keys := make([]ot.Label, size)
+	if err := oti.Receive(flags, keys); err != nil {
+		return false, err
+	}
+
+	// Sum our alternatives into the blinded values.
+	values := make([]uint64, size)
+	for j := 0; j < size; j++ {
+		var data []byte
+		for alt := 0; alt <= 1; alt++ {
+			d, err := conn.ReceiveData()
+			if err != nil {
+				return false, err
+			}
+			if flags[j] == (alt == 1) {
+				data = d
+			}
+		}
+		if len(data) != size*valueSize {
+			return false, fmt.Errorf("invalid data length %d, expected %d",
+				len(data), size*valueSize)
+		}
+		if err := xorKeyStream(keys[j], data); err != nil {
+			return false, err
+		}
+		for i := range values {
+			v := bo.Uint64(data[i*valueSize:])
+			if v >= prime {
+				return false, fmt.Errorf("invalid value %d", v)
+			}
+			values[i] = add(values[i], v)
+		}
+	}
+
+	var result bool
+	for _, v := range values {
+		if v == 0 {
+			result = true
+		}
+	}
+	var ret byte
+	if result {
+		ret = 1
+	}
+	if err := conn.SendByte(ret); err != nil {
+		return false, err
+	}
+	if err := conn.Flush(); err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+func checkInput(v *big.Int, size int) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+	if v.Sign() < 0 || v.BitLen() > size {
+		return fmt.Errorf("input %v overflows %d bits", v, size)
+	}
+	return nil
+}
+
+// xorKeyStream encrypts or decrypts the data in place with the
+// AES-CTR key stream of the key label. Each key is used only for one
+// message so the stream starts from the zero IV.
+func xorKeyStream(key ot.Label, data []byte) error {
+	var keyData ot.LabelData
+	block, err := aes.NewCipher(key.Bytes(&keyData))
+	if err != nil {
+		return err
+	}
+	var iv [aes.BlockSize]byte
+	cipher.NewCTR(block, iv[:]).XORKeyStream(data, data)
+	return nil
+}
+
+func add(a, b uint64) uint64 {
+	return (a + b) % prime
+}
+
+func sub(a, b uint64) uint64 {
+	return (a + prime - b) % prime
+}
+
+func mul(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, prime)
+}
+
+func randomElement() (uint64, error) {
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		v := bo.Uint64(buf[:]) & prime
+		if v < prime {
+			return v, nil
+		}
+	}
+}
+
+func randomNonZero() (uint64, error) {
+	for {
+		v, err := randomElement()
+		if err != nil {
+			return 0, err
+		}
+		if v != 0 {
+			return v, nil
+		}
+	}
+}
+
+// randomPerm returns a random permutation of [0...n).
+func randomPerm(n int) ([]int, error) {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		perm[i], perm[j.Int64()] = perm[j.Int64()], perm[i]
+	}
+	return perm, nil
+}
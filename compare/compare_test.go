@@ -0,0 +1,86 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compare
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func compare(t *testing.T, a, b *big.Int, size int) {
+	sconn, rconn := p2p.Pipe()
+
+	type result struct {
+		lt  bool
+		err error
+	}
+	done := make(chan result)
+
+	go func() {
+		lt, err := Receiver(rconn, ot.NewCO(), b, size)
+		done <- result{
+			lt:  lt,
+			err: err,
+		}
+	}()
+
+	lt, err := Sender(sconn, ot.NewCO(), a, size)
+	if err != nil {
+		t.Fatalf("Sender failed: %v", err)
+	}
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("Receiver failed: %v", r.err)
+	}
+	expected := a.Cmp(b) < 0
+	if lt != expected {
+		t.Errorf("Sender: %v<%v=%v, expected %v", a, b, lt, expected)
+	}
+	if r.lt != expected {
+		t.Errorf("Receiver: %v<%v=%v, expected %v", a, b, r.lt, expected)
+	}
+}
+
+func TestCompareEdges(t *testing.T) {
+	const size = 8
+	values := []int64{0, 1, 2, 127, 128, 254, 255}
+	for _, a := range values {
+		for _, b := range values {
+			compare(t, big.NewInt(a), big.NewInt(b), size)
+		}
+	}
+}
+
+func TestCompareRandom(t *testing.T) {
+	for _, size := range []int{1, 16, 64, 128} {
+		max := new(big.Int).Lsh(big.NewInt(1), uint(size))
+		for i := 0; i < 10; i++ {
+			a, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compare(t, a, b, size)
+			compare(t, a, a, size)
+		}
+	}
+}
+
+func TestCompareOverflow(t *testing.T) {
+	sconn, _ := p2p.Pipe()
+	_, err := Sender(sconn, ot.NewCO(), big.NewInt(256), 8)
+	if err == nil {
+		t.Errorf("overflowing input accepted")
+	}
+}
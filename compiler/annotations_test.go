@@ -0,0 +1,106 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/ast"
+)
+
+const directivesInput = `
+package main
+
+// Limit is the maximum value.
+// @since 1.2
+const Limit = 10
+
+// Point is a 2D point.
+// @deprecated use Vector instead.
+type Point struct {
+	X, Y int32
+}
+
+// Sum adds the arguments, @ a and b.
+//
+// @since 1.3
+// @example Sum(1, 2)
+// @example Sum(3, 4)
+// @pure
+//   @not-a-directive! text
+func Sum(a, b int32) int32 {
+	return a + b
+}
+`
+
+func TestDirectives(t *testing.T) {
+	pkg := parseFormat(t, "{data}", strings.NewReader(directivesInput))
+
+	tests := []struct {
+		name        string
+		annotations ast.Annotations
+		directives  ast.Directives
+		prose       ast.Annotations
+	}{
+		{
+			name:        "const",
+			annotations: pkg.Constants[0].Annotations,
+			directives: ast.Directives{
+				"since": {"1.2"},
+			},
+			prose: ast.Annotations{" Limit is the maximum value."},
+		},
+		{
+			name:        "type",
+			annotations: pkg.Types[0].Annotations,
+			directives: ast.Directives{
+				"deprecated": {"use Vector instead."},
+			},
+			prose: ast.Annotations{" Point is a 2D point."},
+		},
+		{
+			name:        "func",
+			annotations: pkg.Functions["Sum"].Annotations,
+			directives: ast.Directives{
+				"since":   {"1.3"},
+				"example": {"Sum(1, 2)", "Sum(3, 4)"},
+				"pure":    {""},
+			},
+			prose: ast.Annotations{
+				" Sum adds the arguments, @ a and b.",
+				"",
+				"   @not-a-directive! text",
+			},
+		},
+	}
+	for _, test := range tests {
+		directives := test.annotations.Directives()
+		if !reflect.DeepEqual(directives, test.directives) {
+			t.Errorf("%s: got directives %v, expected %v",
+				test.name, directives, test.directives)
+		}
+		prose := test.annotations.Prose()
+		if !reflect.DeepEqual(prose, test.prose) {
+			t.Errorf("%s: got prose %q, expected %q",
+				test.name, prose, test.prose)
+		}
+	}
+
+	d := pkg.Functions["Sum"].Annotations.Directives()
+	if d.Get("example") != "Sum(1, 2)" {
+		t.Errorf("Get(example): got %q", d.Get("example"))
+	}
+	if !d.Has("pure") || d.Get("pure") != "" {
+		t.Errorf("pure: got %v %q", d.Has("pure"), d.Get("pure"))
+	}
+	if d.Has("deprecated") || d.Get("deprecated") != "" {
+		t.Errorf("deprecated: got %v %q",
+			d.Has("deprecated"), d.Get("deprecated"))
+	}
+}
@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"sort"
+
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// API describes the exported symbols of a package. The symbols are
+// sorted by their names.
+type API struct {
+	Package   string
+	Functions []FuncAPI
+	Types     []TypeAPI
+	Constants []ValueAPI
+	Variables []ValueAPI
+}
+
+// FuncAPI describes an exported function or method.
+type FuncAPI struct {
+	utils.Point
+	Name        string
+	Signature   string
+	Args        []ParamAPI
+	Return      []ParamAPI
+	Annotations ast.Annotations
+}
+
+// ParamAPI describes a function argument or return value. The Name
+// is empty for unnamed return values.
+type ParamAPI struct {
+	Name string
+	Type string
+}
+
+// TypeAPI describes an exported type and its exported methods.
+type TypeAPI struct {
+	utils.Point
+	Name        string
+	Definition  string
+	Methods     []FuncAPI
+	Annotations ast.Annotations
+}
+
+// ValueAPI describes an exported constant or variable. The Type is
+// empty for values without an explicit type and the Value is empty
+// for values without an initializer.
+type ValueAPI struct {
+	utils.Point
+	Name        string
+	Type        string
+	Value       string
+	Annotations ast.Annotations
+}
+
+// PackageAPI returns the exported symbols of the parsed package pkg.
+// The symbols are returned as the package declares them; their types
+// are not resolved against the package's imports.
+func PackageAPI(pkg *ast.Package) API {
+	api := API{
+		Package: pkg.Name,
+	}
+	for _, f := range pkg.Functions {
+		if ast.IsExported(f.Name) {
+			api.Functions = append(api.Functions, funcAPI(f))
+		}
+	}
+	sort.Slice(api.Functions, func(i, j int) bool {
+		return api.Functions[i].Name < api.Functions[j].Name
+	})
+
+	for _, ti := range pkg.Types {
+		if !ast.IsExported(ti.TypeName) {
+			continue
+		}
+		t := TypeAPI{
+			Point:       ti.Point,
+			Name:        ti.TypeName,
+			Definition:  ti.Format(),
+			Annotations: ti.Annotations,
+		}
+		for _, m := range ti.Methods {
+			if ast.IsExported(m.Name) {
+				t.Methods = append(t.Methods, funcAPI(m))
+			}
+		}
+		sort.Slice(t.Methods, func(i, j int) bool {
+			return t.Methods[i].Name < t.Methods[j].Name
+		})
+		api.Types = append(api.Types, t)
+	}
+	sort.Slice(api.Types, func(i, j int) bool {
+		return api.Types[i].Name < api.Types[j].Name
+	})
+
+	for _, c := range pkg.Constants {
+		if c.Exported() {
+			api.Constants = append(api.Constants,
+				valueAPI(c.Point, c.Name, c.Type, c.Init, c.Annotations))
+		}
+	}
+	sort.Slice(api.Constants, func(i, j int) bool {
+		return api.Constants[i].Name < api.Constants[j].Name
+	})
+
+	for _, v := range pkg.Variables {
+		for _, name := range v.Names {
+			if ast.IsExported(name) {
+				api.Variables = append(api.Variables,
+					valueAPI(v.Point, name, v.Type, v.Init, v.Annotations))
+			}
+		}
+	}
+	sort.Slice(api.Variables, func(i, j int) bool {
+		return api.Variables[i].Name < api.Variables[j].Name
+	})
+
+	return api
+}
+
+func funcAPI(f *ast.Func) FuncAPI {
+	result := FuncAPI{
+		Point:       f.Point,
+		Name:        f.Name,
+		Signature:   f.String(),
+		Annotations: f.Annotations,
+	}
+	for _, arg := range f.Args {
+		result.Args = append(result.Args, ParamAPI{
+			Name: arg.Name,
+			Type: arg.Type.String(),
+		})
+	}
+	for _, ret := range f.Return {
+		param := ParamAPI{
+			Type: ret.Type.String(),
+		}
+		if f.NamedReturn {
+			param.Name = ret.Name
+		}
+		result.Return = append(result.Return, param)
+	}
+	return result
+}
+
+func valueAPI(point utils.Point, name string, ti *ast.TypeInfo, init ast.AST,
+	annotations ast.Annotations) ValueAPI {
+
+	result := ValueAPI{
+		Point:       point,
+		Name:        name,
+		Annotations: annotations,
+	}
+	if ti != nil {
+		result.Type = ti.String()
+	}
+	if init != nil {
+		result.Value = init.String()
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const apiInput = `
+package shapes
+
+// Scale is the default scale.
+const Scale = 10
+
+const precision uint32 = 8
+
+var Origin Point
+
+var corner Point
+
+type Point struct {
+	X, Y int32
+}
+
+type offset int32
+
+// Add adds the points.
+func (p Point) Add(o Point) Point {
+	return Point{X: p.X + o.X, Y: p.Y + o.Y}
+}
+
+func (p Point) norm() int32 {
+	return p.X + p.Y
+}
+
+func Dot(a, b Point) (sum int32, ok bool) {
+	return a.X*b.X + a.Y*b.Y, true
+}
+
+func Max(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func helper(a offset) offset {
+	return a
+}
+`
+
+func TestPackageAPI(t *testing.T) {
+	pkg := parseFormat(t, "{data}", strings.NewReader(apiInput))
+	api := PackageAPI(pkg)
+
+	if api.Package != "shapes" {
+		t.Errorf("got package %s, expected shapes", api.Package)
+	}
+
+	var funcs []string
+	for _, f := range api.Functions {
+		funcs = append(funcs, f.Signature)
+	}
+	expectedFuncs := []string{
+		"func Dot(a, b Point) (sum int32, ok bool)",
+		"func Max(a, b int32) int32",
+	}
+	if !reflect.DeepEqual(funcs, expectedFuncs) {
+		t.Errorf("got functions %q, expected %q", funcs, expectedFuncs)
+	}
+	dot := api.Functions[0]
+	expectedArgs := []ParamAPI{
+		{Name: "a", Type: "Point"},
+		{Name: "b", Type: "Point"},
+	}
+	if !reflect.DeepEqual(dot.Args, expectedArgs) {
+		t.Errorf("got Dot args %v, expected %v", dot.Args, expectedArgs)
+	}
+	expectedReturn := []ParamAPI{
+		{Name: "sum", Type: "int32"},
+		{Name: "ok", Type: "bool"},
+	}
+	if !reflect.DeepEqual(dot.Return, expectedReturn) {
+		t.Errorf("got Dot return %v, expected %v", dot.Return,
+			expectedReturn)
+	}
+	if ret := api.Functions[1].Return; len(ret) != 1 || ret[0].Name != "" {
+		t.Errorf("got Max return %v, expected unnamed int32", ret)
+	}
+
+	if len(api.Types) != 1 {
+		t.Fatalf("got %d types, expected 1", len(api.Types))
+	}
+	point := api.Types[0]
+	if point.Name != "Point" {
+		t.Errorf("got type %s, expected Point", point.Name)
+	}
+	if len(point.Methods) != 1 ||
+		point.Methods[0].Signature != "func (p Point) Add(o Point) Point" {
+		t.Errorf("got Point methods %v, expected Add", point.Methods)
+	} else if point.Methods[0].Annotations.FirstSentence() !=
+		" Add adds the points." {
+		t.Errorf("got Add annotations %q", point.Methods[0].Annotations)
+	}
+
+	if len(api.Constants) != 1 || api.Constants[0].Name != "Scale" ||
+		api.Constants[0].Value != "10" || api.Constants[0].Type != "" {
+		t.Errorf("got constants %v, expected Scale=10", api.Constants)
+	}
+	if len(api.Variables) != 1 || api.Variables[0].Name != "Origin" ||
+		api.Variables[0].Type != "Point" {
+		t.Errorf("got variables %v, expected Origin Point", api.Variables)
+	}
+}
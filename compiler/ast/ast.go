@@ -29,6 +29,7 @@ var (
 	_ AST = &Call{}
 	_ AST = &ArrayCast{}
 	_ AST = &Return{}
+	_ AST = &Defer{}
 	_ AST = &For{}
 	_ AST = &ForRange{}
 	_ AST = &Binary{}
@@ -40,6 +41,7 @@ var (
 	_ AST = &CompositeLit{}
 	_ AST = &Make{}
 	_ AST = &Copy{}
+	_ AST = &Send{}
 )
 
 func indent(w io.Writer, indent int) {
@@ -59,6 +61,8 @@ const (
 	TypeStruct
 	TypePointer
 	TypeAlias
+	TypeChan
+	TypeFunc
 )
 
 // TypeInfo contains AST type information.
@@ -71,6 +75,8 @@ type TypeInfo struct {
 	TypeName     string
 	StructFields []StructField
 	AliasType    *TypeInfo
+	FuncArgs     []*TypeInfo
+	FuncReturn   []*TypeInfo
 	Methods      map[string]*Func
 	Annotations  Annotations
 }
@@ -88,7 +94,7 @@ func (ti *TypeInfo) Equal(o *TypeInfo) bool {
 		return ti.ElementType.Equal(o.ElementType) &&
 			ti.ArrayLength == o.ArrayLength
 
-	case TypeSlice, TypePointer:
+	case TypeSlice, TypePointer, TypeChan:
 		return ti.ElementType.Equal(o.ElementType)
 
 	case TypeStruct:
@@ -105,11 +111,27 @@ func (ti *TypeInfo) Equal(o *TypeInfo) bool {
 	case TypeAlias:
 		return ti.AliasType.Equal(o.AliasType)
 
+	case TypeFunc:
+		return typeInfosEqual(ti.FuncArgs, o.FuncArgs) &&
+			typeInfosEqual(ti.FuncReturn, o.FuncReturn)
+
 	default:
 		panic("unsupported type")
 	}
 }
 
+func typeInfosEqual(a, b []*TypeInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx, ti := range a {
+		if !ti.Equal(b[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
 // StructField contains AST structure field information.
 type StructField struct {
 	utils.Point
@@ -179,6 +201,32 @@ func (ti *TypeInfo) format(pp bool) string {
 	case TypePointer:
 		return fmt.Sprintf("%s*%s", str, ti.ElementType)
 
+	case TypeChan:
+		return fmt.Sprintf("%schan %s", str, ti.ElementType)
+
+	case TypeFunc:
+		str += "func("
+		for idx, arg := range ti.FuncArgs {
+			if idx > 0 {
+				str += ", "
+			}
+			str += arg.String()
+		}
+		str += ")"
+		if len(ti.FuncReturn) == 1 {
+			str += " " + ti.FuncReturn[0].String()
+		} else if len(ti.FuncReturn) > 1 {
+			str += " ("
+			for idx, ret := range ti.FuncReturn {
+				if idx > 0 {
+					str += ", "
+				}
+				str += ret.String()
+			}
+			str += ")"
+		}
+		return str
+
 	default:
 		return fmt.Sprintf("%s{TypeInfo %d}", str, ti.Type)
 	}
@@ -299,18 +347,77 @@ func (ti *TypeInfo) Resolve(env *Env, ctx *Codegen, gen *ssa.Generator) (
 			ElementType: &elInfo,
 		}, nil
 
+	case TypeStruct:
+		// Construct compound type.
+		var fields []types.StructField
+		var bits types.Size
+		var minBits types.Size
+		var offset types.Size
+		for _, field := range ti.StructFields {
+			info, err := field.Type.Resolve(env, ctx, gen)
+			if err != nil {
+				return result, err
+			}
+			field := types.StructField{
+				Name: field.Name,
+				Type: info,
+			}
+			field.Type.Offset = offset
+			fields = append(fields, field)
+
+			bits += info.Bits
+			minBits += info.MinBits
+			offset += info.Bits
+		}
+		return types.Info{
+			Type:       types.TStruct,
+			IsConcrete: true,
+			Bits:       bits,
+			MinBits:    minBits,
+			Struct:     fields,
+		}, nil
+
+	case TypeChan:
+		// Element type.
+		elInfo, err := ti.ElementType.Resolve(env, ctx, gen)
+		if err != nil {
+			return result, err
+		}
+		if !elInfo.Concrete() {
+			return result, ctx.Errorf(ti.ElementType,
+				"unspecified channel element type: %s", elInfo)
+		}
+		// Channels are compile-time values without wires.
+		return types.Info{
+			Type:        types.TChan,
+			IsConcrete:  true,
+			ElementType: &elInfo,
+		}, nil
+
+	case TypeFunc:
+		// Function values are compile-time values without wires. The
+		// function signatures are checked when the function values
+		// are bound to the function arguments.
+		return types.Info{
+			Type:       types.TFunc,
+			IsConcrete: true,
+		}, nil
+
 	default:
 		return result, ctx.Errorf(ti, "can't resolve type %s", ti)
 	}
 }
 
-// ConstantDef implements a constant definition.
+// ConstantDef implements a constant definition. The Group specifies
+// the location of the definition's declaration group or it is zero if
+// the constant is not defined in a group.
 type ConstantDef struct {
 	utils.Point
 	Name        string
 	Type        *TypeInfo
 	Init        AST
 	Annotations Annotations
+	Group       utils.Point
 }
 
 // Exported describes if the constant is exported from the package.
@@ -458,6 +565,15 @@ type ReturnInfo struct {
 // Annotations specify function annotations.
 type Annotations []string
 
+// Comment implements a source code comment. The Text field holds the
+// comment with its delimiters and End specifies the comment's last
+// line.
+type Comment struct {
+	utils.Point
+	End  utils.Point
+	Text string
+}
+
 // FirstSentence returns the first sentence from the annotations or an
 // empty string it if annotations are empty.
 func (ann Annotations) FirstSentence() string {
@@ -469,6 +585,72 @@ func (ann Annotations) FirstSentence() string {
 	return ""
 }
 
+// Directives map annotation directive keys to their values. A
+// directive is an annotation line of the form "@key value" where the
+// value is optional. The values of the repeated directives are in
+// their source order.
+type Directives map[string][]string
+
+// Get returns the first value of the directive key. The function
+// returns an empty string if the directive is not set or if it does
+// not have a value.
+func (d Directives) Get(key string) string {
+	values := d[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Has tests if the directive key is set.
+func (d Directives) Has(key string) bool {
+	_, ok := d[key]
+	return ok
+}
+
+// Directives returns the annotation directives.
+func (ann Annotations) Directives() Directives {
+	result := make(Directives)
+	for _, line := range ann {
+		key, value, ok := directive(line)
+		if ok {
+			result[key] = append(result[key], value)
+		}
+	}
+	return result
+}
+
+// Prose returns the annotation lines without the directives.
+func (ann Annotations) Prose() Annotations {
+	var result Annotations
+	for _, line := range ann {
+		if _, _, ok := directive(line); !ok {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// directive parses the annotation line as a directive.
+func directive(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || line[0] != '@' {
+		return
+	}
+	idx := strings.IndexFunc(line, unicode.IsSpace)
+	if idx < 0 {
+		idx = len(line)
+	}
+	key = line[1:idx]
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' &&
+			r != '-' && r != '.' {
+			return "", "", false
+		}
+	}
+	return key, strings.TrimSpace(line[idx:]), true
+}
+
 // NewFunc creates a new function definition.
 func NewFunc(loc utils.Point, name string, args []*Variable, ret []*Variable,
 	namedReturn bool, body List, end utils.Point,
@@ -500,6 +682,21 @@ func NewFunc(loc utils.Point, name string, args []*Variable, ret []*Variable,
 	}
 }
 
+// funcType returns the function type of the function.
+func (ast *Func) funcType() *TypeInfo {
+	result := &TypeInfo{
+		Point: ast.Point,
+		Type:  TypeFunc,
+	}
+	for _, arg := range ast.Args {
+		result.FuncArgs = append(result.FuncArgs, arg.Type)
+	}
+	for _, ret := range ast.Return {
+		result.FuncReturn = append(result.FuncReturn, ret.Type)
+	}
+	return result
+}
+
 func (ast *Func) String() string {
 	var str string
 	if ast.This != nil {
@@ -552,13 +749,16 @@ func (ast *Func) String() string {
 	return str
 }
 
-// VariableDef implements an AST variable definition.
+// VariableDef implements an AST variable definition. The Group
+// specifies the location of the definition's declaration group or it
+// is zero if the variable is not defined in a group.
 type VariableDef struct {
 	utils.Point
 	Names       []string
 	Type        *TypeInfo
 	Init        AST
 	Annotations Annotations
+	Group       utils.Point
 }
 
 func (ast *VariableDef) String() string {
@@ -620,7 +820,8 @@ func (ast *Call) String() string {
 	return str + ")"
 }
 
-// ArrayCast implements array cast expressions.
+// ArrayCast implements cast expressions to array, slice, and struct
+// type literals.
 type ArrayCast struct {
 	utils.Point
 	TypeInfo *TypeInfo
@@ -642,6 +843,17 @@ func (ast *Return) String() string {
 	return fmt.Sprintf("return %v", ast.Exprs)
 }
 
+// Defer implements an AST defer statement. The deferred statements
+// are executed in LIFO order when the function returns.
+type Defer struct {
+	utils.Point
+	Stmt AST
+}
+
+func (ast *Defer) String() string {
+	return fmt.Sprintf("defer %v", ast.Stmt)
+}
+
 // For implements an AST for statement.
 type For struct {
 	utils.Point
@@ -652,6 +864,9 @@ type For struct {
 }
 
 func (ast *For) String() string {
+	if ast.Cond == nil {
+		return fmt.Sprintf("for %s", ast.Body)
+	}
 	return fmt.Sprintf("for %s; %s; %s %s",
 		ast.Init, ast.Cond, ast.Inc, ast.Body)
 }
@@ -739,12 +954,14 @@ func (t BinaryType) String() string {
 	return fmt.Sprintf("{BinaryType %d}", t)
 }
 
-// Binary implements an AST binary expression.
+// Binary implements an AST binary expression. The Paren field
+// specifies if the expression was parenthesized in the source.
 type Binary struct {
 	utils.Point
 	Left  AST
 	Op    BinaryType
 	Right AST
+	Paren bool
 }
 
 func (ast *Binary) String() string {
@@ -800,6 +1017,9 @@ type Slice struct {
 	Expr AST
 	From AST
 	To   AST
+	// Max is the capacity index of the three-index slice expression
+	// a[from:to:max]. It is nil for two-index slices.
+	Max AST
 }
 
 func (ast *Slice) String() string {
@@ -810,6 +1030,9 @@ func (ast *Slice) String() string {
 	if ast.To != nil {
 		toStr = ast.To.String()
 	}
+	if ast.Max != nil {
+		return fmt.Sprintf("%s[%s:%s:%s]", ast.Expr, fromStr, toStr, ast.Max)
+	}
 	return fmt.Sprintf("%s[%s:%s]", ast.Expr, fromStr, toStr)
 }
 
@@ -834,10 +1057,12 @@ func (ast *VariableRef) String() string {
 	return ast.Name.String()
 }
 
-// BasicLit implements an AST basic literal value.
+// BasicLit implements an AST basic literal value. The Raw field
+// contains the literal's source text, if known.
 type BasicLit struct {
 	utils.Point
 	Value interface{}
+	Raw   string
 }
 
 func (ast *BasicLit) String() string {
@@ -916,3 +1141,14 @@ type Copy struct {
 func (ast *Copy) String() string {
 	return fmt.Sprintf("copy(%v, %v)", ast.Dst, ast.Src)
 }
+
+// Send implements the channel send statement.
+type Send struct {
+	utils.Point
+	Chan  AST
+	Value AST
+}
+
+func (ast *Send) String() string {
+	return fmt.Sprintf("%v <- %v", ast.Chan, ast.Value)
+}
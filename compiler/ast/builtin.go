@@ -8,10 +8,13 @@ package ast
 
 import (
 	"fmt"
+	"math/big"
 	"path"
+	"strings"
 
 	"github.com/markkurossi/mpc/circuit"
 	"github.com/markkurossi/mpc/compiler/circuits"
+	"github.com/markkurossi/mpc/compiler/mpa"
 	"github.com/markkurossi/mpc/compiler/ssa"
 	"github.com/markkurossi/mpc/compiler/utils"
 	"github.com/markkurossi/mpc/types"
@@ -33,14 +36,52 @@ type Eval func(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 
 // Predeclared identifiers.
 var builtins = map[string]Builtin{
+	"addsat": {
+		SSA:  addsatSSA,
+		Eval: addsatEval,
+	},
+	"argmax": {
+		SSA: argmaxSSA,
+	},
+	"argmin": {
+		SSA: argminSSA,
+	},
+	"bytesBE": {
+		SSA: bytesBESSA,
+	},
+	"bytesLE": {
+		SSA: bytesLESSA,
+	},
+	"cap": {
+		SSA:  capSSA,
+		Eval: capEval,
+	},
+	"clz": {
+		SSA:  clzSSA,
+		Eval: clzEval,
+	},
+	"cswap": {
+		SSA: cswapSSA,
+	},
+	"ctz": {
+		SSA:  ctzSSA,
+		Eval: ctzEval,
+	},
 	"floorPow2": {
 		SSA:  floorPow2SSA,
 		Eval: floorPow2Eval,
 	},
+	"isqrt": {
+		SSA:  isqrtSSA,
+		Eval: isqrtEval,
+	},
 	"len": {
 		SSA:  lenSSA,
 		Eval: lenEval,
 	},
+	"mulx": {
+		SSA: mulxSSA,
+	},
 	"native": {
 		SSA: nativeSSA,
 	},
@@ -48,10 +89,425 @@ var builtins = map[string]Builtin{
 		SSA:  panicSSA,
 		Eval: panicEval,
 	},
+	"poly": {
+		SSA:  polySSA,
+		Eval: polyEval,
+	},
+	"revealIf": {
+		SSA: revealIfSSA,
+	},
+	"select": {
+		SSA: selectSSA,
+	},
+	"shuffle": {
+		SSA: shuffleSSA,
+	},
 	"size": {
 		SSA:  sizeSSA,
 		Eval: sizeEval,
 	},
+	"sprintf": {
+		SSA:  sprintfSSA,
+		Eval: sprintfEval,
+	},
+	"subsat": {
+		SSA:  subsatSSA,
+		Eval: subsatEval,
+	},
+	"uintBE": {
+		SSA: uintBESSA,
+	},
+	"uintLE": {
+		SSA: uintLESSA,
+	},
+}
+
+func addsatSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return saturatingSSA("addsat", circuits.NewSaturatingAdder, block, ctx,
+		gen, args, loc)
+}
+
+func subsatSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return saturatingSSA("subsat", circuits.NewSaturatingSubtractor, block,
+		ctx, gen, args, loc)
+}
+
+func saturatingSSA(name string,
+	f func(cc *circuits.Compiler, signed bool, x, y, z []*circuits.Wire) error,
+	block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 2 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+	for idx, arg := range args {
+		switch arg.Type.Type {
+		case types.TInt, types.TUint:
+		default:
+			return nil, nil, ctx.Errorf(loc,
+				"invalid argument %d (type %s) for %s", idx+1, arg.Type, name)
+		}
+	}
+
+	// Untyped constant arguments get the type of the other argument.
+	x, err := convertConst(ctx, loc, gen, args[0], args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	y, err := convertConst(ctx, loc, gen, args[1], x)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !x.Type.Equal(y.Type) {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid arguments for %s (mismatched types %s and %s)",
+			name, x.Type, y.Type)
+	}
+	signed := x.Type.Type == types.TInt
+
+	v := gen.AnonVal(x.Type)
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return f(cc, signed, a, b, r)
+		}, x, y, v))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func addsatEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+	return saturatingEval("addsat", (*big.Int).Add, args, env, ctx, gen, loc)
+}
+
+func subsatEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+	return saturatingEval("subsat", (*big.Int).Sub, args, env, ctx, gen, loc)
+}
+
+func saturatingEval(name string, op func(z, x, y *big.Int) *big.Int,
+	args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	if len(args) != 2 {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+
+	var vals [2]*big.Int
+	var ti types.Info
+	for idx, arg := range args {
+		constVal, ok, err := arg.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			return ssa.Undefined, ok, err
+		}
+		val, ok := constVal.ConstValue.(*mpa.Int)
+		if !ok {
+			return ssa.Undefined, false, ctx.Errorf(loc,
+				"invalid argument %d (type %s) for %s",
+				idx+1, constVal.Type, name)
+		}
+		if idx > 0 && !constVal.Type.Equal(ti) {
+			// Leave the type unification to the SSA generation.
+			return ssa.Undefined, false, nil
+		}
+		ti = constVal.Type
+		switch ti.Type {
+		case types.TInt, types.TUint:
+		default:
+			return ssa.Undefined, false, ctx.Errorf(loc,
+				"invalid argument %d (type %s) for %s", idx+1, ti, name)
+		}
+		if ti.Bits == 0 || ti.Bits > 64 {
+			return ssa.Undefined, false, nil
+		}
+		// Interpret the value's bit pattern in the type.
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(ti.Bits))
+		vals[idx] = big.NewInt(val.Int64())
+		vals[idx].Mod(vals[idx], mod)
+		if ti.Type == types.TInt && vals[idx].Bit(int(ti.Bits-1)) != 0 {
+			vals[idx].Sub(vals[idx], mod)
+		}
+	}
+
+	// Clamp the result to the type's value range.
+	var lo, hi *big.Int
+	if ti.Type == types.TInt {
+		hi = new(big.Int).Lsh(big.NewInt(1), uint(ti.Bits-1))
+		lo = new(big.Int).Neg(hi)
+		hi.Sub(hi, big.NewInt(1))
+	} else {
+		lo = big.NewInt(0)
+		hi = new(big.Int).Lsh(big.NewInt(1), uint(ti.Bits))
+		hi.Sub(hi, big.NewInt(1))
+	}
+	result := op(new(big.Int), vals[0], vals[1])
+	if result.Cmp(lo) < 0 {
+		result = lo
+	} else if result.Cmp(hi) > 0 {
+		result = hi
+	}
+	v, ok := gen.IntConstant(result, ti)
+	if !ok {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"constant %v overflows %v", result, ti)
+	}
+	return v, true, nil
+}
+
+func bytesBESSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return bytesSSA("bytesBE", true, block, ctx, gen, args, loc)
+}
+
+func bytesLESSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return bytesSSA("bytesLE", false, block, ctx, gen, args, loc)
+}
+
+// bytesSSA converts the integer argument to a byte array in the big-
+// or little-endian byte order.
+func bytesSSA(name string, bigEndian bool, block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, args []ssa.Value, loc utils.Point) (
+	*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+	x := args[0]
+	switch x.Type.Type {
+	case types.TInt, types.TUint:
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", x.Type, name)
+	}
+	if !x.Type.Concrete() {
+		return nil, nil, ctx.Errorf(loc,
+			"unspecified size for argument (type %s) for %s", x.Type, name)
+	}
+	if x.Type.Bits%types.ByteBits != 0 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s: size is not whole bytes",
+			x.Type, name)
+	}
+	elType := types.Byte
+	v := gen.AnonVal(types.Info{
+		Type:        types.TArray,
+		IsConcrete:  true,
+		Bits:        x.Type.Bits,
+		MinBits:     x.Type.Bits,
+		ElementType: &elType,
+		ArraySize:   x.Type.Bits / types.ByteBits,
+	})
+	byteOrder(block, gen, bigEndian, x, v)
+
+	return block, []ssa.Value{v}, nil
+}
+
+func uintBESSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return uintSSA("uintBE", true, block, ctx, gen, args, loc)
+}
+
+func uintLESSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return uintSSA("uintLE", false, block, ctx, gen, args, loc)
+}
+
+// uintSSA converts the byte array argument in the big- or
+// little-endian byte order to an unsigned integer.
+func uintSSA(name string, bigEndian bool, block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, args []ssa.Value, loc utils.Point) (
+	*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+	arr := args[0]
+	if !arr.Type.Type.Array() || arr.Type.ArraySize == 0 ||
+		arr.Type.ElementType.Type != types.TUint ||
+		arr.Type.ElementType.Bits != types.ByteBits {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", arr.Type, name)
+	}
+	bits := arr.Type.ArraySize * types.ByteBits
+	v := gen.AnonVal(types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       bits,
+		MinBits:    bits,
+	})
+	byteOrder(block, gen, bigEndian, arr, v)
+
+	return block, []ssa.Value{v}, nil
+}
+
+// byteOrder moves the value x to v. If bigEndian is true, the byte
+// order of the value is reversed. In MPCL, the integers and arrays
+// are stored least significant bit first so the integer's bytes are
+// in the array in the little-endian order.
+func byteOrder(block *ssa.Block, gen *ssa.Generator, bigEndian bool,
+	x, v ssa.Value) {
+
+	if !bigEndian {
+		block.AddInstr(ssa.NewMovInstr(x, v))
+		return
+	}
+	zero := gen.Constant(int64(0), types.Undefined)
+	gen.AddConstant(zero)
+
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return circuits.NewByteSwap(cc, a, r)
+		}, x, zero, v))
+}
+
+func argmaxSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return argExtremumSSA("argmax", circuits.NewArgmax, circuits.NewIntArgmax,
+		block, ctx, gen, args, loc)
+}
+
+func argminSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return argExtremumSSA("argmin", circuits.NewArgmin, circuits.NewIntArgmin,
+		block, ctx, gen, args, loc)
+}
+
+func argExtremumSSA(name string,
+	unsigned, signed func(cc *circuits.Compiler, elemBits int,
+		arr, index []*circuits.Wire) error,
+	block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+	arr := args[0]
+	if !arr.Type.Type.Array() {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", arr.Type, name)
+	}
+	f := unsigned
+	switch arr.Type.ElementType.Type {
+	case types.TUint:
+	case types.TInt:
+		f = signed
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", arr.Type, name)
+	}
+	if arr.Type.ArraySize == 0 {
+		return nil, nil, ctx.Errorf(loc, "%s of empty array", name)
+	}
+	elemBits := int(arr.Type.ElementType.Bits)
+
+	zero := gen.Constant(int64(0), types.Undefined)
+	gen.AddConstant(zero)
+
+	v := gen.AnonVal(types.Int32)
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return f(cc, elemBits, a, r)
+		}, arr, zero, v))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func clzSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return zerosSSA("clz", circuits.NewLeadingZeros, block, ctx, gen, args,
+		loc)
+}
+
+func ctzSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+	return zerosSSA("ctz", circuits.NewTrailingZeros, block, ctx, gen, args,
+		loc)
+}
+
+func zerosSSA(name string,
+	f func(cc *circuits.Compiler, x, r []*circuits.Wire) error,
+	block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+	switch args[0].Type.Type {
+	case types.TInt, types.TUint:
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", args[0].Type, name)
+	}
+
+	zero := gen.Constant(int64(0), types.Undefined)
+	gen.AddConstant(zero)
+
+	v := gen.AnonVal(types.Int32)
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return f(cc, a, r)
+		}, args[0], zero, v))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func clzEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+	return zerosEval("clz", true, args, env, ctx, gen, loc)
+}
+
+func ctzEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+	return zerosEval("ctz", false, args, env, ctx, gen, loc)
+}
+
+func zerosEval(name string, leading bool, args []AST, env *Env,
+	ctx *Codegen, gen *ssa.Generator, loc utils.Point) (
+	ssa.Value, bool, error) {
+
+	if len(args) != 1 {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid amount of arguments in call to %s", name)
+	}
+
+	constVal, ok, err := args[0].Eval(env, ctx, gen)
+	if err != nil || !ok {
+		return ssa.Undefined, ok, err
+	}
+	val, ok := constVal.ConstValue.(*mpa.Int)
+	if !ok {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for %s", constVal.Type, name)
+	}
+	if !constVal.Type.Concrete() {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"%s of untyped constant %v", name, val)
+	}
+	bits := int(constVal.Type.Bits)
+
+	var count int
+	for count < bits {
+		var bit int
+		if leading {
+			bit = bits - 1 - count
+		} else {
+			bit = count
+		}
+		if val.Bit(bit) != 0 {
+			break
+		}
+		count++
+	}
+
+	return gen.Constant(int64(count), types.Int32), true, nil
 }
 
 func floorPow2SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
@@ -86,6 +542,53 @@ func floorPow2Eval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 	return gen.Constant(int64(i), types.Undefined), true, nil
 }
 
+func isqrtSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to isqrt")
+	}
+	switch args[0].Type.Type {
+	case types.TInt, types.TUint:
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for isqrt", args[0].Type)
+	}
+
+	zero := gen.Constant(int64(0), types.Undefined)
+	gen.AddConstant(zero)
+
+	v := gen.AnonVal(args[0].Type)
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return circuits.NewISqrt(cc, a, r)
+		}, args[0], zero, v))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func isqrtEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	if len(args) != 1 {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid amount of arguments in call to isqrt")
+	}
+
+	constVal, ok, err := args[0].Eval(env, ctx, gen)
+	if err != nil || !ok {
+		return ssa.Undefined, ok, err
+	}
+	val, ok := constVal.ConstValue.(*mpa.Int)
+	if !ok {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for isqrt", constVal.Type)
+	}
+
+	return gen.Constant(new(mpa.Int).Sqrt(val), constVal.Type), true, nil
+}
+
 func lenSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
 
@@ -124,7 +627,36 @@ func lenEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 			"invalid amount of arguments in call to len")
 	}
 
-	switch arg := args[0].(type) {
+	typeInfo, err := lenArgType(args[0], env, ctx, gen, loc)
+	if err != nil {
+		return ssa.Undefined, false, err
+	}
+	if typeInfo.Type == types.TPtr {
+		typeInfo = *typeInfo.ElementType
+	}
+
+	switch typeInfo.Type {
+	case types.TString:
+		return gen.Constant(int64(typeInfo.Bits/types.ByteBits),
+			types.Undefined), true, nil
+
+	case types.TArray, types.TSlice:
+		return gen.Constant(int64(typeInfo.ArraySize), types.Undefined),
+			true, nil
+
+	default:
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for len", typeInfo)
+	}
+}
+
+// lenArgType resolves the type of the len and cap argument arg. The
+// argument must be a variable reference, an index expression of an
+// array variable, or a constant expression.
+func lenArgType(arg AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (types.Info, error) {
+
+	switch arg := arg.(type) {
 	case *VariableRef:
 		var typeInfo types.Info
 
@@ -133,7 +665,7 @@ func lenEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 			b, ok := env.Get(arg.Name.Package)
 			if ok {
 				if b.Type.Type != types.TStruct {
-					return ssa.Undefined, false, ctx.Errorf(loc,
+					return types.Undefined, ctx.Errorf(loc,
 						"%s undefined", arg.Name)
 				}
 				ok = false
@@ -145,19 +677,19 @@ func lenEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 					}
 				}
 				if !ok {
-					return ssa.Undefined, false, ctx.Errorf(loc,
+					return types.Undefined, ctx.Errorf(loc,
 						"undefined variable '%s'", arg.Name)
 				}
 			} else {
 				// Resolve name from the package.
 				pkg, ok := ctx.Packages[arg.Name.Package]
 				if !ok {
-					return ssa.Undefined, false, ctx.Errorf(loc,
+					return types.Undefined, ctx.Errorf(loc,
 						"package '%s' not found", arg.Name.Package)
 				}
 				b, ok := pkg.Bindings.Get(arg.Name.Name)
 				if !ok {
-					return ssa.Undefined, false, ctx.Errorf(loc,
+					return types.Undefined, ctx.Errorf(loc,
 						"undefined variable '%s'", arg.Name)
 				}
 				typeInfo = b.Type
@@ -165,34 +697,177 @@ func lenEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
 		} else {
 			b, ok := env.Get(arg.Name.Name)
 			if !ok {
-				return ssa.Undefined, false, ctx.Errorf(loc,
+				return types.Undefined, ctx.Errorf(loc,
 					"undefined variable '%s'", arg.Name)
 			}
 			typeInfo = b.Type
 		}
+		return typeInfo, nil
 
+	case *Index:
+		typeInfo, err := lenArgType(arg.Expr, env, ctx, gen, loc)
+		if err != nil {
+			return types.Undefined, err
+		}
 		if typeInfo.Type == types.TPtr {
 			typeInfo = *typeInfo.ElementType
 		}
+		if !typeInfo.Type.Array() {
+			return types.Undefined, ctx.Errorf(loc,
+				"len(%v) is not constant", arg)
+		}
+		return *typeInfo.ElementType, nil
 
-		switch typeInfo.Type {
-		case types.TString:
-			return gen.Constant(int64(typeInfo.Bits/types.ByteBits),
-				types.Undefined), true, nil
+	default:
+		// Constant expressions, such as string literals.
+		constVal, ok, err := arg.Eval(env, ctx, gen)
+		if err != nil {
+			return types.Undefined, err
+		}
+		if !ok {
+			return types.Undefined, ctx.Errorf(loc,
+				"len(%v/%T) is not constant", arg, arg)
+		}
+		return constVal.Type, nil
+	}
+}
 
-		case types.TArray, types.TSlice:
-			return gen.Constant(int64(typeInfo.ArraySize), types.Undefined),
-				true, nil
+func capSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
 
-		default:
-			return ssa.Undefined, false, ctx.Errorf(loc,
-				"invalid argument 1 (type %s) for len", typeInfo)
-		}
+	if len(args) != 1 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to cap")
+	}
+
+	var val types.Size
+	switch args[0].Type.Type {
+	case types.TArray, types.TSlice:
+		val = args[0].Type.Cap()
+
+	case types.TNil:
+		val = 0
+
+	default:
+		return nil, nil, ctx.Errorf(loc, "invalid argument 1 (type %s) for cap",
+			args[0].Type)
+	}
+
+	v := gen.Constant(int64(val), types.Undefined)
+	gen.AddConstant(v)
+
+	return block, []ssa.Value{v}, nil
+}
+
+func capEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	if len(args) != 1 {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"invalid amount of arguments in call to cap")
+	}
+
+	typeInfo, err := lenArgType(args[0], env, ctx, gen, loc)
+	if err != nil {
+		return ssa.Undefined, false, err
+	}
+	if typeInfo.Type == types.TPtr {
+		typeInfo = *typeInfo.ElementType
+	}
+
+	switch typeInfo.Type {
+	case types.TArray, types.TSlice:
+		return gen.Constant(int64(typeInfo.Cap()), types.Undefined),
+			true, nil
 
 	default:
 		return ssa.Undefined, false, ctx.Errorf(loc,
-			"len(%v/%T) is not constant", arg, arg)
+			"invalid argument 1 (type %s) for cap", typeInfo)
+	}
+}
+
+func mulxSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 2 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to mulx")
 	}
+	for idx, arg := range args {
+		switch arg.Type.Type {
+		case types.TInt, types.TUint:
+		default:
+			return nil, nil, ctx.Errorf(loc,
+				"invalid argument %d (type %s) for mulx", idx+1, arg.Type)
+		}
+	}
+
+	// Untyped constant arguments get the type of the other argument.
+	x, err := convertConst(ctx, loc, gen, args[0], args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	y, err := convertConst(ctx, loc, gen, args[1], x)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !x.Type.Equal(y.Type) {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid arguments for mulx (mismatched types %s and %s)",
+			x.Type, y.Type)
+	}
+	if !x.Type.Concrete() {
+		return nil, nil, ctx.Errorf(loc,
+			"unspecified size for arguments (type %s) for mulx", x.Type)
+	}
+	signed := x.Type.Type == types.TInt
+
+	calloc := circuits.NewAllocator()
+	inputs := circuit.IO{
+		circuit.IOArg{
+			Name: "x",
+			Type: x.Type,
+		},
+		circuit.IOArg{
+			Name: "y",
+			Type: y.Type,
+		},
+	}
+	outputs := circuit.IO{
+		circuit.IOArg{
+			Name: "low",
+			Type: x.Type,
+		},
+		circuit.IOArg{
+			Name: "overflow",
+			Type: types.Bool,
+		},
+	}
+	inputWires := calloc.Wires(x.Type.Bits * 2)
+	outputWires := calloc.Wires(x.Type.Bits + 1)
+	for _, w := range outputWires {
+		w.SetOutput(true)
+	}
+
+	cc, err := circuits.NewCompiler(ctx.Params, calloc, inputs, outputs,
+		inputWires, outputWires)
+	if err != nil {
+		return nil, nil, ctx.Errorf(loc, "%s", err)
+	}
+	bits := int(x.Type.Bits)
+	err = circuits.NewOverflowMultiplier(cc, signed, inputWires[:bits],
+		inputWires[bits:], outputWires[:bits], outputWires[bits])
+	if err != nil {
+		return nil, nil, ctx.Errorf(loc, "%s", err)
+	}
+	circ := cc.Compile()
+
+	low := gen.AnonVal(x.Type)
+	overflow := gen.AnonVal(types.Bool)
+	block.AddInstr(ssa.NewCircInstr([]ssa.Value{x, y}, circ,
+		[]ssa.Value{low, overflow}))
+
+	return block, []ssa.Value{low, overflow}, nil
 }
 
 func nativeSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
@@ -250,7 +925,12 @@ func nativeCircuit(name string, block *ssa.Block, ctx *Codegen,
 
 	circ, ok := ctx.Native[fp]
 	if !ok {
-		circ, err = circuit.Parse(fp)
+		prefix := utils.PkgFSSource + "/"
+		if ctx.Params.PkgFS != nil && strings.HasPrefix(fp, prefix) {
+			circ, err = circuit.ParseFS(ctx.Params.PkgFS, fp[len(prefix):])
+		} else {
+			circ, err = circuit.Parse(fp)
+		}
 		if err != nil {
 			return nil, nil, ctx.Errorf(loc, "failed to parse circuit: %s", err)
 		}
@@ -358,6 +1038,467 @@ func panicMessage(args []string) string {
 	return result
 }
 
+func polySSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) < 2 {
+		return nil, nil, ctx.Errorf(loc,
+			"not enough arguments in call to poly")
+	}
+	x := args[0]
+	switch x.Type.Type {
+	case types.TInt, types.TUint:
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for poly", x.Type)
+	}
+	if !x.Type.Concrete() {
+		return nil, nil, ctx.Errorf(loc,
+			"unspecified size for argument 1 (type %s) for poly", x.Type)
+	}
+
+	for idx, arg := range args {
+		switch arg.Type.Type {
+		case types.TInt, types.TUint:
+		default:
+			return nil, nil, ctx.Errorf(loc,
+				"invalid argument %d (type %s) for poly", idx+1, arg.Type)
+		}
+		if !arg.Const && !arg.Type.Equal(x.Type) {
+			return nil, nil, ctx.Errorf(loc,
+				"invalid argument %d for poly: mismatched types %s and %s",
+				idx+1, arg.Type, x.Type)
+		}
+	}
+
+	// Fold constant polynomials.
+	v, ok, err := polyFold(ctx, gen, args, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		gen.AddConstant(v)
+		return block, []ssa.Value{v}, nil
+	}
+
+	calloc := circuits.NewAllocator()
+	var inputs circuit.IO
+	var inputWires []*circuits.Wire
+	var coeffs [][]*circuits.Wire
+	var signed []bool
+
+	for idx, arg := range args {
+		inputs = append(inputs, circuit.IOArg{
+			Name: fmt.Sprintf("arg%d", idx),
+			Type: arg.Type,
+		})
+		wires := calloc.Wires(arg.Type.Bits)
+		inputWires = append(inputWires, wires...)
+		if idx > 0 {
+			coeffs = append(coeffs, wires)
+			signed = append(signed, arg.Type.Type == types.TInt)
+		}
+	}
+	outputs := circuit.IO{
+		circuit.IOArg{
+			Name: "result",
+			Type: x.Type,
+		},
+	}
+	outputWires := calloc.Wires(x.Type.Bits)
+	for _, w := range outputWires {
+		w.SetOutput(true)
+	}
+
+	cc, err := circuits.NewCompiler(ctx.Params, calloc, inputs, outputs,
+		inputWires, outputWires)
+	if err != nil {
+		return nil, nil, ctx.Errorf(loc, "%s", err)
+	}
+	err = circuits.NewHorner(cc, coeffs, signed, inputWires[:x.Type.Bits],
+		x.Type.Type == types.TInt, 0, outputWires)
+	if err != nil {
+		return nil, nil, ctx.Errorf(loc, "%s", err)
+	}
+	circ := cc.Compile()
+
+	v = gen.AnonVal(x.Type)
+	block.AddInstr(ssa.NewCircInstr(args, circ, []ssa.Value{v}))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func polyEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	if len(args) < 2 {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"not enough arguments in call to poly")
+	}
+	var vals []ssa.Value
+	for _, arg := range args {
+		constVal, ok, err := arg.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			return ssa.Undefined, ok, err
+		}
+		vals = append(vals, constVal)
+	}
+	return polyFold(ctx, gen, vals, loc)
+}
+
+// polyFold evaluates the polynomial poly(args[0], args[1], ...) if
+// all arguments are integer constants. The result is computed like
+// in the Horner circuit: the coefficients are interpreted in their
+// types and the result is reduced modulo the type size of args[0].
+func polyFold(ctx *Codegen, gen *ssa.Generator, args []ssa.Value,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	x := args[0]
+	if !x.Type.Concrete() || x.Type.Bits > 64 {
+		// Leave the type checks to the SSA generation.
+		return ssa.Undefined, false, nil
+	}
+	var vals []*big.Int
+	for _, arg := range args {
+		if !arg.Const {
+			return ssa.Undefined, false, nil
+		}
+		val, ok := arg.ConstValue.(*mpa.Int)
+		if !ok || arg.Type.Bits > 64 {
+			return ssa.Undefined, false, nil
+		}
+		switch arg.Type.Type {
+		case types.TInt, types.TUint:
+		default:
+			return ssa.Undefined, false, nil
+		}
+		// Interpret the value's bit pattern in the type.
+		v := big.NewInt(val.Int64())
+		if arg.Type.Bits > 0 {
+			mod := new(big.Int).Lsh(big.NewInt(1), uint(arg.Type.Bits))
+			v.Mod(v, mod)
+			if arg.Type.Type == types.TInt &&
+				v.Bit(int(arg.Type.Bits-1)) != 0 {
+				v.Sub(v, mod)
+			}
+		}
+		vals = append(vals, v)
+	}
+
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(x.Type.Bits))
+	result := new(big.Int).Set(vals[len(vals)-1])
+	for i := len(vals) - 2; i > 0; i-- {
+		result.Mul(result, vals[0])
+		result.Add(result, vals[i])
+		result.Mod(result, mod)
+	}
+	result.Mod(result, mod)
+	if x.Type.Type == types.TInt && result.Bit(int(x.Type.Bits-1)) != 0 {
+		result.Sub(result, mod)
+	}
+	v, ok := gen.IntConstant(result, x.Type)
+	if !ok {
+		return ssa.Undefined, false, ctx.Errorf(loc,
+			"constant %v overflows %v", result, x.Type)
+	}
+	return v, true, nil
+}
+
+func revealIfSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 2 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to revealIf")
+	}
+	cond := args[0]
+	value := args[1]
+
+	if cond.Type.Type != types.TBool {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for revealIf", cond.Type)
+	}
+	if !value.Type.Concrete() || value.Type.Bits == 0 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 2 (type %s) for revealIf", value.Type)
+	}
+
+	// The sentinel is the zero value of the value's type.
+	init, err := initValue(value.Type)
+	if err != nil {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 2 (type %s) for revealIf", value.Type)
+	}
+	sentinel := gen.Constant(init, value.Type)
+	gen.AddConstant(sentinel)
+
+	if cond.Const {
+		b, ok := cond.ConstValue.(bool)
+		if !ok {
+			return nil, nil, ctx.Errorf(loc,
+				"invalid argument 1 (%v) for revealIf", cond)
+		}
+		if b {
+			return block, []ssa.Value{value}, nil
+		}
+		return block, []ssa.Value{sentinel}, nil
+	}
+
+	v := mux(block, gen, cond, value, sentinel, value.Type)
+
+	return block, []ssa.Value{v}, nil
+}
+
+// mux selects the value t if cond is set and f otherwise. The result
+// has the type typ.
+func mux(block *ssa.Block, gen *ssa.Generator, cond, t, f ssa.Value,
+	typ types.Info) ssa.Value {
+
+	// The constants can have more wires than their type. Move them
+	// into values of the result type for the MUX.
+	if t.Const {
+		v := gen.AnonVal(typ)
+		block.AddInstr(ssa.NewMovInstr(t, v))
+		t = v
+	}
+	if f.Const {
+		v := gen.AnonVal(typ)
+		block.AddInstr(ssa.NewMovInstr(f, v))
+		f = v
+	}
+	v := gen.AnonVal(typ)
+	block.AddInstr(ssa.NewPhiInstr(cond, t, f, v))
+
+	return v
+}
+
+func selectSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	cond, t, f, typ, err := condArgs("select", ctx, gen, args, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cond.Const {
+		if cond.ConstValue.(bool) {
+			return block, []ssa.Value{t}, nil
+		}
+		return block, []ssa.Value{f}, nil
+	}
+
+	v := mux(block, gen, cond, t, f, typ)
+
+	return block, []ssa.Value{v}, nil
+}
+
+func cswapSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	cond, a, b, typ, err := condArgs("cswap", ctx, gen, args, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cond.Const {
+		if cond.ConstValue.(bool) {
+			return block, []ssa.Value{b, a}, nil
+		}
+		return block, []ssa.Value{a, b}, nil
+	}
+
+	v0 := mux(block, gen, cond, b, a, typ)
+	v1 := mux(block, gen, cond, a, b, typ)
+
+	return block, []ssa.Value{v0, v1}, nil
+}
+
+// condArgs checks the arguments of the builtin functions that take a
+// bool condition and two values of the same type. The function
+// returns the condition, the values, and the type of the result.
+func condArgs(name string, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (cond, a, b ssa.Value,
+	typ types.Info, err error) {
+
+	if len(args) != 3 {
+		err = ctx.Errorf(loc, "invalid amount of arguments in call to %s",
+			name)
+		return
+	}
+	cond = args[0]
+	if cond.Type.Type != types.TBool {
+		err = ctx.Errorf(loc, "invalid argument 1 (type %s) for %s",
+			cond.Type, name)
+		return
+	}
+	if cond.Const {
+		if _, ok := cond.ConstValue.(bool); !ok {
+			err = ctx.Errorf(loc, "invalid argument 1 (%v) for %s", cond, name)
+			return
+		}
+	}
+
+	// Untyped constant arguments get the type of the other argument.
+	a, err = convertConst(ctx, loc, gen, args[1], args[2])
+	if err != nil {
+		return
+	}
+	b, err = convertConst(ctx, loc, gen, args[2], a)
+	if err != nil {
+		return
+	}
+	for idx, arg := range []ssa.Value{a, b} {
+		if !arg.Type.Concrete() || arg.Type.Bits == 0 ||
+			arg.Type.Type == types.TPtr || arg.Type.Type == types.TFunc {
+			err = ctx.Errorf(loc, "invalid argument %d (type %s) for %s",
+				idx+2, arg.Type, name)
+			return
+		}
+	}
+	// The named types must match in addition to their layout.
+	if !a.Type.Equal(b.Type) ||
+		a.Type.ID != 0 && b.Type.ID != 0 && a.Type.ID != b.Type.ID {
+		err = ctx.Errorf(loc,
+			"invalid arguments for %s (mismatched types %s and %s)",
+			name, ctx.typeName(a.Type), ctx.typeName(b.Type))
+		return
+	}
+	typ = a.Type
+	if a.Const && !b.Const {
+		typ = b.Type
+	}
+	return
+}
+
+func shuffleSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	if len(args) != 2 {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid amount of arguments in call to shuffle")
+	}
+	arr := args[0]
+	control := args[1]
+
+	if !arr.Type.Type.Array() {
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 1 (type %s) for shuffle", arr.Type)
+	}
+	switch control.Type.Type {
+	case types.TInt, types.TUint, types.TBool:
+	default:
+		return nil, nil, ctx.Errorf(loc,
+			"invalid argument 2 (type %s) for shuffle", control.Type)
+	}
+	elemBits := int(arr.Type.ElementType.Bits)
+	switches := circuits.ShuffleSwitches(int(arr.Type.ArraySize))
+	if !control.Const && int(control.Type.Bits) < switches {
+		return nil, nil, ctx.Errorf(loc,
+			"shuffle control has %d bits, need %d bits for %d elements",
+			control.Type.Bits, switches, arr.Type.ArraySize)
+	}
+
+	v := gen.AnonVal(arr.Type)
+	block.AddInstr(ssa.NewBuiltinInstr(
+		func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+			return circuits.NewObliviousShuffle(cc, elemBits, a, b, r)
+		}, arr, control, v))
+
+	return block, []ssa.Value{v}, nil
+}
+
+func sprintfSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
+
+	result, err := sprintf(ctx, loc, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := gen.Constant(result, types.Undefined)
+	gen.AddConstant(v)
+
+	return block, []ssa.Value{v}, nil
+}
+
+func sprintfEval(args []AST, env *Env, ctx *Codegen, gen *ssa.Generator,
+	loc utils.Point) (ssa.Value, bool, error) {
+
+	var values []ssa.Value
+	for _, arg := range args {
+		constVal, ok, err := arg.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			// Report non-constant arguments in the SSA generation.
+			return ssa.Undefined, ok, err
+		}
+		values = append(values, constVal)
+	}
+	result, err := sprintf(ctx, loc, values)
+	if err != nil {
+		return ssa.Undefined, false, err
+	}
+	return gen.Constant(result, types.Undefined), true, nil
+}
+
+// sprintf formats the constant arguments according to the format
+// specifier in the first argument. The formatting is done at compile
+// time so all arguments must be constants.
+func sprintf(ctx *Codegen, loc utils.Point, args []ssa.Value) (
+	string, error) {
+
+	if len(args) < 1 {
+		return "", ctx.Errorf(loc,
+			"invalid amount of arguments in call to sprintf")
+	}
+	var format string
+	var values []interface{}
+	for idx, arg := range args {
+		if !arg.Const {
+			return "", ctx.Errorf(loc,
+				"argument %d for sprintf is not constant", idx+1)
+		}
+		var val interface{}
+		switch cv := arg.ConstValue.(type) {
+		case *mpa.Int:
+			val = constIntValue(cv, arg.Type)
+		case bool, string:
+			val = cv
+		}
+		if idx == 0 {
+			str, ok := val.(string)
+			if !ok {
+				return "", ctx.Errorf(loc,
+					"invalid argument 1 (type %s) for sprintf", arg.Type)
+			}
+			format = str
+			continue
+		}
+		if val == nil {
+			return "", ctx.Errorf(loc,
+				"invalid argument %d (type %s) for sprintf", idx+1, arg.Type)
+		}
+		values = append(values, val)
+	}
+	result := fmt.Sprintf(format, values...)
+	if strings.Contains(result, "%!") {
+		return "", ctx.Errorf(loc, "invalid format for sprintf: %s", result)
+	}
+	return result, nil
+}
+
+// constIntValue returns the value of the constant integer in its
+// type. The signed values are interpreted as two's complement numbers.
+func constIntValue(val *mpa.Int, ti types.Info) *big.Int {
+	bits := int(ti.Bits)
+	if bits == 0 {
+		bits = val.TypeSize()
+	}
+	result, _ := new(big.Int).SetString(val.String(), 10)
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	result.Mod(result, mod)
+	if ti.Type == types.TInt && result.Bit(bits-1) != 0 {
+		result.Sub(result, mod)
+	}
+	return result
+}
+
 func sizeSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 	args []ssa.Value, loc utils.Point) (*ssa.Block, []ssa.Value, error) {
 
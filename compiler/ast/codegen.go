@@ -9,11 +9,13 @@
 package ast
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
 
 	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/mpa"
 	"github.com/markkurossi/mpc/compiler/ssa"
 	"github.com/markkurossi/mpc/compiler/utils"
 	"github.com/markkurossi/mpc/types"
@@ -22,6 +24,7 @@ import (
 // Codegen implements compilation stack.
 type Codegen struct {
 	logger         *utils.Logger
+	Context        context.Context
 	Params         *utils.Params
 	Verbose        bool
 	Package        *Package
@@ -30,7 +33,19 @@ type Codegen struct {
 	Stack          []Compilation
 	Types          map[types.ID]*TypeInfo
 	Native         map[string]*circuit.Circuit
+	Defines        map[string]interface{}
 	HeapID         int
+	EvalStats      EvalStats
+	InstrStats     InstrStats
+	branchConds    []branchCond
+	unreachable    map[utils.Point]bool
+	largeLoops     map[utils.Point]bool
+	largeShifts    map[utils.Point]bool
+	evalCache      map[evalKey]evalResult
+	evalVersion    uint64
+	instrMark      int
+	subcircuits    map[string]*subcircuit
+	sideEffects    int
 }
 
 // NewCodegen creates a new compilation.
@@ -40,6 +55,7 @@ func NewCodegen(logger *utils.Logger, pkg *Package,
 
 	return &Codegen{
 		logger:         logger,
+		Context:        context.Background(),
 		Params:         params,
 		Verbose:        params.Verbose,
 		Package:        pkg,
@@ -47,9 +63,63 @@ func NewCodegen(logger *utils.Logger, pkg *Package,
 		MainInputSizes: mainInputSizes,
 		Types:          make(map[types.ID]*TypeInfo),
 		Native:         make(map[string]*circuit.Circuit),
+		InstrStats:     make(InstrStats),
+		unreachable:    make(map[utils.Point]bool),
+		largeLoops:     make(map[utils.Point]bool),
+		largeShifts:    make(map[utils.Point]bool),
+		evalCache:      make(map[evalKey]evalResult),
+		subcircuits:    make(map[string]*subcircuit),
 	}
 }
 
+// Define returns the compile-time constant definition for the
+// variable reference ref.
+func (ctx *Codegen) Define(gen *ssa.Generator, ref *VariableRef) (
+	ssa.Value, bool) {
+
+	if len(ref.Name.Package) > 0 {
+		return ssa.Undefined, false
+	}
+	val, ok := ctx.Defines[ref.Name.Name]
+	if !ok {
+		return ssa.Undefined, false
+	}
+	return gen.Constant(val, types.Undefined), true
+}
+
+// channel resolves the channel of the channel operation op. The
+// expression expr must evaluate to a channel value.
+func (ctx *Codegen) channel(block *ssa.Block, gen *ssa.Generator, op,
+	expr AST) (*ssa.Channel, error) {
+
+	if len(ctx.branchConds) > 0 {
+		return nil, ctx.Errorf(op,
+			"channel operation %s in non-constant conditional branch", op)
+	}
+	v, ok, err := expr.Eval(NewEnv(block), ctx, gen)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ctx.Errorf(expr, "invalid operation: %s is not a channel",
+			expr)
+	}
+	ch, ok := v.ConstValue.(*ssa.Channel)
+	if !ok {
+		return nil, ctx.Errorf(expr,
+			"invalid operation: %s (type %v) is not a channel", expr, v.Type)
+	}
+	// Channel operations are compile-time side effects.
+	ctx.sideEffects++
+	return ch, nil
+}
+
+// Canceled returns the context's error if the compilation context is
+// canceled or its deadline has passed. Otherwise it returns nil.
+func (ctx *Codegen) Canceled() error {
+	return ctx.Context.Err()
+}
+
 func (ctx *Codegen) errorLoc(err error) error {
 	if !ctx.Params.MPCLCErrorLoc {
 		return err
@@ -86,6 +156,50 @@ func (ctx *Codegen) Warningf(locator utils.Locator, format string,
 	ctx.logger.Warningf(locator.Location(), format, a...)
 }
 
+// Unreachable logs an unreachable code warning for the argument
+// statement. Each location is reported only once even if the
+// containing function is instantiated multiple times.
+func (ctx *Codegen) Unreachable(locator utils.Locator) {
+	loc := locator.Location()
+	if ctx.unreachable[loc] {
+		return
+	}
+	ctx.unreachable[loc] = true
+	ctx.Warningf(loc, "unreachable code")
+}
+
+// LargeLoop logs a warning about a for-loop that unrolls count
+// times. Each location is reported only once.
+func (ctx *Codegen) LargeLoop(locator utils.Locator, count int) {
+	loc := locator.Location()
+	if ctx.largeLoops[loc] {
+		return
+	}
+	ctx.largeLoops[loc] = true
+	if count > ctx.Params.MaxLoopUnroll {
+		ctx.Warningf(loc, "for-loop unrolls more than %d times, "+
+			"exceeding the unroll limit", ctx.Params.MaxLoopUnroll)
+	} else {
+		ctx.Warningf(loc, "for-loop unrolls %d times (warning limit %d)",
+			count, ctx.Params.LoopUnrollWarn)
+	}
+}
+
+// LargeShift logs a warning about a shift operation whose constant
+// shift count is not smaller than the width of the shifted type. Each
+// location is reported only once.
+func (ctx *Codegen) LargeShift(locator utils.Locator, count *mpa.Int,
+	t types.Info, result string) {
+
+	loc := locator.Location()
+	if ctx.largeShifts[loc] {
+		return
+	}
+	ctx.largeShifts[loc] = true
+	ctx.Warningf(loc, "shift count %s >= width of %s: the result is %s",
+		count, t, result)
+}
+
 // DefineType defines the argument type and assigns it an unique type
 // ID.
 func (ctx *Codegen) DefineType(t *TypeInfo) types.ID {
@@ -94,11 +208,31 @@ func (ctx *Codegen) DefineType(t *TypeInfo) types.ID {
 	return id
 }
 
+// typeName returns the name of the argument type. The named types
+// are returned with their type names.
+func (ctx *Codegen) typeName(t types.Info) string {
+	if t.ID != 0 {
+		info, ok := ctx.Types[t.ID]
+		if ok && len(info.TypeName) > 0 {
+			return info.TypeName
+		}
+	}
+	return t.String()
+}
+
 // LookupFunc resolves the named function from the context.
 func (ctx *Codegen) LookupFunc(block *ssa.Block, ref *VariableRef) (
 	*Func, error) {
 
-	// First, check method calls.
+	// Function values bound to variables.
+	if len(ref.Name.Package) == 0 {
+		b, ok := block.Bindings.Get(ref.Name.Name)
+		if ok && b.Type.Type == types.TFunc {
+			return ctx.boundFunc(ref, b)
+		}
+	}
+
+	// Check method calls.
 	if len(ref.Name.Package) > 0 {
 		// Check if package name is bound to a value.
 		var b ssa.Binding
@@ -147,6 +281,44 @@ func (ctx *Codegen) LookupFunc(block *ssa.Block, ref *VariableRef) (
 	return called, nil
 }
 
+// boundFunc returns the function of the function value binding b.
+func (ctx *Codegen) boundFunc(ref *VariableRef, b ssa.Binding) (
+	*Func, error) {
+
+	v, ok := b.Bound.(*ssa.Value)
+	if ok {
+		fn, ok := v.ConstValue.(*ssa.Function)
+		if ok {
+			return fn.Func.(*Func), nil
+		}
+	}
+	return nil, ctx.Errorf(ref, "function value %s is not constant", ref)
+}
+
+// funcValue resolves the function reference ref as a function
+// value. The function values are compile-time constants and the calls
+// through them are inlined at the call sites.
+func (ctx *Codegen) funcValue(gen *ssa.Generator, ref *VariableRef) (
+	ssa.Value, bool) {
+
+	var pkgName string
+	if len(ref.Name.Package) > 0 {
+		pkgName = ref.Name.Package
+	} else {
+		pkgName = ref.Name.Defined
+	}
+	pkg, ok := ctx.Packages[pkgName]
+	if !ok {
+		return ssa.Undefined, false
+	}
+	f, ok := pkg.Functions[ref.Name.Name]
+	if !ok {
+		return ssa.Undefined, false
+	}
+	return gen.Constant(gen.NewFunction(ref.Name.String(), f),
+		types.Undefined), true
+}
+
 // Func returns the current function in the current compilation.
 func (ctx *Codegen) Func() *Func {
 	if len(ctx.Stack) == 0 {
@@ -168,10 +340,11 @@ func (ctx *Codegen) PushCompilation(start, ret, caller *ssa.Block,
 	called *Func) {
 
 	ctx.Stack = append(ctx.Stack, Compilation{
-		Start:  start,
-		Return: ret,
-		Caller: caller,
-		Called: called,
+		Start:      start,
+		Return:     ret,
+		Caller:     caller,
+		Called:     called,
+		branchBase: len(ctx.branchConds),
 	})
 }
 
@@ -216,4 +389,41 @@ type Compilation struct {
 	Called *Func
 	// XXX Bindings
 	// XXX Parent scope.
+
+	branchBase int
+	defers     []deferred
+	deferring  bool
+}
+
+// deferred defines a deferred statement. The conditional deferred
+// statements are executed if their condition value is true. The
+// captured bindings hold the values of the variables that the
+// statement reads but does not modify, evaluated at the defer
+// statement.
+type deferred struct {
+	stmt        AST
+	cond        ssa.Value
+	conditional bool
+	captured    []ssa.Binding
+}
+
+// branchCond defines the condition of a non-constant conditional
+// branch. The negate is true for the else branch.
+type branchCond struct {
+	value  ssa.Value
+	negate bool
+}
+
+// pushBranch pushes the condition of a non-constant conditional
+// branch to the branch stack.
+func (ctx *Codegen) pushBranch(value ssa.Value, negate bool) {
+	ctx.branchConds = append(ctx.branchConds, branchCond{
+		value:  value,
+		negate: negate,
+	})
+}
+
+// popBranch pops the topmost branch condition from the branch stack.
+func (ctx *Codegen) popBranch() {
+	ctx.branchConds = ctx.branchConds[:len(ctx.branchConds)-1]
 }
@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ast
+
+import (
+	"sort"
+
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// pkgDecl is a package-level constant or type declaration.
+type pkgDecl struct {
+	name     string
+	constant *ConstantDef
+	typ      *TypeInfo
+}
+
+// defineDecls defines the package constants and types in their
+// dependency order so that constant expressions and type definitions
+// can refer to constants and types that are declared later in the
+// package. Declarations without mutual dependencies are defined in
+// their declaration order.
+func (pkg *Package) defineDecls(ctx *Codegen, gen *ssa.Generator) error {
+	var decls []pkgDecl
+	for _, def := range pkg.Constants {
+		decls = append(decls, pkgDecl{
+			name:     def.Name,
+			constant: def,
+		})
+	}
+	for _, def := range pkg.Types {
+		decls = append(decls, pkgDecl{
+			name: def.TypeName,
+			typ:  def,
+		})
+	}
+	byName := make(map[string]int)
+	for idx, decl := range decls {
+		if _, ok := byName[decl.name]; !ok {
+			byName[decl.name] = idx
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		defined
+	)
+	state := make([]int, len(decls))
+
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		decl := decls[idx]
+		var locator utils.Locator = decl.typ
+		if decl.constant != nil {
+			locator = decl.constant
+		}
+		switch state[idx] {
+		case defined:
+			return nil
+		case visiting:
+			return ctx.Errorf(locator, "initialization cycle: %s",
+				decl.name)
+		}
+		state[idx] = visiting
+
+		refs := make(map[string]bool)
+		if decl.constant != nil {
+			decl.constant.Type.refs(refs)
+			astRefs(decl.constant.Init, refs)
+		} else {
+			decl.typ.ElementType.refs(refs)
+			astRefs(decl.typ.ArrayLength, refs)
+			for _, field := range decl.typ.StructFields {
+				field.Type.refs(refs)
+			}
+			decl.typ.AliasType.refs(refs)
+		}
+		// Visit the dependencies in their declaration order.
+		var deps []int
+		for name := range refs {
+			dep, ok := byName[name]
+			if ok {
+				deps = append(deps, dep)
+			}
+		}
+		sort.Ints(deps)
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[idx] = defined
+
+		if decl.constant != nil {
+			return pkg.defineConstant(decl.constant, ctx, gen)
+		}
+		return pkg.defineType(decl.typ, ctx, gen)
+	}
+
+	for idx := range decls {
+		if err := visit(idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refs collects the unqualified names the type info refers to.
+func (ti *TypeInfo) refs(names map[string]bool) {
+	if ti == nil {
+		return
+	}
+	if ti.Type == TypeName && !ti.Name.Qualified() {
+		names[ti.Name.Name] = true
+	}
+	ti.ElementType.refs(names)
+	astRefs(ti.ArrayLength, names)
+	for _, field := range ti.StructFields {
+		field.Type.refs(names)
+	}
+	ti.AliasType.refs(names)
+	for _, arg := range ti.FuncArgs {
+		arg.refs(names)
+	}
+	for _, ret := range ti.FuncReturn {
+		ret.refs(names)
+	}
+}
+
+// astRefs collects the unqualified names the expression refers to.
+func astRefs(ast AST, names map[string]bool) {
+	switch ast := ast.(type) {
+	case *VariableRef:
+		if !ast.Name.Qualified() {
+			names[ast.Name.Name] = true
+		}
+	case *Binary:
+		astRefs(ast.Left, names)
+		astRefs(ast.Right, names)
+	case *Unary:
+		astRefs(ast.Expr, names)
+	case *Call:
+		astRefs(ast.Ref, names)
+		for _, expr := range ast.Exprs {
+			astRefs(expr, names)
+		}
+	case *ArrayCast:
+		ast.TypeInfo.refs(names)
+		astRefs(ast.Expr, names)
+	case *Slice:
+		astRefs(ast.Expr, names)
+		astRefs(ast.From, names)
+		astRefs(ast.To, names)
+		astRefs(ast.Max, names)
+	case *Index:
+		astRefs(ast.Expr, names)
+		astRefs(ast.Index, names)
+	case *CompositeLit:
+		ast.Type.refs(names)
+		for _, e := range ast.Value {
+			astRefs(e.Key, names)
+			astRefs(e.Element, names)
+		}
+	case *Make:
+		ast.Type.refs(names)
+		for _, expr := range ast.Exprs {
+			astRefs(expr, names)
+		}
+	}
+}
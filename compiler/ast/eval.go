@@ -9,6 +9,7 @@ package ast
 import (
 	"fmt"
 	"math"
+	"math/big"
 
 	"github.com/markkurossi/mpc/compiler/mpa"
 	"github.com/markkurossi/mpc/compiler/ssa"
@@ -143,9 +144,19 @@ func (ast *Call) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	if ok {
 		return ssa.Undefined, false, nil
 	}
+	// Calls through function values are not constant.
+	if len(ast.Ref.Name.Package) == 0 {
+		b, ok := env.Get(ast.Ref.Name.Name)
+		if ok && b.Type.Type == types.TFunc {
+			return ssa.Undefined, false, nil
+		}
+	}
 	// Check builtin functions.
 	bi, ok := builtins[ast.Ref.Name.Name]
-	if ok && bi.Eval != nil {
+	if ok {
+		if bi.Eval == nil {
+			return ssa.Undefined, false, nil
+		}
 		return bi.Eval(ast.Exprs, env, ctx, gen, ast.Location())
 	}
 
@@ -197,6 +208,23 @@ func (ast *Call) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 			return ssa.Undefined, false,
 				ctx.Errorf(ast.Ref, "casting %T not supported", constVal.Type)
 		}
+
+	case types.TFixed:
+		if !constVal.IntegerLike() {
+			return ssa.Undefined, false, nil
+		}
+		val, ok := constVal.ConstValue.(*mpa.Int)
+		if !ok || val.TypeSize() > 64 {
+			return ssa.Undefined, false, nil
+		}
+		rep := big.NewInt(val.Int64())
+		rep.Lsh(rep, uint(typeInfo.FracBits))
+		v, ok := gen.IntConstant(rep, typeInfo)
+		if !ok {
+			return ssa.Undefined, false, ctx.Errorf(ast.Exprs[0],
+				"constant %v overflows %v", val, typeInfo)
+		}
+		return v, true, nil
 	}
 
 	return ssa.Undefined, false, nil
@@ -210,6 +238,9 @@ func (ast *ArrayCast) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return ssa.Undefined, false, err
 	}
+	if typeInfo.Type == types.TStruct {
+		return ssa.Undefined, false, nil
+	}
 	if !typeInfo.Type.Array() {
 		return ssa.Undefined, false,
 			ctx.Errorf(ast.Expr, "array cast to non-array type %v", typeInfo)
@@ -268,6 +299,12 @@ func (ast *Return) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	return ssa.Undefined, false, nil
 }
 
+// Eval implements the compiler.ast.AST.Eval for defer statements.
+func (ast *Defer) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	ssa.Value, bool, error) {
+	return ssa.Undefined, false, nil
+}
+
 // Eval implements the compiler.ast.AST.Eval for for statements.
 func (ast *For) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
@@ -282,6 +319,13 @@ func (ast *ForRange) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 
 // Eval implements the compiler.ast.AST.Eval for binary expressions.
 func (ast *Binary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	ssa.Value, bool, error) {
+	return ctx.cachedEval(ast, env, func() (ssa.Value, bool, error) {
+		return ast.eval(env, ctx, gen)
+	})
+}
+
+func (ast *Binary) eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
 	l, ok, err := ast.Left.Eval(env, ctx, gen)
 	if err != nil || !ok {
@@ -303,6 +347,15 @@ func (ast *Binary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return ssa.Undefined, false, err
 	}
+	// The constant values keep the types of their definitions when
+	// they are passed to functions so their widths are not checked.
+	if err := ast.checkShift(ctx, l, r, false); err != nil {
+		return ssa.Undefined, false, err
+	}
+	if l.Type.Type == types.TFixed || r.Type.Type == types.TFixed {
+		// Fixed-point operations are not folded.
+		return ssa.Undefined, false, nil
+	}
 
 	switch lval := l.ConstValue.(type) {
 	case bool:
@@ -363,17 +416,23 @@ func (ast *Binary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 			return gen.Constant(mpa.New(rt.Bits).Sub(lval, rval), rt),
 				true, nil
 		case BinaryEq:
-			return gen.Constant(lval.Cmp(rval) == 0, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) == 0, types.Bool),
+				true, nil
 		case BinaryNeq:
-			return gen.Constant(lval.Cmp(rval) != 0, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) != 0, types.Bool),
+				true, nil
 		case BinaryLt:
-			return gen.Constant(lval.Cmp(rval) == -1, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) == -1, types.Bool),
+				true, nil
 		case BinaryLe:
-			return gen.Constant(lval.Cmp(rval) != 1, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) != 1, types.Bool),
+				true, nil
 		case BinaryGt:
-			return gen.Constant(lval.Cmp(rval) == 1, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) == 1, types.Bool),
+				true, nil
 		case BinaryGe:
-			return gen.Constant(lval.Cmp(rval) != -1, types.Bool), true, nil
+			return gen.Constant(compareInt(l, r, lval, rval) != -1, types.Bool),
+				true, nil
 		}
 
 	case string:
@@ -385,6 +444,30 @@ func (ast *Binary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 		switch ast.Op {
 		case BinaryAdd:
 			return gen.Constant(lval+rval, types.Undefined), true, nil
+		case BinaryEq:
+			return gen.Constant(lval == rval, types.Bool), true, nil
+		case BinaryNeq:
+			return gen.Constant(lval != rval, types.Bool), true, nil
+		}
+
+	case []interface{}:
+		switch ast.Op {
+		case BinaryEq, BinaryNeq:
+			if !l.Type.Equal(r.Type) && !(l.Type.Type.Array() &&
+				r.Type.Type.Array() && l.Type.ArraySize == r.Type.ArraySize &&
+				l.Type.ElementType.Equal(*r.Type.ElementType)) {
+				return ssa.Undefined, false, ctx.Errorf(ast,
+					"invalid operation: %s %s %s (mismatched types %s and %s)",
+					ast.Left, ast.Op, ast.Right, l.Type, r.Type)
+			}
+			eq, err := constEqual(lval, r.ConstValue)
+			if err != nil {
+				return ssa.Undefined, false, ctx.Errorf(ast, "%s", err)
+			}
+			if ast.Op == BinaryNeq {
+				eq = !eq
+			}
+			return gen.Constant(eq, types.Bool), true, nil
 		}
 	}
 
@@ -393,9 +476,80 @@ func (ast *Binary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 		ast.Op, l, l.Type)
 }
 
+// constEqual tests if the constant values a and b are equal. The
+// composite values are compared element-wise.
+func constEqual(a, b interface{}) (bool, error) {
+	if v, ok := a.(ssa.Value); ok {
+		a = v.ConstValue
+	}
+	if v, ok := b.(ssa.Value); ok {
+		b = v.ConstValue
+	}
+	switch av := a.(type) {
+	case bool:
+		bv, ok := b.(bool)
+		if ok {
+			return av == bv, nil
+		}
+
+	case int64:
+		switch bv := b.(type) {
+		case int64:
+			return av == bv, nil
+		case *mpa.Int:
+			return bv.Cmp(mpa.NewInt(av, 64)) == 0, nil
+		}
+
+	case *mpa.Int:
+		switch bv := b.(type) {
+		case int64:
+			return av.Cmp(mpa.NewInt(bv, 64)) == 0, nil
+		case *mpa.Int:
+			return av.Cmp(bv) == 0, nil
+		}
+
+	case string:
+		bv, ok := b.(string)
+		if ok {
+			return av == bv, nil
+		}
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if len(av) != len(bv) {
+			return false, fmt.Errorf("length mismatch: %d != %d",
+				len(av), len(bv))
+		}
+		for i := range av {
+			eq, err := constEqual(av[i], bv[i])
+			if err != nil || !eq {
+				return eq, err
+			}
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid comparison: %v (%T) == %v (%T)",
+		a, a, b, b)
+}
+
 // Eval implements the compiler.ast.AST.Eval for unary expressions.
 func (ast *Unary) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
+	return ctx.cachedEval(ast, env, func() (ssa.Value, bool, error) {
+		return ast.eval(env, ctx, gen)
+	})
+}
+
+func (ast *Unary) eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	ssa.Value, bool, error) {
+	if ast.Type == UnarySend {
+		// Channel receive modifies the channel so it is evaluated
+		// in Unary.SSA.
+		return ssa.Undefined, false, nil
+	}
 	expr, ok, err := ast.Expr.Eval(env, ctx, gen)
 	if err != nil || !ok {
 		return ssa.Undefined, ok, err
@@ -449,10 +603,28 @@ func (ast *Slice) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 			return ssa.Undefined, false, ctx.Errorf(ast.To, err.Error())
 		}
 	}
+	maxIndex := -1
+	if ast.Max != nil {
+		val, ok, err := ast.Max.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			return ssa.Undefined, ok, err
+		}
+		maxIndex, err = intVal(val)
+		if err != nil {
+			return ssa.Undefined, false, ctx.Errorf(ast.Max, err.Error())
+		}
+	}
 	if to < from {
 		return ssa.Undefined, false, ctx.Errorf(ast.Expr,
 			"invalid slice range %d:%d", from, to)
 	}
+	if expr.IntegerLike() {
+		if ast.Max != nil {
+			return ssa.Undefined, false, ctx.Errorf(ast,
+				"invalid operation: 3-index slice of %s", expr.Type)
+		}
+		return ast.evalBits(ctx, gen, expr, from, to)
+	}
 	if !expr.Type.Type.Array() {
 		return ssa.Undefined, false, ctx.Errorf(ast.Expr,
 			"invalid operation: cannot slice %v (%v)", expr, expr.Type)
@@ -469,6 +641,17 @@ func (ast *Slice) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 			"slice bounds out of range [%d:%d] in slice of length %v",
 			from, to, expr.Type.ArraySize)
 	}
+	if maxIndex >= 0 &&
+		(to > maxIndex || maxIndex > int(expr.Type.ArraySize)) {
+		return ssa.Undefined, false, ctx.Errorf(ast,
+			"slice bounds out of range [%d:%d:%d] with capacity %d",
+			from, to, maxIndex, expr.Type.ArraySize)
+	}
+	if maxIndex > to {
+		// The constant values do not have spare capacity. The
+		// Slice.SSA creates the slice with capacity maxIndex-from.
+		return ssa.Undefined, false, nil
+	}
 	numElements := to - from
 
 	switch val := arr.(type) {
@@ -494,6 +677,31 @@ func (ast *Slice) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	}
 }
 
+// evalBits slices the bits from:to of the constant integer value
+// expr into an unsigned integer of to-from bits.
+func (ast *Slice) evalBits(ctx *Codegen, gen *ssa.Generator, expr ssa.Value,
+	from, to int) (ssa.Value, bool, error) {
+
+	val, ok := expr.ConstValue.(*mpa.Int)
+	if !ok {
+		return ssa.Undefined, false, ctx.Errorf(ast.Expr,
+			"invalid operation: cannot slice %v (%v)", expr, expr.Type)
+	}
+	if to == math.MaxInt32 {
+		to = int(expr.Type.Bits)
+	}
+	if err := ast.checkBits(ctx, from, to, expr.Type); err != nil {
+		return ssa.Undefined, false, err
+	}
+	bits := types.Size(to - from)
+	return gen.Constant(new(mpa.Int).Extract(val, from, to), types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       bits,
+		MinBits:    bits,
+	}), true, nil
+}
+
 func intVal(val interface{}) (int, error) {
 	switch v := val.(type) {
 	case *mpa.Int:
@@ -513,6 +721,13 @@ func intVal(val interface{}) (int, error) {
 // Eval implements the compiler.ast.AST.Eval() for index expressions.
 func (ast *Index) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
+	return ctx.cachedEval(ast, env, func() (ssa.Value, bool, error) {
+		return ast.eval(env, ctx, gen)
+	})
+}
+
+func (ast *Index) eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	ssa.Value, bool, error) {
 
 	expr, ok, err := ast.Expr.Eval(env, ctx, gen)
 	if err != nil || !ok {
@@ -571,8 +786,18 @@ func (ast *Index) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 func (ast *VariableRef) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
 
-	lrv, ok, _, err := ctx.LookupVar(nil, gen, env.Bindings, ast)
+	lrv, ok, df, err := ctx.LookupVar(nil, gen, env.Bindings, ast)
 	if err != nil {
+		if df {
+			v, ok := ctx.Define(gen, ast)
+			if ok {
+				return v, true, nil
+			}
+		}
+		v, ok := ctx.funcValue(gen, ast)
+		if ok {
+			return v, true, nil
+		}
 		return ssa.Undefined, false, ctx.Error(ast, err.Error())
 	}
 	if !ok {
@@ -652,8 +877,8 @@ func (ast *Make) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 		return ssa.Undefined, false, ctx.Errorf(ast.Type, "%s is not a type",
 			ast.Type)
 	}
-	if typeInfo.Type.Array() {
-		// Arrays are made in Make.SSA.
+	if typeInfo.Type.Array() || typeInfo.Type == types.TChan {
+		// Arrays and channels are made in Make.SSA.
 		return ssa.Undefined, false, nil
 	}
 	if typeInfo.Bits != 0 {
@@ -682,3 +907,32 @@ func (ast *Copy) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
 	ssa.Value, bool, error) {
 	return ssa.Undefined, false, nil
 }
+
+// Eval implements the compiler.ast.AST.Eval for channel send
+// statements.
+func (ast *Send) Eval(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	ssa.Value, bool, error) {
+	return ssa.Undefined, false, nil
+}
+
+// compareInt compares the integer constants l and r, and returns -1,
+// 0, or 1 if l is smaller, equal, or greater than r. The constants
+// are compared as unsigned values if either of them has an unsigned
+// type.
+func compareInt(l, r ssa.Value, lval, rval *mpa.Int) int {
+	if l.Type.Type != types.TUint && r.Type.Type != types.TUint {
+		return lval.Cmp(rval)
+	}
+	return unsignedInt(lval, l.Type.Bits).Cmp(unsignedInt(rval, r.Type.Bits))
+}
+
+// unsignedInt returns the unsigned value of the bits low bits of v.
+func unsignedInt(v *mpa.Int, bits types.Size) *big.Int {
+	result := new(big.Int)
+	for i := 0; i < int(bits); i++ {
+		if v.Bit(i) != 0 {
+			result.SetBit(result, i, 1)
+		}
+	}
+	return result
+}
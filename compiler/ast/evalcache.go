@@ -0,0 +1,84 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ast
+
+import (
+	"github.com/markkurossi/mpc/compiler/mpa"
+	"github.com/markkurossi/mpc/compiler/ssa"
+)
+
+// EvalStats contains the constant folding cache statistics.
+type EvalStats struct {
+	Hits   int
+	Misses int
+}
+
+// evalKey identifies the constant folding result of an AST node. The
+// result depends on the node's variable bindings. The environments
+// are cloned from their block's bindings and the clones share the
+// bindings values until they are modified, so the bindings are
+// identified by their values array.
+type evalKey struct {
+	ast    AST
+	values *ssa.Binding
+	count  int
+	pkg    *Package
+}
+
+type evalResult struct {
+	value ssa.Value
+	ok    bool
+}
+
+// cachedEval memoizes the constant folding result of the expression
+// ast in the environment env. The function eval computes the result
+// if it is not in the cache. The cache is flushed whenever any
+// variable bindings change so the cached results are valid for the
+// current bindings.
+func (ctx *Codegen) cachedEval(ast AST, env *Env,
+	eval func() (ssa.Value, bool, error)) (ssa.Value, bool, error) {
+
+	if ctx.Params.NoEvalCache {
+		return eval()
+	}
+	version := ssa.BindingsVersion()
+	if version != ctx.evalVersion {
+		clear(ctx.evalCache)
+		ctx.evalVersion = version
+	}
+	key := evalKey{
+		ast:   ast,
+		count: len(env.Bindings.Values),
+		pkg:   ctx.Package,
+	}
+	if key.count > 0 {
+		key.values = &env.Bindings.Values[0]
+	}
+	result, ok := ctx.evalCache[key]
+	if ok {
+		ctx.EvalStats.Hits++
+		v := result.value
+		if i, ok := v.ConstValue.(*mpa.Int); ok {
+			// The constant's type size can be modified so return a
+			// copy of the cached value.
+			c := *i
+			v.ConstValue = &c
+		}
+		return v, result.ok, nil
+	}
+	ctx.EvalStats.Misses++
+
+	v, ok, err := eval()
+	if err != nil || ssa.BindingsVersion() != version {
+		return v, ok, err
+	}
+	ctx.evalCache[key] = evalResult{
+		value: v,
+		ok:    ok,
+	}
+	return v, ok, nil
+}
@@ -0,0 +1,1423 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// Format formats the package as canonical MPCL source code and writes
+// the result into w. The package comments are emitted next to the
+// statements and declarations they precede. If the package does not
+// have comments, the declaration annotations are printed as comments.
+// The declarations are printed in their source order so Format should
+// be used for packages parsed from a single source file. The line
+// breaks of multi-line expressions and composite literals follow the
+// source. Only the parentheses around binary expressions are kept
+// from the source; other expressions are parenthesized as required.
+func Format(pkg *Package, w io.Writer) error {
+	f := &formatter{
+		comments:    pkg.Comments,
+		annotations: len(pkg.Comments) == 0,
+	}
+	f.pkg(pkg)
+	_, err := w.Write(f.alignComments())
+	return err
+}
+
+type formatter struct {
+	buf         bytes.Buffer
+	comments    []Comment
+	annotations bool
+	level       int
+	last        int
+	blank       bool
+	header      bool
+	trailers    []int
+}
+
+// decl describes a top-level declaration.
+type decl struct {
+	keyword string
+	group   utils.Point
+	start   int
+	end     int
+	spec    *spec
+	print   func()
+}
+
+// spec describes a constant or variable specification. The name and
+// type widths specify the column widths for aligned specifications.
+type spec struct {
+	names     string
+	typ       *TypeInfo
+	init      AST
+	nameWidth int
+	typeWidth int
+}
+
+// startLine starts a new output line.
+func (f *formatter) startLine() {
+	if f.blank && f.buf.Len() > 0 {
+		f.buf.WriteByte('\n')
+	}
+	f.blank = false
+	for i := 0; i < f.level; i++ {
+		f.buf.WriteByte('\t')
+	}
+}
+
+// gap requests a blank line if the source line is not adjacent to the
+// last printed source line.
+func (f *formatter) gap(line int) {
+	if f.last > 0 && line > f.last+1 {
+		f.blank = true
+	}
+}
+
+// flushComments prints all comments preceding the source line.
+func (f *formatter) flushComments(line int) {
+	for len(f.comments) > 0 && f.comments[0].Line < line {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+		f.gap(c.Line)
+		f.startLine()
+		f.buf.WriteString(c.Text)
+		f.buf.WriteByte('\n')
+		f.last = c.End.Line
+	}
+}
+
+// trailing prints the comment starting at the source line.
+func (f *formatter) trailing(line int) {
+	if len(f.comments) > 0 && f.comments[0].Line == line {
+		f.trailers = append(f.trailers, f.buf.Len())
+		f.buf.WriteByte(' ')
+		f.buf.WriteString(f.comments[0].Text)
+		f.comments = f.comments[1:]
+	}
+}
+
+// lineBreak continues the expression on a new output line if the
+// next source line is after the previous source line.
+func (f *formatter) lineBreak(prev, next int) bool {
+	if next <= prev {
+		return false
+	}
+	f.trailing(prev)
+	f.buf.WriteByte('\n')
+	for i := 0; i <= f.level; i++ {
+		f.buf.WriteByte('\t')
+	}
+	return true
+}
+
+// alignComments aligns the trailing comments of consecutive output
+// lines having the same indentation.
+func (f *formatter) alignComments() []byte {
+	data := f.buf.Bytes()
+
+	type trailer struct {
+		pos    int
+		start  int
+		end    int
+		indent int
+		width  int
+	}
+	var trailers []trailer
+	for _, pos := range f.trailers {
+		t := trailer{
+			pos:   pos,
+			start: bytes.LastIndexByte(data[:pos], '\n') + 1,
+			end:   len(data),
+		}
+		if idx := bytes.IndexByte(data[pos:], '\n'); idx >= 0 {
+			t.end = pos + idx
+		}
+		for t.start+t.indent < pos && data[t.start+t.indent] == '\t' {
+			t.indent++
+		}
+		t.width = utf8.RuneCount(data[t.start+t.indent : pos])
+		trailers = append(trailers, t)
+	}
+
+	var result []byte
+	var last int
+	for i := 0; i < len(trailers); {
+		j := i + 1
+		width := trailers[i].width
+		for j < len(trailers) && trailers[j].start == trailers[j-1].end+1 &&
+			trailers[j].indent == trailers[i].indent {
+			width = max(width, trailers[j].width)
+			j++
+		}
+		for _, t := range trailers[i:j] {
+			result = append(result, data[last:t.pos]...)
+			for k := t.width; k < width; k++ {
+				result = append(result, ' ')
+			}
+			last = t.pos
+		}
+		i = j
+	}
+	return append(result, data[last:]...)
+}
+
+// item prints an output line spanning the source lines from start to
+// end.
+func (f *formatter) item(start, end int, print func()) {
+	f.flushComments(start)
+	f.gap(start)
+	f.startLine()
+	f.last = start
+	print()
+	f.trailing(end)
+	f.buf.WriteByte('\n')
+	f.last = end
+}
+
+func (f *formatter) printAnnotations(ann Annotations) {
+	if !f.annotations {
+		return
+	}
+	for _, line := range ann {
+		f.startLine()
+		f.buf.WriteString("//")
+		f.buf.WriteString(line)
+		f.buf.WriteByte('\n')
+	}
+}
+
+func (f *formatter) pkg(pkg *Package) {
+	f.printAnnotations(pkg.Annotations)
+	f.item(pkg.Clause.Line, pkg.Clause.Line, func() {
+		fmt.Fprintf(&f.buf, "package %s", pkg.Name)
+	})
+
+	if len(pkg.Imports) > 0 {
+		var aliases []string
+		for alias := range pkg.Imports {
+			aliases = append(aliases, alias)
+		}
+		sort.Slice(aliases, func(i, j int) bool {
+			return pkg.Imports[aliases[i]] < pkg.Imports[aliases[j]]
+		})
+		f.blank = true
+		f.startLine()
+		f.buf.WriteString("import (\n")
+		for _, alias := range aliases {
+			name := pkg.Imports[alias]
+			if alias == path.Base(name) {
+				fmt.Fprintf(&f.buf, "\t%q\n", name)
+			} else {
+				fmt.Fprintf(&f.buf, "\t%s %q\n", alias, name)
+			}
+		}
+		f.buf.WriteString(")\n")
+	}
+
+	var decls []*decl
+	for _, c := range pkg.Constants {
+		decls = append(decls, f.constDecl(c))
+	}
+	for _, v := range pkg.Variables {
+		decls = append(decls, f.varDecl(v))
+	}
+	for _, t := range pkg.Types {
+		decls = append(decls, f.typeDecl(t))
+		for _, m := range t.Methods {
+			decls = append(decls, f.funcDecl(m))
+		}
+	}
+	for _, fn := range pkg.Functions {
+		decls = append(decls, f.funcDecl(fn))
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		return decls[i].start < decls[j].start
+	})
+
+	var keyword string
+	for i := 0; i < len(decls); {
+		d := decls[i]
+		j := i + 1
+		if d.group.Line > 0 {
+			for j < len(decls) && decls[j].keyword == d.keyword &&
+				decls[j].group == d.group {
+				j++
+			}
+		}
+		// Declarations are separated by a blank line if their kind
+		// changes or if they have a doc comment. Otherwise the source
+		// line spacing is kept.
+		if d.keyword != keyword || d.group.Line > 0 || f.annotations ||
+			(len(f.comments) > 0 && f.comments[0].Line < d.start) {
+			f.blank = true
+		}
+		keyword = d.keyword
+		if d.group.Line > 0 {
+			keyword = ""
+		}
+		if d.group.Line == 0 {
+			d.print()
+		} else {
+			f.flushComments(d.group.Line)
+			f.gap(d.group.Line)
+			f.startLine()
+			fmt.Fprintf(&f.buf, "%s (", d.keyword)
+			f.openBlock(d.group.Line)
+			f.alignSpecs(decls[i:j])
+			f.level++
+			for _, member := range decls[i:j] {
+				member.print()
+			}
+			end := decls[j-1].end + 1
+			f.flushComments(end)
+			f.level--
+			f.blank = false
+			f.startLine()
+			f.buf.WriteString(")\n")
+			f.last = end
+		}
+		i = j
+	}
+	f.flushComments(int(^uint(0) >> 1))
+}
+
+func (f *formatter) constDecl(c *ConstantDef) *decl {
+	d := &decl{
+		keyword: "const",
+		group:   c.Group,
+		start:   c.Line,
+		end:     c.Line,
+		spec: &spec{
+			names: c.Name,
+			typ:   c.Type,
+			init:  c.Init,
+		},
+	}
+	if c.Type != nil {
+		d.end = max(d.end, typeEnd(c.Type))
+	}
+	if c.Init != nil {
+		d.end = max(d.end, endLine(c.Init))
+	}
+	d.print = func() {
+		f.printAnnotations(c.Annotations)
+		f.item(d.start, d.end, func() {
+			if f.level == 0 {
+				f.buf.WriteString("const ")
+			}
+			f.spec(d.spec)
+		})
+	}
+	return d
+}
+
+func (f *formatter) varDecl(v *VariableDef) *decl {
+	d := &decl{
+		keyword: "var",
+		group:   v.Group,
+		start:   v.Line,
+		end:     stmtEnd(v),
+		spec:    varSpec(v),
+	}
+	d.print = func() {
+		f.printAnnotations(v.Annotations)
+		f.item(d.start, d.end, func() {
+			if f.level == 0 {
+				f.buf.WriteString("var ")
+			}
+			f.spec(d.spec)
+		})
+	}
+	return d
+}
+
+func varSpec(v *VariableDef) *spec {
+	return &spec{
+		names: strings.Join(v.Names, ", "),
+		typ:   v.Type,
+		init:  v.Init,
+	}
+}
+
+// alignSpecs sets the column widths of the grouped specifications.
+// The columns are aligned over the consecutive single-line
+// specifications.
+func (f *formatter) alignSpecs(decls []*decl) {
+	for i := 0; i < len(decls); {
+		j := i + 1
+		for j < len(decls) && decls[j-1].start == decls[j-1].end &&
+			decls[j].start == decls[j-1].end+1 &&
+			singleLineType(decls[j].spec.typ, decls[j].start) {
+			j++
+		}
+		var nameWidth, typeWidth int
+		for _, d := range decls[i:j] {
+			nameWidth = max(nameWidth, width(d.spec.names))
+			if d.spec.typ != nil && singleLineType(d.spec.typ, d.start) {
+				typeWidth = max(typeWidth, width(typeString(d.spec.typ)))
+			}
+		}
+		for _, d := range decls[i:j] {
+			d.spec.nameWidth = nameWidth
+			d.spec.typeWidth = typeWidth
+		}
+		i = j
+	}
+}
+
+func singleLineType(ti *TypeInfo, line int) bool {
+	return ti == nil || typeEnd(ti) == line
+}
+
+func typeString(ti *TypeInfo) string {
+	f := new(formatter)
+	f.typ(ti)
+	return f.buf.String()
+}
+
+func width(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// pad pads the column text s to the width.
+func (f *formatter) pad(s string, w int) {
+	for i := width(s); i < w; i++ {
+		f.buf.WriteByte(' ')
+	}
+}
+
+func (f *formatter) spec(s *spec) {
+	f.buf.WriteString(s.names)
+	if s.typ != nil {
+		if s.nameWidth > 0 {
+			f.pad(s.names, s.nameWidth)
+		}
+		f.buf.WriteByte(' ')
+		start := f.buf.Len()
+		f.typ(s.typ)
+		if s.init != nil && s.typeWidth > 0 {
+			f.pad(string(f.buf.Bytes()[start:]), s.typeWidth)
+		}
+	} else if s.init != nil {
+		if s.typeWidth > 0 {
+			f.pad(s.names, s.nameWidth+1+s.typeWidth)
+		} else {
+			f.pad(s.names, s.nameWidth)
+		}
+	}
+	if s.init != nil {
+		f.buf.WriteString(" = ")
+		f.expr(s.init)
+	}
+}
+
+func (f *formatter) typeDecl(t *TypeInfo) *decl {
+	d := &decl{
+		keyword: "type",
+		start:   t.Line,
+		end:     typeEnd(t),
+	}
+	if t.Type == TypeAlias {
+		d.end = typeEnd(t.AliasType)
+	}
+	d.print = func() {
+		f.printAnnotations(t.Annotations)
+		f.item(d.start, d.end, func() {
+			fmt.Fprintf(&f.buf, "type %s ", t.TypeName)
+			if t.Type == TypeAlias {
+				f.buf.WriteString("= ")
+				f.typ(t.AliasType)
+			} else {
+				f.typ(t)
+			}
+		})
+	}
+	return d
+}
+
+func (f *formatter) funcDecl(fn *Func) *decl {
+	d := &decl{
+		keyword: "func",
+		start:   fn.Line,
+		end:     max(fn.Line, fn.End.Line),
+	}
+	// The source line of the body's opening brace.
+	open := fn.Line
+	for _, v := range append(append([]*Variable{fn.This}, fn.Args...),
+		fn.Return...) {
+		if v != nil {
+			open = max(open, typeEnd(v.Type))
+		}
+	}
+	d.print = func() {
+		f.printAnnotations(fn.Annotations)
+		f.item(d.start, d.end, func() {
+			f.buf.WriteString("func ")
+			if fn.This != nil {
+				fmt.Fprintf(&f.buf, "(%s ", fn.This.Name)
+				f.typ(fn.This.Type)
+				f.buf.WriteString(") ")
+			}
+			f.buf.WriteString(fn.Name)
+			f.buf.WriteByte('(')
+			f.params(fn.Args)
+			f.buf.WriteByte(')')
+
+			if fn.NamedReturn {
+				f.buf.WriteString(" (")
+				f.params(fn.Return)
+				f.buf.WriteByte(')')
+			} else if len(fn.Return) == 1 {
+				f.buf.WriteByte(' ')
+				f.typ(fn.Return[0].Type)
+			} else if len(fn.Return) > 1 {
+				f.buf.WriteString(" (")
+				for idx, ret := range fn.Return {
+					if idx > 0 {
+						f.buf.WriteString(", ")
+					}
+					f.typ(ret.Type)
+				}
+				f.buf.WriteByte(')')
+			}
+			if fn.End.Line == open && simpleBody(fn.Body) {
+				f.buf.WriteString(" {")
+				for _, stmt := range fn.Body {
+					f.buf.WriteByte(' ')
+					f.stmtBody(stmt)
+					f.buf.WriteByte(' ')
+				}
+				f.buf.WriteByte('}')
+				return
+			}
+			f.buf.WriteString(" {")
+			f.openBlock(open)
+			f.block(fn.Body, d.end)
+			f.closeBlock()
+		})
+	}
+	return d
+}
+
+// simpleBody tests if the function body can be printed on one line.
+func simpleBody(body List) bool {
+	if len(body) > 1 {
+		return false
+	}
+	for _, stmt := range body {
+		switch stmt.(type) {
+		case *If, *For, *ForRange:
+			return false
+		}
+	}
+	return true
+}
+
+// params prints the variables. Consecutive variables sharing their
+// type are printed as an identifier list.
+func (f *formatter) params(vars []*Variable) {
+	for idx, v := range vars {
+		if idx > 0 {
+			f.buf.WriteString(", ")
+		}
+		f.buf.WriteString(v.Name)
+		if idx+1 < len(vars) && vars[idx+1].Type == v.Type {
+			continue
+		}
+		f.buf.WriteByte(' ')
+		f.typ(v.Type)
+	}
+}
+
+func (f *formatter) openBlock(line int) {
+	f.trailing(line)
+	f.buf.WriteByte('\n')
+	f.last = line
+}
+
+func (f *formatter) block(list List, end int) {
+	f.level++
+	for _, stmt := range list {
+		f.stmt(stmt)
+	}
+	f.flushComments(end)
+	f.level--
+}
+
+func (f *formatter) closeBlock() {
+	f.blank = false
+	f.startLine()
+	f.buf.WriteByte('}')
+}
+
+func (f *formatter) stmt(stmt AST) {
+	f.item(startLine(stmt), stmtEnd(stmt), func() {
+		f.stmtBody(stmt)
+	})
+}
+
+func (f *formatter) stmtBody(stmt AST) {
+	switch s := stmt.(type) {
+	case List:
+		f.exprs(s)
+
+	case *VariableDef:
+		f.buf.WriteString("var ")
+		f.spec(varSpec(s))
+
+	case *Assign:
+		f.assign(s)
+
+	case *Send:
+		f.expr(s.Chan)
+		f.buf.WriteString(" <- ")
+		f.expr(s.Value)
+
+	case *Return:
+		f.buf.WriteString("return")
+		if len(s.Exprs) > 0 {
+			f.buf.WriteByte(' ')
+			f.exprs(s.Exprs)
+		}
+
+	case *Defer:
+		f.buf.WriteString("defer ")
+		f.stmtBody(s.Stmt)
+
+	case *If:
+		f.buf.WriteString("if ")
+		f.headerExpr(s.Expr)
+		f.buf.WriteString(" {")
+		f.openBlock(endLine(s.Expr))
+		trueEnd := blockEnd(s.True, endLine(s.Expr))
+		f.block(listOf(s.True), trueEnd)
+		f.closeBlock()
+		switch b := s.False.(type) {
+		case nil:
+		case *If:
+			f.buf.WriteString(" else ")
+			f.stmtBody(b)
+		default:
+			f.buf.WriteString(" else {")
+			f.openBlock(trueEnd)
+			f.block(listOf(b), blockEnd(b, trueEnd))
+			f.closeBlock()
+		}
+
+	case *For:
+		f.buf.WriteString("for ")
+		f.header = true
+		if s.Init != nil || s.Inc != nil {
+			if s.Init != nil {
+				f.stmtBody(s.Init)
+			}
+			f.buf.WriteString("; ")
+			f.expr(s.Cond)
+			f.buf.WriteString("; ")
+			f.stmtBody(s.Inc)
+			f.buf.WriteByte(' ')
+		} else if s.Cond != nil {
+			f.expr(s.Cond)
+			f.buf.WriteByte(' ')
+		}
+		f.header = false
+		f.buf.WriteByte('{')
+		f.openBlock(forOpen(s))
+		f.block(s.Body, blockEnd(s.Body, forOpen(s)))
+		f.closeBlock()
+
+	case *ForRange:
+		f.buf.WriteString("for ")
+		f.header = true
+		f.exprs(s.ExprList)
+		if s.Def {
+			f.buf.WriteString(" := range ")
+		} else {
+			f.buf.WriteString(" = range ")
+		}
+		f.expr(s.Expr)
+		f.header = false
+		f.buf.WriteString(" {")
+		f.openBlock(rangeOpen(s))
+		f.block(s.Body, blockEnd(s.Body, rangeOpen(s)))
+		f.closeBlock()
+
+	default:
+		fmt.Fprintf(&f.buf, "/* unsupported statement %T */", stmt)
+	}
+}
+
+// assign prints the assignment. The parser expands the compound
+// assignments and increment and decrement statements into binary
+// expressions whose left operand is the assignment's lvalue.
+func (f *formatter) assign(s *Assign) {
+	if !s.Define && len(s.LValues) == 1 && len(s.Exprs) == 1 {
+		b, ok := s.Exprs[0].(*Binary)
+		if ok && b.Left == s.LValues[0] {
+			f.expr(b.Left)
+			lit, ok := b.Right.(*BasicLit)
+			if ok && lit.Point == b.Point && lit.Value == int64(1) &&
+				(b.Op == BinaryAdd || b.Op == BinarySub) {
+				fmt.Fprintf(&f.buf, "%s%s", b.Op, b.Op)
+				return
+			}
+			fmt.Fprintf(&f.buf, " %s= ", b.Op)
+			f.expr(b.Right)
+			return
+		}
+	}
+	f.exprs(s.LValues)
+	if s.Define {
+		f.buf.WriteString(" := ")
+	} else {
+		f.buf.WriteString(" = ")
+	}
+	depth := 1
+	if len(s.LValues) > 1 && len(s.Exprs) > 1 {
+		depth++
+	}
+	f.exprList(s.Exprs, depth)
+}
+
+func (f *formatter) typ(ti *TypeInfo) {
+	switch ti.Type {
+	case TypeName:
+		f.buf.WriteString(ti.Name.String())
+
+	case TypeArray:
+		f.buf.WriteByte('[')
+		f.noHeader(ti.ArrayLength, 1)
+		f.buf.WriteByte(']')
+		f.typ(ti.ElementType)
+
+	case TypeSlice:
+		f.buf.WriteString("[]")
+		f.typ(ti.ElementType)
+
+	case TypePointer:
+		f.buf.WriteByte('*')
+		f.typ(ti.ElementType)
+
+	case TypeChan:
+		f.buf.WriteString("chan ")
+		f.typ(ti.ElementType)
+
+	case TypeFunc:
+		f.buf.WriteString("func(")
+		for idx, arg := range ti.FuncArgs {
+			if idx > 0 {
+				f.buf.WriteString(", ")
+			}
+			f.typ(arg)
+		}
+		f.buf.WriteByte(')')
+		if len(ti.FuncReturn) == 1 {
+			f.buf.WriteByte(' ')
+			f.typ(ti.FuncReturn[0])
+		} else if len(ti.FuncReturn) > 1 {
+			f.buf.WriteString(" (")
+			for idx, ret := range ti.FuncReturn {
+				if idx > 0 {
+					f.buf.WriteString(", ")
+				}
+				f.typ(ret)
+			}
+			f.buf.WriteByte(')')
+		}
+
+	case TypeAlias:
+		f.typ(ti.AliasType)
+
+	case TypeStruct:
+		// Fields sharing their type are printed as an identifier
+		// list.
+		var fields []*spec
+		var lines []int
+		for idx, field := range ti.StructFields {
+			if idx > 0 && field.Type == ti.StructFields[idx-1].Type {
+				fields[len(fields)-1].names += ", " + field.Name
+			} else {
+				fields = append(fields, &spec{
+					names: field.Name,
+					typ:   field.Type,
+				})
+				lines = append(lines, field.Line)
+			}
+		}
+		if len(fields) == 0 {
+			f.buf.WriteString("struct{}")
+			return
+		}
+		if len(fields) == 1 && typeEnd(ti) == ti.Line {
+			f.buf.WriteString("struct{ ")
+			f.spec(fields[0])
+			f.buf.WriteString(" }")
+			return
+		}
+		f.buf.WriteString("struct {")
+		f.openBlock(ti.Line)
+
+		// Align the field types of consecutive single-line fields.
+		for i := 0; i < len(fields); {
+			j := i + 1
+			for j < len(fields) &&
+				typeEnd(fields[j-1].typ) == lines[j-1] &&
+				lines[j] == lines[j-1]+1 {
+				j++
+			}
+			var nameWidth int
+			for _, field := range fields[i:j] {
+				nameWidth = max(nameWidth, width(field.names))
+			}
+			for _, field := range fields[i:j] {
+				field.nameWidth = nameWidth
+			}
+			i = j
+		}
+		f.level++
+		for idx, field := range fields {
+			f.item(lines[idx], typeEnd(field.typ), func() {
+				f.spec(field)
+			})
+		}
+		f.flushComments(typeEnd(ti))
+		f.level--
+		f.closeBlock()
+
+	default:
+		fmt.Fprintf(&f.buf, "/* unsupported type %v */", ti.Type)
+	}
+}
+
+func (f *formatter) exprs(list []AST) {
+	f.exprList(list, 1)
+}
+
+func (f *formatter) exprList(list []AST, depth int) {
+	for idx, expr := range list {
+		if idx > 0 {
+			f.buf.WriteByte(',')
+			if !f.lineBreak(endLine(list[idx-1]), startLine(expr)) {
+				f.buf.WriteByte(' ')
+			}
+		}
+		f.expr1(expr, 0, depth)
+	}
+}
+
+// headerExpr prints the expression of a statement header where
+// composite literals of named types must be parenthesized.
+func (f *formatter) headerExpr(expr AST) {
+	f.header = true
+	f.expr(expr)
+	f.header = false
+}
+
+// noHeader prints the expression outside the statement header
+// context.
+func (f *formatter) noHeader(expr AST, depth int) {
+	header := f.header
+	f.header = false
+	f.expr1(expr, 0, depth)
+	f.header = header
+}
+
+// paren prints the expression in parentheses. The parentheses undo
+// one level of depth.
+func (f *formatter) paren(expr AST, depth int) {
+	header := f.header
+	f.header = false
+	f.buf.WriteByte('(')
+	if b, ok := expr.(*Binary); ok {
+		f.binary(b, max(depth-1, 1))
+	} else {
+		f.expr1(expr, 0, max(depth-1, 1))
+	}
+	f.buf.WriteByte(')')
+	f.header = header
+}
+
+// operand prints the operand of an operator with the precedence prec.
+func (f *formatter) operand(expr AST, prec, depth int) {
+	b, ok := expr.(*Binary)
+	if ok && !b.Paren && binaryPrecedence[b.Op] < prec {
+		f.paren(expr, depth)
+	} else {
+		f.expr1(expr, prec, depth)
+	}
+}
+
+// primary prints the operand of a primary expression.
+func (f *formatter) primary(expr AST) {
+	switch expr.(type) {
+	case *Binary, *Unary:
+		f.paren(expr, 1)
+	default:
+		f.expr(expr)
+	}
+}
+
+const unaryPrecedence = 6
+
+var binaryPrecedence = map[BinaryType]int{
+	BinaryMul:    5,
+	BinaryDiv:    5,
+	BinaryMod:    5,
+	BinaryLshift: 5,
+	BinaryRshift: 5,
+	BinaryBand:   5,
+	BinaryBclear: 5,
+	BinaryAdd:    4,
+	BinarySub:    4,
+	BinaryBor:    4,
+	BinaryBxor:   4,
+	BinaryEq:     3,
+	BinaryNeq:    3,
+	BinaryLt:     3,
+	BinaryLe:     3,
+	BinaryGt:     3,
+	BinaryGe:     3,
+	BinaryAnd:    2,
+	BinaryOr:     1,
+}
+
+// binaryCutoff returns the precedence below which the binary
+// operators of the expression are surrounded by blanks. The spacing
+// follows gofmt: the blanks are dropped around the higher precedence
+// operators of nested expressions.
+func binaryCutoff(e *Binary, depth int) int {
+	has4, has5, maxProblem := walkBinary(e)
+	if maxProblem > 0 {
+		return maxProblem + 1
+	}
+	if has4 && has5 {
+		if depth == 1 {
+			return 5
+		}
+		return 4
+	}
+	if depth == 1 {
+		return 6
+	}
+	return 4
+}
+
+func walkBinary(e *Binary) (has4, has5 bool, maxProblem int) {
+	prec := binaryPrecedence[e.Op]
+	switch prec {
+	case 4:
+		has4 = true
+	case 5:
+		has5 = true
+	}
+	left, ok := e.Left.(*Binary)
+	if ok && !left.Paren && binaryPrecedence[left.Op] >= prec {
+		h4, h5, mp := walkBinary(left)
+		has4 = has4 || h4
+		has5 = has5 || h5
+		maxProblem = max(maxProblem, mp)
+	}
+	switch right := e.Right.(type) {
+	case *Binary:
+		if !right.Paren && binaryPrecedence[right.Op] > prec {
+			h4, h5, mp := walkBinary(right)
+			has4 = has4 || h4
+			has5 = has5 || h5
+			maxProblem = max(maxProblem, mp)
+		}
+	case *Unary:
+		switch e.Op.String() + right.Type.String() {
+		case "/*", "&&", "&^":
+			maxProblem = 5
+		case "++", "--":
+			maxProblem = max(maxProblem, 4)
+		}
+	}
+	return
+}
+
+func diffPrec(expr AST, prec int) int {
+	b, ok := expr.(*Binary)
+	if !ok || b.Paren || binaryPrecedence[b.Op] != prec {
+		return 1
+	}
+	return 0
+}
+
+func (f *formatter) expr(expr AST) {
+	f.expr1(expr, 0, 1)
+}
+
+func (f *formatter) expr1(expr AST, prec1, depth int) {
+	switch e := expr.(type) {
+	case *BasicLit:
+		if len(e.Raw) > 0 {
+			f.buf.WriteString(e.Raw)
+		} else if e.Value == nil {
+			f.buf.WriteString("nil")
+		} else {
+			f.buf.WriteString(ConstantName(e.Value))
+		}
+
+	case *VariableRef:
+		f.buf.WriteString(e.Name.String())
+
+	case *Binary:
+		if e.Paren {
+			f.paren(e, depth)
+		} else {
+			f.binary(e, depth)
+		}
+
+	case *Unary:
+		f.buf.WriteString(e.Type.String())
+		operand, ok := e.Expr.(*Unary)
+		if ok {
+			// Separate operators that would lex as one token.
+			switch e.Type.String() + operand.Type.String() {
+			case "++", "--", "&&", "&^":
+				f.buf.WriteByte(' ')
+			}
+		}
+		f.operand(e.Expr, unaryPrecedence, depth)
+
+	case *Call:
+		if len(e.Exprs) > 1 {
+			depth++
+		}
+		f.expr(e.Ref)
+		f.buf.WriteByte('(')
+		f.exprList(e.Exprs, depth)
+		f.buf.WriteByte(')')
+
+	case *Make:
+		if len(e.Exprs) > 0 {
+			depth++
+		}
+		f.buf.WriteString("make(")
+		f.typ(e.Type)
+		prev := e.Type.Line
+		for _, arg := range e.Exprs {
+			f.buf.WriteByte(',')
+			if !f.lineBreak(prev, startLine(arg)) {
+				f.buf.WriteByte(' ')
+			}
+			f.expr1(arg, 0, depth)
+			prev = endLine(arg)
+		}
+		f.buf.WriteByte(')')
+
+	case *Copy:
+		f.buf.WriteString("copy(")
+		f.exprList([]AST{e.Dst, e.Src}, depth+1)
+		f.buf.WriteByte(')')
+
+	case *Index:
+		f.primary(e.Expr)
+		f.buf.WriteByte('[')
+		f.expr1(e.Index, 0, depth+1)
+		f.buf.WriteByte(']')
+
+	case *Slice:
+		// Blanks surround the colons if at least two indices are
+		// present and one of them is a binary expression.
+		indices := []AST{e.From, e.To}
+		if e.Max != nil {
+			indices = append(indices, e.Max)
+		}
+		var count int
+		var binary bool
+		for _, index := range indices {
+			if index != nil {
+				count++
+				if _, ok := index.(*Binary); ok {
+					binary = true
+				}
+			}
+		}
+		blank := depth <= 1 && count > 1 && binary
+
+		f.primary(e.Expr)
+		f.buf.WriteByte('[')
+		for i, index := range indices {
+			if i > 0 {
+				if blank && indices[i-1] != nil {
+					f.buf.WriteByte(' ')
+				}
+				f.buf.WriteByte(':')
+				if blank && index != nil {
+					f.buf.WriteByte(' ')
+				}
+			}
+			if index != nil {
+				f.expr1(index, 0, depth+1)
+			}
+		}
+		f.buf.WriteByte(']')
+
+	case *ArrayCast:
+		f.typ(e.TypeInfo)
+		f.buf.WriteByte('(')
+		f.noHeader(e.Expr, depth)
+		f.buf.WriteByte(')')
+
+	case *CompositeLit:
+		f.compositeLit(e, false)
+
+	default:
+		fmt.Fprintf(&f.buf, "/* unsupported expression %T */", expr)
+	}
+}
+
+func (f *formatter) binary(e *Binary, depth int) {
+	prec := binaryPrecedence[e.Op]
+	blank := prec < binaryCutoff(e, depth)
+	f.operand(e.Left, prec, depth+diffPrec(e.Left, prec))
+	if blank {
+		f.buf.WriteByte(' ')
+	}
+	f.buf.WriteString(e.Op.String())
+	if !f.lineBreak(endLine(e.Left), startLine(e.Right)) && blank {
+		f.buf.WriteByte(' ')
+	}
+	f.operand(e.Right, prec+1, depth+1)
+}
+
+func (f *formatter) compositeLit(lit *CompositeLit, elided bool) {
+	if hexBytes(lit) {
+		f.buf.WriteString("hex\"")
+		for _, e := range lit.Value {
+			fmt.Fprintf(&f.buf, "%02x", e.Element.(*BasicLit).Value)
+		}
+		f.buf.WriteByte('"')
+		return
+	}
+	if f.header && !elided && lit.Type.Type == TypeName {
+		header := f.header
+		f.header = false
+		f.buf.WriteByte('(')
+		f.compositeLit(lit, false)
+		f.buf.WriteByte(')')
+		f.header = header
+		return
+	}
+	header := f.header
+	f.header = false
+	defer func() {
+		f.header = header
+	}()
+
+	if !elided {
+		f.typ(lit.Type)
+	}
+	f.buf.WriteByte('{')
+
+	if !litMultiline(lit, elided) {
+		for idx, e := range lit.Value {
+			if idx > 0 {
+				f.buf.WriteByte(',')
+				prev := elementEnd(lit, lit.Value[idx-1])
+				if !f.lineBreak(prev, elementStart(lit, e)) {
+					f.buf.WriteByte(' ')
+				}
+			}
+			f.element(lit, e)
+		}
+		f.buf.WriteByte('}')
+		return
+	}
+	f.openBlock(litOpen(lit, elided))
+	f.level++
+	// The elements sharing a source line are printed on one line.
+	for i := 0; i < len(lit.Value); {
+		j := i + 1
+		for j < len(lit.Value) && elementStart(lit, lit.Value[j]) ==
+			elementEnd(lit, lit.Value[j-1]) {
+			j++
+		}
+		start := elementStart(lit, lit.Value[i])
+		end := elementEnd(lit, lit.Value[j-1])
+		f.item(start, end, func() {
+			for idx, e := range lit.Value[i:j] {
+				if idx > 0 {
+					f.buf.WriteString(", ")
+				}
+				f.element(lit, e)
+			}
+			f.buf.WriteByte(',')
+		})
+		i = j
+	}
+	f.flushComments(litEnd(lit, elided))
+	f.level--
+	f.closeBlock()
+}
+
+func (f *formatter) element(lit *CompositeLit, e KeyedElement) {
+	if e.Key != nil {
+		f.expr(e.Key)
+		f.buf.WriteString(": ")
+	}
+	if elidedLit(lit, e) {
+		f.compositeLit(e.Element.(*CompositeLit), true)
+	} else {
+		f.expr(e.Element)
+	}
+}
+
+// elidedLit tests if the element is a composite literal whose type is
+// elided from the source.
+func elidedLit(lit *CompositeLit, e KeyedElement) bool {
+	el, ok := e.Element.(*CompositeLit)
+	return ok && el.Type != nil && el.Type == lit.Type.ElementType
+}
+
+// hexBytes tests if the composite literal is a hex"..." literal.
+func hexBytes(lit *CompositeLit) bool {
+	if lit.Type == nil || lit.Type.Type != TypeSlice ||
+		lit.Type.Point != lit.Point ||
+		lit.Type.ElementType.Point != lit.Point ||
+		lit.Type.ElementType.Name.Name != "byte" {
+		return false
+	}
+	for _, e := range lit.Value {
+		b, ok := e.Element.(*BasicLit)
+		if !ok || e.Key != nil || b.Point != lit.Point {
+			return false
+		}
+		if _, ok := b.Value.(int64); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func litStart(lit *CompositeLit, elided bool) int {
+	if elided || lit.Type == nil || lit.Type.Line == 0 {
+		return lit.Line
+	}
+	return min(lit.Type.Line, lit.Line)
+}
+
+// litOpen returns the source line of the composite literal's opening
+// brace.
+func litOpen(lit *CompositeLit, elided bool) int {
+	if elided || lit.Type == nil || lit.Type.Line == 0 {
+		return lit.Line
+	}
+	return typeEnd(lit.Type)
+}
+
+func litMultiline(lit *CompositeLit, elided bool) bool {
+	if len(lit.Value) == 0 {
+		return false
+	}
+	return elementStart(lit, lit.Value[0]) > litOpen(lit, elided)
+}
+
+func litEnd(lit *CompositeLit, elided bool) int {
+	if len(lit.Value) == 0 {
+		return litOpen(lit, elided)
+	}
+	end := elementEnd(lit, lit.Value[len(lit.Value)-1])
+	if litMultiline(lit, elided) {
+		return end + 1
+	}
+	return max(end, litOpen(lit, elided))
+}
+
+func elementStart(lit *CompositeLit, e KeyedElement) int {
+	if e.Key != nil {
+		return startLine(e.Key)
+	}
+	if elidedLit(lit, e) {
+		return e.Element.(*CompositeLit).Line
+	}
+	return startLine(e.Element)
+}
+
+func elementEnd(lit *CompositeLit, e KeyedElement) int {
+	if elidedLit(lit, e) {
+		return litEnd(e.Element.(*CompositeLit), true)
+	}
+	return endLine(e.Element)
+}
+
+func listOf(ast AST) List {
+	list, _ := ast.(List)
+	return list
+}
+
+// startLine returns the first source line of the AST node.
+func startLine(ast AST) int {
+	switch a := ast.(type) {
+	case List:
+		if len(a) > 0 {
+			return startLine(a[0])
+		}
+	case *Binary:
+		return startLine(a.Left)
+	case *Assign:
+		if len(a.LValues) > 0 {
+			return startLine(a.LValues[0])
+		}
+	case *Send:
+		return startLine(a.Chan)
+	case *If:
+		return startLine(a.Expr)
+	case *ArrayCast:
+		return a.TypeInfo.Line
+	case *CompositeLit:
+		return litStart(a, false)
+	}
+	return ast.Location().Line
+}
+
+// endLine returns the last source line of the expression.
+func endLine(ast AST) int {
+	line := ast.Location().Line
+	switch a := ast.(type) {
+	case List:
+		if len(a) > 0 {
+			return endLine(a[len(a)-1])
+		}
+	case *Binary:
+		return max(line, endLine(a.Right))
+	case *Unary:
+		return max(line, endLine(a.Expr))
+	case *Call:
+		if len(a.Exprs) > 0 {
+			return max(line, endLine(a.Exprs[len(a.Exprs)-1]))
+		}
+	case *Make:
+		if len(a.Exprs) > 0 {
+			return max(line, endLine(a.Exprs[len(a.Exprs)-1]))
+		}
+	case *Copy:
+		return max(line, endLine(a.Src))
+	case *Index:
+		return max(endLine(a.Expr), endLine(a.Index))
+	case *Slice:
+		line = endLine(a.Expr)
+		if a.From != nil {
+			line = max(line, endLine(a.From))
+		}
+		if a.To != nil {
+			line = max(line, endLine(a.To))
+		}
+		if a.Max != nil {
+			line = max(line, endLine(a.Max))
+		}
+	case *ArrayCast:
+		return max(line, endLine(a.Expr))
+	case *CompositeLit:
+		return litEnd(a, false)
+	}
+	return line
+}
+
+// stmtEnd returns the last source line of the statement.
+func stmtEnd(stmt AST) int {
+	switch s := stmt.(type) {
+	case *VariableDef:
+		line := s.Line
+		if s.Type != nil {
+			line = max(line, typeEnd(s.Type))
+		}
+		if s.Init != nil {
+			line = max(line, endLine(s.Init))
+		}
+		return line
+	case *Assign:
+		if len(s.Exprs) > 0 {
+			return endLine(s.Exprs[len(s.Exprs)-1])
+		}
+	case *Send:
+		return endLine(s.Value)
+	case *Return:
+		if len(s.Exprs) > 0 {
+			return max(s.Line, endLine(s.Exprs[len(s.Exprs)-1]))
+		}
+		return s.Line
+	case *Defer:
+		return stmtEnd(s.Stmt)
+	case *If:
+		end := blockEnd(s.True, endLine(s.Expr))
+		switch b := s.False.(type) {
+		case nil:
+			return end
+		case *If:
+			return stmtEnd(b)
+		default:
+			return blockEnd(b, end)
+		}
+	case *For:
+		return blockEnd(s.Body, forOpen(s))
+	case *ForRange:
+		return blockEnd(s.Body, rangeOpen(s))
+	}
+	return endLine(stmt)
+}
+
+// forOpen returns the source line of the for statement's opening
+// brace.
+func forOpen(s *For) int {
+	line := s.Line
+	if s.Init != nil {
+		line = max(line, stmtEnd(s.Init))
+	}
+	if s.Cond != nil {
+		line = max(line, endLine(s.Cond))
+	}
+	if s.Inc != nil {
+		line = max(line, stmtEnd(s.Inc))
+	}
+	return line
+}
+
+// rangeOpen returns the source line of the range statement's opening
+// brace.
+func rangeOpen(s *ForRange) int {
+	return max(s.Line, endLine(s.Expr))
+}
+
+// blockEnd returns the source line of the block's closing brace.
+func blockEnd(block AST, open int) int {
+	list := listOf(block)
+	if len(list) == 0 {
+		return open + 1
+	}
+	return stmtEnd(list[len(list)-1]) + 1
+}
+
+// typeEnd returns the last source line of the type.
+func typeEnd(ti *TypeInfo) int {
+	switch ti.Type {
+	case TypeStruct:
+		if len(ti.StructFields) == 0 {
+			return ti.Line
+		}
+		end := typeEnd(ti.StructFields[len(ti.StructFields)-1].Type)
+		if end == ti.Line {
+			return end
+		}
+		return end + 1
+	case TypeArray, TypeSlice, TypePointer, TypeChan:
+		return max(ti.Line, typeEnd(ti.ElementType))
+	}
+	return ti.Line
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ast
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/tabulate"
+)
+
+// InitFunc names the package initializers in the instruction
+// statistics.
+const InitFunc = "<init>"
+
+// InstrStats contains the number of generated SSA instructions by
+// function names. The instructions of all instances of a function
+// are counted together. The package initializers are counted under
+// the InitFunc name.
+type InstrStats map[string]int
+
+// Total returns the total number of instructions.
+func (stats InstrStats) Total() int {
+	var total int
+	for _, count := range stats {
+		total += count
+	}
+	return total
+}
+
+// Print prints the instruction counts to w, ordered by the counts.
+func (stats InstrStats) Print(w io.Writer) {
+	var names []string
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci := stats[names[i]]
+		cj := stats[names[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+
+	tab := tabulate.New(tabulate.Plain)
+	tab.Header("Function").SetAlign(tabulate.ML)
+	tab.Header("Instrs").SetAlign(tabulate.MR)
+	for _, name := range names {
+		row := tab.Row()
+		row.Column(name)
+		row.Column(fmt.Sprintf("%v", stats[name]))
+	}
+	row := tab.Row()
+	row.Column("Total").SetFormat(tabulate.FmtBold)
+	row.Column(fmt.Sprintf("%v", stats.Total())).SetFormat(tabulate.FmtBold)
+	tab.Print(w)
+}
+
+// QualifiedName returns the function name qualified with its
+// receiver type for methods.
+func (ast *Func) QualifiedName() string {
+	if ast.This == nil {
+		return ast.Name
+	}
+	return fmt.Sprintf("%s.%s", ast.This.Type, ast.Name)
+}
+
+// countInstrs accounts the instructions generated since the previous
+// call to the current function and checks that the program is within
+// the instruction limit.
+func (ctx *Codegen) countInstrs(locator utils.Locator,
+	gen *ssa.Generator) error {
+
+	name := InitFunc
+	if f := ctx.Func(); f != nil {
+		name = f.QualifiedName()
+	}
+	count := gen.NumInstrs()
+	if count > ctx.instrMark {
+		ctx.InstrStats[name] += count - ctx.instrMark
+		ctx.instrMark = count
+	}
+
+	limit := ctx.Params.MaxInstructions
+	if limit > 0 && count > limit {
+		return ctx.Errorf(locator,
+			"function %s exceeds the instruction limit %d (%d instructions)",
+			name, limit, count)
+	}
+	return nil
+}
@@ -118,7 +118,7 @@ func (lrv *LRValue) Indirect() *LRValue {
 // Set sets the l-value to rv.
 func (lrv LRValue) Set(rv ssa.Value) error {
 	if !ssa.CanAssign(lrv.valueType, rv) {
-		return fmt.Errorf("cannot assing %v to variable of type %v",
+		return fmt.Errorf("cannot assign %v to variable of type %v",
 			rv.Type, lrv.valueType)
 	}
 	lValue := lrv.LValue()
@@ -197,6 +197,9 @@ func (lrv *LRValue) ValueType() types.Info {
 }
 
 func (lrv *LRValue) ptrBaseValue() (ssa.Value, error) {
+	if lrv.baseInfo == nil {
+		return ssa.Undefined, fmt.Errorf("nil pointer dereference")
+	}
 	b, ok := lrv.baseInfo.Bindings.Get(lrv.baseInfo.Name)
 	if !ok {
 		return ssa.Undefined, fmt.Errorf("undefined: %s", lrv.baseInfo.Name)
@@ -211,7 +214,8 @@ func (lrv *LRValue) ConstValue() (ssa.Value, bool, error) {
 		return lrv.value, false, nil
 
 	case types.TBool, types.TInt, types.TUint, types.TFloat, types.TString,
-		types.TStruct, types.TArray, types.TSlice, types.TNil:
+		types.TStruct, types.TArray, types.TSlice, types.TNil,
+		types.TChan, types.TFunc:
 		return lrv.value, true, nil
 
 	default:
@@ -347,7 +351,7 @@ func (ctx *Codegen) LookupVar(block *ssa.Block, gen *ssa.Generator,
 	}
 	lrv.valueType = lrv.value.Type
 
-	if lrv.value.Type.Type == types.TPtr {
+	if lrv.value.Type.Type == types.TPtr && lrv.value.PtrInfo != nil {
 		lrv.baseInfo = lrv.value.PtrInfo
 		lrv.baseValue, err = lrv.ptrBaseValue()
 		if err != nil {
@@ -20,7 +20,9 @@ import (
 type Package struct {
 	Name        string
 	Source      string
+	Clause      utils.Point
 	Annotations Annotations
+	Comments    []Comment
 	Initialized bool
 	Imports     map[string]string
 	Bindings    *ssa.Bindings
@@ -42,12 +44,85 @@ func NewPackage(name, source string, annotations Annotations) *Package {
 	}
 }
 
-// Compile compiles the package.
-func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
+// Reset clears the package's compilation state so that the parsed
+// package can be compiled again, for example, with different input
+// sizes.
+func (pkg *Package) Reset() {
+	pkg.Initialized = false
+	pkg.Bindings = new(ssa.Bindings)
+	for _, f := range pkg.Functions {
+		f.reset()
+	}
+	for _, t := range pkg.Types {
+		for _, m := range t.Methods {
+			m.reset()
+		}
+	}
+}
+
+// overrideSizes returns the input sizes with the non-zero widths
+// replacing the corresponding sizes.
+func overrideSizes(sizes, widths []int) []int {
+	if len(widths) == 0 {
+		return sizes
+	}
+	result := make([]int, max(len(sizes), len(widths)))
+	copy(result, sizes)
+	for idx, w := range widths {
+		if w > 0 {
+			result[idx] = w
+		}
+	}
+	return result
+}
+
+// checkWidths checks that the type info has the non-zero widths. The
+// struct fields are checked against the widths similarly to how
+// types.Info.InstantiateWithSizes assigns the sizes.
+func checkWidths(info types.Info, widths []int) error {
+	if len(widths) == 0 {
+		return nil
+	}
+	if info.Type == types.TStruct {
+		for idx, field := range info.Struct {
+			if idx >= len(widths) {
+				break
+			}
+			if err := checkWidths(field.Type, widths[idx:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if widths[0] > 0 && int(info.Bits) != widths[0] {
+		return fmt.Errorf("type %v does not support width %d",
+			info, widths[0])
+	}
+	return nil
+}
+
+func (f *Func) reset() {
+	f.NumInstances = 0
+	f.Returns = nil
+}
+
+// mainCode holds the front end results of the package's main
+// function.
+type mainCode struct {
+	main    *Func
+	gen     *ssa.Generator
+	init    *ssa.Block
+	inputs  circuit.IO
+	outputs circuit.IO
+}
 
+// compileMain runs the front end for the package's main function. It
+// resolves the types and generates the SSA instructions of the
+// package initialization and of the functions reachable from main.
+func (pkg *Package) compileMain(ctx *Codegen) (*mainCode, error) {
 	main, err := pkg.Main()
 	if err != nil {
-		return nil, nil, ctx.Error(utils.Point{
+		return nil, ctx.Error(utils.Point{
 			Source: pkg.Source,
 		}, err.Error())
 	}
@@ -60,7 +135,7 @@ func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
 	// Init package.
 	block, err := pkg.Init(ctx.Packages, init, ctx, gen)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Main block derives package's bindings from block with NextBlock().
@@ -71,27 +146,38 @@ func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
 	for idx, arg := range main.Args {
 		typeInfo, err := arg.Type.Resolve(NewEnv(ctx.Start()), ctx, gen)
 		if err != nil {
-			return nil, nil, ctx.Errorf(arg, "invalid argument type: %s", err)
+			return nil, ctx.Errorf(arg, "invalid argument type: %s", err)
+		}
+		var widths []int
+		if idx < len(ctx.Params.InputWidths) {
+			widths = ctx.Params.InputWidths[idx]
 		}
 		if !typeInfo.Concrete() {
-			if ctx.MainInputSizes == nil {
-				return nil, nil,
-					ctx.Errorf(arg, "argument %s of %s has unspecified type",
-						arg.Name, main)
+			if ctx.MainInputSizes == nil && widths == nil {
+				return nil, ctx.Errorf(arg,
+					"argument %s of %s has unspecified type", arg.Name, main)
 			}
 			// Specify unspecified argument type.
-			if idx >= len(ctx.MainInputSizes) {
-				return nil, nil, ctx.Errorf(arg,
+			if idx >= len(ctx.MainInputSizes) && widths == nil {
+				return nil, ctx.Errorf(arg,
 					"not enough values for argument %s of %s",
 					arg.Name, main)
 			}
-			err = typeInfo.InstantiateWithSizes(ctx.MainInputSizes[idx])
+			var sizes []int
+			if idx < len(ctx.MainInputSizes) {
+				sizes = ctx.MainInputSizes[idx]
+			}
+			err = typeInfo.InstantiateWithSizes(overrideSizes(sizes, widths))
 			if err != nil {
-				return nil, nil, ctx.Errorf(arg,
+				return nil, ctx.Errorf(arg,
 					"can't specify unspecified argument %s of %s: %s",
 					arg.Name, main, err)
 			}
 		}
+		if err := checkWidths(typeInfo, widths); err != nil {
+			return nil, ctx.Errorf(arg,
+				"invalid width for argument %s of %s: %s", arg.Name, main, err)
+		}
 		// Define argument in block.
 		a := gen.NewVal(arg.Name, typeInfo, ctx.Scope())
 		ctx.Start().Bindings.Define(a, nil)
@@ -110,22 +196,25 @@ func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
 	// Compile main.
 	_, returnVars, err := main.SSA(ctx.Start(), ctx, gen)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if err := ctx.countInstrs(main, gen); err != nil {
+		return nil, err
 	}
 
 	// Return values
 	var outputs circuit.IO
 	for idx, rt := range main.Return {
 		if idx >= len(returnVars) {
-			return nil, nil, fmt.Errorf("too few values for %s", main)
+			return nil, fmt.Errorf("too few values for %s", main)
 		}
 		typeInfo, err := rt.Type.Resolve(NewEnv(ctx.Start()), ctx, gen)
 		if err != nil {
-			return nil, nil, ctx.Errorf(rt, "invalid return type: %s", err)
+			return nil, ctx.Errorf(rt, "invalid return type: %s", err)
 		}
 		// Instantiate result values for template functions.
 		if !typeInfo.Concrete() && !typeInfo.Instantiate(returnVars[idx].Type) {
-			return nil, nil, ctx.Errorf(main,
+			return nil, ctx.Errorf(main,
 				"invalid value %v for return value %d of %s",
 				returnVars[idx].Type, idx, main)
 		}
@@ -134,9 +223,9 @@ func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
 			returnVars[idx].Type.Type = typeInfo.Type
 		}
 		if !ssa.CanAssign(typeInfo, returnVars[idx]) {
-			return nil, nil,
-				ctx.Errorf(main, "invalid value %v for return value %d of %s",
-					returnVars[idx].Type, idx, main)
+			return nil, ctx.Errorf(main,
+				"invalid value %v for return value %d of %s",
+				returnVars[idx].Type, idx, main)
 		}
 
 		v := returnVars[idx]
@@ -146,29 +235,62 @@ func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
 		})
 	}
 
-	steps := init.Serialize()
+	return &mainCode{
+		main:    main,
+		gen:     gen,
+		init:    init,
+		inputs:  inputs,
+		outputs: outputs,
+	}, nil
+}
+
+// Check runs the front end for the package's main function without
+// assembling the SSA program. The function returns the first error
+// that the type resolution and checks found.
+func (pkg *Package) Check(ctx *Codegen) error {
+	_, err := pkg.compileMain(ctx)
+	return err
+}
 
-	program, err := ssa.NewProgram(ctx.Params, inputs, outputs, gen.Constants(),
-		steps)
+// Compile compiles the package.
+func (pkg *Package) Compile(ctx *Codegen) (*ssa.Program, Annotations, error) {
+	code, err := pkg.compileMain(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := ctx.Canceled(); err != nil {
+		return nil, nil, err
+	}
+	steps := code.init.Serialize()
+
+	program, err := ssa.NewProgram(ctx.Params, code.inputs, code.outputs,
+		code.gen.Constants(), steps)
+	if err != nil {
+		return nil, nil, err
+	}
+	program.Init = code.init
 	if false { // XXX Peephole liveness analysis is broken.
 		err = program.Peephole()
 		if err != nil {
 			return nil, nil, err
 		}
 	}
+	if ctx.Params.OptCoalesceMovs {
+		program.CoalesceMovs()
+	}
 	program.GC()
 
 	if ctx.Params.SSAOut != nil {
 		program.PP(ctx.Params.SSAOut)
 	}
 	if ctx.Params.SSADotOut != nil {
-		ssa.Dot(ctx.Params.SSADotOut, init)
+		ssa.Dot(ctx.Params.SSADotOut, code.init)
+	}
+	if ctx.Params.SSACFGOut != nil {
+		program.DotCFG(ctx.Params.SSACFGOut)
 	}
 
-	return program, main.Annotations, nil
+	return program, code.main.Annotations, nil
 }
 
 // Main returns package's main function.
@@ -226,20 +348,9 @@ func (pkg *Package) Init(packages map[string]*Package, block *ssa.Block,
 		}
 	}
 
-	// Define constants.
-	for _, def := range pkg.Constants {
-		err := pkg.defineConstant(def, ctx, gen)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Define types.
-	for _, typeDef := range pkg.Types {
-		err := pkg.defineType(typeDef, ctx, gen)
-		if err != nil {
-			return nil, err
-		}
+	// Define constants and types.
+	if err := pkg.defineDecls(ctx, gen); err != nil {
+		return nil, err
 	}
 
 	// Package initializer block.
@@ -257,6 +368,9 @@ func (pkg *Package) Init(packages map[string]*Package, block *ssa.Block,
 		if err != nil {
 			return nil, err
 		}
+		if err := ctx.countInstrs(def, gen); err != nil {
+			return nil, err
+		}
 	}
 
 	pkg.Bindings = block.Bindings
@@ -284,7 +398,11 @@ func (pkg *Package) defineConstant(def *ConstantDef, ctx *Codegen,
 	}
 	constVar := gen.Constant(constVal, typeInfo)
 	if typeInfo.Undefined() {
-		typeInfo.Type = constVar.Type.Type
+		if constVar.Type.Type.Array() {
+			typeInfo = constVar.Type
+		} else {
+			typeInfo.Type = constVar.Type.Type
+		}
 	}
 	if !typeInfo.Concrete() {
 		typeInfo.Bits = constVar.Type.Bits
@@ -320,37 +438,7 @@ func (pkg *Package) defineType(def *TypeInfo, ctx *Codegen,
 	var err error
 
 	switch def.Type {
-	case TypeStruct:
-		// Construct compound type.
-		var fields []types.StructField
-		var bits types.Size
-		var minBits types.Size
-		var offset types.Size
-		for _, field := range def.StructFields {
-			info, err := field.Type.Resolve(env, ctx, gen)
-			if err != nil {
-				return err
-			}
-			field := types.StructField{
-				Name: field.Name,
-				Type: info,
-			}
-			field.Type.Offset = offset
-			fields = append(fields, field)
-
-			bits += info.Bits
-			minBits += info.MinBits
-			offset += info.Bits
-		}
-		info = types.Info{
-			Type:       types.TStruct,
-			IsConcrete: true,
-			Bits:       bits,
-			MinBits:    minBits,
-			Struct:     fields,
-		}
-
-	case TypeArray:
+	case TypeStruct, TypeArray:
 		info, err = def.Resolve(env, ctx, gen)
 		if err != nil {
 			return err
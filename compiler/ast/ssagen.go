@@ -8,10 +8,15 @@ package ast
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"slices"
+	"sort"
 
+	"github.com/markkurossi/mpc/compiler/circuits"
+	"github.com/markkurossi/mpc/compiler/mpa"
 	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
 	"github.com/markkurossi/mpc/types"
 	"github.com/markkurossi/tabulate"
 )
@@ -27,26 +32,51 @@ func (ast List) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	var err error
 
 	for _, b := range ast {
+		if err := ctx.Canceled(); err != nil {
+			return nil, nil, err
+		}
 		if block.Dead {
-			warn := true
+			// Report all unreachable statements, skipping the
+			// implicit return at the end of the function.
 			ret, ok := b.(*Return)
-			if ok && ret.AutoGenerated {
-				warn = false
+			if !ok || !ret.AutoGenerated {
+				ctx.Unreachable(stmtLocation(b))
 			}
-			if warn {
-				ctx.Warningf(b, "unreachable code")
-			}
-			break
+			continue
 		}
 		block, _, err = b.SSA(block, ctx, gen)
 		if err != nil {
 			return nil, nil, err
 		}
+		if err := ctx.countInstrs(stmtLocation(b), gen); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	return block, nil, nil
 }
 
+// stmtLocation returns the start location of the statement. The
+// assignment nodes are located at their assignment operator so their
+// location is taken from the first l-value.
+func stmtLocation(stmt AST) utils.Point {
+	assign, ok := stmt.(*Assign)
+	if ok && len(assign.LValues) > 0 {
+		return assign.LValues[0].Location()
+	}
+	return stmt.Location()
+}
+
+// hasAutoReturn tests if the function body already ends with the
+// implicit return statement from an earlier instantiation.
+func (ast *Func) hasAutoReturn() bool {
+	if len(ast.Body) == 0 {
+		return false
+	}
+	ret, ok := ast.Body[len(ast.Body)-1].(*Return)
+	return ok && ret.AutoGenerated
+}
+
 // SSA implements the compiler.ast.AST.SSA for function definitions.
 func (ast *Func) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -68,10 +98,12 @@ func (ast *Func) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		block.Bindings.Define(r, nil)
 	}
 
-	ast.Body = append(ast.Body, &Return{
-		Point:         ast.End,
-		AutoGenerated: true,
-	})
+	if !ast.hasAutoReturn() {
+		ast.Body = append(ast.Body, &Return{
+			Point:         ast.End,
+			AutoGenerated: true,
+		})
+	}
 
 	block, _, err := ast.Body.SSA(block, ctx, gen)
 	if err != nil {
@@ -197,12 +229,22 @@ func (ast *VariableDef) SSA(block *ssa.Block, ctx *Codegen,
 			typeInfo.SetConcrete(true)
 		}
 		if !typeInfo.CanAssignConst(init.Type) {
+			if init.Const && init.IntegerLike() &&
+				(typeInfo.Type == types.TInt || typeInfo.Type == types.TUint) {
+				return nil, nil, ctx.Errorf(ast,
+					"constant %v overflows %s (%d bits needed)",
+					init.ConstValue, typeInfo, init.Type.MinBits)
+			}
 			return nil, nil, ctx.Errorf(ast,
 				"cannot use %s (type %s) as type %s in assignment",
 				init, init.Type, typeInfo)
 		}
 
 		lValue := gen.NewVal(n, typeInfo, ctx.Scope())
+		if typeInfo.Type == types.TPtr {
+			// Nil pointers have no PtrInfo.
+			lValue.PtrInfo = init.PtrInfo
+		}
 		block.Bindings.Define(lValue, nil)
 
 		// Constant init values can be shared between different
@@ -220,6 +262,8 @@ func initValue(typeInfo types.Info) (interface{}, error) {
 		return int64(0), nil
 	case types.TString:
 		return "", nil
+	case types.TPtr:
+		return nil, nil
 	case types.TStruct:
 		var init []interface{}
 		for _, field := range typeInfo.Struct {
@@ -251,26 +295,35 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 
 	var values []ssa.Value
 
-	for _, expr := range ast.Exprs {
-		// Check if init value is constant.
-		env := NewEnv(block)
-		constVal, ok, err := expr.Eval(env, ctx, gen)
+	if index, ok := ast.commaOkIndex(); ok {
+		var err error
+		block, values, err = index.commaOkSSA(block, ctx, gen)
 		if err != nil {
 			return nil, nil, err
 		}
-		if ok {
-			gen.AddConstant(constVal)
-			values = append(values, constVal)
-		} else {
-			var v []ssa.Value
-			block, v, err = expr.SSA(block, ctx, gen)
+	} else {
+		for _, expr := range ast.Exprs {
+			// Check if init value is constant.
+			env := NewEnv(block)
+			constVal, ok, err := expr.Eval(env, ctx, gen)
 			if err != nil {
 				return nil, nil, err
 			}
-			if len(v) == 0 {
-				return nil, nil, ctx.Errorf(expr, "%s used as value", expr)
+			if ok {
+				gen.AddConstant(constVal)
+				values = append(values, constVal)
+			} else {
+				var v []ssa.Value
+				block, v, err = expr.SSA(block, ctx, gen)
+				if err != nil {
+					return nil, nil, err
+				}
+				if len(v) == 0 {
+					return nil, nil, ctx.Errorf(expr, "%s used as value",
+						expr)
+				}
+				values = append(values, v...)
 			}
-			values = append(values, v...)
 		}
 	}
 	if len(ast.LValues) != len(values) {
@@ -321,6 +374,7 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 			var v []ssa.Value
 			var indices []arrayIndex
 			var lrv *LRValue
+			var dynamic bool
 			idx := lv
 
 			for lrv == nil {
@@ -331,14 +385,19 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 				if len(v) != 1 {
 					return nil, nil, ctx.Errorf(idx.Index, "invalid index")
 				}
-				index, err := v[0].ConstInt()
-				if err != nil {
-					return nil, nil, ctx.Error(idx.Index, err.Error())
-				}
-				indices = append(indices, arrayIndex{
-					i:   index,
+				index := arrayIndex{
+					v:   v[0],
 					ast: idx.Index,
-				})
+				}
+				if v[0].Const {
+					index.i, err = v[0].ConstInt()
+					if err != nil {
+						return nil, nil, ctx.Error(idx.Index, err.Error())
+					}
+				} else {
+					dynamic = true
+				}
+				indices = append(indices, index)
 				switch i := idx.Expr.(type) {
 				case *Index:
 					idx = i
@@ -360,33 +419,44 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 			slices.Reverse(indices)
 
 			lrv = lrv.Indirect()
-			t := lrv.ValueType()
-			var offset types.Size
 
-			for _, index := range indices {
-				if !t.Type.Array() {
-					return nil, nil, ctx.Errorf(index.ast,
-						"setting elements of non-array %s (%s)", lv.Expr, t)
+			var val ssa.Value
+			if dynamic {
+				val, err = ast.indexStore(block, ctx, gen, lvalue,
+					lrv.RValue(), lrv.ValueType(), indices, rv)
+				if err != nil {
+					return nil, nil, err
 				}
-				if index.i >= t.ArraySize {
-					return nil, nil, ctx.Errorf(index.ast,
-						"invalid array index %d (out of bounds for %d-element array)",
-						index.i, t.ArraySize)
+			} else {
+				t := lrv.ValueType()
+				var offset types.Size
+
+				for _, index := range indices {
+					if !t.Type.Array() {
+						return nil, nil, ctx.Errorf(index.ast,
+							"setting elements of non-array %s (%s)",
+							lv.Expr, t)
+					}
+					if index.i >= t.ArraySize {
+						return nil, nil, ctx.Errorf(index.ast,
+							"invalid array index %d (out of bounds for %d-element array)",
+							index.i, t.ArraySize)
+					}
+					offset += index.i * t.ElementType.Bits
+					t = *t.ElementType
 				}
-				offset += index.i * t.ElementType.Bits
-				t = *t.ElementType
-			}
 
-			if !ssa.CanAssign(t, rv) {
-				return nil, nil, ctx.Errorf(lvalue,
-					"cannot assign %v to variable of type %v", rv.Type, t)
-			}
+				if !ssa.CanAssign(t, rv) {
+					return nil, nil, ctx.Errorf(lvalue,
+						"cannot assign %v to variable of type %v", rv.Type, t)
+				}
 
-			val := gen.AnonVal(lrv.ValueType())
-			fromConst := gen.Constant(int64(offset), types.Undefined)
-			toConst := gen.Constant(int64(offset+t.Bits), types.Undefined)
-			block.AddInstr(ssa.NewAmovInstr(rv, lrv.RValue(), fromConst,
-				toConst, val))
+				val = gen.AnonVal(lrv.ValueType())
+				fromConst := gen.Constant(int64(offset), types.Undefined)
+				toConst := gen.Constant(int64(offset+t.Bits), types.Undefined)
+				block.AddInstr(ssa.NewAmovInstr(rv, lrv.RValue(), fromConst,
+					toConst, val))
+			}
 
 			err = lrv.Set(val)
 			if err != nil {
@@ -413,6 +483,10 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 						"cannot assign non-pointer: %s", ptr.Name)
 				}
 				v := b.Value(block, gen)
+				if v.PtrInfo == nil {
+					return nil, nil, ctx.Errorf(ast,
+						"nil pointer dereference: %s", ptr.Name)
+				}
 				dstName := v.PtrInfo.Name
 				dstType := v.PtrInfo.ContainerType
 				dstScope := v.PtrInfo.Scope
@@ -465,13 +539,112 @@ func (ast *Assign) SSA(block *ssa.Block, ctx *Codegen,
 
 type arrayIndex struct {
 	i   types.Size
+	v   ssa.Value
 	ast AST
 }
 
 func (i arrayIndex) String() string {
+	if !i.v.Const {
+		return i.v.String()
+	}
 	return fmt.Sprintf("%d", i.i)
 }
 
+// indexStore returns the value of the array arr of type t with the
+// element at indices set to rv. Constant indices update their
+// element directly. Non-constant indices update each element of
+// their dimension with a multiplexer that selects the new value if
+// the index matches the element's position. The out-of-bounds
+// non-constant indices leave the array unchanged.
+func (ast *Assign) indexStore(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, lvalue AST, arr ssa.Value, t types.Info,
+	indices []arrayIndex, rv ssa.Value) (ssa.Value, error) {
+
+	if len(indices) == 0 {
+		if !ssa.CanAssign(t, rv) {
+			return ssa.Undefined, ctx.Errorf(lvalue,
+				"cannot assign %v to variable of type %v", rv.Type, t)
+		}
+		return rv, nil
+	}
+	index := indices[0]
+	if !t.Type.Array() {
+		return ssa.Undefined, ctx.Errorf(index.ast,
+			"setting elements of non-array %s (%s)", lvalue, t)
+	}
+	if index.v.Const {
+		if index.i < 0 || index.i >= t.ArraySize {
+			return ssa.Undefined, ctx.Errorf(index.ast,
+				"invalid array index %d (out of bounds for %d-element array)",
+				index.i, t.ArraySize)
+		}
+		return ast.elementStore(block, ctx, gen, lvalue, arr, t, index.i,
+			nil, indices[1:], rv)
+	}
+
+	// The number of element positions the index type can select.
+	count := t.ArraySize
+	bits := index.v.Type.Bits
+	if index.v.Type.Type == types.TInt {
+		bits--
+	}
+	if bits < 31 && count > 1<<bits {
+		count = 1 << bits
+	}
+
+	for i := types.Size(0); i < count; i++ {
+		pos := gen.Constant(int64(i), index.v.Type)
+		gen.AddConstant(pos)
+		cond := gen.AnonVal(types.Bool)
+		instr, err := ssa.NewEqInstr(index.v, pos, cond)
+		if err != nil {
+			return ssa.Undefined, ctx.Errorf(index.ast, "%s", err)
+		}
+		block.AddInstr(instr)
+
+		arr, err = ast.elementStore(block, ctx, gen, lvalue, arr, t, i,
+			&cond, indices[1:], rv)
+		if err != nil {
+			return ssa.Undefined, err
+		}
+	}
+	return arr, nil
+}
+
+// elementStore returns the value of the array arr of type t with its
+// element i updated with indices and rv. If cond is not nil, the
+// element is updated only if cond is set.
+func (ast *Assign) elementStore(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, lvalue AST, arr ssa.Value, t types.Info,
+	i types.Size, cond *ssa.Value, indices []arrayIndex, rv ssa.Value) (
+	ssa.Value, error) {
+
+	et := *t.ElementType
+	if et.Bits == 0 {
+		return arr, nil
+	}
+	fromConst := gen.Constant(int64(i*et.Bits), types.Undefined)
+	toConst := gen.Constant(int64((i+1)*et.Bits), types.Undefined)
+
+	elem := gen.AnonVal(et)
+	block.AddInstr(ssa.NewSliceInstr(arr, fromConst, toConst, elem))
+
+	v, err := ast.indexStore(block, ctx, gen, lvalue, elem, et, indices, rv)
+	if err != nil {
+		return ssa.Undefined, err
+	}
+	if cond != nil {
+		sel := gen.AnonVal(et)
+		block.AddInstr(ssa.NewPhiInstr(*cond, v, elem, sel))
+		v = sel
+	}
+
+	result := gen.AnonVal(t)
+	block.AddInstr(ssa.NewAmovInstr(v, arr, fromConst, toConst, result))
+
+	return result, nil
+}
+
 // SSA implements the compiler.ast.AST.SSA for if statements.
 func (ast *If) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -517,7 +690,9 @@ func (ast *If) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	tBlock := gen.BranchBlock(block)
 
 	// True branch.
+	ctx.pushBranch(e[0], false)
 	tNext, _, err := ast.True.SSA(tBlock, ctx, gen)
+	ctx.popBranch()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -538,7 +713,9 @@ func (ast *If) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 
 	fBlock := gen.NextBlock(block)
 
+	ctx.pushBranch(e[0], true)
 	fNext, _, err := ast.False.SSA(fBlock, ctx, gen)
+	ctx.popBranch()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -568,6 +745,73 @@ func (ast *If) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	return next, nil, nil
 }
 
+// argument returns the call expression that produces the argument
+// idx. A single multi-value expression produces all arguments.
+func (ast *Call) argument(idx int) AST {
+	if len(ast.Exprs) == 1 {
+		return ast.Exprs[0]
+	}
+	return ast.Exprs[idx]
+}
+
+// checkFuncArg checks that the function value v, bound to the
+// argument idx, matches the argument's function type. The argument
+// and return types are compared if both types are concrete.
+func (ast *Call) checkFuncArg(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, called *Func, idx int, v ssa.Value) error {
+
+	arg := called.Args[idx]
+	fn, ok := v.ConstValue.(*ssa.Function)
+	if !ok {
+		return ctx.Errorf(ast.argument(idx),
+			"function value %s is not constant", ast.argument(idx))
+	}
+	f := fn.Func.(*Func)
+	sig := arg.Type
+	for sig.Type == TypeAlias {
+		sig = sig.AliasType
+	}
+	if sig.Type != TypeFunc {
+		return nil
+	}
+	ft := f.funcType()
+	match := len(sig.FuncArgs) == len(ft.FuncArgs) &&
+		len(sig.FuncReturn) == len(ft.FuncReturn)
+
+	env := NewEnv(block)
+	pairs := [][2][]*TypeInfo{
+		{sig.FuncArgs, ft.FuncArgs},
+		{sig.FuncReturn, ft.FuncReturn},
+	}
+	for _, pair := range pairs {
+		if !match {
+			break
+		}
+		for i, ti := range pair[0] {
+			want, err := ti.Resolve(env, ctx, gen)
+			if err != nil {
+				return err
+			}
+			have, err := pair[1][i].Resolve(env, ctx, gen)
+			if err != nil {
+				return err
+			}
+			if want.Concrete() && have.Concrete() &&
+				!want.Equal(have) {
+				match = false
+				break
+			}
+		}
+	}
+	if !match {
+		expr := ast.argument(idx)
+		return ctx.Errorf(expr,
+			"cannot use %s (type %s) as type %s in argument %s to %s",
+			expr, ft, sig, arg.Name, called.Name)
+	}
+	return nil
+}
+
 // SSA implements the compiler.ast.AST.SSA for call expressions.
 func (ast *Call) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -678,6 +922,17 @@ func (ast *Call) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 	}
 
+	if ctx.Params.SubcircuitCalls {
+		b, result, ok, err := ast.subcircuitCall(block, ctx, gen,
+			called, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return b, result, nil
+		}
+	}
+
 	// Return block.
 	rblock := gen.Block()
 	rblock.Bindings = block.Bindings.Clone()
@@ -691,15 +946,19 @@ func (ast *Call) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 			return nil, nil, ctx.Errorf(arg, "invalid argument type: %s", err)
 		}
 		// Instantiate argument types of template functions.
-		if !typeInfo.Concrete() && !typeInfo.Instantiate(args[idx].Type) {
-			return nil, nil, ctx.Errorf(ast.Exprs[idx],
-				"cannot use %v as type %s in argument to %s",
-				args[idx].Type, typeInfo, called.Name)
+		if (!typeInfo.Concrete() && !typeInfo.Instantiate(args[idx].Type)) ||
+			!ssa.CanAssign(typeInfo, args[idx]) {
+			expr := ast.argument(idx)
+			return nil, nil, ctx.Errorf(expr,
+				"cannot use %s (type %v) as type %s in argument %s to %s",
+				expr, args[idx].Type, typeInfo, arg.Name, called.Name)
 		}
-		if !ssa.CanAssign(typeInfo, args[idx]) {
-			return nil, nil, ctx.Errorf(ast,
-				"cannot use %v as type %s in argument to %s",
-				args[idx].Type, typeInfo, called.Name)
+		if typeInfo.Type == types.TFunc {
+			err = ast.checkFuncArg(block, ctx, gen, called, idx,
+				args[idx])
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 		a := gen.NewVal(arg.Name, args[idx].Type, ctx.Scope())
 		a.PtrInfo = args[idx].PtrInfo
@@ -731,7 +990,6 @@ func (ast *Call) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 			}
 		}
 
-		// XXX only one level of pointers.
 		if typeInfo.Type == types.TPtr && b.Type.Type != types.TPtr {
 			// Pointer receiver.
 			this = gen.AnonVal(types.Info{
@@ -767,6 +1025,9 @@ func (ast *Call) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := ctx.countInstrs(ast, gen); err != nil {
+		return nil, nil, err
+	}
 
 	block.SetNext(ctx.Start())
 
@@ -791,6 +1052,9 @@ func (ast *Call) cast(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 		typeInfo.Bits = cv.Type.Bits
 		typeInfo.SetConcrete(true)
 	}
+	if typeInfo.Type == types.TFixed || cv.Type.Type == types.TFixed {
+		return ast.castFixed(block, ctx, gen, typeInfo, cv)
+	}
 
 	var t ssa.Value
 
@@ -849,6 +1113,63 @@ castTargetType:
 	return block, []ssa.Value{t}, nil
 }
 
+// castFixed casts the value cv between integer and fixed-point
+// types. The value is scaled by the difference of the fractional bits
+// of the types and truncated to the size of the target type.
+func (ast *Call) castFixed(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, typeInfo types.Info, cv ssa.Value) (
+	*ssa.Block, []ssa.Value, error) {
+
+	var from, to types.Size
+
+	switch cv.Type.Type {
+	case types.TInt, types.TUint:
+	case types.TFixed:
+		from = cv.Type.FracBits
+	default:
+		return nil, nil, ctx.Errorf(ast.Exprs[0], "cast from %v to %v",
+			cv.Type, typeInfo)
+	}
+	switch typeInfo.Type {
+	case types.TInt, types.TUint:
+	case types.TFixed:
+		to = typeInfo.FracBits
+	default:
+		return nil, nil, ctx.Errorf(ast.Exprs[0], "cast from %v to %v",
+			cv.Type, typeInfo)
+	}
+
+	// Extend the value so that scaling does not drop integer bits.
+	bits := max(cv.Type.Bits, typeInfo.Bits) + to
+	wide := types.Info{
+		Type:       types.TInt,
+		IsConcrete: true,
+		Bits:       bits,
+		MinBits:    bits,
+	}
+	v := gen.AnonVal(wide)
+	if cv.Type.Type == types.TUint {
+		block.AddInstr(ssa.NewMovInstr(cv, v))
+	} else {
+		block.AddInstr(ssa.NewSmovInstr(cv, v))
+	}
+	if to > from {
+		s := gen.AnonVal(wide)
+		shift := gen.Constant(int64(to-from), types.Undefined)
+		block.AddInstr(ssa.NewLshiftInstr(v, shift, s))
+		v = s
+	} else if from > to {
+		s := gen.AnonVal(wide)
+		shift := gen.Constant(int64(from-to), types.Undefined)
+		block.AddInstr(ssa.NewSrshiftInstr(v, shift, s))
+		v = s
+	}
+	t := gen.AnonVal(typeInfo)
+	block.AddInstr(ssa.NewMovInstr(v, t))
+
+	return block, []ssa.Value{t}, nil
+}
+
 func (ast *Call) error(ctx *Codegen, message string, have [][]ssa.Value,
 	want []*Variable) error {
 
@@ -931,6 +1252,16 @@ func (ast *ArrayCast) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return nil, nil, err
 	}
+	if typeInfo.Type == types.TStruct {
+		// Struct conversions require identical field names and types.
+		if !sameStruct(cv.Type, typeInfo) {
+			return nil, nil, ctx.Errorf(ast.Expr, "cast from %v to %v",
+				cv.Type, ast.TypeInfo)
+		}
+		t := gen.AnonVal(typeInfo)
+		block.AddInstr(ssa.NewMovInstr(cv, t))
+		return block, []ssa.Value{t}, nil
+	}
 	if !typeInfo.Type.Array() {
 		return nil, nil, ctx.Errorf(ast.Expr, "array cast to non-array type %v",
 			typeInfo)
@@ -975,6 +1306,35 @@ func (ast *ArrayCast) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	return block, []ssa.Value{t}, nil
 }
 
+// sameStruct tests if the struct types a and b have identical fields.
+func sameStruct(a, b types.Info) bool {
+	if a.Type != types.TStruct || !a.Equal(b) {
+		return false
+	}
+	for idx, f := range a.Struct {
+		if f.Name != b.Struct[idx].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// valueError creates an error for the invalid return value idx. The
+// error is reported at the expression that produces the value.
+func (ast *Return) valueError(ctx *Codegen, exprs []AST, idx int,
+	got, expected types.Info) error {
+
+	var expr AST = ast
+	if len(exprs) == 1 {
+		expr = exprs[0]
+	} else if idx < len(exprs) {
+		expr = exprs[idx]
+	}
+	return ctx.Errorf(expr,
+		"cannot use %s (type %v) as type %v in return value %d",
+		expr, got, expected, idx)
+}
+
 // SSA implements the compiler.ast.AST.SSA for return statements.
 func (ast *Return) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -1071,8 +1431,7 @@ func (ast *Return) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 		// Instantiate result values for template functions.
 		if !typeInfo.Concrete() && !typeInfo.Instantiate(result[idx].Type) {
-			return nil, nil, ctx.Errorf(ast,
-				"invalid value %v for return value %v",
+			return nil, nil, ast.valueError(ctx, exprs, idx,
 				result[idx].Type, typeInfo)
 		}
 		info.Types = append(info.Types, typeInfo)
@@ -1087,8 +1446,7 @@ func (ast *Return) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 
 		if !ssa.CanAssign(typeInfo, result[idx]) {
-			return nil, nil, ctx.Errorf(ast,
-				"invalid value %v for return value %v",
+			return nil, nil, ast.valueError(ctx, exprs, idx,
 				result[idx].Type, v.Type)
 		}
 
@@ -1099,12 +1457,281 @@ func (ast *Return) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 	}
 
+	// Run deferred statements after the return values are set so
+	// they can modify the named return values.
+	block, err = ctx.runDefers(block, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	block.SetNext(ctx.Return())
 	block.Dead = true
 
 	return block, nil, nil
 }
 
+// SSA implements the compiler.ast.AST.SSA for defer statements. The
+// deferred statement is registered to the current function and it is
+// executed when the function returns. The deferred statements of
+// non-constant conditional branches are executed with the branch
+// conditions.
+//
+// The variables that the deferred statement assigns, takes the
+// address of, or calls methods on are accessed when the statement is
+// executed. All other variables are evaluated at the defer statement,
+// like the arguments of deferred function calls in Go.
+func (ast *Defer) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
+	*ssa.Block, []ssa.Value, error) {
+
+	if ctx.Func() == nil {
+		return nil, nil, ctx.Errorf(ast, "defer outside function")
+	}
+	c := &ctx.Stack[len(ctx.Stack)-1]
+	if c.deferring {
+		return nil, nil, ctx.Errorf(ast, "defer in deferred statement")
+	}
+	d := deferred{
+		stmt: ast.Stmt,
+	}
+	reads := make(map[string]bool)
+	writes := make(map[string]bool)
+	stmtRefs(ast.Stmt, reads, writes)
+	for name := range reads {
+		if writes[name] {
+			continue
+		}
+		b, ok := block.Bindings.Get(name)
+		if ok {
+			d.captured = append(d.captured, b)
+		}
+	}
+	sort.Slice(d.captured, func(i, j int) bool {
+		return d.captured[i].Name < d.captured[j].Name
+	})
+	for _, bc := range ctx.branchConds[c.branchBase:] {
+		v := bc.value
+		if bc.negate {
+			t := gen.AnonVal(types.Bool)
+			instr, err := ssa.NewNotInstr(v, t)
+			if err != nil {
+				return nil, nil, err
+			}
+			block.AddInstr(instr)
+			v = t
+		}
+		if d.conditional {
+			t := gen.AnonVal(types.Bool)
+			instr, err := ssa.NewAndInstr(d.cond, v, t)
+			if err != nil {
+				return nil, nil, err
+			}
+			block.AddInstr(instr)
+			v = t
+		}
+		d.cond = v
+		d.conditional = true
+	}
+	c.defers = append(c.defers, d)
+
+	return block, nil, nil
+}
+
+// runDefers generates the deferred statements of the current
+// function in LIFO order.
+func (ctx *Codegen) runDefers(block *ssa.Block, gen *ssa.Generator) (
+	*ssa.Block, error) {
+
+	// The deferred statements can call functions which push new
+	// compilations to the stack so the current compilation is
+	// accessed by its index.
+	n := len(ctx.Stack) - 1
+	ctx.Stack[n].deferring = true
+	defer func() {
+		ctx.Stack[n].deferring = false
+	}()
+	defers := ctx.Stack[n].defers
+
+	var err error
+	for i := len(defers) - 1; i >= 0; i-- {
+		d := defers[i]
+		if !d.conditional {
+			exit := d.capture(block.Bindings)
+			block, _, err = d.stmt.SSA(block, ctx, gen)
+			if err != nil {
+				return nil, err
+			}
+			d.restore(block.Bindings, exit)
+			continue
+		}
+		block.BranchCond = d.cond
+		tBlock := gen.BranchBlock(block)
+
+		exit := d.capture(tBlock.Bindings)
+		ctx.pushBranch(d.cond, false)
+		tNext, _, err := d.stmt.SSA(tBlock, ctx, gen)
+		ctx.popBranch()
+		if err != nil {
+			return nil, err
+		}
+		d.restore(tNext.Bindings, exit)
+		tNext.Bindings = tNext.Bindings.Merge(d.cond, block.Bindings)
+		block.SetNext(tNext)
+		block = tNext
+	}
+	return block, nil
+}
+
+// capture sets the captured variable values to the bindings and
+// returns the bindings they replaced.
+func (d deferred) capture(bindings *ssa.Bindings) []ssa.Binding {
+	var exit []ssa.Binding
+	for _, b := range d.captured {
+		cur, ok := bindings.Get(b.Name)
+		if !ok {
+			continue
+		}
+		exit = append(exit, cur)
+		bindings.SetBinding(b)
+	}
+	return exit
+}
+
+// restore restores the exit values of the captured variables unless
+// the deferred statement modified them through pointers.
+func (d deferred) restore(bindings *ssa.Bindings, exit []ssa.Binding) {
+	for _, e := range exit {
+		cur, ok := bindings.Get(e.Name)
+		if !ok {
+			continue
+		}
+		for _, b := range d.captured {
+			if b.Name == e.Name && cur.Bound.Equal(b.Bound) {
+				bindings.SetBinding(e)
+				break
+			}
+		}
+	}
+}
+
+// stmtRefs collects the variables that the statement ast reads and
+// modifies. The variables whose address is taken and the method call
+// receivers are collected as modified.
+func stmtRefs(ast AST, reads, writes map[string]bool) {
+	switch ast := ast.(type) {
+	case List:
+		for _, stmt := range ast {
+			stmtRefs(stmt, reads, writes)
+		}
+	case *VariableDef:
+		for _, name := range ast.Names {
+			writes[name] = true
+		}
+		exprRefs(ast.Init, reads, writes)
+	case *Assign:
+		for _, lv := range ast.LValues {
+			lvalueRefs(lv, reads, writes)
+		}
+		for _, expr := range ast.Exprs {
+			exprRefs(expr, reads, writes)
+		}
+	case *If:
+		exprRefs(ast.Expr, reads, writes)
+		stmtRefs(ast.True, reads, writes)
+		stmtRefs(ast.False, reads, writes)
+	case *For:
+		stmtRefs(ast.Init, reads, writes)
+		exprRefs(ast.Cond, reads, writes)
+		stmtRefs(ast.Inc, reads, writes)
+		stmtRefs(ast.Body, reads, writes)
+	case *ForRange:
+		for _, lv := range ast.ExprList {
+			lvalueRefs(lv, reads, writes)
+		}
+		exprRefs(ast.Expr, reads, writes)
+		stmtRefs(ast.Body, reads, writes)
+	case *Copy:
+		lvalueRefs(ast.Dst, reads, writes)
+		exprRefs(ast.Src, reads, writes)
+	case *Send:
+		lvalueRefs(ast.Chan, reads, writes)
+		exprRefs(ast.Value, reads, writes)
+	default:
+		exprRefs(ast, reads, writes)
+	}
+}
+
+// exprRefs collects the variables that the expression ast reads and
+// modifies.
+func exprRefs(ast AST, reads, writes map[string]bool) {
+	switch ast := ast.(type) {
+	case *VariableRef:
+		if ast.Name.Qualified() {
+			reads[ast.Name.Package] = true
+		} else {
+			reads[ast.Name.Name] = true
+		}
+	case *Binary:
+		exprRefs(ast.Left, reads, writes)
+		exprRefs(ast.Right, reads, writes)
+	case *Unary:
+		switch ast.Type {
+		case UnaryAddr, UnarySend:
+			lvalueRefs(ast.Expr, reads, writes)
+		default:
+			exprRefs(ast.Expr, reads, writes)
+		}
+	case *Call:
+		if ast.Ref.Name.Qualified() {
+			writes[ast.Ref.Name.Package] = true
+		}
+		for _, expr := range ast.Exprs {
+			exprRefs(expr, reads, writes)
+		}
+	case *ArrayCast:
+		exprRefs(ast.Expr, reads, writes)
+	case *Slice:
+		exprRefs(ast.Expr, reads, writes)
+		exprRefs(ast.From, reads, writes)
+		exprRefs(ast.To, reads, writes)
+		exprRefs(ast.Max, reads, writes)
+	case *Index:
+		exprRefs(ast.Expr, reads, writes)
+		exprRefs(ast.Index, reads, writes)
+	case *CompositeLit:
+		for _, e := range ast.Value {
+			exprRefs(e.Key, reads, writes)
+			exprRefs(e.Element, reads, writes)
+		}
+	case *Make:
+		for _, expr := range ast.Exprs {
+			exprRefs(expr, reads, writes)
+		}
+	}
+}
+
+// lvalueRefs collects the variable that the lvalue ast modifies and
+// the variables its index expressions read.
+func lvalueRefs(ast AST, reads, writes map[string]bool) {
+	switch ast := ast.(type) {
+	case *VariableRef:
+		if ast.Name.Qualified() {
+			writes[ast.Name.Package] = true
+		} else {
+			writes[ast.Name.Name] = true
+		}
+	case *Index:
+		lvalueRefs(ast.Expr, reads, writes)
+		exprRefs(ast.Index, reads, writes)
+	case *Slice:
+		lvalueRefs(ast.Expr, reads, writes)
+		exprRefs(ast.From, reads, writes)
+		exprRefs(ast.To, reads, writes)
+		exprRefs(ast.Max, reads, writes)
+	default:
+		exprRefs(ast, reads, writes)
+	}
+}
+
 func (ast *Return) error(ctx *Codegen, message string, have [][]ssa.Value,
 	want []*Variable) error {
 
@@ -1168,42 +1795,62 @@ func (ast *For) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 	}
 
+	// Warn about large loops before unrolling them.
+	warn := gen.Params.LoopUnrollWarn
+	if warn > 0 && !ctx.largeLoops[ast.Location()] {
+		count, ok := ast.estimateUnroll(env, ctx, gen)
+		if ok && count > warn {
+			ctx.LargeLoop(ast, count)
+		}
+	}
+
 	// Expand body as long as condition is true.
 	for i := 0; ; i++ {
 		if i >= gen.Params.MaxLoopUnroll {
 			return nil, nil, ctx.Errorf(ast,
 				"for-loop unroll limit exceeded: %d", i)
 		}
-		constVal, ok, err := ast.Cond.Eval(env, ctx, gen)
-		if err != nil {
+		if err := ctx.Canceled(); err != nil {
 			return nil, nil, err
 		}
-		if !ok {
-			return nil, nil, ctx.Errorf(ast.Cond,
-				"condition is not compile-time constant: %s", ast.Cond)
-		}
-		val, ok := constVal.ConstValue.(bool)
-		if !ok {
-			return nil, nil, ctx.Errorf(ast.Cond,
-				"condition is not boolean expression")
-		}
-		if !val {
-			// Loop completed.
-			break
+		// Loops without condition run until the body terminates.
+		if ast.Cond != nil {
+			constVal, ok, err := ast.Cond.Eval(env, ctx, gen)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				return nil, nil, ctx.Errorf(ast.Cond,
+					"condition is not compile-time constant: %s", ast.Cond)
+			}
+			val, ok := constVal.ConstValue.(bool)
+			if !ok {
+				return nil, nil, ctx.Errorf(ast.Cond,
+					"condition is not boolean expression")
+			}
+			if !val {
+				// Loop completed.
+				break
+			}
 		}
 
 		// Expand block.
+		var err error
 		block.Bindings = env.Bindings
 		block, _, err = ast.Body.SSA(block, ctx, gen)
 		if err != nil {
 			return nil, nil, err
 		}
 		env.Bindings = block.Bindings
+		if block.Dead {
+			// Body terminated, the loop does not continue.
+			break
+		}
 
 		// Increment.
 		if ast.Inc != nil {
 			env = NewEnv(block)
-			_, ok, err = ast.Inc.Eval(env, ctx, gen)
+			_, ok, err := ast.Inc.Eval(env, ctx, gen)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -1221,6 +1868,44 @@ func (ast *For) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	return block, nil, nil
 }
 
+// estimateUnroll estimates the loop's unroll count by evaluating its
+// condition and increment statements without the loop body. The
+// estimate is capped to MaxLoopUnroll+1 iterations. The function
+// returns false if the count can't be estimated at compile time.
+func (ast *For) estimateUnroll(env *Env, ctx *Codegen, gen *ssa.Generator) (
+	int, bool) {
+
+	if ast.Cond == nil || ast.Inc == nil {
+		return 0, false
+	}
+	env = &Env{
+		Bindings: env.Bindings.Clone(),
+	}
+	var count int
+	for ; count <= gen.Params.MaxLoopUnroll; count++ {
+		if ctx.Canceled() != nil {
+			// The loop unrolling reports the cancellation.
+			return 0, false
+		}
+		constVal, ok, err := ast.Cond.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			return 0, false
+		}
+		val, ok := constVal.ConstValue.(bool)
+		if !ok {
+			return 0, false
+		}
+		if !val {
+			break
+		}
+		_, ok, err = ast.Inc.Eval(env, ctx, gen)
+		if err != nil || !ok {
+			return 0, false
+		}
+	}
+	return count, true
+}
+
 // SSA implements the compiler.ast.AST.SSA for for statements.
 func (ast *ForRange) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -1294,6 +1979,9 @@ func (ast *ForRange) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 
 	// Expand body for each element in value.
 	for i := 0; i < count; i++ {
+		if err := ctx.Canceled(); err != nil {
+			return nil, nil, err
+		}
 		// Index variable.
 		if len(idxVar) > 0 {
 			idxConst := gen.Constant(int64(i), types.Undefined)
@@ -1370,6 +2058,10 @@ func (ast *ForRange) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		if err != nil {
 			return nil, nil, err
 		}
+		if block.Dead {
+			// Body terminated, the loop does not continue.
+			break
+		}
 	}
 
 	return block, nil, nil
@@ -1486,18 +2178,55 @@ func (ast *Binary) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return nil, nil, err
 	}
+	l = sliceElements(block, gen, l)
+	r = sliceElements(block, gen, r)
+
+	ptrCmp, ok, err := ast.comparePtr(ctx, gen, l, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		gen.AddConstant(ptrCmp)
+		return block, []ssa.Value{ptrCmp}, nil
+	}
+
+	// Untyped constants take the type of the other operand. The
+	// shift count keeps its own type.
+	switch ast.Op {
+	case BinaryLshift, BinaryRshift:
+	default:
+		l, err = convertConst(ctx, ast, gen, l, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, err = convertConst(ctx, ast, gen, r, l)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Resolve target type.
 	resultType, err := ast.resultType(ctx, l, r)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := ast.checkShift(ctx, l, r, true); err != nil {
+		return nil, nil, err
+	}
 	t := gen.AnonVal(resultType)
 
 	var instr ssa.Instr
 	switch ast.Op {
 	case BinaryMul:
-		instr, err = ssa.NewMultInstr(l.Type, l, r, t)
+		if resultType.Type == types.TFixed {
+			frac := int(resultType.FracBits)
+			instr = ssa.NewBuiltinInstr(
+				func(cc *circuits.Compiler, a, b, r []*circuits.Wire) error {
+					return circuits.NewFixedMultiplier(cc, frac, a, b, r)
+				}, l, r, t)
+		} else {
+			instr, err = ssa.NewMultInstr(l.Type, l, r, t)
+		}
 	case BinaryDiv:
 		instr, err = ssa.NewDivInstr(l.Type, l, r, t)
 	case BinaryMod:
@@ -1611,6 +2340,127 @@ func (ast *Binary) resultType(ctx *Codegen, l, r ssa.Value) (
 	return resultType, nil
 }
 
+// comparePtr folds the comparisons of pointer values. The pointer
+// targets are resolved at compile time so the comparison results are
+// constants. The nil value takes the pointer type of the other
+// operand.
+func (ast *Binary) comparePtr(ctx *Codegen, gen *ssa.Generator,
+	l, r ssa.Value) (ssa.Value, bool, error) {
+
+	if l.Type.Type != types.TPtr && r.Type.Type != types.TPtr {
+		return ssa.Undefined, false, nil
+	}
+	switch ast.Op {
+	case BinaryEq, BinaryNeq:
+	default:
+		return ssa.Undefined, false, ctx.Errorf(ast,
+			"invalid operation: operator %s not defined on %s", ast.Op, l)
+	}
+	if !ptrComparable(l.Type, r.Type) || !ptrComparable(r.Type, l.Type) {
+		return ssa.Undefined, false, ctx.Errorf(ast,
+			"invalid operation: %s %s %s (mismatched types %s and %s)",
+			ast.Left, ast.Op, ast.Right, l.Type, r.Type)
+	}
+
+	var equal bool
+	if l.PtrInfo == nil || r.PtrInfo == nil {
+		equal = l.PtrInfo == r.PtrInfo
+	} else {
+		equal = l.PtrInfo.Name == r.PtrInfo.Name &&
+			l.PtrInfo.Scope == r.PtrInfo.Scope &&
+			l.PtrInfo.Offset == r.PtrInfo.Offset
+	}
+	if ast.Op == BinaryNeq {
+		equal = !equal
+	}
+	return gen.Constant(equal, types.Bool), true, nil
+}
+
+func ptrComparable(t, o types.Info) bool {
+	switch t.Type {
+	case types.TNil:
+		return o.Type == types.TPtr
+	case types.TPtr:
+		return o.Type == types.TNil ||
+			(o.Type == types.TPtr && t.ElementType.Equal(*o.ElementType))
+	default:
+		return false
+	}
+}
+
+// checkShift checks the shift count r of the shift operation
+// l<<r or l>>r. Negative constant counts are errors. The constant
+// counts that are equal to or greater than the width of l are valid
+// but often bugs so they are reported as warnings if warn is
+// true. Their results are defined like the results of shifting l one
+// bit at a time: zero for the left shifts and for the right shifts of
+// unsigned values, and the sign bits (0 or -1) for the right shifts
+// of signed values.
+func (ast *Binary) checkShift(ctx *Codegen, l, r ssa.Value,
+	warn bool) error {
+
+	switch ast.Op {
+	case BinaryLshift, BinaryRshift:
+	default:
+		return nil
+	}
+	if !r.Const {
+		return nil
+	}
+	count, ok := r.ConstValue.(*mpa.Int)
+	if !ok {
+		return nil
+	}
+	if count.Sign() < 0 {
+		return ctx.Errorf(ast.Right, "negative shift count %v", count)
+	}
+	if !warn || !l.Type.Concrete() || l.Type.Bits == 0 ||
+		(count.BitLen() < 63 && count.Int64() < int64(l.Type.Bits)) {
+		return nil
+	}
+	result := "0"
+	if ast.Op == BinaryRshift && l.Type.Type == types.TInt {
+		result = "the sign bits"
+	}
+	ctx.LargeShift(ast, count, l.Type, result)
+	return nil
+}
+
+// convertConst converts the integer constant operand c to the type of
+// the non-constant integer or fixed-point operand o. The function
+// returns c unmodified unless c is an integer constant and o is a
+// non-constant integer or fixed-point value.
+func convertConst(ctx *Codegen, loc utils.Locator, gen *ssa.Generator,
+	c, o ssa.Value) (ssa.Value, error) {
+
+	fixed := o.Type.Type == types.TFixed
+	if !c.Const || o.Const || !c.IntegerLike() ||
+		!o.IntegerLike() && !fixed ||
+		c.Type.Type == o.Type.Type && c.Type.Bits == o.Type.Bits {
+		return c, nil
+	}
+	cv, ok := c.ConstValue.(*mpa.Int)
+	if !ok || cv.TypeSize() > 64 {
+		return c, nil
+	}
+	val := big.NewInt(cv.Int64())
+	if c.Type.Type == types.TUint && val.Sign() < 0 {
+		val.Add(val, new(big.Int).Lsh(big.NewInt(1), uint(cv.TypeSize())))
+	}
+	rep := val
+	if fixed {
+		rep = new(big.Int).Lsh(val, uint(o.Type.FracBits))
+	}
+	v, ok := gen.IntConstant(rep, o.Type)
+	if !ok {
+		return c, ctx.Errorf(loc, "constant %v overflows %v", val, o.Type)
+	}
+	gen.RemoveConstant(c)
+	gen.AddConstant(v)
+
+	return v, nil
+}
+
 func (ast *Binary) value(env *Env, val AST, block *ssa.Block, ctx *Codegen,
 	gen *ssa.Generator) (*ssa.Block, ssa.Value, error) {
 
@@ -1695,7 +2545,7 @@ func (ast *Unary) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 
 		t := gen.AnonVal(expr.Type)
 		switch expr.Type.Type {
-		case types.TInt, types.TUint:
+		case types.TInt, types.TUint, types.TFixed:
 			zero := gen.Constant(int64(0), types.Undefined)
 			gen.AddConstant(zero)
 			instr, err := ssa.NewSubInstr(expr.Type, zero, expr, t)
@@ -1778,6 +2628,17 @@ func (ast *Unary) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 			return nil, nil, ctx.Errorf(ast, "Unary.SSA: '%T' not supported", v)
 		}
 
+	case UnarySend:
+		ch, err := ctx.channel(block, gen, ast, ast.Expr)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := ch.Receive()
+		if err != nil {
+			return nil, nil, ctx.Error(ast, err.Error())
+		}
+		return block, []ssa.Value{v}, nil
+
 	default:
 		return nil, nil, ctx.Errorf(ast, "Unary.SSA not implemented yet: %v",
 			ast)
@@ -1845,6 +2706,9 @@ func (ast *Slice) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		return nil, nil, ctx.Errorf(ast, "invalid expression")
 	}
 	expr := exprs[0]
+	if expr.IntegerLike() {
+		return ast.bitsSSA(block, ctx, gen, expr)
+	}
 	arrayType := expr.IndirectType()
 	if !arrayType.Type.Array() {
 		return nil, nil, ctx.Errorf(ast, "invalid operation: cannot slice %v",
@@ -1852,13 +2716,16 @@ func (ast *Slice) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	}
 	elementSize := arrayType.ElementType.Bits
 
-	var from, to types.Size
-	block, from, to, err = ast.limitsSSA(block, ctx, gen, arrayType)
+	var from, to, max types.Size
+	block, from, to, max, err = ast.limitsSSA(block, ctx, gen,
+		arrayType.ArraySize, arrayType.Cap())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	bits := (to - from) * elementSize
+	// The result holds the elements up to its capacity max-from so
+	// that it can be resliced up to its capacity.
+	bits := (max - from) * elementSize
 
 	var t ssa.Value
 
@@ -1870,6 +2737,7 @@ func (ast *Slice) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		et := arrayType
 		et.Type = types.TSlice
 		et.ID = 0
+		et.Bits = bits
 		et.ArraySize = to - from
 
 		ti := types.Info{
@@ -1890,23 +2758,87 @@ func (ast *Slice) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 		}
 		ti.Type = types.TSlice
 		ti.ElementType = arrayType.ElementType
-		ti.ArraySize = ti.Bits / ti.ElementType.Bits
+		ti.ArraySize = to - from
 
 		t = gen.AnonVal(ti)
 	}
 	if bits > 0 {
 		fromConst := gen.Constant(int64(from*elementSize), types.Undefined)
-		toConst := gen.Constant(int64(to*elementSize), types.Undefined)
+		toConst := gen.Constant(int64(max*elementSize), types.Undefined)
 		block.AddInstr(ssa.NewSliceInstr(expr, fromConst, toConst, t))
 	}
 
 	return block, []ssa.Value{t}, nil
 }
 
-func (ast *Slice) limitsSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
-	t types.Info) (*ssa.Block, types.Size, types.Size, error) {
+// sliceElements returns the elements of the slice value v without its
+// spare capacity.
+func sliceElements(block *ssa.Block, gen *ssa.Generator,
+	v ssa.Value) ssa.Value {
+
+	if v.Type.Type != types.TSlice || v.Type.Cap() == v.Type.ArraySize {
+		return v
+	}
+	ti := v.Type
+	ti.Bits = ti.ArraySize * ti.ElementType.Bits
+	ti.MinBits = ti.Bits
+
+	t := gen.AnonVal(ti)
+	if ti.Bits > 0 {
+		fromConst := gen.Constant(int64(0), types.Undefined)
+		toConst := gen.Constant(int64(ti.Bits), types.Undefined)
+		block.AddInstr(ssa.NewSliceInstr(v, fromConst, toConst, t))
+	}
+	return t
+}
 
-	elementCount := t.ArraySize
+// bitsSSA slices the bits from:to of the integer value expr into an
+// unsigned integer of to-from bits.
+func (ast *Slice) bitsSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	expr ssa.Value) (*ssa.Block, []ssa.Value, error) {
+
+	if ast.Max != nil {
+		return nil, nil, ctx.Errorf(ast,
+			"invalid operation: 3-index slice of %s", expr.Type)
+	}
+	block, from, to, _, err := ast.limitsSSA(block, ctx, gen,
+		expr.Type.Bits, expr.Type.Bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ast.checkBits(ctx, int(from), int(to), expr.Type); err != nil {
+		return nil, nil, err
+	}
+	bits := to - from
+	t := gen.AnonVal(types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       bits,
+		MinBits:    bits,
+	})
+	fromConst := gen.Constant(int64(from), types.Undefined)
+	toConst := gen.Constant(int64(to), types.Undefined)
+	block.AddInstr(ssa.NewSliceInstr(expr, fromConst, toConst, t))
+
+	return block, []ssa.Value{t}, nil
+}
+
+// checkBits checks the bit slice bounds from:to of the integer type t.
+func (ast *Slice) checkBits(ctx *Codegen, from, to int, t types.Info) error {
+	if from < 0 || from >= to || to > int(t.Bits) {
+		return ctx.Errorf(ast, "bit slice bounds out of range [%d:%d] of %s",
+			from, to, t)
+	}
+	return nil
+}
+
+// limitsSSA resolves the from, to, and max indices of the slice
+// expression of a value with length elements and capacity capacity.
+// The max index defaults to the to index, i.e. the capacity of a
+// two-index slice is its length.
+func (ast *Slice) limitsSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	length, capacity types.Size) (
+	*ssa.Block, types.Size, types.Size, types.Size, error) {
 
 	var err error
 	var val []ssa.Value
@@ -1917,39 +2849,62 @@ func (ast *Slice) limitsSSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 	} else {
 		block, val, err = ast.From.SSA(block, ctx, gen)
 		if err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, 0, err
 		}
 		if len(val) != 1 {
-			return nil, 0, 0, ctx.Errorf(ast.From, "invalid from index")
+			return nil, 0, 0, 0, ctx.Errorf(ast.From, "invalid from index")
 		}
 		from, err = val[0].ConstInt()
 		if err != nil {
-			return nil, 0, 0, ctx.Errorf(ast.From, "%s", err)
+			return nil, 0, 0, 0, ctx.Errorf(ast.From, "%s", err)
 		}
 	}
 	var to types.Size
 	if ast.To == nil {
-		to = elementCount
+		to = length
 	} else {
 		block, val, err = ast.To.SSA(block, ctx, gen)
 		if err != nil {
-			return nil, 0, 0, err
+			return nil, 0, 0, 0, err
 		}
 		if len(val) != 1 {
-			return nil, 0, 0, ctx.Errorf(ast.To, "invalid to index")
+			return nil, 0, 0, 0, ctx.Errorf(ast.To, "invalid to index")
 		}
 		to, err = val[0].ConstInt()
 		if err != nil {
-			return nil, 0, 0, ctx.Errorf(ast.To, "%s", err)
+			return nil, 0, 0, 0, ctx.Errorf(ast.To, "%s", err)
 		}
 	}
 	if ast.From != nil && ast.To != nil &&
-		(from >= elementCount || from > to) {
-		return nil, 0, 0, ctx.Errorf(ast, "slice bounds out of range [%d:%d]",
-			from, to)
+		(from >= capacity || from > to) {
+		return nil, 0, 0, 0, ctx.Errorf(ast,
+			"slice bounds out of range [%d:%d]", from, to)
+	}
+	if to > capacity {
+		return nil, 0, 0, 0, ctx.Errorf(ast,
+			"slice bounds out of range [:%d] with capacity %d", to, capacity)
+	}
+	max := to
+	if ast.Max != nil {
+		block, val, err = ast.Max.SSA(block, ctx, gen)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		if len(val) != 1 {
+			return nil, 0, 0, 0, ctx.Errorf(ast.Max, "invalid max index")
+		}
+		max, err = val[0].ConstInt()
+		if err != nil {
+			return nil, 0, 0, 0, ctx.Errorf(ast.Max, "%s", err)
+		}
+		if to > max || max > capacity {
+			return nil, 0, 0, 0, ctx.Errorf(ast,
+				"slice bounds out of range [%d:%d:%d] with capacity %d",
+				from, to, max, capacity)
+		}
 	}
 
-	return block, from, to, nil
+	return block, from, to, max, nil
 }
 
 // SSA implements the compiler.ast.AST.SSA for index expressions.
@@ -1978,6 +2933,98 @@ func (ast *Index) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	return ast.index(block, ctx, gen, expr, val[0])
 }
 
+// commaOkIndex tests if the assignment is a comma-ok index
+// expression v, ok := arr[i].
+func (ast *Assign) commaOkIndex() (*Index, bool) {
+	if len(ast.LValues) != 2 || len(ast.Exprs) != 1 {
+		return nil, false
+	}
+	index, ok := ast.Exprs[0].(*Index)
+	return index, ok
+}
+
+// commaOkSSA creates the comma-ok index expression. It returns the
+// indexed element and a boolean value specifying if the index was
+// within the array bounds. Out-of-bounds indices return the zero
+// value of the element type.
+func (ast *Index) commaOkSSA(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator) (*ssa.Block, []ssa.Value, error) {
+
+	if ctx.Params.IndexPolicy != utils.IndexFlag {
+		return nil, nil, ctx.Errorf(ast,
+			"comma-ok index %s requires index policy %s (policy is %s)",
+			ast, utils.IndexFlag, ctx.Params.IndexPolicy)
+	}
+
+	block, exprs, err := ast.Expr.SSA(block, ctx, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(exprs) != 1 {
+		return nil, nil, ctx.Errorf(ast, "invalid expression")
+	}
+	expr := exprs[0]
+
+	it := expr.Type
+	if it.Type == types.TPtr {
+		it = *it.ElementType
+	}
+	if !it.Type.Array() {
+		return nil, nil, ctx.Errorf(ast,
+			"invalid operation: %s (type %s does not support comma-ok index)",
+			ast, expr.Type)
+	}
+
+	block, val, err := ast.Index.SSA(block, ctx, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(val) != 1 {
+		return nil, nil, ctx.Errorf(ast.Index, "invalid index")
+	}
+	if val[0].Const {
+		index, err := val[0].ConstInt()
+		if err != nil {
+			return nil, nil, ctx.Errorf(ast.Index, "%s", err)
+		}
+		if index >= 0 && index < it.ArraySize {
+			var v []ssa.Value
+			block, v, err = ast.constIndex(block, ctx, gen, expr, val[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			okVal := gen.Constant(true, types.Bool)
+			gen.AddConstant(okVal)
+			return block, append(v, okVal), nil
+		}
+		init, err := initValue(*it.ElementType)
+		if err != nil {
+			return nil, nil, ctx.Errorf(ast, "%s", err)
+		}
+		zero := gen.Constant(init, *it.ElementType)
+		gen.AddConstant(zero)
+		okVal := gen.Constant(false, types.Bool)
+		gen.AddConstant(okVal)
+		return block, []ssa.Value{zero, okVal}, nil
+	}
+
+	block, v, err := ast.index(block, ctx, gen, expr, val[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	length := gen.Constant(int64(it.ArraySize), types.Undefined)
+	gen.AddConstant(length)
+	okVal := gen.AnonVal(types.Bool)
+	instr, err := ssa.NewLtInstr(types.Info{Type: types.TUint}, val[0],
+		length, okVal)
+	if err != nil {
+		return nil, nil, ctx.Errorf(ast.Index, "%s", err)
+	}
+	block.AddInstr(instr)
+
+	return block, append(v, okVal), nil
+}
+
 func (ast *Index) constIndex(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 	expr, ival ssa.Value) (*ssa.Block, []ssa.Value, error) {
 
@@ -2089,8 +3136,19 @@ func (ast *Index) index(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
 func (ast *VariableRef) SSA(block *ssa.Block, ctx *Codegen,
 	gen *ssa.Generator) (*ssa.Block, []ssa.Value, error) {
 
-	lrv, _, _, err := ctx.LookupVar(block, gen, block.Bindings, ast)
+	lrv, _, df, err := ctx.LookupVar(block, gen, block.Bindings, ast)
 	if err != nil {
+		if df {
+			v, ok := ctx.Define(gen, ast)
+			if ok {
+				gen.AddConstant(v)
+				return block, []ssa.Value{v}, nil
+			}
+		}
+		v, ok := ctx.funcValue(gen, ast)
+		if ok {
+			return block, []ssa.Value{v}, nil
+		}
 		return nil, nil, ctx.Error(ast, err.Error())
 	}
 
@@ -2131,6 +3189,9 @@ func (ast *Make) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	if err != nil {
 		return nil, nil, ctx.Errorf(ast.Type, "%s is not a type", ast.Type)
 	}
+	if typeInfo.Type == types.TChan {
+		return ast.makeChan(block, ctx, gen, env, typeInfo)
+	}
 	if !typeInfo.Type.Array() {
 		return nil, nil, ctx.Errorf(ast.Type,
 			"can't make instance of type %s", typeInfo)
@@ -2171,6 +3232,34 @@ func (ast *Make) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	return block, []ssa.Value{v}, nil
 }
 
+// makeChan creates a channel of type typeInfo. The channel capacity
+// must be constant.
+func (ast *Make) makeChan(block *ssa.Block, ctx *Codegen, gen *ssa.Generator,
+	env *Env, typeInfo types.Info) (*ssa.Block, []ssa.Value, error) {
+
+	constVal, ok, err := ast.Exprs[0].Eval(env, ctx, gen)
+	if err != nil {
+		return nil, nil, ctx.Error(ast.Exprs[0], err.Error())
+	}
+	if !ok {
+		return nil, nil, ctx.Errorf(ast.Exprs[0],
+			"channel capacity is not constant: %s", ast.Exprs[0])
+	}
+	capacity, err := constVal.ConstInt()
+	if err != nil {
+		return nil, nil, ctx.Errorf(ast.Exprs[0],
+			"non-integer (%T) capacity argument in %s: %s",
+			constVal, ast, err)
+	}
+	if capacity <= 0 {
+		return nil, nil, ctx.Errorf(ast.Exprs[0],
+			"invalid channel capacity %d", capacity)
+	}
+	ch := gen.NewChannel(*typeInfo.ElementType, int(capacity))
+
+	return block, []ssa.Value{gen.Constant(ch, types.Undefined)}, nil
+}
+
 // SSA implements the compiler.ast.AST.SSA for the builtin function copy.
 func (ast *Copy) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 	*ssa.Block, []ssa.Value, error) {
@@ -2212,7 +3301,8 @@ func (ast *Copy) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 			return nil, nil, ast.errf(ctx, ast.Dst, "got %v", dst.Type)
 		}
 
-		block, dstFrom, dstTo, err = lv.limitsSSA(block, ctx, gen, dst.Type)
+		block, dstFrom, dstTo, _, err = lv.limitsSSA(block, ctx, gen,
+			dst.Type.ArraySize, dst.Type.ArraySize)
 		if err != nil {
 			return nil, nil, ctx.Error(ast.Dst, err.Error())
 		}
@@ -2245,7 +3335,8 @@ func (ast *Copy) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
 
 	var ret ssa.Value
 
-	if dstFrom == 0 && srcCount >= dstCount {
+	if dstFrom == 0 && srcCount >= dstCount &&
+		dst.Type.Cap() == dst.Type.ArraySize {
 		// Src overwrites dst fully.
 		bits := dstCount * elSize
 		ti := types.Info{
@@ -2300,3 +3391,50 @@ func (ast *Copy) errf(ctx *Codegen, offending AST, format string,
 	return ctx.Errorf(offending,
 		"invalid argument: copy expects slice arguments: %s", msg)
 }
+
+// SSA implements the compiler.ast.AST.SSA for channel send
+// statements. The sent value is moved into a new value which is
+// queued into the channel. The channel receive returns the queued
+// values in the send order.
+func (ast *Send) SSA(block *ssa.Block, ctx *Codegen, gen *ssa.Generator) (
+	*ssa.Block, []ssa.Value, error) {
+
+	ch, err := ctx.channel(block, gen, ast, ast.Chan)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var v ssa.Value
+	constVal, ok, err := ast.Value.Eval(NewEnv(block), ctx, gen)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ok {
+		gen.AddConstant(constVal)
+		v = constVal
+	} else {
+		var values []ssa.Value
+		block, values, err = ast.Value.SSA(block, ctx, gen)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(values) != 1 {
+			return nil, nil, ctx.Errorf(ast.Value,
+				"multiple-value %s used in single-value context", ast.Value)
+		}
+		v = values[0]
+	}
+	if !ssa.CanAssign(ch.ElementType, v) {
+		return nil, nil, ctx.Errorf(ast.Value,
+			"cannot use %s (type %v) as type %v in send",
+			ast.Value, v.Type, ch.ElementType)
+	}
+	slot := gen.AnonVal(ch.ElementType)
+	block.AddInstr(ssa.NewMovInstr(v, slot))
+
+	err = ch.Send(slot)
+	if err != nil {
+		return nil, nil, ctx.Error(ast, err.Error())
+	}
+	return block, nil, nil
+}
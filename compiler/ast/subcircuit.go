@@ -0,0 +1,239 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/types"
+)
+
+// subcircuit is a function compiled into a standalone circuit for
+// the argument types of its cache key. The nil circ marks functions
+// that can't be compiled into subcircuits.
+type subcircuit struct {
+	circ    *circuit.Circuit
+	returns []types.Info
+}
+
+// valueKey identifies an SSA value independently of its generator.
+type valueKey struct {
+	name    string
+	scope   ssa.Scope
+	version int32
+}
+
+func newValueKey(v ssa.Value) valueKey {
+	return valueKey{
+		name:    v.Name,
+		scope:   v.Scope,
+		version: v.Version,
+	}
+}
+
+// subcircuitCall calls the function called with the circuit Circ
+// instruction. The function is compiled once for each combination of
+// argument types and the identical call sites share the compiled
+// subcircuit instead of inlining the function's body. The function
+// returns false if the call can't use a subcircuit and it must be
+// inlined.
+func (ast *Call) subcircuitCall(block *ssa.Block, ctx *Codegen,
+	gen *ssa.Generator, called *Func, args []ssa.Value) (
+	*ssa.Block, []ssa.Value, bool, error) {
+
+	if called.This != nil || len(called.Return) == 0 {
+		return nil, nil, false, nil
+	}
+	var key strings.Builder
+	fmt.Fprintf(&key, "%p", called)
+	for _, arg := range args {
+		if arg.Const || !arg.Type.Concrete() || arg.Type.Bits == 0 ||
+			arg.Type.Type == types.TPtr {
+			return nil, nil, false, nil
+		}
+		fmt.Fprintf(&key, ",%v/%d", arg.Type, arg.Type.Bits)
+	}
+
+	sub, ok := ctx.subcircuits[key.String()]
+	if !ok {
+		var err error
+		sub, err = ast.compileSubcircuit(block, ctx, called, args)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		ctx.subcircuits[key.String()] = sub
+		if ctx.Verbose && sub.circ != nil {
+			fmt.Printf(" - subcircuit %s: %v\n", called.Name,
+				sub.circ)
+		}
+	}
+	if sub.circ == nil {
+		return nil, nil, false, nil
+	}
+
+	var result []ssa.Value
+	for _, t := range sub.returns {
+		result = append(result, gen.AnonVal(t))
+	}
+	block.AddInstr(ssa.NewCircInstr(args, sub.circ, result))
+
+	return block, result, true, nil
+}
+
+// compileSubcircuit compiles the function called into a standalone
+// circuit. The function compiles the called function with its own
+// SSA generator and falls back to inlining if the function refers to
+// package variables or has compile-time side effects.
+func (ast *Call) compileSubcircuit(block *ssa.Block, ctx *Codegen,
+	called *Func, args []ssa.Value) (*subcircuit, error) {
+
+	// Snapshot the package bindings to detect assignments to
+	// package variables.
+	type snapshot struct {
+		bindings *ssa.Bindings
+		values   []ssa.Binding
+	}
+	snapshots := make(map[*Package]snapshot)
+	pkgs := []*Package{ctx.Package}
+	for _, pkg := range ctx.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	for _, pkg := range pkgs {
+		var values []ssa.Binding
+		snapshots[pkg] = snapshot{
+			bindings: pkg.Bindings,
+			values:   append(values, pkg.Bindings.Values...),
+		}
+	}
+	branchConds := ctx.branchConds
+	instrMark := ctx.instrMark
+	sideEffects := ctx.sideEffects
+	ctx.branchConds = nil
+	ctx.instrMark = 0
+	defer func() {
+		ctx.branchConds = branchConds
+		ctx.instrMark = instrMark
+	}()
+
+	gen := ssa.NewGenerator(ctx.Params)
+	start := gen.Block()
+
+	ctx.PushCompilation(start, gen.Block(), nil, called)
+
+	var inputs circuit.IO
+	defined := make(map[valueKey]bool)
+	for idx, arg := range called.Args {
+		// Let the inlined call report the argument errors.
+		typeInfo, err := arg.Type.Resolve(NewEnv(block), ctx, gen)
+		valid := err == nil
+		if valid && !typeInfo.Concrete() {
+			valid = typeInfo.Instantiate(args[idx].Type)
+		}
+		if !valid || !ssa.CanAssign(typeInfo, args[idx]) ||
+			typeInfo.Type == types.TFunc {
+			ctx.PopCompilation()
+			return &subcircuit{}, nil
+		}
+		a := gen.NewVal(arg.Name, args[idx].Type, ctx.Scope())
+		start.Bindings.Define(a, nil)
+		defined[newValueKey(a)] = true
+
+		inputs = append(inputs, circuit.IOArg{
+			Name: arg.Name,
+			Type: a.Type,
+		})
+	}
+	_, returnValues, err := called.SSA(start, ctx, gen)
+	ctx.PopCompilation()
+	if err != nil {
+		return nil, err
+	}
+
+	var modified bool
+	for pkg, s := range snapshots {
+		if pkg.Bindings != s.bindings ||
+			len(pkg.Bindings.Values) != len(s.values) {
+			modified = true
+		} else {
+			for idx, b := range pkg.Bindings.Values {
+				if b.Bound != s.values[idx].Bound {
+					modified = true
+				}
+			}
+		}
+		pkg.Bindings = s.bindings
+		pkg.Bindings.Values = s.values
+	}
+	if modified || ctx.sideEffects != sideEffects {
+		return &subcircuit{}, nil
+	}
+
+	var outputs circuit.IO
+	var returns []types.Info
+	for _, v := range returnValues {
+		if !v.Type.Concrete() || v.Type.Bits == 0 ||
+			v.Type.Type == types.TPtr {
+			return &subcircuit{}, nil
+		}
+		outputs = append(outputs, circuit.IOArg{
+			Name: v.String(),
+			Type: v.Type,
+		})
+		returns = append(returns, v.Type)
+	}
+
+	steps := start.Serialize()
+
+	// Check that the function uses only its arguments and the values
+	// it defines.
+	for _, step := range steps {
+		for _, in := range step.Instr.In {
+			if in.Const || in.TypeRef {
+				continue
+			}
+			if !defined[newValueKey(in)] {
+				return &subcircuit{}, nil
+			}
+		}
+		if step.Instr.Out != nil {
+			defined[newValueKey(*step.Instr.Out)] = true
+		}
+		for _, ret := range step.Instr.Ret {
+			defined[newValueKey(ret)] = true
+		}
+	}
+
+	program, err := ssa.NewProgram(ctx.Params, inputs, outputs,
+		gen.Constants(), steps)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Params.OptCoalesceMovs {
+		program.CoalesceMovs()
+	}
+	program.GC()
+
+	// Compile the subcircuit without the program's circuit outputs.
+	params := *ctx.Params
+	params.Verbose = false
+	params.CircOut = nil
+	params.CircDotOut = nil
+	params.CircSvgOut = nil
+	circ, err := program.CompileCircuitContext(ctx.Context, &params)
+	if err != nil {
+		return nil, err
+	}
+	circ.AssignLevels()
+
+	return &subcircuit{
+		circ:    circ,
+		returns: returns,
+	}, nil
+}
@@ -0,0 +1,113 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestBitSlice(t *testing.T) {
+	inputs := []int64{0x12345678, 0xabcdef01, 0xffffffff, 0}
+
+	for _, typ := range []string{"uint32", "int32"} {
+		for _, r := range []struct {
+			lo, hi int
+		}{
+			{0, 4},
+			{4, 8},
+			{28, 32},
+			{0, 8},
+			{8, 16},
+			{24, 32},
+			{3, 17},
+			{0, 32},
+		} {
+			code := fmt.Sprintf(`
+package main
+func main(a %s, b uint8) (uint32, uint32, uint32) {
+    return uint32(a[%d:%d]), uint32(a[:%d]), uint32(a[%d:])
+}
+`,
+				typ, r.lo, r.hi, r.hi, r.lo)
+
+			circ, _, err := New(utils.NewParams()).Compile(code, nil)
+			if err != nil {
+				t.Fatalf("%s[%d:%d]: compile failed: %v", typ, r.lo, r.hi, err)
+			}
+			for _, input := range inputs {
+				results, err := circ.Compute([]*big.Int{
+					big.NewInt(input),
+					big.NewInt(0),
+				})
+				if err != nil {
+					t.Fatalf("compute failed: %v", err)
+				}
+				expected := []int64{
+					(input >> r.lo) & (1<<(r.hi-r.lo) - 1),
+					input & (1<<r.hi - 1),
+					(input & 0xffffffff) >> r.lo,
+				}
+				for idx, e := range expected {
+					if results[idx].Int64() != e {
+						t.Errorf("%s(%x): result %d: got %x, expected %x",
+							typ, input, idx, results[idx], e)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBitSliceConst(t *testing.T) {
+	code := `
+package main
+const C = 0x12345678
+func main(a uint8) (uint32, uint32, uint32) {
+    var v uint32 = 0xabcdef01
+    return uint32(C[4:8]), uint32(v[8:16]), uint32(v[28:])
+}
+`
+	circ, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	results, err := circ.Compute([]*big.Int{big.NewInt(0)})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	for idx, expected := range []int64{0x7, 0xef, 0xa} {
+		if results[idx].Int64() != expected {
+			t.Errorf("result %d: got %x, expected %x",
+				idx, results[idx], expected)
+		}
+	}
+}
+
+func TestBitSliceInvalid(t *testing.T) {
+	for _, slice := range []string{
+		"a[4:4]",
+		"a[8:4]",
+		"a[0:33]",
+		"a[32:]",
+		"a[:0]",
+	} {
+		code := fmt.Sprintf(`
+package main
+func main(a uint32) uint32 {
+    return uint32(%s)
+}
+`, slice)
+		_, _, err := New(utils.NewParams()).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", slice)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const byteOrderProgram = `
+package main
+
+func main(x uint64, b [8]byte) ([8]byte, [8]byte, uint64, uint64, uint64) {
+    return bytesBE(x), bytesLE(x), uintBE(b), uintLE(b),
+        uintBE(bytesBE(x)) ^ uintLE(bytesLE(x))
+}
+`
+
+// bytesValue returns the byte array data as a circuit value: the
+// array element 0 is in the least significant bits of the value.
+func bytesValue(data []byte) *big.Int {
+	v := new(big.Int)
+	for i := len(data) - 1; i >= 0; i-- {
+		v.Lsh(v, 8)
+		v.Or(v, big.NewInt(int64(data[i])))
+	}
+	return v
+}
+
+func TestByteOrder(t *testing.T) {
+	params := utils.NewParams()
+	params.OptPruneGates = true
+	circ, _, err := New(params).Compile(byteOrderProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	// The conversions are wire permutations and the only XOR gates
+	// are the ones that set the circuit's output wires.
+	if circ.Stats[circuit.XOR] != uint64(circ.Outputs.Size()) {
+		t.Errorf("conversions have gates: %v", circ.Stats)
+	}
+
+	x := uint64(0x0102030405060708)
+	data := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x23, 0x45, 0x67}
+
+	results, err := circ.Compute([]*big.Int{
+		new(big.Int).SetUint64(x),
+		bytesValue(data),
+	})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+
+	var be, le [8]byte
+	binary.BigEndian.PutUint64(be[:], x)
+	binary.LittleEndian.PutUint64(le[:], x)
+
+	expected := []*big.Int{
+		bytesValue(be[:]),
+		bytesValue(le[:]),
+		new(big.Int).SetUint64(binary.BigEndian.Uint64(data)),
+		new(big.Int).SetUint64(binary.LittleEndian.Uint64(data)),
+		big.NewInt(0),
+	}
+	for idx, r := range results {
+		if r.Cmp(expected[idx]) != 0 {
+			t.Errorf("result %d: got %x, expected %x", idx, r, expected[idx])
+		}
+	}
+}
+
+func TestByteOrderInvalid(t *testing.T) {
+	for _, input := range []string{
+		"package main\nfunc main(x uint12) []byte { return bytesBE(x) }\n",
+		"package main\nfunc main(x bool) []byte { return bytesLE(x) }\n",
+		"package main\nfunc main(x uint8) []byte { return bytesLE(x, x) }\n",
+		"package main\nfunc main(x [2]int8) uint16 { return uintBE(x) }\n",
+		"package main\nfunc main(x uint16) uint16 { return uintLE(x) }\n",
+	} {
+		_, _, err := New(utils.NewParams()).Compile(input, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", input)
+		}
+	}
+}
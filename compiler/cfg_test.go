@@ -0,0 +1,76 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error {
+	return nil
+}
+
+func TestDotCFG(t *testing.T) {
+	out := new(bufferCloser)
+	params := utils.NewParams()
+	params.NoCircCompile = true
+	params.SSACFGOut = out
+
+	_, _, err := New(params).Compile(`
+package main
+func main(a, b int32) int32 {
+    r := a
+    if a > b {
+        r = b
+    } else {
+        r = a + b
+    }
+    return r
+}
+`, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+
+	var blocks, edges, condEdges, merges int
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, " -> "):
+			edges++
+			if strings.Contains(line, "label=") {
+				condEdges++
+			}
+		case strings.Contains(line, "[label="):
+			blocks++
+			if strings.Contains(line, "peripheries=2") {
+				merges++
+			}
+		}
+	}
+	// Entry, function, if, true, false, merge, and return blocks.
+	if blocks != 7 {
+		t.Errorf("expected 7 blocks, got %v:\n%s", blocks, out)
+	}
+	if edges != 7 {
+		t.Errorf("expected 7 edges, got %v:\n%s", edges, out)
+	}
+	if condEdges != 2 {
+		t.Errorf("expected 2 branch edges, got %v:\n%s", condEdges, out)
+	}
+	if merges != 1 {
+		t.Errorf("expected 1 phi merge block, got %v:\n%s", merges, out)
+	}
+}
@@ -0,0 +1,145 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+const chanPipeline = `
+package main
+func main(a, b uint32) (uint32, uint32, uint32) {
+    ch := make(chan uint32, 3)
+    produce(ch, a, b)
+    return consume(ch)
+}
+func produce(ch chan uint32, a, b uint32) {
+    ch <- a + b
+    ch <- a * b
+    ch <- a - b
+}
+func consume(ch chan uint32) (uint32, uint32, uint32) {
+    first := <-ch
+    second := <-ch
+    third := <-ch
+    return third, second, first
+}
+`
+
+func TestChannelPipelineStream(t *testing.T) {
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"5"}, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	_, values, err := New(utils.NewParams()).stream(context.Background(),
+		gConn, ot.NewCO(), "{data}", strings.NewReader(chanPipeline),
+		[]string{"7"}, nil)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	expected := []int64{2, 35, 12}
+	if len(values) != len(expected) {
+		t.Fatalf("got %d values, expected %d", len(values), len(expected))
+	}
+	for idx, e := range expected {
+		if values[idx].Int64() != e || eResult.values[idx].Int64() != e {
+			t.Errorf("result %d: got %v (%v), expected %v",
+				idx, values[idx], eResult.values[idx], e)
+		}
+	}
+}
+
+var chanErrors = []string{
+	// Send to full channel.
+	`
+package main
+func main(a uint32) uint32 {
+    ch := make(chan uint32, 1)
+    ch <- a
+    ch <- a
+    return <-ch
+}
+`,
+	// Receive from empty channel.
+	`
+package main
+func main(a uint32) uint32 {
+    ch := make(chan uint32, 1)
+    return <-ch
+}
+`,
+	// Channel operation in non-constant branch.
+	`
+package main
+func main(a uint32) uint32 {
+    ch := make(chan uint32, 1)
+    if a > 10 {
+        ch <- a
+    }
+    return a
+}
+`,
+	// Invalid element type.
+	`
+package main
+func main(a uint32) bool {
+    ch := make(chan bool, 1)
+    ch <- a
+    return <-ch
+}
+`,
+	// Non-constant capacity.
+	`
+package main
+func main(a uint32) uint32 {
+    ch := make(chan uint32, a)
+    ch <- a
+    return <-ch
+}
+`,
+	// Receive from non-channel.
+	`
+package main
+func main(a uint32) uint32 {
+    return <-a
+}
+`,
+}
+
+func TestChannelErrors(t *testing.T) {
+	for idx, code := range chanErrors {
+		_, _, err := New(utils.NewParams()).Compile(code, nil)
+		if err == nil {
+			t.Errorf("test %d: Compile succeeded", idx)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var checkTests = []struct {
+	name        string
+	code        string
+	diagnostics []string
+}{
+	{
+		name: "valid",
+		code: `
+package main
+func main(a, b int32) int32 {
+    return add(a, b)
+}
+func add(x, y int32) int32 {
+    return x + y
+}
+`,
+	},
+	{
+		name: "argument type",
+		code: `
+package main
+func main(a int32, b bool) int32 {
+    return add(a,
+        b)
+}
+func add(x, y int32) int32 {
+    return x + y
+}
+`,
+		diagnostics: []string{
+			"{data}:5:8: cannot use b (type bool1) as type int32 " +
+				"in argument y to add",
+		},
+	},
+	{
+		name: "undefined",
+		code: `
+package main
+func main(a int32) int32 {
+    return a + c
+}
+`,
+		diagnostics: []string{
+			"{data}:4:15: undefined variable 'c'",
+		},
+	},
+	{
+		name: "syntax",
+		code: `
+package main
+func main(a int32) int32 {
+    return a +
+}
+`,
+		diagnostics: []string{
+			"{data}:5:0: unexpected token '}' while parsing expression",
+		},
+	},
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range checkTests {
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+
+		c := New(params)
+		diagnostics := c.Check(test.code)
+
+		var got []string
+		for _, d := range diagnostics {
+			if d.Severity == utils.SeverityError {
+				got = append(got, d.String())
+			}
+		}
+		if len(got) != len(test.diagnostics) {
+			t.Errorf("%s: got diagnostics %q, expected %q", test.name, got,
+				test.diagnostics)
+			continue
+		}
+		for idx := range got {
+			if got[idx] != test.diagnostics[idx] {
+				t.Errorf("%s: got diagnostic %q, expected %q", test.name,
+					got[idx], test.diagnostics[idx])
+			}
+		}
+	}
+}
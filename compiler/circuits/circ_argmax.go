@@ -0,0 +1,117 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewArgmax creates a circuit that computes the index of the maximum
+// element of the array arr. The elements are elemBits wide unsigned
+// integers. The array is scanned with comparators and the index of
+// the current maximum is tracked with MUXes so the result does not
+// reveal which element is the maximum. If the maximum is not unique,
+// the circuit returns the index of its first occurrence. The index is
+// truncated to the width of outIndex.
+func NewArgmax(cc *Compiler, elemBits int, arr, outIndex []*Wire) error {
+	return newArgExtremum(cc, elemBits, arr, outIndex, NewGtComparator)
+}
+
+// NewArgmin creates a circuit that computes the index of the minimum
+// element of the array arr. The semantics are the same as in
+// NewArgmax.
+func NewArgmin(cc *Compiler, elemBits int, arr, outIndex []*Wire) error {
+	return newArgExtremum(cc, elemBits, arr, outIndex, NewLtComparator)
+}
+
+// NewIntArgmax creates a circuit that computes the index of the
+// maximum element of the array arr of signed integers. The semantics
+// are the same as in NewArgmax.
+func NewIntArgmax(cc *Compiler, elemBits int, arr, outIndex []*Wire) error {
+	return newArgExtremum(cc, elemBits, arr, outIndex, NewIntGtComparator)
+}
+
+// NewIntArgmin creates a circuit that computes the index of the
+// minimum element of the array arr of signed integers. The semantics
+// are the same as in NewArgmax.
+func NewIntArgmin(cc *Compiler, elemBits int, arr, outIndex []*Wire) error {
+	return newArgExtremum(cc, elemBits, arr, outIndex, NewIntLtComparator)
+}
+
+// newArgExtremum implements the argmax and argmin circuits. The
+// comparator cmp tests if its first argument should replace the
+// current extremum. It must be strict so that ties keep the first
+// occurrence.
+func newArgExtremum(cc *Compiler, elemBits int, arr, outIndex []*Wire,
+	cmp func(cc *Compiler, x, y, r []*Wire) error) error {
+
+	if elemBits <= 0 || len(arr)%elemBits != 0 {
+		return fmt.Errorf("array width %d must be multiple of element size %d",
+			len(arr), elemBits)
+	}
+	n := len(arr) / elemBits
+	if n == 0 {
+		return fmt.Errorf("argmax of empty array")
+	}
+	if len(outIndex) == 0 {
+		return fmt.Errorf("invalid argmax arguments: index=%d", len(outIndex))
+	}
+	bits := len(outIndex)
+
+	best := arr[:elemBits]
+	bestIndex := cc.indexWires(0, bits)
+
+	for i := 1; i < n; i++ {
+		elem := arr[i*elemBits : (i+1)*elemBits]
+
+		cond := cc.Calloc.Wires(1)
+		if err := cmp(cc, elem, best, cond); err != nil {
+			return err
+		}
+
+		var index []*Wire
+		if i+1 < n {
+			index = cc.Calloc.Wires(types.Size(bits))
+		} else {
+			index = outIndex
+		}
+		if err := NewMUX(cc, cond, cc.indexWires(i, bits), bestIndex,
+			index); err != nil {
+			return err
+		}
+		bestIndex = index
+
+		if i+1 < n {
+			next := cc.Calloc.Wires(types.Size(elemBits))
+			if err := NewMUX(cc, cond, elem, best, next); err != nil {
+				return err
+			}
+			best = next
+		}
+	}
+	if n == 1 {
+		for i := 0; i < bits; i++ {
+			cc.ID(bestIndex[i], outIndex[i])
+		}
+	}
+	return nil
+}
+
+// indexWires returns the constant value v as bits wires.
+func (cc *Compiler) indexWires(v, bits int) []*Wire {
+	result := make([]*Wire, bits)
+	for i := 0; i < bits; i++ {
+		if i < 63 && v&(1<<i) != 0 {
+			result[i] = cc.OneWire()
+		} else {
+			result[i] = cc.ZeroWire()
+		}
+	}
+	return result
+}
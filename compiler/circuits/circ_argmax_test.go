@@ -0,0 +1,128 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"testing"
+)
+
+const (
+	argmaxElemBits  = 8
+	argmaxIndexBits = 4
+)
+
+type argmaxFunc func(cc *Compiler, elemBits int, arr, outIndex []*Wire) error
+
+func argmax(t *testing.T, f argmaxFunc, arr []int64) int64 {
+	arrBits := len(arr) * argmaxElemBits
+
+	inputs := makeWires(arrBits, false)
+	outputs := makeWires(argmaxIndexBits, true)
+
+	cc, err := NewCompiler(params, calloc, NewIO(arrBits, "arr"),
+		NewIO(argmaxIndexBits, "index"), inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = f(cc, argmaxElemBits, inputs, outputs)
+	if err != nil {
+		t.Fatalf("argmax: %s", err)
+	}
+	circ := cc.Compile()
+
+	in := new(big.Int)
+	mask := big.NewInt(1<<argmaxElemBits - 1)
+	for i, v := range arr {
+		e := new(big.Int).And(big.NewInt(v), mask)
+		in.Or(in, e.Lsh(e, uint(i*argmaxElemBits)))
+	}
+	results, err := circ.Compute([]*big.Int{in})
+	if err != nil {
+		t.Fatalf("compute failed: %s", err)
+	}
+	return results[0].Int64()
+}
+
+var argmaxTests = []struct {
+	arr    []int64
+	max    int64
+	min    int64
+	intMax int64
+	intMin int64
+}{
+	{
+		arr: []int64{42},
+	},
+	{
+		arr:    []int64{3, 9, 1, 7},
+		max:    1,
+		min:    2,
+		intMax: 1,
+		intMin: 2,
+	},
+	{
+		arr:    []int64{5, -1, 200, 0, 17},
+		max:    1,
+		min:    3,
+		intMax: 4,
+		intMin: 2,
+	},
+	{
+		// Ties return the first occurrence.
+		arr:    []int64{4, 9, 2, 9, 2, 9},
+		max:    1,
+		min:    2,
+		intMax: 1,
+		intMin: 2,
+	},
+	{
+		arr: []int64{7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7},
+	},
+	{
+		arr:    []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		max:    15,
+		intMax: 15,
+	},
+}
+
+func TestArgmax(t *testing.T) {
+	for idx, test := range argmaxTests {
+		if got := argmax(t, NewArgmax, test.arr); got != test.max {
+			t.Errorf("t%d: argmax(%v)=%d, expected %d",
+				idx, test.arr, got, test.max)
+		}
+		if got := argmax(t, NewArgmin, test.arr); got != test.min {
+			t.Errorf("t%d: argmin(%v)=%d, expected %d",
+				idx, test.arr, got, test.min)
+		}
+		if got := argmax(t, NewIntArgmax, test.arr); got != test.intMax {
+			t.Errorf("t%d: int argmax(%v)=%d, expected %d",
+				idx, test.arr, got, test.intMax)
+		}
+		if got := argmax(t, NewIntArgmin, test.arr); got != test.intMin {
+			t.Errorf("t%d: int argmin(%v)=%d, expected %d",
+				idx, test.arr, got, test.intMin)
+		}
+	}
+}
+
+func TestArgmaxErrors(t *testing.T) {
+	inputs := makeWires(12, false)
+	outputs := makeWires(argmaxIndexBits, true)
+	cc, err := NewCompiler(params, calloc, NewIO(12, "arr"),
+		NewIO(argmaxIndexBits, "index"), inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	if NewArgmax(cc, 8, inputs, outputs) == nil {
+		t.Errorf("argmax of partial element succeeded")
+	}
+	if NewArgmax(cc, 8, nil, outputs) == nil {
+		t.Errorf("argmax of empty array succeeded")
+	}
+}
@@ -0,0 +1,30 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewByteSwap creates a circuit that reverses the byte order of x
+// into r. The circuit is a wire permutation and its identity gates
+// are removed by the constant propagation.
+func NewByteSwap(cc *Compiler, x, r []*Wire) error {
+	if len(x)%types.ByteBits != 0 || len(r) != len(x) {
+		return fmt.Errorf("invalid byte swap arguments: x=%d, r=%d",
+			len(x), len(r))
+	}
+	n := len(x) / types.ByteBits
+	for i := 0; i < n; i++ {
+		for bit := 0; bit < types.ByteBits; bit++ {
+			cc.ID(x[(n-1-i)*types.ByteBits+bit], r[i*types.ByteBits+bit])
+		}
+	}
+	return nil
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -60,12 +60,58 @@ func NewLeComparator(cc *Compiler, x, y, r []*Wire) error {
 	return comparator(cc, cc.OneWire(), y, x, r)
 }
 
-// NewNeqComparator tewsts if x!=y.
+// signedComparator tests signed x>y if cin=0, and x>=y if
+// cin=1. The two's complement values are compared as unsigned values
+// with their sign bits inverted.
+func signedComparator(cc *Compiler, cin *Wire, x, y, r []*Wire) error {
+	x, y = cc.ZeroPad(x, y)
+	n := len(x)
+	if n == 0 {
+		return fmt.Errorf("invalid signed comparator arguments: x=%d, y=%d",
+			len(x), len(y))
+	}
+	xs := cc.Calloc.Wire()
+	cc.INV(x[n-1], xs)
+	ys := cc.Calloc.Wire()
+	cc.INV(y[n-1], ys)
+
+	return comparator(cc, cin, append(x[:n-1:n-1], xs),
+		append(y[:n-1:n-1], ys), r)
+}
+
+// NewIntGtComparator tests if signed x>y.
+func NewIntGtComparator(cc *Compiler, x, y, r []*Wire) error {
+	return signedComparator(cc, cc.ZeroWire(), x, y, r)
+}
+
+// NewIntGeComparator tests if signed x>=y.
+func NewIntGeComparator(cc *Compiler, x, y, r []*Wire) error {
+	return signedComparator(cc, cc.OneWire(), x, y, r)
+}
+
+// NewIntLtComparator tests if signed x<y.
+func NewIntLtComparator(cc *Compiler, x, y, r []*Wire) error {
+	return signedComparator(cc, cc.ZeroWire(), y, x, r)
+}
+
+// NewIntLeComparator tests if signed x<=y.
+func NewIntLeComparator(cc *Compiler, x, y, r []*Wire) error {
+	return signedComparator(cc, cc.OneWire(), y, x, r)
+}
+
+// NewNeqComparator tewsts if x!=y. If either argument is constant
+// zero, the comparator is implemented with NewIsNonZero.
 func NewNeqComparator(cc *Compiler, x, y, r []*Wire) error {
 	x, y = cc.ZeroPad(x, y)
 	if len(r) != 1 {
 		return fmt.Errorf("invalid neq comparator arguments: r=%d", len(r))
 	}
+	if cc.isZero(y) {
+		return NewIsNonZero(cc, x, r)
+	}
+	if cc.isZero(x) {
+		return NewIsNonZero(cc, y, r)
+	}
 
 	if len(x) == 1 {
 		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, x[0], y[0], r[0]))
@@ -91,11 +137,19 @@ func NewNeqComparator(cc *Compiler, x, y, r []*Wire) error {
 	return nil
 }
 
-// NewEqComparator tests if x==y.
+// NewEqComparator tests if x==y. If either argument is constant zero,
+// the comparator is implemented with NewIsZero.
 func NewEqComparator(cc *Compiler, x, y, r []*Wire) error {
 	if len(r) != 1 {
 		return fmt.Errorf("invalid eq comparator arguments: r=%d", len(r))
 	}
+	x, y = cc.ZeroPad(x, y)
+	if cc.isZero(y) {
+		return NewIsZero(cc, x, r)
+	}
+	if cc.isZero(x) {
+		return NewIsZero(cc, y, r)
+	}
 
 	// w = x == y
 	w := cc.Calloc.Wire()
@@ -108,6 +162,60 @@ func NewEqComparator(cc *Compiler, x, y, r []*Wire) error {
 	return nil
 }
 
+// NewIsZero tests if x==0. The test is an inverted OR-reduction of
+// the bits of x which is computed with a balanced tree of
+// log2(len(x)) levels.
+func NewIsZero(cc *Compiler, x, r []*Wire) error {
+	if len(r) != 1 {
+		return fmt.Errorf("invalid is zero arguments: r=%d", len(r))
+	}
+	if len(x) == 0 {
+		cc.ID(cc.OneWire(), r[0])
+		return nil
+	}
+	w := x[0]
+	if len(x) > 1 {
+		w = cc.Calloc.Wire()
+		orReduce(cc, x, w)
+	}
+	cc.INV(w, r[0])
+	return nil
+}
+
+// NewIsNonZero tests if x!=0. See NewIsZero for details.
+func NewIsNonZero(cc *Compiler, x, r []*Wire) error {
+	if len(r) != 1 {
+		return fmt.Errorf("invalid is non-zero arguments: r=%d", len(r))
+	}
+	switch len(x) {
+	case 0:
+		cc.ID(cc.ZeroWire(), r[0])
+	case 1:
+		cc.ID(x[0], r[0])
+	default:
+		orReduce(cc, x, r[0])
+	}
+	return nil
+}
+
+// orReduce computes r=OR(x) with a balanced tree of OR gates. The
+// argument x must have at least two wires.
+func orReduce(cc *Compiler, x []*Wire, r *Wire) {
+	for len(x) > 2 {
+		var next []*Wire
+		for i := 0; i+1 < len(x); i += 2 {
+			w := cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.OR, x[i], x[i+1], w))
+			next = append(next, w)
+		}
+		if len(x)%2 != 0 {
+			next = append(next, x[len(x)-1])
+		}
+		x = next
+	}
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.OR, x[0], x[1], r))
+}
+
 // NewLogicalAND implements logical AND implementing r=x&y. The input
 // and output wires must be 1 bit wide.
 func NewLogicalAND(cc *Compiler, x, y, r []*Wire) error {
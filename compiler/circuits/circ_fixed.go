@@ -0,0 +1,55 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewFixedMultiplier creates a signed fixed-point multiplier circuit
+// implementing z=x*y. The operands and the result have len(z) bits of
+// which frac bits are fractional bits. The full-width product is
+// computed from the sign-extended operands and then shifted right by
+// frac bits so the result is truncated towards negative infinity.
+func NewFixedMultiplier(cc *Compiler, frac int, x, y, z []*Wire) error {
+	n := len(z)
+	if len(x) != n || len(y) != n {
+		return fmt.Errorf("fixed-point multiplier: invalid arguments: "+
+			"x=%d, y=%d, z=%d", len(x), len(y), n)
+	}
+	if frac < 0 || frac >= n {
+		return fmt.Errorf("fixed-point multiplier: invalid fraction bits %d",
+			frac)
+	}
+
+	// Sign-extend the operands to the full product width.
+	width := 2 * n
+	xe := make([]*Wire, width)
+	ye := make([]*Wire, width)
+	for i := 0; i < width; i++ {
+		if i < n {
+			xe[i] = x[i]
+			ye[i] = y[i]
+		} else {
+			xe[i] = x[n-1]
+			ye[i] = y[n-1]
+		}
+	}
+
+	// The product bits above frac+n are dropped.
+	product := cc.Calloc.Wires(types.Size(frac + n))
+	err := NewMultiplier(cc, cc.Params.CircMultArrayTreshold, xe, ye, product)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		cc.ID(product[frac+i], z[i])
+	}
+	return nil
+}
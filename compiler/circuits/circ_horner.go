@@ -0,0 +1,111 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewHorner creates a polynomial evaluation circuit computing
+// out=coeffs[0]+coeffs[1]*x+...+coeffs[n-1]*x^(n-1) with Horner's
+// method. The intermediate values are computed with width bits so
+// the accumulated value is the polynomial's value modulo 2^width. If
+// width is 0, the intermediate values are computed with len(out)
+// bits. A width narrower than out limits the precision of the
+// result, which is extended to out; a wider width gives the same
+// result as len(out) since the result is truncated to out.
+//
+// The signed argument tells which coefficients are signed values and
+// xSigned tells if x is signed. The signed arguments narrower than
+// width are sign-extended and all other arguments are
+// zero-extended. The result is sign-extended to out if x is signed.
+func NewHorner(cc *Compiler, coeffs [][]*Wire, signed []bool,
+	x []*Wire, xSigned bool, width int, out []*Wire) error {
+
+	if len(coeffs) == 0 {
+		return fmt.Errorf("no polynomial coefficients")
+	}
+	if len(signed) != len(coeffs) {
+		return fmt.Errorf("invalid coefficient signedness: got %d, "+
+			"expected %d", len(signed), len(coeffs))
+	}
+	if len(x) == 0 {
+		return fmt.Errorf("no polynomial argument")
+	}
+	if width < 0 {
+		return fmt.Errorf("invalid intermediate width %d", width)
+	}
+	if width == 0 {
+		width = len(out)
+	}
+
+	extended := make([][]*Wire, len(coeffs))
+	for idx, c := range coeffs {
+		if len(c) == 0 {
+			return fmt.Errorf("no bits in coefficient %d", idx)
+		}
+		extended[idx] = hornerExtend(cc, c, signed[idx], width)
+	}
+	coeffs = extended
+	x = hornerExtend(cc, x, xSigned, width)
+
+	// The last step computes directly to out when the widths match.
+	direct := width == len(out)
+
+	acc := coeffs[len(coeffs)-1]
+	for i := len(coeffs) - 2; i >= 0; i-- {
+		prod := cc.Calloc.Wires(types.Size(width))
+		err := NewMultiplier(cc, cc.Params.CircMultArrayTreshold, acc, x, prod)
+		if err != nil {
+			return err
+		}
+		var sum []*Wire
+		if i == 0 && direct {
+			sum = out
+		} else {
+			sum = cc.Calloc.Wires(types.Size(width))
+		}
+		err = NewAdder(cc, prod, coeffs[i], sum)
+		if err != nil {
+			return err
+		}
+		acc = sum
+	}
+	if direct && len(coeffs) > 1 {
+		return nil
+	}
+	for i := 0; i < len(out); i++ {
+		if i < len(acc) {
+			cc.ID(acc[i], out[i])
+		} else if xSigned {
+			cc.ID(acc[len(acc)-1], out[i])
+		} else {
+			cc.ID(cc.ZeroWire(), out[i])
+		}
+	}
+	return nil
+}
+
+// hornerExtend extends or truncates the wires w to bits wires. The
+// extension repeats the sign bit if signed is true and uses zero
+// wires otherwise.
+func hornerExtend(cc *Compiler, w []*Wire, signed bool, bits int) []*Wire {
+	if len(w) >= bits {
+		return w[:bits]
+	}
+	if signed {
+		return signExtend(w, bits)
+	}
+	result := make([]*Wire, bits)
+	copy(result, w)
+	for i := len(w); i < bits; i++ {
+		result[i] = cc.ZeroWire()
+	}
+	return result
+}
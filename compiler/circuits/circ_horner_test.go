@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+const (
+	hornerInputBits = 8
+)
+
+func newHornerCircuit(t *testing.T, n, outBits, width int,
+	signed, xSigned bool) *circuit.Circuit {
+
+	inputs := makeWires(hornerInputBits*(n+1), false)
+	outputs := makeWires(outBits, true)
+
+	io := NewIO(hornerInputBits, "x")
+	var coeffs [][]*Wire
+	var signs []bool
+	for i := 0; i < n; i++ {
+		io = append(io, NewIO(hornerInputBits, "c")...)
+		from := (i + 1) * hornerInputBits
+		coeffs = append(coeffs, inputs[from:from+hornerInputBits])
+		signs = append(signs, signed)
+	}
+
+	cc, err := NewCompiler(params, calloc, io, NewIO(outBits, "out"),
+		inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = NewHorner(cc, coeffs, signs, inputs[:hornerInputBits], xSigned,
+		width, outputs)
+	if err != nil {
+		t.Fatalf("NewHorner: %s", err)
+	}
+	return cc.Compile()
+}
+
+func TestHorner(t *testing.T) {
+	tests := []struct {
+		coeffs  []int64
+		outBits int
+	}{
+		{coeffs: []int64{42}, outBits: 8},
+		{coeffs: []int64{7, 3}, outBits: 8},
+		{coeffs: []int64{1, 2, 3}, outBits: 8},
+		{coeffs: []int64{1, 2, 3}, outBits: 32},
+		{coeffs: []int64{255, 0, 17, 5}, outBits: 16},
+		{coeffs: []int64{255, 0, 17, 5}, outBits: 40},
+	}
+	for _, test := range tests {
+		circ := newHornerCircuit(t, len(test.coeffs), test.outBits, 0,
+			false, false)
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(test.outBits))
+
+		for _, x := range []int64{0, 1, 2, 10, 127, 200, 255} {
+			inputs := []*big.Int{big.NewInt(x)}
+			expected := new(big.Int)
+			pow := big.NewInt(1)
+			for _, c := range test.coeffs {
+				inputs = append(inputs, big.NewInt(c))
+				expected.Add(expected, new(big.Int).Mul(big.NewInt(c), pow))
+				pow.Mul(pow, big.NewInt(x))
+			}
+			expected.Mod(expected, mod)
+
+			results, err := circ.Compute(inputs)
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if results[0].Cmp(expected) != 0 {
+				t.Errorf("poly%v(%d) at %d bits: got %v, expected %v",
+					test.coeffs, x, test.outBits, results[0], expected)
+			}
+		}
+	}
+}
+
+func TestHornerSigned(t *testing.T) {
+	tests := []struct {
+		coeffs  []int64
+		outBits int
+	}{
+		{coeffs: []int64{-3}, outBits: 16},
+		{coeffs: []int64{-3, 1}, outBits: 16},
+		{coeffs: []int64{5, -2, -1}, outBits: 32},
+		{coeffs: []int64{-128, 127, -1}, outBits: 40},
+	}
+	for _, test := range tests {
+		circ := newHornerCircuit(t, len(test.coeffs), test.outBits, 0,
+			true, false)
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(test.outBits))
+		inputMod := big.NewInt(1 << hornerInputBits)
+
+		for _, x := range []int64{0, 1, 2, 10, 127, 200, 255} {
+			inputs := []*big.Int{big.NewInt(x)}
+			expected := new(big.Int)
+			pow := big.NewInt(1)
+			for _, c := range test.coeffs {
+				inputs = append(inputs,
+					new(big.Int).Mod(big.NewInt(c), inputMod))
+				expected.Add(expected, new(big.Int).Mul(big.NewInt(c), pow))
+				pow.Mul(pow, big.NewInt(x))
+			}
+			expected.Mod(expected, mod)
+
+			results, err := circ.Compute(inputs)
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if results[0].Cmp(expected) != 0 {
+				t.Errorf("poly%v(%d) at %d bits: got %v, expected %v",
+					test.coeffs, x, test.outBits, results[0], expected)
+			}
+		}
+	}
+}
+
+func TestHornerWidth(t *testing.T) {
+	tests := []struct {
+		coeffs  []int64
+		outBits int
+		width   int
+		signed  bool
+	}{
+		// Narrow intermediate values truncate the result.
+		{coeffs: []int64{1, 2, 3}, outBits: 32, width: 8},
+		{coeffs: []int64{255, 0, 17, 5}, outBits: 16, width: 12},
+		{coeffs: []int64{-3, 1}, outBits: 16, width: 8, signed: true},
+		{coeffs: []int64{5, -2, -1}, outBits: 32, width: 10, signed: true},
+		// Wide intermediate values give the modular result.
+		{coeffs: []int64{1, 2, 3}, outBits: 8, width: 32},
+		{coeffs: []int64{-128, 127, -1}, outBits: 16, width: 40,
+			signed: true},
+		// Constant polynomial.
+		{coeffs: []int64{-42}, outBits: 16, width: 8, signed: true},
+	}
+	for _, test := range tests {
+		circ := newHornerCircuit(t, len(test.coeffs), test.outBits,
+			test.width, test.signed, test.signed)
+		width := test.width
+		if width > test.outBits {
+			width = test.outBits
+		}
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(width))
+		outMod := new(big.Int).Lsh(big.NewInt(1), uint(test.outBits))
+		inputMod := big.NewInt(1 << hornerInputBits)
+
+		for _, x := range []int64{0, 1, 2, 10, 127, 200, 255} {
+			xv := x
+			if test.signed && xv >= 128 {
+				xv -= 256
+			}
+			inputs := []*big.Int{big.NewInt(x)}
+			expected := new(big.Int)
+			pow := big.NewInt(1)
+			for _, c := range test.coeffs {
+				inputs = append(inputs,
+					new(big.Int).Mod(big.NewInt(c), inputMod))
+				expected.Add(expected, new(big.Int).Mul(big.NewInt(c), pow))
+				pow.Mul(pow, big.NewInt(xv))
+			}
+			// The result has width bits, extended to outBits.
+			expected.Mod(expected, mod)
+			if test.signed && expected.Bit(width-1) != 0 {
+				expected.Sub(expected, mod)
+			}
+			expected.Mod(expected, outMod)
+
+			results, err := circ.Compute(inputs)
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if results[0].Cmp(expected) != 0 {
+				t.Errorf("poly%v(%d) at %d/%d bits: got %v, expected %v",
+					test.coeffs, xv, test.width, test.outBits, results[0],
+					expected)
+			}
+		}
+	}
+}
@@ -8,9 +8,17 @@ package circuits
 
 import (
 	"fmt"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/types"
 )
 
-// NewIndex creates a new array element selection (index) circuit.
+// NewIndex creates a new array element selection (index) circuit. The
+// index is interpreted as an unsigned integer and indices beyond the
+// array bounds select the zero value. If the compiler params have
+// NoBoundsCheck set, the circuit assumes the index is within the
+// array bounds and omits the bounds guard. The out-of-bounds indices
+// then select an unspecified array element.
 func NewIndex(cc *Compiler, size int, array, index, out []*Wire) error {
 	if len(array)%size != 0 {
 		return fmt.Errorf("array width %d must be multiple of element size %d",
@@ -35,11 +43,31 @@ func NewIndex(cc *Compiler, size int, array, index, out []*Wire) error {
 		bits++
 	}
 
-	return newIndex(cc, bits-1, length, size, array, index, out)
+	if cc.Params != nil && cc.Params.NoBoundsCheck {
+		return newIndex(cc, bits-1, length, size, array, index, out, false)
+	}
+	if len(index) < 64 && 1<<len(index) <= n {
+		// All index values are within the array bounds.
+		return newIndex(cc, bits-1, length, size, array, index, out, true)
+	}
+
+	val := cc.Calloc.Wires(types.Size(size))
+	err := newIndex(cc, bits-1, length, size, array, index, val, true)
+	if err != nil {
+		return err
+	}
+	inRange := newConstLt(cc, index, uint64(n))
+	for i := 0; i < size; i++ {
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, val[i], inRange, out[i]))
+	}
+	return nil
 }
 
+// newIndex creates the MUX tree that selects the element at index
+// from array. If checked is false, the tree omits the MUXes that
+// select the zero value for the positions beyond the array end.
 func newIndex(cc *Compiler, bit, length, size int,
-	array, index, out []*Wire) error {
+	array, index, out []*Wire, checked bool) error {
 
 	// Default "not found" value.
 	def := make([]*Wire, size)
@@ -55,8 +83,13 @@ func newIndex(cc *Compiler, bit, length, size int,
 		var tVal []*Wire
 		if n > 1 {
 			tVal = array[size : 2*size]
-		} else {
+		} else if checked {
 			tVal = def
+		} else {
+			for i := 0; i < size; i++ {
+				cc.ID(fVal[i], out[i])
+			}
+			return nil
 		}
 		return NewMUX(cc, index[0:1], tVal, fVal, out)
 	}
@@ -67,17 +100,18 @@ func newIndex(cc *Compiler, bit, length, size int,
 		fArray = fArray[:length*size]
 	}
 
-	if bit >= len(index) {
-		// Not enough bits to select upper half so just select from
-		// the lower half.
-		return newIndex(cc, bit-1, length, size, fArray, index, out)
+	if bit >= len(index) || !checked && n <= length {
+		// Not enough bits to select upper half, or the upper half is
+		// empty and not checked, so just select from the lower half.
+		return newIndex(cc, bit-1, length, size, fArray, index, out,
+			checked)
 	}
 
 	fVal := make([]*Wire, size)
 	for i := 0; i < size; i++ {
 		fVal[i] = cc.Calloc.Wire()
 	}
-	err := newIndex(cc, bit-1, length, size, fArray, index, fVal)
+	err := newIndex(cc, bit-1, length, size, fArray, index, fVal, checked)
 	if err != nil {
 		return err
 	}
@@ -89,7 +123,7 @@ func newIndex(cc *Compiler, bit, length, size int,
 			tVal[i] = cc.Calloc.Wire()
 		}
 		err = newIndex(cc, bit-1, length, size,
-			array[length*size:], index, tVal)
+			array[length*size:], index, tVal, checked)
 		if err != nil {
 			return err
 		}
@@ -99,3 +133,36 @@ func newIndex(cc *Compiler, bit, length, size int,
 
 	return NewMUX(cc, index[bit:bit+1], tVal, fVal, out)
 }
+
+// newConstLt returns a wire that is set if x<c for the constant c.
+func newConstLt(cc *Compiler, x []*Wire, c uint64) *Wire {
+	lt := cc.ZeroWire()
+	zero := true
+
+	for i := 0; i < len(x); i++ {
+		set := i < 64 && c&(1<<i) != 0
+		if !set && zero {
+			continue
+		}
+		inv := cc.Calloc.Wire()
+		cc.INV(x[i], inv)
+
+		if set {
+			// lt = !x[i] || lt
+			if zero {
+				lt = inv
+			} else {
+				w := cc.Calloc.Wire()
+				cc.AddGate(cc.Calloc.BinaryGate(circuit.OR, inv, lt, w))
+				lt = w
+			}
+			zero = false
+		} else {
+			// lt = !x[i] && lt
+			w := cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, inv, lt, w))
+			lt = w
+		}
+	}
+	return lt
+}
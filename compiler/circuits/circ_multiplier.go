@@ -1,7 +1,7 @@
 //
 // circ_multiplier.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -13,11 +13,19 @@ import (
 	"github.com/markkurossi/mpc/types"
 )
 
-// NewMultiplier creates a multiplier circuit implementing x*y=z.
+// NewMultiplier creates a multiplier circuit implementing x*y=z. The
+// function uses the tree multiplier if the compiler parameters
+// enable it for the operand size. Otherwise the function uses the
+// Karatsuba multiplier which switches to the array multiplier for
+// operands of at most arrayTreshold bits.
 func NewMultiplier(c *Compiler, arrayTreshold int, x, y, z []*Wire) error {
 	if false {
 		return NewArrayMultiplier(c, x, y, z)
 	}
+	treeTreshold := c.Params.CircMultTreeTreshold
+	if treeTreshold > 0 && max(len(x), len(y)) >= treeTreshold {
+		return NewTreeMultiplier(c, x, y, z)
+	}
 	if arrayTreshold < 8 {
 		var ok bool
 
@@ -41,8 +49,8 @@ func NewArrayMultiplier(cc *Compiler, x, y, z []*Wire) error {
 	// One bit multiplication is AND.
 	if len(x) == 1 {
 		cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, x[0], y[0], z[0]))
-		if len(z) > 1 {
-			z[1] = cc.ZeroWire()
+		for i := 1; i < len(z); i++ {
+			z[i] = cc.ZeroWire()
 		}
 		return nil
 	}
@@ -124,12 +132,87 @@ func NewArrayMultiplier(cc *Compiler, x, y, z []*Wire) error {
 		c = cout
 	}
 	for i := j + len(x) + 1; i < len(z); i++ {
-		z[1] = cc.ZeroWire()
+		z[i] = cc.ZeroWire()
 	}
 
 	return nil
 }
 
+// NewTreeMultiplier creates a multiplier circuit implementing
+// x*y=z. This function implements the Wallace Tree Multiplier
+// (https://en.wikipedia.org/wiki/Wallace_tree). The partial products
+// are reduced with layers of carry-save adders until each column has
+// at most two bits, and the final two rows are summed with a
+// ripple-carry adder. The tree has the same number of non-XOR gates
+// as the array multiplier but its depth is smaller on large operands.
+func NewTreeMultiplier(cc *Compiler, x, y, z []*Wire) error {
+	// Partial products by the result columns.
+	columns := make([][]*Wire, len(z))
+	for j, yn := range y {
+		for i, xn := range x {
+			if i+j >= len(z) {
+				break
+			}
+			w := cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, xn, yn, w))
+			columns[i+j] = append(columns[i+j], w)
+		}
+	}
+
+	// Reduce columns with carry-save adder layers.
+	for {
+		var height int
+		for _, col := range columns {
+			height = max(height, len(col))
+		}
+		if height <= 2 {
+			break
+		}
+		next := make([][]*Wire, len(z))
+		for k, col := range columns {
+			var i int
+			for ; i+3 <= len(col); i += 3 {
+				s := cc.Calloc.Wire()
+				var c *Wire
+				if k+1 < len(z) {
+					c = cc.Calloc.Wire()
+					next[k+1] = append(next[k+1], c)
+				}
+				NewFullAdder(cc, col[i], col[i+1], col[i+2], s, c)
+				next[k] = append(next[k], s)
+			}
+			if i+2 == len(col) && len(col) > 2 {
+				s := cc.Calloc.Wire()
+				var c *Wire
+				if k+1 < len(z) {
+					c = cc.Calloc.Wire()
+					next[k+1] = append(next[k+1], c)
+				}
+				NewHalfAdder(cc, col[i], col[i+1], s, c)
+				next[k] = append(next[k], s)
+			} else {
+				next[k] = append(next[k], col[i:]...)
+			}
+		}
+		columns = next
+	}
+
+	// Sum the final two rows.
+	a := make([]*Wire, len(z))
+	b := make([]*Wire, len(z))
+	for k, col := range columns {
+		a[k] = cc.ZeroWire()
+		b[k] = cc.ZeroWire()
+		if len(col) > 0 {
+			a[k] = col[0]
+		}
+		if len(col) > 1 {
+			b[k] = col[1]
+		}
+	}
+	return NewAdder(cc, a, b, z)
+}
+
 // NewKaratsubaMultiplier creates a multiplier circuit implementing
 // the Karatsuba algorithm
 // (https://en.wikipedia.org/wiki/Karatsuba_algorithm). The Karatsuba
@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+type multiplier func(cc *Compiler, x, y, z []*Wire) error
+
+func newMultiplierCircuit(mult multiplier, xBits, yBits, zBits int) (
+	*circuit.Circuit, error) {
+
+	inputs := makeWires(xBits+yBits, false)
+	outputs := makeWires(zBits, true)
+
+	in := append(NewIO(xBits, "x"), NewIO(yBits, "y")...)
+	cc, err := NewCompiler(params, calloc, in, NewIO(zBits, "z"),
+		inputs, outputs)
+	if err != nil {
+		return nil, err
+	}
+	err = mult(cc, inputs[:xBits], inputs[xBits:], outputs)
+	if err != nil {
+		return nil, err
+	}
+	circ := cc.Compile()
+	circ.AssignLevels()
+	return circ, nil
+}
+
+func TestTreeMultiplier(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, sizes := range [][3]int{
+		{1, 1, 1}, {3, 5, 8}, {8, 8, 8}, {8, 8, 16},
+		{13, 7, 11}, {32, 32, 32}, {64, 64, 128},
+	} {
+		tree, err := newMultiplierCircuit(NewTreeMultiplier,
+			sizes[0], sizes[1], sizes[2])
+		if err != nil {
+			t.Fatalf("NewTreeMultiplier%v: %v", sizes, err)
+		}
+		array, err := newMultiplierCircuit(NewArrayMultiplier,
+			sizes[0], sizes[1], sizes[2])
+		if err != nil {
+			t.Fatalf("NewArrayMultiplier%v: %v", sizes, err)
+		}
+		mask := new(big.Int).Lsh(big.NewInt(1), uint(sizes[2]))
+		mask.Sub(mask, big.NewInt(1))
+
+		for i := 0; i < 100; i++ {
+			x := new(big.Int).Rand(rnd,
+				new(big.Int).Lsh(big.NewInt(1), uint(sizes[0])))
+			y := new(big.Int).Rand(rnd,
+				new(big.Int).Lsh(big.NewInt(1), uint(sizes[1])))
+
+			expected := new(big.Int).Mul(x, y)
+			expected.And(expected, mask)
+
+			tr, err := tree.Compute([]*big.Int{x, y})
+			if err != nil {
+				t.Fatalf("tree compute failed: %v", err)
+			}
+			ar, err := array.Compute([]*big.Int{x, y})
+			if err != nil {
+				t.Fatalf("array compute failed: %v", err)
+			}
+			if tr[0].Cmp(expected) != 0 || ar[0].Cmp(tr[0]) != 0 {
+				t.Errorf("%v: %v*%v: tree=%v, array=%v, expected %v",
+					sizes, x, y, tr[0], ar[0], expected)
+			}
+		}
+	}
+}
+
+func TestTreeMultiplierLevels(t *testing.T) {
+	for _, bits := range []int{32, 64} {
+		tree, err := newMultiplierCircuit(NewTreeMultiplier,
+			bits, bits, bits)
+		if err != nil {
+			t.Fatalf("NewTreeMultiplier: %v", err)
+		}
+		array, err := newMultiplierCircuit(NewArrayMultiplier,
+			bits, bits, bits)
+		if err != nil {
+			t.Fatalf("NewArrayMultiplier: %v", err)
+		}
+		tl := tree.Stats[circuit.NumLevels]
+		al := array.Stats[circuit.NumLevels]
+		if tl >= al {
+			t.Errorf("%d bits: tree levels %d >= array levels %d", bits, tl, al)
+		}
+	}
+}
+
+func BenchmarkMultiplierLevels(b *testing.B) {
+	for _, bits := range []int{64, 128, 256} {
+		for _, m := range []struct {
+			name string
+			mult multiplier
+		}{
+			{"array", NewArrayMultiplier},
+			{"tree", NewTreeMultiplier},
+			{"karatsuba", func(cc *Compiler, x, y, z []*Wire) error {
+				return NewKaratsubaMultiplier(cc, 21, x, y, z)
+			}},
+		} {
+			b.Run(fmt.Sprintf("%s-%d", m.name, bits), func(b *testing.B) {
+				var circ *circuit.Circuit
+				var err error
+				for i := 0; i < b.N; i++ {
+					circ, err = newMultiplierCircuit(m.mult, bits, bits, bits)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(circ.Stats[circuit.NumLevels]),
+					"levels")
+				b.ReportMetric(float64(circ.Cost()), "cost")
+			})
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewOverflowMultiplier creates a multiplier circuit implementing
+// z=x*y. The circuit computes the double-width product and sets the
+// overflow wire ov if the product does not fit into len(z) bits. The
+// argument signed specifies if the operands are signed or unsigned
+// integers of len(z) bits. The unsigned product overflows if any bit
+// of its high half is set. The signed product overflows if any bit of
+// its high half differs from the sign bit of z.
+func NewOverflowMultiplier(cc *Compiler, signed bool, x, y, z []*Wire,
+	ov *Wire) error {
+
+	x, y = cc.ZeroPad(x, y)
+	n := len(z)
+	if n == 0 || len(x) != n {
+		return fmt.Errorf(
+			"invalid overflow multiplier arguments: x=%d, y=%d, z=%d",
+			len(x), len(y), len(z))
+	}
+	if signed {
+		// Sign-extend the operands to the product width.
+		x = signExtend(x, 2*n)
+		y = signExtend(y, 2*n)
+	}
+
+	// The low half of the product is the result z. The signed
+	// overflow test needs the sign bit of the low half but the output
+	// wires can't be used as gate inputs so the signed product is
+	// computed into its own wires.
+	var product []*Wire
+	if signed {
+		product = cc.Calloc.Wires(types.Size(2 * n))
+	} else {
+		product = append(z[:n:n], cc.Calloc.Wires(types.Size(n))...)
+	}
+	err := NewMultiplier(cc, cc.Params.CircMultArrayTreshold, x, y, product)
+	if err != nil {
+		return err
+	}
+	high := product[n:]
+	if signed {
+		for i := 0; i < n; i++ {
+			cc.ID(product[i], z[i])
+		}
+		var diff []*Wire
+		for _, w := range high {
+			d := cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, w, product[n-1], d))
+			diff = append(diff, d)
+		}
+		high = diff
+	}
+	if len(high) == 1 {
+		cc.ID(high[0], ov)
+	} else {
+		orReduce(cc, high, ov)
+	}
+	return nil
+}
+
+// signExtend extends the wires x to bits wires by repeating the sign
+// bit of x.
+func signExtend(x []*Wire, bits int) []*Wire {
+	result := make([]*Wire, bits)
+	copy(result, x)
+	for i := len(x); i < bits; i++ {
+		result[i] = x[len(x)-1]
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestOverflowMultiplier(t *testing.T) {
+	const bits = 6
+	const mask = 1<<bits - 1
+
+	for _, signed := range []bool{false, true} {
+		inputs := makeWires(bits*2, false)
+		outputs := makeWires(bits+1, true)
+
+		io := append(NewIO(bits, "x"), NewIO(bits, "y")...)
+		cc, err := NewCompiler(params, calloc, io,
+			append(NewIO(bits, "z"), NewIO(1, "ov")...), inputs, outputs)
+		if err != nil {
+			t.Fatalf("NewCompiler: %s", err)
+		}
+		err = NewOverflowMultiplier(cc, signed, inputs[:bits], inputs[bits:],
+			outputs[:bits], outputs[bits])
+		if err != nil {
+			t.Fatalf("NewOverflowMultiplier: %s", err)
+		}
+		circ := cc.Compile()
+
+		lo, hi := int64(0), int64(mask)
+		if signed {
+			lo, hi = -(1 << (bits - 1)), 1<<(bits-1)-1
+		}
+		for x := lo; x <= hi; x++ {
+			for y := lo; y <= hi; y++ {
+				product := x * y
+				overflow := product < lo || product > hi
+
+				results, err := circ.Compute([]*big.Int{
+					big.NewInt(x & mask),
+					big.NewInt(y & mask),
+				})
+				if err != nil {
+					t.Fatalf("compute failed: %s", err)
+				}
+				if results[0].Int64() != product&mask {
+					t.Fatalf("signed=%v: %d*%d: got %v, expected %v",
+						signed, x, y, results[0], product&mask)
+				}
+				if (results[1].Int64() != 0) != overflow {
+					t.Fatalf("signed=%v: %d*%d: got overflow %v, expected %v",
+						signed, x, y, results[1], overflow)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewSaturatingAdder creates a saturating adder circuit implementing
+// z=x+y. If the sum overflows, the result is clamped to the minimum
+// or maximum value of the type. The argument signed specifies if the
+// operands are signed or unsigned integers of len(z) bits.
+func NewSaturatingAdder(cc *Compiler, signed bool, x, y, z []*Wire) error {
+	x, y, err := saturatingArgs(cc, x, y, z)
+	if err != nil {
+		return err
+	}
+	n := len(z)
+
+	// Full-width sum with the carry bit.
+	sum := cc.Calloc.Wires(types.Size(n + 1))
+	if err := NewAdder(cc, x, y, sum); err != nil {
+		return err
+	}
+	if !signed {
+		// The carry clamps the result to all ones.
+		for i := 0; i < n; i++ {
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.OR, sum[i], sum[n], z[i]))
+		}
+		return nil
+	}
+
+	// Overflow if the operands have the same sign and the sum's sign
+	// differs from it: ov = (xs XNOR ys) AND (ss XOR xs).
+	w1 := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.XNOR, x[n-1], y[n-1], w1))
+	w2 := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, sum[n-1], x[n-1], w2))
+	ov := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, w1, w2, ov))
+
+	return saturateSigned(cc, ov, x[n-1], sum[:n], z)
+}
+
+// NewSaturatingSubtractor creates a saturating subtractor circuit
+// implementing z=x-y. If the difference overflows, the result is
+// clamped to the minimum or maximum value of the type. The argument
+// signed specifies if the operands are signed or unsigned integers
+// of len(z) bits.
+func NewSaturatingSubtractor(cc *Compiler, signed bool, x, y, z []*Wire) error {
+	x, y, err := saturatingArgs(cc, x, y, z)
+	if err != nil {
+		return err
+	}
+	n := len(z)
+
+	// Full-width difference with the borrow bit.
+	diff := cc.Calloc.Wires(types.Size(n + 1))
+	if err := NewSubtractor(cc, x, y, diff); err != nil {
+		return err
+	}
+	if !signed {
+		// The borrow clamps the result to zero.
+		nb := cc.Calloc.Wire()
+		cc.INV(diff[n], nb)
+		for i := 0; i < n; i++ {
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, diff[i], nb, z[i]))
+		}
+		return nil
+	}
+
+	// Overflow if the operands have different signs and the
+	// difference's sign differs from x: ov = (xs XOR ys) AND (ds XOR xs).
+	w1 := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, x[n-1], y[n-1], w1))
+	w2 := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, diff[n-1], x[n-1], w2))
+	ov := cc.Calloc.Wire()
+	cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, w1, w2, ov))
+
+	return saturateSigned(cc, ov, x[n-1], diff[:n], z)
+}
+
+func saturatingArgs(cc *Compiler, x, y, z []*Wire) ([]*Wire, []*Wire, error) {
+	if len(z) == 0 {
+		return nil, nil, fmt.Errorf("invalid saturating arguments: z=%d",
+			len(z))
+	}
+	x, y = cc.ZeroPad(x, y)
+	if len(x) != len(z) {
+		return nil, nil, fmt.Errorf(
+			"invalid saturating arguments: x=%d, y=%d, z=%d",
+			len(x), len(y), len(z))
+	}
+	return x, y, nil
+}
+
+// saturateSigned sets z to v or, if ov is set, to the minimum value
+// if sign is set and to the maximum value otherwise.
+func saturateSigned(cc *Compiler, ov, sign *Wire, v, z []*Wire) error {
+	n := len(z)
+
+	// The saturation value is ^sign for the value bits and sign for
+	// the sign bit.
+	nsign := cc.Calloc.Wire()
+	cc.INV(sign, nsign)
+
+	for i := 0; i < n; i++ {
+		sat := nsign
+		if i == n-1 {
+			sat = sign
+		}
+		// z[i] = v[i] XOR (ov AND (sat XOR v[i]))
+		w1 := cc.Calloc.Wire()
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, sat, v[i], w1))
+		w2 := cc.Calloc.Wire()
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, ov, w1, w2))
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, v[i], w2, z[i]))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+type saturatingFunc func(cc *Compiler, signed bool, x, y, z []*Wire) error
+
+func newSaturatingCircuit(t *testing.T, bits int, signed bool,
+	f saturatingFunc) *circuit.Circuit {
+
+	inputs := makeWires(bits*2, false)
+	outputs := makeWires(bits, true)
+
+	io := append(NewIO(bits, "x"), NewIO(bits, "y")...)
+
+	cc, err := NewCompiler(params, calloc, io, NewIO(bits, "out"),
+		inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = f(cc, signed, inputs[:bits], inputs[bits:], outputs)
+	if err != nil {
+		t.Fatalf("saturating circuit: %s", err)
+	}
+	return cc.Compile()
+}
+
+func TestSaturating(t *testing.T) {
+	const bits = 8
+
+	tests := []struct {
+		name   string
+		signed bool
+		f      saturatingFunc
+		op     func(x, y int64) int64
+	}{
+		{"uadd", false, NewSaturatingAdder, func(x, y int64) int64 {
+			return x + y
+		}},
+		{"usub", false, NewSaturatingSubtractor, func(x, y int64) int64 {
+			return x - y
+		}},
+		{"sadd", true, NewSaturatingAdder, func(x, y int64) int64 {
+			return x + y
+		}},
+		{"ssub", true, NewSaturatingSubtractor, func(x, y int64) int64 {
+			return x - y
+		}},
+	}
+	for _, test := range tests {
+		circ := newSaturatingCircuit(t, bits, test.signed, test.f)
+
+		lo, hi := int64(0), int64(1<<bits-1)
+		if test.signed {
+			lo, hi = -(1 << (bits - 1)), 1<<(bits-1)-1
+		}
+		for x := lo; x <= hi; x++ {
+			for y := lo; y <= hi; y++ {
+				expected := test.op(x, y)
+				if expected < lo {
+					expected = lo
+				} else if expected > hi {
+					expected = hi
+				}
+				results, err := circ.Compute([]*big.Int{
+					big.NewInt(x & 0xff),
+					big.NewInt(y & 0xff),
+				})
+				if err != nil {
+					t.Fatalf("compute failed: %s", err)
+				}
+				if results[0].Int64() != expected&0xff {
+					t.Fatalf("%s(%d, %d): got %v, expected %v",
+						test.name, x, y, results[0], expected&0xff)
+				}
+			}
+		}
+	}
+}
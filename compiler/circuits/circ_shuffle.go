@@ -0,0 +1,236 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewObliviousShuffle creates an oblivious shuffle circuit that
+// permutes the elements of the array arr with a Beneš permutation
+// network. The elements are elemBits wide. The network's switches are
+// controlled by the control wires so the routing is data-independent
+// but the applied permutation is hidden. The network uses
+// ShuffleSwitches(n) control bits for an n-element array; missing
+// control bits are treated as zero and extra control bits are
+// ignored. The ShuffleControl function computes the control bits for
+// a given permutation.
+func NewObliviousShuffle(cc *Compiler, elemBits int, arr, control,
+	out []*Wire) error {
+
+	if elemBits <= 0 || len(arr)%elemBits != 0 {
+		return fmt.Errorf("array width %d must be multiple of element size %d",
+			len(arr), elemBits)
+	}
+	if len(out) != len(arr) {
+		return fmt.Errorf("invalid shuffle arguments: arr=%d, out=%d",
+			len(arr), len(out))
+	}
+	n := len(arr) / elemBits
+	if n == 0 {
+		return nil
+	}
+	switches := ShuffleSwitches(n)
+	for len(control) < switches {
+		control = append(control, cc.ZeroWire())
+	}
+
+	var in, result [][]*Wire
+	for i := 0; i < n; i++ {
+		in = append(in, arr[i*elemBits:(i+1)*elemBits])
+		result = append(result, out[i*elemBits:(i+1)*elemBits])
+	}
+	newBenes(cc, in, control[:switches], result)
+
+	return nil
+}
+
+func newBenes(cc *Compiler, in [][]*Wire, control []*Wire, out [][]*Wire) {
+	n := len(in)
+	switch n {
+	case 1:
+		for i := 0; i < len(in[0]); i++ {
+			cc.ID(in[0][i], out[0][i])
+		}
+		return
+
+	case 2:
+		newSwitch(cc, control[0], in[0], in[1], out[0], out[1])
+		return
+	}
+
+	top := n / 2
+	bottom := n - top
+	elemBits := len(in[0])
+
+	topIn := make([][]*Wire, top)
+	topOut := make([][]*Wire, top)
+	bottomIn := make([][]*Wire, bottom)
+	bottomOut := make([][]*Wire, bottom)
+
+	for i := 0; i < top; i++ {
+		topIn[i] = cc.Calloc.Wires(types.Size(elemBits))
+		topOut[i] = cc.Calloc.Wires(types.Size(elemBits))
+		bottomIn[i] = cc.Calloc.Wires(types.Size(elemBits))
+		bottomOut[i] = cc.Calloc.Wires(types.Size(elemBits))
+	}
+	if bottom > top {
+		// The last element of an odd-sized network bypasses the
+		// input and output switches.
+		bottomIn[top] = in[n-1]
+		bottomOut[top] = out[n-1]
+	}
+
+	// Input switches.
+	for i := 0; i < top; i++ {
+		newSwitch(cc, control[i], in[2*i], in[2*i+1], topIn[i], bottomIn[i])
+	}
+	control = control[top:]
+
+	// Sub-networks.
+	topSwitches := ShuffleSwitches(top)
+	newBenes(cc, topIn, control[:topSwitches], topOut)
+	control = control[topSwitches:]
+
+	bottomSwitches := ShuffleSwitches(bottom)
+	newBenes(cc, bottomIn, control[:bottomSwitches], bottomOut)
+	control = control[bottomSwitches:]
+
+	// Output switches.
+	for i := 0; i < top; i++ {
+		newSwitch(cc, control[i], topOut[i], bottomOut[i], out[2*i],
+			out[2*i+1])
+	}
+}
+
+// newSwitch creates a conditional swap circuit that sets the outputs
+// oa and ob to b and a if c is set, and to a and b otherwise.
+func newSwitch(cc *Compiler, c *Wire, a, b, oa, ob []*Wire) {
+	for i := 0; i < len(a); i++ {
+		w1 := cc.Calloc.Wire()
+		w2 := cc.Calloc.Wire()
+
+		// w1 = XOR(a[i], b[i])
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, a[i], b[i], w1))
+
+		// w2 = AND(w1, c)
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, w1, c, w2))
+
+		// oa[i] = XOR(a[i], w2), ob[i] = XOR(b[i], w2)
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, a[i], w2, oa[i]))
+		cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, b[i], w2, ob[i]))
+	}
+}
+
+// ShuffleSwitches returns the number of switches, and the number of
+// control bits, in the permutation network of an n-element array.
+func ShuffleSwitches(n int) int {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	default:
+		top := n / 2
+		return 2*top + ShuffleSwitches(top) + ShuffleSwitches(n-top)
+	}
+}
+
+// ShuffleControl computes the control bits for the oblivious shuffle
+// network that implements the permutation perm. The permutation maps
+// input element i to the output element perm[i].
+func ShuffleControl(perm []int) ([]bool, error) {
+	seen := make([]bool, len(perm))
+	for _, p := range perm {
+		if p < 0 || p >= len(perm) || seen[p] {
+			return nil, fmt.Errorf("invalid permutation: %v", perm)
+		}
+		seen[p] = true
+	}
+	return routeBenes(perm), nil
+}
+
+func routeBenes(perm []int) []bool {
+	n := len(perm)
+	switch n {
+	case 0, 1:
+		return nil
+	case 2:
+		return []bool{perm[0] == 1}
+	}
+
+	top := n / 2
+	paired := 2 * top
+
+	inv := make([]int, n)
+	for i, p := range perm {
+		inv[p] = i
+	}
+
+	// Assign elements to the top (0) and bottom (1) sub-networks so
+	// that the elements of each input and output switch go to
+	// different sub-networks.
+	color := make([]int, n)
+	for i := range color {
+		color[i] = -1
+	}
+	var stack []int
+	assign := func(e, c int) {
+		if color[e] < 0 {
+			color[e] = c
+			stack = append(stack, e)
+		}
+	}
+	propagate := func() {
+		for len(stack) > 0 {
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if e < paired {
+				assign(e^1, 1-color[e])
+			}
+			if perm[e] < paired {
+				assign(inv[perm[e]^1], 1-color[e])
+			}
+		}
+	}
+	if n > paired {
+		// The unpaired last element must use the bottom network.
+		assign(n-1, 1)
+		propagate()
+	}
+	for e := 0; e < n; e++ {
+		if color[e] < 0 {
+			assign(e, 0)
+			propagate()
+		}
+	}
+
+	var inSwitches, outSwitches []bool
+	topPerm := make([]int, top)
+	bottomPerm := make([]int, n-top)
+
+	for i := 0; i < top; i++ {
+		inSwitches = append(inSwitches, color[2*i] == 1)
+		outSwitches = append(outSwitches, color[inv[2*i]] == 1)
+	}
+	for e, p := range perm {
+		if color[e] == 0 {
+			topPerm[e/2] = p / 2
+		} else {
+			bottomPerm[e/2] = p / 2
+		}
+	}
+
+	result := inSwitches
+	result = append(result, routeBenes(topPerm)...)
+	result = append(result, routeBenes(bottomPerm)...)
+	return append(result, outSwitches...)
+}
@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+const (
+	shuffleElemBits = 8
+)
+
+func newShuffleCircuit(t *testing.T, n int) *circuit.Circuit {
+	switches := ShuffleSwitches(n)
+	arrBits := n * shuffleElemBits
+
+	inputs := makeWires(arrBits+switches, false)
+	outputs := makeWires(arrBits, true)
+
+	io := NewIO(arrBits, "arr")
+	io = append(io, NewIO(switches, "control")...)
+
+	cc, err := NewCompiler(params, calloc, io, NewIO(arrBits, "out"),
+		inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = NewObliviousShuffle(cc, shuffleElemBits, inputs[:arrBits],
+		inputs[arrBits:], outputs)
+	if err != nil {
+		t.Fatalf("NewObliviousShuffle: %s", err)
+	}
+	return cc.Compile()
+}
+
+func shuffle(t *testing.T, circ *circuit.Circuit, arr []int64,
+	control []bool) []int64 {
+
+	in := new(big.Int)
+	for i, v := range arr {
+		in.Or(in, new(big.Int).Lsh(big.NewInt(v), uint(i*shuffleElemBits)))
+	}
+	ctrl := new(big.Int)
+	for i, c := range control {
+		if c {
+			ctrl.SetBit(ctrl, i, 1)
+		}
+	}
+	results, err := circ.Compute([]*big.Int{in, ctrl})
+	if err != nil {
+		t.Fatalf("compute failed: %s", err)
+	}
+	mask := big.NewInt(1<<shuffleElemBits - 1)
+
+	result := make([]int64, len(arr))
+	for i := range result {
+		v := new(big.Int).Rsh(results[0], uint(i*shuffleElemBits))
+		result[i] = v.And(v, mask).Int64()
+	}
+	return result
+}
+
+func TestShuffleSwitches(t *testing.T) {
+	// Beneš networks of size 2^k have n*log2(n) - n/2 switches.
+	for k := 1; k <= 6; k++ {
+		n := 1 << k
+		expected := n*k - n/2
+		if got := ShuffleSwitches(n); got != expected {
+			t.Errorf("ShuffleSwitches(%d)=%d, expected %d", n, got, expected)
+		}
+	}
+}
+
+func TestShufflePermutation(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+
+	for n := 1; n <= 9; n++ {
+		circ := newShuffleCircuit(t, n)
+
+		arr := make([]int64, n)
+		for i := range arr {
+			arr[i] = int64(i*7 + 3)
+		}
+
+		perms := [][]int{
+			rnd.Perm(n),
+			rnd.Perm(n),
+			rnd.Perm(n),
+		}
+		identity := make([]int, n)
+		reverse := make([]int, n)
+		for i := 0; i < n; i++ {
+			identity[i] = i
+			reverse[i] = n - 1 - i
+		}
+		perms = append(perms, identity, reverse)
+
+		for _, perm := range perms {
+			control, err := ShuffleControl(perm)
+			if err != nil {
+				t.Fatalf("ShuffleControl(%v): %s", perm, err)
+			}
+			if len(control) != ShuffleSwitches(n) {
+				t.Fatalf("ShuffleControl(%v): got %d bits, expected %d",
+					perm, len(control), ShuffleSwitches(n))
+			}
+			result := shuffle(t, circ, arr, control)
+			for i, p := range perm {
+				if result[p] != arr[i] {
+					t.Errorf("n=%d, perm=%v: got %v", n, perm, result)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestShuffleControl(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+
+	for n := 2; n <= 9; n++ {
+		circ := newShuffleCircuit(t, n)
+
+		arr := make([]int64, n)
+		for i := range arr {
+			arr[i] = int64(i + 1)
+		}
+
+		// All control settings produce a permutation of the input.
+		for i := 0; i < 10; i++ {
+			control := make([]bool, ShuffleSwitches(n))
+			for j := range control {
+				control[j] = rnd.Intn(2) == 1
+			}
+			result := shuffle(t, circ, arr, control)
+			seen := make(map[int64]bool)
+			for _, v := range result {
+				if v < 1 || v > int64(n) || seen[v] {
+					t.Fatalf("n=%d, control=%v: invalid result %v",
+						n, control, result)
+				}
+				seen[v] = true
+			}
+		}
+	}
+}
+
+func TestShuffleControlInvalid(t *testing.T) {
+	for _, perm := range [][]int{{0, 0}, {1, 2}, {-1, 0}} {
+		_, err := ShuffleControl(perm)
+		if err == nil {
+			t.Errorf("ShuffleControl(%v) succeeded", perm)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"github.com/markkurossi/mpc/types"
+)
+
+// NewISqrt creates an integer square root circuit computing
+// out=floor(sqrt(x)). The argument x is interpreted as an unsigned
+// integer. This function uses the digit-by-digit algorithm which
+// resolves one bit of the root for each pair of input bits.
+func NewISqrt(cc *Compiler, x, out []*Wire) error {
+	if len(x)%2 != 0 {
+		x = append(x[:len(x):len(x)], cc.ZeroWire())
+	}
+	m := len(x) / 2
+
+	// The remainder is at most 2*root so it fits into m+2 bits.
+	rem := make([]*Wire, m+2)
+	for i := 0; i < len(rem); i++ {
+		rem[i] = cc.ZeroWire()
+	}
+	// The root bits, LSB first.
+	var root []*Wire
+
+	for i := m - 1; i >= 0; i-- {
+		// rem = rem<<2 | x[2i+1:2i]
+		for j := len(rem) - 1; j > 1; j-- {
+			rem[j] = rem[j-2]
+		}
+		rem[1] = x[2*i+1]
+		rem[0] = x[2*i]
+
+		// trial = root<<2 | 1
+		trial := make([]*Wire, len(rem))
+		for j := 0; j < len(trial); j++ {
+			switch {
+			case j == 0:
+				trial[j] = cc.OneWire()
+			case j >= 2 && j-2 < len(root):
+				trial[j] = root[j-2]
+			default:
+				trial[j] = cc.ZeroWire()
+			}
+		}
+
+		// rem-trial, overflow: rem < trial
+		diff := cc.Calloc.Wires(types.Size(len(rem) + 1))
+		err := NewSubtractor(cc, rem, trial, diff)
+		if err != nil {
+			return err
+		}
+		borrow := diff[len(diff)-1:]
+
+		bit := cc.Calloc.Wire()
+		cc.INV(borrow[0], bit)
+		root = append([]*Wire{bit}, root...)
+
+		if i == 0 {
+			break
+		}
+		nr := cc.Calloc.Wires(types.Size(len(rem)))
+		err = NewMUX(cc, borrow, rem, diff[:len(diff)-1], nr)
+		if err != nil {
+			return err
+		}
+		rem = nr
+	}
+	for i := 0; i < len(out); i++ {
+		if i < len(root) {
+			cc.ID(root[i], out[i])
+		} else {
+			out[i] = cc.ZeroWire()
+		}
+	}
+
+	return nil
+}
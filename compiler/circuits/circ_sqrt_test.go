@@ -0,0 +1,45 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestISqrt(t *testing.T) {
+	for _, bits := range []int{1, 2, 7, 12} {
+		outBits := (bits + 1) / 2
+
+		inputs := makeWires(bits, false)
+		outputs := makeWires(outBits, true)
+
+		cc, err := NewCompiler(params, calloc, NewIO(bits, "x"),
+			NewIO(outBits, "out"), inputs, outputs)
+		if err != nil {
+			t.Fatalf("NewCompiler: %s", err)
+		}
+		err = NewISqrt(cc, inputs, outputs)
+		if err != nil {
+			t.Fatalf("NewISqrt: %s", err)
+		}
+		circ := cc.Compile()
+
+		for x := int64(0); x < 1<<bits; x++ {
+			results, err := circ.Compute([]*big.Int{big.NewInt(x)})
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			expected := int64(math.Sqrt(float64(x)))
+			if results[0].Int64() != expected {
+				t.Errorf("%d-bit isqrt(%d)=%v, expected %v",
+					bits, x, results[0], expected)
+			}
+		}
+	}
+}
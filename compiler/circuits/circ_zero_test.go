@@ -0,0 +1,106 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+func newEqCircuit(t *testing.T, bits int) *circuit.Circuit {
+	x := makeWires(bits, false)
+	y := makeWires(bits, false)
+	r := makeWires(1, true)
+
+	cc, err := NewCompiler(params, calloc,
+		append(NewIO(bits, "x"), NewIO(bits, "y")...), NewIO(1, "r"),
+		append(x, y...), r)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = NewEqComparator(cc, x, y, r)
+	if err != nil {
+		t.Fatalf("NewEqComparator: %s", err)
+	}
+	circ := cc.Compile()
+	circ.AssignLevels()
+	return circ
+}
+
+func newIsZeroCircuit(t *testing.T, bits int, nonZero bool) *circuit.Circuit {
+	x := makeWires(bits, false)
+	r := makeWires(1, true)
+
+	cc, err := NewCompiler(params, calloc, NewIO(bits, "x"), NewIO(1, "r"),
+		x, r)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	if nonZero {
+		err = NewIsNonZero(cc, x, r)
+	} else {
+		err = NewIsZero(cc, x, r)
+	}
+	if err != nil {
+		t.Fatalf("NewIsZero: %s", err)
+	}
+	circ := cc.Compile()
+	circ.AssignLevels()
+	return circ
+}
+
+func TestIsZero(t *testing.T) {
+	for _, bits := range []int{1, 2, 3, 8, 13} {
+		eq := newEqCircuit(t, bits)
+		isZero := newIsZeroCircuit(t, bits, false)
+		nonZero := newIsZeroCircuit(t, bits, true)
+
+		zero := big.NewInt(0)
+		for x := int64(0); x < 1<<bits; x++ {
+			bx := big.NewInt(x)
+			expected, err := eq.Compute([]*big.Int{bx, zero})
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			result, err := isZero.Compute([]*big.Int{bx})
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if result[0].Cmp(expected[0]) != 0 {
+				t.Errorf("%d-bit IsZero(%d)=%v, expected %v",
+					bits, x, result[0], expected[0])
+			}
+			result, err = nonZero.Compute([]*big.Int{bx})
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if result[0].Uint64() == expected[0].Uint64() {
+				t.Errorf("%d-bit IsNonZero(%d)=%v, expected %v",
+					bits, x, result[0], 1-expected[0].Uint64())
+			}
+		}
+
+		zeroCost := isZero.Stats[circuit.AND] + isZero.Stats[circuit.OR]
+		eqCost := eq.Stats[circuit.AND] + eq.Stats[circuit.OR]
+		if zeroCost > eqCost {
+			t.Errorf("%d-bit IsZero: %d AND/OR gates, comparator %d",
+				bits, zeroCost, eqCost)
+		}
+		if isZero.NumGates >= eq.NumGates {
+			t.Errorf("%d-bit IsZero: %d gates, comparator %d gates",
+				bits, isZero.NumGates, eq.NumGates)
+		}
+		if bits >= 3 &&
+			isZero.Stats[circuit.NumLevels] >= eq.Stats[circuit.NumLevels] {
+			t.Errorf("%d-bit IsZero: %d levels, comparator %d levels",
+				bits, isZero.Stats[circuit.NumLevels],
+				eq.Stats[circuit.NumLevels])
+		}
+	}
+}
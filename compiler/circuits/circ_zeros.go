@@ -0,0 +1,92 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"github.com/markkurossi/mpc/circuit"
+)
+
+// NewLeadingZeros creates a circuit computing the number of leading
+// zero bits in x. The result is len(x) for x=0.
+func NewLeadingZeros(cc *Compiler, x, r []*Wire) error {
+	rev := make([]*Wire, len(x))
+	for i := 0; i < len(x); i++ {
+		rev[i] = x[len(x)-1-i]
+	}
+	return NewTrailingZeros(cc, rev, r)
+}
+
+// NewTrailingZeros creates a circuit computing the number of trailing
+// zero bits in x. The result is len(x) for x=0. The circuit is a
+// priority encoder: it selects the lowest set bit of x and encodes
+// its index into r.
+func NewTrailingZeros(cc *Compiler, x, r []*Wire) error {
+	// The index bits are XORed together from the one-hot lowest set
+	// bit selectors.
+	out := make([][]*Wire, len(r))
+
+	// seen is set if any of the bits x[0]...x[i-1] are set.
+	var seen *Wire
+	for i := 0; i < len(x); i++ {
+		var first *Wire
+		if seen == nil {
+			first = x[i]
+			seen = x[i]
+		} else {
+			notSeen := cc.Calloc.Wire()
+			cc.INV(seen, notSeen)
+			first = cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.AND, x[i], notSeen,
+				first))
+
+			s := cc.Calloc.Wire()
+			cc.AddGate(cc.Calloc.BinaryGate(circuit.OR, seen, x[i], s))
+			seen = s
+		}
+		for bit := 0; bit < len(r); bit++ {
+			if i&(1<<bit) != 0 {
+				out[bit] = append(out[bit], first)
+			}
+		}
+	}
+
+	// All bits zero: the result is len(x).
+	if len(x) > 0 {
+		zero := cc.Calloc.Wire()
+		cc.INV(seen, zero)
+		for bit := 0; bit < len(r); bit++ {
+			if len(x)&(1<<bit) != 0 {
+				out[bit] = append(out[bit], zero)
+			}
+		}
+	}
+
+	for bit := 0; bit < len(r); bit++ {
+		switch len(out[bit]) {
+		case 0:
+			cc.ID(cc.ZeroWire(), r[bit])
+
+		case 1:
+			cc.ID(out[bit][0], r[bit])
+
+		default:
+			w := out[bit][0]
+			for i := 1; i < len(out[bit]); i++ {
+				var o *Wire
+				if i+1 < len(out[bit]) {
+					o = cc.Calloc.Wire()
+				} else {
+					o = r[bit]
+				}
+				cc.AddGate(cc.Calloc.BinaryGate(circuit.XOR, w, out[bit][i],
+					o))
+				w = o
+			}
+		}
+	}
+	return nil
+}
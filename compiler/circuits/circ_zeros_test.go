@@ -0,0 +1,102 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package circuits
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+func newZerosCircuit(t *testing.T, bits, outBits int,
+	f func(cc *Compiler, x, r []*Wire) error) *circuit.Circuit {
+
+	inputs := makeWires(bits, false)
+	outputs := makeWires(outBits, true)
+
+	cc, err := NewCompiler(params, calloc, NewIO(bits, "x"),
+		NewIO(outBits, "out"), inputs, outputs)
+	if err != nil {
+		t.Fatalf("NewCompiler: %s", err)
+	}
+	err = f(cc, inputs, outputs)
+	if err != nil {
+		t.Fatalf("circuit failed: %s", err)
+	}
+	return cc.Compile()
+}
+
+func TestLeadingTrailingZeros32(t *testing.T) {
+	lz := newZerosCircuit(t, 32, 6, NewLeadingZeros)
+	tz := newZerosCircuit(t, 32, 6, NewTrailingZeros)
+
+	values := []uint32{0, math.MaxUint32, 1, 0x80000000, 0x00010000}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		// Shift random values to get a range of zero counts.
+		values = append(values, rnd.Uint32()>>(i%32)<<(i%7))
+	}
+	for _, x := range values {
+		in := []*big.Int{big.NewInt(int64(x))}
+
+		results, err := lz.Compute(in)
+		if err != nil {
+			t.Fatalf("compute failed: %s", err)
+		}
+		if results[0].Int64() != int64(bits.LeadingZeros32(x)) {
+			t.Errorf("LeadingZeros32(%08x)=%v, expected %v",
+				x, results[0], bits.LeadingZeros32(x))
+		}
+		results, err = tz.Compute(in)
+		if err != nil {
+			t.Fatalf("compute failed: %s", err)
+		}
+		if results[0].Int64() != int64(bits.TrailingZeros32(x)) {
+			t.Errorf("TrailingZeros32(%08x)=%v, expected %v",
+				x, results[0], bits.TrailingZeros32(x))
+		}
+	}
+}
+
+func TestLeadingTrailingZerosWidths(t *testing.T) {
+	for _, width := range []int{1, 2, 3, 5, 8} {
+		outBits := bits.Len(uint(width))
+		lz := newZerosCircuit(t, width, outBits, NewLeadingZeros)
+		tz := newZerosCircuit(t, width, outBits, NewTrailingZeros)
+
+		for x := uint64(0); x < 1<<width; x++ {
+			in := []*big.Int{new(big.Int).SetUint64(x)}
+
+			expected := int64(bits.LeadingZeros64(x) - (64 - width))
+			results, err := lz.Compute(in)
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if results[0].Int64() != expected {
+				t.Errorf("%d-bit LeadingZeros(%x)=%v, expected %v",
+					width, x, results[0], expected)
+			}
+
+			expected = int64(bits.TrailingZeros64(x))
+			if x == 0 {
+				expected = int64(width)
+			}
+			results, err = tz.Compute(in)
+			if err != nil {
+				t.Fatalf("compute failed: %s", err)
+			}
+			if results[0].Int64() != expected {
+				t.Errorf("%d-bit TrailingZeros(%x)=%v, expected %v",
+					width, x, results[0], expected)
+			}
+		}
+	}
+}
@@ -138,6 +138,16 @@ func (cc *Compiler) ShiftLeft(w []*Wire, size, count int) []*Wire {
 	return result
 }
 
+// isZero tests if all wires of w hold the constant value 0.
+func (cc *Compiler) isZero(w []*Wire) bool {
+	for _, wire := range w {
+		if wire.Value() != Zero {
+			return false
+		}
+	}
+	return true
+}
+
 // INV creates an inverse wire inverting the input wire i's value to
 // the output wire o.
 func (cc *Compiler) INV(i, o *Wire) {
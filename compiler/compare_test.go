@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var compareOps = []string{"<", "<=", ">", ">=", "==", "!="}
+
+func compareExpected(op string, a, b *big.Int) bool {
+	c := a.Cmp(b)
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	default:
+		panic(op)
+	}
+}
+
+// compareValues returns the boundary values for the integer type.
+func compareValues(signed bool, bits int) []*big.Int {
+	one := big.NewInt(1)
+	if signed {
+		max := new(big.Int).Lsh(one, uint(bits-1))
+		min := new(big.Int).Neg(max)
+		max.Sub(max, one)
+		return []*big.Int{
+			min, new(big.Int).Add(min, one), big.NewInt(-1), big.NewInt(0),
+			one, max,
+		}
+	}
+	max := new(big.Int).Lsh(one, uint(bits))
+	max.Sub(max, one)
+	half := new(big.Int).Lsh(one, uint(bits-1))
+	return []*big.Int{
+		big.NewInt(0), one, new(big.Int).Sub(half, one), half, max,
+	}
+}
+
+func TestCompareMatrix(t *testing.T) {
+	for _, signed := range []bool{true, false} {
+		for _, bits := range []int{8, 32, 64} {
+			typ := fmt.Sprintf("uint%d", bits)
+			if signed {
+				typ = typ[1:]
+			}
+			testCompareType(t, typ, signed, bits)
+		}
+	}
+}
+
+func testCompareType(t *testing.T, typ string, signed bool, bits int) {
+	values := compareValues(signed, bits)
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+
+	// Circuit evaluation.
+	code := fmt.Sprintf(`
+package main
+func main(a, b %s) (bool, bool, bool, bool, bool, bool) {
+    return a < b, a <= b, a > b, a >= b, a == b, a != b
+}
+`, typ)
+	circ, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("%s: compile failed: %v", typ, err)
+	}
+
+	// Constant folding.
+	var exprs []string
+	for _, a := range values {
+		for _, b := range values {
+			for _, op := range compareOps {
+				exprs = append(exprs,
+					fmt.Sprintf("%s(%v) %s %s(%v)", typ, a, op, typ, b))
+			}
+		}
+	}
+	code = fmt.Sprintf(`
+package main
+func main(z bool) (%s) {
+    return %s
+}
+`, strings.Repeat("bool, ", len(exprs)-1)+"bool", strings.Join(exprs, ", "))
+	folded, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("%s: constant compile failed: %v", typ, err)
+	}
+	foldedResults, err := folded.Compute([]*big.Int{big.NewInt(0)})
+	if err != nil {
+		t.Fatalf("%s: constant compute failed: %v", typ, err)
+	}
+
+	var idx int
+	for _, a := range values {
+		for _, b := range values {
+			ua := new(big.Int).Mod(a, mod)
+			ub := new(big.Int).Mod(b, mod)
+			results, err := circ.Compute([]*big.Int{ua, ub})
+			if err != nil {
+				t.Fatalf("%s: compute failed: %v", typ, err)
+			}
+			for i, op := range compareOps {
+				expected := compareExpected(op, a, b)
+				if (results[i].Int64() != 0) != expected {
+					t.Errorf("%s: circuit %v %s %v: got %v, expected %v",
+						typ, a, op, b, results[i], expected)
+				}
+				if (foldedResults[idx].Int64() != 0) != expected {
+					t.Errorf("%s: constant %v %s %v: got %v, expected %v",
+						typ, a, op, b, foldedResults[idx], expected)
+				}
+				idx++
+			}
+		}
+	}
+}
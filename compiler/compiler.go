@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -7,25 +7,79 @@
 package compiler
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/big"
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/markkurossi/mpc/circuit"
 	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/ssa"
 	"github.com/markkurossi/mpc/compiler/utils"
 	"github.com/markkurossi/mpc/ot"
 	"github.com/markkurossi/mpc/p2p"
 )
 
-// Compiler implements MPCL compiler.
+// Compiler implements MPCL compiler. The compiler caches the parsed
+// source files so compiling the same file again, for example, with
+// different input sizes, only repeats the size-dependent code
+// generation and circuit compilation. The maxSSAPrograms most recently
+// generated SSA programs of each file are cached by input sizes and
+// code generation parameters so compiling the same file again with
+// the same input sizes only repeats the circuit compilation.
 type Compiler struct {
 	params   *utils.Params
 	packages map[string]*ast.Package
+	files    map[string]*parsedFile
 	pkgPath  string
+
+	// Stats contains the compiler statistics.
+	Stats Stats
+}
+
+// Stats contains compiler statistics.
+type Stats struct {
+	// Parsed is the number of parsed source files, including the
+	// files of the imported packages.
+	Parsed int
+	// Reused is the number of compilations that used a cached parsed
+	// program.
+	Reused int
+	// ReusedSSA is the number of compilations that used a cached SSA
+	// program.
+	ReusedSSA int
+	// EvalCacheHits is the number of constant folding results that
+	// were served from the constant folding cache.
+	EvalCacheHits int
+	// Instructions contains the SSA instruction counts of the
+	// latest compilation by function names.
+	Instructions ast.InstrStats
+}
+
+type parsedFile struct {
+	pkg      *ast.Package
+	modTime  time.Time
+	size     int64
+	programs []*ssaProgram
+}
+
+// maxSSAPrograms is the maximum number of cached SSA programs per
+// source file. The evaluator compiles the programs for the input
+// sizes of its peers so the cache must be bounded.
+const maxSSAPrograms = 8
+
+// ssaProgram is an SSA program cached by its input sizes and code
+// generation parameters.
+type ssaProgram struct {
+	key        string
+	program    *ssa.Program
+	annotation ast.Annotations
+	instrStats ast.InstrStats
 }
 
 type pkgPath struct {
@@ -39,25 +93,142 @@ func New(params *utils.Params) *Compiler {
 	return &Compiler{
 		params:   params,
 		packages: make(map[string]*ast.Package),
+		files:    make(map[string]*parsedFile),
 	}
 }
 
 // Compile compiles the input program.
 func (c *Compiler) Compile(data string, inputSizes [][]int) (
 	*circuit.Circuit, ast.Annotations, error) {
-	return c.compile("{data}", strings.NewReader(data), inputSizes)
+	return c.CompileContext(context.Background(), data, inputSizes)
+}
+
+// CompileContext compiles the input program. The compilation checks
+// ctx between the SSA generation and circuit compilation steps and
+// returns ctx.Err() if the context is canceled or its deadline
+// expires.
+func (c *Compiler) CompileContext(ctx context.Context, data string,
+	inputSizes [][]int) (*circuit.Circuit, ast.Annotations, error) {
+	return c.compile(ctx, "{data}", strings.NewReader(data), inputSizes)
 }
 
-// CompileFile compiles the input file.
+// CompileFile compiles the input file. If the file is unmodified
+// since its previous compilation, the compiler reuses the parsed
+// program, and if the input sizes are also unchanged, the compiler
+// reuses the generated SSA program.
 func (c *Compiler) CompileFile(file string, inputSizes [][]int) (
 	*circuit.Circuit, ast.Annotations, error) {
 
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	cached, ok := c.files[file]
+	if ok && cached.modTime.Equal(fi.ModTime()) && cached.size == fi.Size() {
+		c.Stats.Reused++
+		c.packages[cached.pkg.Name] = cached.pkg
+		return c.compileFile(cached, inputSizes)
+	}
+
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer f.Close()
-	return c.compile(file, f, inputSizes)
+
+	pkg, err := c.parse(file, f, c.logger(), ast.NewPackage("main", file, nil))
+	if err != nil {
+		return nil, nil, err
+	}
+	cached = &parsedFile{
+		pkg:     pkg,
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+	}
+	c.files[file] = cached
+	return c.compileFile(cached, inputSizes)
+}
+
+// compileFile compiles the parsed file. The function caches the SSA
+// programs of the file by input sizes and code generation parameters,
+// evicting the least recently used program when the cache is full.
+// The cached programs are not compiled into circuits since the circuit
+// compilation binds wires to the program values; each compilation uses
+// a clone of the cached program.
+func (c *Compiler) compileFile(file *parsedFile, inputSizes [][]int) (
+	*circuit.Circuit, ast.Annotations, error) {
+
+	key := ssaKey(c.params, inputSizes)
+	cached := file.lookup(key)
+	if cached != nil {
+		c.Stats.ReusedSSA++
+		c.Stats.Instructions = cached.instrStats
+	} else {
+		program, annotation, err := c.generateSSA(context.Background(),
+			file.pkg, inputSizes)
+		if err != nil {
+			return nil, nil, err
+		}
+		cached = &ssaProgram{
+			key:        key,
+			program:    program,
+			annotation: annotation,
+			instrStats: c.Stats.Instructions,
+		}
+		file.add(cached)
+	}
+	program, err := cached.program.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.params.OutputIndices != nil {
+		if err := program.EvaluateOutputs(c.params.OutputIndices); err != nil {
+			return nil, nil, err
+		}
+	}
+	return c.compileProgram(context.Background(), program, cached.annotation)
+}
+
+// lookup returns the cached SSA program for the key and marks it as
+// the most recently used program. The function returns nil if the
+// program is not cached.
+func (file *parsedFile) lookup(key string) *ssaProgram {
+	for i, p := range file.programs {
+		if p.key == key {
+			copy(file.programs[1:], file.programs[:i])
+			file.programs[0] = p
+			return p
+		}
+	}
+	return nil
+}
+
+// add adds the SSA program to the cache as the most recently used
+// program.
+func (file *parsedFile) add(p *ssaProgram) {
+	if len(file.programs) < maxSSAPrograms {
+		file.programs = append(file.programs, nil)
+	}
+	copy(file.programs[1:], file.programs)
+	file.programs[0] = p
+}
+
+// ssaKey returns the SSA program cache key for the input sizes. The
+// key contains a snapshot of all parameters except the outputs and
+// the diagnostics that do not affect the generated code.
+func ssaKey(params *utils.Params, inputSizes [][]int) string {
+	snapshot := *params
+	snapshot.Verbose = false
+	snapshot.Diagnostics = false
+	snapshot.SSAOut = nil
+	snapshot.SSADotOut = nil
+	snapshot.SSACFGOut = nil
+	snapshot.LogOut = nil
+	snapshot.CircOut = nil
+	snapshot.CircDotOut = nil
+	snapshot.CircSvgOut = nil
+	snapshot.BenchmarkCompile = false
+	return fmt.Sprintf("%v %+v", inputSizes, snapshot)
 }
 
 // ParseFile parses the input file.
@@ -67,66 +238,207 @@ func (c *Compiler) ParseFile(file string) (*ast.Package, error) {
 		return nil, err
 	}
 	defer f.Close()
-	logger := utils.NewLogger(os.Stdout)
-	return c.parse(file, f, logger, nil)
+	return c.parse(file, f, c.logger(), nil)
 }
 
-func (c *Compiler) compile(source string, in io.Reader, inputSizes [][]int) (
-	*circuit.Circuit, ast.Annotations, error) {
+func (c *Compiler) compile(cctx context.Context, source string, in io.Reader,
+	inputSizes [][]int) (*circuit.Circuit, ast.Annotations, error) {
 
-	logger := utils.NewLogger(os.Stdout)
-	pkg, err := c.parse(source, in, logger, ast.NewPackage("main", source, nil))
+	pkg, err := c.parse(source, in, c.logger(),
+		ast.NewPackage("main", source, nil))
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.compilePkg(cctx, pkg, inputSizes)
+}
 
-	ctx := ast.NewCodegen(logger, pkg, c.packages, c.params, inputSizes)
+func (c *Compiler) compilePkg(cctx context.Context, pkg *ast.Package,
+	inputSizes [][]int) (*circuit.Circuit, ast.Annotations, error) {
 
-	program, annotation, err := pkg.Compile(ctx)
+	program, annotation, err := c.compileSSA(cctx, pkg, inputSizes)
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.compileProgram(cctx, program, annotation)
+}
+
+func (c *Compiler) compileProgram(cctx context.Context, program *ssa.Program,
+	annotation ast.Annotations) (*circuit.Circuit, ast.Annotations, error) {
+
 	if c.params.NoCircCompile {
 		return nil, annotation, nil
 	}
-	circ, err := program.CompileCircuit(c.params)
+	circ, err := program.CompileCircuitContext(cctx, c.params)
 	if err != nil {
 		return nil, nil, err
 	}
 	return circ, annotation, nil
 }
 
+func (c *Compiler) compileSSA(cctx context.Context, pkg *ast.Package,
+	inputSizes [][]int) (*ssa.Program, ast.Annotations, error) {
+
+	program, annotation, err := c.generateSSA(cctx, pkg, inputSizes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.params.OutputIndices != nil {
+		if err := program.EvaluateOutputs(c.params.OutputIndices); err != nil {
+			return nil, nil, err
+		}
+	}
+	return program, annotation, nil
+}
+
+// generateSSA generates the SSA program for the input sizes. The
+// program returns all outputs of the main function.
+func (c *Compiler) generateSSA(cctx context.Context, pkg *ast.Package,
+	inputSizes [][]int) (*ssa.Program, ast.Annotations, error) {
+
+	c.reset()
+	ctx, err := c.newCodegen(c.logger(), pkg, inputSizes)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx.Context = cctx
+
+	program, annotation, err := pkg.Compile(ctx)
+	c.Stats.EvalCacheHits += ctx.EvalStats.Hits
+	c.instrStats(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return program, annotation, nil
+}
+
+func (c *Compiler) instrStats(ctx *ast.Codegen) {
+	c.Stats.Instructions = ctx.InstrStats
+	if c.params.Verbose {
+		fmt.Printf("SSA instructions:\n")
+		ctx.InstrStats.Print(os.Stdout)
+	}
+}
+
+// CompileToSSA compiles the input program into an SSA program. The
+// program can be inspected or transformed before it is compiled into
+// a circuit with CompileSSAToCircuit.
+func (c *Compiler) CompileToSSA(data string, inputSizes [][]int) (
+	*ssa.Program, error) {
+
+	pkg, err := c.parse("{data}", strings.NewReader(data), c.logger(),
+		ast.NewPackage("main", "{data}", nil))
+	if err != nil {
+		return nil, err
+	}
+	program, _, err := c.compileSSA(context.Background(), pkg, inputSizes)
+	return program, err
+}
+
+// Check type-checks the input program without compiling it into an
+// SSA program or a circuit. The check parses the program and runs the
+// front end through the type resolution of the main function and the
+// functions it calls. The function returns the errors and warnings of
+// the check with their source locations; a valid program has no
+// error diagnostics. The arguments of the main function must have
+// concrete types or widths specified with Params.InputWidths.
+func (c *Compiler) Check(data string) []utils.Diagnostic {
+	logger := c.logger()
+
+	pkg, err := c.parse("{data}", strings.NewReader(data), logger,
+		ast.NewPackage("main", "{data}", nil))
+	if err == nil {
+		c.reset()
+		var ctx *ast.Codegen
+		ctx, err = c.newCodegen(logger, pkg, nil)
+		if err == nil {
+			err = pkg.Check(ctx)
+		}
+	}
+	diagnostics := logger.Diagnostics()
+	if err == nil {
+		return diagnostics
+	}
+	for _, d := range diagnostics {
+		if d.Severity == utils.SeverityError {
+			return diagnostics
+		}
+	}
+	// The error was not logged with a location.
+	return append(diagnostics, utils.Diagnostic{
+		Point: utils.Point{
+			Source: "{data}",
+		},
+		Severity: utils.SeverityError,
+		Message:  err.Error(),
+	})
+}
+
+// CompileSSAToCircuit compiles the SSA program into a boolean
+// circuit. The program must be compiled with CompileToSSA by the same
+// compiler, and it can be compiled into a circuit only once.
+func (c *Compiler) CompileSSAToCircuit(prog *ssa.Program) (
+	*circuit.Circuit, error) {
+	return prog.CompileCircuit(c.params)
+}
+
 // StreamFile compiles the input program and uses the streaming mode
 // to garble and stream the circuit to the evaluator node.
 func (c *Compiler) StreamFile(conn *p2p.Conn, oti ot.OT, file string,
 	input []string, inputSizes [][]int) (circuit.IO, []*big.Int, error) {
+	return c.StreamFileContext(context.Background(), conn, oti, file, input,
+		inputSizes)
+}
+
+// StreamFileContext compiles the input program and uses the
+// streaming mode to garble and stream the circuit to the evaluator
+// node. If ctx is canceled, the compilation or streaming is aborted
+// with the context's error and the evaluator is notified about the
+// abort.
+func (c *Compiler) StreamFileContext(ctx context.Context, conn *p2p.Conn,
+	oti ot.OT, file string, input []string, inputSizes [][]int) (
+	circuit.IO, []*big.Int, error) {
 
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer f.Close()
-	return c.stream(conn, oti, file, f, input, inputSizes)
+	return c.stream(ctx, conn, oti, file, f, input, inputSizes)
 }
 
-func (c *Compiler) stream(conn *p2p.Conn, oti ot.OT, source string,
-	in io.Reader, inputFlag []string, inputSizes [][]int) (
+func (c *Compiler) stream(cctx context.Context, conn *p2p.Conn, oti ot.OT,
+	source string, in io.Reader, inputFlag []string, inputSizes [][]int) (
 	circuit.IO, []*big.Int, error) {
 
 	timing := circuit.NewTiming()
 
-	logger := utils.NewLogger(os.Stdout)
+	logger := c.logger()
 	pkg, err := c.parse(source, in, logger, ast.NewPackage("main", source, nil))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	ctx := ast.NewCodegen(logger, pkg, c.packages, c.params, inputSizes)
+	c.reset()
+	ctx, err := c.newCodegen(logger, pkg, inputSizes)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx.Context = cctx
 
 	program, _, err := pkg.Compile(ctx)
+	c.Stats.EvalCacheHits += ctx.EvalStats.Hits
+	c.instrStats(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
+	if c.params.OutputIndices != nil {
+		if err := program.EvaluateOutputs(c.params.OutputIndices); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := program.SetOutputModes(c.params.OutputModes); err != nil {
+		return nil, nil, err
+	}
 
 	timing.Sample("Compile", nil)
 
@@ -154,7 +466,8 @@ func (c *Compiler) stream(conn *p2p.Conn, oti ot.OT, source string,
 	fmt.Printf(" - Out: %s\n", program.Outputs)
 	fmt.Printf(" -  In: %s\n", inputFlag)
 
-	out, bits, err := program.Stream(conn, oti, c.params, input, timing)
+	out, bits, err := program.StreamContext(cctx, conn, oti, c.params, input,
+		timing)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -164,9 +477,40 @@ func (c *Compiler) stream(conn *p2p.Conn, oti ot.OT, source string,
 	return out, bits, err
 }
 
+// newCodegen creates a code generator for the package pkg with the
+// compile-time constants defined in the compiler params.
+func (c *Compiler) newCodegen(logger *utils.Logger, pkg *ast.Package,
+	inputSizes [][]int) (*ast.Codegen, error) {
+
+	defines, err := parseDefines(c.params.Defines)
+	if err != nil {
+		return nil, err
+	}
+	ctx := ast.NewCodegen(logger, pkg, c.packages, c.params, inputSizes)
+	ctx.Defines = defines
+
+	return ctx, nil
+}
+
+// reset clears the parsed packages' state from any earlier
+// compilation.
+func (c *Compiler) reset() {
+	for _, pkg := range c.packages {
+		pkg.Reset()
+	}
+}
+
+func (c *Compiler) logger() *utils.Logger {
+	if c.params.LogOut != nil {
+		return utils.NewLogger(c.params.LogOut)
+	}
+	return utils.NewLogger(os.Stdout)
+}
+
 func (c *Compiler) parse(source string, in io.Reader, logger *utils.Logger,
 	pkg *ast.Package) (*ast.Package, error) {
 
+	c.Stats.Parsed++
 	parser := NewParser(source, c, logger, in)
 	pkg, err := parser.Parse(pkg)
 	if err != nil {
@@ -214,6 +558,9 @@ func (c *Compiler) resolvePkgPath() error {
 		break
 	}
 	if len(c.pkgPath) == 0 {
+		if len(c.params.PkgPath) > 0 {
+			return fmt.Errorf("could not find pkg root directory")
+		}
 		fmt.Printf("could not resolve pkg root directory, tried:\n")
 		for _, pkgPath := range pkgPaths {
 			if len(pkgPath.precond) > 0 {
@@ -255,21 +602,32 @@ func (c *Compiler) parsePkg(alias, name, source string) (*ast.Package, error) {
 	}
 	pkg = ast.NewPackage(alias, source, nil)
 
-	err := c.resolvePkgPath()
-	if err != nil {
-		return nil, err
+	// The packages in params.PkgPath are found even if the standard
+	// package root can't be resolved.
+	var roots []pkgRoot
+	if c.params.PkgFS != nil {
+		roots = append(roots, pkgRoot{
+			fsys:   c.params.PkgFS,
+			prefix: utils.PkgFSSource,
+		})
+	} else {
+		err := c.resolvePkgPath()
+		if err == nil {
+			roots = append(roots, newPkgRoot(c.pkgPath))
+		} else if len(c.params.PkgPath) == 0 {
+			return nil, err
+		}
+	}
+	for _, dir := range c.params.PkgPath {
+		roots = append(roots, newPkgRoot(dir))
 	}
 
 	if c.params.Verbose {
 		fmt.Printf("looking for package %s (%s)\n", alias, name)
 	}
 
-	var dirs []string
-	dirs = append(dirs, c.pkgPath)
-	dirs = append(dirs, c.params.PkgPath...)
-
-	for _, dir := range dirs {
-		pkg, ok, err := c.tryParsePkg(pkg, dir, name)
+	for _, root := range roots {
+		pkg, ok, err := c.tryParsePkg(pkg, root, name)
 		if err != nil {
 			return nil, err
 		}
@@ -280,24 +638,31 @@ func (c *Compiler) parsePkg(alias, name, source string) (*ast.Package, error) {
 	return nil, fmt.Errorf("package %s not found", name)
 }
 
-func (c *Compiler) tryParsePkg(pkg *ast.Package, prefix, name string) (
-	*ast.Package, bool, error) {
+// pkgRoot defines a package root. The prefix is the source name
+// prefix of the files in the root.
+type pkgRoot struct {
+	fsys   fs.FS
+	prefix string
+}
 
-	dir := path.Join(prefix, name)
-	df, err := os.Open(dir)
-	if err != nil {
-		return nil, false, nil
+func newPkgRoot(dir string) pkgRoot {
+	return pkgRoot{
+		fsys:   os.DirFS(dir),
+		prefix: dir,
 	}
-	defer df.Close()
+}
+
+func (c *Compiler) tryParsePkg(pkg *ast.Package, root pkgRoot, name string) (
+	*ast.Package, bool, error) {
 
-	files, err := df.Readdirnames(-1)
+	entries, err := fs.ReadDir(root.fsys, name)
 	if err != nil {
-		return nil, false, fmt.Errorf("package %s not found: %s", name, err)
+		return nil, false, nil
 	}
 	var mpcls []string
-	for _, f := range files {
-		if strings.HasSuffix(f, ".mpcl") {
-			mpcls = append(mpcls, f)
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".mpcl") {
+			mpcls = append(mpcls, e.Name())
 		}
 	}
 	if len(mpcls) == 0 {
@@ -305,13 +670,13 @@ func (c *Compiler) tryParsePkg(pkg *ast.Package, prefix, name string) (
 	}
 
 	for _, mpcl := range mpcls {
-		fp := path.Join(dir, mpcl)
+		fp := path.Join(root.prefix, name, mpcl)
 
 		if c.params.Verbose {
-			fmt.Printf(" - parsing @%v\n", fp[len(c.pkgPath):])
+			fmt.Printf(" - parsing @%v\n", fp[len(root.prefix):])
 		}
 
-		f, err := os.Open(fp)
+		f, err := root.fsys.Open(path.Join(name, mpcl))
 		if err != nil {
 			fmt.Printf("pkg not found: %s\n", err)
 			return nil, false, fmt.Errorf("error reading package %s: %s",
@@ -319,7 +684,7 @@ func (c *Compiler) tryParsePkg(pkg *ast.Package, prefix, name string) (
 		}
 		defer f.Close()
 
-		pkg, err = c.parse(fp, f, utils.NewLogger(os.Stdout), pkg)
+		pkg, err = c.parse(fp, f, c.logger(), pkg)
 		if err != nil {
 			return nil, false, err
 		}
@@ -0,0 +1,171 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func compileCircuit(t *testing.T, code string) *circuit.Circuit {
+	circ, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+	return circ
+}
+
+func TestCompose(t *testing.T) {
+	adder := compileCircuit(t, `
+package main
+func main(a, b uint4) uint4 {
+    return a + b
+}
+`)
+	comparator := compileCircuit(t, `
+package main
+func main(a, b uint4) (bool, uint4) {
+    return a > b, a - b
+}
+`)
+	circ, err := circuit.Compose(&circuit.ComposeSpec{
+		Circuits: []*circuit.Circuit{adder, comparator},
+		Connections: []circuit.Connection{
+			{
+				From: circuit.Port{Circuit: 0, Arg: 0},
+				To:   circuit.Port{Circuit: 1, Arg: 0},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compose failed: %s", err)
+	}
+	if len(circ.Inputs) != 3 || len(circ.Outputs) != 2 {
+		t.Fatalf("unexpected composed circuit: inputs=%v, outputs=%v",
+			circ.Inputs, circ.Outputs)
+	}
+	expected := adder.NumGates + comparator.NumGates
+	if circ.NumGates != expected {
+		t.Errorf("unexpected number of gates: got %v, expected %v",
+			circ.NumGates, expected)
+	}
+	if circ.Stats.Count() != uint64(expected) {
+		t.Errorf("unexpected stats: got %v, expected %v",
+			circ.Stats.Count(), expected)
+	}
+
+	for a := int64(0); a < 16; a++ {
+		for b := int64(0); b < 16; b++ {
+			for c := int64(0); c < 16; c += 3 {
+				results, err := circ.Compute([]*big.Int{
+					big.NewInt(a), big.NewInt(b), big.NewInt(c),
+				})
+				if err != nil {
+					t.Fatalf("compute failed: %s", err)
+				}
+				sum := (a + b) & 0xf
+				var gt int64
+				if sum > c {
+					gt = 1
+				}
+				if results[0].Int64() != gt {
+					t.Errorf("%d+%d > %d: got %v, expected %v",
+						a, b, c, results[0], gt)
+				}
+				diff := (sum - c) & 0xf
+				if results[1].Int64() != diff {
+					t.Errorf("%d+%d - %d: got %v, expected %v",
+						a, b, c, results[1], diff)
+				}
+			}
+		}
+	}
+
+	// Export the adder's output in addition to feeding it to the
+	// comparator.
+	circ, err = circuit.Compose(&circuit.ComposeSpec{
+		Circuits: []*circuit.Circuit{adder, comparator},
+		Connections: []circuit.Connection{
+			{
+				From: circuit.Port{Circuit: 0, Arg: 0},
+				To:   circuit.Port{Circuit: 1, Arg: 0},
+			},
+		},
+		Outputs: []circuit.Port{
+			{Circuit: 1, Arg: 0},
+			{Circuit: 0, Arg: 0},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compose failed: %s", err)
+	}
+	results, err := circ.Compute([]*big.Int{
+		big.NewInt(7), big.NewInt(5), big.NewInt(9),
+	})
+	if err != nil {
+		t.Fatalf("compute failed: %s", err)
+	}
+	if results[0].Int64() != 1 || results[1].Int64() != 12 {
+		t.Errorf("7+5 > 9: got %v", results)
+	}
+}
+
+func TestComposeInvalid(t *testing.T) {
+	adder := compileCircuit(t, `
+package main
+func main(a, b uint4) uint4 {
+    return a + b
+}
+`)
+	wide := compileCircuit(t, `
+package main
+func main(a, b uint8) bool {
+    return a > b
+}
+`)
+	tests := []circuit.ComposeSpec{
+		{
+			// Width mismatch.
+			Circuits: []*circuit.Circuit{adder, wide},
+			Connections: []circuit.Connection{
+				{
+					From: circuit.Port{Circuit: 0, Arg: 0},
+					To:   circuit.Port{Circuit: 1, Arg: 0},
+				},
+			},
+		},
+		{
+			// Backward connection.
+			Circuits: []*circuit.Circuit{adder, adder},
+			Connections: []circuit.Connection{
+				{
+					From: circuit.Port{Circuit: 1, Arg: 0},
+					To:   circuit.Port{Circuit: 0, Arg: 0},
+				},
+			},
+		},
+		{
+			// Invalid input.
+			Circuits: []*circuit.Circuit{adder, adder},
+			Connections: []circuit.Connection{
+				{
+					From: circuit.Port{Circuit: 0, Arg: 0},
+					To:   circuit.Port{Circuit: 1, Arg: 2},
+				},
+			},
+		},
+	}
+	for idx, test := range tests {
+		_, err := circuit.Compose(&test)
+		if err == nil {
+			t.Errorf("test %d: Compose succeeded", idx)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/markkurossi/mpc/compiler/mpa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// cond describes a conditional compilation block started with the
+// #if directive.
+type cond struct {
+	loc     utils.Point
+	outer   bool
+	value   bool
+	active  bool
+	hasElse bool
+}
+
+// SetDefines sets the compile-time definitions that control the
+// conditional compilation directives. The directive #if NAME includes
+// the lines up to the matching #else or #endif if NAME is defined and
+// its value is not false or zero. The directive #if !NAME negates the
+// condition.
+func (l *Lexer) SetDefines(defines map[string]string) {
+	l.defines = defines
+}
+
+// enabled tests if the current source line is included in the
+// compilation.
+func (l *Lexer) enabled() bool {
+	if len(l.conds) == 0 {
+		return true
+	}
+	return l.conds[len(l.conds)-1].active
+}
+
+// atLineStart tests if the rune, just read from the input, is the
+// first non-space rune of the current source line.
+func (l *Lexer) atLineStart() bool {
+	line := l.history[l.point.Line]
+	if len(line) == 0 {
+		return false
+	}
+	for _, r := range line[:len(line)-1] {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// readLine reads the remaining input from the current source line.
+func (l *Lexer) readLine() (string, error) {
+	var line []rune
+	for {
+		r, _, err := l.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+		if r == '\n' {
+			return string(line), nil
+		}
+		line = append(line, r)
+	}
+}
+
+// directive processes the conditional compilation directive starting
+// at the location loc. If the directive disables the compilation, the
+// function skips all input lines until the compilation is enabled
+// again.
+func (l *Lexer) directive(loc utils.Point) error {
+	line, err := l.readLine()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err := l.cond(loc, line); err != nil {
+		return err
+	}
+	for !l.enabled() {
+		loc = l.point
+		line, err = l.readLine()
+		if err != nil {
+			if err == io.EOF {
+				return l.errUnterminated()
+			}
+			return err
+		}
+		text := strings.TrimLeftFunc(line, unicode.IsSpace)
+		if strings.HasPrefix(text, "#") {
+			loc.Col += len([]rune(line)) - len([]rune(text))
+			if err := l.cond(loc, text[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Lexer) cond(loc utils.Point, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("%s: missing directive", loc)
+	}
+	switch fields[0] {
+	case "if":
+		if len(fields) != 2 {
+			return fmt.Errorf("%s: #if expects one condition", loc)
+		}
+		name := fields[1]
+		neg := strings.HasPrefix(name, "!")
+		if neg {
+			name = name[1:]
+		}
+		if !isIdentifier(name) {
+			return fmt.Errorf("%s: invalid #if condition '%s'",
+				loc, fields[1])
+		}
+		value := l.defined(name) != neg
+		outer := l.enabled()
+		l.conds = append(l.conds, cond{
+			loc:    loc,
+			outer:  outer,
+			value:  value,
+			active: outer && value,
+		})
+
+	case "else", "endif":
+		if len(fields) != 1 {
+			return fmt.Errorf("%s: unexpected arguments for #%s",
+				loc, fields[0])
+		}
+		if len(l.conds) == 0 {
+			return fmt.Errorf("%s: #%s without #if", loc, fields[0])
+		}
+		c := &l.conds[len(l.conds)-1]
+		if fields[0] == "endif" {
+			l.conds = l.conds[:len(l.conds)-1]
+			return nil
+		}
+		if c.hasElse {
+			return fmt.Errorf("%s: duplicate #else for #if at %s",
+				loc, c.loc)
+		}
+		c.hasElse = true
+		c.active = c.outer && !c.value
+
+	default:
+		return fmt.Errorf("%s: unknown directive #%s", loc, fields[0])
+	}
+	return nil
+}
+
+// defined tests if the compile-time definition name is set and its
+// value is not false or zero.
+func (l *Lexer) defined(name string) bool {
+	value, ok := l.defines[name]
+	if !ok {
+		return false
+	}
+	// Invalid definitions are reported when the compiler parses the
+	// definitions.
+	val, err := parseDefine(value)
+	if err != nil {
+		return true
+	}
+	switch v := val.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case *mpa.Int:
+		return v.Sign() != 0
+	default:
+		return true
+	}
+}
+
+func (l *Lexer) errUnterminated() error {
+	return fmt.Errorf("%s: unterminated #if", l.conds[len(l.conds)-1].loc)
+}
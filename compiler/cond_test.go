@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const condProgram = `
+package main
+
+func main(a, b uint8) uint8 {
+#if Square
+    a *= a
+#endif
+    #if !Xor
+    return a + b
+    #else
+    #if Broken
+    return a b)
+    #endif
+    return a ^ b
+    #endif
+}
+`
+
+func TestCond(t *testing.T) {
+	tests := []struct {
+		defines  map[string]string
+		expected int64
+		fail     bool
+	}{
+		{
+			expected: 5 + 3,
+		},
+		{
+			defines: map[string]string{
+				"Square": "true",
+			},
+			expected: 5*5 + 3,
+		},
+		{
+			defines: map[string]string{
+				"Square": "0",
+				"Xor":    "1",
+			},
+			expected: 5 ^ 3,
+		},
+		{
+			defines: map[string]string{
+				"Square": "true",
+				"Xor":    "true",
+				"Broken": "false",
+			},
+			expected: (5 * 5) ^ 3,
+		},
+		{
+			// Broken is excluded without Xor.
+			defines: map[string]string{
+				"Broken": "true",
+			},
+			expected: 5 + 3,
+		},
+		{
+			defines: map[string]string{
+				"Xor":    "true",
+				"Broken": "true",
+			},
+			fail: true,
+		},
+	}
+
+	var plain, square int
+	for _, test := range tests {
+		params := utils.NewParams()
+		params.Defines = test.defines
+
+		circ, _, err := New(params).Compile(condProgram, nil)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%v: compile succeeded", test.defines)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: compile failed: %v", test.defines, err)
+		}
+		results, err := circ.Compute([]*big.Int{
+			big.NewInt(5),
+			big.NewInt(3),
+		})
+		if err != nil {
+			t.Fatalf("%v: compute failed: %v", test.defines, err)
+		}
+		if results[0].Int64() != test.expected&0xff {
+			t.Errorf("%v: got %v, expected %v",
+				test.defines, results[0], test.expected&0xff)
+		}
+		switch {
+		case test.defines == nil:
+			plain = circ.NumGates
+		case test.defines["Square"] == "true" && len(test.defines) == 1:
+			square = circ.NumGates
+		}
+	}
+	if square <= plain {
+		t.Errorf("Square circuit has %d gates, plain %d", square, plain)
+	}
+}
+
+func TestCondInvalid(t *testing.T) {
+	for _, input := range []string{
+		"package main\n#if A\n",
+		"package main\n#if A\n#else\n",
+		"package main\n#else\n",
+		"package main\n#endif\n",
+		"package main\n#if A B\n#endif\n",
+		"package main\n#if 1A\n#endif\n",
+		"package main\n#ifdef A\n#endif\n",
+		"package main\n#if A\n#else\n#else\n#endif\n",
+		"package main\n#if A\n#endif extra\n",
+		"package main\nvar x = 1 # 2\n",
+	} {
+		_, _, err := New(utils.NewParams()).Compile(input, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestConstOrder(t *testing.T) {
+	code := `
+package main
+
+type Buf [M]byte
+
+type Pair struct {
+    A Buf
+    B [len(Name) + Extra]uint8
+}
+
+type Table [Rows][Cols]int32
+
+const M = N * 2
+const N = len("hello")
+
+const (
+    Rows = Cols - 1
+    Cols = len(Name) / 2
+)
+
+const Name = "abcdef"
+
+const Extra = cap(Seed)
+
+const Seed = [Cols]uint8{1, 2, 3}
+
+func main(a byte) (int, int, int, int, int, int) {
+    var buf Buf
+    var pair Pair
+    var table Table
+    return len(buf), len(pair.A), len(pair.B), len(table), len(table[0]),
+        cap(pair.B)
+}
+`
+	circ, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	results, err := circ.Compute([]*big.Int{big.NewInt(0)})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	for idx, expected := range []int64{10, 10, 9, 2, 3, 9} {
+		if results[idx].Int64() != expected {
+			t.Errorf("result %d: got %v, expected %v",
+				idx, results[idx], expected)
+		}
+	}
+}
+
+func TestConstOrderCycle(t *testing.T) {
+	for _, decls := range []string{
+		"const A = B + 1\nconst B = A + 1",
+		"const A = A + 1",
+		"type T [N]byte\nconst N = len(T{})",
+	} {
+		code := `
+package main
+` + decls + `
+func main(a byte) byte {
+    return a
+}
+`
+		_, _, err := New(utils.NewParams()).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", decls)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const longLoop = `
+package main
+func main(a, b uint64) uint64 {
+    var r uint64
+    for i := 0; i < 1000000; i++ {
+        r = r*a + b
+    }
+    return r
+}
+`
+
+func TestCompileContextTimeout(t *testing.T) {
+	params := utils.NewParams()
+	params.MaxLoopUnroll = 2000000
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := New(params).CompileContext(ctx, longLoop, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CompileContext: expected %v, got %v",
+			context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("CompileContext: cancellation took %v", d)
+	}
+}
+
+func TestCompileContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := New(utils.NewParams()).CompileContext(ctx, `
+package main
+func main(a, b uint8) uint8 {
+    return a + b
+}
+`, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CompileContext: expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/types"
+)
+
+var reMain = regexp.MustCompile(`(?m)^func\s+main\s*\(`)
+
+// CrossCheck compiles the two-party program code and evaluates it
+// with n random inputs. For each input, the function compares the
+// results of the constant folding, the plaintext circuit evaluation,
+// and the garbled circuit protocol run over an in-memory
+// connection. The constant folding is checked if all main function
+// arguments are integer or boolean values. The function returns an
+// error describing the first divergence.
+func CrossCheck(code string, n int) error {
+	return crossCheck(code, n, nil)
+}
+
+// crossCheck implements CrossCheck. The optional optimize function
+// is applied to the compiled circuits before their evaluation.
+func crossCheck(code string, n int, optimize func(*circuit.Circuit)) error {
+	params := utils.NewParams()
+	params.OptPruneGates = true
+
+	c := New(params)
+	pkg, err := c.parse("{data}", strings.NewReader(code), c.logger(),
+		ast.NewPackage("main", "{data}", nil))
+	if err != nil {
+		return err
+	}
+	main, err := pkg.Main()
+	if err != nil {
+		return err
+	}
+	circ, _, err := c.compilePkg(context.Background(), pkg, nil)
+	if err != nil {
+		return err
+	}
+	if optimize != nil {
+		optimize(circ)
+	}
+	if len(circ.Inputs) != 2 {
+		return fmt.Errorf("invalid program for 2-party computation: %d parties",
+			len(circ.Inputs))
+	}
+	foldable := len(main.Args) == len(circ.Inputs) && len(main.Return) > 0
+	for _, arg := range circ.Inputs {
+		switch arg.Type.Type {
+		case types.TInt, types.TUint, types.TBool:
+		default:
+			foldable = false
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		var inputs []*big.Int
+		for _, arg := range circ.Inputs {
+			max := new(big.Int).Lsh(big.NewInt(1), uint(arg.Type.Bits))
+			v, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, v)
+		}
+		plain, err := circ.Compute(inputs)
+		if err != nil {
+			return fmt.Errorf("inputs %v: circuit: %v", inputs, err)
+		}
+		garbled, err := circuit.RunLocal(circ, inputs[0], inputs[1])
+		if err != nil {
+			return fmt.Errorf("inputs %v: garbled: %v", inputs, err)
+		}
+		if !crossCheckEqual(plain, garbled) {
+			return fmt.Errorf("inputs %v: circuit %v != garbled %v",
+				inputs, plain, garbled)
+		}
+		if !foldable {
+			continue
+		}
+		folded, err := crossCheckFolded(code, main, circ.Inputs, inputs,
+			optimize)
+		if err != nil {
+			return fmt.Errorf("inputs %v: constant folding: %v", inputs, err)
+		}
+		if !crossCheckEqual(plain, folded) {
+			return fmt.Errorf("inputs %v: circuit %v != constant folding %v",
+				inputs, plain, folded)
+		}
+	}
+	return nil
+}
+
+// crossCheckFolded compiles the program with the main function
+// arguments bound to the constant inputs and returns the results of
+// the compiled constant program.
+func crossCheckFolded(code string, main *ast.Func, args circuit.IO,
+	inputs []*big.Int, optimize func(*circuit.Circuit)) ([]*big.Int, error) {
+
+	if len(reMain.FindAllStringIndex(code, -1)) != 1 {
+		return nil, fmt.Errorf("main function not found")
+	}
+	var rets []string
+	for _, r := range main.Return {
+		rets = append(rets, r.Type.String())
+	}
+	var vals []string
+	for idx, arg := range args {
+		v := inputs[idx]
+		switch arg.Type.Type {
+		case types.TBool:
+			vals = append(vals, fmt.Sprintf("%v", v.Sign() != 0))
+
+		case types.TInt:
+			if v.Bit(int(arg.Type.Bits)-1) != 0 {
+				v = new(big.Int).Sub(v,
+					new(big.Int).Lsh(big.NewInt(1), uint(arg.Type.Bits)))
+			}
+			fallthrough
+
+		default:
+			vals = append(vals,
+				fmt.Sprintf("%s(%s)", main.Args[idx].Type, v))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(reMain.ReplaceAllString(code, "func crossCheckMain("))
+	fmt.Fprintf(&sb, `
+func main(crossCheckG, crossCheckE uint1) (%s) {
+    return crossCheckMain(%s)
+}
+`,
+		strings.Join(rets, ", "), strings.Join(vals, ", "))
+
+	// The constant inputs make some of the program's code unreachable
+	// so the warnings are discarded.
+	params := utils.NewParams()
+	params.OptPruneGates = true
+	params.LogOut = io.Discard
+	circ, _, err := New(params).Compile(sb.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if optimize != nil {
+		optimize(circ)
+	}
+	return circ.Compute([]*big.Int{big.NewInt(0), big.NewInt(0)})
+}
+
+func crossCheckEqual(a, b []*big.Int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx, v := range a {
+		if v.Cmp(b[idx]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+)
+
+var crossCheckPrograms = []string{
+	`
+package main
+func main(a, b uint8) uint8 {
+    return a & b
+}
+`,
+	`
+package main
+func main(a, b int32) (int32, bool) {
+    if a > b {
+        return a - b, true
+    }
+    return b*a + 7, false
+}
+`,
+	`
+package main
+func main(a uint16, b bool) uint16 {
+    var r uint16
+    for i := 0; i < 4; i++ {
+        if b {
+            r += a >> i
+        } else {
+            r ^= a << i
+        }
+    }
+    return r
+}
+`,
+	`
+package main
+func main(a, b [4]byte) [4]byte {
+    var r [4]byte
+    for i := 0; i < len(a); i++ {
+        r[i] = a[i] + b[len(b)-i-1]
+    }
+    return r
+}
+`,
+}
+
+func TestCrossCheck(t *testing.T) {
+	for idx, code := range crossCheckPrograms {
+		if err := CrossCheck(code, 8); err != nil {
+			t.Errorf("program %d: %v", idx, err)
+		}
+	}
+}
+
+// brokenOptimization replaces AND gates with OR gates.
+func brokenOptimization(circ *circuit.Circuit) {
+	for i := range circ.Gates {
+		if circ.Gates[i].Op == circuit.AND {
+			circ.Gates[i].Op = circuit.OR
+		}
+	}
+}
+
+func TestCrossCheckBroken(t *testing.T) {
+	err := crossCheck(crossCheckPrograms[0], 8, brokenOptimization)
+	if err == nil {
+		t.Fatalf("CrossCheck did not catch broken optimization")
+	}
+	t.Logf("CrossCheck: %v", err)
+}
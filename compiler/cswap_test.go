@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const cswapAggregateProgram = `
+package main
+
+type Point struct {
+	X, Y int16
+}
+
+func main(a, b [3]Point) ([3]Point, [3]Point) {
+	// Compare-and-swap sort of the points by their X coordinates.
+	var p, q Point
+	for i := 0; i < len(a); i++ {
+		p, q = a[i], b[i]
+		a[i], b[i] = cswap(p.X > q.X, p, q)
+	}
+	p, q = a[0], b[0]
+	return cswap(p.Y > q.Y, a, b)
+}
+`
+
+type cswapPoint struct {
+	x, y int16
+}
+
+func cswapPoints(arr [3]cswapPoint) *big.Int {
+	v := new(big.Int)
+	for i := len(arr) - 1; i >= 0; i-- {
+		v.Lsh(v, 16)
+		v.Or(v, big.NewInt(int64(uint16(arr[i].y))))
+		v.Lsh(v, 16)
+		v.Or(v, big.NewInt(int64(uint16(arr[i].x))))
+	}
+	return v
+}
+
+func TestCSwapAggregate(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).Compile(cswapAggregateProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	tests := [][2][3]cswapPoint{
+		{
+			{{1, 2}, {5, 6}, {-3, 0}},
+			{{4, 1}, {2, 9}, {-3, 7}},
+		},
+		{
+			{{7, -1}, {0, 0}, {100, -100}},
+			{{-7, 1}, {1, 1}, {-100, 100}},
+		},
+		{
+			{{0, 0}, {0, 0}, {0, 0}},
+			{{0, 0}, {0, 0}, {0, 0}},
+		},
+	}
+	for _, test := range tests {
+		a := test[0]
+		b := test[1]
+		for i := range a {
+			if a[i].x > b[i].x {
+				a[i], b[i] = b[i], a[i]
+			}
+		}
+		if a[0].y > b[0].y {
+			a, b = b, a
+		}
+		result, err := circuit.RunLocal(circ, cswapPoints(test[0]),
+			cswapPoints(test[1]))
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		if len(result) != 2 || result[0].Cmp(cswapPoints(a)) != 0 ||
+			result[1].Cmp(cswapPoints(b)) != 0 {
+			t.Errorf("cswap(%v, %v): got %x, expected %v %v",
+				test[0], test[1], result, a, b)
+		}
+	}
+}
+
+func TestCSwapInvalid(t *testing.T) {
+	tests := []struct {
+		expr    string
+		message string
+	}{
+		{
+			expr:    "cswap(1, a, a)",
+			message: "invalid argument 1 (type int32) for cswap",
+		},
+		{
+			expr:    "cswap(true, a)",
+			message: "invalid amount of arguments in call to cswap",
+		},
+		{
+			expr:    "cswap(a.X > 1, a, b)",
+			message: "mismatched types Point and Pair",
+		},
+		{
+			expr:    "cswap(a.X > 1, c, d)",
+			message: "mismatched types [4]uint8 and [2]uint16",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+type Point struct {
+    X, Y uint16
+}
+type Pair struct {
+    X, Y uint16
+}
+func main(a Point, b Pair) uint16 {
+    var c [4]uint8
+    var d [2]uint16
+    r, _ := %s
+    return r.X
+}
+`, test.expr)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", test.expr)
+			continue
+		}
+		if !strings.Contains(log.String(), test.message) {
+			t.Errorf("%s: got error %q, expected %q",
+				test.expr, log.String(), test.message)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var deferErrors = []string{
+	// Deferred return.
+	`
+package main
+func main(a uint32) uint32 {
+    defer return a
+    return a
+}
+`,
+	// Nested defer.
+	`
+package main
+func main(a uint32) uint32 {
+    defer defer a++
+    return a
+}
+`,
+	// Defer in deferred statement.
+	`
+package main
+func main(a uint32) (r uint32) {
+    defer if a > 0 {
+        defer r++
+    }
+    return a
+}
+`,
+}
+
+func TestDeferErrors(t *testing.T) {
+	for idx, code := range deferErrors {
+		_, _, err := New(utils.NewParams()).Compile(code, nil)
+		if err == nil {
+			t.Errorf("test %d: Compile succeeded", idx)
+		}
+	}
+}
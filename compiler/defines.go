@@ -0,0 +1,93 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/markkurossi/mpc/compiler/mpa"
+	"github.com/markkurossi/mpc/types"
+)
+
+// parseDefines parses the compile-time constant definitions. The
+// constant values are typed by their literal form: integers
+// (optionally negative), characters, booleans, and strings.
+func parseDefines(defines map[string]string) (map[string]interface{}, error) {
+	if len(defines) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]interface{})
+	for name, value := range defines {
+		if !isIdentifier(name) {
+			return nil, fmt.Errorf("invalid define name '%s'", name)
+		}
+		val, err := parseDefine(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for define %s: %s",
+				name, err)
+		}
+		result[name] = val
+	}
+	return result, nil
+}
+
+func parseDefine(value string) (interface{}, error) {
+	lexer := NewLexer("define", strings.NewReader(value))
+
+	t, err := lexer.Get()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("empty value")
+		}
+		return nil, err
+	}
+	var neg bool
+	if t.Type == '-' {
+		neg = true
+		t, err = lexer.Get()
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal '%s'", value)
+		}
+	}
+	if t.Type != TConstant {
+		return nil, fmt.Errorf("invalid literal '%s'", value)
+	}
+	if _, err := lexer.Get(); err != io.EOF {
+		return nil, fmt.Errorf("invalid literal '%s'", value)
+	}
+	if !neg {
+		return t.ConstVal, nil
+	}
+	switch val := t.ConstVal.(type) {
+	case *mpa.Int:
+		r := mpa.NewInt(0, types.Size(val.TypeSize()))
+		return r.Sub(r, val), nil
+	case int64:
+		return -val, nil
+	default:
+		return nil, fmt.Errorf("invalid literal '%s'", value)
+	}
+}
+
+func isIdentifier(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	if _, ok := symbols[name]; ok {
+		return false
+	}
+	for idx, r := range name {
+		if !unicode.IsLetter(r) && r != '_' &&
+			(idx == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/mpa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const definesProgram = `
+package main
+func main(a [N]uint8) uint8 {
+    var sum uint8
+    for i := 0; i < N; i++ {
+        sum += a[i]
+    }
+    if Enabled {
+        return sum + Bias
+    }
+    return sum
+}
+`
+
+func TestDefines(t *testing.T) {
+	tests := []struct {
+		defines  map[string]string
+		inputs   []int64
+		expected int64
+	}{
+		{
+			defines: map[string]string{
+				"N":       "3",
+				"Bias":    "7",
+				"Enabled": "true",
+			},
+			inputs:   []int64{1, 2, 3},
+			expected: 13,
+		},
+		{
+			defines: map[string]string{
+				"N":       "5",
+				"Bias":    "0x10",
+				"Enabled": "true",
+			},
+			inputs:   []int64{1, 2, 3, 4, 5},
+			expected: 31,
+		},
+		{
+			defines: map[string]string{
+				"N":       "5",
+				"Bias":    "0x10",
+				"Enabled": "false",
+			},
+			inputs:   []int64{1, 2, 3, 4, 5},
+			expected: 15,
+		},
+	}
+	for _, test := range tests {
+		params := utils.NewParams()
+		params.Defines = test.defines
+
+		circ, _, err := New(params).Compile(definesProgram, nil)
+		if err != nil {
+			t.Fatalf("%v: compile failed: %v", test.defines, err)
+		}
+		size := circ.Inputs.Size()
+		if size != len(test.inputs)*8 {
+			t.Errorf("%v: got input size %d, expected %d",
+				test.defines, size, len(test.inputs)*8)
+		}
+		input := new(big.Int)
+		for i := len(test.inputs) - 1; i >= 0; i-- {
+			input.Lsh(input, 8)
+			input.Or(input, big.NewInt(test.inputs[i]))
+		}
+		results, err := circ.Compute([]*big.Int{input})
+		if err != nil {
+			t.Fatalf("%v: compute failed: %v", test.defines, err)
+		}
+		if results[0].Int64() != test.expected {
+			t.Errorf("%v: got %v, expected %v",
+				test.defines, results[0], test.expected)
+		}
+	}
+}
+
+func TestDefinesInvalid(t *testing.T) {
+	for _, defines := range []map[string]string{
+		{"N": ""},
+		{"N": "3 4"},
+		{"N": "x"},
+		{"N": "-true"},
+		{"1N": "3"},
+		{"func": "3"},
+	} {
+		params := utils.NewParams()
+		params.Defines = defines
+		_, _, err := New(params).Compile(definesProgram, nil)
+		if err == nil {
+			t.Errorf("%v: compile succeeded", defines)
+		}
+	}
+}
+
+func TestParseDefine(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected interface{}
+	}{
+		{"42", int64(42)},
+		{"-42", int64(-42)},
+		{"0xff", int64(255)},
+		{"'a'", int64('a')},
+		{"true", true},
+		{"false", false},
+		{`"hello"`, "hello"},
+	}
+	for _, test := range tests {
+		val, err := parseDefine(test.value)
+		if err != nil {
+			t.Errorf("parseDefine(%s) failed: %v", test.value, err)
+			continue
+		}
+		if i, ok := val.(*mpa.Int); ok {
+			val = i.Int64()
+		}
+		if val != test.expected {
+			t.Errorf("parseDefine(%s): got %v (%T), expected %v (%T)",
+				test.value, val, val, test.expected, test.expected)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+type ErrorLocationTest struct {
+	Name     string
+	Code     string
+	Location string
+	Message  string
+}
+
+var reErrorLocation = regexp.MustCompile(`(?m)^\{data\}:([0-9]+:[0-9]+): (.*)$`)
+
+var errorLocationTests = []ErrorLocationTest{
+	{
+		Name: "argument",
+		Code: `
+package main
+func main(a int32, b bool) int32 {
+    return add(a,
+        b,
+        a)
+}
+func add(x, y, z int32) int32 {
+    return x + y + z
+}
+`,
+		Location: "5:8",
+		Message:  "cannot use b (type bool1) as type int32 in argument y to add",
+	},
+	{
+		Name: "template argument",
+		Code: `
+package main
+func main(a int32, b bool) int32 {
+    return first(b,
+        a)
+}
+func first(x, y int) int {
+    return x
+}
+`,
+		Location: "4:17",
+		Message:  "cannot use b (type bool1) as type int in argument x to first",
+	},
+	{
+		Name: "multi-value argument",
+		Code: `
+package main
+func main(a int32, b bool) int32 {
+    return add(
+        pair(a, b))
+}
+func pair(a int32, b bool) (int32, bool) {
+    return a, b
+}
+func add(x, y int32) int32 {
+    return x + y
+}
+`,
+		Location: "5:8",
+		Message: "cannot use pair(a, b) (type bool1) as type int32 " +
+			"in argument y to add",
+	},
+	{
+		Name: "return value",
+		Code: `
+package main
+func main(a int32, b bool) (int32, int32) {
+    return a,
+        b
+}
+`,
+		Location: "5:8",
+		Message:  "cannot use b (type bool1) as type int32 in return value 1",
+	},
+}
+
+func TestErrorLocation(t *testing.T) {
+	for _, test := range errorLocationTests {
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+
+		_, _, err := New(params).Compile(test.Code, nil)
+		if err == nil {
+			t.Fatalf("%s: compile succeeded", test.Name)
+		}
+		m := reErrorLocation.FindStringSubmatch(buf.String())
+		if m == nil {
+			t.Fatalf("%s: no error location: %s", test.Name, buf.String())
+		}
+		if m[1] != test.Location {
+			t.Errorf("%s: error at %s, expected %s", test.Name, m[1],
+				test.Location)
+		}
+		if m[2] != test.Message {
+			t.Errorf("%s: got error '%s', expected '%s'", test.Name, m[2],
+				test.Message)
+		}
+	}
+}
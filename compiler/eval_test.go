@@ -0,0 +1,44 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var constEqualMismatchTests = []string{
+	`
+package main
+const X = [3]int32{1, 2, 3} == [2]int32{1, 2}
+func main(a, b int32) bool {
+    return X
+}
+`,
+	`
+package main
+const X = [2]int32{1, 2} != [2]int64{1, 2}
+func main(a, b int32) bool {
+    return X
+}
+`,
+}
+
+func TestConstEqualMismatch(t *testing.T) {
+	for idx, code := range constEqualMismatchTests {
+		params := utils.NewParams()
+		params.LogOut = &bytes.Buffer{}
+		params.NoCircCompile = true
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("test %d: mismatched comparison compiled", idx)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// constHeavyProgram creates a program that folds a right-nested
+// expression of table lookups in an unrolled loop.
+func constHeavyProgram(terms, rounds int) string {
+	rnd := rand.New(rand.NewSource(1))
+	var table []string
+	for i := 0; i < 256; i++ {
+		table = append(table, fmt.Sprintf("%d", rnd.Uint32()))
+	}
+	expr := "a"
+	for i := terms - 1; i >= 0; i-- {
+		expr = fmt.Sprintf("(Table[(i+%d)%%256] ^ %s)", i, expr)
+	}
+	return fmt.Sprintf(`
+package main
+
+const Table = [256]uint32{%s}
+
+func main(a, b uint32) uint32 {
+	var r uint32
+	for i := 0; i < %d; i++ {
+		r += %s
+	}
+	return r + b
+}
+`, strings.Join(table, ", "), rounds, expr)
+}
+
+func compileEvalCache(t *testing.T, code string, cache bool) (
+	*circuit.Circuit, *Compiler, time.Duration) {
+
+	params := utils.NewParams()
+	params.NoEvalCache = !cache
+	cc := New(params)
+
+	start := time.Now()
+	circ, _, err := cc.Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed (cache=%v): %v", cache, err)
+	}
+	return circ, cc, time.Since(start)
+}
+
+func compareCircuits(t *testing.T, name string, a, b *circuit.Circuit) {
+	var abuf, bbuf bytes.Buffer
+	if err := a.Marshal(&abuf); err != nil {
+		t.Fatalf("%s: Marshal failed: %v", name, err)
+	}
+	if err := b.Marshal(&bbuf); err != nil {
+		t.Fatalf("%s: Marshal failed: %v", name, err)
+	}
+	if !bytes.Equal(abuf.Bytes(), bbuf.Bytes()) {
+		t.Errorf("%s: circuits differ: %v vs. %v", name, a, b)
+	}
+}
+
+func TestEvalCache(t *testing.T) {
+	code := constHeavyProgram(100, 4)
+
+	cached, cc, tCached := compileEvalCache(t, code, true)
+	uncached, uc, tUncached := compileEvalCache(t, code, false)
+
+	compareCircuits(t, "const-heavy", cached, uncached)
+
+	if cc.Stats.EvalCacheHits == 0 {
+		t.Errorf("no eval cache hits")
+	}
+	if uc.Stats.EvalCacheHits != 0 {
+		t.Errorf("eval cache hits with disabled cache: %v",
+			uc.Stats.EvalCacheHits)
+	}
+	t.Logf("compile: cached %v, uncached %v, %d hits",
+		tCached, tUncached, cc.Stats.EvalCacheHits)
+}
+
+func TestEvalCacheTestsuite(t *testing.T) {
+	files, err := filepath.Glob("../testsuite/lang/*.mpcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		var circs [2]*circuit.Circuit
+		var errs [2]error
+		for idx, cache := range []bool{true, false} {
+			params := utils.NewParams()
+			params.NoEvalCache = !cache
+			params.LogOut = io.Discard
+			circs[idx], _, errs[idx] = New(params).CompileFile(file, nil)
+		}
+		name := filepath.Base(file)
+		if errs[0] != nil || errs[1] != nil {
+			// Programs with unsized arguments can't be compiled
+			// without input sizes.
+			if fmt.Sprint(errs[0]) != fmt.Sprint(errs[1]) {
+				t.Errorf("%s: errors differ: %v vs. %v",
+					name, errs[0], errs[1])
+			}
+			continue
+		}
+		compareCircuits(t, name, circs[0], circs[1])
+	}
+}
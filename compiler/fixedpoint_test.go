@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const fixedFrac = 16
+
+// toFixed returns the Q16.16 bit pattern of the value v.
+func toFixed(v float64) *big.Int {
+	raw := int64(math.Round(v * (1 << fixedFrac)))
+	return big.NewInt(int64(uint32(raw)))
+}
+
+// fromFixed returns the value of the Q16.16 bit pattern v.
+func fromFixed(v *big.Int) float64 {
+	return float64(int32(uint32(v.Uint64()))) / (1 << fixedFrac)
+}
+
+var fixedPointTests = []struct {
+	name     string
+	code     string
+	inputs   []float64
+	expected []float64
+}{
+	{
+		name: "mul",
+		code: `
+package main
+func main(a, b fixed<16,16>) fixed<16,16> {
+    return a * b
+}
+`,
+		inputs:   []float64{1.5, 2.25},
+		expected: []float64{3.375},
+	},
+	{
+		name: "mul negative",
+		code: `
+package main
+func main(a, b fixed<16,16>) fixed<16,16> {
+    return a * b
+}
+`,
+		inputs:   []float64{-1.5, 2.25},
+		expected: []float64{-3.375},
+	},
+	{
+		name: "mul fraction",
+		code: `
+package main
+func main(a, b fixed<16,16>) fixed<16,16> {
+    return a * b
+}
+`,
+		inputs:   []float64{0.1, 0.1},
+		expected: []float64{0.01},
+	},
+	{
+		name: "add sub",
+		code: `
+package main
+func main(a, b fixed<16,16>) (fixed<16,16>, fixed<16,16>, fixed<16,16>) {
+    return a + b, a - b, -a + 1
+}
+`,
+		inputs:   []float64{1.5, 2.25},
+		expected: []float64{3.75, -0.75, -0.5},
+	},
+	{
+		name: "compare",
+		code: `
+package main
+func main(a, b fixed<16,16>) fixed<16,16> {
+    if a < b {
+        return a
+    }
+    return b
+}
+`,
+		inputs:   []float64{-1.5, 0.25},
+		expected: []float64{-1.5},
+	},
+	{
+		name: "convert",
+		code: `
+package main
+type Q16 = fixed<16,16>
+func main(a, b Q16) (Q16, Q16) {
+    n := int32(a * b)
+    return fixed<16,16>(n), Q16(3) * b
+}
+`,
+		inputs:   []float64{1.5, 2.25},
+		expected: []float64{3, 6.75},
+	},
+}
+
+func TestFixedPoint(t *testing.T) {
+	// The result must be within one unit in the last place.
+	const epsilon = 1.0 / (1 << fixedFrac)
+
+	for _, test := range fixedPointTests {
+		circ, _, err := New(utils.NewParams()).Compile(test.code, nil)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", test.name, err)
+		}
+		var inputs []*big.Int
+		for _, in := range test.inputs {
+			inputs = append(inputs, toFixed(in))
+		}
+		results, err := circ.Compute(inputs)
+		if err != nil {
+			t.Fatalf("%s: compute failed: %v", test.name, err)
+		}
+		for idx, expected := range test.expected {
+			result := fromFixed(results[idx])
+			if math.Abs(result-expected) > epsilon {
+				t.Errorf("%s: result %d: got %v, expected %v",
+					test.name, idx, result, expected)
+			}
+		}
+	}
+}
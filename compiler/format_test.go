@@ -0,0 +1,234 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const formatInput = `// Package doc.
+package main
+import ( "math"
+  b "bytes" )
+// Limits.
+const (
+Max=10
+  MinValue = 0x0f // trailing
+)
+var  v  int32
+type Point struct { x,y int32
+  label string }
+func (p *Point) Sum() int32 { return p.x+p.y }
+// main is the entry point.
+func main(a,b int32) (r int32, ok bool) {
+  var arr [Max]int32
+  for i:=0;i<Max;i++ { arr[i] += (a*(b+1)) }
+
+
+  // Range over the array.
+  for _, v := range arr { r = r - -v }
+  if (Point{a, b}) == q { ok = true } else if a == b { ok = false } else {
+    r++
+  }
+  x := [2][2]int32{
+    {1, 2}, {3, 4}}
+  return r + x[1][1] +
+    int32(math.MaxInt8) + int32(len(b.Repeat(hex"0a", 2))), (ok)
+}
+`
+
+const formatOutput = `// Package doc.
+package main
+
+import (
+	b "bytes"
+	"math"
+)
+
+// Limits.
+const (
+	Max      = 10
+	MinValue = 0x0f // trailing
+)
+
+var v int32
+
+type Point struct {
+	x, y  int32
+	label string
+}
+
+func (p *Point) Sum() int32 { return p.x + p.y }
+
+// main is the entry point.
+func main(a, b int32) (r int32, ok bool) {
+	var arr [Max]int32
+	for i := 0; i < Max; i++ {
+		arr[i] += (a * (b + 1))
+	}
+
+	// Range over the array.
+	for _, v := range arr {
+		r = r - -v
+	}
+	if (Point{a, b}) == q {
+		ok = true
+	} else if a == b {
+		ok = false
+	} else {
+		r++
+	}
+	x := [2][2]int32{
+		{1, 2}, {3, 4},
+	}
+	return r + x[1][1] +
+		int32(math.MaxInt8) + int32(len(b.Repeat(hex"0a", 2))), ok
+}
+`
+
+func parseFormat(t *testing.T, name string, in io.Reader) *ast.Package {
+	logger := utils.NewLogger(os.Stderr)
+	parser := NewParser(name, New(utils.NewParams()), logger, in)
+	pkg, err := parser.Parse(nil)
+	if err != nil {
+		t.Fatalf("%s: parse failed: %v", name, err)
+	}
+	return pkg
+}
+
+func TestFormat(t *testing.T) {
+	pkg := parseFormat(t, "{data}", strings.NewReader(formatInput))
+	var buf bytes.Buffer
+	if err := ast.Format(pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != formatOutput {
+		t.Errorf("Format:\n%s\nexpected:\n%s", buf.String(), formatOutput)
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	var files []string
+	for _, dir := range []string{"../pkg", "../testsuite/lang"} {
+		err := filepath.WalkDir(dir,
+			func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.HasSuffix(path, ".mpcl") {
+					files = append(files, path)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg := parseFormat(t, file, bytes.NewReader(data))
+		var formatted bytes.Buffer
+		if err := ast.Format(pkg, &formatted); err != nil {
+			t.Fatal(err)
+		}
+		pkg2 := parseFormat(t, file, bytes.NewReader(formatted.Bytes()))
+		if !astEqual(reflect.ValueOf(pkg), reflect.ValueOf(pkg2)) {
+			t.Errorf("%s: formatted AST differs:\n%s", file, formatted.String())
+			continue
+		}
+		var again bytes.Buffer
+		if err := ast.Format(pkg2, &again); err != nil {
+			t.Fatal(err)
+		}
+		if again.String() != formatted.String() {
+			t.Errorf("%s: formatting is not idempotent:\n%s\n---\n%s",
+				file, formatted.String(), again.String())
+		}
+	}
+}
+
+var pointType = reflect.TypeOf(utils.Point{})
+
+// astEqual tests if the values are structurally equal. The source
+// locations are ignored.
+func astEqual(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a.Type() == pointType {
+		return true
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return astEqual(a.Elem(), b.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !astEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !astEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			bv := b.MapIndex(iter.Key())
+			if !bv.IsValid() || !astEqual(iter.Value(), bv) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.String:
+		return a.String() == b.String()
+
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return a.Int() == b.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestFuncValue(t *testing.T) {
+	code := `
+package main
+
+func double(x int32) int32 {
+    return x * 2
+}
+
+func inc(x int32) int32 {
+    return x + 1
+}
+
+func add(a, b int32) int32 {
+    return a + b
+}
+
+func mapArray(f func(int32) int32, arr [4]int32) [4]int32 {
+    var result [4]int32
+    for i := 0; i < len(arr); i++ {
+        result[i] = f(arr[i])
+    }
+    return result
+}
+
+func reduce(f func(int32, int32) int32, arr [4]int32) int32 {
+    acc := arr[0]
+    for i := 1; i < len(arr); i++ {
+        acc = f(acc, arr[i])
+    }
+    return acc
+}
+
+func main(a, b int32) ([4]int32, int32) {
+    var arr [4]int32
+    arr[0] = a
+    arr[1] = b
+    arr[2] = a + b
+    arr[3] = a - b
+    g := inc
+    return mapArray(double, mapArray(g, arr)), reduce(add, arr)
+}
+`
+	circ, _, err := New(utils.NewParams()).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	for _, input := range [][2]int64{
+		{3, 5},
+		{100, 7},
+		{0, 0},
+	} {
+		results, err := circ.Compute([]*big.Int{
+			big.NewInt(input[0]),
+			big.NewInt(input[1]),
+		})
+		if err != nil {
+			t.Fatalf("compute failed: %v", err)
+		}
+		a, b := input[0], input[1]
+		arr := []int64{a, b, a + b, a - b}
+
+		var expected big.Int
+		var sum int64
+		for idx, v := range arr {
+			el := big.NewInt(((v + 1) * 2) & 0xffffffff)
+			expected.Or(&expected, el.Lsh(el, uint(idx*32)))
+			sum += v
+		}
+		if results[0].Cmp(&expected) != 0 {
+			t.Errorf("%v: mapArray: got %x, expected %x",
+				input, results[0], &expected)
+		}
+		if results[1].Int64() != sum {
+			t.Errorf("%v: reduce: got %v, expected %v",
+				input, results[1], sum)
+		}
+	}
+}
+
+func TestFuncValueInvalid(t *testing.T) {
+	for _, call := range []string{
+		"apply(add, a)",
+		"apply(wide, a)",
+		"apply(a, a)",
+	} {
+		code := `
+package main
+
+func add(a, b int32) int32 {
+    return a + b
+}
+
+func wide(x int64) int32 {
+    return int32(x)
+}
+
+func apply(f func(int32) int32, x int32) int32 {
+    return f(x)
+}
+
+func main(a, b int32) int32 {
+    return ` + call + `
+}
+`
+		params := utils.NewParams()
+		params.LogOut = &bytes.Buffer{}
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", call)
+		}
+	}
+}
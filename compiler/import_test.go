@@ -0,0 +1,116 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/pkg"
+)
+
+const mathxPackage = `
+package mathx
+
+// Square returns x*x.
+func Square(x int32) int32 {
+    return mul(x, x)
+}
+
+func mul(a, b int32) int32 {
+    return a * b
+}
+`
+
+const mathxMain = `
+package main
+
+import (
+    "mathx"
+)
+
+func main(a, b int32) int32 {
+    return mathx.Square(a) + mathx.Square(b)
+}
+`
+
+func TestImportPkgPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(path.Join(dir, "mathx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(path.Join(dir, "mathx", "mathx.mpcl"),
+		[]byte(mathxPackage), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainFile := path.Join(dir, "main.mpcl")
+	if err := os.WriteFile(mainFile, []byte(mathxMain), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	params := utils.NewParams()
+	params.PkgPath = []string{dir}
+
+	circ, _, err := New(params).CompileFile(mainFile, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	results, err := circ.Compute([]*big.Int{big.NewInt(3), big.NewInt(4)})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	if results[0].Int64() != 25 {
+		t.Errorf("got %v, expected 25", results[0])
+	}
+}
+
+const pkgFSMain = `
+package main
+
+import (
+    "encoding/binary"
+    "math"
+)
+
+func main(a, b uint64) uint64 {
+    var buf [8]byte
+    buf = binary.PutUint64(buf, 0, math.AddUint64(a, b))
+    return binary.GetUint64(buf)
+}
+`
+
+func TestImportPkgFS(t *testing.T) {
+	// Make the standard library unreachable from the disk.
+	t.Setenv("MPCLDIR", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+
+	params := utils.NewParams()
+	params.PkgFS = pkg.FS
+
+	circ, _, err := New(params).Compile(pkgFSMain, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	results, err := circ.Compute([]*big.Int{big.NewInt(3), big.NewInt(4)})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	if results[0].Int64() != 7 {
+		t.Errorf("got %v, expected 7", results[0])
+	}
+
+	// Without PkgFS, the standard library is not found.
+	_, _, err = New(utils.NewParams()).Compile(pkgFSMain, nil)
+	if err == nil {
+		t.Errorf("compile succeeded without standard library")
+	}
+}
@@ -0,0 +1,156 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestIndexPolicyFlag(t *testing.T) {
+	code := `
+package main
+func main(arr [5]uint8, i int8) (uint8, bool) {
+    v, ok := arr[i]
+    return v, ok
+}
+`
+	params := utils.NewParams()
+	params.IndexPolicy = utils.IndexFlag
+	circ, _, err := New(params).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+	arr := big.NewInt(0x0504030201)
+
+	for i := int64(-128); i < 128; i++ {
+		index := big.NewInt(i & 0xff)
+		results, err := circ.Compute([]*big.Int{arr, index})
+		if err != nil {
+			t.Fatalf("compute failed: %s", err)
+		}
+		var value, ok int64
+		if i >= 0 && i < 5 {
+			value = i + 1
+			ok = 1
+		}
+		if results[0].Int64() != value || results[1].Int64() != ok {
+			t.Errorf("arr[%d]: got (%v, %v), expected (%v, %v)",
+				i, results[0], results[1], value, ok)
+		}
+	}
+}
+
+func TestIndexPolicyFlagConst(t *testing.T) {
+	code := `
+package main
+func main(arr [3]uint8) (uint8, bool, uint8, bool) {
+    a, aok := arr[1]
+    b, bok := arr[7]
+    return a, aok, b, bok
+}
+`
+	params := utils.NewParams()
+	params.IndexPolicy = utils.IndexFlag
+	circ, _, err := New(params).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+	results, err := circ.Compute([]*big.Int{big.NewInt(0x030201)})
+	if err != nil {
+		t.Fatalf("compute failed: %s", err)
+	}
+	expected := []int64{2, 1, 0, 0}
+	for idx, r := range results {
+		if r.Int64() != expected[idx] {
+			t.Errorf("result %d: got %v, expected %v", idx, r, expected[idx])
+		}
+	}
+}
+
+func TestIndexPolicyZero(t *testing.T) {
+	code := `
+package main
+func main(arr [5]uint8, i uint8) uint8 {
+    return arr[i]
+}
+`
+	circ := compileCircuit(t, code)
+	arr := big.NewInt(0x0504030201)
+
+	for i := int64(0); i < 256; i++ {
+		results, err := circ.Compute([]*big.Int{arr, big.NewInt(i)})
+		if err != nil {
+			t.Fatalf("compute failed: %s", err)
+		}
+		var value int64
+		if i < 5 {
+			value = i + 1
+		}
+		if results[0].Int64() != value {
+			t.Errorf("arr[%d]: got %v, expected %v", i, results[0], value)
+		}
+	}
+
+	// The comma-ok index requires the flag policy.
+	_, _, err := New(utils.NewParams()).Compile(`
+package main
+func main(arr [5]uint8, i uint8) (uint8, bool) {
+    v, ok := arr[i]
+    return v, ok
+}
+`, nil)
+	if err == nil {
+		t.Errorf("comma-ok index compiled with zero index policy")
+	}
+}
+
+func TestNoBoundsCheck(t *testing.T) {
+	params := utils.NewParams()
+	params.NoBoundsCheck = true
+
+	for n := 1; n <= 9; n++ {
+		code := fmt.Sprintf(`
+package main
+func main(arr [%d]uint16, i uint8) uint16 {
+    return arr[i]
+}
+`, n)
+		circ := compileCircuit(t, code)
+		unchecked, _, err := New(params).Compile(code, nil)
+		if err != nil {
+			t.Fatalf("failed to compile: %s", err)
+		}
+		if unchecked.Stats[circuit.AND] >= circ.Stats[circuit.AND] {
+			t.Errorf("[%d]uint16: unchecked index has %d AND gates, "+
+				"checked %d AND gates", n, unchecked.Stats[circuit.AND],
+				circ.Stats[circuit.AND])
+		}
+
+		arr := new(big.Int)
+		for i := n - 1; i >= 0; i-- {
+			arr.Lsh(arr, 16)
+			arr.Or(arr, big.NewInt(int64(0x1111*(i+1))))
+		}
+		for i := int64(0); i < int64(n); i++ {
+			for _, c := range []*circuit.Circuit{circ, unchecked} {
+				results, err := c.Compute([]*big.Int{arr, big.NewInt(i)})
+				if err != nil {
+					t.Fatalf("compute failed: %s", err)
+				}
+				if results[0].Int64() != 0x1111*(i+1) {
+					t.Errorf("[%d]uint16: arr[%d]: got %x, expected %x",
+						n, i, results[0], 0x1111*(i+1))
+				}
+			}
+		}
+	}
+}
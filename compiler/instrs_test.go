@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const instrsTemplate = `
+package main
+
+var zero int32 = 0
+
+func add(a, b int32) int32 {
+    return a + b
+}
+
+func main(a, b int32) int32 {
+    r := zero
+    for i := 0; i < %d; i++ {
+        r = add(r, a)
+    }
+    return r + b
+}
+`
+
+func compileInstrs(rounds, limit int) (ast.InstrStats, string, error) {
+	var log bytes.Buffer
+	params := utils.NewParams()
+	params.NoCircCompile = true
+	params.MaxInstructions = limit
+	params.LogOut = &log
+
+	c := New(params)
+	_, _, err := c.Compile(fmt.Sprintf(instrsTemplate, rounds), nil)
+	return c.Stats.Instructions, log.String(), err
+}
+
+func TestInstrStats(t *testing.T) {
+	stats10, _, err := compileInstrs(10, 0)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	stats20, _, err := compileInstrs(20, 0)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	for _, name := range []string{ast.InitFunc, "main", "add"} {
+		if stats10[name] == 0 {
+			t.Errorf("no instructions for %s: %v", name, stats10)
+		}
+	}
+	if len(stats10) != 3 {
+		t.Errorf("unexpected functions: %v", stats10)
+	}
+
+	// Each call to add generates the same instructions.
+	if stats20["add"] != 2*stats10["add"] {
+		t.Errorf("add: got %d instructions for 20 calls, %d for 10 calls",
+			stats20["add"], stats10["add"])
+	}
+	if stats20[ast.InitFunc] != stats10[ast.InitFunc] {
+		t.Errorf("init: got %d and %d instructions",
+			stats20[ast.InitFunc], stats10[ast.InitFunc])
+	}
+	if stats20.Total() <= stats10.Total() {
+		t.Errorf("total: got %d and %d instructions",
+			stats20.Total(), stats10.Total())
+	}
+
+	// The program compiles with its exact instruction count as the
+	// limit.
+	total := stats10.Total()
+	stats, _, err := compileInstrs(10, total)
+	if err != nil {
+		t.Fatalf("compile with limit %d failed: %v", total, err)
+	}
+	if stats.Total() != total {
+		t.Errorf("got %d instructions, expected %d", stats.Total(), total)
+	}
+
+	_, log, err := compileInstrs(10, total-1)
+	if err == nil {
+		t.Fatalf("compile with limit %d succeeded", total-1)
+	}
+	if !strings.Contains(err.Error(), "function main exceeds") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(log, "instruction limit") {
+		t.Errorf("error not logged: %q", log)
+	}
+}
+
+const instrsLoopFunc = `
+package main
+
+func sum(a int32) int32 {
+    var r int32
+    for i := 0; i < 100; i++ {
+        r += a
+    }
+    return r
+}
+
+func main(a, b int32) int32 {
+    return sum(a) + b
+}
+`
+
+func TestInstrLimitFunc(t *testing.T) {
+	params := utils.NewParams()
+	params.NoCircCompile = true
+
+	c := New(params)
+	_, _, err := c.Compile(instrsLoopFunc, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	stats := c.Stats.Instructions
+	if stats["sum"] < 100 {
+		t.Fatalf("sum: got %d instructions", stats["sum"])
+	}
+
+	params.MaxInstructions = stats.Total() - stats["sum"]/2
+	params.LogOut = new(bytes.Buffer)
+	_, _, err = New(params).Compile(instrsLoopFunc, nil)
+	if err == nil {
+		t.Fatalf("compile with limit %d succeeded", params.MaxInstructions)
+	}
+	if !strings.Contains(err.Error(), "function sum exceeds") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ package compiler
 
 import (
 	"bufio"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
@@ -26,6 +27,7 @@ type TokenType int
 const (
 	TIdentifier TokenType = 256 + iota
 	TConstant
+	THexBytes
 	TSymPackage
 	TSymImport
 	TSymFunc
@@ -42,6 +44,8 @@ const (
 	TSymFor
 	TSymRange
 	TSymNil
+	TSymChan
+	TSymDefer
 	TDefAssign
 	TMultEq
 	TDivEq
@@ -71,6 +75,7 @@ const (
 var tokenTypes = map[TokenType]string{
 	TIdentifier:  "identifier",
 	TConstant:    "constant",
+	THexBytes:    "hex literal",
 	TSymPackage:  "package",
 	TSymImport:   "import",
 	TSymFunc:     "func",
@@ -87,6 +92,8 @@ var tokenTypes = map[TokenType]string{
 	TSymFor:      "for",
 	TSymRange:    "range",
 	TSymNil:      "nil",
+	TSymChan:     "chan",
+	TSymDefer:    "defer",
 	TDefAssign:   ":=",
 	TMultEq:      "*=",
 	TDivEq:       "/=",
@@ -181,6 +188,8 @@ var symbols = map[string]TokenType{
 	"for":    TSymFor,
 	"range":  TSymRange,
 	"nil":    TSymNil,
+	"chan":   TSymChan,
+	"defer":  TSymDefer,
 	"else":   TSymElse,
 	// "break":    TSymBreak,
 	// "continue": TSymContinue,
@@ -234,6 +243,9 @@ type Lexer struct {
 	unreadPoint utils.Point
 	history     map[int][]rune
 	lastComment Comment
+	comments    []ast.Comment
+	defines     map[string]string
+	conds       []cond
 }
 
 // NewLexer creates a new lexer for the input.
@@ -316,12 +328,24 @@ func (l *Lexer) Get() (*Token, error) {
 		l.tokenStart = l.point
 		r, _, err := l.ReadRune()
 		if err != nil {
+			if err == io.EOF && len(l.conds) > 0 {
+				return nil, l.errUnterminated()
+			}
 			return nil, err
 		}
 		if unicode.IsSpace(r) {
 			continue
 		}
 		switch r {
+		case '#':
+			if !l.atLineStart() {
+				return nil, l.errUnexpected(r)
+			}
+			if err := l.directive(l.tokenStart); err != nil {
+				return nil, err
+			}
+			continue
+
 		case '%', '(', ')', '{', '}', '[', ']', ',', ';', '.':
 			return l.Token(TokenType(r)), nil
 
@@ -417,6 +441,11 @@ func (l *Lexer) Get() (*Token, error) {
 					comment = append(comment, r)
 				}
 				l.commentLine(string(comment), start)
+				l.comments = append(l.comments, ast.Comment{
+					Point: l.tokenStart,
+					End:   start,
+					Text:  "//" + string(comment),
+				})
 				continue
 
 			case '*':
@@ -440,6 +469,11 @@ func (l *Lexer) Get() (*Token, error) {
 					comment = append(comment, r)
 				}
 				l.commentLine(string(comment), start)
+				l.comments = append(l.comments, ast.Comment{
+					Point: l.tokenStart,
+					End:   l.point,
+					Text:  "/*" + string(comment) + "*/",
+				})
 				continue
 
 			case '=':
@@ -474,6 +508,8 @@ func (l *Lexer) Get() (*Token, error) {
 				}
 				l.UnreadRune()
 				return l.Token(TLshift), nil
+			case '-':
+				return l.Token(TSend), nil
 			default:
 				l.UnreadRune()
 				return l.Token(TLt), nil
@@ -690,6 +726,23 @@ func (l *Lexer) Get() (*Token, error) {
 					token.ConstVal = symbol == "true"
 					return token, nil
 				}
+				if symbol == "hex" {
+					r, _, err := l.ReadRune()
+					if err == nil {
+						if r == '"' {
+							data, err := l.readHexBytes()
+							if err != nil {
+								return nil, err
+							}
+							token := l.Token(THexBytes)
+							token.ConstVal = data
+							return token, nil
+						}
+						l.UnreadRune()
+					} else if err != io.EOF {
+						return nil, err
+					}
+				}
 
 				token := l.Token(TIdentifier)
 				token.StrVal = symbol
@@ -889,6 +942,36 @@ func (l *Lexer) readHexLiteral(val []rune) (*mpa.Int, error) {
 	return ival, nil
 }
 
+// readHexBytes reads the hex digits of the hex"..." byte-array
+// literal and returns the decoded bytes. Whitespace between the
+// digits is ignored.
+func (l *Lexer) readHexBytes() ([]byte, error) {
+	var val []rune
+	for {
+		r, _, err := l.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		if r == '"' {
+			break
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if !unicode.Is(unicode.Hex_Digit, r) {
+			return nil, fmt.Errorf("malformed hex literal: invalid digit '%c'",
+				r)
+		}
+		val = append(val, r)
+	}
+	data, err := hex.DecodeString(string(val))
+	if err != nil {
+		return nil, fmt.Errorf("malformed hex literal '%s': %s",
+			string(val), err)
+	}
+	return data, nil
+}
+
 // Unget pushes the token back to the lexer input stream. The next
 // call to Get will return it.
 func (l *Lexer) Unget(t *Token) {
@@ -938,6 +1021,24 @@ func (l *Lexer) Annotations(loc utils.Point) ast.Annotations {
 	return l.lastComment.Lines
 }
 
+// Comments returns all comments the lexer has seen so far.
+func (l *Lexer) Comments() []ast.Comment {
+	return l.comments
+}
+
+// Text returns the source text of the token. The function returns an
+// empty string if the token spans multiple lines.
+func (l *Lexer) Text(t *Token) string {
+	if t.From.Line != t.To.Line {
+		return ""
+	}
+	line := l.history[t.From.Line]
+	if t.From.Col < 0 || t.From.Col > t.To.Col || t.To.Col > len(line) {
+		return ""
+	}
+	return string(line[t.From.Col:t.To.Col])
+}
+
 func (l *Lexer) errUnexpected(r rune) error {
 	return fmt.Errorf("%s: unexpected character '%s'", l.point, string(r))
 }
@@ -38,3 +38,46 @@ func TestLexer(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerHexBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []byte
+	}{
+		{`hex""`, []byte{}},
+		{`hex"2f8d5570"`, []byte{0x2f, 0x8d, 0x55, 0x70}},
+		{`hex"2F8D 5570"`, []byte{0x2f, 0x8d, 0x55, 0x70}},
+	}
+	for _, test := range tests {
+		lexer := NewLexer("{data}", bytes.NewReader([]byte(test.input)))
+		token, err := lexer.Get()
+		if err != nil {
+			t.Fatalf("%s: Get failed: %v", test.input, err)
+		}
+		if token.Type != THexBytes {
+			t.Fatalf("%s: unexpected token %s", test.input, token.Type)
+		}
+		data := token.ConstVal.([]byte)
+		if !bytes.Equal(data, test.expected) {
+			t.Errorf("%s: got %x, expected %x", test.input, data, test.expected)
+		}
+	}
+
+	for _, input := range []string{`hex"2f8"`, `hex"2g"`, `hex"2f`} {
+		lexer := NewLexer("{data}", bytes.NewReader([]byte(input)))
+		_, err := lexer.Get()
+		if err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+
+	// The hex identifier is not a literal prefix without the quote.
+	lexer := NewLexer("{data}", bytes.NewReader([]byte(`hex + 1`)))
+	token, err := lexer.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if token.Type != TIdentifier || token.StrVal != "hex" {
+		t.Errorf("unexpected token %s", token)
+	}
+}
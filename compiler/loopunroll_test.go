@@ -0,0 +1,127 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+type LoopUnrollTest struct {
+	Name     string
+	Code     string
+	Warnings []string
+}
+
+var reLoopUnroll = regexp.MustCompile(
+	`(?m)^\{data\}:([0-9]+:[0-9]+): warning: (for-loop unrolls .*)$`)
+
+var loopUnrollTests = []LoopUnrollTest{
+	{
+		Name: "under limit",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 0; i < 16; i++ {
+        a += b
+    }
+    return a
+}
+`,
+	},
+	{
+		Name: "over limit",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 0; i < 17; i++ {
+        a += b
+    }
+    return a
+}
+`,
+		Warnings: []string{
+			"4:4 for-loop unrolls 17 times (warning limit 16)",
+		},
+	},
+	{
+		Name: "step",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 100; i >= 0; i -= 3 {
+        a += b
+    }
+    return a
+}
+`,
+		Warnings: []string{
+			"4:4 for-loop unrolls 34 times (warning limit 16)",
+		},
+	},
+	{
+		Name: "nested",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 0; i < 4; i++ {
+        for j := 0; j < i*8; j++ {
+            a += b
+        }
+    }
+    return a
+}
+`,
+		Warnings: []string{
+			"5:8 for-loop unrolls 24 times (warning limit 16)",
+		},
+	},
+	{
+		Name: "unroll limit",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 0; i < 1000000; i++ {
+        return a
+    }
+    return b
+}
+`,
+		Warnings: []string{
+			"4:4 for-loop unrolls more than 64 times, exceeding the unroll limit",
+		},
+	},
+}
+
+func TestLoopUnrollWarning(t *testing.T) {
+	for _, test := range loopUnrollTests {
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+		params.NoCircCompile = true
+		params.MaxLoopUnroll = 64
+		params.LoopUnrollWarn = 16
+
+		_, _, err := New(params).Compile(test.Code, nil)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %s", test.Name, err)
+		}
+		var warnings []string
+		for _, m := range reLoopUnroll.FindAllStringSubmatch(buf.String(),
+			-1) {
+			warnings = append(warnings, m[1]+" "+m[2])
+		}
+		if !reflect.DeepEqual(warnings, test.Warnings) {
+			t.Errorf("%s: got warnings %q, expected %q\n%s",
+				test.Name, warnings, test.Warnings, buf.String())
+		}
+	}
+}
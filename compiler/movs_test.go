@@ -0,0 +1,93 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const movsProgram = `
+package main
+func main(a, b uint16) (uint16, uint16) {
+    x := a
+    y := x
+    z := y
+    c := b
+    d := c
+    var r uint16
+    if z > d {
+        r = z - d
+    } else {
+        r = d - z
+    }
+    s := r
+    t := s
+    return t, z + d
+}
+`
+
+func compileMovs(t *testing.T, coalesce bool) (*ssa.Program, int) {
+	params := utils.NewParams()
+	params.OptCoalesceMovs = coalesce
+	prog, err := New(params).CompileToSSA(movsProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	var movs int
+	for _, step := range prog.Steps {
+		if step.Instr.Op == ssa.Mov {
+			movs++
+		}
+	}
+	return prog, movs
+}
+
+func TestCoalesceMovs(t *testing.T) {
+	plain, plainMovs := compileMovs(t, false)
+	opt, optMovs := compileMovs(t, true)
+	if len(opt.Steps) >= len(plain.Steps) {
+		t.Errorf("got %d steps, expected less than %d",
+			len(opt.Steps), len(plain.Steps))
+	}
+	if optMovs >= plainMovs {
+		t.Errorf("got %d movs, expected less than %d", optMovs, plainMovs)
+	}
+
+	plainCirc, err := New(utils.NewParams()).CompileSSAToCircuit(plain)
+	if err != nil {
+		t.Fatalf("CompileSSAToCircuit failed: %v", err)
+	}
+	optCirc, err := New(utils.NewParams()).CompileSSAToCircuit(opt)
+	if err != nil {
+		t.Fatalf("CompileSSAToCircuit failed: %v", err)
+	}
+	for _, args := range [][]int64{
+		{0, 0}, {7, 3}, {3, 7}, {0xffff, 1}, {1234, 4321},
+	} {
+		a := big.NewInt(args[0])
+		b := big.NewInt(args[1])
+		expected, err := circuit.RunLocal(plainCirc, a, b)
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		got, err := circuit.RunLocal(optCirc, a, b)
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		for i := range expected {
+			if got[i].Cmp(expected[i]) != 0 {
+				t.Errorf("%v: result %d: got %v, expected %v",
+					args, i, got[i], expected[i])
+			}
+		}
+	}
+}
@@ -275,6 +275,24 @@ func (z *Int) Div(x, y *Int) *Int {
 	return z
 }
 
+// Extract sets z to the bits from..to-1 of x and returns z. The type
+// size of z is to-from bits.
+func (z *Int) Extract(x *Int, from, to int) *Int {
+	r := new(big.Int)
+	for i := from; i < to; i++ {
+		if x.Bit(i) == 1 {
+			r.SetBit(r, i-from, 1)
+		}
+	}
+	z.bits = types.Size(to - from)
+	if z.isSmall() {
+		z.setSmall(r.Int64())
+	} else {
+		z.values = r
+	}
+	return z
+}
+
 // Lsh sets z to x<<n and returns z.
 func (z *Int) Lsh(x *Int, n uint) *Int {
 	if z.isSmall() {
@@ -445,6 +463,59 @@ func (z *Int) Sign() int {
 	return z.big().Sign()
 }
 
+// IsNegative tests if the sign bit of z is set in the
+// two's-complement representation of z in its type size. Unlike
+// Sign, IsNegative interprets z as a signed value of its type size.
+func (z *Int) IsNegative() bool {
+	return z.unsigned().Bit(int(z.bits-1)) == 1
+}
+
+// Abs sets z to |x| and returns z. Like in circuits, the absolute
+// value of the most negative value wraps around to itself.
+func (z *Int) Abs(x *Int) *Int {
+	neg := x.IsNegative()
+	z.bits = x.bits
+
+	if z.isSmall() {
+		v := x.small()
+		if neg {
+			v = -v
+		}
+		z.setSmall(v)
+		return z
+	}
+	v := x.unsigned()
+	if neg {
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(z.bits))
+		v.Sub(mod, v)
+		v.And(v, mod.Sub(mod, big.NewInt(1)))
+	}
+	z.values = v
+	return z
+}
+
+// unsigned returns the unsigned two's-complement representation of z
+// in its type size.
+func (z *Int) unsigned() *big.Int {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(z.bits))
+	mask.Sub(mask, big.NewInt(1))
+	return mask.And(mask, z.big())
+}
+
+// Sqrt sets z to floor(sqrt(x)) and returns z. The argument x is
+// interpreted as an unsigned integer like in the integer square root
+// circuit.
+func (z *Int) Sqrt(x *Int) *Int {
+	r := new(big.Int).Sqrt(x.unsigned())
+	z.bits = x.bits
+	if z.isSmall() {
+		z.setSmall(r.Int64())
+	} else {
+		z.values = r
+	}
+	return z
+}
+
 // Sub sets z to x-y and returns z.
 func (z *Int) Sub(x, y *Int) *Int {
 	if z.isSmall() {
@@ -410,3 +410,25 @@ func TestInt64Xor(t *testing.T) {
 		}
 	}
 }
+
+var sqrt64Tests = []struct {
+	a int64
+	r int64
+}{
+	{a: 0, r: 0},
+	{a: 1, r: 1},
+	{a: 15, r: 3},
+	{a: 16, r: 4},
+	{a: 0x7fffffffffffffff, r: 3037000499},
+	{a: -1, r: 0xffffffff},
+}
+
+func TestInt64Sqrt(t *testing.T) {
+	for _, test := range sqrt64Tests {
+		a := NewInt(test.a, 64)
+		r := new(Int).Sqrt(a)
+		if r.Int64() != test.r || r.TypeSize() != 64 {
+			t.Errorf("isqrt(%v)=%v, expected %v\n", test.a, r, test.r)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package mpa
+
+import (
+	"math"
+	"testing"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+type signTest struct {
+	bits types.Size
+	a    int64
+	sign int
+	abs  int64
+}
+
+var signTests = []signTest{
+	{bits: 8, a: 0, sign: 0, abs: 0},
+	{bits: 8, a: 5, sign: 1, abs: 5},
+	{bits: 8, a: -5, sign: -1, abs: 5},
+	{bits: 8, a: math.MaxInt8, sign: 1, abs: math.MaxInt8},
+	{bits: 8, a: math.MinInt8, sign: -1, abs: math.MinInt8},
+	{bits: 32, a: -1, sign: -1, abs: 1},
+	{bits: 32, a: math.MaxInt32, sign: 1, abs: math.MaxInt32},
+	{bits: 32, a: math.MinInt32, sign: -1, abs: math.MinInt32},
+	{bits: 64, a: 0, sign: 0, abs: 0},
+	{bits: 64, a: -42, sign: -1, abs: 42},
+	{bits: 64, a: math.MaxInt64, sign: 1, abs: math.MaxInt64},
+	{bits: 64, a: math.MinInt64, sign: -1, abs: math.MinInt64},
+}
+
+func TestIntSign(t *testing.T) {
+	for idx, test := range signTests {
+		a := NewInt(test.a, test.bits)
+		if a.Sign() != test.sign {
+			t.Errorf("TestIntSign-%v: Sign(%v)=%v, expected %v",
+				idx, test.a, a.Sign(), test.sign)
+		}
+		if a.IsNegative() != (test.sign < 0) {
+			t.Errorf("TestIntSign-%v: IsNegative(%v)=%v",
+				idx, test.a, a.IsNegative())
+		}
+		abs := new(Int).Abs(a)
+		if abs.TypeSize() != int(test.bits) {
+			t.Errorf("TestIntSign-%v: Abs(%v) has %v bits, expected %v",
+				idx, test.a, abs.TypeSize(), test.bits)
+		}
+		if abs.Int64() != test.abs {
+			t.Errorf("TestIntSign-%v: Abs(%v)=%v, expected %v",
+				idx, test.a, abs.Int64(), test.abs)
+		}
+		for i := 0; i < int(test.bits); i++ {
+			expected := uint(uint64(test.a)>>i) & 1
+			if a.Bit(i) != expected {
+				t.Errorf("TestIntSign-%v: Bit(%v, %v)=%v, expected %v",
+					idx, test.a, i, a.Bit(i), expected)
+			}
+		}
+	}
+}
+
+func TestInt128Sign(t *testing.T) {
+	one := NewInt(1, 128)
+	zero := New(128).Lsh(one, 128)
+	minInt := New(128).Lsh(one, 127)
+	maxInt := New(128).Sub(minInt, one)
+	minusOne := New(128).Sub(zero, one)
+
+	tests := []struct {
+		a   *Int
+		neg bool
+		abs string
+	}{
+		{a: zero, neg: false, abs: "0"},
+		{a: one, neg: false, abs: "1"},
+		{a: minusOne, neg: true, abs: "1"},
+		{a: maxInt, neg: false, abs: maxInt.String()},
+		{a: minInt, neg: true, abs: minInt.String()},
+	}
+	for idx, test := range tests {
+		if test.a.IsNegative() != test.neg {
+			t.Errorf("TestInt128Sign-%v: IsNegative(%v)=%v, expected %v",
+				idx, test.a, test.a.IsNegative(), test.neg)
+		}
+		abs := new(Int).Abs(test.a)
+		if abs.String() != test.abs {
+			t.Errorf("TestInt128Sign-%v: Abs(%v)=%v, expected %v",
+				idx, test.a, abs, test.abs)
+		}
+		if (test.a.Bit(127) == 1) != test.neg {
+			t.Errorf("TestInt128Sign-%v: Bit(%v, 127)=%v",
+				idx, test.a, test.a.Bit(127))
+		}
+	}
+
+	// The absolute value of the most negative value matches the
+	// circuit's wraparound in negation.
+	neg := New(128).Sub(zero, minInt)
+	abs := new(Int).Abs(minInt)
+	if abs.Cmp(neg) != 0 {
+		t.Errorf("TestInt128Sign: Abs(%v)=%v, circuit negation %v",
+			minInt, abs, neg)
+	}
+
+	// Negative big values.
+	v, ok := Parse("-170141183460469231731687303715884105728", 10)
+	if !ok {
+		t.Fatalf("Parse failed")
+	}
+	if v.TypeSize() != 128 || v.Sign() != -1 || !v.IsNegative() {
+		t.Errorf("TestInt128Sign: %v: bits=%v, sign=%v",
+			v, v.TypeSize(), v.Sign())
+	}
+	abs = new(Int).Abs(v)
+	if abs.String() != minInt.String() {
+		t.Errorf("TestInt128Sign: Abs(%v)=%v, expected %v", v, abs, minInt)
+	}
+}
+
+func TestIntSignUnsigned(t *testing.T) {
+	// Sign is the sign of the value, IsNegative the sign bit of its
+	// two's-complement representation in the type size.
+	a := NewInt(255, 8)
+	if a.Sign() != 1 {
+		t.Errorf("Sign(%v)=%v, expected 1", a, a.Sign())
+	}
+	if !a.IsNegative() {
+		t.Errorf("IsNegative(%v)=false, expected true", a)
+	}
+	abs := new(Int).Abs(a)
+	if abs.Int64() != 1 {
+		t.Errorf("Abs(%v)=%v, expected 1", a, abs)
+	}
+
+	a = NewInt(-1, 8)
+	if a.Bit(8) != 1 || a.Bit(63) != 1 {
+		t.Errorf("Bit(%v): sign bits are not extended", a)
+	}
+}
@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// TestNarrowingInvalid tests that assignments of values that do not
+// fit into the destination are errors instead of silent truncations.
+func TestNarrowingInvalid(t *testing.T) {
+	tests := []struct {
+		stmts    string
+		location string
+		message  string
+	}{
+		{
+			stmts:    "var x uint8 = a",
+			location: "4:4",
+			message:  "(type uint32) as type uint8",
+		},
+		{
+			stmts:    "var x uint8 = a >> 24",
+			location: "4:4",
+			message:  "(type uint32) as type uint8",
+		},
+		{
+			stmts:    "var x uint8 = 0x100",
+			location: "4:4",
+			message:  "constant 256 overflows uint8 (9 bits needed)",
+		},
+		{
+			stmts:    "x := b\n    x = a",
+			location: "5:4",
+			message:  "cannot assign uint32 to variable of type uint8",
+		},
+		{
+			stmts:    "var x [2]uint8\n    x[b] = a",
+			location: "5:4",
+			message:  "cannot assign uint32 to variable of type uint8",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+func main(a uint32, b uint8) uint8 {
+    %s
+    return b
+}
+`, test.stmts)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", test.stmts)
+			continue
+		}
+		m := reErrorLocation.FindStringSubmatch(log.String())
+		if m == nil {
+			t.Errorf("%q: no error location: %s", test.stmts, log.String())
+			continue
+		}
+		if m[1] != test.location {
+			t.Errorf("%q: got location %s, expected %s",
+				test.stmts, m[1], test.location)
+		}
+		if !strings.Contains(m[2], test.message) {
+			t.Errorf("%q: got error %q, expected %q",
+				test.stmts, m[2], test.message)
+		}
+	}
+}
+
+// TestNarrowingValid tests that the values that provably fit into the
+// destination compile without diagnostics.
+func TestNarrowingValid(t *testing.T) {
+	code := `
+package main
+const C uint32 = 0xff
+func main(a uint32, b uint8) (uint8, uint8, uint8) {
+    var x uint8 = C
+    y := b
+    y = 0x7f
+    var z uint8 = uint8(a)
+    return x, y, z
+}
+`
+	var log bytes.Buffer
+	params := utils.NewParams()
+	params.LogOut = &log
+
+	circ, _, err := New(params).Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if log.Len() != 0 {
+		t.Errorf("unexpected diagnostics: %s", log.String())
+	}
+	results, err := circ.Compute([]*big.Int{
+		big.NewInt(0x12345678),
+		big.NewInt(0),
+	})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	for idx, expected := range []int64{0xff, 0x7f, 0x78} {
+		if results[idx].Int64() != expected {
+			t.Errorf("result %d: got %v, expected %v",
+				idx, results[idx], expected)
+		}
+	}
+}
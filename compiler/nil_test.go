@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const nilProgram = `
+package main
+
+type Point struct {
+    X, Y int32
+}
+
+func main(a, b int32) int32 {
+    var p *int32 = nil
+    var q *int32
+    var r int32
+
+    if p != nil {
+        r |= 1
+    }
+    if q == nil {
+        r |= 2
+    }
+    if p == q {
+        r |= 4
+    }
+    p = &a
+    if p != nil && nil != p {
+        r |= 8
+    }
+    if p == &a {
+        r |= 16
+    }
+    q = &b
+    if p == q {
+        r |= 32
+    }
+
+    var pt Point
+    var pp *Point = &pt
+    if pp != nil {
+        pp.X = a
+        r |= 64
+    }
+    return r + pt.X
+}
+`
+
+func TestNil(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).Compile(nilProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	results, err := circ.Compute([]*big.Int{
+		big.NewInt(1000),
+		big.NewInt(2000),
+	})
+	if err != nil {
+		t.Fatalf("compute failed: %v", err)
+	}
+	expected := int64(2 | 4 | 8 | 16 | 64 + 1000)
+	if results[0].Int64() != expected {
+		t.Errorf("got %v, expected %v", results[0], expected)
+	}
+}
+
+func TestNilInvalid(t *testing.T) {
+	for _, input := range []string{
+		`
+package main
+func main(a int32, b uint8) bool {
+    return &a == &b
+}`,
+		`
+package main
+func main(a int32) bool {
+    return &a < nil
+}`,
+		`
+package main
+func main(a int32) int32 {
+    var p *int32
+    *p = a
+    return a
+}`,
+		`
+package main
+func main(a int32) int32 {
+    var p *int32 = a
+    return a
+}`,
+	} {
+		_, _, err := New(utils.NewParams()).Compile(input, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", input)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+const multiOutput = `
+package main
+func main(a, b uint32) (uint32, uint32, bool) {
+    return a * b, a + b, a < b
+}
+`
+
+func TestEvaluateOutputs(t *testing.T) {
+	full, _, err := New(utils.NewParams()).Compile(multiOutput, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	params := utils.NewParams()
+	params.OutputIndices = []int{1}
+	circ, _, err := New(params).Compile(multiOutput, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if len(circ.Outputs) != 1 || circ.Outputs[0].Type.Bits != 32 {
+		t.Fatalf("invalid outputs: %v", circ.Outputs)
+	}
+	if circ.NumGates >= full.NumGates {
+		t.Errorf("selected output circuit not smaller: %d >= %d gates",
+			circ.NumGates, full.NumGates)
+	}
+
+	a := big.NewInt(0xfffffff0)
+	b := big.NewInt(0x20)
+	result, err := circ.Compute([]*big.Int{a, b})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if result[0].Int64() != 0x10 {
+		t.Errorf("a+b: got %v, expected %v", result[0], 0x10)
+	}
+
+	params.OutputIndices = []int{2, 0}
+	circ, _, err = New(params).Compile(multiOutput, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	result, err = circ.Compute([]*big.Int{a, b})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if len(result) != 2 || result[0].Int64() != 0 ||
+		result[1].Int64() != 0xfffffe00 {
+		t.Errorf("a<b, a*b: got %v, expected [0 %v]", result, 0xfffffe00)
+	}
+}
+
+func TestEvaluateOutputsStream(t *testing.T) {
+	params := utils.NewParams()
+	params.OutputIndices = []int{2}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		outputs circuit.IO
+		values  []*big.Int
+		err     error
+	}
+	ch := make(chan result)
+	go func() {
+		outputs, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"7"}, false)
+		ch <- result{
+			outputs: outputs,
+			values:  values,
+			err:     err,
+		}
+	}()
+	_, values, err := New(params).stream(context.Background(), gConn,
+		ot.NewCO(), "{data}", strings.NewReader(multiOutput),
+		[]string{"5"}, nil)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	if len(eResult.outputs) != 1 || len(values) != 1 ||
+		values[0].Int64() != 1 || eResult.values[0].Int64() != 1 {
+		t.Errorf("a<b: got %v (%v), expected [1]", values, eResult.values)
+	}
+}
+
+const twoOutputs = `
+package main
+func main(a, b uint32) (uint32, uint32) {
+    return a + b, a * b
+}
+`
+
+func TestOutputModesStream(t *testing.T) {
+	params := utils.NewParams()
+	params.OutputModes = map[int]circuit.OutputMode{
+		0: circuit.OutputReveal,
+		1: circuit.OutputShare,
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		outputs circuit.IO
+		values  []*big.Int
+		err     error
+	}
+	ch := make(chan result)
+	go func() {
+		outputs, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"7"}, false)
+		ch <- result{
+			outputs: outputs,
+			values:  values,
+			err:     err,
+		}
+	}()
+	outputs, values, err := New(params).stream(context.Background(),
+		gConn, ot.NewCO(), "{data}", strings.NewReader(twoOutputs),
+		[]string{"5"}, nil)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	for _, io := range []circuit.IO{outputs, eResult.outputs} {
+		if len(io) != 2 || io[0].Mode != circuit.OutputReveal ||
+			io[1].Mode != circuit.OutputShare {
+			t.Fatalf("invalid output modes: %v", io)
+		}
+	}
+	if values[0].Int64() != 12 || eResult.values[0].Int64() != 12 {
+		t.Errorf("a+b: got %v (%v), expected 12",
+			values[0], eResult.values[0])
+	}
+	product := new(big.Int).Xor(values[1], eResult.values[1])
+	if product.Int64() != 35 {
+		t.Errorf("a*b: got %v^%v=%v, expected 35",
+			values[1], eResult.values[1], product)
+	}
+}
+
+func TestOutputModesInvalid(t *testing.T) {
+	for _, modes := range []map[int]circuit.OutputMode{
+		{2: circuit.OutputShare},
+		{-1: circuit.OutputShare},
+		{0: circuit.OutputMode(7)},
+	} {
+		params := utils.NewParams()
+		params.OutputModes = modes
+
+		gConn, eConn := p2p.Pipe()
+		_, _, err := New(params).stream(context.Background(), gConn,
+			ot.NewCO(), "{data}", strings.NewReader(twoOutputs),
+			[]string{"5"}, nil)
+		if err == nil {
+			t.Errorf("stream succeeded with output modes %v", modes)
+		}
+		gConn.Close()
+		eConn.Close()
+	}
+}
+
+func TestEvaluateOutputsInvalid(t *testing.T) {
+	for _, indices := range [][]int{{}, {3}, {-1}, {0, 0}} {
+		params := utils.NewParams()
+		params.OutputIndices = indices
+		_, _, err := New(params).Compile(multiOutput, nil)
+		if err == nil {
+			t.Errorf("Compile succeeded with output indices %v", indices)
+		}
+	}
+}
+
+const outputsProgram = `
+package main
+func main(a, b uint8) (uint8, uint8) {
+    return a + b, a * b
+}
+`
+
+// reclaimOutput compiles outputsProgram and breaks it by reclaiming
+// the wires of its first return value before the Ret instruction.
+func reclaimOutput(t *testing.T, params *utils.Params) *ssa.Program {
+	prog, err := New(params).CompileToSSA(outputsProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	last := len(prog.Steps) - 1
+	ret := prog.Steps[last]
+	if ret.Instr.Op != ssa.Ret {
+		t.Fatalf("last instruction is %s, expected %s", ret.Instr.Op, ssa.Ret)
+	}
+	prog.Steps = append(prog.Steps[:last], ssa.Step{
+		Instr: ssa.NewGCInstr(ret.Instr.In[0]),
+	}, ret)
+	return prog
+}
+
+func TestOutputsReclaimedStream(t *testing.T) {
+	params := utils.NewParams()
+	prog := reclaimOutput(t, params)
+
+	gConn, eConn := p2p.Pipe()
+	ch := make(chan error)
+	go func() {
+		_, _, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"3"}, false)
+		ch <- err
+	}()
+	_, _, err := prog.Stream(gConn, ot.NewCO(), params, big.NewInt(5),
+		circuit.NewTiming())
+	eErr := <-ch
+	gConn.Close()
+	eConn.Close()
+	if err == nil {
+		t.Fatalf("Stream succeeded with reclaimed output")
+	}
+	if !strings.Contains(err.Error(), "output %ret0") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if eErr == nil {
+		t.Errorf("StreamEvaluator succeeded with reclaimed output")
+	}
+}
+
+func TestOutputsUndefinedCircuit(t *testing.T) {
+	params := utils.NewParams()
+	prog, err := New(params).CompileToSSA(outputsProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	// Return a value that the program does not define.
+	ret := &prog.Steps[len(prog.Steps)-1].Instr
+	in := make([]ssa.Value, len(ret.In))
+	copy(in, ret.In)
+	in[1].Name = "undefined"
+	ret.In = in
+
+	_, err = New(params).CompileSSAToCircuit(prog)
+	if err == nil {
+		t.Fatalf("CompileSSAToCircuit succeeded with undefined output")
+	}
+	if !strings.Contains(err.Error(), "return value undefined") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/mpa"
 	"github.com/markkurossi/mpc/compiler/utils"
 )
 
@@ -23,26 +24,34 @@ type Parser struct {
 	logger   *utils.Logger
 	lexer    *Lexer
 	pkg      *ast.Package
+	errLoc   utils.Point
+	lastErr  error
+	errors   []error
 }
 
 // NewParser creates a new parser.
 func NewParser(source string, compiler *Compiler, logger *utils.Logger,
 	in io.Reader) *Parser {
+	lexer := NewLexer(source, in)
+	if compiler != nil {
+		lexer.SetDefines(compiler.params.Defines)
+	}
 	return &Parser{
 		compiler: compiler,
 		logger:   logger,
-		lexer:    NewLexer(source, in),
+		lexer:    lexer,
 	}
 }
 
 // Parse parses a package.
 func (p *Parser) Parse(pkg *ast.Package) (*ast.Package, error) {
-	name, doc, err := p.parsePackage()
+	name, loc, doc, err := p.parsePackage()
 	if err != nil {
 		return nil, err
 	}
 	if pkg == nil {
 		p.pkg = ast.NewPackage(name, p.lexer.Source(), doc)
+		p.pkg.Clause = loc
 	} else {
 		// This source file must be in the same package.
 		if name != pkg.Name {
@@ -56,6 +65,7 @@ func (p *Parser) Parse(pkg *ast.Package) (*ast.Package, error) {
 		if err != io.EOF {
 			return nil, err
 		}
+		p.pkg.Comments = append(p.pkg.Comments, p.lexer.Comments()...)
 		return p.pkg, nil
 	}
 	if token.Type == TSymImport {
@@ -109,13 +119,115 @@ func (p *Parser) Parse(pkg *ast.Package) (*ast.Package, error) {
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, err
+			if !p.recoverable(err) {
+				return nil, p.combinedError(err)
+			}
+			err = p.skipToplevel()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, p.combinedError(err)
+			}
 		}
 	}
+	if len(p.errors) > 0 {
+		return nil, p.combinedError(nil)
+	}
+	p.pkg.Comments = append(p.pkg.Comments, p.lexer.Comments()...)
 
 	return p.pkg, nil
 }
 
+// recoverable tests if the parser can continue after the error err.
+// The syntax errors are recoverable if the AllErrors parameter is
+// set. The function records the recoverable errors for the combined
+// error.
+func (p *Parser) recoverable(err error) bool {
+	if p.compiler == nil || !p.compiler.params.AllErrors ||
+		err != p.lastErr {
+		return false
+	}
+	p.errors = append(p.errors, fmt.Errorf("%s: %w", p.errLoc, err))
+	return true
+}
+
+// combinedError returns the recorded syntax errors and the error err
+// as one error. If only err is set, the function returns it as-is.
+func (p *Parser) combinedError(err error) error {
+	if len(p.errors) == 0 {
+		return err
+	}
+	errs := p.errors
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// skipToplevel skips the input after a syntax error to the next
+// top-level declaration. The declarations are recognized by their
+// keywords at the beginning of the line.
+func (p *Parser) skipToplevel() error {
+	p.dropErrorToken()
+	for {
+		t, err := p.lexer.Get()
+		if err != nil {
+			return err
+		}
+		if t.From.Col != 0 {
+			continue
+		}
+		switch t.Type {
+		case TSymConst, TSymVar, TSymType, TSymFunc:
+			p.lexer.Unget(t)
+			return nil
+		}
+	}
+}
+
+// skipStatement skips the input after a syntax error to the next
+// statement. The rest of the erroneous line is already skipped by
+// errf so the function skips only the blocks that were opened on the
+// line. The function returns true if the erroneous line closed the
+// current block.
+func (p *Parser) skipStatement() (bool, error) {
+	p.dropErrorToken()
+
+	var depth int
+	line := p.lexer.history[p.errLoc.Line]
+	if p.errLoc.Col < len(line) {
+		for _, r := range line[p.errLoc.Col:] {
+			switch r {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+		}
+	}
+	for depth > 0 {
+		t, err := p.lexer.Get()
+		if err != nil {
+			return false, err
+		}
+		switch t.Type {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return depth < 0, nil
+}
+
+// dropErrorToken drops the pushed back token if it is on the
+// erroneous line that errf has skipped.
+func (p *Parser) dropErrorToken() {
+	if p.lexer.ungot != nil && p.lexer.ungot.From.Line == p.errLoc.Line {
+		p.lexer.ungot = nil
+	}
+}
+
 var leaves = map[string]bool{
 	"errorLoc":      true,
 	"errf":          true,
@@ -150,6 +262,9 @@ func (p *Parser) errorLoc(msg string) {
 
 func (p *Parser) errf(loc utils.Point, format string, a ...interface{}) error {
 	msg := fmt.Sprintf(format, a...)
+	err := errors.New(msg)
+	p.errLoc = loc
+	p.lastErr = err
 
 	p.errorLoc(msg)
 
@@ -171,10 +286,10 @@ func (p *Parser) errf(loc utils.Point, format string, a ...interface{}) error {
 		p.logger.Errorf(loc, "%s\n%s\n%s\n",
 			msg, string(line), string(indicator))
 
-		return errors.New(msg)
+		return err
 	}
 	p.logger.Errorf(loc, "%s", msg)
-	return errors.New(msg)
+	return err
 }
 
 func (p *Parser) errUnexpected(offending *Token, expected TokenType) error {
@@ -203,17 +318,20 @@ func (p *Parser) sameLine(current utils.Point) bool {
 	return t.From.Line == current.Line
 }
 
-func (p *Parser) parsePackage() (string, ast.Annotations, error) {
+func (p *Parser) parsePackage() (string, utils.Point, ast.Annotations,
+	error) {
+
 	t, err := p.needToken(TSymPackage)
 	if err != nil {
-		return "", nil, err
+		return "", utils.Point{}, nil, err
 	}
+	loc := t.From
 	t, err = p.needToken(TIdentifier)
 	if err != nil {
-		return "", nil, err
+		return "", utils.Point{}, nil, err
 	}
 	parts := strings.Split(t.StrVal, "/")
-	return parts[len(parts)-1], p.lexer.Annotations(t.From), nil
+	return parts[len(parts)-1], loc, p.lexer.Annotations(t.From), nil
 }
 
 func (p *Parser) parseToplevel() error {
@@ -349,9 +467,10 @@ func (p *Parser) parseGlobalVar(isConst bool,
 	}
 	switch token.Type {
 	case TIdentifier:
-		return p.parseGlobalVarDef(token, isConst, annotations)
+		return p.parseGlobalVarDef(token, isConst, annotations, utils.Point{})
 
 	case '(':
+		group := token.From
 		for {
 			t, err := p.lexer.Get()
 			if err != nil {
@@ -361,7 +480,7 @@ func (p *Parser) parseGlobalVar(isConst bool,
 				return nil
 			}
 			err = p.parseGlobalVarDef(t, isConst,
-				p.lexer.Annotations(t.From))
+				p.lexer.Annotations(t.From), group)
 			if err != nil {
 				return err
 			}
@@ -373,7 +492,7 @@ func (p *Parser) parseGlobalVar(isConst bool,
 }
 
 func (p *Parser) parseGlobalVarDef(token *Token, isConst bool,
-	annotations ast.Annotations) error {
+	annotations ast.Annotations, group utils.Point) error {
 
 	if token.Type != TIdentifier {
 		return p.errf(token.From, "unexpected token '%s'", token.Type)
@@ -418,6 +537,7 @@ func (p *Parser) parseGlobalVarDef(token *Token, isConst bool,
 			Type:        varType,
 			Init:        init,
 			Annotations: annotations,
+			Group:       group,
 		})
 	} else {
 		p.pkg.Variables = append(p.pkg.Variables, &ast.VariableDef{
@@ -426,6 +546,7 @@ func (p *Parser) parseGlobalVarDef(token *Token, isConst bool,
 			Type:        varType,
 			Init:        init,
 			Annotations: annotations,
+			Group:       group,
 		})
 	}
 
@@ -444,50 +565,9 @@ func (p *Parser) parseTypeDecl(annotations ast.Annotations) error {
 	switch t.Type {
 	case TSymStruct:
 		loc := t.From
-		_, err := p.needToken('{')
+		fields, err := p.parseStructFields()
 		if err != nil {
-		}
-		var fields []ast.StructField
-		for {
-			t, err := p.lexer.Get()
-			if err != nil {
-				return err
-			}
-			if t.Type == '}' {
-				break
-			}
-			var names []*Token
-			for {
-				if t.Type != TIdentifier {
-					return p.errf(t.From, "unexpected token '%s'", t.Type)
-				}
-				names = append(names, t)
-				t, err = p.lexer.Get()
-				if err != nil {
-					return err
-				}
-				if t.Type != ',' {
-					p.lexer.Unget(t)
-					break
-				}
-				t, err = p.lexer.Get()
-				if err != nil {
-					return err
-				}
-			}
-
-			typeInfo, err := p.parseType()
-			if err != nil {
-				return err
-			}
-			// Expand names.
-			for _, n := range names {
-				fields = append(fields, ast.StructField{
-					Point: n.From,
-					Name:  n.StrVal,
-					Type:  typeInfo,
-				})
-			}
+			return err
 		}
 		typeInfo := &ast.TypeInfo{
 			Point:        loc,
@@ -730,7 +810,18 @@ func (p *Parser) parseBlock() (ast.List, utils.Point, error) {
 
 		ast, err := p.parseStatement(false)
 		if err != nil {
-			return nil, utils.Point{}, err
+			if !p.recoverable(err) {
+				return nil, utils.Point{}, err
+			}
+			closed, err := p.skipStatement()
+			if err != nil {
+				return nil, utils.Point{}, err
+			}
+			if closed {
+				end = p.errLoc
+				break
+			}
+			continue
 		}
 		result = append(result, ast)
 	}
@@ -865,6 +956,20 @@ func (p *Parser) parseStatement(needLBrace bool) (ast.AST, error) {
 			Exprs: exprs,
 		}, nil
 
+	case TSymDefer:
+		stmt, err := p.parseStatement(needLBrace)
+		if err != nil {
+			return nil, err
+		}
+		switch stmt.(type) {
+		case *ast.Return, *ast.Defer:
+			return nil, p.errf(stmt.Location(), "%s can't be deferred", stmt)
+		}
+		return &ast.Defer{
+			Point: tStmt.From,
+			Stmt:  stmt,
+		}, nil
+
 		// ForStmt = "for" [ Condition | ForClause | RangeClause ] Block .
 		// Condition = Expression .
 	case TSymFor:
@@ -873,6 +978,17 @@ func (p *Parser) parseStatement(needLBrace bool) (ast.AST, error) {
 		if err != nil {
 			return nil, err
 		}
+		if n.Type == '{' {
+			// for Block
+			body, _, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			return &ast.For{
+				Point: tStmt.From,
+				Body:  body,
+			}, nil
+		}
 		p.lexer.Unget(n)
 		if n.Type != ';' {
 			// init | Condition | RangeClause
@@ -1038,6 +1154,20 @@ func (p *Parser) parseStatement(needLBrace bool) (ast.AST, error) {
 				},
 			}, nil
 
+		case TSend:
+			if len(lvalues) != 1 {
+				return nil, p.errf(tStmt.From, "expected 1 expression")
+			}
+			value, err := p.parseExpr(needLBrace)
+			if err != nil {
+				return nil, err
+			}
+			return &ast.Send{
+				Point: t.From,
+				Chan:  lvalues[0],
+				Value: value,
+			}, nil
+
 		case TPlusPlus, TMinusMinus:
 			if len(lvalues) != 1 {
 				return nil, p.errf(tStmt.From, "expected 1 expression")
@@ -1355,26 +1485,55 @@ primary:
 					return nil, p.errUnexpected(n, ':')
 				}
 			}
+			var expr3 ast.AST
 			n, err = p.lexer.Get()
 			if err != nil {
 				return nil, err
 			}
+			if n.Type == ':' {
+				return nil, p.errf(n.From,
+					"middle index required in 3-index slice")
+			}
 			if n.Type != ']' {
 				p.lexer.Unget(n)
 				expr2, err = p.parseExpr(needLBrace)
 				if err != nil {
 					return nil, err
 				}
-				_, err = p.needToken(']')
+				n, err = p.lexer.Get()
 				if err != nil {
 					return nil, err
 				}
+				if n.Type == ':' {
+					// Three-index slice.
+					n, err = p.lexer.Get()
+					if err != nil {
+						return nil, err
+					}
+					if n.Type == ']' {
+						return nil, p.errf(n.From,
+							"final index required in 3-index slice")
+					}
+					p.lexer.Unget(n)
+					expr3, err = p.parseExpr(needLBrace)
+					if err != nil {
+						return nil, err
+					}
+					_, err = p.needToken(']')
+					if err != nil {
+						return nil, err
+					}
+				} else if n.Type != ']' {
+					p.lexer.Unget(n)
+					return nil, p.errUnexpected(n, ']')
+				}
 			}
 			primary = &ast.Slice{
 				Point: primary.Location(),
 				Expr:  primary,
 				From:  expr1,
 				To:    expr2,
+				Max:   expr3,
 			}
 
 		case '(':
@@ -1484,6 +1643,7 @@ func (p *Parser) parseOperand(needLBrace bool) (ast.AST, error) {
 		return &ast.BasicLit{
 			Point: t.From,
 			Value: t.ConstVal,
+			Raw:   p.lexer.Text(t),
 		}, nil
 
 	case TSymNil:
@@ -1491,6 +1651,32 @@ func (p *Parser) parseOperand(needLBrace bool) (ast.AST, error) {
 			Point: t.From,
 		}, nil
 
+	case THexBytes: // Hex byte-array literal
+		lit := &ast.CompositeLit{
+			Point: t.From,
+			Type: &ast.TypeInfo{
+				Point: t.From,
+				Type:  ast.TypeSlice,
+				ElementType: &ast.TypeInfo{
+					Point: t.From,
+					Type:  ast.TypeName,
+					Name: ast.Identifier{
+						Defined: p.pkg.Name,
+						Name:    "byte",
+					},
+				},
+			},
+		}
+		for _, b := range t.ConstVal.([]byte) {
+			lit.Value = append(lit.Value, ast.KeyedElement{
+				Element: &ast.BasicLit{
+					Point: t.From,
+					Value: int64(b),
+				},
+			})
+		}
+		return lit, nil
+
 	case TIdentifier: // OperandName
 		n, err := p.lexer.Get()
 		if err != nil {
@@ -1511,6 +1697,19 @@ func (p *Parser) parseOperand(needLBrace bool) (ast.AST, error) {
 					Name:    id.StrVal,
 				},
 			}
+		} else if n.Type == TLt && t.StrVal == "fixed" {
+			// Fixed-point type name in conversions.
+			name, err := p.parseFixedName()
+			if err != nil {
+				return nil, err
+			}
+			operandName = &ast.VariableRef{
+				Point: t.From,
+				Name: ast.Identifier{
+					Defined: p.pkg.Name,
+					Name:    name,
+				},
+			}
 		} else {
 			// Identifier in current package.
 			p.lexer.Unget(n)
@@ -1561,6 +1760,27 @@ func (p *Parser) parseOperand(needLBrace bool) (ast.AST, error) {
 			return nil, p.errf(n.From, "unexpected token '%s'", n.Type)
 		}
 
+	case TSymStruct: // StructType LiteralValue
+		p.lexer.Unget(t)
+		typeInfo, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.lexer.Get()
+		if err != nil {
+			return nil, err
+		}
+		switch n.Type {
+		case '{':
+			return p.parseCompositeLit(typeInfo)
+
+		case '(':
+			return p.parseArrayCast(n.From, typeInfo)
+
+		default:
+			return nil, p.errf(n.From, "unexpected token '%s'", n.Type)
+		}
+
 	case '(': // '(' Expression ')'
 		expr, err := p.parseExpr(false)
 		if err != nil {
@@ -1570,6 +1790,9 @@ func (p *Parser) parseOperand(needLBrace bool) (ast.AST, error) {
 		if err != nil {
 			return nil, err
 		}
+		if b, ok := expr.(*ast.Binary); ok {
+			b.Paren = true
+		}
 		return expr, nil
 
 	default:
@@ -1704,6 +1927,86 @@ func (p *Parser) parseCompositeLitValue(typeInfo *ast.TypeInfo) (
 // Type      = TypeName | TypeLit | "(" Type ")" .
 // TypeName  = identifier | QualifiedIdent .
 // TypeLit   = ArrayType | StructType | PointerType | SliceType .
+// parseFixedName parses the fixed-point type parameters <I,F>
+// following the fixed type name and returns the canonical type name.
+func (p *Parser) parseFixedName() (string, error) {
+	var params [2]int64
+	for idx := range params {
+		if idx > 0 {
+			_, err := p.needToken(',')
+			if err != nil {
+				return "", err
+			}
+		}
+		t, err := p.needToken(TConstant)
+		if err != nil {
+			return "", err
+		}
+		val, ok := t.ConstVal.(*mpa.Int)
+		if !ok {
+			return "", p.errf(t.From,
+				"invalid fixed-point type parameter: %v", t.ConstVal)
+		}
+		params[idx] = val.Int64()
+	}
+	_, err := p.needToken(TGt)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("fixed<%d,%d>", params[0], params[1]), nil
+}
+
+// parseStructFields parses the field declarations of a struct type.
+func (p *Parser) parseStructFields() ([]ast.StructField, error) {
+	_, err := p.needToken('{')
+	if err != nil {
+		return nil, err
+	}
+	var fields []ast.StructField
+	for {
+		t, err := p.lexer.Get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == '}' {
+			break
+		}
+		var names []*Token
+		for {
+			if t.Type != TIdentifier {
+				return nil, p.errf(t.From, "unexpected token '%s'", t.Type)
+			}
+			names = append(names, t)
+			t, err = p.lexer.Get()
+			if err != nil {
+				return nil, err
+			}
+			if t.Type != ',' {
+				p.lexer.Unget(t)
+				break
+			}
+			t, err = p.lexer.Get()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		typeInfo, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		// Expand names.
+		for _, n := range names {
+			fields = append(fields, ast.StructField{
+				Point: n.From,
+				Name:  n.StrVal,
+				Type:  typeInfo,
+			})
+		}
+	}
+	return fields, nil
+}
+
 func (p *Parser) parseType() (*ast.TypeInfo, error) {
 	t, err := p.lexer.Get()
 	if err != nil {
@@ -1731,6 +2034,19 @@ func (p *Parser) parseType() (*ast.TypeInfo, error) {
 				} else {
 					p.lexer.Unget(n)
 				}
+			} else if n.Type == TLt && t.StrVal == "fixed" {
+				name, err = p.parseFixedName()
+				if err != nil {
+					return nil, err
+				}
+				return &ast.TypeInfo{
+					Point: loc,
+					Type:  ast.TypeName,
+					Name: ast.Identifier{
+						Defined: p.pkg.Name,
+						Name:    name,
+					},
+				}, nil
 			} else {
 				p.lexer.Unget(n)
 			}
@@ -1798,8 +2114,114 @@ func (p *Parser) parseType() (*ast.TypeInfo, error) {
 			ElementType: elType,
 		}, nil
 
+	case TSymStruct:
+		fields, err := p.parseStructFields()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.TypeInfo{
+			Point:        t.From,
+			Type:         ast.TypeStruct,
+			StructFields: fields,
+		}, nil
+
+	case TSymChan:
+		elType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.TypeInfo{
+			Point:       t.From,
+			Type:        ast.TypeChan,
+			ElementType: elType,
+		}, nil
+
+	case TSymFunc:
+		return p.parseFuncType(t.From)
+
 	default:
 		return nil, p.errf(t.From,
 			"unexpected token '%s' while parsing type", t)
 	}
 }
+
+// parseFuncType parses the function type signature after the func
+// keyword:
+//
+//	FunctionType = "func" "(" [ Type { "," Type } ] ")" [ Result ] .
+//	Result       = Type | "(" Type { "," Type } ")" .
+func (p *Parser) parseFuncType(loc utils.Point) (*ast.TypeInfo, error) {
+	_, err := p.needToken('(')
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parseTypeList()
+	if err != nil {
+		return nil, err
+	}
+	result := &ast.TypeInfo{
+		Point:    loc,
+		Type:     ast.TypeFunc,
+		FuncArgs: args,
+	}
+
+	t, err := p.lexer.Get()
+	if err != nil {
+		if err == io.EOF {
+			return result, nil
+		}
+		return nil, err
+	}
+	switch t.Type {
+	case '(':
+		result.FuncReturn, err = p.parseTypeList()
+		if err != nil {
+			return nil, err
+		}
+
+	case TIdentifier, '[', '*', TSymStruct, TSymChan, TSymFunc:
+		p.lexer.Unget(t)
+		ret, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		result.FuncReturn = []*ast.TypeInfo{ret}
+
+	default:
+		p.lexer.Unget(t)
+	}
+	return result, nil
+}
+
+// parseTypeList parses a comma-separated list of types until the
+// closing parenthesis.
+func (p *Parser) parseTypeList() ([]*ast.TypeInfo, error) {
+	var result []*ast.TypeInfo
+
+	t, err := p.lexer.Get()
+	if err != nil {
+		return nil, err
+	}
+	if t.Type == ')' {
+		return result, nil
+	}
+	p.lexer.Unget(t)
+	for {
+		ti, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ti)
+
+		t, err = p.lexer.Get()
+		if err != nil {
+			return nil, err
+		}
+		if t.Type == ')' {
+			return result, nil
+		}
+		if t.Type != ',' {
+			return nil, p.errUnexpected(t, ',')
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markkurossi/mpc/compiler/utils"
@@ -127,3 +128,61 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+const allErrorsInput = `package main
+
+func main(a, b int32) int32 {
+    x := a + ) b
+    if a > b {
+        x = ]
+    }
+    return x + b
+}
+
+func add(a, b int32 int32 {
+    return a + b
+}
+
+var z int32 = }
+
+func sub(a, b int32) int32 {
+    return a - b
+}
+`
+
+func TestParserAllErrors(t *testing.T) {
+	expected := []string{
+		"{data}:4:13: ",
+		"{data}:6:12: ",
+		"{data}:11:20: ",
+		"{data}:15:14: ",
+	}
+	for _, allErrors := range []bool{false, true} {
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.AllErrors = allErrors
+		logger := utils.NewLogger(&log)
+
+		parser := NewParser("{data}", New(params), logger,
+			bytes.NewReader([]byte(allErrorsInput)))
+		_, err := parser.Parse(nil)
+		if err == nil {
+			t.Fatalf("AllErrors=%v: Parse succeeded", allErrors)
+		}
+		count := 1
+		if allErrors {
+			count = len(expected)
+		}
+		for idx, loc := range expected {
+			reported := strings.Contains(log.String(), loc)
+			if reported != (idx < count) {
+				t.Errorf("AllErrors=%v: error %s reported=%v:\n%s",
+					allErrors, loc, reported, log.String())
+			}
+			if reported != strings.Contains(err.Error(), loc) && allErrors {
+				t.Errorf("AllErrors=%v: error %s not in %q",
+					allErrors, loc, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestPolyConstFold(t *testing.T) {
+	folded := `
+package main
+func main(a, b uint32) uint32 {
+    c := uint32(10)
+    return a + poly(c, 7, 2, 3)
+}
+`
+	literal := `
+package main
+func main(a, b uint32) uint32 {
+    return a + 327
+}
+`
+	fc, _, err := New(utils.NewParams()).Compile(folded, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	lc, _, err := New(utils.NewParams()).Compile(literal, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	if fc.NumGates != lc.NumGates {
+		t.Errorf("constant poly not folded: got %d gates, expected %d",
+			fc.NumGates, lc.NumGates)
+	}
+}
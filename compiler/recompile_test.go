@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const recompileProgram = `
+package main
+
+import (
+	"math"
+)
+
+var bias uint16 = math.MaxUint8
+
+func main(a []uint8, b uint8) uint16 {
+	var sum uint16
+	for i := 0; i < len(a); i++ {
+		sum += uint16(a[i] ^ b)
+	}
+	return sum + bias
+}
+`
+
+func TestRecompileInputSizes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sum.mpcl")
+	err := os.WriteFile(file, []byte(recompileProgram), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc := New(utils.NewParams())
+	var parsed int
+
+	for idx, count := range []int{2, 4, 2, 7} {
+		circ, _, err := cc.CompileFile(file, [][]int{{count * 8}, {8}})
+		if err != nil {
+			t.Fatalf("compile %d failed: %s", idx, err)
+		}
+		if idx == 0 {
+			parsed = cc.Stats.Parsed
+			if parsed < 2 {
+				t.Fatalf("expected main and imported files parsed, got %v",
+					parsed)
+			}
+		} else if cc.Stats.Parsed != parsed {
+			t.Errorf("compile %d parsed %d files", idx,
+				cc.Stats.Parsed-parsed)
+		}
+		if cc.Stats.Reused != idx {
+			t.Errorf("compile %d: reused=%v, expected %v",
+				idx, cc.Stats.Reused, idx)
+		}
+
+		a := new(big.Int)
+		b := int64(0x0f)
+		expected := int64(0xff)
+		for i := 0; i < count; i++ {
+			v := int64(i*37 + 1)
+			a.Or(a, new(big.Int).Lsh(big.NewInt(v), uint(i*8)))
+			expected += v ^ b
+		}
+		results, err := circ.Compute([]*big.Int{a, big.NewInt(b)})
+		if err != nil {
+			t.Fatalf("compute %d failed: %s", idx, err)
+		}
+		if results[0].Int64() != expected {
+			t.Errorf("compile %d: got %v, expected %v",
+				idx, results[0], expected)
+		}
+	}
+
+	// The third compilation used the SSA program of the first one.
+	if cc.Stats.ReusedSSA != 1 {
+		t.Errorf("reusedSSA=%v, expected 1", cc.Stats.ReusedSSA)
+	}
+
+	// Modified source file is parsed again.
+	err = os.WriteFile(file, []byte(recompileProgram+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, future, future); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cc.CompileFile(file, [][]int{{16}, {8}})
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+	if cc.Stats.Parsed != parsed+1 {
+		t.Errorf("modified file: parsed %d files, expected 1",
+			cc.Stats.Parsed-parsed)
+	}
+	if cc.Stats.ReusedSSA != 1 {
+		t.Errorf("modified file: reusedSSA=%v, expected 1",
+			cc.Stats.ReusedSSA)
+	}
+}
+
+func TestRecompileSSACache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sum.mpcl")
+	err := os.WriteFile(file, []byte(recompileProgram), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := utils.NewParams()
+	cc := New(params)
+
+	compile := func(count int) {
+		_, _, err := cc.CompileFile(file, [][]int{{count * 8}, {8}})
+		if err != nil {
+			t.Fatalf("compile %d failed: %s", count, err)
+		}
+	}
+
+	// The changed code generation parameters are not served from the
+	// cache.
+	compile(2)
+	params.NoBoundsCheck = true
+	compile(2)
+	if cc.Stats.ReusedSSA != 0 {
+		t.Errorf("changed params: reusedSSA=%v, expected 0",
+			cc.Stats.ReusedSSA)
+	}
+	compile(2)
+	if cc.Stats.ReusedSSA != 1 {
+		t.Errorf("unchanged params: reusedSSA=%v, expected 1",
+			cc.Stats.ReusedSSA)
+	}
+
+	// The peer-selected input sizes do not grow the cache without
+	// bounds.
+	for count := 3; count < 3+2*maxSSAPrograms; count++ {
+		compile(count)
+	}
+	if n := len(cc.files[file].programs); n != maxSSAPrograms {
+		t.Errorf("%d cached programs, expected %d", n, maxSSAPrograms)
+	}
+	reused := cc.Stats.ReusedSSA
+	compile(2)
+	if cc.Stats.ReusedSSA != reused {
+		t.Errorf("evicted program was reused")
+	}
+	compile(2 + 2*maxSSAPrograms)
+	if cc.Stats.ReusedSSA != reused+1 {
+		t.Errorf("recent program was not reused")
+	}
+}
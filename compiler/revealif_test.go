@@ -0,0 +1,171 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+const revealIfProgram = `
+package main
+
+type Point struct {
+	X, Y int16
+}
+
+func main(a, b int16) (Point, [2]uint8, int16) {
+	ok := a >= 0 && a < 100 && b >= 0 && b < 100
+	var p Point
+	p.X = a
+	p.Y = b
+	var arr [2]uint8
+	arr[0] = byte(a)
+	arr[1] = 0xff
+	return revealIf(ok, p), revealIf(ok, arr), revealIf(ok, int16(-1))
+}
+`
+
+var revealIfTests = []struct {
+	a, b     int64
+	expected []int64
+}{
+	{
+		a:        3,
+		b:        42,
+		expected: []int64{42<<16 | 3, 0xff03, 0xffff},
+	},
+	{
+		a:        3,
+		b:        100,
+		expected: []int64{0, 0, 0},
+	},
+	{
+		a:        -1,
+		b:        42,
+		expected: []int64{0, 0, 0},
+	},
+}
+
+func TestRevealIf(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).Compile(revealIfProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	for _, test := range revealIfTests {
+		mask := big.NewInt(0xffff)
+		a := new(big.Int).And(big.NewInt(test.a), mask)
+		b := new(big.Int).And(big.NewInt(test.b), mask)
+
+		result, err := circuit.RunLocal(circ, a, b)
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		checkRevealIf(t, fmt.Sprintf("%v,%v", test.a, test.b), result,
+			test.expected)
+	}
+}
+
+func TestRevealIfStream(t *testing.T) {
+	params := utils.NewParams()
+	for _, test := range revealIfTests {
+		prog, err := New(params).CompileToSSA(revealIfProgram, nil)
+		if err != nil {
+			t.Fatalf("CompileToSSA failed: %v", err)
+		}
+		gConn, eConn := p2p.Pipe()
+
+		type result struct {
+			values []*big.Int
+			err    error
+		}
+		ch := make(chan result)
+		go func() {
+			_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+				[]string{fmt.Sprintf("%d", test.b)}, false)
+			ch <- result{
+				values: values,
+				err:    err,
+			}
+		}()
+		_, values, err := prog.Stream(gConn, ot.NewCO(), params,
+			big.NewInt(test.a&0xffff), circuit.NewTiming())
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		eResult := <-ch
+		gConn.Close()
+		eConn.Close()
+		if eResult.err != nil {
+			t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+		}
+		name := fmt.Sprintf("%v,%v", test.a, test.b)
+		checkRevealIf(t, name, values, test.expected)
+		checkRevealIf(t, name, eResult.values, test.expected)
+	}
+}
+
+func checkRevealIf(t *testing.T, name string, result []*big.Int,
+	expected []int64) {
+
+	var got []int64
+	for _, r := range result {
+		got = append(got, r.Int64())
+	}
+	if fmt.Sprintf("%v", got) != fmt.Sprintf("%v", expected) {
+		t.Errorf("%s: got %v, expected %v", name, got, expected)
+	}
+}
+
+func TestRevealIfInvalid(t *testing.T) {
+	tests := []struct {
+		expr    string
+		message string
+	}{
+		{
+			expr:    "revealIf(a, b)",
+			message: "invalid argument 1 (type uint8) for revealIf",
+		},
+		{
+			expr:    "revealIf(a > b)",
+			message: "invalid amount of arguments in call to revealIf",
+		},
+		{
+			expr:    "revealIf(a > b, a, b)",
+			message: "invalid amount of arguments in call to revealIf",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+func main(a, b uint8) uint8 {
+    return %s
+}
+`, test.expr)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", test.expr)
+			continue
+		}
+		if !strings.Contains(log.String(), test.message) {
+			t.Errorf("%s: got error %q, expected %q",
+				test.expr, log.String(), test.message)
+		}
+	}
+}
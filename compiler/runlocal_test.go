@@ -0,0 +1,55 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestRunLocal(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).CompileFile(
+		"../apps/garbled/examples/millionaire.mpcl", nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	for _, input := range [][2]int64{
+		{1000000, 999999},
+		{999999, 1000000},
+		{42, 42},
+		{-5, -6},
+		{-6, 5},
+	} {
+		// The int64 inputs are given in two's complement form.
+		mask := new(big.Int).SetUint64(1<<64 - 1)
+		a := new(big.Int).And(big.NewInt(input[0]), mask)
+		b := new(big.Int).And(big.NewInt(input[1]), mask)
+
+		plain, err := circ.Compute([]*big.Int{a, b})
+		if err != nil {
+			t.Fatalf("%v: compute failed: %v", input, err)
+		}
+		garbled, err := circuit.RunLocal(circ, a, b)
+		if err != nil {
+			t.Fatalf("%v: RunLocal failed: %v", input, err)
+		}
+		if len(garbled) != 1 || garbled[0].Cmp(plain[0]) != 0 {
+			t.Errorf("%v: RunLocal returned %v, expected %v",
+				input, garbled, plain)
+		}
+		var expected int64
+		if input[0] > input[1] {
+			expected = 1
+		}
+		if plain[0].Int64() != expected {
+			t.Errorf("%v: got %v, expected %v", input, plain[0], expected)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const selectStructProgram = `
+package main
+
+type Record struct {
+	ID    uint16
+	Score int32
+	Tags  [3]uint8
+}
+
+func main(a, b Record) Record {
+	return select(a.Score > b.Score, a, b)
+}
+`
+
+type selectRecord struct {
+	id    uint16
+	score int32
+	tags  [3]uint8
+}
+
+func (r selectRecord) Int() *big.Int {
+	v := new(big.Int).SetUint64(uint64(r.id))
+	v.Or(v, new(big.Int).Lsh(big.NewInt(int64(uint32(r.score))), 16))
+	for i, tag := range r.tags {
+		v.Or(v, new(big.Int).Lsh(big.NewInt(int64(tag)), uint(48+i*8)))
+	}
+	return v
+}
+
+func TestSelectStruct(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).Compile(selectStructProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	tests := [][2]selectRecord{
+		{
+			{id: 1, score: 100, tags: [3]uint8{1, 2, 3}},
+			{id: 2, score: 50, tags: [3]uint8{4, 5, 6}},
+		},
+		{
+			{id: 0xffff, score: -7, tags: [3]uint8{0xff, 0, 0xff}},
+			{id: 0x1234, score: 3, tags: [3]uint8{0, 0xff, 0}},
+		},
+		{
+			{id: 7, score: -1, tags: [3]uint8{7, 7, 7}},
+			{id: 8, score: -1, tags: [3]uint8{8, 8, 8}},
+		},
+	}
+	for _, test := range tests {
+		expected := test[1]
+		if test[0].score > test[1].score {
+			expected = test[0]
+		}
+		result, err := circuit.RunLocal(circ, test[0].Int(), test[1].Int())
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		if len(result) != 1 || result[0].Cmp(expected.Int()) != 0 {
+			t.Errorf("select(%v, %v): got %x, expected %x",
+				test[0], test[1], result, expected.Int())
+		}
+	}
+}
+
+const selectArrayProgram = `
+package main
+
+func main(a, b [4]uint16) ([4]uint16, [4]uint16) {
+	var sum uint16
+	for i := 0; i < len(a); i++ {
+		sum += a[i] ^ b[i]
+	}
+	odd := sum&1 == 1
+	return select(odd, a, b), select(odd, [4]uint16{1, 2, 3, 4}, b)
+}
+`
+
+func TestSelectArray(t *testing.T) {
+	circ, _, err := New(utils.NewParams()).Compile(selectArrayProgram, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	arrayInt := func(arr [4]uint16) *big.Int {
+		v := new(big.Int)
+		for i := len(arr) - 1; i >= 0; i-- {
+			v.Lsh(v, 16)
+			v.Or(v, big.NewInt(int64(arr[i])))
+		}
+		return v
+	}
+	tests := [][2][4]uint16{
+		{{1, 0, 0, 0}, {0xffff, 0xfffe, 0xfffd, 0xfffc}},
+		{{1, 0, 0, 0}, {0xffff, 0xfffe, 0xfffd, 0xfffd}},
+		{{0, 0, 0, 0}, {0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		var sum uint16
+		for i := range test[0] {
+			sum += test[0][i] ^ test[1][i]
+		}
+		e0 := test[1]
+		e1 := test[1]
+		if sum&1 == 1 {
+			e0 = test[0]
+			e1 = [4]uint16{1, 2, 3, 4}
+		}
+		result, err := circuit.RunLocal(circ, arrayInt(test[0]),
+			arrayInt(test[1]))
+		if err != nil {
+			t.Fatalf("RunLocal failed: %v", err)
+		}
+		if len(result) != 2 || result[0].Cmp(arrayInt(e0)) != 0 ||
+			result[1].Cmp(arrayInt(e1)) != 0 {
+			t.Errorf("select(%v, %v): got %x, expected %v %v",
+				test[0], test[1], result, e0, e1)
+		}
+	}
+}
+
+func TestSelectInvalid(t *testing.T) {
+	tests := []struct {
+		expr    string
+		message string
+	}{
+		{
+			expr:    "select(a, b, b)",
+			message: "invalid argument 1 (type struct32) for select",
+		},
+		{
+			expr:    "select(true, a)",
+			message: "invalid amount of arguments in call to select",
+		},
+		{
+			expr:    "select(a.X > 1, a, b)",
+			message: "mismatched types Point and Pair",
+		},
+		{
+			expr:    "select(a.X > 1, a, c)",
+			message: "mismatched types Point and [4]uint8",
+		},
+		{
+			expr:    "select(a.X > 1, c, d)",
+			message: "mismatched types [4]uint8 and [2]uint16",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+type Point struct {
+    X, Y uint16
+}
+type Pair struct {
+    X, Y uint16
+}
+func main(a Point, b Pair) uint16 {
+    var c [4]uint8
+    var d [2]uint16
+    r := %s
+    return r.X
+}
+`, test.expr)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", test.expr)
+			continue
+		}
+		if !strings.Contains(log.String(), test.message) {
+			t.Errorf("%s: got error %q, expected %q",
+				test.expr, log.String(), test.message)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestShiftWidth(t *testing.T) {
+	tests := []struct {
+		typ      string
+		op       string
+		count    int
+		input    int64
+		expected int64
+		warning  bool
+	}{
+		{"uint8", "<<", 7, 0x81, 0x80, false},
+		{"uint8", "<<", 8, 0x81, 0, true},
+		{"uint8", "<<", 9, 0x81, 0, true},
+		{"uint8", ">>", 7, 0x81, 1, false},
+		{"uint8", ">>", 8, 0x81, 0, true},
+		{"uint8", ">>", 12, 0x81, 0, true},
+		{"int8", "<<", 8, -1, 0, true},
+		{"int8", "<<", 10, 0x7f, 0, true},
+		{"int8", ">>", 7, -128, -1, false},
+		{"int8", ">>", 8, -128, -1, true},
+		{"int8", ">>", 8, 0x7f, 0, true},
+		{"int8", ">>", 100, -2, -1, true},
+		{"int8", ">>", 100, 2, 0, true},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("%s(%d)%s%d", test.typ, test.input, test.op,
+			test.count)
+
+		for _, constant := range []bool{false, true} {
+			var code string
+			if constant {
+				code = fmt.Sprintf(`
+package main
+func main(a %s) %s {
+    var x %s = %d
+    return x %s %d
+}
+`,
+					test.typ, test.typ, test.typ, test.input, test.op,
+					test.count)
+			} else {
+				code = fmt.Sprintf(`
+package main
+func main(a %s) %s {
+    return a %s %d
+}
+`,
+					test.typ, test.typ, test.op, test.count)
+			}
+			var log bytes.Buffer
+			params := utils.NewParams()
+			params.LogOut = &log
+
+			circ, _, err := New(params).Compile(code, nil)
+			if err != nil {
+				t.Fatalf("%s: compile failed: %v", name, err)
+			}
+			// The folded constant shifts are not always reported.
+			warning := strings.Contains(log.String(), "shift count")
+			if !constant && warning != test.warning {
+				t.Errorf("%s: got warning %v: %s",
+					name, warning, log.String())
+			}
+			results, err := circ.Compute([]*big.Int{
+				big.NewInt(test.input & 0xff),
+			})
+			if err != nil {
+				t.Fatalf("%s: compute failed: %v", name, err)
+			}
+			if results[0].Int64() != test.expected&0xff {
+				t.Errorf("%s: const=%v: got %v, expected %v", name,
+					constant, results[0], test.expected&0xff)
+			}
+		}
+	}
+}
+
+func TestShiftNegative(t *testing.T) {
+	for _, code := range []string{
+		"package main\nfunc main(a uint8) uint8 { return a << -1 }\n",
+		"package main\nfunc main(a int8) int8 { return a >> -8 }\n",
+	} {
+		params := utils.NewParams()
+		params.LogOut = &bytes.Buffer{}
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%q: compile succeeded", code)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/ast"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+func TestSlice3Parse(t *testing.T) {
+	code := `
+package main
+func main(a [6]uint8) []uint8 {
+    return a[1:3:5]
+}
+`
+	pkg := parseFormat(t, "{data}", strings.NewReader(code))
+	ret, ok := pkg.Functions["main"].Body[0].(*ast.Return)
+	if !ok {
+		t.Fatalf("unexpected statement %T", pkg.Functions["main"].Body[0])
+	}
+	slice, ok := ret.Exprs[0].(*ast.Slice)
+	if !ok {
+		t.Fatalf("unexpected expression %T", ret.Exprs[0])
+	}
+	if slice.From.String() != "1" || slice.To.String() != "3" ||
+		slice.Max == nil || slice.Max.String() != "5" {
+		t.Errorf("invalid slice %v", slice)
+	}
+	if slice.String() != "a[1:3:5]" {
+		t.Errorf("got %q, expected a[1:3:5]", slice)
+	}
+
+	var buf bytes.Buffer
+	code = `
+package main
+func main(a [6]uint8, i int) []uint8 {
+    return a[i:i+1:5]
+}
+`
+	pkg = parseFormat(t, "{data}", strings.NewReader(code))
+	if err := ast.Format(pkg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "a[i : i+1 : 5]") {
+		t.Errorf("invalid format:\n%s", buf.String())
+	}
+}
+
+func TestSlice3Invalid(t *testing.T) {
+	tests := []struct {
+		expr     string
+		location string
+		message  string
+	}{
+		{
+			expr:     "a[1::3]",
+			location: "4:13",
+			message:  "middle index required in 3-index slice",
+		},
+		{
+			expr:     "a[1:2:]",
+			location: "4:15",
+			message:  "final index required in 3-index slice",
+		},
+		{
+			expr:     "a[1:3:7]",
+			location: "4:9",
+			message:  "slice bounds out of range [1:3:7] with capacity 6",
+		},
+		{
+			expr:     "a[1:4:3]",
+			location: "4:9",
+			message:  "slice bounds out of range [1:4:3] with capacity 6",
+		},
+		{
+			expr:     "Data[0:2:8]",
+			location: "4:9",
+			message:  "slice bounds out of range [0:2:8] with capacity 4",
+		},
+		{
+			expr:     "a[1:3:4][0:4]",
+			location: "4:9",
+			message:  "slice bounds out of range [:4] with capacity 3",
+		},
+		{
+			expr:     "b[0:4:8]",
+			location: "4:9",
+			message:  "3-index slice of uint32",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+func main(a [6]uint8, b uint32) int {
+    x := %s
+    return len(x)
+}
+const Data = [4]uint8{1, 2, 3, 4}
+`, test.expr)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", test.expr)
+			continue
+		}
+		m := reErrorLocation.FindStringSubmatch(log.String())
+		if m == nil {
+			t.Errorf("%s: unexpected log: %s", test.expr, log.String())
+			continue
+		}
+		if m[1] != test.location {
+			t.Errorf("%s: error at %s, expected %s: %s",
+				test.expr, m[1], test.location, log.String())
+		}
+		if !strings.Contains(m[2], test.message) {
+			t.Errorf("%s: got error %q, expected %q",
+				test.expr, m[2], test.message)
+		}
+	}
+}
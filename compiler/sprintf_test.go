@@ -0,0 +1,158 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+// bytesInt returns the byte array value in the circuit's little-endian
+// byte order.
+func bytesInt(data []byte) *big.Int {
+	v := new(big.Int)
+	for i := len(data) - 1; i >= 0; i-- {
+		v.Lsh(v, 8)
+		v.Or(v, big.NewInt(int64(data[i])))
+	}
+	return v
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{
+			expr:     `sprintf("v%d.%d", 1, 2)`,
+			expected: "v1.2",
+		},
+		{
+			expr:     `sprintf("v%d.%d", Major, Minor)`,
+			expected: "v1.2",
+		},
+		{
+			expr:     `sprintf("%s-%04x:%t", "id", 255, true)`,
+			expected: "id-00ff:true",
+		},
+		{
+			expr:     `sprintf("%d/%d", -3, int8(-4))`,
+			expected: "-3/-4",
+		},
+		{
+			expr:     `sprintf("%s", Tag)`,
+			expected: "v1.2",
+		},
+		{
+			expr:     `sprintf("plain")`,
+			expected: "plain",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+const (
+    Major = 1
+    Minor = 2
+    Tag = sprintf("v%%d.%%d", Major, Minor)
+)
+func main(a, b byte) []byte {
+    label := []byte(%s)
+    return label
+}
+`, test.expr)
+		circ, _, err := New(utils.NewParams()).Compile(code, nil)
+		if err != nil {
+			t.Errorf("%s: compile failed: %v", test.expr, err)
+			continue
+		}
+		// The formatted string compiles like the string literal.
+		literal, _, err := New(utils.NewParams()).Compile(fmt.Sprintf(`
+package main
+func main(a, b byte) []byte {
+    label := []byte(%q)
+    return label
+}
+`, test.expected), nil)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %v", test.expr, err)
+		}
+		if circ.NumGates != literal.NumGates {
+			t.Errorf("%s: got %d gates, expected %d",
+				test.expr, circ.NumGates, literal.NumGates)
+		}
+		if circ.Outputs.Size() != len(test.expected)*8 {
+			t.Errorf("%s: got %d output bits, expected %d",
+				test.expr, circ.Outputs.Size(), len(test.expected)*8)
+		}
+		result, err := circuit.RunLocal(circ, big.NewInt(0), big.NewInt(0))
+		if err != nil {
+			t.Fatalf("%s: RunLocal failed: %v", test.expr, err)
+		}
+		expected := bytesInt([]byte(test.expected))
+		if len(result) != 1 || result[0].Cmp(expected) != 0 {
+			t.Errorf("%s: got %x, expected %x (%q)",
+				test.expr, result, expected, test.expected)
+		}
+	}
+}
+
+func TestSprintfInvalid(t *testing.T) {
+	tests := []struct {
+		expr    string
+		message string
+	}{
+		{
+			expr:    `sprintf("%d", b)`,
+			message: "argument 2 for sprintf is not constant",
+		},
+		{
+			expr:    `sprintf(string(a), 1)`,
+			message: "argument 1 for sprintf is not constant",
+		},
+		{
+			expr:    `sprintf()`,
+			message: "invalid amount of arguments in call to sprintf",
+		},
+		{
+			expr:    `sprintf(1, 2)`,
+			message: "invalid argument 1 (type int32) for sprintf",
+		},
+		{
+			expr:    `sprintf("%d %d", 1)`,
+			message: "invalid format for sprintf",
+		},
+	}
+	for _, test := range tests {
+		code := fmt.Sprintf(`
+package main
+func main(a [2]byte, b byte) []byte {
+    label := []byte(%s)
+    return label
+}
+`, test.expr)
+		var log bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &log
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil {
+			t.Errorf("%s: compile succeeded", test.expr)
+			continue
+		}
+		if !strings.Contains(log.String(), test.message) {
+			t.Errorf("%s: got error %q, expected %q",
+				test.expr, log.String(), test.message)
+		}
+	}
+}
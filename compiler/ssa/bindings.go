@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -8,6 +8,7 @@ package ssa
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/markkurossi/mpc/types"
 )
@@ -17,6 +18,15 @@ var (
 	_ BindingValue = &Select{}
 )
 
+var bindingsVersion atomic.Uint64
+
+// BindingsVersion returns the current version of the value
+// bindings. The version changes whenever a name is defined or set in
+// any Bindings instance.
+func BindingsVersion() uint64 {
+	return bindingsVersion.Load()
+}
+
 // Bindings defines value bindings.
 type Bindings struct {
 	shared bool
@@ -67,7 +77,29 @@ func (bindings *Bindings) Set(v Value, val *Value) error {
 	return nil
 }
 
+// SetBinding sets the binding b. It replaces the binding of the
+// same name and scope or adds b to the bindings.
+func (bindings *Bindings) SetBinding(b Binding) {
+	bindingsVersion.Add(1)
+
+	if bindings.shared {
+		values := make([]Binding, len(bindings.Values))
+		copy(values, bindings.Values)
+		bindings.Values = values
+		bindings.shared = false
+	}
+	for idx, v := range bindings.Values {
+		if v.Name == b.Name && v.Scope == b.Scope {
+			bindings.Values[idx] = b
+			return
+		}
+	}
+	bindings.Values = append(bindings.Values, b)
+}
+
 func (bindings *Bindings) set(v Value, t types.Info, val *Value) {
+	bindingsVersion.Add(1)
+
 	if bindings.shared {
 		// Make our own copy of the values.
 		values := make([]Binding, len(bindings.Values))
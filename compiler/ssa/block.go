@@ -26,6 +26,7 @@ type Block struct {
 	Bindings   *Bindings
 	Dead       bool
 	Processed  bool
+	gen        *Generator
 }
 
 // BlockID defines unique block IDs.
@@ -78,6 +79,9 @@ func (b *Block) addFrom(o *Block) {
 func (b *Block) AddInstr(instr Instr) {
 	instr.Check()
 	b.Instr = append(b.Instr, instr)
+	if b.gen != nil {
+		b.gen.numInstrs++
+	}
 }
 
 type returnBindingKey struct {
@@ -232,6 +236,23 @@ func (b *Block) serialize(code []Step, seen map[BlockID]bool) []Step {
 	return code
 }
 
+// reachable appends all basic blocks reachable from this block into
+// result in depth-first order.
+func (b *Block) reachable(result []*Block, seen map[BlockID]bool) []*Block {
+	if seen[b.ID] {
+		return result
+	}
+	seen[b.ID] = true
+	result = append(result, b)
+	if b.Branch != nil {
+		result = b.Branch.reachable(result, seen)
+	}
+	if b.Next != nil {
+		result = b.Next.reachable(result, seen)
+	}
+	return result
+}
+
 // DotNodes creates graphviz dot description of this basic block.
 func (b *Block) DotNodes(out io.Writer, seen map[BlockID]bool) {
 	if seen[b.ID] {
@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ssa
+
+import (
+	"fmt"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// Channel implements compile-time channels. The channel holds the
+// values that are sent into it in a FIFO queue. Since the circuit
+// stages are generated in program order, the values flow from the
+// producing stage to the consuming stage without any gates.
+type Channel struct {
+	ID          ValueID
+	ElementType types.Info
+	Capacity    int
+	Values      []Value
+}
+
+// NewChannel creates a new channel for elements of type elType. The
+// channel can hold capacity values.
+func (gen *Generator) NewChannel(elType types.Info, capacity int) *Channel {
+	return &Channel{
+		ID:          gen.nextValueID(),
+		ElementType: elType,
+		Capacity:    capacity,
+	}
+}
+
+func (ch *Channel) String() string {
+	return fmt.Sprintf("chan%d", ch.ID)
+}
+
+// Send adds the value v to the end of the channel queue.
+func (ch *Channel) Send(v Value) error {
+	if len(ch.Values) >= ch.Capacity {
+		return fmt.Errorf("send to full channel (capacity %d)", ch.Capacity)
+	}
+	ch.Values = append(ch.Values, v)
+	return nil
+}
+
+// Receive removes and returns the first value of the channel queue.
+func (ch *Channel) Receive() (Value, error) {
+	if len(ch.Values) == 0 {
+		return Undefined, fmt.Errorf("receive from empty channel")
+	}
+	v := ch.Values[0]
+	ch.Values = ch.Values[1:]
+	return v, nil
+}
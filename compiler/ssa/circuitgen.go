@@ -7,6 +7,7 @@
 package ssa
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/markkurossi/mpc/circuit"
@@ -18,6 +19,14 @@ import (
 // CompileCircuit compiles the MPCL program into a boolean circuit.
 func (prog *Program) CompileCircuit(params *utils.Params) (
 	*circuit.Circuit, error) {
+	return prog.CompileCircuitContext(context.Background(), params)
+}
+
+// CompileCircuitContext compiles the MPCL program into a boolean
+// circuit. The compilation is aborted with the context's error if ctx
+// is canceled.
+func (prog *Program) CompileCircuitContext(ctx context.Context,
+	params *utils.Params) (*circuit.Circuit, error) {
 
 	calloc := circuits.NewAllocator()
 
@@ -35,7 +44,7 @@ func (prog *Program) CompileCircuit(params *utils.Params) (
 	if params.Verbose {
 		fmt.Printf("Creating circuit...\n")
 	}
-	err = prog.Circuit(cc)
+	err = prog.CircuitContext(ctx, cc)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +54,10 @@ func (prog *Program) CompileCircuit(params *utils.Params) (
 	}
 	cc.ConstPropagate()
 	cc.ShortCircuitXORZero()
-	if params.OptPruneGates {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.OptPruneGates || prog.outputs != nil {
 		orig := float64(len(cc.Gates))
 		pruned := cc.Prune()
 		if params.Verbose {
@@ -53,7 +65,13 @@ func (prog *Program) CompileCircuit(params *utils.Params) (
 				float64(pruned)/orig*100)
 		}
 	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
 	circ := cc.Compile()
+	if err := circ.ValidateOutputs(); err != nil {
+		return nil, fmt.Errorf("invalid circuit outputs: %w", err)
+	}
 	if params.CircOut != nil {
 		if params.Verbose {
 			fmt.Printf("Serializing circuit...\n")
@@ -75,9 +93,24 @@ func (prog *Program) CompileCircuit(params *utils.Params) (
 
 // Circuit creates the boolean circuits for the program steps.
 func (prog *Program) Circuit(cc *circuits.Compiler) error {
+	return prog.CircuitContext(context.Background(), cc)
+}
+
+// CircuitContext creates the boolean circuits for the program
+// steps. The function returns the context's error if ctx is canceled.
+func (prog *Program) CircuitContext(ctx context.Context,
+	cc *circuits.Compiler) error {
 
 	for _, step := range prog.Steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		instr := step.Instr
+		if instr.Op == Ret {
+			if err := prog.validateReturn(instr); err != nil {
+				return err
+			}
+		}
 		var wires [][]*circuits.Wire
 		for idx, in := range instr.In {
 			if !in.Type.Concrete() {
@@ -298,7 +331,17 @@ func (prog *Program) Circuit(cc *circuits.Compiler) error {
 				return err
 			}
 
-		case Ilt, Ult:
+		case Ilt:
+			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
+			if err != nil {
+				return err
+			}
+			err = circuits.NewIntLtComparator(cc, wires[0], wires[1], o)
+			if err != nil {
+				return err
+			}
+
+		case Ult:
 			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
 			if err != nil {
 				return err
@@ -308,7 +351,17 @@ func (prog *Program) Circuit(cc *circuits.Compiler) error {
 				return err
 			}
 
-		case Ile, Ule:
+		case Ile:
+			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
+			if err != nil {
+				return err
+			}
+			err = circuits.NewIntLeComparator(cc, wires[0], wires[1], o)
+			if err != nil {
+				return err
+			}
+
+		case Ule:
 			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
 			if err != nil {
 				return err
@@ -318,7 +371,17 @@ func (prog *Program) Circuit(cc *circuits.Compiler) error {
 				return err
 			}
 
-		case Igt, Ugt:
+		case Igt:
+			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
+			if err != nil {
+				return err
+			}
+			err = circuits.NewIntGtComparator(cc, wires[0], wires[1], o)
+			if err != nil {
+				return err
+			}
+
+		case Ugt:
 			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
 			if err != nil {
 				return err
@@ -328,7 +391,17 @@ func (prog *Program) Circuit(cc *circuits.Compiler) error {
 				return err
 			}
 
-		case Ige, Uge:
+		case Ige:
+			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
+			if err != nil {
+				return err
+			}
+			err = circuits.NewIntGeComparator(cc, wires[0], wires[1], o)
+			if err != nil {
+				return err
+			}
+
+		case Uge:
 			o, err := prog.walloc.Wires(*instr.Out, instr.Out.Type.Bits)
 			if err != nil {
 				return err
@@ -529,7 +602,7 @@ func (prog *Program) Circuit(cc *circuits.Compiler) error {
 
 		case Ret:
 			// Assign output wires.
-			for _, wg := range wires {
+			for _, wg := range selectOutputs(prog.outputs, wires) {
 				for _, w := range wg {
 					o := cc.Calloc.Wire()
 					cc.ID(w, o)
@@ -0,0 +1,35 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ssa
+
+import (
+	"fmt"
+)
+
+// Function implements compile-time function values. The function
+// values refer to the compiler's function definitions and they are
+// resolved at compile time: the calls through function values are
+// inlined at the call sites.
+type Function struct {
+	ID   ValueID
+	Name string
+	Func interface{}
+}
+
+// NewFunction creates a new function value for the function f with
+// the name name.
+func (gen *Generator) NewFunction(name string, f interface{}) *Function {
+	return &Function{
+		ID:   gen.nextValueID(),
+		Name: name,
+		Func: f,
+	}
+}
+
+func (f *Function) String() string {
+	return fmt.Sprintf("func %s", f.Name)
+}
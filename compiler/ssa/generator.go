@@ -8,6 +8,7 @@ package ssa
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/markkurossi/mpc/compiler/mpa"
@@ -26,6 +27,7 @@ type Generator struct {
 	blockID   BlockID
 	constants map[string]ConstantInst
 	nextValID ValueID
+	numInstrs int
 }
 
 // ConstantInst defines a constant value instance.
@@ -150,11 +152,18 @@ func fmtKey(name string, scope Scope) string {
 	return fmt.Sprintf("%s@%d", name, scope)
 }
 
+// NumInstrs returns the number of instructions added to the basic
+// blocks of the generator.
+func (gen *Generator) NumInstrs() int {
+	return gen.numInstrs
+}
+
 // Block creates a new basic block.
 func (gen *Generator) Block() *Block {
 	block := &Block{
 		ID:       gen.blockID,
 		Bindings: new(Bindings),
+		gen:      gen,
 	}
 	gen.blockID++
 
@@ -203,7 +212,9 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 				break
 			}
 		}
-		if minBits > 32 {
+		// Untyped constants are signed so values with bit 31 set
+		// do not fit into 32 bits.
+		if minBits > 32 || minBits == 32 && ti.Undefined() {
 			bits = 64
 		} else {
 			bits = 32
@@ -224,7 +235,10 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 		minBits = types.Size(val.BitLen())
 		if minBits > 64 {
 			bits = minBits
-		} else if minBits > 32 {
+		} else if minBits > 32 ||
+			minBits == 32 && ti.Undefined() && val.TypeSize() > 32 {
+			// Untyped values with bit 31 set are positive if their
+			// type size is larger than 32 bits.
 			bits = 64
 		} else {
 			bits = 32
@@ -232,6 +246,14 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 		if v.Type.Bits < bits {
 			v.Type.Bits = bits
 		}
+		if v.Type.Type == types.TInt && bits <= 64 && val.IsNegative() {
+			// Negative values need the bits of their two's
+			// complement representation and the sign bit.
+			minBits = 1
+			for x := ^val.Int64(); x != 0; x >>= 1 {
+				minBits++
+			}
+		}
 		v.Type.MinBits = minBits
 		if v.Type.MinBits > v.Type.Bits {
 			panic(fmt.Sprintf("Constant(%v): Bits=%v, MinBits=%v",
@@ -259,6 +281,18 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 		}
 
 	case []interface{}:
+		if !ti.Undefined() && ti.Type == types.TStruct {
+			// The struct size is the sum of its field sizes.
+			for _, field := range ti.Struct {
+				bits += field.Type.Bits
+			}
+			v.Name = "$" + ti.String()
+			ti.Bits = bits
+			ti.MinBits = bits
+			v.Type = ti
+			return v
+		}
+
 		var length string
 		var name string
 		var elementType types.Info
@@ -276,13 +310,6 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 		} else {
 			name = "interface{}"
 		}
-		if !ti.Undefined() && ti.Type == types.TStruct {
-			v.Name = "$" + ti.String()
-			ti.Bits = bits
-			ti.MinBits = bits
-			v.Type = ti
-			return v
-		}
 
 		v.Name = fmt.Sprintf("$[%s]%s%s", length, name, arrayString(val))
 		if ti.Undefined() {
@@ -304,6 +331,21 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 		v.Type = val
 		v.TypeRef = true
 
+	case *Channel:
+		v.Name = fmt.Sprintf("$%s", val)
+		v.Type = types.Info{
+			Type:        types.TChan,
+			IsConcrete:  true,
+			ElementType: &val.ElementType,
+		}
+
+	case *Function:
+		v.Name = fmt.Sprintf("$%s", val.Name)
+		v.Type = types.Info{
+			Type:       types.TFunc,
+			IsConcrete: true,
+		}
+
 	case Value:
 		if !val.Const {
 			panic(fmt.Sprintf("value %v (%T) is not constant", val, val))
@@ -323,6 +365,63 @@ func (gen *Generator) Constant(value interface{}, ti types.Info) Value {
 	return v
 }
 
+// IntConstant creates an integer constant of the concrete integer
+// type ti from the value val. The constant is stored in the
+// two's-complement representation of the type size so that negative
+// values get the correct bit pattern. For fixed-point types, val is
+// the scaled integer representation of the value. The function
+// returns false if val does not fit into ti.
+func (gen *Generator) IntConstant(val *big.Int, ti types.Info) (Value, bool) {
+	if ti.Bits == 0 {
+		return Undefined, false
+	}
+	one := big.NewInt(1)
+	var lo, hi *big.Int
+	switch ti.Type {
+	case types.TInt, types.TFixed:
+		hi = new(big.Int).Lsh(one, uint(ti.Bits-1))
+		lo = new(big.Int).Neg(hi)
+	case types.TUint:
+		lo = big.NewInt(0)
+		hi = new(big.Int).Lsh(one, uint(ti.Bits))
+	default:
+		return Undefined, false
+	}
+	if val.Cmp(lo) < 0 || val.Cmp(hi) >= 0 {
+		return Undefined, false
+	}
+
+	// The constant name is its unsigned bit pattern. Constants with
+	// the same name share their wires, and the shared wires must have
+	// the same value regardless of the constant type.
+	mask := new(big.Int).Lsh(one, uint(ti.Bits))
+	mask.Sub(mask, one)
+	bits := new(big.Int).And(val, mask)
+
+	var cv *mpa.Int
+	if ti.Bits <= 64 {
+		cv = mpa.NewInt(int64(bits.Uint64()), ti.Bits)
+	} else {
+		cv, _ = mpa.Parse(bits.String(), 10)
+		cv.SetTypeSize(ti.Bits)
+	}
+
+	v := Value{
+		Name:       fmt.Sprintf("$%s", bits),
+		Const:      true,
+		ConstValue: cv,
+		Type:       ti,
+	}
+	v.Type.MinBits = types.Size(bits.BitLen())
+	if v.Type.MinBits == 0 {
+		v.Type.MinBits = 1
+	}
+	v.Type.SetConcrete(true)
+	v.ID = gen.nextValueID()
+
+	return v, true
+}
+
 func arrayString(arr []interface{}) string {
 	var parts []string
 
@@ -171,7 +171,7 @@ func (i Instr) Check() {
 func NewAddInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Iadd
 	case types.TUint:
 		op = Uadd
@@ -195,7 +195,7 @@ func NewAddInstr(t types.Info, l, r, o Value) (Instr, error) {
 func NewSubInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Isub
 	case types.TUint:
 		op = Usub
@@ -332,7 +332,7 @@ func NewIndexInstr(v, offset, index, o Value) Instr {
 func NewLtInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Ilt
 	case types.TUint:
 		op = Ult
@@ -353,7 +353,7 @@ func NewLtInstr(t types.Info, l, r, o Value) (Instr, error) {
 func NewLeInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Ile
 	case types.TUint:
 		op = Ule
@@ -374,7 +374,7 @@ func NewLeInstr(t types.Info, l, r, o Value) (Instr, error) {
 func NewGtInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Igt
 	case types.TUint:
 		op = Ugt
@@ -395,7 +395,7 @@ func NewGtInstr(t types.Info, l, r, o Value) (Instr, error) {
 func NewGeInstr(t types.Info, l, r, o Value) (Instr, error) {
 	var op Operand
 	switch t.Type {
-	case types.TInt:
+	case types.TInt, types.TFixed:
 		op = Ige
 	case types.TUint:
 		op = Uge
@@ -501,8 +501,13 @@ func NewBxorInstr(l, r, o Value) (Instr, error) {
 	}, nil
 }
 
-// NewMovInstr creates a new Mov instruction.
+// NewMovInstr creates a new Mov instruction. Signed integer
+// constants are sign-extended to the size of the destination.
 func NewMovInstr(from, to Value) Instr {
+	if from.Const && from.Type.Type == types.TInt && from.Type.Bits <= 64 &&
+		to.Type.Bits > from.Type.Bits {
+		return NewSmovInstr(from, to)
+	}
 	return Instr{
 		Op:  Mov,
 		In:  []Value{from},
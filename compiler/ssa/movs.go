@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ssa
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markkurossi/mpc/types"
+)
+
+// CoalesceMovs removes the mov instructions that copy a value to a
+// value of the identical type. The uses of the mov destination values
+// are replaced with the mov source values so the chains of movs
+// collapse to their first source value. The function must be called
+// before GC. It returns the number of removed instructions.
+func (prog *Program) CoalesceMovs() int {
+	start := time.Now()
+
+	// Count value definitions. The SSA values are defined once but
+	// the coalescing is safe only if that holds for the values it
+	// replaces.
+	defs := make(map[ValueID]int)
+	for _, step := range prog.Steps {
+		if step.Instr.Out != nil {
+			defs[step.Instr.Out.ID]++
+		}
+		for _, ret := range step.Instr.Ret {
+			defs[ret.ID]++
+		}
+	}
+
+	replace := make(map[ValueID]Value)
+	resolve := func(v Value) Value {
+		for {
+			r, ok := replace[v.ID]
+			if !ok {
+				return v
+			}
+			v = r
+		}
+	}
+
+	steps := make([]Step, 0, len(prog.Steps))
+	for _, step := range prog.Steps {
+		instr := step.Instr
+		var in []Value
+		for idx, v := range instr.In {
+			if v.Const || v.TypeRef {
+				continue
+			}
+			r := resolve(v)
+			if r.ID == v.ID {
+				continue
+			}
+			if in == nil {
+				in = make([]Value, len(instr.In))
+				copy(in, instr.In)
+			}
+			in[idx] = r
+		}
+		if in != nil {
+			instr.In = in
+		}
+		if instr.Op == Mov && coalescable(instr.In[0], *instr.Out) &&
+			defs[instr.Out.ID] == 1 {
+			replace[instr.Out.ID] = instr.In[0]
+			continue
+		}
+		step.Instr = instr
+		steps = append(steps, step)
+	}
+	removed := len(prog.Steps) - len(steps)
+	prog.Steps = steps
+
+	if prog.Params.Diagnostics {
+		fmt.Printf(" - Program.CoalesceMovs: removed %d movs in %s\n",
+			removed, time.Since(start))
+	}
+	return removed
+}
+
+// coalescable tests if the mov from the value from to the value to can
+// be coalesced.
+func coalescable(from, to Value) bool {
+	if from.Const || from.TypeRef || from.PtrInfo != nil ||
+		to.PtrInfo != nil {
+		return false
+	}
+	if !from.Type.Concrete() || !to.Type.Concrete() ||
+		from.Type.Bits != to.Type.Bits {
+		return false
+	}
+	switch from.Type.Type {
+	case types.TUndefined, types.TPtr, types.TNil, types.TFunc,
+		types.TChan:
+		return false
+	}
+	return from.Type.Equal(to.Type)
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ssa
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/circuits"
+)
+
+// validateReturn checks that the return values of the Ret instruction
+// have wires. The wire allocator assigns new wires for unknown
+// values. If a return value was never defined or it has already been
+// reclaimed, its output would silently read the wires that the
+// allocator has recycled for other values. The zero-width values do
+// not have wires so they are always assigned.
+func (prog *Program) validateReturn(instr Instr) error {
+	for idx, in := range instr.In {
+		if in.Const || in.Type.Bits == 0 || prog.walloc.Allocated(in) {
+			continue
+		}
+		name := fmt.Sprintf("%d", idx)
+		if idx < len(prog.allOutputs) && len(prog.allOutputs[idx].Name) > 0 {
+			name = prog.allOutputs[idx].Name
+		}
+		return fmt.Errorf("output %s: return value %s is not assigned",
+			name, in)
+	}
+	return nil
+}
+
+// validateOutputs checks that the output wires are assigned and
+// that each output wire still belongs to the value that the output
+// reads. The streamer shares wires between values, for example the
+// Mov, Slice, and shift outputs use the wires of their source values,
+// so several output bits can have the same wire ID if they read the
+// same value. The wire allocator recycles the wires of reclaimed
+// values, so an output that uses a reclaimed wire would read the
+// bits of an unrelated value. Such a wire is either on the free list
+// or owned by a value that was allocated after the output value.
+func (prog *Program) validateOutputs(values []Value,
+	wires [][]circuit.Wire) error {
+
+	var ids []circuit.Wire
+	var args []int
+	users := make(map[circuit.Wire][]int)
+	for idx, w := range wires {
+		for _, id := range w {
+			users[id] = append(users[id], len(ids))
+			ids = append(ids, id)
+			args = append(args, idx)
+		}
+	}
+	for bit, id := range ids {
+		if id == circuits.UnassignedID || id >= prog.walloc.nextWireID {
+			return fmt.Errorf("output %s: wire %d not assigned",
+				prog.Outputs.BitName(bit), id)
+		}
+	}
+
+	owners := prog.walloc.owners(ids)
+
+	for bit, id := range ids {
+		owner, ok := owners[id]
+		if !ok {
+			var names []string
+			for _, b := range users[id] {
+				names = append(names, prog.Outputs.BitName(b))
+			}
+			return fmt.Errorf("output %s: wire %d has been reclaimed",
+				strings.Join(names, ", "), id)
+		}
+		value := values[args[bit]]
+		if owner.key.Equal(&value) {
+			continue
+		}
+		alloc := prog.walloc.lookup(prog.walloc.hashCode(value), value)
+		if alloc != nil && alloc.seq > owner.seq {
+			continue
+		}
+		// The output reads a wire that has been reassigned to the
+		// owner value. Report the output that the wire aliases.
+		for _, b := range users[id] {
+			if b != bit {
+				return fmt.Errorf("outputs %s and %s share wire %d",
+					prog.Outputs.BitName(bit), prog.Outputs.BitName(b), id)
+			}
+		}
+		return fmt.Errorf("output %s: wire %d has been reassigned to %s",
+			prog.Outputs.BitName(bit), id, owner.key)
+	}
+	return nil
+}
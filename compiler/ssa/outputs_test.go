@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package ssa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/types"
+)
+
+func TestValidateOutputs(t *testing.T) {
+	typ := types.Info{
+		Type:       types.TUint,
+		IsConcrete: true,
+		Bits:       4,
+	}
+	prog, err := NewProgram(utils.NewParams(), nil, circuit.IO{
+		{Name: "r0", Type: typ},
+		{Name: "r1", Type: typ},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewProgram failed: %v", err)
+	}
+	v0 := Value{Name: "v0", Scope: 1, Type: typ}
+	v1 := Value{Name: "v1", Scope: 1, Type: typ}
+
+	ids0, err := prog.walloc.AssignedIDs(v0, typ.Bits)
+	if err != nil {
+		t.Fatalf("AssignedIDs failed: %v", err)
+	}
+	ids0 = append([]circuit.Wire(nil), ids0...)
+	ids1, err := prog.walloc.AssignedIDs(v1, typ.Bits)
+	if err != nil {
+		t.Fatalf("AssignedIDs failed: %v", err)
+	}
+	ids1 = append([]circuit.Wire(nil), ids1...)
+
+	values := []Value{v0, v1}
+	if err := prog.validateOutputs(values,
+		[][]circuit.Wire{ids0, ids1}); err != nil {
+		t.Fatalf("validateOutputs failed: %v", err)
+	}
+
+	// The outputs can share the wires of the values they read.
+	if err := prog.validateOutputs([]Value{v0, v0},
+		[][]circuit.Wire{ids0, ids0}); err != nil {
+		t.Fatalf("validateOutputs failed for the same value: %v", err)
+	}
+	if err := prog.validateOutputs(values, [][]circuit.Wire{ids0, {
+		ids1[0], ids1[1], ids0[2], ids1[3],
+	}}); err != nil {
+		t.Fatalf("validateOutputs failed for aliased value: %v", err)
+	}
+
+	// Output wires that are not assigned.
+	unassigned := append([]circuit.Wire(nil), ids1...)
+	unassigned[1] = prog.walloc.nextWireID
+	err = prog.validateOutputs(values, [][]circuit.Wire{ids0, unassigned})
+	if err == nil || !strings.Contains(err.Error(), "output r1[1]") {
+		t.Errorf("unexpected error for unassigned wire: %v", err)
+	}
+
+	// Reclaim v1 and report the outputs that use its wires.
+	prog.walloc.GCWires(v1)
+	err = prog.validateOutputs([]Value{v0, v0}, [][]circuit.Wire{ids0, {
+		ids1[0], ids1[0], ids0[2], ids0[3],
+	}})
+	if err == nil {
+		t.Fatalf("validateOutputs succeeded for reclaimed wires")
+	}
+	if !strings.Contains(err.Error(), "output r1[0], r1[1]: wire") ||
+		!strings.Contains(err.Error(), "has been reclaimed") {
+		t.Errorf("unexpected error for reclaimed wires: %v", err)
+	}
+
+	// Reassign the reclaimed wires to v2. The outputs of v0 can not
+	// use them anymore.
+	v2 := Value{Name: "v2", Scope: 1, Type: typ}
+	ids2, err := prog.walloc.AssignedIDs(v2, typ.Bits)
+	if err != nil {
+		t.Fatalf("AssignedIDs failed: %v", err)
+	}
+	if ids2[0] != ids1[0] {
+		t.Fatalf("v2 got wires %v, expected reclaimed wires %v", ids2, ids1)
+	}
+	err = prog.validateOutputs([]Value{v0, v0}, [][]circuit.Wire{ids0, ids1})
+	if err == nil || !strings.Contains(err.Error(), "output r1[0]: wire") ||
+		!strings.Contains(err.Error(), "has been reassigned to v2") {
+		t.Errorf("unexpected error for reassigned wires: %v", err)
+	}
+
+	// Report the outputs that alias the reassigned wires.
+	err = prog.validateOutputs([]Value{v2, v0}, [][]circuit.Wire{ids2, {
+		ids0[0], ids0[1], ids0[2], ids1[3],
+	}})
+	if err == nil ||
+		!strings.Contains(err.Error(), "outputs r1[3] and r0[3] share wire") {
+		t.Errorf("unexpected error for aliased outputs: %v", err)
+	}
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -29,12 +29,16 @@ type Program struct {
 	OutputWires []*circuits.Wire
 	Constants   map[string]ConstantInst
 	Steps       []Step
+	Init        *Block
+	allOutputs  circuit.IO
+	outputs     []int
 	walloc      *WireAllocator
 	calloc      *circuits.Allocator
 	zeroWire    *circuits.Wire
 	oneWire     *circuits.Wire
 	stats       circuit.Stats
 	numWires    int
+	flow        *circuit.StreamFlow
 	tInit       time.Duration
 	tGarble     time.Duration
 }
@@ -47,17 +51,51 @@ func NewProgram(params *utils.Params, in, out circuit.IO,
 	calloc := circuits.NewAllocator()
 
 	prog := &Program{
-		Params:    params,
-		Inputs:    in,
-		Outputs:   out,
-		Constants: consts,
-		Steps:     steps,
-		walloc:    NewWireAllocator(calloc),
-		calloc:    calloc,
+		Params:     params,
+		Inputs:     in,
+		Outputs:    out,
+		allOutputs: out,
+		Constants:  consts,
+		Steps:      steps,
+		walloc:     NewWireAllocator(calloc),
+		calloc:     calloc,
 	}
+	if err := prog.allocInputs(); err != nil {
+		return nil, err
+	}
+
+	return prog, nil
+}
+
+// Clone creates a copy of the program that shares the program steps
+// but has its own wire allocation state. The compilation of a program
+// into a circuit binds wires to the program values so a program can
+// be compiled only once; Clone returns a copy that can be compiled
+// again.
+func (prog *Program) Clone() (*Program, error) {
+	calloc := circuits.NewAllocator()
 
-	// Inputs into wires.
-	for idx, arg := range in {
+	clone := &Program{
+		Params:     prog.Params,
+		Inputs:     prog.Inputs,
+		Outputs:    prog.Outputs,
+		Constants:  prog.Constants,
+		Steps:      prog.Steps,
+		Init:       prog.Init,
+		allOutputs: prog.allOutputs,
+		outputs:    prog.outputs,
+		walloc:     NewWireAllocator(calloc),
+		calloc:     calloc,
+	}
+	if err := clone.allocInputs(); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// allocInputs allocates the wires of the program inputs.
+func (prog *Program) allocInputs() error {
+	for idx, arg := range prog.Inputs {
 		if len(arg.Name) == 0 {
 			arg.Name = fmt.Sprintf("arg{%d}", idx)
 		}
@@ -67,12 +105,94 @@ func NewProgram(params *utils.Params, in, out circuit.IO,
 			Type:  arg.Type,
 		}, arg.Type.Bits)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		prog.InputWires = append(prog.InputWires, wires...)
 	}
+	return nil
+}
+
+// EvaluateOutputs restricts the program outputs to the return values
+// at indices. The circuits of the program return only the selected
+// outputs, and the gates that contribute only to the unselected
+// outputs are pruned from the compiled circuit. The indices refer to
+// the return values of the main function. Calling EvaluateOutputs
+// with nil indices selects all outputs.
+func (prog *Program) EvaluateOutputs(indices []int) error {
+	if indices == nil {
+		prog.Outputs = prog.allOutputs
+		prog.outputs = nil
+		return nil
+	}
+	if len(indices) == 0 {
+		return fmt.Errorf("no outputs selected")
+	}
+	seen := make(map[int]bool)
+	var outputs circuit.IO
+	for _, idx := range indices {
+		if idx < 0 || idx >= len(prog.allOutputs) {
+			return fmt.Errorf("invalid output index %d: program has %d outputs",
+				idx, len(prog.allOutputs))
+		}
+		if seen[idx] {
+			return fmt.Errorf("duplicate output index %d", idx)
+		}
+		seen[idx] = true
+		outputs = append(outputs, prog.allOutputs[idx])
+	}
+	prog.Outputs = outputs
+	prog.outputs = indices
 
-	return prog, nil
+	return nil
+}
+
+// SetOutputModes sets the output modes of the program outputs. The
+// modes are indexed with the return value indices of the main
+// function and they must refer to the outputs selected with
+// EvaluateOutputs. The outputs without an explicit mode are revealed
+// to both parties.
+func (prog *Program) SetOutputModes(modes map[int]circuit.OutputMode) error {
+	outputs := make(circuit.IO, len(prog.Outputs))
+	copy(outputs, prog.Outputs)
+
+	for idx, mode := range modes {
+		if mode != circuit.OutputReveal && mode != circuit.OutputShare {
+			return fmt.Errorf("invalid mode %s for output %d", mode, idx)
+		}
+		pos := -1
+		if prog.outputs == nil {
+			if idx >= 0 && idx < len(outputs) {
+				pos = idx
+			}
+		} else {
+			for i, sel := range prog.outputs {
+				if sel == idx {
+					pos = i
+					break
+				}
+			}
+		}
+		if pos < 0 {
+			return fmt.Errorf("invalid output index %d for mode %s", idx, mode)
+		}
+		outputs[pos].Mode = mode
+	}
+	prog.Outputs = outputs
+
+	return nil
+}
+
+// selectOutputs returns the selected program outputs from the return
+// instruction's input values or wires.
+func selectOutputs[T any](outputs []int, args []T) []T {
+	if outputs == nil {
+		return args
+	}
+	var result []T
+	for _, idx := range outputs {
+		result = append(result, args[idx])
+	}
+	return result
 }
 
 // Step defines one SSA program step.
@@ -294,3 +414,66 @@ func (prog *Program) PP(out io.Writer) {
 		}
 	}
 }
+
+// DotCFG creates a graphviz dot description of the program's
+// control-flow graph. The basic blocks are drawn as nodes listing
+// their instructions. Conditional branches have their true and false
+// edges labeled with the branch condition, and the blocks merging
+// values with phi instructions are drawn with a double border.
+func (prog *Program) DotCFG(out io.Writer) {
+	fontname := "Courier"
+	fontsize := 10
+
+	fmt.Fprintln(out, "digraph cfg {")
+	fmt.Fprintf(out, "  node [shape=box fontname=\"%s\" fontsize=\"%d\"]\n",
+		fontname, fontsize)
+	fmt.Fprintf(out, "  edge [fontname=\"%s\" fontsize=\"%d\"]\n",
+		fontname, fontsize)
+
+	var blocks []*Block
+	if prog.Init != nil {
+		blocks = prog.Init.reachable(nil, make(map[BlockID]bool))
+	}
+	for _, b := range blocks {
+		var maxLen int
+		var phi bool
+		for _, i := range b.Instr {
+			l := len(i.Op.String())
+			if l > maxLen {
+				maxLen = l
+			}
+			if i.Op == Phi {
+				phi = true
+			}
+		}
+		label := b.ID.String()
+		if len(b.Name) > 0 {
+			label += " (" + b.Name + ")"
+		}
+		label += ":\\l"
+		for _, i := range b.Instr {
+			label += i.string(maxLen, false)
+			label += "\\l"
+		}
+		var attrs string
+		if phi {
+			attrs = " peripheries=2"
+		}
+		fmt.Fprintf(out, "  %s [label=\"%s\"%s]\n", b,
+			strings.ReplaceAll(label, `"`, `\"`), attrs)
+	}
+	for _, b := range blocks {
+		if b.Branch != nil && b.Next != nil && b.Branch != b.Next {
+			fmt.Fprintf(out, "  %s -> %s [label=\"%s\"];\n",
+				b, b.Branch, b.BranchCond)
+			fmt.Fprintf(out, "  %s -> %s [label=\"!%s\" style=dashed];\n",
+				b, b.Next, b.BranchCond)
+		} else if b.Branch != nil {
+			fmt.Fprintf(out, "  %s -> %s [label=\"%s\"];\n",
+				b, b.Branch, b.BranchCond)
+		} else if b.Next != nil {
+			fmt.Fprintf(out, "  %s -> %s;\n", b, b.Next)
+		}
+	}
+	fmt.Fprintln(out, "}")
+}
@@ -7,6 +7,7 @@
 package ssa
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"math/big"
@@ -27,40 +28,28 @@ import (
 func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 	params *utils.Params, inputs *big.Int, timing *circuit.Timing) (
 	circuit.IO, []*big.Int, error) {
+	return prog.StreamContext(context.Background(), conn, oti, params, inputs,
+		timing)
+}
+
+// StreamContext streams the program circuit into the P2P
+// connection. If ctx is canceled, the function stops streaming,
+// notifies the evaluator with the circuit.OpAbort message, releases
+// the program's wire allocations, and returns the context's error.
+// All other errors after the evaluator has received its inputs abort
+// the evaluator in the same way.
+func (prog *Program) StreamContext(ctx context.Context, conn *p2p.Conn,
+	oti ot.OT, params *utils.Params, inputs *big.Int,
+	timing *circuit.Timing) (outputs circuit.IO, values []*big.Int,
+	err error) {
 
 	var key [32]byte
-	_, err := rand.Read(key[:])
+	_, err = rand.Read(key[:])
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if params.Verbose {
-		fmt.Printf(" - Sending program info...\n")
-	}
-	if err := conn.SendData(key[:]); err != nil {
-		return nil, nil, err
-	}
-	// Our input.
-	if err := sendArgument(conn, prog.Inputs[0]); err != nil {
-		return nil, nil, err
-	}
-	// Peer input.
-	if err := sendArgument(conn, prog.Inputs[1]); err != nil {
-		return nil, nil, err
-	}
-	// Program outputs.
-	if err := conn.SendUint32(len(prog.Outputs)); err != nil {
-		return nil, nil, err
-	}
-	for _, o := range prog.Outputs {
-		if err := sendArgument(conn, o); err != nil {
-			return nil, nil, err
-		}
-	}
-	// Number of program steps.
-	if err := conn.SendUint32(len(prog.Steps)); err != nil {
-		return nil, nil, err
-	}
+	conn.SetLabelEncoding(params.LabelEncoding)
 
 	// Collect input wire IDs.
 	var ids []circuit.Wire
@@ -90,16 +79,52 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 		n1 = append(n1, n)
 	}
 
-	// Send our inputs.
-	var labelData ot.LabelData
-	for idx, i := range n1 {
-		if params.Verbose && false {
-			fmt.Printf("N1[%d]:\t%s\n", idx, i)
+	if params.Verbose {
+		fmt.Printf(" - Sending program info...\n")
+	}
+	if err := conn.SendData(key[:]); err != nil {
+		return nil, nil, err
+	}
+	window := params.StreamWindow
+	if window == 0 {
+		window = circuit.DefaultStreamWindow
+	}
+	prog.flow, err = circuit.NewStreamFlow(window)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.SendUint32(window); err != nil {
+		return nil, nil, err
+	}
+	// Our input.
+	if err := sendArgument(conn, prog.Inputs[0]); err != nil {
+		return nil, nil, err
+	}
+	// Peer input.
+	if err := sendArgument(conn, prog.Inputs[1]); err != nil {
+		return nil, nil, err
+	}
+	// Program outputs.
+	if err := conn.SendUint32(len(prog.Outputs)); err != nil {
+		return nil, nil, err
+	}
+	for _, o := range prog.Outputs {
+		if err := sendArgument(conn, o); err != nil {
+			return nil, nil, err
 		}
-		if err := conn.SendLabel(i, &labelData); err != nil {
+		if err := conn.SendByte(byte(o.Mode)); err != nil {
 			return nil, nil, err
 		}
 	}
+	// Number of program steps.
+	if err := conn.SendUint32(len(prog.Steps)); err != nil {
+		return nil, nil, err
+	}
+
+	// Send our inputs.
+	if err := conn.SendLabels(n1); err != nil {
+		return nil, nil, err
+	}
 
 	ioStats := conn.Stats.Sum()
 	timing.Sample("Init", []string{circuit.FileSize(ioStats).String()})
@@ -123,6 +148,15 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 	ioStats = conn.Stats.Sum()
 	timing.Sample("Peer Inputs", []string{circuit.FileSize(xfer).String()})
 
+	// The evaluator is now processing the streaming operations. Abort
+	// it on all errors so that it does not wait for the operations
+	// forever.
+	defer func() {
+		if err != nil {
+			err = prog.abort(conn, err)
+		}
+	}()
+
 	zero, err := prog.ZeroWire(conn, streaming)
 	if err != nil {
 		return nil, nil, err
@@ -154,6 +188,9 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 	var iIDs, oIDs []circuit.Wire
 
 	for idx, step := range prog.Steps {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 		dStart := time.Now()
 		if idx%10 == 0 && params.Verbose {
 			now := time.Now()
@@ -174,6 +211,11 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 			}
 		}
 		instr := step.Instr
+		if instr.Op == Ret {
+			if err := prog.validateReturn(instr); err != nil {
+				return nil, nil, err
+			}
+		}
 		wires = wires[:0]
 		for _, in := range instr.In {
 			w, err := prog.walloc.AssignedIDs(in, in.Type.Bits)
@@ -391,15 +433,21 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 			}
 
 		case Ret:
+			outputs := selectOutputs(prog.outputs, wires)
+			err := prog.validateOutputs(selectOutputs(prog.outputs, instr.In),
+				outputs)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, arg := range outputs {
+				returnIDs = append(returnIDs, arg...)
+			}
 			if err := conn.SendUint32(circuit.OpReturn); err != nil {
 				return nil, nil, err
 			}
-			for _, arg := range wires {
-				for _, w := range arg {
-					if err := conn.SendUint32(w.Int()); err != nil {
-						return nil, nil, err
-					}
-					returnIDs = append(returnIDs, w)
+			for _, w := range returnIDs {
+				if err := circuit.SendWire(conn, uint64(w)); err != nil {
+					return nil, nil, err
 				}
 			}
 			if circuit.StreamDebug {
@@ -452,6 +500,16 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 			}
 
 		case GC:
+			ids := prog.walloc.OwnedIDs(*instr.GC)
+			if params.ZeroizeLabels {
+				// Clear the secret labels of the recycled wires. The
+				// wires that the value shares with other values stay
+				// intact.
+				streaming.Zeroize(ids)
+			}
+			if err := prog.gc(conn, ids); err != nil {
+				return nil, nil, err
+			}
 			prog.walloc.GCWires(*instr.GC)
 
 		default:
@@ -554,6 +612,9 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 
 	result := new(big.Int)
 
+	if err := prog.flow.Drain(conn); err != nil {
+		return nil, nil, err
+	}
 	op, err := conn.ReceiveUint32()
 	if err != nil {
 		return nil, nil, err
@@ -563,14 +624,27 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 	}
 
 	var label ot.Label
+	var labelData ot.LabelData
 
-	for i := 0; i < prog.Outputs.Size(); i++ {
+	// The evaluator returns the labels of the revealed outputs. Our
+	// shares of the shared outputs are the permutation bits of their
+	// wires and the evaluator's shares are the permutation bits of
+	// its labels.
+	revealed := new(big.Int)
+	for i, shared := range prog.Outputs.SharedBits() {
+		wire := streaming.GetInput(returnIDs[i])
+		var bit uint
+		if shared {
+			if wire.L0.S() {
+				bit = 1
+			}
+			result.SetBit(result, i, bit)
+			continue
+		}
 		err := conn.ReceiveLabel(&label, &labelData)
 		if err != nil {
 			return nil, nil, err
 		}
-		wire := streaming.GetInput(returnIDs[i])
-		var bit uint
 		if label.Equal(wire.L0) {
 			bit = 0
 		} else if label.Equal(wire.L1) {
@@ -580,8 +654,12 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 				label, i)
 		}
 		result.SetBit(result, i, bit)
+		revealed.SetBit(revealed, i, bit)
+	}
+	data := revealed.Bytes()
+	if err := conn.SendUint32(circuit.OpResult); err != nil {
+		return nil, nil, err
 	}
-	data := result.Bytes()
 	if err := conn.SendData(data); err != nil {
 		return nil, nil, err
 	}
@@ -620,7 +698,12 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 		}
 
 		sort.Slice(keys, func(i, j int) bool {
-			return istats[keys[i]].Cost() > istats[keys[j]].Cost()
+			ci := istats[keys[i]].CostWith(params.CostModel)
+			cj := istats[keys[j]].CostWith(params.CostModel)
+			if ci != cj {
+				return ci > cj
+			}
+			return keys[i] < keys[j]
 		})
 
 		for _, key := range keys {
@@ -646,6 +729,22 @@ func (prog *Program) Stream(conn *p2p.Conn, oti ot.OT,
 	return prog.Outputs, prog.Outputs.Split(result), nil
 }
 
+// abort aborts the streaming with the error err. The function
+// notifies the evaluator about the abort and releases the wire
+// allocations of the program.
+func (prog *Program) abort(conn *p2p.Conn, err error) error {
+	prog.walloc = NewWireAllocator(prog.calloc)
+	prog.flow = nil
+
+	if err := conn.SendUint32(circuit.OpAbort); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	return err
+}
+
 func addStats(istats map[string]circuit.Stats, instr Instr,
 	circ *circuit.Circuit) {
 
@@ -688,10 +787,10 @@ func (prog *Program) garble(conn *p2p.Conn, streaming *circuit.Streaming,
 	if err := conn.SendUint32(circ.NumGates); err != nil {
 		return err
 	}
-	if err := conn.SendUint32(circ.NumWires); err != nil {
+	if err := circuit.SendWire(conn, uint64(circ.NumWires)); err != nil {
 		return err
 	}
-	if err := conn.SendUint32(int(maxID + 1)); err != nil {
+	if err := circuit.SendWire(conn, uint64(maxID)+1); err != nil {
 		return err
 	}
 	tInit, tGarble, err := streaming.Garble(circ, in, out)
@@ -703,7 +802,23 @@ func (prog *Program) garble(conn *p2p.Conn, streaming *circuit.Streaming,
 	prog.stats.Add(circ.Stats)
 	prog.numWires += circ.NumWires
 
-	return nil
+	return prog.flow.Sent(conn, circ.NumGates)
+}
+
+// gc notifies the evaluator that the wires ids are dead so that it
+// can free their labels. The ids are the consecutive wire IDs that a
+// value owns.
+func (prog *Program) gc(conn *p2p.Conn, ids []circuit.Wire) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := conn.SendUint32(circuit.OpGC); err != nil {
+		return err
+	}
+	if err := circuit.SendWire(conn, uint64(ids[0])); err != nil {
+		return err
+	}
+	return conn.SendUint32(len(ids))
 }
 
 // ZeroWire returns a wire with value 0.
@@ -911,13 +1026,13 @@ var circuitGenerators = map[Operand]NewCircuit{
 	Imod:  newIModulo,
 	Umod:  newUModulo,
 	Index: newIndex,
-	Ilt:   newBinary(circuits.NewLtComparator),
+	Ilt:   newBinary(circuits.NewIntLtComparator),
 	Ult:   newBinary(circuits.NewLtComparator),
-	Ile:   newBinary(circuits.NewLeComparator),
+	Ile:   newBinary(circuits.NewIntLeComparator),
 	Ule:   newBinary(circuits.NewLeComparator),
-	Igt:   newBinary(circuits.NewGtComparator),
+	Igt:   newBinary(circuits.NewIntGtComparator),
 	Ugt:   newBinary(circuits.NewGtComparator),
-	Ige:   newBinary(circuits.NewGeComparator),
+	Ige:   newBinary(circuits.NewIntGeComparator),
 	Uge:   newBinary(circuits.NewGeComparator),
 	Eq:    newBinary(circuits.NewEqComparator),
 	Neq:   newBinary(circuits.NewNeqComparator),
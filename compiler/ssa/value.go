@@ -117,7 +117,7 @@ func (v Value) Indirect(block *Block, gen *Generator) Value {
 // ContainerType returs the pointer container type of the value. For
 // non-pointer values, this returns the value type itself.
 func (v Value) ContainerType() types.Info {
-	if v.Type.Type == types.TPtr {
+	if v.Type.Type == types.TPtr && v.PtrInfo != nil {
 		return v.PtrInfo.ContainerType
 	}
 	return v.Type
@@ -138,7 +138,7 @@ func (v Value) String() string {
 	}
 
 	// XXX Value should have type, now we have flags and Type.Type
-	if v.Type.Type == types.TPtr {
+	if v.Type.Type == types.TPtr && v.PtrInfo != nil {
 		return fmt.Sprintf("%s{%d,%s}%s{%s{%d}%s[%d-%d]}",
 			v.Name, v.Scope, version, v.Type.ShortString(),
 			v.PtrInfo.Name, v.PtrInfo.Scope, v.PtrInfo.ContainerType,
@@ -319,7 +319,8 @@ func isSet(v interface{}, vt types.Info, bit types.Size) bool {
 
 	case Value:
 		switch val.Type.Type {
-		case types.TBool, types.TInt, types.TUint, types.TFloat, types.TString:
+		case types.TBool, types.TInt, types.TUint, types.TFloat, types.TString,
+			types.TFixed:
 			return isSet(val.ConstValue, val.Type, bit)
 
 		case types.TArray, types.TSlice:
@@ -24,6 +24,7 @@ type WireAllocator struct {
 	freeIDs     map[types.Size][][]circuit.Wire
 	hash        [10240]*allocByValue
 	nextWireID  circuit.Wire
+	nextSeq     uint64
 	flHdrs      cacheStats
 	flWires     cacheStats
 	flIDs       cacheStats
@@ -46,6 +47,8 @@ func (cs cacheStats) String() string {
 type allocByValue struct {
 	next  *allocByValue
 	key   Value
+	seq   uint64
+	owns  bool
 	base  circuit.Wire
 	wires []*circuits.Wire
 	ids   []circuit.Wire
@@ -80,7 +83,10 @@ func (walloc *WireAllocator) newHeader(v Value) (ret *allocByValue) {
 		walloc.flHdrs.hit++
 	}
 	ret.key = v
+	ret.seq = walloc.nextSeq
+	ret.owns = false
 	ret.base = circuits.UnassignedID
+	walloc.nextSeq++
 	return ret
 }
 
@@ -144,6 +150,7 @@ func (walloc *WireAllocator) alloc(bits types.Size, v Value,
 	if bits == 0 {
 		return result
 	}
+	result.owns = true
 
 	if wires && ids {
 		result.wires = walloc.newWires(bits)
@@ -181,6 +188,37 @@ func (walloc *WireAllocator) Allocated(v Value) bool {
 	return alloc != nil
 }
 
+// owners returns the live values that own the argument wire IDs. The
+// values own the wires that the allocator assigned for them. The
+// values that are defined with SetWires share the argument wires and
+// do not own them.
+func (walloc *WireAllocator) owners(ids []circuit.Wire) (
+	result map[circuit.Wire]*allocByValue) {
+
+	result = make(map[circuit.Wire]*allocByValue)
+	for _, id := range ids {
+		result[id] = nil
+	}
+	for _, bucket := range walloc.hash {
+		for alloc := bucket; alloc != nil; alloc = alloc.next {
+			if !alloc.owns {
+				continue
+			}
+			for _, id := range alloc.ownedIDs() {
+				if _, ok := result[id]; ok {
+					result[id] = alloc
+				}
+			}
+		}
+	}
+	for id, alloc := range result {
+		if alloc == nil {
+			delete(result, id)
+		}
+	}
+	return result
+}
+
 // NextWireID allocated and returns the next unassigned wire ID.
 // XXX is this sync with circuits.Compiler.NextWireID()?
 func (walloc *WireAllocator) NextWireID() circuit.Wire {
@@ -218,6 +256,45 @@ func (walloc *WireAllocator) AssignedIDs(v Value, bits types.Size) (
 	return alloc.ids, nil
 }
 
+// OwnedIDs returns the wire IDs that the argument value owns and
+// that GCWires recycles to the free list. Unlike the value's current
+// wire IDs, the owned IDs never include the IDs that the value shares
+// with other values, such as the source wires of Mov and Slice
+// outputs or the constant zero and one wires. The function returns
+// nil if the value does not have wires or if its wire IDs have not
+// been assigned.
+func (walloc *WireAllocator) OwnedIDs(v Value) []circuit.Wire {
+	hash := walloc.hashCode(v)
+	alloc := walloc.lookup(hash, v)
+	if alloc == nil {
+		return nil
+	}
+	return alloc.ownedIDs()
+}
+
+func (alloc *allocByValue) ownedIDs() []circuit.Wire {
+	bits := len(alloc.ids)
+	if alloc.wires != nil {
+		bits = len(alloc.wires)
+	}
+	base := alloc.base
+	if base == circuits.UnassignedID && bits > 0 {
+		if alloc.wires != nil {
+			base = alloc.wires[0].ID()
+		} else {
+			base = alloc.ids[0]
+		}
+	}
+	if base == circuits.UnassignedID {
+		return nil
+	}
+	ids := make([]circuit.Wire, bits)
+	for i := 0; i < bits; i++ {
+		ids[i] = base + circuit.Wire(i)
+	}
+	return ids
+}
+
 // AssignedWires allocates assigned wires for the argument value.
 func (walloc *WireAllocator) AssignedWires(v Value, bits types.Size) (
 	[]*circuits.Wire, error) {
@@ -310,9 +387,11 @@ func (walloc *WireAllocator) SetWires(v Value, w []*circuits.Wire) {
 	}
 	alloc = &allocByValue{
 		key:   v,
+		seq:   walloc.nextSeq,
 		wires: w,
 		ids:   make([]circuit.Wire, len(w)),
 	}
+	walloc.nextSeq++
 	if len(w) == 0 {
 		alloc.base = circuits.UnassignedID
 	} else {
@@ -0,0 +1,68 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const ssaAPIProgram = `
+package main
+func main(a, b uint16) uint16 {
+    return a*b + a
+}
+`
+
+func TestCompileToSSA(t *testing.T) {
+	c := New(utils.NewParams())
+	prog, err := c.CompileToSSA(ssaAPIProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	if len(prog.Steps) != 7 {
+		t.Errorf("got %d steps, expected 7", len(prog.Steps))
+	}
+	ops := make(map[ssa.Operand]int)
+	for _, step := range prog.Steps {
+		ops[step.Instr.Op]++
+	}
+	for op, count := range map[ssa.Operand]int{
+		ssa.Umult: 1,
+		ssa.Uadd:  1,
+		ssa.Mov:   1,
+		ssa.GC:    3,
+		ssa.Ret:   1,
+	} {
+		if ops[op] != count {
+			t.Errorf("got %d %s instructions, expected %d", ops[op], op, count)
+		}
+	}
+
+	circ, err := c.CompileSSAToCircuit(prog)
+	if err != nil {
+		t.Fatalf("CompileSSAToCircuit failed: %v", err)
+	}
+	oneShot, _, err := New(utils.NewParams()).Compile(ssaAPIProgram, nil)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	var a, b bytes.Buffer
+	if err := circ.Marshal(&a); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := oneShot.Marshal(&b); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("circuits differ: %v vs. %v", circ, oneShot)
+	}
+}
@@ -0,0 +1,430 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"context"
+	"math/big"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+const streamRounds = 400
+
+const streamProgram = `
+package main
+func main(a, b uint64) uint64 {
+    r := a
+    for i := 0; i < Rounds; i++ {
+        r = r*b + a
+    }
+    return r
+}
+`
+
+func TestStreamBoundedMemory(t *testing.T) {
+	params := utils.NewParams()
+	params.StreamWindow = 4096
+	params.Defines = map[string]string{
+		"Rounds": strconv.Itoa(streamRounds),
+	}
+
+	prog, err := New(params).CompileToSSA(streamProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+
+	var a, b uint64 = 0x0123456789abcdef, 0xfedcba9876543210
+	expected := a
+	for i := 0; i < streamRounds; i++ {
+		expected = expected*b + a
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	// Sample the peak heap usage during the streaming.
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	baseline := ms.HeapInuse
+	peak := baseline
+
+	done := make(chan bool)
+	sampled := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				close(sampled)
+				return
+			case <-ticker.C:
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				if ms.HeapInuse > peak {
+					peak = ms.HeapInuse
+				}
+			}
+		}
+	}()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{new(big.Int).SetUint64(b).String()}, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	_, values, err := prog.Stream(gConn, ot.NewCO(), params,
+		new(big.Int).SetUint64(a), circuit.NewTiming())
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	eResult := <-ch
+	close(done)
+	<-sampled
+
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	if values[0].Uint64() != expected || eResult.values[0].Uint64() != expected {
+		t.Errorf("got %v (%v), expected %v", values[0], eResult.values[0],
+			expected)
+	}
+
+	xfer := gConn.Stats.Sum()
+	growth := peak - baseline
+	t.Logf("transferred %v, peak heap growth %v",
+		circuit.FileSize(xfer), circuit.FileSize(growth))
+	if growth > xfer/4 {
+		t.Errorf("peak heap growth %v exceeds bound for %v transferred",
+			circuit.FileSize(growth), circuit.FileSize(xfer))
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	params := utils.NewParams()
+	params.Defines = map[string]string{
+		"Rounds": "5000",
+	}
+
+	prog, err := New(params).CompileToSSA(streamProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	gConn, eConn := p2p.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan error)
+	go func() {
+		_, _, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"3"}, false)
+		ch <- err
+	}()
+
+	// Cancel the stream once it is well underway.
+	go func() {
+		for gConn.Stats.Sent.Load() < 1<<20 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	gDone := make(chan error)
+	go func() {
+		_, _, err := prog.StreamContext(ctx, gConn, ot.NewCO(), params,
+			big.NewInt(5), circuit.NewTiming())
+		gDone <- err
+	}()
+
+	timeout := time.After(30 * time.Second)
+	select {
+	case err := <-gDone:
+		if err != context.Canceled {
+			t.Errorf("StreamContext: got %v, expected %v",
+				err, context.Canceled)
+		}
+	case <-timeout:
+		t.Fatalf("StreamContext did not return after cancel")
+	}
+	select {
+	case err := <-ch:
+		if err != circuit.ErrStreamAborted {
+			t.Errorf("StreamEvaluator: got %v, expected %v",
+				err, circuit.ErrStreamAborted)
+		}
+	case <-timeout:
+		t.Fatalf("StreamEvaluator did not return after abort")
+	}
+
+	gConn.Close()
+	eConn.Close()
+
+	// The connection goroutines exit after the connections are
+	// closed.
+	for i := 0; runtime.NumGoroutine() > baseline; i++ {
+		if i >= 100 {
+			t.Fatalf("leaked goroutines: %d > %d",
+				runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamAbortOnError(t *testing.T) {
+	params := utils.NewParams()
+	prog, err := New(params).CompileToSSA(outputsProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	// Slice the first return value with a variable index that the
+	// garbler can't stream.
+	last := len(prog.Steps) - 1
+	ret := prog.Steps[last]
+	out := ret.Instr.In[0]
+	out.Name = "sliced"
+	prog.Steps = append(prog.Steps[:last], ssa.Step{
+		Instr: ssa.Instr{
+			Op:  ssa.Slice,
+			In:  []ssa.Value{ret.Instr.In[0], ret.Instr.In[1], ret.Instr.In[1]},
+			Out: &out,
+		},
+	}, ret)
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	ch := make(chan error)
+	go func() {
+		_, _, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{"3"}, false)
+		ch <- err
+	}()
+	_, _, err = prog.Stream(gConn, ot.NewCO(), params, big.NewInt(5),
+		circuit.NewTiming())
+	if err == nil {
+		t.Fatalf("Stream succeeded with variable slice index")
+	}
+	select {
+	case err := <-ch:
+		if err != circuit.ErrStreamAborted {
+			t.Errorf("StreamEvaluator: got %v, expected %v",
+				err, circuit.ErrStreamAborted)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("StreamEvaluator did not return after abort")
+	}
+}
+
+func TestStreamLabelEncoding(t *testing.T) {
+	params := utils.NewParams()
+	params.LabelEncoding = ot.LabelLSB
+	params.Defines = map[string]string{
+		"Rounds": "10",
+	}
+
+	prog, err := New(params).CompileToSSA(streamProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+
+	var a, b uint64 = 7, 11
+	expected := a
+	for i := 0; i < 10; i++ {
+		expected = expected*b + a
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	eConn.SetLabelEncoding(ot.LabelLSB)
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{new(big.Int).SetUint64(b).String()}, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	_, values, err := prog.Stream(gConn, ot.NewCO(), params,
+		new(big.Int).SetUint64(a), circuit.NewTiming())
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	if gConn.LabelEncoding() != ot.LabelLSB {
+		t.Errorf("garbler label encoding %s, expected %s",
+			gConn.LabelEncoding(), ot.LabelLSB)
+	}
+	if values[0].Uint64() != expected || eResult.values[0].Uint64() != expected {
+		t.Errorf("got %v (%v), expected %v", values[0], eResult.values[0],
+			expected)
+	}
+}
+
+func TestStreamZeroizeLabels(t *testing.T) {
+	params := utils.NewParams()
+	params.ZeroizeLabels = true
+	params.Defines = map[string]string{
+		"Rounds": "10",
+	}
+
+	prog, err := New(params).CompileToSSA(streamProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	var gcs int
+	for _, step := range prog.Steps {
+		if step.Instr.Op == ssa.GC {
+			gcs++
+		}
+	}
+	if gcs == 0 {
+		t.Fatalf("program does not reclaim any values")
+	}
+
+	var a, b uint64 = 7, 11
+	expected := a
+	for i := 0; i < 10; i++ {
+		expected = expected*b + a
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{new(big.Int).SetUint64(b).String()}, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	_, values, err := prog.Stream(gConn, ot.NewCO(), params,
+		new(big.Int).SetUint64(a), circuit.NewTiming())
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	if values[0].Uint64() != expected || eResult.values[0].Uint64() != expected {
+		t.Errorf("got %v (%v), expected %v", values[0], eResult.values[0],
+			expected)
+	}
+}
+
+const streamAliasProgram = `
+package main
+func main(a, b uint32) (uint64, uint32, uint32) {
+    c := uint64(a)
+    d := c + 1
+    e := a * b
+    f := uint32(d >> 1)
+    return d, e, f + a
+}
+`
+
+func TestStreamZeroizeAliases(t *testing.T) {
+	params := utils.NewParams()
+	params.ZeroizeLabels = true
+
+	prog, err := New(params).CompileToSSA(streamAliasProgram, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	var movs int
+	for _, step := range prog.Steps {
+		switch step.Instr.Op {
+		case ssa.Mov, ssa.Slice:
+			movs++
+		}
+	}
+	if movs == 0 {
+		t.Fatalf("program does not have aliasing instructions")
+	}
+
+	var a, b uint32 = 7, 11
+	expected := []uint64{
+		uint64(a) + 1,
+		uint64(a * b),
+		uint64(uint32((uint64(a)+1)>>1) + a),
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		_, values, err := circuit.StreamEvaluator(eConn, ot.NewCO(),
+			[]string{strconv.Itoa(int(b))}, false)
+		ch <- result{
+			values: values,
+			err:    err,
+		}
+	}()
+	_, values, err := prog.Stream(gConn, ot.NewCO(), params,
+		big.NewInt(int64(a)), circuit.NewTiming())
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("StreamEvaluator failed: %v", eResult.err)
+	}
+	for i, e := range expected {
+		if values[i].Uint64() != e || eResult.values[i].Uint64() != e {
+			t.Errorf("result %d: got %v (%v), expected %v",
+				i, values[i], eResult.values[i], e)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var anonymousStructErrors = []struct {
+	code       string
+	diagnostic string
+}{
+	// Cast with different field names.
+	{
+		code: `
+package main
+type Point struct {
+    x, y int32
+}
+func main(a, b int32) int32 {
+    var p Point
+    p.x = a
+    q := struct{ a, b int32 }(p)
+    return q.a
+}
+`,
+		diagnostic: "{data}:9:30: cast from struct64 to struct {a int32, b int32}",
+	},
+	// Cast with different field types.
+	{
+		code: `
+package main
+type Point struct {
+    x, y int32
+}
+func main(a, b int32) int32 {
+    var p Point
+    p.x = a
+    q := struct{ x, y uint32 }(p)
+    return int32(q.x)
+}
+`,
+		diagnostic: "{data}:9:31: cast from struct64 to struct {x uint32, y uint32}",
+	},
+	// Undefined field.
+	{
+		code: `
+package main
+func main(a, b int32) int32 {
+    var p struct{ x, y int32 }
+    p.z = a
+    return p.x
+}
+`,
+		diagnostic: "{data}:5:4: a non-name p.z on left side of :=",
+	},
+}
+
+func TestAnonymousStructErrors(t *testing.T) {
+	for idx, test := range anonymousStructErrors {
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+
+		_, _, err := New(params).Compile(test.code, nil)
+		if err == nil {
+			t.Errorf("test %d: Compile succeeded", idx)
+			continue
+		}
+		if !strings.Contains(buf.String(), test.diagnostic) {
+			t.Errorf("test %d: got diagnostics %q, expected %q",
+				idx, buf.String(), test.diagnostic)
+		}
+	}
+}
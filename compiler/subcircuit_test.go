@@ -0,0 +1,169 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/ssa"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var subcircuitTests = []struct {
+	name string
+	code string
+}{
+	{
+		name: "loop",
+		code: `
+package main
+
+func mix(a, b uint32) uint32 {
+	x := a*b + (a ^ (b >> 3))
+	return x * 3
+}
+
+func main(a, b uint32) uint32 {
+	var r uint32 = a
+	for i := 0; i < 16; i++ {
+		r = mix(r, b)
+	}
+	return r
+}
+`,
+	},
+	{
+		name: "const-args",
+		code: `
+package main
+
+func add(a, b uint32) (uint32, bool) {
+	return a + b, a > b
+}
+
+func main(a, b uint32) (uint32, bool) {
+	r, gt := add(a, b)
+	s, _ := add(r, 7)
+	t, _ := add(s, b)
+	return t, gt
+}
+`,
+	},
+	{
+		name: "package-var",
+		code: `
+package main
+
+var counter uint32
+
+func next(a uint32) uint32 {
+	counter = counter + a
+	return counter
+}
+
+func main(a, b uint32) uint32 {
+	next(a)
+	next(b)
+	return next(a)
+}
+`,
+	},
+}
+
+func compileSubcircuits(t *testing.T, code string, enabled bool) (
+	*circuit.Circuit, int) {
+
+	params := utils.NewParams()
+	params.OptPruneGates = true
+	params.SubcircuitCalls = enabled
+
+	c := New(params)
+	circ, _, err := c.Compile(code, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	return circ, c.Stats.Instructions.Total()
+}
+
+func TestSubcircuitCalls(t *testing.T) {
+	inputs := [][2]int64{
+		{0, 0},
+		{1, 2},
+		{7, 11},
+		{0xffffffff, 3},
+		{123456789, 987654321},
+	}
+	for _, test := range subcircuitTests {
+		inlined, _ := compileSubcircuits(t, test.code, false)
+		shared, _ := compileSubcircuits(t, test.code, true)
+
+		// Inlining can optimize across the call boundaries so the
+		// subcircuits may cost a few extra gates.
+		if shared.NumGates > inlined.NumGates*101/100 {
+			t.Errorf("%s: subcircuits increased gates: %d > %d",
+				test.name, shared.NumGates, inlined.NumGates)
+		}
+		for _, input := range inputs {
+			args := []*big.Int{
+				big.NewInt(input[0]),
+				big.NewInt(input[1]),
+			}
+			expected, err := inlined.Compute(args)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			got, err := shared.Compute(args)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if len(got) != len(expected) {
+				t.Fatalf("%s%v: got %v, expected %v",
+					test.name, input, got, expected)
+			}
+			for i := range got {
+				if got[i].Cmp(expected[i]) != 0 {
+					t.Errorf("%s%v: got %v, expected %v",
+						test.name, input, got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestSubcircuitCallsShared(t *testing.T) {
+	params := utils.NewParams()
+	params.SubcircuitCalls = true
+
+	prog, err := New(params).CompileToSSA(subcircuitTests[0].code, nil)
+	if err != nil {
+		t.Fatalf("CompileToSSA failed: %v", err)
+	}
+	circs := make(map[*circuit.Circuit]int)
+	for _, step := range prog.Steps {
+		if step.Instr.Op == ssa.Circ {
+			circs[step.Instr.Circ]++
+		}
+	}
+	if len(circs) != 1 {
+		t.Fatalf("got %d subcircuits, expected 1", len(circs))
+	}
+	for _, count := range circs {
+		if count != 16 {
+			t.Errorf("subcircuit called %d times, expected 16",
+				count)
+		}
+	}
+
+	_, inlined := compileSubcircuits(t, subcircuitTests[0].code, false)
+	_, shared := compileSubcircuits(t, subcircuitTests[0].code, true)
+	if shared >= inlined {
+		t.Errorf("subcircuits generated %d instructions, inlining %d",
+			shared, inlined)
+	}
+}
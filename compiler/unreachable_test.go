@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+type UnreachableTest struct {
+	Name  string
+	Code  string
+	Lines []string
+}
+
+var reUnreachable = regexp.MustCompile(
+	`(?m)^\{data\}:([0-9]+:[0-9]+): warning: unreachable code$`)
+
+var unreachableTests = []UnreachableTest{
+	{
+		Name: "none",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    if a > b {
+        return a
+    }
+    return b
+}
+`,
+	},
+	{
+		Name: "after return",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    return a
+    a = b
+    return b
+}
+`,
+		Lines: []string{"5:4", "6:4"},
+	},
+	{
+		Name: "after if-else",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    if a > b {
+        return a
+    } else {
+        return b
+    }
+    return a + b
+}
+`,
+		Lines: []string{"9:4"},
+	},
+	{
+		Name: "inside branch",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    if a > b {
+        return a
+        a = b
+    }
+    return b
+}
+`,
+		Lines: []string{"6:8"},
+	},
+	{
+		Name: "after loop",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for i := 0; i < 10; i++ {
+        return a
+    }
+    return b
+}
+`,
+		Lines: []string{"7:4"},
+	},
+	{
+		Name: "after infinite loop",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    for {
+        a = a + b
+        return a
+        b = a
+    }
+    a = b
+    return b
+}
+`,
+		Lines: []string{"7:8", "9:4", "10:4"},
+	},
+	{
+		Name: "called twice",
+		Code: `
+package main
+func main(a, b int32) int32 {
+    return f(a) + f(b)
+}
+func f(a int32) int32 {
+    return a
+    return 0
+}
+`,
+		Lines: []string{"8:4"},
+	},
+}
+
+func TestUnreachable(t *testing.T) {
+	for _, test := range unreachableTests {
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+		params.NoCircCompile = true
+
+		_, _, err := New(params).Compile(test.Code, nil)
+		if err != nil {
+			t.Fatalf("%s: compile failed: %s", test.Name, err)
+		}
+		var lines []string
+		for _, m := range reUnreachable.FindAllStringSubmatch(buf.String(),
+			-1) {
+			lines = append(lines, m[1])
+		}
+		if !reflect.DeepEqual(lines, test.Lines) {
+			t.Errorf("%s: got warnings at %v, expected %v\n%s",
+				test.Name, lines, test.Lines, buf.String())
+		}
+	}
+}
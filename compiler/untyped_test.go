@@ -0,0 +1,52 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+var untypedOverflowTests = []struct {
+	typ        string
+	result     string
+	expr       string
+	diagnostic string
+}{
+	{"uint8", "uint8", "x + 256", "{data}:4:13: constant 256 overflows uint8"},
+	{"uint8", "uint8", "300 - x", "{data}:4:15: constant 300 overflows uint8"},
+	{"int8", "int8", "x * 128", "{data}:4:13: constant 128 overflows int8"},
+	{"uint16", "uint16", "x + -1", "{data}:4:13: constant -1 overflows uint16"},
+	{"int8", "bool", "x == -129", "{data}:4:13: constant -129 overflows int8"},
+}
+
+func TestUntypedConstOverflow(t *testing.T) {
+	for _, test := range untypedOverflowTests {
+		code := `
+package main
+func main(x, y ` + test.typ + `) ` + test.result + ` {
+    return ` + test.expr + `
+}
+`
+		var buf bytes.Buffer
+		params := utils.NewParams()
+		params.LogOut = &buf
+
+		_, _, err := New(params).Compile(code, nil)
+		if err == nil || !strings.Contains(err.Error(), "overflows") {
+			t.Errorf("%s (%s): expected overflow error, got %v",
+				test.expr, test.typ, err)
+		}
+		if !strings.Contains(buf.String(), test.diagnostic) {
+			t.Errorf("%s (%s): got diagnostics %q, expected %q",
+				test.expr, test.typ, buf.String(), test.diagnostic)
+		}
+	}
+}
@@ -13,9 +13,49 @@ import (
 	"strings"
 )
 
-// Logger implements compiler logging facility.
+// Logger implements compiler logging facility. The logger also
+// records the logged messages as diagnostics.
 type Logger struct {
-	out io.Writer
+	out         io.Writer
+	diagnostics []Diagnostic
+}
+
+// Severity specifies the severity of a diagnostic.
+type Severity int
+
+// Diagnostic severities.
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("{Severity %d}", s)
+	}
+}
+
+// Diagnostic describes an error or a warning at a source location.
+type Diagnostic struct {
+	Point
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	loc := d.Point.String()
+	if d.Undefined() {
+		loc = d.Source
+	}
+	if d.Severity == SeverityWarning {
+		return fmt.Sprintf("%s: warning: %s", loc, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", loc, d.Message)
 }
 
 // NewLogger creates a new logger outputting to the argument io.Writer.
@@ -37,10 +77,12 @@ func (l *Logger) Errorf(loc Point, format string, a ...interface{}) error {
 		fmt.Fprintf(l.out, "%s: %s", loc, msg)
 	}
 
-	idx := strings.IndexRune(msg, '\n')
-	if idx > 0 {
-		msg = msg[:idx]
-	}
+	msg = firstLine(msg)
+	l.record(Diagnostic{
+		Point:    loc,
+		Severity: SeverityError,
+		Message:  msg,
+	})
 	return errors.New(msg)
 }
 
@@ -55,4 +97,34 @@ func (l *Logger) Warningf(loc Point, format string, a ...interface{}) {
 	} else {
 		fmt.Fprintf(l.out, "%s: warning: %s", loc, msg)
 	}
+	l.record(Diagnostic{
+		Point:    loc,
+		Severity: SeverityWarning,
+		Message:  firstLine(msg),
+	})
+}
+
+// record records the diagnostic. The messages that the compiler logs
+// several times for the same location are recorded only once.
+func (l *Logger) record(d Diagnostic) {
+	for _, o := range l.diagnostics {
+		if o == d {
+			return
+		}
+	}
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+// Diagnostics returns the diagnostics of the logged messages in their
+// logging order.
+func (l *Logger) Diagnostics() []Diagnostic {
+	return l.diagnostics
+}
+
+func firstLine(msg string) string {
+	idx := strings.IndexRune(msg, '\n')
+	if idx > 0 {
+		return msg[:idx]
+	}
+	return msg
 }
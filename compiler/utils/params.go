@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2020-2023 Markku Rossi
+// Copyright (c) 2020-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -7,7 +7,12 @@
 package utils
 
 import (
+	"fmt"
 	"io"
+	"io/fs"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
 )
 
 // Params specify compiler parameters.
@@ -16,18 +21,62 @@ type Params struct {
 	Diagnostics   bool
 	SSAOut        io.WriteCloser
 	SSADotOut     io.WriteCloser
+	SSACFGOut     io.WriteCloser
 	MPCLCErrorLoc bool
 
+	// LogOut specifies the output for compiler errors and
+	// warnings. If unset, messages are printed to os.Stdout.
+	LogOut io.Writer
+
+	// AllErrors specifies if the parser reports all syntax errors
+	// of a source file. If set, the parser continues from the next
+	// statement or top-level declaration after a syntax error and
+	// returns the combined errors at the end of the file. If unset,
+	// the parser stops at the first error.
+	AllErrors bool
+
 	// PkgPath defines additional directories to search for imported
 	// packages.
 	PkgPath []string
 
+	// PkgFS specifies the file system for the standard library
+	// packages. If set, the compiler loads the standard library from
+	// PkgFS instead of resolving the package root directory from the
+	// disk. The pkg package provides the standard library as an
+	// embedded file system.
+	PkgFS fs.FS
+
 	// MaxVarBits specifies the maximum variable width in bits.
 	MaxVarBits int
 
 	// MaxLoopUnroll specifies the upper limit for loop unrolling.
 	MaxLoopUnroll int
 
+	// LoopUnrollWarn specifies the soft limit for loop unrolling. The
+	// compiler warns about loops that it estimates to unroll more than
+	// LoopUnrollWarn times. The value 0 disables the warning.
+	LoopUnrollWarn int
+
+	// MaxInstructions specifies the upper limit for the number of
+	// SSA instructions that the compiler generates. The compilation
+	// fails with an error naming the function that exceeded the
+	// limit. The value 0 disables the limit.
+	MaxInstructions int
+
+	// IndexPolicy specifies how out-of-bounds array indices are
+	// handled.
+	IndexPolicy IndexPolicy
+
+	// NoBoundsCheck specifies that the non-constant array indices
+	// are assumed to be within the array bounds. The compiler omits
+	// the bounds guards of the index circuits which makes the
+	// circuits smaller. This is unsafe for untrusted code: if the
+	// assumption is violated, an out-of-bounds index selects an
+	// unspecified array element instead of the zero value of the
+	// IndexPolicy. The constant indices are still checked at compile
+	// time.
+	NoBoundsCheck bool
+
 	NoCircCompile bool
 	CircOut       io.WriteCloser
 	CircDotOut    io.WriteCloser
@@ -36,17 +85,148 @@ type Params struct {
 
 	CircMultArrayTreshold int
 
+	// CircMultTreeTreshold specifies the operand size in bits from
+	// which the multiplications use the tree multiplier instead of
+	// the Karatsuba and array multipliers. The tree multiplier has a
+	// smaller circuit depth on large operands. The value 0 disables
+	// the tree multiplier.
+	CircMultTreeTreshold int
+
+	// CostModel specifies the gate costs for the circuit cost
+	// estimates.
+	CostModel circuit.CostModel
+
 	OptPruneGates bool
 
+	// OptCoalesceMovs specifies if the compiler removes the mov
+	// instructions that copy values between the values of identical
+	// types. The uses of the copies are replaced with the original
+	// values.
+	OptCoalesceMovs bool
+
+	// SubcircuitCalls specifies if the function calls with
+	// non-constant arguments are compiled into subcircuits instead of
+	// inlining the called functions. Each function is compiled once
+	// for each combination of argument types and the call sites share
+	// the compiled subcircuit. The functions that use package
+	// variables or channels are always inlined.
+	SubcircuitCalls bool
+
+	// NoEvalCache disables the memoization of the constant folding
+	// results.
+	NoEvalCache bool
+
+	// StreamWindow specifies the streaming flow-control window in
+	// gates. The garbler stops to wait for the evaluator when it is
+	// more than one window ahead of it. If unset, the streaming uses
+	// circuit.DefaultStreamWindow.
+	StreamWindow int
+
+	// LabelEncoding specifies the on-wire encoding of the wire labels
+	// in the streaming mode. The evaluator must use the same
+	// encoding.
+	LabelEncoding ot.LabelEncoding
+
+	// ZeroizeLabels specifies if the streaming garbler clears the
+	// wire labels of the values that the GC instructions reclaim.
+	// The zeroization limits the lifetime of the secret labels in
+	// the garbler's memory.
+	ZeroizeLabels bool
+
+	// MaxOTs, MaxGates, and MaxWires limit the evaluator's work. The
+	// evaluator rejects the garbler's input sizes and circuits that
+	// exceed the limits. The value 0 disables the limit. See
+	// circuit.Limits for details.
+	MaxOTs   int
+	MaxGates int
+	MaxWires int
+
+	// Defines specifies compile-time constants that are accessible
+	// in MPCL as predeclared identifiers. The constant values are
+	// typed by their literal form.
+	Defines map[string]string
+
+	// InputWidths specifies the bit widths of the main function
+	// arguments. The widths are indexed like the input sizes: first
+	// by the argument and then by the argument's value. The widths
+	// override the input sizes of the unspecified-size arguments and
+	// they must match the sizes of the concrete arguments. The zero
+	// width keeps the size of the input value.
+	InputWidths [][]int
+
+	// OutputIndices selects the main function return values that
+	// the compiled circuit outputs. If unset, the circuit outputs
+	// all return values.
+	OutputIndices []int
+
+	// OutputModes specifies how the main function return values are
+	// resolved in the streaming mode. The map is indexed with the
+	// return value indices. The return values without an explicit
+	// mode are revealed to both parties.
+	OutputModes map[int]circuit.OutputMode
+
 	BenchmarkCompile bool
 }
 
+// Limits returns the evaluator limits of the parameters.
+func (p *Params) Limits() circuit.Limits {
+	return circuit.Limits{
+		MaxOTs:   p.MaxOTs,
+		MaxGates: p.MaxGates,
+		MaxWires: p.MaxWires,
+	}
+}
+
+// IndexPolicy specifies how non-constant array indices that are out
+// of the array bounds are handled.
+type IndexPolicy int
+
+// Index policies.
+const (
+	// IndexZero returns the zero value for out-of-bounds indices.
+	IndexZero IndexPolicy = iota
+	// IndexFlag returns the zero value for out-of-bounds indices
+	// and allows programs to test the bounds with the comma-ok index
+	// expression v, ok := arr[i].
+	IndexFlag
+)
+
+var indexPolicies = map[IndexPolicy]string{
+	IndexZero: "zero",
+	IndexFlag: "flag",
+}
+
+func (p IndexPolicy) String() string {
+	name, ok := indexPolicies[p]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{IndexPolicy %d}", p)
+}
+
+// ParseIndexPolicy parses the index policy name.
+func ParseIndexPolicy(name string) (IndexPolicy, error) {
+	for k, v := range indexPolicies {
+		if v == name {
+			return k, nil
+		}
+	}
+	return IndexZero, fmt.Errorf("unknown index policy: %s", name)
+}
+
+// PkgFSSource is the source name prefix of the files that are loaded
+// from Params.PkgFS.
+const PkgFSSource = "{pkg}"
+
 // NewParams returns new compiler params object, initialized with the
 // default values.
 func NewParams() *Params {
 	return &Params{
-		MaxVarBits:    0x20000,
-		MaxLoopUnroll: 0x20000,
+		MaxVarBits:           0x20000,
+		MaxLoopUnroll:        0x20000,
+		LoopUnrollWarn:       0x4000,
+		CircMultTreeTreshold: 64,
+		CostModel:            circuit.DefaultCostModel(),
 	}
 }
 
@@ -60,6 +240,10 @@ func (p *Params) Close() {
 		p.SSADotOut.Close()
 		p.SSADotOut = nil
 	}
+	if p.SSACFGOut != nil {
+		p.SSACFGOut.Close()
+		p.SSACFGOut = nil
+	}
 	if p.CircOut != nil {
 		p.CircOut.Close()
 		p.CircOut = nil
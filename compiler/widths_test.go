@@ -0,0 +1,132 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package compiler
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/compiler/utils"
+)
+
+const widthsProgram = `
+package main
+func main(a, b uint) uint {
+    return a*b + a
+}
+`
+
+func TestInputWidths(t *testing.T) {
+	var prev *circuit.Circuit
+	for _, bits := range []int{8, 16, 32, 64} {
+		params := utils.NewParams()
+		params.InputWidths = [][]int{{bits}, {bits}}
+		circ, _, err := New(params).Compile(widthsProgram, nil)
+		if err != nil {
+			t.Fatalf("%d bits: Compile failed: %v", bits, err)
+		}
+		for i, io := range []circuit.IO{
+			circ.Inputs[:1], circ.Inputs[1:], circ.Outputs,
+		} {
+			if io.Size() != bits {
+				t.Errorf("%d bits: I/O %d: got %d bits", bits, i, io.Size())
+			}
+		}
+		if prev != nil && circ.NumGates <= prev.NumGates {
+			t.Errorf("%d bits: got %d gates, expected more than %d",
+				bits, circ.NumGates, prev.NumGates)
+		}
+		prev = circ
+
+		a := big.NewInt(200)
+		b := big.NewInt(3)
+		mask := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		expected := new(big.Int).Mul(a, b)
+		expected.Add(expected, a)
+		expected.Mod(expected, mask)
+		result, err := circuit.RunLocal(circ, a, b)
+		if err != nil {
+			t.Fatalf("%d bits: RunLocal failed: %v", bits, err)
+		}
+		if result[0].Cmp(expected) != 0 {
+			t.Errorf("%d bits: got %v, expected %v", bits, result[0], expected)
+		}
+	}
+}
+
+func TestInputWidthsOverride(t *testing.T) {
+	// The widths override the sizes of the input values.
+	params := utils.NewParams()
+	params.InputWidths = [][]int{{32}, {0}}
+	circ, _, err := New(params).Compile(`
+package main
+func main(a, b uint) (uint, uint) {
+    return a, b
+}
+`, [][]int{{8}, {16}})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if circ.Inputs[0].Type.Bits != 32 || circ.Inputs[1].Type.Bits != 16 {
+		t.Errorf("got inputs %v, expected uint32 and uint16", circ.Inputs)
+	}
+}
+
+func TestInputWidthsInvalid(t *testing.T) {
+	tests := []struct {
+		code    string
+		widths  [][]int
+		message string
+	}{
+		{
+			code: `
+package main
+func main(a, b int32) int32 {
+    return a + b
+}
+`,
+			widths:  [][]int{{16}, {32}},
+			message: "type int32 does not support width 16",
+		},
+		{
+			code: `
+package main
+func main(a, b []uint8) uint8 {
+    return a[0] + b[0]
+}
+`,
+			widths:  [][]int{{20}, {16}},
+			message: "type []uint8 does not support width 20",
+		},
+		{
+			code: `
+package main
+func main(a, b uint) uint {
+    return a + b
+}
+`,
+			widths:  [][]int{{16}},
+			message: "argument b of func main(a, b uint) uint has unspecified",
+		},
+	}
+	for _, test := range tests {
+		params := utils.NewParams()
+		params.InputWidths = test.widths
+		params.LogOut = &bytes.Buffer{}
+		_, _, err := New(params).Compile(test.code, nil)
+		if err == nil {
+			t.Errorf("Compile succeeded for widths %v", test.widths)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("got error %q, expected %q", err, test.message)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 //
 // co.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -254,6 +254,22 @@ type CO struct {
 	hash   hash.Hash
 	digest []byte
 	io     IO
+	batch  *coBatch
+}
+
+// coBatch holds the state of an incremental batch transfer. The
+// sender's random value a and its public value A are established with
+// the first batch and reused in all subsequent batches. The count
+// holds the number of transfers done so far and it is used as the
+// transfer ID for the key derivation so that the incremental batches
+// are equal to one large batch.
+type coBatch struct {
+	a      []byte
+	Ax     *big.Int
+	Ay     *big.Int
+	AaInvx *big.Int
+	AaInvy *big.Int
+	count  uint64
 }
 
 // NewCO creates a new CO OT implementing the OT interface.
@@ -268,6 +284,7 @@ func NewCO() *CO {
 // InitSender initializes the OT sender.
 func (co *CO) InitSender(io IO) error {
 	co.io = io
+	co.batch = nil
 	if err := SendString(io, co.curve.Params().Name); err != nil {
 		return err
 	}
@@ -277,6 +294,7 @@ func (co *CO) InitSender(io IO) error {
 // InitReceiver initializes the OT receiver.
 func (co *CO) InitReceiver(io IO) error {
 	co.io = io
+	co.batch = nil
 
 	name, err := ReceiveString(io)
 	if err != nil {
@@ -291,12 +309,34 @@ func (co *CO) InitReceiver(io IO) error {
 
 // Send sends the wire labels with OT.
 func (co *CO) Send(wires []Wire) error {
+	batch, err := co.sendSetup()
+	if err != nil {
+		return incomplete(0, err)
+	}
+	return co.send(batch, wires)
+}
+
+// SendBatch sends the wire labels with OT as the next batch of an
+// incremental transfer. The first batch runs the setup and all
+// subsequent batches continue the same transfer.
+func (co *CO) SendBatch(wires []Wire) error {
+	if co.batch == nil {
+		batch, err := co.sendSetup()
+		if err != nil {
+			return incomplete(0, err)
+		}
+		co.batch = batch
+	}
+	return co.send(co.batch, wires)
+}
+
+func (co *CO) sendSetup() (*coBatch, error) {
 	curveParams := co.curve.Params()
 
 	// a <- Zp
 	a, err := rand.Int(rand.Reader, curveParams.N)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	aBytes := a.Bytes()
 
@@ -304,13 +344,13 @@ func (co *CO) Send(wires []Wire) error {
 	Ax, Ay := co.curve.ScalarBaseMult(aBytes)
 
 	if err := co.io.SendData(Ax.Bytes()); err != nil {
-		return err
+		return nil, err
 	}
 	if err := co.io.SendData(Ay.Bytes()); err != nil {
-		return err
+		return nil, err
 	}
 	if err := co.io.Flush(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Aa = A^a
@@ -319,9 +359,24 @@ func (co *CO) Send(wires []Wire) error {
 	// a:    {x,y}
 	// a^-1: {x,-y}
 	// AaInv = {Aax, -Aay}
-	AaInvx := big.NewInt(0).Set(Aax)
-	AaInvy := big.NewInt(0).Sub(curveParams.P, Aay)
+	return &coBatch{
+		a:      aBytes,
+		Ax:     Ax,
+		Ay:     Ay,
+		AaInvx: big.NewInt(0).Set(Aax),
+		AaInvy: big.NewInt(0).Sub(curveParams.P, Aay),
+	}, nil
+}
+
+// send sends the wires with OT. The function returns the number of
+// completed transfers as an IncompleteError if the transfer fails.
+func (co *CO) send(batch *coBatch, wires []Wire) error {
+	completed, err := co.sendTransfers(batch, wires)
+	batch.count += uint64(completed)
+	return incomplete(completed, err)
+}
 
+func (co *CO) sendTransfers(batch *coBatch, wires []Wire) (int, error) {
 	BxRaw := big.NewInt(0)
 	ByRaw := big.NewInt(0)
 
@@ -334,17 +389,17 @@ func (co *CO) Send(wires []Wire) error {
 	for i := 0; i < wiresCnt; i++ {
 		data, err := co.io.ReceiveData()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		BxRaw.SetBytes(data)
 		data, err = co.io.ReceiveData()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		ByRaw.SetBytes(data)
 
-		Bx, By := co.curve.ScalarMult(BxRaw, ByRaw, aBytes)
-		Bax, Bay := co.curve.Add(Bx, By, AaInvx, AaInvy)
+		Bx, By := co.curve.ScalarMult(BxRaw, ByRaw, batch.a)
+		Bax, Bay := co.curve.Add(Bx, By, batch.AaInvx, batch.AaInvy)
 
 		Bxs[i] = Bx
 		Bys[i] = By
@@ -352,6 +407,7 @@ func (co *CO) Send(wires []Wire) error {
 		Bays[i] = Bay
 	}
 
+	enc := IOLabelEncoding(co.io)
 	for i := 0; i < wiresCnt; i++ {
 		var labelData LabelData
 
@@ -359,39 +415,76 @@ func (co *CO) Send(wires []Wire) error {
 		By := Bys[i]
 		Bax := Baxs[i]
 		Bay := Bays[i]
+		id := batch.count + uint64(i)
 
-		wires[i].L0.GetData(&labelData)
-		e0 := xor(kdf(co.hash, Bx, By, uint64(i), co.digest[:]), labelData[:])
+		enc.Encode(wires[i].L0, &labelData)
+		e0 := xor(kdf(co.hash, Bx, By, id, co.digest[:]), labelData[:])
 		if err := co.io.SendData(e0); err != nil {
-			return err
+			return i, err
 		}
-		wires[i].L1.GetData(&labelData)
-		e1 := xor(kdf(co.hash, Bax, Bay, uint64(i), co.digest[:]), labelData[:])
+		enc.Encode(wires[i].L1, &labelData)
+		e1 := xor(kdf(co.hash, Bax, Bay, id, co.digest[:]), labelData[:])
 		if err := co.io.SendData(e1); err != nil {
-			return err
+			return i, err
 		}
 	}
-
-	if err := co.io.Flush(); err != nil {
-		return err
-	}
-
-	return nil
+	return wiresCnt, co.io.Flush()
 }
 
 // Receive receives the wire labels with OT based on the flag values.
 func (co *CO) Receive(flags []bool, result []Label) error {
-	curveParams := co.curve.Params()
+	batch, err := co.receiveSetup()
+	if err != nil {
+		return incomplete(0, err)
+	}
+	return co.receive(batch, flags, result)
+}
+
+// ReceiveBatch receives the wire labels with OT based on the flag
+// values as the next batch of an incremental transfer. The first
+// batch runs the setup and all subsequent batches continue the same
+// transfer.
+func (co *CO) ReceiveBatch(flags []bool, result []Label) error {
+	if co.batch == nil {
+		batch, err := co.receiveSetup()
+		if err != nil {
+			return incomplete(0, err)
+		}
+		co.batch = batch
+	}
+	return co.receive(co.batch, flags, result)
+}
 
+func (co *CO) receiveSetup() (*coBatch, error) {
 	Ax, err := ReceiveBigInt(co.io)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	Ay, err := ReceiveBigInt(co.io)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	return &coBatch{
+		Ax: Ax,
+		Ay: Ay,
+	}, nil
+}
+
+// receive receives the labels with OT. The function returns the
+// number of completed transfers as an IncompleteError if the transfer
+// fails.
+func (co *CO) receive(batch *coBatch, flags []bool, result []Label) error {
+	completed, err := co.receiveTransfers(batch, flags, result)
+	batch.count += uint64(completed)
+	return incomplete(completed, err)
+}
+
+func (co *CO) receiveTransfers(batch *coBatch, flags []bool,
+	result []Label) (int, error) {
+
+	curveParams := co.curve.Params()
+
 	flagsCnt := len(flags)
 	BsBytes := make([][]byte, flagsCnt)
 
@@ -399,62 +492,65 @@ func (co *CO) Receive(flags []bool, result []Label) error {
 		// b <= Zp
 		b, err := rand.Int(rand.Reader, curveParams.N)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		bBytes := b.Bytes()
 
 		Bx, By := co.curve.ScalarBaseMult(bBytes)
 		if flags[i] {
-			Bx, By = co.curve.Add(Bx, By, Ax, Ay)
+			Bx, By = co.curve.Add(Bx, By, batch.Ax, batch.Ay)
 		}
 		if err := co.io.SendData(Bx.Bytes()); err != nil {
-			return err
+			return 0, err
 		}
 		if err := co.io.SendData(By.Bytes()); err != nil {
-			return err
+			return 0, err
 		}
 
 		BsBytes[i] = bBytes
 	}
 
 	if err := co.io.Flush(); err != nil {
-		return err
+		return 0, err
 	}
 
+	enc := IOLabelEncoding(co.io)
+	var labelData LabelData
 	for i := 0; i < flagsCnt; i++ {
 		bBytes := BsBytes[i]
-		Asx, Asy := co.curve.ScalarMult(Ax, Ay, bBytes)
+		Asx, Asy := co.curve.ScalarMult(batch.Ax, batch.Ay, bBytes)
 
 		// Receive E. Please, be careful when editing the code below
 		// since the co.digest will be used as data after kdf()
 		// call. Also, data received from co.io can be overridden by
 		// the next call so we do the xor() as soon as we received the
 		// data.
-		data := kdf(co.hash, Asx, Asy, uint64(i), co.digest[:])
+		data := kdf(co.hash, Asx, Asy, batch.count+uint64(i), co.digest[:])
 		var e []byte
+		var err error
 		if flags[i] {
 			_, err = co.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 			e, err := co.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 			data = xor(data, e)
 		} else {
 			e, err = co.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 			data = xor(data, e)
 			_, err := co.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 		}
-		result[i].SetBytes(data)
+		copy(labelData[:], data)
+		enc.Decode(&result[i], &labelData)
 	}
-
-	return nil
+	return flagsCnt, nil
 }
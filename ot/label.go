@@ -55,9 +55,9 @@ func NewLabel(rand io.Reader) (Label, error) {
 }
 
 // NewTweak creates a new label from the tweak value.
-func NewTweak(tweak uint32) Label {
+func NewTweak(tweak uint64) Label {
 	return Label{
-		D1: uint64(tweak),
+		D1: tweak,
 	}
 }
 
@@ -118,3 +118,94 @@ func (l *Label) SetBytes(data []byte) {
 	l.D0 = binary.BigEndian.Uint64(data[0:8])
 	l.D1 = binary.BigEndian.Uint64(data[8:16])
 }
+
+// LabelEncoding defines the on-wire encoding of the 128 bit wire
+// labels. The encoding only affects how the labels are serialized to
+// the peer. The garbling scheme uses the point-and-permute S bit in
+// all encodings since the half-gates garbling depends on it.
+type LabelEncoding byte
+
+// Label encodings.
+const (
+	// LabelMSB encodes the label as a big-endian 128 bit value. The S
+	// bit is the most significant bit of the first byte. This is the
+	// default encoding.
+	LabelMSB LabelEncoding = iota
+
+	// LabelLSB encodes the label so that the S bit is the least
+	// significant bit of the first byte. The label is rotated left by
+	// one bit, moving the S bit to the least significant bit, and the
+	// resulting 128 bit value is encoded in little-endian byte
+	// order. This matches libraries which take the point-and-permute
+	// bit from the low bit of the label block.
+	LabelLSB
+)
+
+var labelEncodings = map[LabelEncoding]string{
+	LabelMSB: "msb",
+	LabelLSB: "lsb",
+}
+
+func (e LabelEncoding) String() string {
+	name, ok := labelEncodings[e]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{LabelEncoding %d}", e)
+}
+
+// ParseLabelEncoding parses the label encoding name.
+func ParseLabelEncoding(name string) (LabelEncoding, error) {
+	for k, v := range labelEncodings {
+		if v == name {
+			return k, nil
+		}
+	}
+	return LabelMSB, fmt.Errorf("unknown label encoding: %s", name)
+}
+
+// Encode encodes the label l into buf.
+func (e LabelEncoding) Encode(l Label, buf *LabelData) {
+	switch e {
+	case LabelLSB:
+		d0 := l.D0<<1 | l.D1>>63
+		d1 := l.D1<<1 | l.D0>>63
+		binary.LittleEndian.PutUint64(buf[0:8], d1)
+		binary.LittleEndian.PutUint64(buf[8:16], d0)
+
+	default:
+		l.GetData(buf)
+	}
+}
+
+// Decode decodes the label l from buf.
+func (e LabelEncoding) Decode(l *Label, buf *LabelData) {
+	switch e {
+	case LabelLSB:
+		d1 := binary.LittleEndian.Uint64(buf[0:8])
+		d0 := binary.LittleEndian.Uint64(buf[8:16])
+		l.D0 = d0>>1 | d1<<63
+		l.D1 = d1>>1 | d0<<63
+
+	default:
+		l.SetData(buf)
+	}
+}
+
+// LabelEncoder is implemented by IO connections that encode labels
+// with a configurable label encoding.
+type LabelEncoder interface {
+	// LabelEncoding returns the connection's label encoding.
+	LabelEncoding() LabelEncoding
+}
+
+// IOLabelEncoding returns the label encoding of the connection
+// io. The function returns LabelMSB if io does not implement the
+// LabelEncoder interface.
+func IOLabelEncoding(io IO) LabelEncoding {
+	enc, ok := io.(LabelEncoder)
+	if ok {
+		return enc.LabelEncoding()
+	}
+	return LabelMSB
+}
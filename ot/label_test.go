@@ -9,6 +9,7 @@
 package ot
 
 import (
+	"crypto/rand"
 	"testing"
 )
 
@@ -90,3 +91,52 @@ func TestLabel(t *testing.T) {
 		t.Errorf("Xor failed: D1=%x, expected=%x", label.D1, val)
 	}
 }
+
+func TestLabelEncoding(t *testing.T) {
+	label := Label{
+		D0: 0x8000000000000001,
+		D1: 0x0102030405060708,
+	}
+	var data LabelData
+
+	LabelMSB.Encode(label, &data)
+	if data[0] != 0x80 || data[15] != 0x08 {
+		t.Errorf("LabelMSB: invalid encoding: %x", data)
+	}
+
+	LabelLSB.Encode(label, &data)
+	if data[0]&1 != 1 {
+		t.Errorf("LabelLSB: S bit not in the LSB of the first byte: %x", data)
+	}
+	expected := LabelData{
+		0x11, 0x0e, 0x0c, 0x0a, 0x08, 0x06, 0x04, 0x02,
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if data != expected {
+		t.Errorf("LabelLSB: got %x, expected %x", data, expected)
+	}
+
+	for _, enc := range []LabelEncoding{LabelMSB, LabelLSB} {
+		for i := 0; i < 100; i++ {
+			l, err := NewLabel(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.SetS(i%2 == 0)
+			enc.Encode(l, &data)
+
+			var decoded Label
+			enc.Decode(&decoded, &data)
+			if !decoded.Equal(l) {
+				t.Fatalf("%s: round-trip failed: %s != %s", enc, decoded, l)
+			}
+		}
+		parsed, err := ParseLabelEncoding(enc.String())
+		if err != nil || parsed != enc {
+			t.Errorf("ParseLabelEncoding(%s) failed: %v %v", enc, parsed, err)
+		}
+	}
+	if _, err := ParseLabelEncoding("native"); err == nil {
+		t.Errorf("ParseLabelEncoding accepted unknown encoding")
+	}
+}
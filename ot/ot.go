@@ -1,12 +1,61 @@
 //
 // ot.go
 //
-// Copyright (c) 2023 Markku Rossi
+// Copyright (c) 2023-2024 Markku Rossi
 //
 // All rights reserved.
 
 package ot
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOTIncomplete is reported when an OT transfer fails before it
+// has transferred all labels. The error is an IncompleteError that
+// specifies the number of completed transfers.
+var ErrOTIncomplete = errors.New("ot: transfer incomplete")
+
+// IncompleteError describes a partially completed OT transfer. The
+// Completed transfers are done and the caller can resume the transfer
+// by transferring the remaining labels after re-initializing the OT
+// with a new connection. The receiver has the labels of its Completed
+// transfers so its count is authoritative. The sender's count is the
+// number of transfers it has written to the connection, which may be
+// greater than the number of transfers the receiver received.
+type IncompleteError struct {
+	Completed int
+	Err       error
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf("%s after %d transfers: %s",
+		ErrOTIncomplete, e.Completed, e.Err)
+}
+
+// Is tests if the target error is ErrOTIncomplete.
+func (e *IncompleteError) Is(target error) bool {
+	return target == ErrOTIncomplete
+}
+
+// Unwrap returns the error that stopped the transfer.
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// incomplete wraps the non-nil err into an IncompleteError with the
+// number of completed transfers.
+func incomplete(completed int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &IncompleteError{
+		Completed: completed,
+		Err:       err,
+	}
+}
+
 // OT defines Oblivious Transfer protocol.
 type OT interface {
 	// InitSender initializes the OT sender.
@@ -15,9 +64,22 @@ type OT interface {
 	// InitReceiver initializes the OT receiver.
 	InitReceiver(io IO) error
 
-	// Send sends the wire labels with OT.
+	// Send sends the wire labels with OT. If the transfer fails,
+	// the error is an IncompleteError.
 	Send(wires []Wire) error
 
-	// Receive receives the wire labels with OT based on the flag values.
+	// Receive receives the wire labels with OT based on the flag
+	// values. If the transfer fails, the error is an
+	// IncompleteError and the labels of the completed transfers are
+	// stored in result.
 	Receive(flags []bool, result []Label) error
+
+	// SendBatch sends the wire labels with OT as the next batch of
+	// an incremental transfer. Subsequent batches continue the
+	// transfer without re-running its setup.
+	SendBatch(wires []Wire) error
+
+	// ReceiveBatch receives the wire labels with OT as the next
+	// batch of an incremental transfer.
+	ReceiveBatch(flags []bool, result []Label) error
 }
@@ -1,7 +1,7 @@
 //
 // ot_test.go
 //
-// Copyright (c) 2023 Markku Rossi
+// Copyright (c) 2023-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -10,6 +10,7 @@ package ot
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -99,6 +100,259 @@ func TestOTRSA(t *testing.T) {
 	testOT(NewRSA(2048), NewRSA(2048), t)
 }
 
+// countIO counts the data messages sent to the peer.
+type countIO struct {
+	IO
+	sent int
+}
+
+func (c *countIO) SendData(val []byte) error {
+	c.sent++
+	return c.IO.SendData(val)
+}
+
+// testOTBatch transfers the wires in three batches and returns the
+// number of data messages the sender sent. If incremental is true,
+// the batches are transferred with SendBatch and ReceiveBatch,
+// otherwise with independent Send and Receive calls.
+func testOTBatch(t *testing.T, sender, receiver OT, incremental bool) int {
+	sizes := []int{5, 16, 11}
+
+	var wires []Wire
+	var flags []bool
+	for i, size := range sizes {
+		for j := 0; j < size; j++ {
+			var w Wire
+			var data LabelData
+			if _, err := rand.Read(data[:]); err != nil {
+				t.Fatal(err)
+			}
+			w.L0.SetData(&data)
+			if _, err := rand.Read(data[:]); err != nil {
+				t.Fatal(err)
+			}
+			w.L1.SetData(&data)
+
+			wires = append(wires, w)
+			flags = append(flags, (i+j)%3 == 0)
+		}
+	}
+	labels := make([]Label, len(wires))
+
+	pipe, rPipe := NewPipe()
+	sio := &countIO{
+		IO: pipe,
+	}
+	done := make(chan error)
+
+	go func() {
+		err := receiver.InitReceiver(rPipe)
+		var ofs int
+		for _, size := range sizes {
+			if err != nil {
+				break
+			}
+			if incremental {
+				err = receiver.ReceiveBatch(flags[ofs:ofs+size],
+					labels[ofs:ofs+size])
+			} else {
+				err = receiver.Receive(flags[ofs:ofs+size],
+					labels[ofs:ofs+size])
+			}
+			ofs += size
+		}
+		if err != nil {
+			rPipe.Close()
+			rPipe.Drain()
+		}
+		done <- err
+	}()
+
+	err := sender.InitSender(sio)
+	var ofs int
+	for _, size := range sizes {
+		if err != nil {
+			break
+		}
+		if incremental {
+			err = sender.SendBatch(wires[ofs : ofs+size])
+		} else {
+			err = sender.Send(wires[ofs : ofs+size])
+		}
+		ofs += size
+	}
+	if err != nil {
+		t.Fatalf("sender failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("receiver failed: %v", err)
+	}
+
+	for i := 0; i < len(flags); i++ {
+		expected := wires[i].L0
+		if flags[i] {
+			expected = wires[i].L1
+		}
+		if !labels[i].Equal(expected) {
+			t.Fatalf("label %d mismatch %v %v,%v", i,
+				labels[i], wires[i].L0, wires[i].L1)
+		}
+	}
+	return sio.sent
+}
+
+func TestOTCOBatch(t *testing.T) {
+	batch := testOTBatch(t, NewCO(), NewCO(), true)
+	independent := testOTBatch(t, NewCO(), NewCO(), false)
+
+	// The incremental batches share one setup and the independent
+	// batches run one setup, sending the point A, per batch.
+	if batch != independent-4 {
+		t.Errorf("batch sent %d messages, independent %d",
+			batch, independent)
+	}
+}
+
+func TestOTRSABatch(t *testing.T) {
+	testOTBatch(t, NewRSA(1024), NewRSA(1024), true)
+}
+
+var errFault = errors.New("injected fault")
+
+// faultIO fails all data messages after limit messages have been
+// sent.
+type faultIO struct {
+	IO
+	limit int
+	sent  int
+}
+
+func (f *faultIO) SendData(val []byte) error {
+	if f.sent >= f.limit {
+		return errFault
+	}
+	f.sent++
+	return f.IO.SendData(val)
+}
+
+func randomWires(t *testing.T, count int) ([]Wire, []bool) {
+	wires := make([]Wire, count)
+	flags := make([]bool, count)
+	for i := 0; i < count; i++ {
+		var data LabelData
+		if _, err := rand.Read(data[:]); err != nil {
+			t.Fatal(err)
+		}
+		wires[i].L0.SetData(&data)
+		if _, err := rand.Read(data[:]); err != nil {
+			t.Fatal(err)
+		}
+		wires[i].L1.SetData(&data)
+		flags[i] = i%3 != 0
+	}
+	return wires, flags
+}
+
+// transfer transfers the wires with OT over a new pipe. The sender's
+// data messages fail after limit messages if limit is not negative.
+func transfer(sender, receiver OT, wires []Wire, flags []bool,
+	labels []Label, limit int) (error, error) {
+
+	pipe, rPipe := NewPipe()
+	var sio IO = pipe
+	if limit >= 0 {
+		sio = &faultIO{
+			IO:    pipe,
+			limit: limit,
+		}
+	}
+	done := make(chan error)
+	go func() {
+		err := receiver.InitReceiver(rPipe)
+		if err == nil {
+			err = receiver.Receive(flags, labels)
+		}
+		rPipe.Close()
+		rPipe.Drain()
+		done <- err
+	}()
+	err := sender.InitSender(sio)
+	if err == nil {
+		err = sender.Send(wires)
+	}
+	pipe.Close()
+	pipe.Drain()
+
+	return err, <-done
+}
+
+// testOTIncomplete fails the sender's connection after each of the
+// fault points and verifies that both parties report the completed
+// transfers and that the transfer of the remaining labels completes
+// the transfer. The setup and perTransfer specify the number of data
+// messages the sender sends in its setup and in each transfer.
+func testOTIncomplete(t *testing.T, newOT func() OT, setup,
+	perTransfer int) {
+
+	const count = 12
+	wires, flags := randomWires(t, count)
+
+	for _, k := range []int{0, 1, 7, count - 1} {
+		labels := make([]Label, count)
+		sErr, rErr := transfer(newOT(), newOT(), wires, flags, labels,
+			setup+k*perTransfer)
+
+		for _, err := range []error{sErr, rErr} {
+			var incomplete *IncompleteError
+			if !errors.Is(err, ErrOTIncomplete) ||
+				!errors.As(err, &incomplete) {
+				t.Fatalf("k=%d: got %v, expected %v", k, err,
+					ErrOTIncomplete)
+			}
+			if incomplete.Completed != k {
+				t.Errorf("k=%d: got %d completed transfers: %v",
+					k, incomplete.Completed, err)
+			}
+		}
+		if !errors.Is(sErr, errFault) {
+			t.Errorf("k=%d: sender error %v does not wrap %v",
+				k, sErr, errFault)
+		}
+
+		// Retry the remaining transfers.
+		sErr, rErr = transfer(newOT(), newOT(), wires[k:], flags[k:],
+			labels[k:], -1)
+		if sErr != nil || rErr != nil {
+			t.Fatalf("k=%d: retry failed: %v, %v", k, sErr, rErr)
+		}
+		for i := 0; i < count; i++ {
+			expected := wires[i].L0
+			if flags[i] {
+				expected = wires[i].L1
+			}
+			if !labels[i].Equal(expected) {
+				t.Fatalf("k=%d: label %d mismatch", k, i)
+			}
+		}
+	}
+}
+
+func TestOTCOIncomplete(t *testing.T) {
+	// The setup sends the curve name and the point A, and each
+	// transfer sends the values e0 and e1.
+	testOTIncomplete(t, func() OT {
+		return NewCO()
+	}, 3, 2)
+}
+
+func TestOTRSAIncomplete(t *testing.T) {
+	// The setup sends the algorithm name and the modulus, and each
+	// transfer sends the random messages and the transfer messages.
+	testOTIncomplete(t, func() OT {
+		return NewRSA(1024)
+	}, 2, 4)
+}
+
 func benchmarkOT(sender, receiver OT, batchSize int, b *testing.B) {
 	wires := make([]Wire, batchSize)
 	flags := make([]bool, batchSize)
@@ -1,7 +1,7 @@
 //
 // rsa.go
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -288,30 +288,36 @@ func (r *RSA) InitReceiver(io IO) error {
 
 // Send sends the wire labels with OT.
 func (r *RSA) Send(wires []Wire) error {
+	completed, err := r.sendTransfers(wires)
+	return incomplete(completed, err)
+}
+
+func (r *RSA) sendTransfers(wires []Wire) (int, error) {
+	enc := IOLabelEncoding(r.io)
 	for i := 0; i < len(wires); i++ {
 		// Send random messages.
 		x0, err := RandomData(r.messageSize())
 		if err != nil {
-			return err
+			return i, err
 		}
 		x1, err := RandomData(r.messageSize())
 		if err != nil {
-			return err
+			return i, err
 		}
 		if err := r.io.SendData(x0); err != nil {
-			return err
+			return i, err
 		}
 		if err := r.io.SendData(x1); err != nil {
-			return err
+			return i, err
 		}
 		if err := r.io.Flush(); err != nil {
-			return err
+			return i, err
 		}
 
 		// Receive V.
 		v, err := ReceiveBigInt(r.io)
 		if err != nil {
-			return err
+			return i, err
 		}
 		x0i := mpint.FromBytes(x0)
 		x1i := mpint.FromBytes(x1)
@@ -320,57 +326,64 @@ func (r *RSA) Send(wires []Wire) error {
 
 		// Create transfer messages.
 		var ld LabelData
-		wires[i].L0.GetData(&ld)
+		enc.Encode(wires[i].L0, &ld)
 		m0, err := pkcs1.NewEncryptionBlock(pkcs1.BT1, r.messageSize(), ld[:])
 		if err != nil {
-			return err
+			return i, err
 		}
 		m0p := mpint.Add(mpint.FromBytes(m0), k0)
 		if err := r.io.SendData(m0p.Bytes()); err != nil {
-			return err
+			return i, err
 		}
-		wires[i].L1.GetData(&ld)
+		enc.Encode(wires[i].L1, &ld)
 		m1, err := pkcs1.NewEncryptionBlock(pkcs1.BT1, r.messageSize(), ld[:])
 		if err != nil {
-			return err
+			return i, err
 		}
 		m1p := mpint.Add(mpint.FromBytes(m1), k1)
 		if err := r.io.SendData(m1p.Bytes()); err != nil {
-			return err
+			return i, err
 		}
 		if err := r.io.Flush(); err != nil {
-			return err
+			return i + 1, err
 		}
 	}
-	return nil
+	return len(wires), nil
 }
 
 // Receive receives the wire labels with OT based on the flag values.
 func (r *RSA) Receive(flags []bool, result []Label) error {
+	completed, err := r.receiveTransfers(flags, result)
+	return incomplete(completed, err)
+}
+
+func (r *RSA) receiveTransfers(flags []bool, result []Label) (int, error) {
+	enc := IOLabelEncoding(r.io)
+	var ld LabelData
 	for i := 0; i < len(flags); i++ {
 		k, err := rand.Int(rand.Reader, r.pub.N)
 		if err != nil {
-			return err
+			return i, err
 		}
 		// Receive random messages.
 		var xb *big.Int
 		if flags[i] {
 			_, err = r.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 			xb, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 		} else {
 			xb, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 			_, err = r.io.ReceiveData()
 			if err != nil {
-				return err
+				return i, err
 			}
 		}
 
@@ -378,10 +391,10 @@ func (r *RSA) Receive(flags []bool, result []Label) error {
 		e := big.NewInt(int64(r.pub.E))
 		v := mpint.Mod(mpint.Add(xb, mpint.Exp(k, e, r.pub.N)), r.pub.N)
 		if err := r.io.SendData(v.Bytes()); err != nil {
-			return err
+			return i, err
 		}
 		if err := r.io.Flush(); err != nil {
-			return err
+			return i, err
 		}
 
 		// Receive transfer messages.
@@ -389,20 +402,20 @@ func (r *RSA) Receive(flags []bool, result []Label) error {
 		if flags[i] {
 			_, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 			mbp, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 		} else {
 			mbp, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 			_, err = ReceiveBigInt(r.io)
 			if err != nil {
-				return err
+				return i, err
 			}
 		}
 
@@ -413,9 +426,24 @@ func (r *RSA) Receive(flags []bool, result []Label) error {
 
 		mb, err := pkcs1.ParseEncryptionBlock(mbBytes)
 		if err != nil {
-			return err
+			return i, err
 		}
-		result[i].SetBytes(mb)
+		copy(ld[:], mb)
+		enc.Decode(&result[i], &ld)
 	}
-	return nil
+	return len(flags), nil
+}
+
+// SendBatch sends the wire labels with OT as the next batch of an
+// incremental transfer. The RSA OT has no per-batch setup so this is
+// equal to Send.
+func (r *RSA) SendBatch(wires []Wire) error {
+	return r.Send(wires)
+}
+
+// ReceiveBatch receives the wire labels with OT based on the flag
+// values as the next batch of an incremental transfer. The RSA OT
+// has no per-batch setup so this is equal to Receive.
+func (r *RSA) ReceiveBatch(flags []bool, result []Label) error {
+	return r.Receive(flags, result)
 }
@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ProtocolVersion specifies the version of the two-party protocol.
+// The peers must use the same protocol version.
+const ProtocolVersion = 1
+
+// handshakeMagic identifies the handshake message: "MPCh".
+const handshakeMagic = 0x4d504368
+
+// Role specifies the party's role in the two-party protocol.
+type Role byte
+
+// Two-party protocol roles.
+const (
+	RoleGarbler Role = iota
+	RoleEvaluator
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleGarbler:
+		return "garbler"
+	case RoleEvaluator:
+		return "evaluator"
+	default:
+		return fmt.Sprintf("{Role %d}", r)
+	}
+}
+
+var (
+	// ErrRoleConflict is returned by the handshake if both peers
+	// claim the same role.
+	ErrRoleConflict = errors.New("role conflict")
+
+	// ErrVersionMismatch is returned by the handshake if the peers
+	// use different protocol versions.
+	ErrVersionMismatch = errors.New("protocol version mismatch")
+)
+
+// Handshake announces the party's role and protocol version to the
+// peer and verifies the peer's announcement. Both peers send their
+// announcements before reading the peer's so the handshake does not
+// depend on which peer starts it. The handshake fails with an error
+// wrapping ErrRoleConflict if both peers claim the same role, and
+// with an error wrapping ErrVersionMismatch if the peers use
+// different protocol versions.
+func (c *Conn) Handshake(role Role) error {
+	if role != RoleGarbler && role != RoleEvaluator {
+		return fmt.Errorf("invalid role: %v", role)
+	}
+	if err := c.SendUint32(handshakeMagic); err != nil {
+		return err
+	}
+	if err := c.SendUint16(ProtocolVersion); err != nil {
+		return err
+	}
+	if err := c.SendByte(byte(role)); err != nil {
+		return err
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+
+	magic, err := c.ReceiveUint32()
+	if err != nil {
+		return err
+	}
+	if magic != handshakeMagic {
+		return fmt.Errorf("invalid handshake message 0x%08x", magic)
+	}
+	version, err := c.ReceiveUint16()
+	if err != nil {
+		return err
+	}
+	b, err := c.ReceiveByte()
+	if err != nil {
+		return err
+	}
+	peer := Role(b)
+
+	if version != ProtocolVersion {
+		return fmt.Errorf("%w: local version %d, peer version %d",
+			ErrVersionMismatch, ProtocolVersion, version)
+	}
+	if peer != RoleGarbler && peer != RoleEvaluator {
+		return fmt.Errorf("invalid peer role: %v", peer)
+	}
+	if peer == role {
+		return fmt.Errorf("%w: both peers claim the %s role",
+			ErrRoleConflict, role)
+	}
+	return nil
+}
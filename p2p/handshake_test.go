@@ -0,0 +1,109 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// handshake runs the handshake for both connections with the roles r0
+// and r1, and returns the handshake errors.
+func handshake(t *testing.T, c0, c1 *Conn, r0, r1 Role) (error, error) {
+	ch := make(chan error)
+	go func() {
+		ch <- c1.Handshake(r1)
+	}()
+	err0 := c0.Handshake(r0)
+
+	select {
+	case err1 := <-ch:
+		return err0, err1
+	case <-time.After(10 * time.Second):
+		t.Fatalf("handshake did not complete")
+	}
+	return nil, nil
+}
+
+func TestHandshake(t *testing.T) {
+	c0, c1 := Pipe()
+	defer c0.Close()
+	defer c1.Close()
+
+	err0, err1 := handshake(t, c0, c1, RoleGarbler, RoleEvaluator)
+	if err0 != nil || err1 != nil {
+		t.Fatalf("handshake failed: %v, %v", err0, err1)
+	}
+
+	// The connection remains usable after the handshake.
+	go func() {
+		c1.SendUint32(42)
+		c1.Flush()
+	}()
+	v, err := c0.ReceiveUint32()
+	if err != nil || v != 42 {
+		t.Errorf("got %v (%v), expected 42", v, err)
+	}
+}
+
+func TestHandshakeRoleConflict(t *testing.T) {
+	for _, role := range []Role{RoleGarbler, RoleEvaluator} {
+		c0, c1 := Pipe()
+
+		err0, err1 := handshake(t, c0, c1, role, role)
+		for _, err := range []error{err0, err1} {
+			if !errors.Is(err, ErrRoleConflict) {
+				t.Errorf("got error %v, expected %v", err, ErrRoleConflict)
+				continue
+			}
+			expected := "both peers claim the " + role.String() + " role"
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("got error %q, expected %q", err, expected)
+			}
+		}
+		c0.Close()
+		c1.Close()
+	}
+}
+
+func TestHandshakeVersionMismatch(t *testing.T) {
+	c0, c1 := Pipe()
+	defer c0.Close()
+	defer c1.Close()
+
+	go func() {
+		// Announce an unknown protocol version.
+		c1.SendUint32(handshakeMagic)
+		c1.SendUint16(ProtocolVersion + 1)
+		c1.SendByte(byte(RoleEvaluator))
+		c1.Flush()
+	}()
+	err := c0.Handshake(RoleGarbler)
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("got error %v, expected %v", err, ErrVersionMismatch)
+	}
+}
+
+func TestHandshakeInvalid(t *testing.T) {
+	c0, c1 := Pipe()
+	defer c0.Close()
+	defer c1.Close()
+
+	go func() {
+		c1.SendInputSizes([]int{8, 8})
+		c1.Flush()
+	}()
+	err := c0.Handshake(RoleGarbler)
+	if err == nil || !strings.Contains(err.Error(), "invalid handshake") {
+		t.Errorf("got error %v, expected invalid handshake", err)
+	}
+	if err := c0.Handshake(Role(7)); err == nil {
+		t.Errorf("handshake succeeded with invalid role")
+	}
+}
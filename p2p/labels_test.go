@@ -0,0 +1,180 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/markkurossi/mpc/ot"
+)
+
+func randomLabels(t testing.TB, count int) []ot.Label {
+	labels := make([]ot.Label, count)
+	for i := 0; i < count; i++ {
+		l, err := ot.NewLabel(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels[i] = l
+	}
+	return labels
+}
+
+func sendLabels(c *Conn, labels []ot.Label, batched bool) error {
+	if batched {
+		if err := c.SendLabels(labels); err != nil {
+			return err
+		}
+	} else {
+		var data ot.LabelData
+		for _, l := range labels {
+			if err := c.SendLabel(l, &data); err != nil {
+				return err
+			}
+		}
+	}
+	return c.Flush()
+}
+
+func receiveLabels(c *Conn, labels []ot.Label, batched bool) error {
+	if batched {
+		return c.ReceiveLabels(labels)
+	}
+	var data ot.LabelData
+	for i := 0; i < len(labels); i++ {
+		if err := c.ReceiveLabel(&labels[i], &data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLabels(t *testing.T) {
+	// The largest count does not fit into the write buffer.
+	for _, count := range []int{0, 1, 128, writeBufSize/16 + 3} {
+		labels := randomLabels(t, count)
+
+		var results [2][]ot.Label
+		for idx, batched := range []bool{false, true} {
+			gConn, eConn := Pipe()
+
+			ch := make(chan error)
+			go func() {
+				ch <- sendLabels(gConn, labels, batched)
+			}()
+			results[idx] = make([]ot.Label, count)
+			err := receiveLabels(eConn, results[idx], batched)
+			if err != nil {
+				t.Fatalf("receive failed: %v", err)
+			}
+			if err := <-ch; err != nil {
+				t.Fatalf("send failed: %v", err)
+			}
+			gConn.Close()
+			eConn.Close()
+		}
+		for i := 0; i < count; i++ {
+			if !results[0][i].Equal(labels[i]) ||
+				!results[1][i].Equal(labels[i]) {
+				t.Fatalf("label %d mismatch: %v, %v, expected %v",
+					i, results[0][i], results[1][i], labels[i])
+			}
+		}
+	}
+}
+
+func TestLabelsCountMismatch(t *testing.T) {
+	gConn, eConn := Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	go sendLabels(gConn, randomLabels(t, 4), true)
+
+	err := eConn.ReceiveLabels(make([]ot.Label, 5))
+	if err == nil {
+		t.Errorf("ReceiveLabels succeeded with wrong label count")
+	}
+}
+
+func benchmarkLabels(b *testing.B, batched bool) {
+	labels := randomLabels(b, 1024)
+	received := make([]ot.Label, len(labels))
+
+	gConn, eConn := Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	ch := make(chan error)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if err := sendLabels(gConn, labels, batched); err != nil {
+				ch <- err
+				return
+			}
+		}
+		ch <- nil
+	}()
+	b.SetBytes(int64(len(labels) * len(ot.LabelData{})))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := receiveLabels(eConn, received, batched); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := <-ch; err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkLabels(b *testing.B) {
+	benchmarkLabels(b, false)
+}
+
+func BenchmarkLabelsBatched(b *testing.B) {
+	benchmarkLabels(b, true)
+}
+
+func TestLabelsEncoding(t *testing.T) {
+	labels := randomLabels(t, 16)
+	for i := range labels {
+		labels[i].SetS(i%2 == 0)
+	}
+
+	gConn, eConn := Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	gConn.SetLabelEncoding(ot.LabelLSB)
+
+	ch := make(chan error)
+	go func() {
+		ch <- sendLabels(gConn, labels, true)
+	}()
+
+	// Receive the labels with the default encoding to access their
+	// on-wire format.
+	received := make([]ot.Label, len(labels))
+	if err := receiveLabels(eConn, received, true); err != nil {
+		t.Fatalf("receive failed: %v", err)
+	}
+	if err := <-ch; err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+	for i, r := range received {
+		var data ot.LabelData
+		r.GetData(&data)
+		if (data[0]&1 != 0) != labels[i].S() {
+			t.Errorf("label %d: S bit not in the LSB of the first byte", i)
+		}
+		var decoded ot.Label
+		ot.LabelLSB.Decode(&decoded, &data)
+		if !decoded.Equal(labels[i]) {
+			t.Errorf("label %d: got %v, expected %v", i, decoded, labels[i])
+		}
+	}
+}
@@ -0,0 +1,131 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Listener accepts protocol connections. The listener drops
+// connections that do not complete their handshake in time so that
+// misbehaving clients can't hold the listener.
+type Listener struct {
+	listener net.Listener
+
+	// HandshakeTimeout specifies the maximum time for the connection
+	// handshake. The zero value means no timeout.
+	HandshakeTimeout time.Duration
+
+	// IOTimeout specifies the read and write deadline for each I/O
+	// operation after the handshake. The zero value means no
+	// timeout.
+	IOTimeout time.Duration
+}
+
+// HandshakeError reports a connection that failed its handshake. The
+// connection is closed and the listener can accept new connections.
+type HandshakeError struct {
+	Addr net.Addr
+	Err  error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake with %s failed: %s", e.Addr, e.Err)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// Listen creates a new listener for the TCP address addr.
+func Listen(addr string) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{
+		listener: ln,
+	}, nil
+}
+
+// Addr returns the listener's network address.
+func (ln *Listener) Addr() net.Addr {
+	return ln.listener.Addr()
+}
+
+// Close closes the listener. Any blocked Accept operations return
+// net.ErrClosed.
+func (ln *Listener) Close() error {
+	return ln.listener.Close()
+}
+
+// Accept waits for the next connection and runs the handshake
+// function for it. If the handshake fails or does not complete within
+// the handshake timeout, Accept closes the connection and returns a
+// HandshakeError.
+func (ln *Listener) Accept(handshake func(conn *Conn) error) (*Conn, error) {
+	nc, err := ln.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tc := &timeoutConn{
+		Conn: nc,
+	}
+	conn := NewConn(tc)
+
+	if ln.HandshakeTimeout > 0 {
+		err = nc.SetDeadline(time.Now().Add(ln.HandshakeTimeout))
+	}
+	if err == nil {
+		err = handshake(conn)
+	}
+	if err == nil {
+		err = nc.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		conn.Close()
+		nc.Close()
+		return nil, &HandshakeError{
+			Addr: nc.RemoteAddr(),
+			Err:  err,
+		}
+	}
+	tc.timeout.Store(int64(ln.IOTimeout))
+
+	return conn, nil
+}
+
+// timeoutConn sets the connection deadline before each I/O operation.
+type timeoutConn struct {
+	net.Conn
+	timeout atomic.Int64
+}
+
+func (c *timeoutConn) Read(p []byte) (int, error) {
+	timeout := time.Duration(c.timeout.Load())
+	if timeout > 0 {
+		err := c.Conn.SetReadDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *timeoutConn) Write(p []byte) (int, error) {
+	timeout := time.Duration(c.timeout.Load())
+	if timeout > 0 {
+		err := c.Conn.SetWriteDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,91 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListenerHandshakeTimeout(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	ln.HandshakeTimeout = 100 * time.Millisecond
+
+	sizes := []int{8, 16}
+	handshake := func(conn *Conn) error {
+		_, err := conn.ReceiveInputSizes()
+		return err
+	}
+
+	// Client that connects but never sends its input sizes.
+	idle, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer idle.Close()
+
+	start := time.Now()
+	_, err = ln.Accept(handshake)
+	var herr *HandshakeError
+	if !errors.As(err, &herr) {
+		t.Fatalf("Accept: expected handshake error, got %v", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Accept: expected timeout, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Accept: handshake timeout took %v", d)
+	}
+
+	// The server closed the idle connection.
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = idle.Read(make([]byte, 1))
+	if err != io.EOF {
+		t.Errorf("idle client: expected EOF, got %v", err)
+	}
+
+	// The listener accepts the next connection.
+	go func() {
+		nc, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		conn := NewConn(nc)
+		conn.SendInputSizes(sizes)
+		conn.Close()
+	}()
+
+	var received []int
+	conn, err := ln.Accept(func(conn *Conn) error {
+		s, err := conn.ReceiveInputSizes()
+		received = s
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	conn.Close()
+	if slices.Compare(received, sizes) != 0 {
+		t.Errorf("received input sizes %v, expected %v", received, sizes)
+	}
+
+	// Accept fails after the listener is closed.
+	ln.Close()
+	_, err = ln.Accept(handshake)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept: expected %v, got %v", net.ErrClosed, err)
+	}
+}
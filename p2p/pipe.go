@@ -0,0 +1,19 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package p2p
+
+import (
+	"net"
+)
+
+// Pipe creates a synchronous, in-memory, full duplex connection
+// pair. The connections are useful for running both protocol peers in
+// the same process.
+func Pipe() (*Conn, *Conn) {
+	a, b := net.Pipe()
+	return NewConn(a), NewConn(b)
+}
@@ -1,5 +1,5 @@
 //
-// Copyright (c) 2019-2023 Markku Rossi
+// Copyright (c) 2019-2024 Markku Rossi
 //
 // All rights reserved.
 //
@@ -7,7 +7,9 @@
 package p2p
 
 import (
+	"fmt"
 	"io"
+	"net"
 	"sync/atomic"
 
 	"github.com/markkurossi/mpc/ot"
@@ -33,6 +35,7 @@ type Conn struct {
 	ReadEnd   int
 	Stats     IOStats
 
+	encoding   ot.LabelEncoding
 	fromWriter chan []byte
 	toWriter   chan []byte
 	writerErr  error
@@ -78,6 +81,10 @@ func (stats IOStats) Sum() uint64 {
 }
 
 // NewConn creates a new connection around the argument connection.
+// The conn can be any transport, such as an in-memory buffer, a
+// message queue adapter, or a recorded session file. The connection
+// tracks its I/O statistics regardless of the transport, and Close
+// closes conn if it implements io.Closer.
 func NewConn(conn io.ReadWriter) *Conn {
 	c := &Conn{
 		conn:       conn,
@@ -94,6 +101,19 @@ func NewConn(conn io.ReadWriter) *Conn {
 	return c
 }
 
+// SetLabelEncoding sets the on-wire encoding of the labels the
+// connection sends and receives. Both peers must use the same
+// encoding. The default encoding is ot.LabelMSB.
+func (c *Conn) SetLabelEncoding(enc ot.LabelEncoding) {
+	c.encoding = enc
+}
+
+// LabelEncoding returns the connection's label encoding. The function
+// implements the ot.LabelEncoder interface.
+func (c *Conn) LabelEncoding() ot.LabelEncoding {
+	return c.encoding
+}
+
 func (c *Conn) writer() {
 	for i := 0; i < numBuffers; i++ {
 		c.fromWriter <- make([]byte, writeBufSize)
@@ -165,7 +185,7 @@ func (c *Conn) Close() error {
 	}
 	// Wait that flush completes.
 	close(c.toWriter)
-	for range <-c.fromWriter {
+	for range c.fromWriter {
 	}
 	if c.writerErr != nil {
 		return c.writerErr
@@ -177,6 +197,16 @@ func (c *Conn) Close() error {
 	return nil
 }
 
+// RemoteAddr returns the remote network address of the connection or
+// nil if the connection is not a network connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	nc, ok := c.conn.(net.Conn)
+	if ok {
+		return nc.RemoteAddr()
+	}
+	return nil
+}
+
 // SendByte sends a byte value.
 func (c *Conn) SendByte(val byte) error {
 	if c.WritePos+1 > len(c.WriteBuf) {
@@ -235,7 +265,8 @@ func (c *Conn) SendData(val []byte) error {
 
 // SendLabel sends an OT label.
 func (c *Conn) SendLabel(val ot.Label, data *ot.LabelData) error {
-	bytes := val.Bytes(data)
+	c.encoding.Encode(val, data)
+	bytes := data[:]
 	if c.WritePos+len(bytes) > len(c.WriteBuf) {
 		if err := c.Flush(); err != nil {
 			return err
@@ -247,6 +278,34 @@ func (c *Conn) SendLabel(val ot.Label, data *ot.LabelData) error {
 	return nil
 }
 
+// SendLabels sends the OT labels as a single framed message: the
+// number of labels followed by the label data. The labels are encoded
+// directly into the write buffer which is flushed only when it fills
+// up.
+func (c *Conn) SendLabels(labels []ot.Label) error {
+	if err := c.SendUint32(len(labels)); err != nil {
+		return err
+	}
+	for len(labels) > 0 {
+		space := (len(c.WriteBuf) - c.WritePos) / len(ot.LabelData{})
+		if space == 0 {
+			if err := c.Flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		if space > len(labels) {
+			space = len(labels)
+		}
+		for _, l := range labels[:space] {
+			c.encoding.Encode(l, (*ot.LabelData)(c.WriteBuf[c.WritePos:]))
+			c.WritePos += len(ot.LabelData{})
+		}
+		labels = labels[space:]
+	}
+	return nil
+}
+
 // SendString sends a string value.
 func (c *Conn) SendString(val string) error {
 	return c.SendData([]byte(val))
@@ -340,7 +399,40 @@ func (c *Conn) ReceiveLabel(val *ot.Label, data *ot.LabelData) error {
 	copy(data[:], c.ReadBuf[c.ReadStart:c.ReadStart+len(data)])
 	c.ReadStart += len(data)
 
-	val.SetData(data)
+	c.encoding.Decode(val, data)
+	return nil
+}
+
+// ReceiveLabels receives the OT labels sent with SendLabels. The
+// number of received labels must match len(labels).
+func (c *Conn) ReceiveLabels(labels []ot.Label) error {
+	count, err := c.ReceiveUint32()
+	if err != nil {
+		return err
+	}
+	if count != len(labels) {
+		return fmt.Errorf("invalid number of labels: got %d, expected %d",
+			count, len(labels))
+	}
+	size := len(ot.LabelData{})
+	for len(labels) > 0 {
+		avail := (c.ReadEnd - c.ReadStart) / size
+		if avail == 0 {
+			if err := c.Fill(size); err != nil {
+				return err
+			}
+			continue
+		}
+		if avail > len(labels) {
+			avail = len(labels)
+		}
+		for i := 0; i < avail; i++ {
+			c.encoding.Decode(&labels[i],
+				(*ot.LabelData)(c.ReadBuf[c.ReadStart:]))
+			c.ReadStart += size
+		}
+		labels = labels[avail:]
+	}
 	return nil
 }
 
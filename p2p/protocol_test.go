@@ -9,8 +9,12 @@
 package p2p
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -82,12 +86,8 @@ func writer(c *Conn) {
 	}
 }
 
-func TestProtocol(t *testing.T) {
-	p0, p1 := newPipes()
-
-	go writer(NewConn(p0))
-
-	c := NewConn(p1)
+func reader(t *testing.T, c *Conn) []interface{} {
+	var result []interface{}
 
 	for _, test := range tests {
 		switch d := test.(type) {
@@ -99,6 +99,7 @@ func TestProtocol(t *testing.T) {
 			if v != d {
 				t.Errorf("ReceiveByte: got %v, expected %v", v, d)
 			}
+			result = append(result, v)
 
 		case uint16:
 			v, err := c.ReceiveUint16()
@@ -108,6 +109,7 @@ func TestProtocol(t *testing.T) {
 			if v != int(d) {
 				t.Errorf("ReceiveUint16: got %v, expected %v", v, d)
 			}
+			result = append(result, v)
 
 		case uint32:
 			v, err := c.ReceiveUint32()
@@ -117,6 +119,7 @@ func TestProtocol(t *testing.T) {
 			if v != int(d) {
 				t.Errorf("ReceiveUint32: got %v, expected %v", v, d)
 			}
+			result = append(result, v)
 
 		case string:
 			v, err := c.ReceiveString()
@@ -126,6 +129,7 @@ func TestProtocol(t *testing.T) {
 			if v != d {
 				t.Errorf("ReceiveString: got %v, expected %v", v, d)
 			}
+			result = append(result, v)
 
 		default:
 			t.Errorf("invalid value: %v(%T)", test, test)
@@ -134,4 +138,96 @@ func TestProtocol(t *testing.T) {
 	if err := c.Close(); err != nil {
 		t.Errorf("Close: %v", err)
 	}
+	return result
+}
+
+func TestProtocol(t *testing.T) {
+	p0, p1 := newPipes()
+
+	go writer(NewConn(p0))
+
+	reader(t, NewConn(p1))
+}
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// recorder records all data read from the connection.
+type recorder struct {
+	io.ReadWriter
+	rec io.Writer
+}
+
+func (r *recorder) Read(data []byte) (n int, err error) {
+	n, err = r.ReadWriter.Read(data)
+	if n > 0 {
+		if _, werr := r.rec.Write(data[:n]); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func TestConnBuffers(t *testing.T) {
+	var buf bytes.Buffer
+
+	c0 := NewConn(&readWriter{
+		Reader: &bytes.Buffer{},
+		Writer: &buf,
+	})
+	writer(c0)
+	if err := c0.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if c0.Stats.Sent.Load() != uint64(buf.Len()) {
+		t.Errorf("sent %v bytes, buffer has %v bytes",
+			c0.Stats.Sent.Load(), buf.Len())
+	}
+	sent := buf.Len()
+
+	c1 := NewConn(&readWriter{
+		Reader: &buf,
+		Writer: io.Discard,
+	})
+	reader(t, c1)
+	if c1.Stats.Recvd.Load() != uint64(sent) {
+		t.Errorf("received %v bytes, expected %v",
+			c1.Stats.Recvd.Load(), sent)
+	}
+}
+
+func TestConnReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the live session.
+	p0, p1 := newPipes()
+	go writer(NewConn(p0))
+	live := reader(t, NewConn(&recorder{
+		ReadWriter: p1,
+		rec:        f,
+	}))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay the recorded session.
+	f, err = os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	replayed := reader(t, NewConn(&readWriter{
+		Reader: f,
+		Writer: io.Discard,
+	}))
+	if !reflect.DeepEqual(live, replayed) {
+		t.Errorf("replay mismatch: live %v, replayed %v", live, replayed)
+	}
 }
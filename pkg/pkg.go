@@ -0,0 +1,19 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package pkg embeds the MPCL standard library packages and their
+// native circuits. Set the compiler parameter PkgFS to FS to compile
+// programs without access to the standard library on the disk.
+package pkg
+
+import (
+	"embed"
+)
+
+// FS contains the MPCL standard library packages.
+//
+//go:embed bytes crypto encoding math mpc sort
+var FS embed.FS
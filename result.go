@@ -19,7 +19,11 @@ import (
 // PrintResults prints the result values.
 func PrintResults(results []*big.Int, outputs circuit.IO) {
 	for idx, value := range Results(results, outputs) {
-		fmt.Printf("Result[%d]: ", idx)
+		if outputs != nil && outputs[idx].Mode == circuit.OutputShare {
+			fmt.Printf("Result[%d] (share): ", idx)
+		} else {
+			fmt.Printf("Result[%d]: ", idx)
+		}
 		switch v := value.(type) {
 		case []byte:
 			fmt.Printf("%x\n", v)
@@ -103,6 +107,17 @@ func Result(result *big.Int, output circuit.IOArg) interface{} {
 			return result
 		}
 
+	case types.TFixed:
+		bits := int(output.Type.Bits)
+		val := new(big.Int).Set(result)
+		if val.Bit(bits-1) == 1 {
+			// Negative number.
+			val.Sub(val, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+		}
+		scale := new(big.Int).Lsh(big.NewInt(1), uint(output.Type.FracBits))
+		f, _ := new(big.Rat).SetFrac(val, scale).Float64()
+		return f
+
 	case types.TBool:
 		return result.Uint64() != 0
 
@@ -0,0 +1,242 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package mpc
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+	"github.com/markkurossi/mpc/types"
+)
+
+// Role specifies the party's role in the two-party computation.
+type Role int
+
+// Two-party computation roles.
+const (
+	Garbler Role = iota
+	Evaluator
+)
+
+func (r Role) String() string {
+	switch r {
+	case Garbler:
+		return "garbler"
+	case Evaluator:
+		return "evaluator"
+	default:
+		return fmt.Sprintf("{Role %d}", r)
+	}
+}
+
+// p2pRole returns the role's connection handshake role.
+func (r Role) p2pRole() p2p.Role {
+	if r == Evaluator {
+		return p2p.RoleEvaluator
+	}
+	return p2p.RoleGarbler
+}
+
+type runConfig struct {
+	oti     ot.OT
+	conn    *p2p.Conn
+	verbose bool
+}
+
+// Option configures the Run function.
+type Option func(cfg *runConfig)
+
+// WithOT sets the oblivious transfer implementation. The default is
+// ot.NewCO().
+func WithOT(oti ot.OT) Option {
+	return func(cfg *runConfig) {
+		cfg.oti = oti
+	}
+}
+
+// WithConn runs the computation over the connection conn instead of
+// dialing or listening the network address. The caller owns the
+// connection and is responsible for closing it.
+func WithConn(conn *p2p.Conn) Option {
+	return func(cfg *runConfig) {
+		cfg.conn = conn
+	}
+}
+
+// WithVerbose enables verbose protocol output.
+func WithVerbose(verbose bool) Option {
+	return func(cfg *runConfig) {
+		cfg.verbose = verbose
+	}
+}
+
+// Run runs the two-party computation of the circuit circ with the
+// peer. The garbler dials the evaluator at the TCP address addr and
+// the evaluator listens for one connection at addr. The input
+// specifies the values of the party's input arguments. The parties
+// verify that they have different roles and the same protocol
+// version. They exchange their input sizes and verify that they agree
+// on the circuit's inputs before running the garbling protocol. Run
+// returns the values of the circuit's output arguments.
+func Run(role Role, addr string, circ *circuit.Circuit, input []*big.Int,
+	opts ...Option) ([]*big.Int, error) {
+
+	cfg := &runConfig{
+		oti: ot.NewCO(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if len(circ.Inputs) != 2 {
+		return nil, fmt.Errorf("invalid circuit for 2-party MPC: %d parties",
+			len(circ.Inputs))
+	}
+	var me, peer circuit.IOArg
+	switch role {
+	case Garbler:
+		me, peer = circ.Inputs[0], circ.Inputs[1]
+	case Evaluator:
+		me, peer = circ.Inputs[1], circ.Inputs[0]
+	default:
+		return nil, fmt.Errorf("invalid role: %v", role)
+	}
+	value, err := packInput(me, input)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := cfg.conn
+	if conn == nil {
+		conn, err = connect(role, addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+	}
+
+	if err = conn.Handshake(role.p2pRole()); err != nil {
+		return nil, err
+	}
+	err = exchangeInputSizes(conn, role, argSizes(me), argSizes(peer))
+	if err != nil {
+		return nil, err
+	}
+
+	if role == Garbler {
+		return circuit.Garbler(conn, cfg.oti, circ, value, cfg.verbose)
+	}
+	return circuit.Evaluator(conn, cfg.oti, circ, value, cfg.verbose)
+}
+
+func connect(role Role, addr string) (*p2p.Conn, error) {
+	if role == Garbler {
+		nc, err := net.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return p2p.NewConn(nc), nil
+	}
+	ln, err := p2p.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+
+	return ln.Accept(func(conn *p2p.Conn) error {
+		return nil
+	})
+}
+
+// exchangeInputSizes exchanges the parties' input sizes and verifies
+// that the peer's sizes match the circuit. The evaluator sends its
+// sizes first.
+func exchangeInputSizes(conn *p2p.Conn, role Role, mine, expected []int) (
+	err error) {
+
+	var peer []int
+	if role == Evaluator {
+		if err = sendInputSizes(conn, mine); err != nil {
+			return err
+		}
+		if peer, err = conn.ReceiveInputSizes(); err != nil {
+			return err
+		}
+	} else {
+		if peer, err = conn.ReceiveInputSizes(); err != nil {
+			return err
+		}
+		if err = sendInputSizes(conn, mine); err != nil {
+			return err
+		}
+	}
+	if slices.Compare(peer, expected) != 0 {
+		return fmt.Errorf("peer input sizes %v do not match circuit inputs %v",
+			peer, expected)
+	}
+	return nil
+}
+
+func sendInputSizes(conn *p2p.Conn, sizes []int) error {
+	err := conn.SendInputSizes(sizes)
+	if err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// argSizes returns the bit sizes of the party's input arguments.
+func argSizes(arg circuit.IOArg) []int {
+	if len(arg.Compound) == 0 {
+		return []int{int(arg.Type.Bits)}
+	}
+	var result []int
+	for _, a := range arg.Compound {
+		result = append(result, int(a.Type.Bits))
+	}
+	return result
+}
+
+// packInput packs the input values into the party's input
+// argument. Negative values of signed arguments are encoded in two's
+// complement.
+func packInput(arg circuit.IOArg, input []*big.Int) (*big.Int, error) {
+	args := arg.Compound
+	if len(args) == 0 {
+		args = circuit.IO{arg}
+	}
+	if len(input) != len(args) {
+		return nil, fmt.Errorf("invalid amount of inputs, got %d, expected %d",
+			len(input), len(args))
+	}
+	result := new(big.Int)
+	var offset int
+	for idx, a := range args {
+		bits := int(a.Type.Bits)
+		v := new(big.Int).Set(input[idx])
+		if a.Type.Type == types.TInt {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+			if v.Cmp(limit) >= 0 || v.Cmp(new(big.Int).Neg(limit)) < 0 {
+				return nil, fmt.Errorf("input %v overflows %s",
+					input[idx], a.Type)
+			}
+			if v.Sign() < 0 {
+				v.Add(v, limit.Lsh(limit, 1))
+			}
+		} else if v.Sign() < 0 || v.BitLen() > bits {
+			return nil, fmt.Errorf("input %v overflows %s", input[idx], a.Type)
+		}
+		result.Or(result, v.Lsh(v, uint(offset)))
+		offset += bits
+	}
+	return result, nil
+}
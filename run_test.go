@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package mpc
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/mpc/compiler"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestRun(t *testing.T) {
+	circ, _, err := compiler.New(utils.NewParams()).Compile(`
+package main
+func main(a, b int16) (int16, bool) {
+    return a - b, a == b
+}
+`, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	type result struct {
+		values []*big.Int
+		err    error
+	}
+	ch := make(chan result)
+	go func() {
+		values, err := Run(Evaluator, "", circ, []*big.Int{big.NewInt(42)},
+			WithConn(eConn))
+		ch <- result{values, err}
+	}()
+
+	values, err := Run(Garbler, "", circ, []*big.Int{big.NewInt(-7)},
+		WithConn(gConn))
+	if err != nil {
+		t.Fatalf("garbler failed: %s", err)
+	}
+	eResult := <-ch
+	if eResult.err != nil {
+		t.Fatalf("evaluator failed: %s", eResult.err)
+	}
+
+	for _, v := range [][]*big.Int{values, eResult.values} {
+		results := Results(v, circ.Outputs)
+		if len(results) != 2 {
+			t.Fatalf("unexpected results: %v", results)
+		}
+		if results[0] != int16(-49) || results[1] != false {
+			t.Errorf("unexpected results: %v", results)
+		}
+	}
+}
+
+func TestRunInvalidInput(t *testing.T) {
+	circ, _, err := compiler.New(utils.NewParams()).Compile(`
+package main
+func main(a int8, b uint8) uint8 {
+    return uint8(a) + b
+}
+`, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+	tests := []struct {
+		role  Role
+		input []*big.Int
+	}{
+		{Garbler, []*big.Int{big.NewInt(128)}},
+		{Garbler, []*big.Int{big.NewInt(-129)}},
+		{Garbler, []*big.Int{big.NewInt(1), big.NewInt(2)}},
+		{Evaluator, []*big.Int{big.NewInt(-1)}},
+		{Evaluator, []*big.Int{big.NewInt(256)}},
+	}
+	for idx, test := range tests {
+		_, err := Run(test.role, "", circ, test.input)
+		if err == nil {
+			t.Errorf("test %d: Run succeeded with invalid input %v",
+				idx, test.input)
+		}
+	}
+}
+
+func TestRunRoleConflict(t *testing.T) {
+	circ, _, err := compiler.New(utils.NewParams()).Compile(`
+package main
+func main(a, b uint8) uint8 {
+    return a + b
+}
+`, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	// Both parties claim the garbler role.
+	ch := make(chan error, 2)
+	for _, conn := range []*p2p.Conn{gConn, eConn} {
+		go func(conn *p2p.Conn) {
+			_, err := Run(Garbler, "", circ, []*big.Int{big.NewInt(1)},
+				WithConn(conn))
+			ch <- err
+		}(conn)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-ch:
+			if !errors.Is(err, p2p.ErrRoleConflict) {
+				t.Errorf("got error %v, expected %v", err, p2p.ErrRoleConflict)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Run did not fail on role conflict")
+		}
+	}
+}
@@ -0,0 +1,187 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package session implements message-driven garbler and evaluator
+// sessions for two-party computation. A session runs the garbled
+// circuit protocol and exchanges its data as opaque messages that the
+// application delivers to the peer with any transport, such as HTTP
+// requests or a message queue. The application does not need to know
+// the protocol rounds: it passes each message it receives from the
+// peer to NextMessage and sends the returned message back to the
+// peer until the session is done.
+package session
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+// ErrClosed is returned when the session is closed before it is done.
+var ErrClosed = errors.New("session closed")
+
+// Session implements a garbler or evaluator session. The session runs
+// the protocol in its own goroutine and NextMessage advances the
+// protocol until it needs more data from the peer.
+type Session struct {
+	m       sync.Mutex
+	c       *sync.Cond
+	conn    *p2p.Conn
+	in      []byte
+	out     []byte
+	written uint64
+	reading bool
+	closed  bool
+	done    bool
+	result  []*big.Int
+	err     error
+}
+
+// NewGarbler creates a new garbler session that computes the circuit
+// with the garbler's input. The garbler sends the first message so
+// the first call to NextMessage is made with nil input.
+func NewGarbler(oti ot.OT, circ *circuit.Circuit, input *big.Int) *Session {
+	return newSession(func(conn *p2p.Conn) ([]*big.Int, error) {
+		return circuit.Garbler(conn, oti, circ, input, false)
+	})
+}
+
+// NewEvaluator creates a new evaluator session that computes the
+// circuit with the evaluator's input.
+func NewEvaluator(oti ot.OT, circ *circuit.Circuit,
+	input *big.Int) *Session {
+
+	return newSession(func(conn *p2p.Conn) ([]*big.Int, error) {
+		return circuit.Evaluator(conn, oti, circ, input, false)
+	})
+}
+
+func newSession(run func(conn *p2p.Conn) ([]*big.Int, error)) *Session {
+	s := new(Session)
+	s.c = sync.NewCond(&s.m)
+	s.conn = p2p.NewConn(&transport{
+		s: s,
+	})
+
+	go func() {
+		result, err := run(s.conn)
+		if cerr := s.conn.Close(); err == nil {
+			err = cerr
+		}
+
+		s.m.Lock()
+		s.done = true
+		s.result = result
+		s.err = err
+		s.c.Broadcast()
+		s.m.Unlock()
+	}()
+
+	return s
+}
+
+// NextMessage passes the message in, received from the peer, to the
+// protocol and runs the protocol until it needs more data from the
+// peer or until the computation is done. The function returns the
+// message to send to the peer and a flag indicating if the session is
+// done. The returned message can be empty. When the session is done,
+// the last message must still be delivered to the peer, and the
+// computation result is available from Result.
+func (s *Session) NextMessage(in []byte) (out []byte, done bool, err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return nil, s.done, ErrClosed
+	}
+	s.in = append(s.in, in...)
+	s.c.Broadcast()
+
+	for !s.done && !s.idle() {
+		s.c.Wait()
+	}
+	out = s.out
+	s.out = nil
+
+	return out, s.done, s.err
+}
+
+// idle tests if the protocol is waiting for the peer's data and all
+// its output is written to the session. The function must be called
+// with the session mutex held.
+func (s *Session) idle() bool {
+	return s.reading && len(s.in) == 0 &&
+		s.written == s.conn.Stats.Sent.Load()
+}
+
+// Result returns the computation result. The result is available
+// when NextMessage has reported the session done.
+func (s *Session) Result() ([]*big.Int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.done {
+		return nil, errors.New("session not done")
+	}
+	return s.result, s.err
+}
+
+// Close closes the session. If the session is not done, the protocol
+// is aborted with the ErrClosed error.
+func (s *Session) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+	s.c.Broadcast()
+	return nil
+}
+
+// transport implements the io.ReadWriter for the session's protocol
+// connection.
+type transport struct {
+	s *Session
+}
+
+func (t *transport) Read(p []byte) (int, error) {
+	s := t.s
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for len(s.in) == 0 {
+		if s.closed {
+			return 0, ErrClosed
+		}
+		s.reading = true
+		s.c.Broadcast()
+		s.c.Wait()
+		s.reading = false
+	}
+	n := copy(p, s.in)
+	s.in = s.in[n:]
+
+	return n, nil
+}
+
+func (t *transport) Write(p []byte) (int, error) {
+	s := t.s
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	s.out = append(s.out, p...)
+	s.written += uint64(len(p))
+	s.c.Broadcast()
+
+	return len(p), nil
+}
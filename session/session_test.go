@@ -0,0 +1,91 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package session
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/circuit"
+	"github.com/markkurossi/mpc/ot"
+)
+
+func TestSession(t *testing.T) {
+	circ, err := circuit.Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := new(big.Int).SetBytes([]byte("0123456789abcdef"))
+	data := new(big.Int).SetBytes([]byte("session messages"))
+
+	expected, err := circ.Compute([]*big.Int{key, data})
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+
+	garbler := NewGarbler(ot.NewCO(), circ, key)
+	evaluator := NewEvaluator(ot.NewCO(), circ, data)
+
+	// Shuttle the messages between the sessions until both are done.
+	var msg []byte
+	var gDone, eDone bool
+	var rounds int
+	for !gDone || !eDone {
+		if rounds%2 == 0 {
+			msg, gDone, err = garbler.NextMessage(msg)
+		} else {
+			msg, eDone, err = evaluator.NextMessage(msg)
+		}
+		if err != nil {
+			t.Fatalf("round %d: NextMessage failed: %v", rounds, err)
+		}
+		rounds++
+		if rounds > 100 {
+			t.Fatalf("session did not complete")
+		}
+	}
+	if len(msg) != 0 {
+		t.Errorf("undelivered final message: %d bytes", len(msg))
+	}
+	if rounds < 3 {
+		t.Errorf("session completed in %d rounds", rounds)
+	}
+
+	for _, s := range []*Session{garbler, evaluator} {
+		result, err := s.Result()
+		if err != nil {
+			t.Fatalf("Result failed: %v", err)
+		}
+		if len(result) != 1 || result[0].Cmp(expected[0]) != 0 {
+			t.Errorf("got %x, expected %x", result, expected[0])
+		}
+	}
+}
+
+func TestSessionClose(t *testing.T) {
+	circ, err := circuit.Parse("../pkg/crypto/aes/aes_128.circ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluator := NewEvaluator(ot.NewCO(), circ, big.NewInt(1))
+
+	msg, done, err := evaluator.NextMessage(nil)
+	if err != nil || done || len(msg) != 0 {
+		t.Fatalf("NextMessage: got %d bytes, done=%v, err=%v",
+			len(msg), done, err)
+	}
+	if _, err := evaluator.Result(); err == nil {
+		t.Errorf("Result succeeded before session is done")
+	}
+	if err := evaluator.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, _, err := evaluator.NextMessage(nil); err != ErrClosed {
+		t.Errorf("NextMessage after Close: got %v, expected %v",
+			err, ErrClosed)
+	}
+}
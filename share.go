@@ -0,0 +1,60 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package mpc
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// SplitSecret splits the secret into n random XOR shares. Each share
+// has the length of the secret and the secret is the XOR of all
+// shares. Any n-1 shares are uniformly random and reveal nothing
+// about the secret. The parties provide their shares as circuit
+// inputs and the MPCL program recombines the secret with the
+// mpc.Recombine function of the MPCL standard library.
+func SplitSecret(secret []byte, n int) ([][]byte, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of shares: %d", n)
+	}
+	shares := make([][]byte, n)
+	last := make([]byte, len(secret))
+	copy(last, secret)
+
+	for i := 0; i < n-1; i++ {
+		share := make([]byte, len(secret))
+		if _, err := rand.Read(share); err != nil {
+			return nil, err
+		}
+		for j, b := range share {
+			last[j] ^= b
+		}
+		shares[i] = share
+	}
+	shares[n-1] = last
+
+	return shares, nil
+}
+
+// Recombine recombines the secret from its XOR shares. The shares
+// should have the same length; shorter shares are treated as if they
+// were padded with zero bytes to the length of the longest share.
+func Recombine(shares [][]byte) []byte {
+	var size int
+	for _, share := range shares {
+		if len(share) > size {
+			size = len(share)
+		}
+	}
+	secret := make([]byte, size)
+	for _, share := range shares {
+		for i, b := range share {
+			secret[i] ^= b
+		}
+	}
+	return secret
+}
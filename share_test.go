@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2024 Markku Rossi
+//
+// All rights reserved.
+//
+
+package mpc
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/markkurossi/mpc/compiler"
+	"github.com/markkurossi/mpc/compiler/utils"
+	"github.com/markkurossi/mpc/p2p"
+)
+
+func TestSplitSecret(t *testing.T) {
+	secret := []byte("a secret value")
+
+	for n := 1; n <= 5; n++ {
+		shares, err := SplitSecret(secret, n)
+		if err != nil {
+			t.Fatalf("SplitSecret(%d): %s", n, err)
+		}
+		if len(shares) != n {
+			t.Fatalf("SplitSecret(%d): got %d shares", n, len(shares))
+		}
+		for _, share := range shares {
+			if len(share) != len(secret) {
+				t.Fatalf("SplitSecret(%d): invalid share length %d",
+					n, len(share))
+			}
+		}
+		result := Recombine(shares)
+		if !bytes.Equal(result, secret) {
+			t.Errorf("Recombine(%d): got %x, expected %x", n, result, secret)
+		}
+	}
+	if _, err := SplitSecret(secret, 0); err == nil {
+		t.Errorf("SplitSecret(0) succeeded")
+	}
+}
+
+func TestRecombineCircuit(t *testing.T) {
+	params := utils.NewParams()
+	circ, _, err := compiler.New(params).Compile(`
+package main
+
+import (
+    "mpc"
+)
+
+func main(g, e [16]byte) []byte {
+    return mpc.Recombine(g, e)
+}
+`, nil)
+	if err != nil {
+		t.Fatalf("compile failed: %s", err)
+	}
+
+	secret := []byte("0123456789abcdef")
+	shares, err := SplitSecret(secret, 2)
+	if err != nil {
+		t.Fatalf("SplitSecret: %s", err)
+	}
+
+	gConn, eConn := p2p.Pipe()
+	defer gConn.Close()
+	defer eConn.Close()
+
+	ch := make(chan error)
+	go func() {
+		_, err := Run(Evaluator, "", circ, []*big.Int{arrayValue(shares[1])},
+			WithConn(eConn))
+		ch <- err
+	}()
+
+	values, err := Run(Garbler, "", circ, []*big.Int{arrayValue(shares[0])},
+		WithConn(gConn))
+	if err != nil {
+		t.Fatalf("garbler failed: %s", err)
+	}
+	if err := <-ch; err != nil {
+		t.Fatalf("evaluator failed: %s", err)
+	}
+	results := Results(values, circ.Outputs)
+	if !bytes.Equal(results[0].([]byte), secret) {
+		t.Errorf("got %x, expected %x", results[0], secret)
+	}
+}
+
+// arrayValue returns the byte array data as a circuit input value:
+// the array element 0 is in the least significant bits of the value.
+func arrayValue(data []byte) *big.Int {
+	v := new(big.Int)
+	for i := len(data) - 1; i >= 0; i-- {
+		v.Lsh(v, 8)
+		v.Or(v, big.NewInt(int64(data[i])))
+	}
+	return v
+}
@@ -49,6 +49,26 @@ func TestSuite(t *testing.T) {
 		})
 }
 
+// TestSuiteCoalesceMovs runs the testsuite with the mov coalescing
+// optimization that the garbled application enables with -O.
+func TestSuiteCoalesceMovs(t *testing.T) {
+	params := utils.NewParams()
+	params.MPCLCErrorLoc = true
+	params.OptCoalesceMovs = true
+
+	filepath.WalkDir(testsuite,
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			testFile(t, compiler.New(params), path)
+			return nil
+		})
+}
+
 func testFile(t *testing.T, cc *compiler.Compiler, file string) {
 	if !strings.HasSuffix(file, ".mpcl") {
 		return
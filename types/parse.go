@@ -17,6 +17,8 @@ import (
 var (
 	reArr   = regexp.MustCompilePOSIX(`^\[([[:digit:]]*)\](.+)$`)
 	reSized = regexp.MustCompilePOSIX(`^([[:alpha:]]+)([[:digit:]]*)$`)
+	reFixed = regexp.MustCompilePOSIX(
+		`^fixed<([[:digit:]]+),([[:digit:]]+)>$`)
 )
 
 // Parse parses type definition and returns its type information.
@@ -37,7 +39,21 @@ func Parse(val string) (info Info, err error) {
 		return
 	}
 
-	m := reSized.FindStringSubmatch(val)
+	m := reFixed.FindStringSubmatch(val)
+	if m != nil {
+		var intBits, fracBits int64
+		intBits, err = strconv.ParseInt(m[1], 10, 32)
+		if err != nil {
+			return
+		}
+		fracBits, err = strconv.ParseInt(m[2], 10, 32)
+		if err != nil {
+			return
+		}
+		return Fixed(Size(intBits), Size(fracBits))
+	}
+
+	m = reSized.FindStringSubmatch(val)
 	if m != nil {
 		switch m[1] {
 		case "b", "bool":
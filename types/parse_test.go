@@ -95,6 +95,16 @@ var parseTests = []struct {
 			ArraySize: 0,
 		},
 	},
+	{
+		input: "fixed<16,16>",
+		info: Info{
+			Type:       TFixed,
+			IsConcrete: true,
+			Bits:       32,
+			MinBits:    32,
+			FracBits:   16,
+		},
+	},
 }
 
 func TestParse(t *testing.T) {
@@ -60,6 +60,9 @@ const (
 	TSlice
 	TPtr
 	TNil
+	TFixed
+	TChan
+	TFunc
 )
 
 // Types define MPCL types and their names.
@@ -75,6 +78,9 @@ var Types = map[string]Type{
 	"slice":       TSlice,
 	"ptr":         TPtr,
 	"nil":         TNil,
+	"fixed":       TFixed,
+	"chan":        TChan,
+	"func":        TFunc,
 }
 
 var shortTypes = map[Type]string{
@@ -89,6 +95,9 @@ var shortTypes = map[Type]string{
 	TSlice:     "slice",
 	TPtr:       "*",
 	TNil:       "nil",
+	TFixed:     "fx",
+	TChan:      "chan",
+	TFunc:      "func",
 }
 
 // Info specifies information about a type.
@@ -98,6 +107,7 @@ type Info struct {
 	IsConcrete  bool
 	Bits        Size
 	MinBits     Size
+	FracBits    Size
 	Struct      []StructField
 	ElementType *Info
 	ArraySize   Size
@@ -164,6 +174,22 @@ var Uint64 = Info{
 	MinBits:    64,
 }
 
+// Fixed creates type info for signed fixed-point values with intBits
+// integer bits (including the sign bit) and fracBits fractional bits.
+func Fixed(intBits, fracBits Size) (Info, error) {
+	if intBits < 1 || fracBits < 0 {
+		return Undefined, fmt.Errorf("invalid fixed-point type fixed<%d,%d>",
+			intBits, fracBits)
+	}
+	return Info{
+		Type:       TFixed,
+		IsConcrete: true,
+		Bits:       intBits + fracBits,
+		MinBits:    intBits + fracBits,
+		FracBits:   fracBits,
+	}, nil
+}
+
 // StructField defines a structure field name and type.
 type StructField struct {
 	Name string
@@ -186,6 +212,15 @@ func (i Info) String() string {
 	case TPtr:
 		return fmt.Sprintf("*%s", i.ElementType)
 
+	case TChan:
+		return fmt.Sprintf("chan %s", i.ElementType)
+
+	case TFunc:
+		return i.Type.String()
+
+	case TFixed:
+		return fmt.Sprintf("fixed<%d,%d>", i.Bits-i.FracBits, i.FracBits)
+
 	default:
 		if !i.Concrete() {
 			return i.Type.String()
@@ -202,9 +237,29 @@ func (i Info) ShortString() string {
 	if i.Type == TPtr {
 		return fmt.Sprintf("*%s", i.ElementType.ShortString())
 	}
+	if i.Type == TChan {
+		return fmt.Sprintf("chan %s", i.ElementType.ShortString())
+	}
+	if i.Type == TFunc {
+		return i.Type.ShortString()
+	}
+	if i.Type == TFixed {
+		return fmt.Sprintf("fx%d.%d", i.Bits-i.FracBits, i.FracBits)
+	}
 	return fmt.Sprintf("%s%d", i.Type.ShortString(), i.Bits)
 }
 
+// Cap returns the capacity of the array or slice type. The slices of
+// the three-index slice expressions a[lo:hi:max] hold max-lo elements
+// of which the first ArraySize elements are the slice elements.
+func (i Info) Cap() Size {
+	if i.Type == TSlice && i.ElementType != nil && i.ElementType.Bits > 0 &&
+		i.Bits > i.ArraySize*i.ElementType.Bits {
+		return i.Bits / i.ElementType.Bits
+	}
+	return i.ArraySize
+}
+
 // Undefined tests if type is undefined.
 func (i Info) Undefined() bool {
 	return i.Type == TUndefined
@@ -346,7 +401,7 @@ func (i *Info) InstantiateWithSizes(sizes []int) error {
 	}
 
 	switch i.Type {
-	case TBool:
+	case TBool, TFixed:
 
 	case TInt, TUint, TFloat:
 		if !i.Concrete() {
@@ -407,6 +462,9 @@ func (i Info) Equal(o Info) bool {
 	case TUndefined, TBool, TInt, TUint, TFloat, TString:
 		return i.Bits == o.Bits
 
+	case TFixed:
+		return i.Bits == o.Bits && i.FracBits == o.FracBits
+
 	case TStruct:
 		if len(i.Struct) != len(o.Struct) || i.Bits != o.Bits {
 			return false
@@ -424,9 +482,14 @@ func (i Info) Equal(o Info) bool {
 		}
 		return i.ElementType.Equal(*o.ElementType)
 
-	case TPtr:
+	case TPtr, TChan:
 		return i.ElementType.Equal(*o.ElementType)
 
+	case TFunc:
+		// The function signatures are checked when the function
+		// values are bound to the function arguments.
+		return true
+
 	default:
 		panic(fmt.Sprintf("Info.Equal called for %v (%T)", i.Type, i.Type))
 	}
@@ -442,6 +505,9 @@ func (i Info) Specializable(o Info) bool {
 	case TUndefined, TBool, TInt, TUint, TFloat, TString:
 		return !i.Concrete() || i.Bits == o.Bits
 
+	case TFixed:
+		return i.Bits == o.Bits && i.FracBits == o.FracBits
+
 	case TStruct:
 		if len(i.Struct) != len(o.Struct) ||
 			(i.Concrete() && i.Bits != o.Bits) {
@@ -463,7 +529,7 @@ func (i Info) Specializable(o Info) bool {
 	case TSlice:
 		return i.ElementType.Specializable(*o.ElementType)
 
-	case TPtr:
+	case TPtr, TChan:
 		return i.ElementType.Specializable(*o.ElementType)
 
 	default:
@@ -479,9 +545,23 @@ func (i Info) CanAssignConst(o Info) bool {
 	case TInt, TUint:
 		return (o.Type == TInt || o.Type == TUint) && i.Bits >= o.MinBits
 
+	case TFixed:
+		return o.Type == TFixed && i.FracBits == o.FracBits &&
+			i.Bits >= o.MinBits
+
 	case TSlice:
 		return o.Type.Array() && i.ElementType.Equal(*o.ElementType)
 
+	case TChan:
+		return o.Type == TChan && i.ElementType.Equal(*o.ElementType)
+
+	case TPtr:
+		if o.Type == TNil {
+			// nil is the zero value of pointers.
+			return true
+		}
+		return i.Type == o.Type && i.Bits >= o.MinBits
+
 	case TArray:
 		if o.Type == TNil {
 			return true
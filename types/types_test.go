@@ -62,6 +62,33 @@ func TestInstantiate(t *testing.T) {
 	testInstantiate(t, st, at10Ptr)
 }
 
+func TestCap(t *testing.T) {
+	at10 := Info{
+		Type:        TArray,
+		IsConcrete:  true,
+		Bits:        10 * 8,
+		MinBits:     10 * 8,
+		ElementType: &Byte,
+		ArraySize:   10,
+	}
+	if c := at10.Cap(); c != 10 {
+		t.Errorf("cap(%v)=%v, expected 10", at10, c)
+	}
+
+	// Slice with 2 elements and capacity of 3 elements.
+	st := Info{
+		Type:        TSlice,
+		IsConcrete:  true,
+		Bits:        3 * 8,
+		MinBits:     3 * 8,
+		ElementType: &Byte,
+		ArraySize:   2,
+	}
+	if c := st.Cap(); c != 3 {
+		t.Errorf("cap(%v)=%v, expected 3", st, c)
+	}
+}
+
 func testInstantiate(t *testing.T, i, o Info) {
 	if !i.Instantiate(o) {
 		t.Errorf("%v.Instantiate(%v) failed", i, o)